@@ -16,7 +16,6 @@ import (
 	"github.com/pomerium/pomerium/internal/cryptutil"
 	"github.com/pomerium/pomerium/internal/encoding"
 	"github.com/pomerium/pomerium/internal/encoding/ecjson"
-	"github.com/pomerium/pomerium/internal/encoding/jws"
 	"github.com/pomerium/pomerium/internal/frontend"
 	"github.com/pomerium/pomerium/internal/grpc"
 	"github.com/pomerium/pomerium/internal/grpc/cache"
@@ -28,6 +27,7 @@ import (
 	"github.com/pomerium/pomerium/internal/sessions/cookie"
 	"github.com/pomerium/pomerium/internal/sessions/header"
 	"github.com/pomerium/pomerium/internal/sessions/queryparam"
+	"github.com/pomerium/pomerium/internal/sessions/revocation"
 	"github.com/pomerium/pomerium/internal/urlutil"
 )
 
@@ -74,6 +74,9 @@ type Authenticate struct {
 	// sharedEncoder is the encoder to use to serialize data to be consumed
 	// by other services
 	sharedEncoder encoding.MarshalUnmarshaler
+	// routeEncoders sign the sessions of routes with their own shared key,
+	// by route host
+	routeEncoders map[string]encoding.MarshalUnmarshaler
 
 	// values related to user sessions
 	//
@@ -90,6 +93,8 @@ type Authenticate struct {
 	// sessionLoaders are a collection of session loaders to attempt to pull
 	// a user's session state from
 	sessionLoaders []sessions.SessionLoader
+	// revocations is the store of sessions revoked by signing out
+	revocations revocation.Store
 
 	// provider is the interface to interacting with the identity provider (IdP)
 	provider identity.Authenticator
@@ -110,7 +115,7 @@ func New(opts config.Options) (*Authenticate, error) {
 
 	// shared state encoder setup
 	sharedCipher, _ := cryptutil.NewAEADCipherFromBase64(opts.SharedKey)
-	sharedEncoder, err := jws.NewHS256Signer([]byte(opts.SharedKey), opts.GetAuthenticateURL().Host)
+	sharedEncoder, err := opts.GetSessionEncoder([]byte(opts.SharedKey), opts.GetAuthenticateURL().Host)
 	if err != nil {
 		return nil, err
 	}
@@ -121,11 +126,13 @@ func New(opts config.Options) (*Authenticate, error) {
 	encryptedEncoder := ecjson.New(cookieCipher)
 
 	cookieOptions := &cookie.Options{
-		Name:     opts.CookieName,
-		Domain:   opts.CookieDomain,
-		Secure:   opts.CookieSecure,
-		HTTPOnly: opts.CookieHTTPOnly,
-		Expire:   opts.CookieExpire,
+		Name:           opts.CookieName,
+		Domain:         opts.CookieDomain,
+		Secure:         opts.CookieSecure,
+		HTTPOnly:       opts.CookieHTTPOnly,
+		Expire:         opts.CookieExpire,
+		MaxChunks:      opts.CookieMaxChunks,
+		ChunkThreshold: opts.CookieChunkThreshold,
 	}
 
 	cookieStore, err := cookie.NewStore(cookieOptions, sharedEncoder)
@@ -149,6 +156,18 @@ func New(opts config.Options) (*Authenticate, error) {
 
 	cacheClient := client.New(cacheConn)
 
+	revocations, err := revocation.New(&revocation.Options{
+		RedisAddr:     opts.SessionRevocationStoreAddr,
+		RedisPassword: opts.SessionRevocationStorePassword,
+		RetryAttempts: opts.SessionRevocationStoreRetryAttempts,
+		RetryDelay:    opts.SessionRevocationStoreRetryDelay,
+		MaxConns:      opts.SessionRevocationStoreMaxConnections,
+		PoolTimeout:   opts.SessionRevocationStorePoolTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	qpStore := queryparam.NewStore(encryptedEncoder, urlutil.QueryProgrammaticToken)
 	headerStore := header.NewStore(encryptedEncoder, httputil.AuthorizationTypePomerium)
 
@@ -183,6 +202,7 @@ func New(opts config.Options) (*Authenticate, error) {
 		sessionStore:     cookieStore,
 		encryptedEncoder: encryptedEncoder,
 		sessionLoaders:   []sessions.SessionLoader{qpStore, headerStore, cookieStore},
+		revocations:      revocations,
 		// IdP
 		provider: provider,
 		// grpc client for cache
@@ -191,6 +211,10 @@ func New(opts config.Options) (*Authenticate, error) {
 		templates:   template.Must(frontend.NewTemplates()),
 	}
 
+	if a.routeEncoders, err = newRouteEncoders(&opts); err != nil {
+		return nil, err
+	}
+
 	if opts.SigningKey != "" {
 		decodedCert, err := base64.StdEncoding.DecodeString(opts.SigningKey)
 		if err != nil {
@@ -205,3 +229,36 @@ func New(opts config.Options) (*Authenticate, error) {
 
 	return a, nil
 }
+
+// newRouteEncoders returns the encoders of routes with their own shared key,
+// by route host.
+func newRouteEncoders(opts *config.Options) (map[string]encoding.MarshalUnmarshaler, error) {
+	encoders := make(map[string]encoding.MarshalUnmarshaler)
+	for i := range opts.Policies {
+		policy := &opts.Policies[i]
+		if policy.SharedKeyRef == "" || policy.Source == nil {
+			continue
+		}
+		key, ok := opts.GetSharedKey(policy)
+		if !ok {
+			return nil, fmt.Errorf("authenticate: unknown shared key %q", policy.SharedKeyRef)
+		}
+		encoder, err := opts.GetSessionEncoder([]byte(key), opts.GetAuthenticateURL().Host)
+		if err != nil {
+			return nil, err
+		}
+		encoders[policy.Source.Host] = encoder
+	}
+	return encoders, nil
+}
+
+// getRouteEncoder returns the encoder of the first of the audience's routes
+// with its own shared key, or the shared encoder if there isn't one.
+func (a *Authenticate) getRouteEncoder(audience []string) encoding.MarshalUnmarshaler {
+	for _, host := range audience {
+		if encoder, ok := a.routeEncoders[host]; ok {
+			return encoder
+		}
+	}
+	return a.sharedEncoder
+}
@@ -4,6 +4,9 @@ import (
 	"testing"
 
 	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/encoding/jws"
+	"github.com/pomerium/pomerium/internal/sessions"
 )
 
 func newTestOptions(t *testing.T) *config.Options {
@@ -133,3 +136,44 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthenticate_getRouteEncoder(t *testing.T) {
+	opts := newTestOptions(t)
+	opts.SharedKeys = map[string]string{"tenant-a": cryptutil.NewBase64Key()}
+	opts.Policies = []config.Policy{
+		{From: "https://a.example.com", To: "http://localhost", SharedKeyRef: "tenant-a"},
+		{From: "https://shared.example.com", To: "http://localhost"},
+	}
+	if err := opts.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	sharedEncoder, err := jws.NewHS256Signer([]byte(opts.SharedKey), "authenticate.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Authenticate{sharedEncoder: sharedEncoder}
+	if a.routeEncoders, err = newRouteEncoders(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// the route's sessions are only verified by its own key
+	state := &sessions.State{Subject: "user"}
+	raw, err := a.getRouteEncoder([]string{"authenticate.example", "a.example.com"}).Marshal(state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantEncoder, err := jws.NewHS256Signer([]byte(opts.SharedKeys["tenant-a"]), "authenticate.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tenantEncoder.Unmarshal(raw, &sessions.State{}); err != nil {
+		t.Errorf("route session isn't signed with the route's key: %v", err)
+	}
+	if err := a.sharedEncoder.Unmarshal(raw, &sessions.State{}); err == nil {
+		t.Error("route session is signed with the shared key")
+	}
+
+	if got := a.getRouteEncoder([]string{"authenticate.example", "shared.example.com"}); got != a.sharedEncoder {
+		t.Error("expected the shared encoder for a route without its own key")
+	}
+}
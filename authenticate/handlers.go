@@ -25,6 +25,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 	"golang.org/x/oauth2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Handler returns the authenticate service's handler chain.
@@ -250,8 +252,8 @@ func (a *Authenticate) SignIn(w http.ResponseWriter, r *http.Request) error {
 		callbackParams.Set(urlutil.QueryIsProgrammatic, "true")
 	}
 
-	// sign the route session, as a JWT
-	signedJWT, err := a.sharedEncoder.Marshal(newSession)
+	// sign the route session, as a JWT, with the route's key if it has its own
+	signedJWT, err := a.getRouteEncoder(jwtAudience).Marshal(newSession)
 	if err != nil {
 		return httputil.NewError(http.StatusBadRequest, err)
 	}
@@ -300,7 +302,17 @@ func (a *Authenticate) SignOut(w http.ResponseWriter, r *http.Request) error {
 		return nil
 	}
 
+	// revoke the session so that any copies of it stop working before they
+	// expire
+	if a.revocations != nil && s.ID != "" && s.Expiry != nil {
+		if err := a.revocations.Revoke(ctx, s.ID, time.Until(s.Expiry.Time())); err != nil {
+			log.Warn().Err(err).Msg("authenticate.SignOut: failed revoking session")
+		}
+	}
+
 	accessToken, err := a.getAccessToken(ctx, s)
+	// the access token is no longer needed, so remove it from the cache
+	a.deleteAccessToken(ctx, s)
 	if err != nil {
 		log.Warn().Err(err).Msg("authenticate.SignOut: failed getting access token")
 		return nil
@@ -395,6 +407,9 @@ func (a *Authenticate) getOAuthCallback(w http.ResponseWriter, r *http.Request)
 		a.RedirectURL.Hostname(),
 		[]string{a.RedirectURL.Hostname()},
 		accessToken)
+	// each sign in gets a unique id, which is kept on refresh, so that the
+	// session can be revoked
+	newState.ID = cryptutil.NewRandomStringN(16)
 
 	// state includes a csrf nonce (validated by middleware) and redirect uri
 	bytes, err := base64.URLEncoding.DecodeString(r.FormValue("state"))
@@ -469,7 +484,7 @@ func (a *Authenticate) RefreshAPI(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 
-	signedJWT, err := a.sharedEncoder.Marshal(routeNewSession)
+	signedJWT, err := a.getRouteEncoder(routeNewSession.Audience).Marshal(routeNewSession)
 	if err != nil {
 		return err
 	}
@@ -553,6 +568,18 @@ func (a *Authenticate) setAccessToken(ctx context.Context, accessToken *oauth2.T
 	return nil
 }
 
+// deleteAccessToken removes a session's access token from the cache. Caches
+// which can't delete keys are ignored, as the session is revoked regardless.
+func (a *Authenticate) deleteAccessToken(ctx context.Context, s *sessions.State) {
+	if s.AccessTokenHash == "" {
+		return
+	}
+	err := a.cacheClient.Delete(ctx, s.AccessTokenHash)
+	if err != nil && status.Code(err) != codes.Unimplemented {
+		log.Warn().Err(err).Msg("authenticate: failed deleting access token")
+	}
+}
+
 func (a *Authenticate) timestampedHash(s string) string {
 	return fmt.Sprintf("%x-%v", hashutil.Hash(s), time.Now().Truncate(time.Minute).Unix())
 }
@@ -1,6 +1,7 @@
 package authenticate
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -24,6 +25,7 @@ import (
 	"github.com/pomerium/pomerium/internal/sessions"
 	"github.com/pomerium/pomerium/internal/sessions/cookie"
 	mstore "github.com/pomerium/pomerium/internal/sessions/mock"
+	"github.com/pomerium/pomerium/internal/sessions/revocation"
 	"github.com/pomerium/pomerium/internal/urlutil"
 
 	"github.com/golang/mock/gomock"
@@ -32,6 +34,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/chacha20poly1305"
 	"golang.org/x/oauth2"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"gopkg.in/square/go-jose.v2/jwt"
 )
 
@@ -251,6 +255,117 @@ func TestAuthenticate_SignOut(t *testing.T) {
 	}
 }
 
+func TestAuthenticate_SignOut_revokesSession(t *testing.T) {
+	t.Parallel()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mc := mock_cache.NewMockCacher(ctrl)
+	mc.EXPECT().Get(gomock.Any(), gomock.Any()).Return([]byte("hi"), nil).AnyTimes()
+
+	secret := cryptutil.NewKey()
+	sharedEncoder, err := jws.NewHS256Signer(secret, "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revocations := revocation.NewMemoryStore()
+	sessionStore := &mstore.Store{Encrypted: true, Secret: secret, Session: &sessions.State{
+		ID:     "session-id",
+		Email:  "user@pomerium.io",
+		Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+	a := &Authenticate{
+		sessionStore:     sessionStore,
+		provider:         identity.MockProvider{},
+		encryptedEncoder: mock.Encoder{},
+		templates:        template.Must(frontend.NewTemplates()),
+		sharedEncoder:    sharedEncoder,
+		cacheClient:      mc,
+		revocations:      revocations,
+	}
+	r := httptest.NewRequest(http.MethodPost, "/sign_out?"+urlutil.QueryRedirectURI+"=https://corp.pomerium.io/", nil)
+	state, err := sessionStore.LoadSession(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r = r.WithContext(sessions.NewContext(r.Context(), state, nil))
+
+	w := httptest.NewRecorder()
+	httputil.HandlerFunc(a.SignOut).ServeHTTP(w, r)
+
+	revoked, err := revocations.IsRevoked(context.Background(), "session-id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !revoked {
+		t.Error("expected session to be revoked on sign out")
+	}
+}
+
+func TestAuthenticate_SignOut_deletesAccessToken(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		deleteErr error
+	}{
+		{"deleted", nil},
+		{"cache can't delete", status.Error(codes.Unimplemented, "not supported")},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mc := mock_cache.NewMockCacher(ctrl)
+			mc.EXPECT().Get(gomock.Any(), gomock.Any()).Return([]byte("hi"), nil).AnyTimes()
+			mc.EXPECT().Delete(gomock.Any(), "token-hash").Return(tt.deleteErr).Times(1)
+
+			secret := cryptutil.NewKey()
+			sharedEncoder, err := jws.NewHS256Signer(secret, "mock")
+			if err != nil {
+				t.Fatal(err)
+			}
+			revocations := revocation.NewMemoryStore()
+			sessionStore := &mstore.Store{Encrypted: true, Secret: secret, Session: &sessions.State{
+				ID:              "session-id",
+				Email:           "user@pomerium.io",
+				Expiry:          jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				AccessTokenHash: "token-hash",
+			}}
+			a := &Authenticate{
+				sessionStore:     sessionStore,
+				provider:         identity.MockProvider{},
+				encryptedEncoder: mock.Encoder{},
+				templates:        template.Must(frontend.NewTemplates()),
+				sharedEncoder:    sharedEncoder,
+				cacheClient:      mc,
+				revocations:      revocations,
+			}
+			r := httptest.NewRequest(http.MethodPost, "/sign_out?"+urlutil.QueryRedirectURI+"=https://corp.pomerium.io/", nil)
+			state, err := sessionStore.LoadSession(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = r.WithContext(sessions.NewContext(r.Context(), state, nil))
+
+			w := httptest.NewRecorder()
+			httputil.HandlerFunc(a.SignOut).ServeHTTP(w, r)
+			if w.Code != http.StatusFound {
+				t.Errorf("handler returned wrong status code: got %v want %v", w.Code, http.StatusFound)
+			}
+
+			revoked, err := revocations.IsRevoked(context.Background(), "session-id")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !revoked {
+				t.Error("expected session to be revoked on sign out")
+			}
+		})
+	}
+}
+
 func TestAuthenticate_OAuthCallback(t *testing.T) {
 	t.Parallel()
 
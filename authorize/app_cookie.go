@@ -0,0 +1,72 @@
+package authorize
+
+import (
+	"net/http"
+	"time"
+
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/encoding/jws"
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+	"github.com/pomerium/pomerium/internal/httputil"
+)
+
+// appCookieLifetime is how long an app cookie is valid for. It's re-issued
+// on every allowed request, so is kept short.
+const appCookieLifetime = 5 * time.Minute
+
+// appCookieClaims are the claims of an app cookie.
+type appCookieClaims struct {
+	jwt.Claims
+	Email  string   `json:"email,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// getAppCookieHeader returns the header used to set the route's app cookie,
+// carrying the user's identity, on the response, or nil if the route doesn't
+// set one.
+func (a *Authorize) getAppCookieHeader(reply *authorize.IsAuthorizedReply, policy *config.Policy) (http.Header, error) {
+	if policy == nil || policy.AppCookieName == "" || reply.GetUser() == "" {
+		return nil, nil
+	}
+	key, err := policy.GetAppCookieKey()
+	if err != nil {
+		return nil, err
+	}
+	opts := a.currentOptions.Load()
+	signer, err := jws.NewHS256Signer(key, opts.GetAuthenticateURL().Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	claims := appCookieClaims{
+		Claims: jwt.Claims{
+			Issuer:   opts.GetAuthenticateURL().Hostname(),
+			Subject:  reply.GetUser(),
+			Audience: jwt.Audience{policy.Source.Hostname()},
+			IssuedAt: jwt.NewNumericDate(now),
+			Expiry:   jwt.NewNumericDate(now.Add(appCookieLifetime)),
+		},
+		Email:  reply.GetEmail(),
+		Groups: reply.GetGroups(),
+	}
+	value, err := signer.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	cookie := &http.Cookie{
+		Name:     policy.AppCookieName,
+		Value:    string(value),
+		Path:     "/",
+		MaxAge:   int(appCookieLifetime.Seconds()),
+		Secure:   opts.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	return http.Header{
+		http.CanonicalHeaderKey(httputil.HeaderPomeriumAppCookie): {cookie.String()},
+	}, nil
+}
@@ -0,0 +1,94 @@
+package authorize
+
+import (
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+	"github.com/pomerium/pomerium/internal/httputil"
+)
+
+func TestAuthorize_okResponse_appCookie(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	appKey := cryptutil.NewKey()
+	policy := config.Policy{
+		From:            "https://app.example.com",
+		To:              "http://localhost",
+		AppCookieName:   "_app_session",
+		AppCookieSecret: base64.StdEncoding.EncodeToString(appKey),
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	opts := *config.NewDefaultOptions()
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	opts.Policies = []config.Policy{policy}
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawJWT := []byte(testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour)))
+	reply := &authorize.IsAuthorizedReply{
+		Allow:  true,
+		User:   "user-1",
+		Email:  "bob@example.com",
+		Groups: []string{"admins"},
+	}
+
+	getAppCookies := func(policy *config.Policy) []*http.Cookie {
+		res := a.okResponse(reply, policy, rawJWT, false)
+		var hdrs []string
+		for _, hvo := range res.GetOkResponse().GetHeaders() {
+			if http.CanonicalHeaderKey(hvo.GetHeader().GetKey()) == http.CanonicalHeaderKey(httputil.HeaderPomeriumAppCookie) {
+				hdrs = append(hdrs, hvo.GetHeader().GetValue())
+			}
+		}
+		return (&http.Response{Header: http.Header{"Set-Cookie": hdrs}}).Cookies()
+	}
+
+	t.Run("set", func(t *testing.T) {
+		cookies := getAppCookies(&policy)
+		if !assert.Len(t, cookies, 1) {
+			return
+		}
+		cookie := cookies[0]
+		assert.Equal(t, "_app_session", cookie.Name)
+		assert.Equal(t, "/", cookie.Path)
+		assert.True(t, cookie.HttpOnly)
+		assert.True(t, cookie.Secure)
+		assert.Equal(t, int(appCookieLifetime.Seconds()), cookie.MaxAge)
+
+		tok, err := jwt.ParseSigned(cookie.Value)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var claims appCookieClaims
+		if !assert.NoError(t, tok.Claims(appKey, &claims)) {
+			return
+		}
+		assert.NoError(t, claims.Validate(jwt.Expected{
+			Issuer:   "authN.example.com",
+			Subject:  "user-1",
+			Audience: jwt.Audience{"app.example.com"},
+			Time:     time.Now(),
+		}))
+		assert.Equal(t, "bob@example.com", claims.Email)
+		assert.Equal(t, []string{"admins"}, claims.Groups)
+
+		// the cookie isn't signed with the shared key
+		assert.Error(t, tok.Claims([]byte(sharedKey), &claims))
+	})
+	t.Run("not configured", func(t *testing.T) {
+		assert.Empty(t, getAppCookies(&config.Policy{From: "https://app.example.com", To: "http://localhost"}))
+		assert.Empty(t, getAppCookies(nil))
+	})
+}
@@ -0,0 +1,150 @@
+package authorize
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/rs/zerolog"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/telemetry/requestid"
+)
+
+// auditLog writes an audit record of each authorization decision to a file.
+type auditLog struct {
+	file     *log.RotatingFile
+	logger   zerolog.Logger
+	requests bool
+	// reservedHeaderPrefix is the prefix of pomerium's headers, which are left
+	// out of recorded requests
+	reservedHeaderPrefix string
+
+	// mu is held to write records, so that the file isn't closed while
+	// they're being written
+	mu     sync.RWMutex
+	closed bool
+}
+
+// newAuditLog opens the audit log file, or returns nil if there isn't one.
+func newAuditLog(opts *config.Options) (*auditLog, error) {
+	if opts.AuditLogFile == "" {
+		return nil, nil
+	}
+	f, err := log.NewRotatingFile(opts.AuditLogFile,
+		int64(opts.AuditLogMaxSize)*1024*1024,
+		opts.AuditLogMaxAge,
+		opts.AuditLogMaxBackups,
+		opts.AuditLogFlushInterval)
+	if err != nil {
+		return nil, err
+	}
+	return &auditLog{
+		file:                 f,
+		logger:               zerolog.New(f).With().Timestamp().Str("stream", "audit").Logger(),
+		requests:             opts.AuditLogRequests,
+		reservedHeaderPrefix: opts.ReservedHeaderPrefix,
+	}, nil
+}
+
+// isAuditLogChanged reports whether the audit log options have changed.
+func isAuditLogChanged(prev, opts *config.Options) bool {
+	return prev.AuditLogFile != opts.AuditLogFile ||
+		prev.AuditLogMaxSize != opts.AuditLogMaxSize ||
+		prev.AuditLogMaxAge != opts.AuditLogMaxAge ||
+		prev.AuditLogMaxBackups != opts.AuditLogMaxBackups ||
+		prev.AuditLogFlushInterval != opts.AuditLogFlushInterval ||
+		prev.AuditLogRequests != opts.AuditLogRequests ||
+		prev.ReservedHeaderPrefix != opts.ReservedHeaderPrefix
+}
+
+// updateAuditLog replaces the audit log if its options have changed. Checks
+// record to the new log before the old one is closed, once the records being
+// written to it are.
+func (a *Authorize) updateAuditLog(prev, opts *config.Options) error {
+	if (a.auditLog.Load() != nil || opts.AuditLogFile == "") && !isAuditLogChanged(prev, opts) {
+		return nil
+	}
+	l, err := newAuditLog(opts)
+	if err != nil {
+		return err
+	}
+	prevLog := a.auditLog.Load()
+	a.auditLog.Store(l)
+	if err := prevLog.Close(); err != nil {
+		log.Warn().Err(err).Msg("authorize: error closing audit log")
+	}
+	return nil
+}
+
+// Record writes the audit record of an authorization decision for a request
+// from the client, and if enabled the evaluated request. Unlike the authorize
+// check log, it doesn't include credentials such as the session.
+func (l *auditLog) Record(ctx context.Context, in *envoy_service_auth_v2.CheckRequest, reply *authorize.IsAuthorizedReply, req *evaluator.Request, clientIP string) {
+	if l == nil {
+		return
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return
+	}
+	hattrs := in.GetAttributes().GetRequest().GetHttp()
+	evt := l.logger.Log()
+	if l.requests {
+		if bs, err := json.Marshal(getAuditLogRequest(req, l.reservedHeaderPrefix)); err == nil {
+			evt = evt.RawJSON("request", bs)
+		}
+	}
+	evt.
+		Str("request-id", requestid.FromContext(ctx)).
+		Str("source-ip", clientIP).
+		Str("method", hattrs.GetMethod()).
+		Str("host", hattrs.GetHost()).
+		Str("path", hattrs.GetPath()).
+		Str("email", reply.GetEmail()).
+		Strs("groups", reply.GetGroups()).
+		Bool("allow", reply.GetAllow()).
+		Strs("deny-reasons", reply.GetDenyReasons()).
+		Strs("deny-rule-ids", reply.GetDenyRuleIds()).
+		Msg("authorize decision")
+}
+
+// getAuditLogRequest returns a copy of the evaluated request without
+// credentials: the session and the headers left out of decision logs.
+func getAuditLogRequest(req *evaluator.Request, reservedHeaderPrefix string) *evaluator.Request {
+	if req == nil {
+		return nil
+	}
+	cp := *req
+	cp.User = ""
+	cp.Header = make(map[string][]string, len(req.Header))
+	for k, vs := range req.Header {
+		if !isDecisionLogSensitiveHeader(k, reservedHeaderPrefix) {
+			cp.Header[k] = vs
+		}
+	}
+	cp.RawHeaders = make(map[string]string, len(req.RawHeaders))
+	for k, v := range req.RawHeaders {
+		if !isDecisionLogSensitiveHeader(k, reservedHeaderPrefix) {
+			cp.RawHeaders[k] = v
+		}
+	}
+	return &cp
+}
+
+// Close syncs and closes the audit log file, once the records being written
+// are. Records after it's closed are dropped.
+func (l *auditLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	return l.file.Close()
+}
@@ -0,0 +1,153 @@
+package authorize
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+)
+
+func TestAuthorize_Check_auditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pomerium-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	opts.AuditLogFile = filepath.Join(dir, "audit.log")
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionJWTs := map[string]string{}
+	for _, email := range []string{"bob@example.com", "alice@example.com"} {
+		sessionJWTs[email] = testSessionJWT(t, sharedKey, email, "app.example.com", time.Now().Add(time.Hour))
+		_, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+			"cookie": "_pomerium=" + sessionJWTs[email],
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// records are written before the file is closed
+	f, err := os.Open(opts.AuditLogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var records []map[string]interface{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		for _, session := range sessionJWTs {
+			assert.False(t, strings.Contains(scanner.Text(), session), "audit record contains a session")
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	if !assert.Len(t, records, 2) {
+		return
+	}
+	assert.Equal(t, "audit", records[0]["stream"])
+	assert.NotContains(t, records[0], "request")
+	assert.Equal(t, "bob@example.com", records[0]["email"])
+	assert.Equal(t, true, records[0]["allow"])
+	assert.Equal(t, "app.example.com", records[0]["host"])
+	assert.Equal(t, "alice@example.com", records[1]["email"])
+	assert.Equal(t, false, records[1]["allow"])
+	assert.Equal(t, []interface{}{"route_policies[0]"}, records[1]["deny-rule-ids"])
+
+	// disabling the audit log closes the file
+	opts.AuditLogFile = ""
+	if err := a.UpdateOptions(opts); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, a.auditLog.Load())
+}
+
+func TestAuthorize_updateAuditLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pomerium-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := *config.NewDefaultOptions()
+	opts.AuditLogFile = filepath.Join(dir, "audit.log")
+	a := new(Authorize)
+	if err := a.updateAuditLog(&config.Options{}, &opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// checks keep recording while the audit log is replaced
+	in := testCheckRequest("GET", "https://app.example.com/", nil)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					a.auditLog.Load().Record(context.TODO(), in, nil, nil, "10.0.0.1")
+				}
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		prev := opts
+		opts.AuditLogRequests = !opts.AuditLogRequests
+		if err := a.updateAuditLog(&prev, &opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+	if !assert.NoError(t, a.auditLog.Load().Close()) {
+		return
+	}
+
+	// every record was written whole
+	f, err := os.Open(opts.AuditLogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record map[string]interface{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+	}
+}
@@ -6,11 +6,16 @@ package authorize
 
 import (
 	"context"
+	"crypto/x509"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/ioutil"
+	"net"
+	"regexp"
 	"sync/atomic"
+	"time"
 
 	"github.com/pomerium/pomerium/authorize/evaluator"
 	"github.com/pomerium/pomerium/authorize/evaluator/opa"
@@ -19,24 +24,92 @@ import (
 	"github.com/pomerium/pomerium/internal/encoding"
 	"github.com/pomerium/pomerium/internal/encoding/jws"
 	"github.com/pomerium/pomerium/internal/frontend"
+	"github.com/pomerium/pomerium/internal/geoip"
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
 	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/sessions/revocation"
 	"github.com/pomerium/pomerium/internal/telemetry/metrics"
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
 	"github.com/pomerium/pomerium/internal/urlutil"
 
+	lru "github.com/hashicorp/golang-lru"
+	"golang.org/x/sync/singleflight"
 	"gopkg.in/square/go-jose.v2"
 )
 
+// atomicOptions holds the options with the state derived from them, so checks
+// never see state derived from other options.
 type atomicOptions struct {
 	value atomic.Value
 }
 
+// optionsState is the options checks are made with, and the state derived
+// from them.
+type optionsState struct {
+	options config.Options
+
+	// routeEncoders sign and verify the sessions of routes with their own
+	// shared key, by key reference
+	routeEncoders map[string]encoding.MarshalUnmarshaler
+	// meshRoots verifies the client certificates of trusted mesh identities
+	meshRoots *x509.CertPool
+	// trustedProxies are the networks of proxies whose forwarded headers are
+	// trusted
+	trustedProxies []*net.IPNet
+	// healthProbes are the sources and paths of health probes allowed
+	// without a session
+	healthProbes []healthProbe
+	// deniedUserAgents match the user agents of requests denied for every
+	// route
+	deniedUserAgents []*regexp.Regexp
+	// overrideVerifier verifies break-glass override tokens, if enabled
+	overrideVerifier encoding.Unmarshaler
+	// innerTrustVerifier verifies identities forwarded by an outer pomerium,
+	// if enabled
+	innerTrustVerifier encoding.Unmarshaler
+	// routeLimits cap the concurrent checks of routes with a limit
+	routeLimits routeLimits
+	// policyLocation is the timezone of the local time policies are
+	// evaluated in
+	policyLocation *time.Location
+}
+
 func (a *atomicOptions) Load() config.Options {
-	return a.value.Load().(config.Options)
+	return a.LoadState().options
 }
 
+// Store replaces the options, without any state derived from them.
 func (a *atomicOptions) Store(options config.Options) {
-	a.value.Store(options)
+	a.StoreState(&optionsState{options: options})
+}
+
+func (a *atomicOptions) LoadState() *optionsState {
+	state, _ := a.value.Load().(*optionsState)
+	if state == nil {
+		return &optionsState{}
+	}
+	return state
+}
+
+func (a *atomicOptions) StoreState(state *optionsState) {
+	a.value.Store(state)
+}
+
+type atomicError struct {
+	value atomic.Value
+}
+
+type errorValue struct {
+	err error
+}
+
+func (a *atomicError) Load() error {
+	v, _ := a.value.Load().(errorValue)
+	return v.err
+}
+
+func (a *atomicError) Store(err error) {
+	a.value.Store(errorValue{err: err})
 }
 
 type atomicMarshalUnmarshaler struct {
@@ -51,13 +124,102 @@ func (a *atomicMarshalUnmarshaler) Store(encoder encoding.MarshalUnmarshaler) {
 	a.value.Store(encoder)
 }
 
+type atomicAuditLog struct {
+	value atomic.Value
+}
+
+func (a *atomicAuditLog) Load() *auditLog {
+	l, _ := a.value.Load().(*auditLog)
+	return l
+}
+
+func (a *atomicAuditLog) Store(l *auditLog) {
+	a.value.Store(l)
+}
+
+type atomicDecisionLog struct {
+	value atomic.Value
+}
+
+func (a *atomicDecisionLog) Load() *decisionLog {
+	l, _ := a.value.Load().(*decisionLog)
+	return l
+}
+
+func (a *atomicDecisionLog) Store(l *decisionLog) {
+	a.value.Store(l)
+}
+
+type atomicGeoIP struct {
+	value atomic.Value
+}
+
+func (a *atomicGeoIP) Load() *geoip.DB {
+	db, _ := a.value.Load().(*geoip.DB)
+	return db
+}
+
+func (a *atomicGeoIP) Store(db *geoip.DB) {
+	a.value.Store(db)
+}
+
+// atomicRevocationStore holds the store in a struct, since the stores it
+// replaces have different concrete types.
+type atomicRevocationStore struct {
+	value atomic.Value
+}
+
+type revocationStoreValue struct {
+	store revocation.Store
+}
+
+func (a *atomicRevocationStore) Load() revocation.Store {
+	v, _ := a.value.Load().(revocationStoreValue)
+	return v.store
+}
+
+func (a *atomicRevocationStore) Store(store revocation.Store) {
+	a.value.Store(revocationStoreValue{store})
+}
+
 // Authorize struct holds
 type Authorize struct {
+	// authorization decisions are made by Check, for envoy, rather than by
+	// the Authorizer service's IsAuthorized, which serves GetVersion
+	authorize.UnimplementedAuthorizerServer
+
 	pe evaluator.Evaluator
 
 	currentOptions atomicOptions
 	currentEncoder atomicMarshalUnmarshaler
 	templates      *template.Template
+
+	// refreshGroup de-duplicates concurrent session refreshes
+	refreshGroup singleflight.Group
+	// refreshedSessions are the recent refreshes, by session, reused for
+	// the min refresh interval
+	refreshedSessions *lru.Cache
+	// authenticateHealth is used to fail over between authenticate urls
+	authenticateHealth authenticateHealth
+	// securityEvents emits denied requests to the security log stream
+	securityEvents securityEvents
+	// revocations is the store of sessions revoked by signing out. It's
+	// replaced while checks may be looking up sessions.
+	revocations atomicRevocationStore
+	// auditLog records authorization decisions to the audit log file, if
+	// configured. It's replaced while checks may be recording to it.
+	auditLog atomicAuditLog
+	// decisionLog sends authorization decisions to the decision log url, if
+	// configured. It's replaced while checks may be recording to it.
+	decisionLog atomicDecisionLog
+	// geoip is the database client locations are looked up in, if
+	// configured. It's replaced while checks may be looking up locations.
+	geoip atomicGeoIP
+	// tenantQuotas count the requests of tenants with a quota
+	tenantQuotas tenantQuotas
+	// sharedKeyErr is why the last shared key update was rejected, if it was,
+	// and fails the signer self-check
+	sharedKeyErr atomicError
 }
 
 // New validates and creates a new Authorize service from a set of config options.
@@ -68,19 +230,10 @@ func New(opts config.Options) (*Authorize, error) {
 	a := Authorize{
 		templates: template.Must(frontend.NewTemplates()),
 	}
-
-	var host string
-	if opts.AuthenticateURL != nil {
-		host = opts.AuthenticateURL.Host
-	}
-	encoder, err := jws.NewHS256Signer([]byte(opts.SharedKey), host)
-	if err != nil {
-		return nil, err
-	}
-	a.currentEncoder.Store(encoder)
+	a.refreshedSessions, _ = lru.New(refreshedSessionsSize)
 
 	a.currentOptions.Store(config.Options{})
-	err = a.UpdateOptions(opts)
+	err := a.UpdateOptions(opts)
 	if err != nil {
 		return nil, err
 	}
@@ -88,8 +241,8 @@ func New(opts config.Options) (*Authorize, error) {
 }
 
 func validateOptions(o config.Options) error {
-	if _, err := cryptutil.NewAEADCipherFromBase64(o.SharedKey); err != nil {
-		return fmt.Errorf("bad shared_secret: %w", err)
+	if err := validateSharedKey(o.SharedKey); err != nil {
+		return err
 	}
 	if err := urlutil.ValidateURL(o.AuthenticateURL); err != nil {
 		return fmt.Errorf("invalid 'AUTHENTICATE_SERVICE_URL': %w", err)
@@ -97,6 +250,47 @@ func validateOptions(o config.Options) error {
 	return nil
 }
 
+// validateSharedKey returns an error if the shared key can't be used to
+// securely sign sessions with HS256, which requires a 256 bit key.
+func validateSharedKey(sharedKey string) error {
+	if sharedKey == "" {
+		return errors.New("bad shared_secret: cannot be empty")
+	}
+	key, err := base64.StdEncoding.DecodeString(sharedKey)
+	if err != nil {
+		return fmt.Errorf("bad shared_secret: %w", err)
+	}
+	if len(key) < 32 {
+		return fmt.Errorf("bad shared_secret: must be at least 32 bytes, got %d", len(key))
+	}
+	if _, err := cryptutil.NewAEADCipher(key); err != nil {
+		return fmt.Errorf("bad shared_secret: %w", err)
+	}
+	return nil
+}
+
+// newRouteEncoders returns the encoders of the shared keys routes reference,
+// by reference.
+func newRouteEncoders(opts *config.Options) (map[string]encoding.MarshalUnmarshaler, error) {
+	encoders := make(map[string]encoding.MarshalUnmarshaler)
+	for i := range opts.Policies {
+		ref := opts.Policies[i].SharedKeyRef
+		if ref == "" || encoders[ref] != nil {
+			continue
+		}
+		key, ok := opts.GetSharedKey(&opts.Policies[i])
+		if !ok {
+			return nil, fmt.Errorf("authorize: unknown shared key %q", ref)
+		}
+		encoder, err := opts.GetSessionEncoder([]byte(key), getAuthenticateHost(*opts))
+		if err != nil {
+			return nil, err
+		}
+		encoders[ref] = encoder
+	}
+	return encoders, nil
+}
+
 // newPolicyEvaluator returns an policy evaluator.
 func newPolicyEvaluator(opts *config.Options) (evaluator.Evaluator, error) {
 	metrics.AddPolicyCountCallback("authorize", func() int64 {
@@ -129,19 +323,9 @@ func newPolicyEvaluator(opts *config.Options) (evaluator.Evaluator, error) {
 		jwk.Key = keyBytes
 	}
 
-	var clientCA string
-	if opts.ClientCA != "" {
-		bs, err := base64.StdEncoding.DecodeString(opts.ClientCA)
-		if err != nil {
-			return nil, fmt.Errorf("authorize: invalid client ca: %w", err)
-		}
-		clientCA = string(bs)
-	} else if opts.ClientCAFile != "" {
-		bs, err := ioutil.ReadFile(opts.ClientCAFile)
-		if err != nil {
-			return nil, fmt.Errorf("authorize: invalid client ca file: %w", err)
-		}
-		clientCA = string(bs)
+	clientCA, err := getClientCA(opts)
+	if err != nil {
+		return nil, err
 	}
 
 	data := map[string]interface{}{
@@ -151,11 +335,30 @@ func newPolicyEvaluator(opts *config.Options) (evaluator.Evaluator, error) {
 		"signing_key":      jwk,
 		"authenticate_url": opts.AuthenticateURLString,
 		"client_ca":        clientCA,
+		"no_policy_match":  opts.NoPolicyMatch,
 	}
 
 	return opa.New(ctx, &opa.Options{Data: data})
 }
 
+// getClientCA returns the PEM encoded client certificate authority, if any.
+func getClientCA(opts *config.Options) (string, error) {
+	if opts.ClientCA != "" {
+		bs, err := base64.StdEncoding.DecodeString(opts.ClientCA)
+		if err != nil {
+			return "", fmt.Errorf("authorize: invalid client ca: %w", err)
+		}
+		return string(bs), nil
+	} else if opts.ClientCAFile != "" {
+		bs, err := ioutil.ReadFile(opts.ClientCAFile)
+		if err != nil {
+			return "", fmt.Errorf("authorize: invalid client ca file: %w", err)
+		}
+		return string(bs), nil
+	}
+	return "", nil
+}
+
 // UpdateOptions implements the OptionsUpdater interface and updates internal
 // structures based on config.Options
 func (a *Authorize) UpdateOptions(opts config.Options) error {
@@ -164,11 +367,117 @@ func (a *Authorize) UpdateOptions(opts config.Options) error {
 	}
 
 	log.Info().Str("checksum", fmt.Sprintf("%x", opts.Checksum())).Msg("authorize: updating options")
-	a.currentOptions.Store(opts)
+	// rather than sign sessions with a weak key, keep the current options and
+	// fail the signer self-check until the key is fixed
+	if err := validateSharedKey(opts.SharedKey); err != nil {
+		err = fmt.Errorf("authorize: bad options: %w", err)
+		a.sharedKeyErr.Store(err)
+		return err
+	}
+	a.sharedKeyErr.Store(nil)
 
-	var err error
+	// build all the state derived from the options before storing any of it,
+	// so a bad update keeps the current state
+	state, err := newOptionsState(&opts)
+	if err != nil {
+		return err
+	}
+	prev := a.currentOptions.Load()
+	a.currentOptions.StoreState(state)
+
+	if prev.SharedKey != opts.SharedKey || getAuthenticateHost(prev) != getAuthenticateHost(opts) || prev.GetSessionSigningAlgorithm() != opts.GetSessionSigningAlgorithm() ||
+		prev.CompressSessions != opts.CompressSessions {
+		var encoder encoding.MarshalUnmarshaler
+		if encoder, err = opts.GetSessionEncoder([]byte(opts.SharedKey), getAuthenticateHost(opts)); err != nil {
+			return err
+		}
+		a.currentEncoder.Store(encoder)
+	}
+	if err := a.updateRevocations(&prev, &opts); err != nil {
+		return err
+	}
+	if err := a.updateAuditLog(&prev, &opts); err != nil {
+		return err
+	}
+	a.updateDecisionLog(&prev, &opts)
+	if err := a.updateGeoIP(&prev, &opts); err != nil {
+		return err
+	}
 	if a.pe, err = newPolicyEvaluator(&opts); err != nil {
 		return err
 	}
+	a.tenantQuotas.update(&opts)
 	return nil
 }
+
+// newOptionsState returns the options with the state derived from them.
+func newOptionsState(opts *config.Options) (*optionsState, error) {
+	state := &optionsState{options: *opts}
+	var err error
+	if state.meshRoots, err = newMeshRoots(opts); err != nil {
+		return nil, err
+	}
+	if state.trustedProxies, err = opts.GetTrustedProxies(); err != nil {
+		return nil, err
+	}
+	if state.healthProbes, err = newHealthProbes(opts); err != nil {
+		return nil, err
+	}
+	if state.routeEncoders, err = newRouteEncoders(opts); err != nil {
+		return nil, err
+	}
+	if state.deniedUserAgents, err = opts.GetDeniedUserAgents(); err != nil {
+		return nil, err
+	}
+	if state.policyLocation, err = opts.GetPolicyLocation(); err != nil {
+		return nil, err
+	}
+	state.routeLimits = newRouteLimits(&state.options)
+	if opts.OverrideTokenSecret != "" {
+		if state.overrideVerifier, err = jws.NewHS256Signer([]byte(opts.OverrideTokenSecret), ""); err != nil {
+			return nil, err
+		}
+	}
+	if opts.InnerTrustSecret != "" {
+		if state.innerTrustVerifier, err = jws.NewHS256Signer([]byte(opts.InnerTrustSecret), ""); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// updateRevocations replaces the revocation store if its options have
+// changed. Checks use the new store as soon as it's stored.
+func (a *Authorize) updateRevocations(prev, opts *config.Options) error {
+	if a.revocations.Load() != nil &&
+		prev.SessionRevocationStoreAddr == opts.SessionRevocationStoreAddr &&
+		prev.SessionRevocationStorePassword == opts.SessionRevocationStorePassword &&
+		prev.SessionRevocationStoreRetryAttempts == opts.SessionRevocationStoreRetryAttempts &&
+		prev.SessionRevocationStoreRetryDelay == opts.SessionRevocationStoreRetryDelay &&
+		prev.SessionRevocationStoreMaxConnections == opts.SessionRevocationStoreMaxConnections &&
+		prev.SessionRevocationStorePoolTimeout == opts.SessionRevocationStorePoolTimeout {
+		return nil
+	}
+	revocations, err := revocation.New(&revocation.Options{
+		RedisAddr:     opts.SessionRevocationStoreAddr,
+		RedisPassword: opts.SessionRevocationStorePassword,
+		RetryAttempts: opts.SessionRevocationStoreRetryAttempts,
+		RetryDelay:    opts.SessionRevocationStoreRetryDelay,
+		MaxConns:      opts.SessionRevocationStoreMaxConnections,
+		PoolTimeout:   opts.SessionRevocationStorePoolTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	a.revocations.Store(revocations)
+	return nil
+}
+
+// getAuthenticateHost returns the host of the authenticate service, which is
+// the issuer of sessions.
+func getAuthenticateHost(opts config.Options) string {
+	if opts.AuthenticateURL == nil {
+		return ""
+	}
+	return opts.AuthenticateURL.Host
+}
@@ -1,9 +1,20 @@
 package authorize
 
 import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
 	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/encoding/jws"
+	"github.com/pomerium/pomerium/internal/sessions"
 )
 
 func TestNew(t *testing.T) {
@@ -19,6 +30,7 @@ func TestNew(t *testing.T) {
 		{"bad shared secret", "AZA85podM73CjLCjViDNz1EUvvejKpWp7Hysr0knXA==", policies, true},
 		{"really bad shared secret", "sup", policies, true},
 		{"validation error, short secret", "AZA85podM73CjLCjViDNz1EUvvejKpWp7Hysr0knXA==", policies, true},
+		{"empty shared secret", "", policies, true},
 		{"empty options", "", []config.Policy{}, true}, // special case
 
 	}
@@ -40,6 +52,160 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func Test_validateSharedKey(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name      string
+		sharedKey string
+		wantErr   string
+	}{
+		{"good", "gXK6ggrlIW2HyKyUF9rUO4azrDgxhDPWqw9y+lJU7B8=", ""},
+		{"empty", "", "bad shared_secret: cannot be empty"},
+		{"not base64", "sup", "bad shared_secret: illegal base64 data at input byte 0"},
+		{"short", base64.StdEncoding.EncodeToString([]byte("too short")), "bad shared_secret: must be at least 32 bytes, got 9"},
+		{"long", "AZA85podM73CjLCjViDNz1EUvvejKpWp7Hysr0knXA4gB+z3CqYvOQ==", "bad shared_secret: cryptutil: got 40 bytes but want 32"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSharedKey(tt.sharedKey)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestAuthorize_UpdateOptions_badSharedKey(t *testing.T) {
+	opts := config.Options{
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       cryptutil.NewBase64Key(),
+		Policies:        testPolicies(t),
+	}
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := health.NewServer()
+	status := func() healthpb.HealthCheckResponse_ServingStatus {
+		a.signerSelfCheck(hs)
+		res, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{Service: authorizationServiceName})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.GetStatus()
+	}
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status())
+
+	for _, sharedKey := range []string{"", base64.StdEncoding.EncodeToString([]byte("too short"))} {
+		reload := opts
+		reload.SharedKey = sharedKey
+		assert.Error(t, a.UpdateOptions(reload))
+		assert.Equal(t, opts.SharedKey, a.currentOptions.Load().SharedKey, "expected the shared key to be kept")
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, status())
+	}
+
+	// a valid key, once reloaded, is used to sign sessions
+	reload := opts
+	reload.SharedKey = cryptutil.NewBase64Key()
+	assert.NoError(t, a.UpdateOptions(reload))
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, status())
+	verifier, err := jws.NewHS256Signer([]byte(reload.SharedKey), "authN.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := a.currentEncoder.Load().Marshal(&sessions.State{Subject: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, verifier.Unmarshal(raw, &sessions.State{}))
+}
+
+func TestAuthorize_UpdateOptions_badRouteSharedKey(t *testing.T) {
+	policies := []config.Policy{
+		{From: "https://a.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, SharedKeyRef: "tenant-a"},
+	}
+	if err := policies[0].Validate(); err != nil {
+		t.Fatal(err)
+	}
+	opts := config.Options{
+		Policies:        policies,
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       cryptutil.NewBase64Key(),
+		SharedKeys:      map[string]string{"tenant-a": cryptutil.NewBase64Key()},
+		TrustedProxies:  []string{"10.0.0.0/8"},
+	}
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an update with a route whose shared key is unknown is rejected whole,
+	// and the route keeps its encoder
+	reload := opts
+	reload.SharedKeys = nil
+	reload.TrustedProxies = []string{"192.168.0.0/16"}
+	assert.Error(t, a.UpdateOptions(reload))
+	state := a.currentOptions.LoadState()
+	assert.NotNil(t, state.routeEncoders["tenant-a"])
+	assert.Equal(t, opts.SharedKeys, state.options.SharedKeys)
+	if assert.Len(t, state.trustedProxies, 1) {
+		assert.Equal(t, "10.0.0.0/8", state.trustedProxies[0].String())
+	}
+}
+
+func TestAuthorize_UpdateOptions_race(t *testing.T) {
+	policies := []config.Policy{
+		{From: "https://a.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, SharedKeyRef: "tenant-a"},
+	}
+	if err := policies[0].Validate(); err != nil {
+		t.Fatal(err)
+	}
+	opts := config.Options{
+		Policies:            policies,
+		AuthenticateURL:     mustParseURL("https://authN.example.com"),
+		SharedKey:           cryptutil.NewBase64Key(),
+		SharedKeys:          map[string]string{"tenant-a": cryptutil.NewBase64Key()},
+		OverrideTokenSecret: cryptutil.NewBase64Key(),
+	}
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checks keep using the state derived from the options while they're
+	// replaced
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					assert.NotNil(t, a.getRouteEncoder(&a.currentOptions.Load().Policies[0]))
+					state := a.currentOptions.LoadState()
+					assert.NotNil(t, state.overrideVerifier)
+					assert.NotNil(t, state.policyLocation)
+					_ = a.getRequestTime()
+				}
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		opts.TrustedProxies = []string{fmt.Sprintf("10.0.%d.0/24", i)}
+		if err := a.UpdateOptions(opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
 func testPolicies(t *testing.T) []config.Policy {
 	testPolicy := config.Policy{From: "https://pomerium.io", To: "http://httpbin.org", AllowedUsers: []string{"test@gmail.com"}}
 	err := testPolicy.Validate()
@@ -51,3 +217,39 @@ func testPolicies(t *testing.T) []config.Policy {
 	}
 	return policies
 }
+
+func TestAuthorize_updateRevocations(t *testing.T) {
+	opts := *config.NewDefaultOptions()
+	a := new(Authorize)
+	if err := a.updateRevocations(&config.Options{}, &opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// checks keep looking up sessions while the store is replaced
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					_, err := a.revocations.Load().IsRevoked(context.TODO(), "session-id")
+					assert.NoError(t, err)
+				}
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		prev := opts
+		opts.SessionRevocationStoreRetryAttempts = i + 1
+		if err := a.updateRevocations(&prev, &opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
@@ -2,33 +2,83 @@ package authorize
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
 	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"golang.org/x/net/http/httpguts"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc/codes"
 
+	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/grpc/authorize"
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/urlutil"
 )
 
+const (
+	defaultWWWAuthenticateRealm  = "pomerium"
+	defaultWWWAuthenticateScheme = "Bearer"
+)
+
+// The error info attached to the status of responses denied by policy.
+const (
+	denialErrorDomain = "pomerium.io"
+
+	denialReasonAccessDenied    = "ACCESS_DENIED"
+	denialReasonUnauthenticated = "UNAUTHENTICATED"
+)
+
 func (a *Authorize) okResponse(
 	reply *authorize.IsAuthorizedReply,
+	policy *config.Policy,
 	rawSession []byte,
 	isNewSession bool,
+	extraHeaders ...http.Header,
 ) *envoy_service_auth_v2.CheckResponse {
-	requestHeaders, err := a.getEnvoyRequestHeaders(rawSession, isNewSession)
+	requestHeaders, err := a.getEnvoyRequestHeaders(policy, rawSession, isNewSession)
 	if err != nil {
 		log.Warn().Err(err).Msg("authorize: error generating new request headers")
 	}
-	requestHeaders = append(requestHeaders,
-		mkHeader(httputil.HeaderPomeriumJWTAssertion, reply.SignedJwt))
+	if hvo := getJWTAssertionHeader(policy, reply.SignedJwt); hvo != nil {
+		requestHeaders = append(requestHeaders, hvo)
+	}
+	if hdrs, err := a.getAppCookieHeader(reply, policy); err != nil {
+		log.Warn().Err(err).Msg("authorize: error generating app cookie")
+	} else {
+		requestHeaders = append(requestHeaders, mkHeaders(hdrs)...)
+	}
+	if len(reply.GetWarnings()) > 0 {
+		requestHeaders = append(requestHeaders, mkHeaders(http.Header{
+			http.CanonicalHeaderKey(httputil.HeaderPomeriumWarning): reply.GetWarnings(),
+		})...)
+	}
+	if policy != nil && policy.PassMatchedConditions {
+		// always set, so that a client can't pass its own conditions upstream
+		requestHeaders = append(requestHeaders, mkHeader(http.CanonicalHeaderKey(httputil.HeaderPomeriumMatchedConditions),
+			strings.Join(reply.GetMatchedConditions(), ",")))
+	}
+	for _, hdrs := range extraHeaders {
+		requestHeaders = append(requestHeaders, mkHeaders(hdrs)...)
+	}
+	if policy != nil {
+		requestHeaders = applyHeaderTransforms(requestHeaders, policy.HeaderTransforms)
+	}
+	requestHeaders = sanitizeHeaders(normalizeHeaders(requestHeaders))
 
 	return &envoy_service_auth_v2.CheckResponse{
 		Status: &status.Status{Code: int32(codes.OK), Message: "OK"},
@@ -40,9 +90,31 @@ func (a *Authorize) okResponse(
 	}
 }
 
+// getJWTAssertionHeader returns the header used to pass the signed JWT
+// assertion upstream for the route, or nil if the route doesn't pass it.
+func getJWTAssertionHeader(policy *config.Policy, signedJWT string) *envoy_api_v2_core.HeaderValueOption {
+	name, format := httputil.HeaderPomeriumJWTAssertion, config.JWTAssertionFormatRaw
+	if policy != nil {
+		if policy.JWTAssertionHeader != "" {
+			name = policy.JWTAssertionHeader
+		}
+		if policy.JWTAssertionFormat != "" {
+			format = policy.JWTAssertionFormat
+		}
+	}
+	switch format {
+	case config.JWTAssertionFormatNone:
+		return nil
+	case config.JWTAssertionFormatBearer:
+		return mkHeader(name, "Bearer "+signedJWT)
+	default:
+		return mkHeader(name, signedJWT)
+	}
+}
+
 func (a *Authorize) deniedResponse(
 	in *envoy_service_auth_v2.CheckRequest,
-	code int32, reason string, headers map[string]string,
+	code int32, reason string, headers http.Header,
 ) *envoy_service_auth_v2.CheckResponse {
 
 	returnHTMLError := true
@@ -51,13 +123,89 @@ func (a *Authorize) deniedResponse(
 		returnHTMLError = strings.Contains(inHeaders["accept"], "text/html")
 	}
 
+	// let allowed cross-origin callers see the actual deny response
+	if corsHeaders := getCORSHeaders(a.currentOptions.Load().CORSAllowedOrigins, inHeaders["origin"]); corsHeaders != nil {
+		for k, vs := range headers {
+			corsHeaders[k] = vs
+		}
+		headers = corsHeaders
+	}
+
 	if returnHTMLError {
-		return a.htmlDeniedResponse(code, reason, headers)
+		return a.htmlDeniedResponse(code, reason, getCheckRequestID(in), headers)
+	}
+	if code >= http.StatusBadRequest && strings.Contains(inHeaders["accept"], "json") {
+		return a.problemDeniedResponse(code, reason, getCheckRequestID(in), headers)
 	}
 	return a.plainTextDeniedResponse(code, reason, headers)
 }
 
-func (a *Authorize) htmlDeniedResponse(code int32, reason string, headers map[string]string) *envoy_service_auth_v2.CheckResponse {
+// problemDetails is an rfc7807 problem details object.
+type problemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int32  `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// SupportURL is an extension member linking to where the user can get
+	// help, quoting the instance as a reference code.
+	SupportURL string `json:"support_url,omitempty"`
+}
+
+// problemDeniedResponse returns a denied response with an rfc7807 problem
+// details body, for API clients which accept JSON.
+func (a *Authorize) problemDeniedResponse(code int32, reason, requestID string, headers http.Header) *envoy_service_auth_v2.CheckResponse {
+	body, err := json.Marshal(problemDetails{
+		Type:       "about:blank",
+		Title:      http.StatusText(int(code)),
+		Status:     code,
+		Detail:     reason,
+		Instance:   requestID,
+		SupportURL: a.getSupportURL(),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("error encoding problem details")
+		return a.plainTextDeniedResponse(code, reason, headers)
+	}
+
+	envoyHeaders := []*envoy_api_v2_core.HeaderValueOption{
+		mkHeader("Content-Type", "application/problem+json"),
+	}
+	envoyHeaders = append(envoyHeaders, mkHeaders(headers)...)
+
+	return &envoy_service_auth_v2.CheckResponse{
+		Status: &status.Status{Code: int32(codes.PermissionDenied), Message: "Access Denied"},
+		HttpResponse: &envoy_service_auth_v2.CheckResponse_DeniedResponse{
+			DeniedResponse: &envoy_service_auth_v2.DeniedHttpResponse{
+				Status: &envoy_type.HttpStatus{
+					Code: envoy_type.StatusCode(code),
+				},
+				Headers: envoyHeaders,
+				Body:    string(body),
+			},
+		},
+	}
+}
+
+// getCheckRequestID returns the id envoy assigned to the request.
+func getCheckRequestID(in *envoy_service_auth_v2.CheckRequest) string {
+	hattrs := in.GetAttributes().GetRequest().GetHttp()
+	if id := hattrs.GetId(); id != "" {
+		return id
+	}
+	return hattrs.GetHeaders()["x-request-id"]
+}
+
+// getSupportURL returns the URL users are directed to for help with a denied
+// request, if any.
+func (a *Authorize) getSupportURL() string {
+	if u := a.currentOptions.Load().SupportURL; u != nil {
+		return u.String()
+	}
+	return ""
+}
+
+func (a *Authorize) htmlDeniedResponse(code int32, reason, requestID string, headers http.Header) *envoy_service_auth_v2.CheckResponse {
 	var details string
 	switch code {
 	case httputil.StatusInvalidClientCertificate:
@@ -74,6 +222,8 @@ func (a *Authorize) htmlDeniedResponse(code int32, reason string, headers map[st
 		"StatusText": reason,
 		"CanDebug":   code/100 == 4,
 		"Error":      details,
+		"RequestID":  requestID,
+		"SupportURL": a.getSupportURL(),
 	})
 	if err != nil {
 		buf.WriteString(reason)
@@ -83,9 +233,7 @@ func (a *Authorize) htmlDeniedResponse(code int32, reason string, headers map[st
 	envoyHeaders := []*envoy_api_v2_core.HeaderValueOption{
 		mkHeader("Content-Type", "text/html"),
 	}
-	for k, v := range headers {
-		envoyHeaders = append(envoyHeaders, mkHeader(k, v))
-	}
+	envoyHeaders = append(envoyHeaders, mkHeaders(headers)...)
 
 	return &envoy_service_auth_v2.CheckResponse{
 		Status: &status.Status{Code: int32(codes.PermissionDenied), Message: "Access Denied"},
@@ -101,13 +249,11 @@ func (a *Authorize) htmlDeniedResponse(code int32, reason string, headers map[st
 	}
 }
 
-func (a *Authorize) plainTextDeniedResponse(code int32, reason string, headers map[string]string) *envoy_service_auth_v2.CheckResponse {
+func (a *Authorize) plainTextDeniedResponse(code int32, reason string, headers http.Header) *envoy_service_auth_v2.CheckResponse {
 	envoyHeaders := []*envoy_api_v2_core.HeaderValueOption{
 		mkHeader("Content-Type", "text/plain"),
 	}
-	for k, v := range headers {
-		envoyHeaders = append(envoyHeaders, mkHeader(k, v))
-	}
+	envoyHeaders = append(envoyHeaders, mkHeaders(headers)...)
 
 	return &envoy_service_auth_v2.CheckResponse{
 		Status: &status.Status{Code: int32(codes.PermissionDenied), Message: "Access Denied"},
@@ -123,18 +269,377 @@ func (a *Authorize) plainTextDeniedResponse(code int32, reason string, headers m
 	}
 }
 
-func (a *Authorize) redirectResponse(in *envoy_service_auth_v2.CheckRequest) *envoy_service_auth_v2.CheckResponse {
+// passthroughResponse passes a request upstream without any additional
+// headers, such as CORS preflight requests for routes which answer them
+// themselves.
+func (a *Authorize) passthroughResponse() *envoy_service_auth_v2.CheckResponse {
+	return &envoy_service_auth_v2.CheckResponse{
+		Status:       &status.Status{Code: int32(codes.OK), Message: "OK"},
+		HttpResponse: &envoy_service_auth_v2.CheckResponse_OkResponse{OkResponse: &envoy_service_auth_v2.OkHttpResponse{}},
+	}
+}
+
+// preflightResponse answers a CORS preflight request from an allowed origin,
+// so that the actual request can then be authorized. It returns nil if the
+// origin isn't allowed.
+func (a *Authorize) preflightResponse(in *envoy_service_auth_v2.CheckRequest, policy *config.Policy) *envoy_service_auth_v2.CheckResponse {
+	inHeaders := in.GetAttributes().GetRequest().GetHttp().GetHeaders()
+	hdrs := getCORSHeaders(a.currentOptions.Load().CORSAllowedOrigins, inHeaders["origin"])
+	if hdrs == nil {
+		return nil
+	}
+	if policy != nil && len(policy.AllowedMethods) > 0 {
+		hdrs.Set("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+	} else {
+		hdrs.Set("Access-Control-Allow-Methods", inHeaders["access-control-request-method"])
+	}
+	if requestHeaders := inHeaders["access-control-request-headers"]; requestHeaders != "" {
+		hdrs.Set("Access-Control-Allow-Headers", requestHeaders)
+	}
+	return &envoy_service_auth_v2.CheckResponse{
+		Status: &status.Status{Code: int32(codes.PermissionDenied), Message: "CORS Preflight"},
+		HttpResponse: &envoy_service_auth_v2.CheckResponse_DeniedResponse{
+			DeniedResponse: &envoy_service_auth_v2.DeniedHttpResponse{
+				Status:  &envoy_type.HttpStatus{Code: envoy_type.StatusCode_NoContent},
+				Headers: mkHeaders(hdrs),
+			},
+		},
+	}
+}
+
+// optionsResponse answers an OPTIONS request to the route, which isn't a CORS
+// preflight, with an empty 200 allowing the route's methods.
+func (a *Authorize) optionsResponse(policy *config.Policy) *envoy_service_auth_v2.CheckResponse {
+	methods := policy.AllowedMethods
+	if len(methods) == 0 {
+		opts := a.currentOptions.Load()
+		methods = getGloballyAllowedMethods(opts.GlobalAllowedMethods, opts.DeniedMethods)
+	}
+	hdrs := http.Header{}
+	if len(methods) > 0 {
+		hdrs.Set("Allow", strings.Join(methods, ", "))
+	}
+	return &envoy_service_auth_v2.CheckResponse{
+		Status: &status.Status{Code: int32(codes.PermissionDenied), Message: "Options"},
+		HttpResponse: &envoy_service_auth_v2.CheckResponse_DeniedResponse{
+			DeniedResponse: &envoy_service_auth_v2.DeniedHttpResponse{
+				Status:  &envoy_type.HttpStatus{Code: envoy_type.StatusCode_OK},
+				Headers: mkHeaders(hdrs),
+			},
+		},
+	}
+}
+
+// serverOptionsResponse answers a server-wide "OPTIONS *" request, which isn't
+// for any route, with an empty 200.
+func (a *Authorize) serverOptionsResponse() *envoy_service_auth_v2.CheckResponse {
+	return &envoy_service_auth_v2.CheckResponse{
+		Status: &status.Status{Code: int32(codes.PermissionDenied), Message: "Server Options"},
+		HttpResponse: &envoy_service_auth_v2.CheckResponse_DeniedResponse{
+			DeniedResponse: &envoy_service_auth_v2.DeniedHttpResponse{
+				Status: &envoy_type.HttpStatus{Code: envoy_type.StatusCode_OK},
+			},
+		},
+	}
+}
+
+// invalidRequestResponse rejects a request which can't be authorized because
+// it is malformed.
+func (a *Authorize) invalidRequestResponse(in *envoy_service_auth_v2.CheckRequest, reason string) *envoy_service_auth_v2.CheckResponse {
+	res := a.deniedResponse(in, http.StatusBadRequest, reason, nil)
+	res.Status = &status.Status{Code: int32(codes.InvalidArgument), Message: reason}
+	return res
+}
+
+// unavailableResponse rejects a request which can't be authorized right now,
+// e.g. because its route is at its concurrency limit.
+func (a *Authorize) unavailableResponse(in *envoy_service_auth_v2.CheckRequest, reason string) *envoy_service_auth_v2.CheckResponse {
+	res := a.deniedResponse(in, http.StatusServiceUnavailable, reason, nil)
+	res.Status = &status.Status{Code: int32(codes.ResourceExhausted), Message: reason}
+	return res
+}
+
+// defaultMaintenanceMessage is the message of maintenance responses for routes
+// without one of their own.
+const defaultMaintenanceMessage = "This page is down for maintenance"
+
+// maintenanceResponse rejects a request to a route in maintenance mode.
+func (a *Authorize) maintenanceResponse(in *envoy_service_auth_v2.CheckRequest, policy *config.Policy) *envoy_service_auth_v2.CheckResponse {
+	msg := policy.MaintenanceMessage
+	if msg == "" {
+		msg = defaultMaintenanceMessage
+	}
+	a.emitDenyEvent(in, "", http.StatusServiceUnavailable, "route in maintenance")
+	res := a.deniedResponse(in, http.StatusServiceUnavailable, msg, nil)
+	res.Status = &status.Status{Code: int32(codes.Unavailable), Message: "route in maintenance"}
+	return res
+}
+
+// tenantQuotaExhaustedResponse rejects a request from a tenant which has
+// exhausted its quota, with the time the quota resets.
+func (a *Authorize) tenantQuotaExhaustedResponse(in *envoy_service_auth_v2.CheckRequest, reply *authorize.IsAuthorizedReply, reset time.Time) *envoy_service_auth_v2.CheckResponse {
+	code := int32(a.currentOptions.Load().TenantQuotaStatusCode)
+	if code == 0 {
+		code = http.StatusTooManyRequests
+	}
+	a.emitDenyEvent(in, reply.GetEmail(), code, "tenant quota exhausted")
+	reason := "Tenant request quota exhausted until " + reset.UTC().Format(time.RFC3339)
+	retryAfter := int64(math.Ceil(reset.Sub(timeNow()).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	res := a.deniedResponse(in, code, reason, http.Header{
+		"Retry-After": {strconv.FormatInt(retryAfter, 10)},
+	})
+	res.Status = &status.Status{Code: int32(codes.ResourceExhausted), Message: reason}
+	return res
+}
+
+// withDenialDetails attaches a google.rpc.ErrorInfo describing why the request
+// was denied to the status of the response, so callers can tell a denial by
+// policy from one which needs the user to sign in again without parsing the
+// body.
+func withDenialDetails(
+	res *envoy_service_auth_v2.CheckResponse,
+	reply *authorize.IsAuthorizedReply,
+	policy *config.Policy,
+	reauth bool,
+) *envoy_service_auth_v2.CheckResponse {
+	info := &errdetails.ErrorInfo{
+		Reason:   denialReasonAccessDenied,
+		Domain:   denialErrorDomain,
+		Metadata: map[string]string{"reauth": fmt.Sprint(reauth)},
+	}
+	if reauth {
+		info.Reason = denialReasonUnauthenticated
+	}
+	if policy != nil {
+		info.Metadata["policy_id"] = fmt.Sprintf("%x", policy.Checksum())
+	}
+	if len(reply.GetDenyReasons()) > 0 {
+		info.Metadata["deny_reasons"] = strings.Join(reply.GetDenyReasons(), ",")
+	}
+	if len(reply.GetDenyRuleIds()) > 0 {
+		info.Metadata["deny_rule_ids"] = strings.Join(reply.GetDenyRuleIds(), ",")
+	}
+
+	detail, err := ptypes.MarshalAny(info)
+	if err != nil {
+		log.Warn().Err(err).Msg("authorize: failed to marshal denial details")
+		return res
+	}
+	res.Status.Details = append(res.Status.Details, detail)
+	return res
+}
+
+// redirectResponse redirects the user to sign in. Any additional headers, such
+// as those clearing a bad session cookie, are added to the response.
+func (a *Authorize) redirectResponse(in *envoy_service_auth_v2.CheckRequest, headers http.Header) *envoy_service_auth_v2.CheckResponse {
+	hdrs := http.Header{}
+	for k, vs := range headers {
+		hdrs[k] = vs
+	}
+	hdrs.Set("Location", a.getSignInURL(in))
+	return a.deniedResponse(in, http.StatusFound, "Login", hdrs)
+}
+
+// unauthenticatedResponse returns a 401 for clients which can't be redirected
+// to sign in, advertising the supported authentication schemes.
+func (a *Authorize) unauthenticatedResponse(in *envoy_service_auth_v2.CheckRequest) *envoy_service_auth_v2.CheckResponse {
 	opts := a.currentOptions.Load()
+	return a.deniedResponse(in, http.StatusUnauthorized, "Unauthenticated", http.Header{
+		"Www-Authenticate": getWWWAuthenticateHeaders(opts.WWWAuthenticateRealm, opts.WWWAuthenticateSchemes, a.getSignInURL(in)),
+	})
+}
 
-	signinURL := opts.GetAuthenticateURL().ResolveReference(&url.URL{Path: "/.pomerium/sign_in"})
+// getSignInURL returns the signed authenticate service sign in url which
+// redirects back to the requested url.
+func (a *Authorize) getSignInURL(in *envoy_service_auth_v2.CheckRequest) string {
+	opts := a.currentOptions.Load()
+
+	signinURL := a.getAuthenticateURL().ResolveReference(&url.URL{Path: "/.pomerium/sign_in"})
 	q := signinURL.Query()
-	q.Set(urlutil.QueryRedirectURI, getCheckRequestURL(in).String())
+	q.Set(urlutil.QueryRedirectURI, getRedirectURI(a.getExternalURL(in), opts.MaxRedirectURILength))
 	signinURL.RawQuery = q.Encode()
-	redirectTo := urlutil.NewSignedURL(opts.SharedKey, signinURL).String()
+	return urlutil.NewSignedURL(opts.SharedKey, signinURL).String()
+}
 
-	return a.deniedResponse(in, http.StatusFound, "Login", map[string]string{
-		"Location": redirectTo,
-	})
+// getRedirectURI returns the url to redirect back to after signing in. If it's
+// longer than the max length, the root of the url's origin is returned
+// instead.
+func getRedirectURI(u *url.URL, maxLength int) string {
+	redirectURI := u.String()
+	if maxLength > 0 && len(redirectURI) > maxLength {
+		log.Warn().Int("length", len(redirectURI)).Str("host", u.Host).Msg("authorize: redirect uri too long, redirecting to the root instead")
+		return (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String()
+	}
+	return redirectURI
+}
+
+// getWWWAuthenticateHeaders returns a WWW-Authenticate challenge for each
+// scheme, as defined in rfc7235. The Bearer challenge also includes the sign
+// in url.
+func getWWWAuthenticateHeaders(realm string, schemes []string, signInURL string) []string {
+	if realm == "" {
+		realm = defaultWWWAuthenticateRealm
+	}
+	if len(schemes) == 0 {
+		schemes = []string{defaultWWWAuthenticateScheme}
+	}
+	challenges := make([]string, 0, len(schemes))
+	for _, scheme := range schemes {
+		challenge := scheme + " realm=" + quoteAuthParam(realm)
+		if strings.EqualFold(scheme, "Bearer") {
+			challenge += ", sign_in_url=" + quoteAuthParam(signInURL)
+		}
+		challenges = append(challenges, challenge)
+	}
+	return challenges
+}
+
+// quoteAuthParam returns s as an rfc7230 quoted-string.
+func quoteAuthParam(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// getCORSHeaders returns the CORS headers for a response to a request from
+// origin, or nil if origin is not in the list of allowed origins.
+func getCORSHeaders(allowedOrigins []string, origin string) http.Header {
+	if origin == "" {
+		return nil
+	}
+	for _, allowed := range allowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return http.Header{
+				"Access-Control-Allow-Origin":      {origin},
+				"Access-Control-Allow-Credentials": {"true"},
+				"Vary":                             {"Origin"},
+			}
+		}
+	}
+	return nil
+}
+
+// mkHeaders converts http headers into envoy headers. Repeated header values
+// (e.g. multiple Set-Cookie headers) are appended rather than overwritten.
+func mkHeaders(headers http.Header) []*envoy_api_v2_core.HeaderValueOption {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var hvos []*envoy_api_v2_core.HeaderValueOption
+	for _, k := range keys {
+		for i, v := range headers[k] {
+			hvo := mkHeader(k, v)
+			if i > 0 {
+				hvo.Append = &wrappers.BoolValue{Value: true}
+			}
+			hvos = append(hvos, hvo)
+		}
+	}
+	return hvos
+}
+
+// normalizeHeaders de-duplicates envoy headers by key, case-insensitively, and
+// sorts them by key so that the headers sent upstream are deterministic. A
+// header replaces any earlier headers with the same key, unless it is
+// appended, in which case the distinct values are kept in order.
+func normalizeHeaders(hvos []*envoy_api_v2_core.HeaderValueOption) []*envoy_api_v2_core.HeaderValueOption {
+	type entry struct {
+		key    string
+		values []string
+		seen   map[string]bool
+	}
+	entries := make(map[string]*entry)
+	for _, hvo := range hvos {
+		k := strings.ToLower(hvo.GetHeader().GetKey())
+		e, ok := entries[k]
+		if !ok || !hvo.GetAppend().GetValue() {
+			e = &entry{key: hvo.GetHeader().GetKey(), seen: make(map[string]bool)}
+			entries[k] = e
+		}
+		if v := hvo.GetHeader().GetValue(); !e.seen[v] {
+			e.values = append(e.values, v)
+			e.seen[v] = true
+		}
+	}
+
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	normalized := make([]*envoy_api_v2_core.HeaderValueOption, 0, len(hvos))
+	for _, k := range keys {
+		e := entries[k]
+		for i, v := range e.values {
+			hvo := mkHeader(e.key, v)
+			if i > 0 {
+				hvo.Append = &wrappers.BoolValue{Value: true}
+			}
+			normalized = append(normalized, hvo)
+		}
+	}
+	return normalized
+}
+
+// applyHeaderTransforms renames, adds and removes headers sent upstream, per
+// the route's header transforms, in order. Removing a header here only removes
+// pomerium's; the client's is removed by the route.
+func applyHeaderTransforms(hvos []*envoy_api_v2_core.HeaderValueOption, transforms []config.HeaderTransform) []*envoy_api_v2_core.HeaderValueOption {
+	for _, t := range transforms {
+		switch {
+		case t.Rename != "":
+			hvos = removeHeader(hvos, t.To)
+			renamed := false
+			for _, hvo := range hvos {
+				if strings.EqualFold(hvo.GetHeader().GetKey(), t.Rename) {
+					hvo.Header.Key = t.To
+					renamed = true
+				}
+			}
+			if !renamed {
+				// always set, so that a client can't pass its own value upstream
+				hvos = append(hvos, mkHeader(t.To, ""))
+			}
+		case t.Add != "":
+			hvos = append(removeHeader(hvos, t.Add), mkHeader(t.Add, t.Value))
+		case t.Remove != "":
+			hvos = removeHeader(hvos, t.Remove)
+		}
+	}
+	return hvos
+}
+
+// removeHeader returns the headers without those with the key, ignoring case.
+func removeHeader(hvos []*envoy_api_v2_core.HeaderValueOption, key string) []*envoy_api_v2_core.HeaderValueOption {
+	kept := hvos[:0]
+	for _, hvo := range hvos {
+		if !strings.EqualFold(hvo.GetHeader().GetKey(), key) {
+			kept = append(kept, hvo)
+		}
+	}
+	return kept
+}
+
+// sanitizeHeaders guards against header injection by claim values and other
+// untrusted input sent upstream. Headers with invalid names are dropped, and
+// values containing control characters (e.g. CR or LF) or non-ASCII are
+// rfc2047 encoded.
+func sanitizeHeaders(hvos []*envoy_api_v2_core.HeaderValueOption) []*envoy_api_v2_core.HeaderValueOption {
+	sanitized := hvos[:0]
+	for _, hvo := range hvos {
+		if !httpguts.ValidHeaderFieldName(hvo.GetHeader().GetKey()) {
+			log.Warn().Str("header", hvo.GetHeader().GetKey()).Msg("authorize: dropped header with invalid name")
+			continue
+		}
+		hvo.Header.Value = mime.BEncoding.Encode("utf-8", hvo.GetHeader().GetValue())
+		sanitized = append(sanitized, hvo)
+	}
+	return sanitized
 }
 
 func mkHeader(k, v string) *envoy_api_v2_core.HeaderValueOption {
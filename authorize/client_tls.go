@@ -0,0 +1,65 @@
+package authorize
+
+import (
+	"crypto/tls"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+)
+
+// clientTLSMetadataNamespace is the namespace of the dynamic metadata in which
+// envoy passes the properties of the client's TLS connection.
+const clientTLSMetadataNamespace = "pomerium.tls"
+
+// envoyTLSVersions maps the TLS versions reported by envoy to their tls
+// package constants.
+var envoyTLSVersions = map[string]uint16{
+	"TLSv1":   tls.VersionTLS10,
+	"TLSv1.1": tls.VersionTLS11,
+	"TLSv1.2": tls.VersionTLS12,
+	"TLSv1.3": tls.VersionTLS13,
+}
+
+// getClientTLS returns the TLS version and cipher suite of the client's
+// connection, or a version of 0 if they're unknown, e.g. for plaintext
+// connections.
+func getClientTLS(in *envoy_service_auth_v2.CheckRequest) (version uint16, cipherSuite string) {
+	fields := in.GetAttributes().GetMetadataContext().GetFilterMetadata()[clientTLSMetadataNamespace].GetFields()
+	return envoyTLSVersions[fields["version"].GetStringValue()], fields["cipher_suite"].GetStringValue()
+}
+
+// getClientSNI returns the server name the client requested in its TLS
+// handshake, or "" if it's unknown, e.g. for plaintext connections.
+func getClientSNI(in *envoy_service_auth_v2.CheckRequest) string {
+	fields := in.GetAttributes().GetMetadataContext().GetFilterMetadata()[clientTLSMetadataNamespace].GetFields()
+	return fields["sni"].GetStringValue()
+}
+
+// applyClientTLSRequirements denies an allowed request if the client's
+// connection is weaker than the route requires. Connections whose properties
+// are unknown are denied.
+func applyClientTLSRequirements(in *envoy_service_auth_v2.CheckRequest, reply *authorize.IsAuthorizedReply, policy *config.Policy) {
+	if !reply.GetAllow() || policy == nil {
+		return
+	}
+	version, cipherSuite := getClientTLS(in)
+	if minVersion := policy.GetClientTLSMinVersion(); minVersion > 0 && version < minVersion {
+		reply.Allow = false
+		reply.DenyReasons = append(reply.DenyReasons, "CLIENT_TLS_VERSION")
+		reply.DenyRuleIds = append(reply.DenyRuleIds, "client_tls_min_version")
+		return
+	}
+	if len(policy.ClientTLSCipherSuites) == 0 {
+		return
+	}
+	for _, allowed := range policy.ClientTLSCipherSuites {
+		if cipherSuite == allowed {
+			return
+		}
+	}
+	reply.Allow = false
+	reply.DenyReasons = append(reply.DenyReasons, "CLIENT_TLS_CIPHER_SUITE")
+	reply.DenyRuleIds = append(reply.DenyRuleIds, "client_tls_cipher_suites")
+}
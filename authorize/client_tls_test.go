@@ -0,0 +1,145 @@
+package authorize
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+)
+
+func TestAuthorize_Check_clientTLS(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+		{From: "https://sensitive.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, ClientTLSMinVersion: "1.3"},
+		{From: "https://ciphers.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, ClientTLSCipherSuites: []string{"ECDHE-ECDSA-AES256-GCM-SHA384"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		host         string
+		version      string
+		cipherSuite  string
+		wantAllowed  bool
+		wantDenyCode int
+	}{
+		{"no requirements", "app.example.com", "TLSv1.2", "ECDHE-RSA-AES128-GCM-SHA256", true, 0},
+		{"tls 1.3 required, tls 1.3", "sensitive.example.com", "TLSv1.3", "TLS_AES_128_GCM_SHA256", true, 0},
+		{"tls 1.3 required, tls 1.2", "sensitive.example.com", "TLSv1.2", "ECDHE-RSA-AES128-GCM-SHA256", false, http.StatusForbidden},
+		{"tls 1.3 required, unknown", "sensitive.example.com", "", "", false, http.StatusForbidden},
+		{"allowed cipher suite", "ciphers.example.com", "TLSv1.2", "ECDHE-ECDSA-AES256-GCM-SHA384", true, 0},
+		{"other cipher suite", "ciphers.example.com", "TLSv1.2", "AES128-SHA", false, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := testCheckRequest("GET", "https://"+tt.host+"/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", tt.host, time.Now().Add(time.Hour)),
+			})
+			if tt.version != "" {
+				in.Attributes.MetadataContext = &envoy_api_v2_core.Metadata{
+					FilterMetadata: map[string]*structpb.Struct{
+						clientTLSMetadataNamespace: {Fields: map[string]*structpb.Value{
+							"version":      {Kind: &structpb.Value_StringValue{StringValue: tt.version}},
+							"cipher_suite": {Kind: &structpb.Value_StringValue{StringValue: tt.cipherSuite}},
+						}},
+					},
+				}
+			}
+			res, err := a.Check(context.TODO(), in)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantDenyCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_sni(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+		{From: "https://internal.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, AllowedServerNames: []string{"Internal.example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	newAuthorize := func(t *testing.T, denyMismatch bool) *Authorize {
+		opts := *config.NewDefaultOptions()
+		opts.Policies = policies
+		opts.CookieName = "_pomerium"
+		opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+		opts.SharedKey = sharedKey
+		opts.DenySNIHostMismatch = denyMismatch
+		a, err := New(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+
+	tests := []struct {
+		name         string
+		host         string
+		sni          string
+		denyMismatch bool
+		wantDenyCode int
+	}{
+		{"allowed server name", "internal.example.com", "internal.example.com", false, 0},
+		{"other server name", "internal.example.com", "app.example.com", false, http.StatusForbidden},
+		{"no server name", "internal.example.com", "", false, http.StatusForbidden},
+		{"no server name requirement", "app.example.com", "", false, 0},
+		{"mismatch allowed", "app.example.com", "other.example.com", false, 0},
+		{"mismatch denied", "app.example.com", "other.example.com", true, http.StatusMisdirectedRequest},
+		{"match", "app.example.com", "APP.example.com", true, 0},
+		{"plaintext", "app.example.com", "", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthorize(t, tt.denyMismatch)
+			in := testCheckRequest("GET", "https://"+tt.host+"/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", tt.host, time.Now().Add(time.Hour)),
+			})
+			if tt.sni != "" {
+				in.Attributes.MetadataContext = &envoy_api_v2_core.Metadata{
+					FilterMetadata: map[string]*structpb.Struct{
+						clientTLSMetadataNamespace: {Fields: map[string]*structpb.Value{
+							"sni": {Kind: &structpb.Value_StringValue{StringValue: tt.sni}},
+						}},
+					},
+				}
+			}
+			res, err := a.Check(context.TODO(), in)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantDenyCode == 0, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantDenyCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package authorize
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/encoding"
+	"github.com/pomerium/pomerium/internal/httputil"
+)
+
+// cookieDomainMarkerSuffix is the suffix of the name of the cookie marking the
+// session cookie as issued under the current cookie domain. Browsers don't
+// send the domain of a cookie, so it's how sessions issued under the legacy
+// domain are told apart.
+const cookieDomainMarkerSuffix = "_domain"
+
+// getCookieDomainMarker returns the value of the cookie marking a session
+// cookie as issued under the domain. Host-only cookies have no domain.
+func getCookieDomainMarker(domain string) string {
+	if domain = strings.TrimPrefix(domain, "."); domain == "" {
+		return "-"
+	}
+	return domain
+}
+
+// needsCookieDomainMigration reports whether the request's session cookie may
+// have been issued under the legacy cookie domain, if migrating: it has one,
+// but not the marker of the current domain.
+func needsCookieDomainMigration(options config.Options, req *http.Request) bool {
+	if !options.CookieMigrateDomain {
+		return false
+	}
+	if _, err := req.Cookie(options.CookieName); err != nil {
+		return false
+	}
+	marker, err := req.Cookie(options.CookieName + cookieDomainMarkerSuffix)
+	return err != nil || marker.Value != getCookieDomainMarker(options.CookieDomain)
+}
+
+// getCookieDomainMigrationHeaders returns the headers which, moved to the
+// response by envoy, clear the request's session cookie, and its chunks,
+// under the legacy cookie domain, and mark the re-issued session cookie as
+// issued under the current one.
+func getCookieDomainMigrationHeaders(options config.Options, encoder encoding.MarshalUnmarshaler, req *http.Request) (http.Header, error) {
+	legacyOptions := options
+	legacyOptions.CookieDomain = options.CookieLegacyDomain
+	cookieStore, err := getCookieStore(legacyOptions, encoder)
+	if err != nil {
+		return nil, err
+	}
+	setCookies := getJWTClearCookieHeaders(cookieStore, req).Values("Set-Cookie")
+
+	// should the marker expire before the session cookie, e.g. as it's been
+	// refreshed, the session is migrated again, only setting it
+	marker := &http.Cookie{
+		Name:     options.CookieName + cookieDomainMarkerSuffix,
+		Value:    getCookieDomainMarker(options.CookieDomain),
+		Path:     "/",
+		Domain:   options.CookieDomain,
+		HttpOnly: options.CookieHTTPOnly,
+		Secure:   options.CookieSecure,
+	}
+	if options.CookieExpire > 0 {
+		marker.Expires = timeNow().Add(options.CookieExpire)
+	}
+	setCookies = append(setCookies, marker.String())
+	return http.Header{
+		http.CanonicalHeaderKey(httputil.HeaderPomeriumCookieMigration): setCookies,
+	}, nil
+}
@@ -0,0 +1,101 @@
+package authorize
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/httputil"
+)
+
+func TestAuthorize_Check_cookieDomainMigration(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	newAuthorize := func(t *testing.T, migrate bool, legacyDomain string) *Authorize {
+		t.Helper()
+		a, err := New(config.Options{
+			Policies:            []config.Policy{policy},
+			CookieName:          "_pomerium",
+			CookieDomain:        ".example.com",
+			CookieExpire:        time.Hour,
+			CookieMigrateDomain: migrate,
+			CookieLegacyDomain:  legacyDomain,
+			AuthenticateURL:     mustParseURL("https://authN.example.com"),
+			SharedKey:           sharedKey,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour))
+
+	// check returns the set-cookie and cookie migration headers of an allowed
+	// request with the cookies
+	check := func(t *testing.T, a *Authorize, cookies string) (setCookie string, migration []string) {
+		t.Helper()
+		res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+			"accept": "text/html",
+			"cookie": cookies,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.NotNil(t, res.GetOkResponse()) {
+			return "", nil
+		}
+		for _, hdr := range res.GetOkResponse().GetHeaders() {
+			switch hdr.GetHeader().GetKey() {
+			case "x-pomerium-Set-Cookie":
+				setCookie = hdr.GetHeader().GetValue()
+			case http.CanonicalHeaderKey(httputil.HeaderPomeriumCookieMigration):
+				migration = append(migration, hdr.GetHeader().GetValue())
+			}
+		}
+		return setCookie, migration
+	}
+
+	t.Run("host-only", func(t *testing.T) {
+		a := newAuthorize(t, true, "")
+		setCookie, migration := check(t, a, "_pomerium="+rawJWT)
+		// re-issued under the new domain
+		assert.True(t, strings.HasPrefix(setCookie, "_pomerium="+rawJWT), setCookie)
+		assert.Contains(t, setCookie, "Domain=example.com")
+		// cleared under the legacy one, and marked as migrated
+		if assert.Len(t, migration, 2) {
+			assert.True(t, strings.HasPrefix(migration[0], "_pomerium=;"), migration[0])
+			assert.NotContains(t, migration[0], "Domain=")
+			assert.Contains(t, migration[0], "Max-Age=0")
+			assert.True(t, strings.HasPrefix(migration[1], "_pomerium_domain=example.com;"), migration[1])
+			assert.Contains(t, migration[1], "Domain=example.com")
+		}
+
+		// with the marker, the session cookie isn't migrated again
+		setCookie, migration = check(t, a, "_pomerium="+rawJWT+"; _pomerium_domain=example.com")
+		assert.Empty(t, setCookie)
+		assert.Empty(t, migration)
+	})
+	t.Run("legacy domain", func(t *testing.T) {
+		a := newAuthorize(t, true, "app.example.com")
+		setCookie, migration := check(t, a, "_pomerium="+rawJWT+"; _pomerium_domain=other.example.com")
+		assert.Contains(t, setCookie, "Domain=example.com")
+		if assert.Len(t, migration, 2) {
+			assert.Contains(t, migration[0], "Domain=app.example.com")
+		}
+	})
+	t.Run("disabled", func(t *testing.T) {
+		a := newAuthorize(t, false, "")
+		setCookie, migration := check(t, a, "_pomerium="+rawJWT)
+		assert.Empty(t, setCookie)
+		assert.Empty(t, migration)
+	})
+}
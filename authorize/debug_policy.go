@@ -0,0 +1,174 @@
+package authorize
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	envoy_type "github.com/envoyproxy/go-control-plane/envoy/type"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/sessions"
+)
+
+// policyDebugPath is the administrator endpoint explaining how policy is
+// evaluated for a sample request.
+const policyDebugPath = "/.pomerium/admin/debug/policy"
+
+// policyDebugRequest is the sample request evaluated by the policy debug
+// endpoint.
+type policyDebugRequest struct {
+	Method string   `json:"method"`
+	URL    string   `json:"url"`
+	Email  string   `json:"email,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// policyDebugResult is the body of a policy debug response.
+type policyDebugResult struct {
+	Request     policyDebugRequest `json:"request"`
+	Allow       bool               `json:"allow"`
+	DenyReasons []string           `json:"deny_reasons,omitempty"`
+	DenyRuleIDs []string           `json:"deny_rule_ids,omitempty"`
+	Policy      *config.Policy     `json:"policy"`
+	Conditions  map[string]bool    `json:"conditions"`
+}
+
+// isPolicyDebugRequest reports whether the request is for the policy debug
+// endpoint.
+func isPolicyDebugRequest(in *envoy_service_auth_v2.CheckRequest) bool {
+	return getCheckRequestURL(in).Path == policyDebugPath
+}
+
+// policyDebugResponse evaluates policy for the sample request described by the
+// query parameters of an administrator's request, and responds with the
+// decision, the matched route policy and the outcomes of its conditions.
+//
+// The sample request is made as the administrator unless the email or groups
+// parameters are set.
+func (a *Authorize) policyDebugResponse(ctx context.Context, in *envoy_service_auth_v2.CheckRequest, rawJWT []byte) *envoy_service_auth_v2.CheckResponse {
+	state, ok := a.getAdministratorSession(rawJWT)
+	if !ok {
+		a.emitDenyEvent(in, state.Email, http.StatusForbidden, "policy debugging requires an administrator")
+		return a.deniedResponse(in, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil)
+	}
+	query := getCheckRequestURL(in).Query()
+	sample := policyDebugRequest{
+		Method: strings.ToUpper(query.Get("method")),
+		URL:    query.Get("url"),
+	}
+	if sample.Method == "" {
+		sample.Method = http.MethodGet
+	}
+	sampleURL, err := url.Parse(sample.URL)
+	if err != nil || sampleURL.Host == "" {
+		return a.invalidRequestResponse(in, "url must be an absolute url")
+	}
+
+	sample.Email, sample.Groups = state.Email, state.Groups
+	if email := query.Get("email"); email != "" {
+		sample.Email = email
+	}
+	if groups := query.Get("groups"); groups != "" {
+		sample.Groups = strings.Split(groups, ",")
+	}
+
+	sampleJWT, err := a.signSampleSession(state, sampleURL.Hostname(), sample.Email, sample.Groups)
+	if err != nil {
+		log.Error().Err(err).Msg("authorize: error signing policy debug session")
+		return a.deniedResponse(in, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+	}
+
+	req := &evaluator.Request{
+		User:       string(sampleJWT),
+		Host:       sampleURL.Host,
+		Method:     sample.Method,
+		RequestURI: sampleURL.String(),
+		URL:        sampleURL.String(),
+	}
+	reply, err := a.pe.IsAuthorized(ctx, req)
+	if err != nil {
+		log.Error().Err(err).Msg("authorize: error evaluating policy debug request")
+		return a.deniedResponse(in, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+	}
+	explanation, err := a.pe.Explain(ctx, req)
+	if err != nil {
+		log.Error().Err(err).Msg("authorize: error explaining policy debug request")
+		return a.deniedResponse(in, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+	}
+
+	result := policyDebugResult{
+		Request:     sample,
+		Allow:       reply.GetAllow(),
+		DenyReasons: reply.GetDenyReasons(),
+		DenyRuleIDs: reply.GetDenyRuleIds(),
+		Conditions:  explanation.Conditions,
+	}
+	if policies := a.currentOptions.Load().Policies; explanation.Route >= 0 && explanation.Route < len(policies) {
+		result.Policy = &policies[explanation.Route]
+	}
+	return a.policyDebugJSONResponse(in, "Policy Debug", result)
+}
+
+// signSampleSession signs a short-lived session, based on the administrator's
+// session, for the sample user's request to the host, so that it's evaluated
+// exactly as a request from the sample user would be.
+func (a *Authorize) signSampleSession(state sessions.State, host, email string, groups []string) ([]byte, error) {
+	now := time.Now()
+	state.Audience = jwt.Audience{host}
+	state.Expiry = jwt.NewNumericDate(now.Add(time.Minute))
+	state.IssuedAt = jwt.NewNumericDate(now)
+	state.NotBefore = jwt.NewNumericDate(now)
+	state.Email, state.Groups = email, groups
+	state.ImpersonateEmail, state.ImpersonateGroups = "", nil
+	return a.currentEncoder.Load().Marshal(&state)
+}
+
+// policyDebugJSONResponse responds to an administrator's debug request with
+// the JSON result.
+func (a *Authorize) policyDebugJSONResponse(in *envoy_service_auth_v2.CheckRequest, message string, result interface{}) *envoy_service_auth_v2.CheckResponse {
+	body, err := json.Marshal(result)
+	if err != nil {
+		log.Error().Err(err).Msg("authorize: error encoding policy debug response")
+		return a.deniedResponse(in, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+	}
+
+	return &envoy_service_auth_v2.CheckResponse{
+		Status: &status.Status{Code: int32(codes.PermissionDenied), Message: message},
+		HttpResponse: &envoy_service_auth_v2.CheckResponse_DeniedResponse{
+			DeniedResponse: &envoy_service_auth_v2.DeniedHttpResponse{
+				Status: &envoy_type.HttpStatus{Code: envoy_type.StatusCode_OK},
+				Headers: []*envoy_api_v2_core.HeaderValueOption{
+					mkHeader("Content-Type", "application/json"),
+					mkHeader("Cache-Control", "no-store"),
+				},
+				Body: string(body),
+			},
+		},
+	}
+}
+
+// getAdministratorSession returns the verified session, and whether it's an
+// administrator's. The debug endpoints check this themselves, rather than
+// relying on policy to deny other users, as they disclose every route's
+// policy.
+func (a *Authorize) getAdministratorSession(rawJWT []byte) (sessions.State, bool) {
+	var state sessions.State
+	if len(rawJWT) == 0 {
+		return state, false
+	}
+	if err := a.currentEncoder.Load().Unmarshal(rawJWT, &state); err != nil {
+		return sessions.State{}, false
+	}
+	return state, !state.IsExpired() && state.Email != "" && containsString(a.currentOptions.Load().Administrators, state.Email)
+}
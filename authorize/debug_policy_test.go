@@ -0,0 +1,156 @@
+package authorize
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+)
+
+func TestAuthorize_Check_debugPolicy(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://public.example.com", To: "http://localhost", AllowPublicUnauthenticatedAccess: true},
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	newAuthorize := func(t *testing.T, enabled bool) *Authorize {
+		t.Helper()
+		opts := *config.NewDefaultOptions()
+		opts.Policies = policies
+		opts.CookieName = "_pomerium"
+		opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+		opts.SharedKey = sharedKey
+		opts.Administrators = []string{"admin@example.com"}
+		opts.DebugPolicy = enabled
+		a, err := New(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	// check makes a policy debug request as the user, returning the status code
+	// and body of the response, or 0 if the request is passed upstream
+	check := func(t *testing.T, a *Authorize, email string, query url.Values) (int, string) {
+		t.Helper()
+		rawJWT := testSessionJWT(t, sharedKey, email, "app.example.com", time.Now().Add(time.Hour))
+		res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com"+policyDebugPath+"?"+query.Encode(), map[string]string{
+			"accept": "application/json",
+			"cookie": "_pomerium=" + rawJWT,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.GetOkResponse() != nil {
+			return 0, ""
+		}
+		return int(res.GetDeniedResponse().GetStatus().GetCode()), res.GetDeniedResponse().GetBody()
+	}
+
+	// the serialized policy isn't decodable to a config.Policy, so it's
+	// decoded generically
+	type result struct {
+		policyDebugResult
+		Policy map[string]interface{} `json:"policy"`
+	}
+	decode := func(t *testing.T, body string) result {
+		t.Helper()
+		var r result
+		if err := json.Unmarshal([]byte(body), &r); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	a := newAuthorize(t, true)
+
+	t.Run("matched policy", func(t *testing.T) {
+		code, body := check(t, a, "admin@example.com", url.Values{
+			"url":   {"https://app.example.com/items"},
+			"email": {"bob@example.com"},
+		})
+		if !assert.Equal(t, http.StatusOK, code, body) {
+			return
+		}
+		result := decode(t, body)
+		assert.Equal(t, policyDebugRequest{Method: "GET", URL: "https://app.example.com/items", Email: "bob@example.com"}, result.Request)
+		assert.True(t, result.Allow)
+		if assert.NotNil(t, result.Policy) {
+			assert.Equal(t, "https://app.example.com", result.Policy["source"])
+			assert.Equal(t, []interface{}{"bob@example.com"}, result.Policy["allowed_users"])
+		}
+		assert.True(t, result.Conditions["route_matched"])
+		assert.True(t, result.Conditions["token_valid"])
+		assert.True(t, result.Conditions["allowed_user"])
+		assert.False(t, result.Conditions["public"])
+		assert.False(t, result.Conditions["denied"])
+	})
+	t.Run("denied sample", func(t *testing.T) {
+		code, body := check(t, a, "admin@example.com", url.Values{
+			"url":    {"https://app.example.com/items"},
+			"method": {"post"},
+		})
+		if !assert.Equal(t, http.StatusOK, code, body) {
+			return
+		}
+		result := decode(t, body)
+		assert.Equal(t, policyDebugRequest{Method: "POST", URL: "https://app.example.com/items", Email: "admin@example.com"}, result.Request)
+		assert.False(t, result.Allow)
+		assert.Equal(t, []string{"route_policies[1]"}, result.DenyRuleIDs)
+		assert.True(t, result.Conditions["route_matched"])
+		assert.False(t, result.Conditions["allowed_user"])
+	})
+	t.Run("no matching policy", func(t *testing.T) {
+		code, body := check(t, a, "admin@example.com", url.Values{"url": {"https://unknown.example.com/"}})
+		if !assert.Equal(t, http.StatusOK, code, body) {
+			return
+		}
+		result := decode(t, body)
+		assert.Nil(t, result.Policy)
+		assert.False(t, result.Conditions["route_matched"])
+	})
+	t.Run("missing url", func(t *testing.T) {
+		code, _ := check(t, a, "admin@example.com", url.Values{})
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+	t.Run("not an administrator", func(t *testing.T) {
+		code, _ := check(t, a, "bob@example.com", url.Values{"url": {"https://app.example.com/items"}})
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+	t.Run("not an administrator, on a public route", func(t *testing.T) {
+		query := url.Values{"url": {"https://app.example.com/items"}, "email": {"bob@example.com"}}
+		for _, cookie := range []string{"", "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "public.example.com", time.Now().Add(time.Hour))} {
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://public.example.com"+policyDebugPath+"?"+query.Encode(), map[string]string{
+				"accept": "application/json",
+				"cookie": cookie,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			if assert.Nil(t, res.GetOkResponse()) {
+				assert.NotEqual(t, http.StatusOK, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			}
+		}
+	})
+	t.Run("not an administrator, if policy allows", func(t *testing.T) {
+		rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour))
+		res := a.policyDebugResponse(context.TODO(), testCheckRequest("GET", "https://app.example.com"+policyDebugPath+"?url=https://app.example.com/", nil), []byte(rawJWT))
+		assert.Equal(t, http.StatusForbidden, int(res.GetDeniedResponse().GetStatus().GetCode()))
+	})
+	t.Run("disabled", func(t *testing.T) {
+		code, _ := check(t, newAuthorize(t, false), "admin@example.com", url.Values{"url": {"https://app.example.com/items"}})
+		assert.Equal(t, 0, code, "expected the request to be passed upstream")
+	})
+}
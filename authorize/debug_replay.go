@@ -0,0 +1,107 @@
+package authorize
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// policyReplayPath is the administrator endpoint replaying a past request,
+// captured in the audit log, against the current policy.
+const policyReplayPath = "/.pomerium/admin/debug/replay"
+
+// policyReplayResult is the body of a policy replay response.
+type policyReplayResult struct {
+	Request     *evaluator.Request `json:"request"`
+	Email       string             `json:"email,omitempty"`
+	Groups      []string           `json:"groups,omitempty"`
+	Allow       bool               `json:"allow"`
+	DenyReasons []string           `json:"deny_reasons,omitempty"`
+	DenyRuleIDs []string           `json:"deny_rule_ids,omitempty"`
+	Policy      *config.Policy     `json:"policy"`
+	Conditions  map[string]bool    `json:"conditions"`
+}
+
+// isPolicyReplayRequest reports whether the request is for the policy replay
+// endpoint.
+func isPolicyReplayRequest(in *envoy_service_auth_v2.CheckRequest) bool {
+	return getCheckRequestURL(in).Path == policyReplayPath
+}
+
+// policyReplayResponse evaluates the past request in the request query
+// parameter of an administrator's request, as captured in the audit log,
+// against the current policy and at the current time. It responds with the
+// decision, the matched route policy and the outcomes of its conditions.
+//
+// The past request is made as the user in the email and groups parameters,
+// the audit record's, or without a session if neither is set.
+func (a *Authorize) policyReplayResponse(ctx context.Context, in *envoy_service_auth_v2.CheckRequest, rawJWT []byte) *envoy_service_auth_v2.CheckResponse {
+	state, ok := a.getAdministratorSession(rawJWT)
+	if !ok {
+		a.emitDenyEvent(in, state.Email, http.StatusForbidden, "policy replay requires an administrator")
+		return a.deniedResponse(in, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil)
+	}
+	query := getCheckRequestURL(in).Query()
+	var req evaluator.Request
+	if err := json.Unmarshal([]byte(query.Get("request")), &req); err != nil {
+		return a.invalidRequestResponse(in, "request must be a captured request")
+	}
+	reqURL, err := url.Parse(req.URL)
+	if err != nil || reqURL.Host == "" {
+		return a.invalidRequestResponse(in, "request url must be an absolute url")
+	}
+	req.User = ""
+	req.Method = strings.ToUpper(req.Method)
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	if req.Host == "" {
+		req.Host = reqURL.Host
+	}
+	if req.RequestURI == "" {
+		req.RequestURI = req.URL
+	}
+	req.Time = a.getRequestTime()
+
+	result := policyReplayResult{Email: query.Get("email")}
+	if groups := query.Get("groups"); groups != "" {
+		result.Groups = strings.Split(groups, ",")
+	}
+	if result.Email != "" || len(result.Groups) > 0 {
+		replayJWT, err := a.signSampleSession(state, reqURL.Hostname(), result.Email, result.Groups)
+		if err != nil {
+			log.Error().Err(err).Msg("authorize: error signing policy replay session")
+			return a.deniedResponse(in, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+		}
+		req.User = string(replayJWT)
+	}
+
+	reply, err := a.pe.IsAuthorized(ctx, &req)
+	if err != nil {
+		log.Error().Err(err).Msg("authorize: error evaluating policy replay request")
+		return a.deniedResponse(in, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+	}
+	explanation, err := a.pe.Explain(ctx, &req)
+	if err != nil {
+		log.Error().Err(err).Msg("authorize: error explaining policy replay request")
+		return a.deniedResponse(in, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError), nil)
+	}
+
+	result.Request = getAuditLogRequest(&req, a.currentOptions.Load().ReservedHeaderPrefix)
+	result.Allow = reply.GetAllow()
+	result.DenyReasons = reply.GetDenyReasons()
+	result.DenyRuleIDs = reply.GetDenyRuleIds()
+	result.Conditions = explanation.Conditions
+	if policies := a.currentOptions.Load().Policies; explanation.Route >= 0 && explanation.Route < len(policies) {
+		result.Policy = &policies[explanation.Route]
+	}
+	return a.policyDebugJSONResponse(in, "Policy Replay", result)
+}
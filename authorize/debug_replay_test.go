@@ -0,0 +1,194 @@
+package authorize
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+)
+
+func TestAuthorize_Check_debugReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pomerium-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sharedKey := cryptutil.NewBase64Key()
+	newOptions := func(t *testing.T, allowedUsers ...string) config.Options {
+		t.Helper()
+		policies := []config.Policy{
+			{From: "https://app.example.com", To: "http://localhost", AllowedUsers: allowedUsers},
+			{From: "https://public.example.com", To: "http://localhost", AllowPublicUnauthenticatedAccess: true},
+		}
+		for i := range policies {
+			if err := policies[i].Validate(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		opts := *config.NewDefaultOptions()
+		opts.Policies = policies
+		opts.CookieName = "_pomerium"
+		opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+		opts.SharedKey = sharedKey
+		opts.Administrators = []string{"admin@example.com"}
+		opts.DebugPolicy = true
+		opts.AuditLogFile = filepath.Join(dir, "audit.log")
+		opts.AuditLogRequests = true
+		return opts
+	}
+	a, err := New(newOptions(t, "bob@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.auditLog.Load().Close()
+
+	// capture bob's request in the audit log
+	bobJWT := testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour))
+	res, err := a.Check(context.TODO(), testCheckRequest("POST", "https://app.example.com/items?q=1", map[string]string{
+		"accept": "application/json",
+		"cookie": "_pomerium=" + bobJWT,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.NotNil(t, res.GetOkResponse()) {
+		return
+	}
+	f, err := os.Open(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		t.Fatal("no audit record")
+	}
+	assert.False(t, strings.Contains(scanner.Text(), bobJWT), "audit record contains a session")
+	var record struct {
+		Email   string          `json:"email"`
+		Request json.RawMessage `json:"request"`
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if !assert.NotEmpty(t, record.Request) {
+		return
+	}
+
+	// replay makes a request to the replay endpoint as the administrator,
+	// returning the status code and decoded body of the response
+	replay := func(t *testing.T, a *Authorize, email string, query url.Values) (int, policyReplayResult) {
+		t.Helper()
+		rawJWT := testSessionJWT(t, sharedKey, email, "app.example.com", time.Now().Add(time.Hour))
+		res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com"+policyReplayPath+"?"+query.Encode(), map[string]string{
+			"accept": "application/json",
+			"cookie": "_pomerium=" + rawJWT,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result policyReplayResult
+		if res.GetOkResponse() != nil {
+			return 0, result
+		}
+		code := int(res.GetDeniedResponse().GetStatus().GetCode())
+		if code == http.StatusOK {
+			// the serialized policy isn't decodable to a config.Policy
+			var body struct {
+				policyReplayResult
+				Policy map[string]interface{} `json:"policy"`
+			}
+			if err := json.Unmarshal([]byte(res.GetDeniedResponse().GetBody()), &body); err != nil {
+				t.Fatal(err)
+			}
+			result = body.policyReplayResult
+		}
+		return code, result
+	}
+
+	t.Run("allowed", func(t *testing.T) {
+		code, result := replay(t, a, "admin@example.com", url.Values{
+			"request": {string(record.Request)},
+			"email":   {record.Email},
+		})
+		if !assert.Equal(t, http.StatusOK, code) {
+			return
+		}
+		assert.True(t, result.Allow)
+		assert.Equal(t, "bob@example.com", result.Email)
+		if assert.NotNil(t, result.Request) {
+			assert.Equal(t, "POST", result.Request.Method)
+			assert.Equal(t, "https://app.example.com/items?q=1", result.Request.URL)
+			assert.Empty(t, result.Request.User)
+		}
+		assert.True(t, result.Conditions["route_matched"])
+		assert.True(t, result.Conditions["allowed_user"])
+	})
+	t.Run("denied by the current policy", func(t *testing.T) {
+		b, err := New(newOptions(t, "alice@example.com"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer b.auditLog.Load().Close()
+		code, result := replay(t, b, "admin@example.com", url.Values{
+			"request": {string(record.Request)},
+			"email":   {record.Email},
+		})
+		if !assert.Equal(t, http.StatusOK, code) {
+			return
+		}
+		assert.False(t, result.Allow)
+		assert.Equal(t, []string{"route_policies[0]"}, result.DenyRuleIDs)
+		assert.False(t, result.Conditions["allowed_user"])
+	})
+	t.Run("without a session", func(t *testing.T) {
+		code, result := replay(t, a, "admin@example.com", url.Values{"request": {string(record.Request)}})
+		if !assert.Equal(t, http.StatusOK, code) {
+			return
+		}
+		assert.False(t, result.Allow)
+		assert.False(t, result.Conditions["token_valid"])
+	})
+	t.Run("bad request", func(t *testing.T) {
+		code, _ := replay(t, a, "admin@example.com", url.Values{"request": {"{"}})
+		assert.Equal(t, http.StatusBadRequest, code)
+		code, _ = replay(t, a, "admin@example.com", url.Values{"request": {`{"url":"/items"}`}})
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+	t.Run("not an administrator", func(t *testing.T) {
+		code, _ := replay(t, a, "bob@example.com", url.Values{"request": {string(record.Request)}})
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+	t.Run("not an administrator, on a public route", func(t *testing.T) {
+		query := url.Values{"request": {string(record.Request)}}
+		for _, cookie := range []string{"", "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "public.example.com", time.Now().Add(time.Hour))} {
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://public.example.com"+policyReplayPath+"?"+query.Encode(), map[string]string{
+				"accept": "application/json",
+				"cookie": cookie,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			if assert.Nil(t, res.GetOkResponse()) {
+				assert.NotEqual(t, http.StatusOK, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			}
+		}
+	})
+	t.Run("not an administrator, if policy allows", func(t *testing.T) {
+		res := a.policyReplayResponse(context.TODO(), testCheckRequest("GET", "https://app.example.com"+policyReplayPath, nil), nil)
+		assert.Equal(t, http.StatusForbidden, int(res.GetDeniedResponse().GetStatus().GetCode()))
+	})
+}
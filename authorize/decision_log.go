@@ -0,0 +1,327 @@
+package authorize
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/telemetry/requestid"
+	"github.com/pomerium/pomerium/internal/version"
+)
+
+const (
+	// decisionLogPath is the policy decision path, the package of the
+	// authorization policy.
+	decisionLogPath = "pomerium/authz"
+	// decisionLogBufferSize is the number of decisions buffered for delivery.
+	// Beyond it, decisions are dropped rather than holding up checks.
+	decisionLogBufferSize = 10000
+	// decisionLogRetries is the number of times a failed delivery is retried.
+	decisionLogRetries = 3
+)
+
+// decisionLogRetryDelay is the delay before the first retry of a failed
+// delivery, doubled for each retry after it. It's a variable for tests.
+var decisionLogRetryDelay = time.Second
+
+// decisionLogID identifies this instance in its decisions' labels.
+var decisionLogID = requestid.New()
+
+// decisionLogSensitiveHeaders are the request headers left out of decision
+// log input, as they carry credentials. Headers with pomerium's reserved
+// prefix are also left out, as are those with the default prefix, since
+// pomerium's own credential headers, e.g. override tokens, keep it.
+var decisionLogSensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"cookie":              true,
+	"proxy-authorization": true,
+}
+
+// isDecisionLogSensitiveHeader reports whether a request header is left out
+// of decision log input, given the configured reserved header prefix.
+func isDecisionLogSensitiveHeader(name, reservedHeaderPrefix string) bool {
+	lk := strings.ToLower(name)
+	return decisionLogSensitiveHeaders[lk] ||
+		strings.HasPrefix(lk, config.DefaultReservedHeaderPrefix) ||
+		(reservedHeaderPrefix != "" && strings.HasPrefix(lk, strings.ToLower(reservedHeaderPrefix)))
+}
+
+// decisionLog sends each authorization decision, in batches, to an endpoint
+// accepting OPA's decision log format.
+type decisionLog struct {
+	url           string
+	batchSize     int
+	flushInterval time.Duration
+	// reservedHeaderPrefix is the prefix of pomerium's headers, which are left
+	// out of decisions
+	reservedHeaderPrefix string
+	labels               map[string]string
+
+	events    chan *decisionLogEvent
+	closeOnce sync.Once
+	closed    chan struct{}
+	stopped   chan struct{}
+
+	// mu is held to queue decisions, so that none are queued once the
+	// queue's been drained for closing
+	mu       sync.RWMutex
+	isClosed bool
+}
+
+// decisionLogEvent is a decision in OPA's decision log format.
+type decisionLogEvent struct {
+	Labels      map[string]string `json:"labels"`
+	DecisionID  string            `json:"decision_id"`
+	Path        string            `json:"path"`
+	Input       decisionLogInput  `json:"input"`
+	Result      decisionLogResult `json:"result"`
+	RequestedBy string            `json:"requested_by"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Metrics     map[string]int64  `json:"metrics"`
+}
+
+// decisionLogInput is the input of a decision. Attributes are the check
+// request attributes, as in the input of OPA's envoy ext_authz plugin,
+// without the request body or credentials.
+type decisionLogInput struct {
+	Attributes json.RawMessage `json:"attributes"`
+	Email      string          `json:"email,omitempty"`
+	Groups     []string        `json:"groups,omitempty"`
+}
+
+type decisionLogResult struct {
+	Allowed     bool     `json:"allowed"`
+	DenyReasons []string `json:"deny_reasons,omitempty"`
+	DenyRuleIDs []string `json:"deny_rule_ids,omitempty"`
+}
+
+// newDecisionLog starts the delivery of decisions to the decision log url,
+// or returns nil if there isn't one.
+func newDecisionLog(opts *config.Options) *decisionLog {
+	if opts.DecisionLogURL == nil {
+		return nil
+	}
+	l := &decisionLog{
+		url:                  opts.DecisionLogURL.String(),
+		batchSize:            opts.DecisionLogBatchSize,
+		flushInterval:        opts.DecisionLogFlushInterval,
+		reservedHeaderPrefix: opts.ReservedHeaderPrefix,
+		labels: map[string]string{
+			"app":     "pomerium",
+			"id":      decisionLogID,
+			"version": version.FullVersion(),
+		},
+		events:  make(chan *decisionLogEvent, decisionLogBufferSize),
+		closed:  make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// isDecisionLogChanged reports whether the decision log options have changed.
+func isDecisionLogChanged(prev, opts *config.Options) bool {
+	return prev.DecisionLogURLString != opts.DecisionLogURLString ||
+		prev.DecisionLogBatchSize != opts.DecisionLogBatchSize ||
+		prev.DecisionLogFlushInterval != opts.DecisionLogFlushInterval ||
+		prev.ReservedHeaderPrefix != opts.ReservedHeaderPrefix
+}
+
+// updateDecisionLog replaces the decision log if its options have changed.
+// Checks record to the new log before the old one is closed, once the
+// decisions queued to it are sent.
+func (a *Authorize) updateDecisionLog(prev, opts *config.Options) {
+	if (a.decisionLog.Load() != nil || opts.DecisionLogURL == nil) && !isDecisionLogChanged(prev, opts) {
+		return
+	}
+	prevLog := a.decisionLog.Load()
+	a.decisionLog.Store(newDecisionLog(opts))
+	prevLog.Close()
+}
+
+// Record queues the decision for a request from the client for delivery.
+func (l *decisionLog) Record(ctx context.Context, in *envoy_service_auth_v2.CheckRequest, reply *authorize.IsAuthorizedReply, clientIP string, elapsed time.Duration) {
+	if l == nil {
+		return
+	}
+	evt := &decisionLogEvent{
+		Labels:     l.labels,
+		DecisionID: requestid.FromContext(ctx),
+		Path:       decisionLogPath,
+		Input: decisionLogInput{
+			Attributes: getDecisionLogAttributes(in, l.reservedHeaderPrefix),
+			Email:      reply.GetEmail(),
+			Groups:     reply.GetGroups(),
+		},
+		Result: decisionLogResult{
+			Allowed:     reply.GetAllow(),
+			DenyReasons: reply.GetDenyReasons(),
+			DenyRuleIDs: reply.GetDenyRuleIds(),
+		},
+		RequestedBy: clientIP,
+		Timestamp:   timeNow().UTC(),
+		Metrics: map[string]int64{
+			"timer_rego_query_eval_ns": elapsed.Nanoseconds(),
+		},
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.isClosed {
+		return
+	}
+	select {
+	case l.events <- evt:
+	default:
+		log.Warn().Str("request-id", evt.DecisionID).Msg("authorize: decision log buffer is full, dropping decision")
+	}
+}
+
+// getDecisionLogAttributes returns the JSON check request attributes without
+// the request body or credentials.
+func getDecisionLogAttributes(in *envoy_service_auth_v2.CheckRequest, reservedHeaderPrefix string) json.RawMessage {
+	attrs, ok := proto.Clone(in.GetAttributes()).(*envoy_service_auth_v2.AttributeContext)
+	if !ok || attrs == nil {
+		return json.RawMessage("{}")
+	}
+	if hattrs := attrs.GetRequest().GetHttp(); hattrs != nil {
+		hattrs.Body = ""
+		for k := range hattrs.Headers {
+			if isDecisionLogSensitiveHeader(k, reservedHeaderPrefix) {
+				delete(hattrs.Headers, k)
+			}
+		}
+	}
+	bs, err := protojson.Marshal(proto.MessageV2(attrs))
+	if err != nil {
+		return json.RawMessage("{}")
+	}
+	return bs
+}
+
+// run collects decisions into batches, which are delivered once they're full
+// or every flush interval, until the decision log is closed.
+func (l *decisionLog) run() {
+	defer close(l.stopped)
+	ticker := time.NewTicker(l.flushInterval)
+	defer ticker.Stop()
+
+	var batch []*decisionLogEvent
+	for {
+		select {
+		case evt := <-l.events:
+			batch = append(batch, evt)
+			if len(batch) < l.batchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-l.closed:
+			for {
+				select {
+				case evt := <-l.events:
+					batch = append(batch, evt)
+					if len(batch) == l.batchSize {
+						l.deliver(batch)
+						batch = nil
+					}
+				default:
+					l.deliver(batch)
+					return
+				}
+			}
+		}
+		l.deliver(batch)
+		batch = nil
+	}
+}
+
+// deliver sends a batch, retrying failures with an exponential backoff. Once
+// the decision log is closed, failures aren't retried.
+func (l *decisionLog) deliver(batch []*decisionLogEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := encodeDecisionLogBatch(batch)
+	if err != nil {
+		log.Error().Err(err).Msg("authorize: error encoding decision log batch")
+		return
+	}
+	delay := decisionLogRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := l.send(body)
+		if err == nil {
+			return
+		}
+		if attempt == decisionLogRetries {
+			log.Warn().Err(err).Int("decisions", len(batch)).Msg("authorize: error sending decision log batch, dropping it")
+			return
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-l.closed:
+			log.Warn().Err(err).Int("decisions", len(batch)).Msg("authorize: error sending decision log batch, dropping it")
+			return
+		}
+	}
+}
+
+// encodeDecisionLogBatch encodes a batch as a gzip-compressed JSON array, as
+// OPA's decision log plugin does.
+func encodeDecisionLogBatch(batch []*decisionLogEvent) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(batch); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (l *decisionLog) send(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, l.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("User-Agent", version.UserAgent())
+	res, err := httputil.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status: %s", res.Status)
+	}
+	return nil
+}
+
+// Close delivers the queued decisions and stops the decision log.
+func (l *decisionLog) Close() {
+	if l == nil {
+		return
+	}
+	l.closeOnce.Do(func() {
+		l.mu.Lock()
+		l.isClosed = true
+		l.mu.Unlock()
+		close(l.closed)
+	})
+	<-l.stopped
+}
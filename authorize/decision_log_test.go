@@ -0,0 +1,241 @@
+package authorize
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+)
+
+// newDecisionLogServer returns a server which sends the decoded batches it
+// receives on the returned channel. The first failures requests fail.
+func newDecisionLogServer(t *testing.T, failures int32) (*httptest.Server, <-chan []map[string]interface{}) {
+	batches := make(chan []map[string]interface{}, 10)
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		if atomic.AddInt32(&requests, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var batch []map[string]interface{}
+		if !assert.NoError(t, json.NewDecoder(zr).Decode(&batch)) {
+			return
+		}
+		batches <- batch
+	}))
+	return srv, batches
+}
+
+func receiveDecisionLogBatch(t *testing.T, batches <-chan []map[string]interface{}) []map[string]interface{} {
+	t.Helper()
+	select {
+	case batch := <-batches:
+		return batch
+	case <-time.After(5 * time.Second):
+		t.Fatal("no decision log batch received")
+		return nil
+	}
+}
+
+func TestAuthorize_Check_decisionLog(t *testing.T) {
+	srv, batches := newDecisionLogServer(t, 0)
+	defer srv.Close()
+
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	opts.DecisionLogURL = mustParseURL(srv.URL + "/logs")
+	opts.DecisionLogBatchSize = 2
+	opts.DecisionLogFlushInterval = time.Hour
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.decisionLog.Load().Close()
+
+	sessionJWTs := map[string]string{}
+	for _, email := range []string{"bob@example.com", "alice@example.com"} {
+		sessionJWTs[email] = testSessionJWT(t, sharedKey, email, "app.example.com", time.Now().Add(time.Hour))
+		_, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/some/path?q=1", map[string]string{
+			"cookie":                    "_pomerium=" + sessionJWTs[email],
+			"authorization":             "Bearer secret",
+			"x-pomerium-override-token": "secret",
+			"accept":                    "text/html",
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the batch is sent once it's full
+	batch := receiveDecisionLogBatch(t, batches)
+	if !assert.Len(t, batch, 2) {
+		return
+	}
+	for _, evt := range batch {
+		bs, _ := json.Marshal(evt)
+		for _, session := range sessionJWTs {
+			assert.False(t, strings.Contains(string(bs), session), "decision contains a session")
+		}
+		assert.False(t, strings.Contains(string(bs), "secret"), "decision contains credentials")
+
+		for _, field := range []string{"labels", "decision_id", "path", "input", "result", "requested_by", "timestamp", "metrics"} {
+			assert.Contains(t, evt, field)
+		}
+		labels, _ := evt["labels"].(map[string]interface{})
+		assert.Equal(t, "pomerium", labels["app"])
+		assert.NotEmpty(t, labels["id"])
+		assert.NotEmpty(t, labels["version"])
+		assert.Equal(t, "pomerium/authz", evt["path"])
+		ts, _ := evt["timestamp"].(string)
+		_, err := time.Parse(time.RFC3339Nano, ts)
+		assert.NoError(t, err)
+		metrics, _ := evt["metrics"].(map[string]interface{})
+		assert.Contains(t, metrics, "timer_rego_query_eval_ns")
+
+		input, _ := evt["input"].(map[string]interface{})
+		attrs, _ := input["attributes"].(map[string]interface{})
+		req, _ := attrs["request"].(map[string]interface{})
+		hattrs, _ := req["http"].(map[string]interface{})
+		assert.Equal(t, "GET", hattrs["method"])
+		assert.Equal(t, "app.example.com", hattrs["host"])
+		assert.Equal(t, "/some/path?q=1", hattrs["path"])
+		headers, _ := hattrs["headers"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"accept": "text/html"}, headers)
+	}
+
+	assert.Equal(t, "bob@example.com", batch[0]["input"].(map[string]interface{})["email"])
+	assert.Equal(t, map[string]interface{}{"allowed": true}, batch[0]["result"])
+	assert.Equal(t, "alice@example.com", batch[1]["input"].(map[string]interface{})["email"])
+	result, _ := batch[1]["result"].(map[string]interface{})
+	assert.Equal(t, false, result["allowed"])
+	assert.NotEmpty(t, result["deny_rule_ids"])
+}
+
+func TestDecisionLog_retry(t *testing.T) {
+	decisionLogRetryDelay = time.Millisecond
+	defer func() { decisionLogRetryDelay = time.Second }()
+
+	srv, batches := newDecisionLogServer(t, 2)
+	defer srv.Close()
+
+	l := newDecisionLog(&config.Options{
+		DecisionLogURL:           mustParseURL(srv.URL),
+		DecisionLogBatchSize:     1,
+		DecisionLogFlushInterval: time.Hour,
+	})
+	defer l.Close()
+	l.Record(context.TODO(), testCheckRequest("GET", "https://app.example.com/", nil), &authorize.IsAuthorizedReply{Allow: true}, "10.0.0.1", time.Millisecond)
+
+	batch := receiveDecisionLogBatch(t, batches)
+	if assert.Len(t, batch, 1) {
+		assert.Equal(t, "10.0.0.1", batch[0]["requested_by"])
+	}
+}
+
+func TestDecisionLog_Close(t *testing.T) {
+	srv, batches := newDecisionLogServer(t, 0)
+	defer srv.Close()
+
+	l := newDecisionLog(&config.Options{
+		DecisionLogURL:           mustParseURL(srv.URL),
+		DecisionLogBatchSize:     2,
+		DecisionLogFlushInterval: time.Hour,
+	})
+	for i := 0; i < 3; i++ {
+		l.Record(context.TODO(), testCheckRequest("GET", "https://app.example.com/", nil), &authorize.IsAuthorizedReply{}, "10.0.0.1", time.Millisecond)
+	}
+
+	// queued decisions are sent before it's closed
+	l.Close()
+	total := len(receiveDecisionLogBatch(t, batches))
+	if total < 3 {
+		total += len(receiveDecisionLogBatch(t, batches))
+	}
+	assert.Equal(t, 3, total)
+}
+
+func Test_isDecisionLogSensitiveHeader(t *testing.T) {
+	tests := []struct {
+		name                 string
+		header               string
+		reservedHeaderPrefix string
+		want                 bool
+	}{
+		{"cookie", "Cookie", "", true},
+		{"authorization", "Authorization", "x-corp-", true},
+		{"default prefix", "X-Pomerium-Override-Token", "", true},
+		{"other header", "Accept", "", false},
+		{"configured prefix", "X-Corp-Override-Token", "x-corp-", true},
+		{"configured prefix, mixed case", "x-corp-override-token", "X-Corp-", true},
+		{"default prefix, when another is configured", "X-Pomerium-Override-Token", "x-corp-", true},
+		{"other header, when another prefix is configured", "X-Request-Id", "x-corp-", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isDecisionLogSensitiveHeader(tt.header, tt.reservedHeaderPrefix))
+		})
+	}
+}
+
+func TestAuthorize_updateDecisionLog(t *testing.T) {
+	srv, _ := newDecisionLogServer(t, 0)
+	defer srv.Close()
+
+	opts := *config.NewDefaultOptions()
+	opts.DecisionLogURL = mustParseURL(srv.URL)
+	opts.DecisionLogBatchSize = 1000
+	opts.DecisionLogFlushInterval = time.Hour
+	a := new(Authorize)
+	a.updateDecisionLog(&config.Options{}, &opts)
+
+	// checks keep recording while the decision log is replaced
+	in := testCheckRequest("GET", "https://app.example.com/", nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				a.decisionLog.Load().Record(context.TODO(), in, &authorize.IsAuthorizedReply{}, "10.0.0.1", time.Millisecond)
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		prev := opts
+		opts.DecisionLogBatchSize = 1000 + i + 1
+		a.updateDecisionLog(&prev, &opts)
+	}
+	wg.Wait()
+	a.decisionLog.Load().Close()
+}
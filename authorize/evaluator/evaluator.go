@@ -4,6 +4,8 @@ package evaluator
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	pb "github.com/pomerium/pomerium/internal/grpc/authorize"
 )
@@ -12,6 +14,18 @@ import (
 type Evaluator interface {
 	IsAuthorized(ctx context.Context, req *Request) (*pb.IsAuthorizedReply, error)
 	PutData(ctx context.Context, data map[string]interface{}) error
+	// Explain returns how policy was evaluated for a request, for debugging.
+	Explain(ctx context.Context, req *Request) (*Explanation, error)
+}
+
+// An Explanation describes how policy was evaluated for a request.
+type Explanation struct {
+	// Route is the index of the route policy matching the request, or -1 if
+	// no route matched.
+	Route int `json:"route"`
+	// Conditions are the outcomes of the matched route policy's conditions,
+	// keyed by condition (e.g. "allowed_user").
+	Conditions map[string]bool `json:"conditions"`
 }
 
 // A Request represents an evaluable request with an associated user, device,
@@ -22,6 +36,8 @@ type Request struct {
 	// User contains the associated user's JWT created by the authenticate
 	// service
 	User string `json:"user,omitempty"`
+	// Session contains the verified session of the user's JWT, if any.
+	Session Session `json:"session"`
 
 	// Request context
 	//
@@ -32,6 +48,16 @@ type Request struct {
 	// Header contains the request header fields either received
 	// by the server or to be sent by the client.
 	Header map[string][]string `json:"headers,omitempty"`
+	// RawHeaders contains the unmodified values of the request headers
+	// configured by `authorize_request_headers`, keyed by the configured name.
+	RawHeaders map[string]string `json:"raw_headers,omitempty"`
+	// Query contains the decoded query parameters of the URL. Repeated
+	// parameters have a value for each, and parameters without a value an
+	// empty value.
+	Query map[string][]string `json:"query,omitempty"`
+	// ContentType is the lowercase media type of the request's Content-Type
+	// header, without parameters, e.g. application/json.
+	ContentType string `json:"content_type,omitempty"`
 	// Host specifies the host on which the URL is sought.
 	Host string `json:"host,omitempty"`
 	// RequestURI is the unmodified request-target of the
@@ -39,13 +65,75 @@ type Request struct {
 	// to a server. Usually the URL field should be used instead.
 	// It is an error to set this field in an HTTP client request.
 	RequestURI string `json:"request_uri,omitempty"`
+	// Time is the local time of the request, in the policy timezone.
+	Time Time `json:"time"`
 
 	// Connection context
 	//
+	// Protocol is the HTTP protocol version of the client's request, e.g.
+	// HTTP/1.1 or HTTP/2.
+	Protocol string `json:"protocol,omitempty"`
+	// ClientIP and ClientScheme are the IP address and scheme of the original
+	// client, taking trusted proxies into account.
+	ClientIP     string `json:"client_ip,omitempty"`
+	ClientScheme string `json:"client_scheme,omitempty"`
+	// ClientCountry and ClientRegion are the ISO 3166 codes of the client's
+	// country and region, per the GeoIP database, if they're known.
+	ClientCountry string `json:"client_country,omitempty"`
+	ClientRegion  string `json:"client_region,omitempty"`
+	// SNI is the server name the client requested in its TLS handshake, if
+	// any.
+	SNI string `json:"sni,omitempty"`
 	// ClientCertificate is the PEM-encoded public certificate used for the user's TLS connection.
 	ClientCertificate string `json:"client_certificate"`
+	// ClientCertificateChain is the parsed certificate chain, leaf first, of
+	// the original client as forwarded in the x-forwarded-client-cert header.
+	ClientCertificateChain []Certificate `json:"client_certificate_chain,omitempty"`
+	// MeshIdentity is the SPIFFE ID of the client certificate, if it's a
+	// trusted mesh identity.
+	MeshIdentity string `json:"mesh_identity,omitempty"`
 
 	// Device context
 	//
 	// todo(bdd):  Use the peer TLS certificate to bind device state with a request
 }
+
+// A Session represents the parts of a verified session that policies can
+// match on.
+type Session struct {
+	// HasRefreshToken is whether the session can be refreshed silently, as
+	// its access token has a refresh token.
+	HasRefreshToken bool `json:"has_refresh_token"`
+}
+
+// A Time represents the local time of a request that policies can match on.
+type Time struct {
+	// Weekday is the lowercase English name of the day, e.g. monday.
+	Weekday string `json:"weekday"`
+	// TimeOfDay is the zero padded 24-hour time, e.g. 09:30.
+	TimeOfDay string `json:"time_of_day"`
+}
+
+// NewTime returns the Time of t, in its location.
+func NewTime(t time.Time) Time {
+	return Time{
+		Weekday:   strings.ToLower(t.Weekday().String()),
+		TimeOfDay: t.Format("15:04"),
+	}
+}
+
+// A Certificate represents the fields of an x509 certificate that policies
+// can match on.
+type Certificate struct {
+	Subject        string   `json:"subject"`
+	Issuer         string   `json:"issuer"`
+	DNSNames       []string `json:"dns_names,omitempty"`
+	EmailAddresses []string `json:"email_addresses,omitempty"`
+	URIs           []string `json:"uris,omitempty"`
+	// NotBefore and NotAfter are the validity bounds in seconds since the
+	// unix epoch.
+	NotBefore int64 `json:"not_before"`
+	NotAfter  int64 `json:"not_after"`
+	// Fingerprint is the hex encoded SHA-256 hash of the DER certificate.
+	Fingerprint string `json:"fingerprint"`
+}
@@ -7,6 +7,7 @@ package mock_evaluator
 import (
 	context "context"
 	gomock "github.com/golang/mock/gomock"
+	evaluator "github.com/pomerium/pomerium/authorize/evaluator"
 	authorize "github.com/pomerium/pomerium/internal/grpc/authorize"
 	reflect "reflect"
 )
@@ -34,6 +35,21 @@ func (m *MockEvaluator) EXPECT() *MockEvaluatorMockRecorder {
 	return m.recorder
 }
 
+// Explain mocks base method
+func (m *MockEvaluator) Explain(arg0 context.Context, arg1 *evaluator.Request) (*evaluator.Explanation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Explain", arg0, arg1)
+	ret0, _ := ret[0].(*evaluator.Explanation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Explain indicates an expected call of Explain
+func (mr *MockEvaluatorMockRecorder) Explain(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Explain", reflect.TypeOf((*MockEvaluator)(nil).Explain), arg0, arg1)
+}
+
 // IsAuthorized mocks base method
 func (m *MockEvaluator) IsAuthorized(arg0 context.Context, arg1 interface{}) (*authorize.IsAuthorizedReply, error) {
 	m.ctrl.T.Helper()
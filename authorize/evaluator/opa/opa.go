@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"sort"
 	"strconv"
 	"sync"
 
@@ -40,6 +41,8 @@ type PolicyEvaluator struct {
 	mu           sync.RWMutex
 	store        storage.Store
 	isAuthorized rego.PreparedEvalQuery
+	explain      rego.PreparedEvalQuery
+	explainErr   error
 	clientCA     string
 }
 
@@ -96,6 +99,23 @@ func (pe *PolicyEvaluator) UpdatePolicy(ctx context.Context, authz string) error
 	if err != nil {
 		return fmt.Errorf("opa: prepare policy: %w", err)
 	}
+
+	// the debug policy relies on the rules of the default authorization
+	// policy, so a custom policy may not support it
+	debug, err := readPolicy("/debug.rego")
+	if err != nil {
+		return err
+	}
+	r = rego.New(
+		rego.Store(pe.store),
+		rego.Module("pomerium.authz", authz),
+		rego.Module("pomerium.debug", string(debug)),
+		rego.Query("result = data.pomerium.debug"),
+	)
+	pe.explain, pe.explainErr = r.PrepareForEval(ctx)
+	if pe.explainErr != nil {
+		pe.explainErr = fmt.Errorf("opa: prepare debug policy: %w", pe.explainErr)
+	}
 	return nil
 }
 
@@ -106,6 +126,25 @@ func (pe *PolicyEvaluator) IsAuthorized(ctx context.Context, req *evaluator.Requ
 	return pe.runBoolQuery(ctx, req, pe.isAuthorized)
 }
 
+// Explain returns the route policy matching a request and the outcomes of its
+// conditions, for debugging policies.
+func (pe *PolicyEvaluator) Explain(ctx context.Context, req *evaluator.Request) (*evaluator.Explanation, error) {
+	ctx, span := trace.StartSpan(ctx, "authorize.evaluator.opa.Explain")
+	defer span.End()
+
+	pe.mu.RLock()
+	err := pe.explainErr
+	pe.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := pe.eval(ctx, req, pe.explain)
+	if err != nil {
+		return nil, err
+	}
+	return explanationFromInterface(bindings)
+}
+
 // PutData adds (or replaces if the mapping key is the same) contextual data
 // for making policy decisions.
 func (pe *PolicyEvaluator) PutData(ctx context.Context, data map[string]interface{}) error {
@@ -158,6 +197,31 @@ func decisionFromInterface(i interface{}) (*pb.IsAuthorizedReply, error) {
 		d.DenyReasons = []string{v}
 	}
 
+	if v, ok := m["deny_rule_ids"].([]interface{}); ok {
+		for _, id := range v {
+			if id, ok := id.(string); ok {
+				d.DenyRuleIds = append(d.DenyRuleIds, id)
+			}
+		}
+	}
+
+	if v, ok := m["warnings"].([]interface{}); ok {
+		for _, w := range v {
+			if w, ok := w.(string); ok {
+				d.Warnings = append(d.Warnings, w)
+			}
+		}
+	}
+
+	if v, ok := m["matched_conditions"].([]interface{}); ok {
+		for _, c := range v {
+			if c, ok := c.(string); ok {
+				d.MatchedConditions = append(d.MatchedConditions, c)
+			}
+		}
+		sort.Strings(d.MatchedConditions)
+	}
+
 	if v, ok := m["user"].(string); ok {
 		d.User = v
 	}
@@ -205,7 +269,37 @@ func decisionFromInterface(i interface{}) (*pb.IsAuthorizedReply, error) {
 	return &d, nil
 }
 
+func explanationFromInterface(i interface{}) (*evaluator.Explanation, error) {
+	m, ok := i.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("interface must be a map")
+	}
+	e := evaluator.Explanation{
+		Route:      anyToInt(m["route"]),
+		Conditions: make(map[string]bool),
+	}
+	conditions, ok := m["conditions"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New("conditions should be a map")
+	}
+	for k, v := range conditions {
+		if e.Conditions[k], ok = v.(bool); !ok {
+			return nil, fmt.Errorf("condition %s should be bool", k)
+		}
+	}
+	return &e, nil
+}
+
 func (pe *PolicyEvaluator) runBoolQuery(ctx context.Context, req *evaluator.Request, q rego.PreparedEvalQuery) (*pb.IsAuthorizedReply, error) {
+	bindings, err := pe.eval(ctx, req, q)
+	if err != nil {
+		return nil, err
+	}
+	return decisionFromInterface(bindings)
+}
+
+// eval evaluates the query for the request, returning the result binding.
+func (pe *PolicyEvaluator) eval(ctx context.Context, req *evaluator.Request, q rego.PreparedEvalQuery) (interface{}, error) {
 	pe.mu.RLock()
 	defer pe.mu.RUnlock()
 
@@ -228,8 +322,7 @@ func (pe *PolicyEvaluator) runBoolQuery(ctx context.Context, req *evaluator.Requ
 	} else if len(rs) == 0 {
 		return nil, fmt.Errorf("empty eval result set %v", rs)
 	}
-	bindings := rs[0].Bindings.WithoutWildcards()["result"]
-	return decisionFromInterface(bindings)
+	return rs[0].Bindings.WithoutWildcards()["result"], nil
 }
 
 func readPolicy(fn string) ([]byte, error) {
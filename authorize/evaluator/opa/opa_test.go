@@ -2,6 +2,7 @@ package opa
 
 import (
 	"context"
+	"net/url"
 	"testing"
 	"time"
 
@@ -108,6 +109,436 @@ func Test_Eval(t *testing.T) {
 	}
 }
 
+func Test_EvalRawHeaders(t *testing.T) {
+	t.Parallel()
+	pe, err := New(context.Background(), &Options{
+		Data: map[string]interface{}{},
+		AuthorizationPolicy: `
+package pomerium.authz
+default allow = false
+default expired = false
+allow {
+	input.raw_headers["X-Tenant-ID"] == "AcMe Corp"
+}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		rawHeaders map[string]string
+		want       bool
+	}{
+		{"allowlisted header", map[string]string{"X-Tenant-ID": "AcMe Corp"}, true},
+		{"value is not canonicalized", map[string]string{"X-Tenant-ID": "acme corp"}, false},
+		{"missing header", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pe.IsAuthorized(context.TODO(), &evaluator.Request{RawHeaders: tt.rawHeaders})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.want, got.GetAllow())
+		})
+	}
+}
+
+func Test_EvalDenyRuleIDs(t *testing.T) {
+	t.Parallel()
+	policies := []config.Policy{
+		{From: "https://other.example", To: "https://to.example", AllowedUsers: []string{"user@example.com"}},
+		{From: "https://from.example", To: "https://to.example", AllowedUsers: []string{"user@example.com"}},
+	}
+	for i := range policies {
+		if err := (&policies[i]).Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pe, err := New(context.Background(), &Options{Data: map[string]interface{}{
+		"route_policies": policies,
+		"admins":         []string{},
+		"shared_key":     "secret",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		route    string
+		email    string
+		audience string
+		expiry   time.Time
+		want     []string
+	}{
+		{"allowed", "from.example", "user@example.com", "from.example", time.Now().Add(time.Hour), nil},
+		{"denied by route policy", "from.example", "bob@example.com", "from.example", time.Now().Add(time.Hour), []string{"route_policies[1]"}},
+		{"no matching route", "unknown.example", "user@example.com", "unknown.example", time.Now().Add(time.Hour), []string{"no_matching_route"}},
+		{"expired", "from.example", "user@example.com", "from.example", time.Now().Add(-time.Hour), []string{"token_expired"}},
+		{"bad audience", "from.example", "user@example.com", "other.example", time.Now().Add(time.Hour), []string{"token_bad_audience"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT, err := jwt.Signed(sig).Claims(jwt.Claims{
+				Expiry:   jwt.NewNumericDate(tt.expiry),
+				Audience: jwt.Audience{tt.audience},
+			}).Claims(map[string]interface{}{"email": tt.email}).CompactSerialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := pe.IsAuthorized(context.TODO(), &evaluator.Request{
+				Host: tt.route,
+				URL:  "https://" + tt.route,
+				User: rawJWT,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.want, got.GetDenyRuleIds())
+		})
+	}
+}
+
+func Test_EvalMatchedConditions(t *testing.T) {
+	t.Parallel()
+	policies := []config.Policy{
+		{From: "https://public.example", To: "https://to.example", AllowPublicUnauthenticatedAccess: true},
+		{From: "https://from.example", To: "https://to.example",
+			AllowedUsers:   []string{"user@example.com"},
+			AllowedGroups:  []string{"engineering", "sales"},
+			AllowedDomains: []string{"example.com", "example.org"}},
+	}
+	for i := range policies {
+		if err := (&policies[i]).Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pe, err := New(context.Background(), &Options{Data: map[string]interface{}{
+		"route_policies": policies,
+		"admins":         []string{},
+		"shared_key":     "secret",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		route  string
+		claims map[string]interface{}
+		want   []string
+	}{
+		{"public", "public.example", map[string]interface{}{"email": "user@example.com"}, []string{"public"}},
+		{"user, group and domain", "from.example", map[string]interface{}{"email": "user@example.com", "groups": []string{"engineering", "everyone"}},
+			[]string{"domain:example.com", "group:engineering", "user:user@example.com"}},
+		{"group only", "from.example", map[string]interface{}{"email": "bob@example.net", "groups": []string{"sales"}}, []string{"group:sales"}},
+		{"impersonated", "from.example", map[string]interface{}{"email": "admin@example.net", "impersonate_email": "alice@example.org"}, []string{"domain:example.org"}},
+		{"denied", "from.example", map[string]interface{}{"email": "bob@example.net"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT, err := jwt.Signed(sig).Claims(jwt.Claims{
+				Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				Audience: jwt.Audience{tt.route},
+			}).Claims(tt.claims).CompactSerialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := pe.IsAuthorized(context.TODO(), &evaluator.Request{
+				Host: tt.route,
+				URL:  "https://" + tt.route,
+				User: rawJWT,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.want, got.GetMatchedConditions())
+		})
+	}
+}
+
+func Test_EvalQueryParams(t *testing.T) {
+	t.Parallel()
+	policies := []config.Policy{
+		{From: "https://from.example", To: "https://to.example", AllowedDomains: []string{"example.com"},
+			RequiredQueryParams: map[string][]string{"tenant": {"acme", "globex"}, "debug": nil}},
+		{From: "https://public.example", To: "https://to.example", AllowPublicUnauthenticatedAccess: true,
+			RequiredQueryParams: map[string][]string{"tenant": {"acme"}}},
+	}
+	for i := range policies {
+		if err := (&policies[i]).Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pe, err := New(context.Background(), &Options{Data: map[string]interface{}{
+		"route_policies": policies,
+		"admins":         []string{},
+		"shared_key":     "secret",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		route     string
+		query     string
+		wantAllow bool
+	}{
+		{"match", "from.example", "tenant=acme&debug=1", true},
+		{"other value", "from.example", "tenant=globex&debug=1", true},
+		{"repeated", "from.example", "tenant=initech&tenant=acme&debug=1", true},
+		{"empty value present", "from.example", "tenant=acme&debug=", true},
+		{"no value present", "from.example", "tenant=acme&debug", true},
+		{"escaped", "from.example", "tenant=%61cme&debug=1", true},
+		{"wrong value", "from.example", "tenant=initech&debug=1", false},
+		{"empty value", "from.example", "tenant=&debug=1", false},
+		{"missing", "from.example", "debug=1", false},
+		{"missing without a value", "from.example", "tenant=acme", false},
+		{"no query", "from.example", "", false},
+		{"public match", "public.example", "tenant=acme", true},
+		{"public mismatch", "public.example", "tenant=globex", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT, err := jwt.Signed(sig).Claims(jwt.Claims{
+				Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				Audience: jwt.Audience{tt.route},
+			}).Claims(map[string]interface{}{"email": "user@example.com"}).CompactSerialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			query, _ := url.ParseQuery(tt.query)
+			got, err := pe.IsAuthorized(context.TODO(), &evaluator.Request{
+				Host:  tt.route,
+				URL:   "https://" + tt.route + "/?" + tt.query,
+				Query: query,
+				User:  rawJWT,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantAllow, got.GetAllow())
+			if !tt.wantAllow {
+				assert.Contains(t, got.GetDenyRuleIds(), "query_param_mismatch")
+			}
+		})
+	}
+}
+
+func Test_EvalAllowedCountries(t *testing.T) {
+	t.Parallel()
+	policies := []config.Policy{
+		{From: "https://from.example", To: "https://to.example", AllowedDomains: []string{"example.com"},
+			AllowedCountries: []string{"gb", "IE"}},
+		{From: "https://public.example", To: "https://to.example", AllowPublicUnauthenticatedAccess: true,
+			AllowedCountries: []string{"GB"}},
+	}
+	for i := range policies {
+		if err := (&policies[i]).Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pe, err := New(context.Background(), &Options{Data: map[string]interface{}{
+		"route_policies": policies,
+		"admins":         []string{},
+		"shared_key":     "secret",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		route     string
+		country   string
+		wantAllow bool
+	}{
+		{"allowed", "from.example", "GB", true},
+		{"other allowed", "from.example", "IE", true},
+		{"not allowed", "from.example", "US", false},
+		{"unknown", "from.example", "", true},
+		{"public allowed", "public.example", "GB", true},
+		{"public not allowed", "public.example", "US", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT, err := jwt.Signed(sig).Claims(jwt.Claims{
+				Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				Audience: jwt.Audience{tt.route},
+			}).Claims(map[string]interface{}{"email": "user@example.com"}).CompactSerialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := pe.IsAuthorized(context.TODO(), &evaluator.Request{
+				Host:          tt.route,
+				URL:           "https://" + tt.route + "/",
+				ClientCountry: tt.country,
+				User:          rawJWT,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantAllow, got.GetAllow())
+			if !tt.wantAllow {
+				assert.Contains(t, got.GetDenyRuleIds(), "country_not_allowed")
+			}
+		})
+	}
+}
+
+func Test_EvalAllowedTimes(t *testing.T) {
+	t.Parallel()
+	policies := []config.Policy{
+		{From: "https://from.example", To: "https://to.example", AllowedDomains: []string{"example.com"},
+			AllowedWeekdays: []string{"Monday", "Friday"}, AllowedHours: "9:00-17:00"},
+		{From: "https://overnight.example", To: "https://to.example", AllowedDomains: []string{"example.com"},
+			AllowedHours: "22:00-06:00"},
+	}
+	for i := range policies {
+		if err := (&policies[i]).Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pe, err := New(context.Background(), &Options{Data: map[string]interface{}{
+		"route_policies": policies,
+		"admins":         []string{},
+		"shared_key":     "secret",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		route      string
+		time       evaluator.Time
+		wantAllow  bool
+		wantRuleID string
+	}{
+		{"inside", "from.example", evaluator.Time{Weekday: "monday", TimeOfDay: "09:00"}, true, ""},
+		{"before", "from.example", evaluator.Time{Weekday: "monday", TimeOfDay: "08:59"}, false, "time_not_allowed"},
+		{"end", "from.example", evaluator.Time{Weekday: "friday", TimeOfDay: "17:00"}, false, "time_not_allowed"},
+		{"weekday not allowed", "from.example", evaluator.Time{Weekday: "tuesday", TimeOfDay: "12:00"}, false, "weekday_not_allowed"},
+		{"no time", "from.example", evaluator.Time{}, false, "weekday_not_allowed"},
+		{"overnight before midnight", "overnight.example", evaluator.Time{Weekday: "sunday", TimeOfDay: "23:00"}, true, ""},
+		{"overnight after midnight", "overnight.example", evaluator.Time{Weekday: "monday", TimeOfDay: "05:59"}, true, ""},
+		{"overnight outside", "overnight.example", evaluator.Time{Weekday: "monday", TimeOfDay: "12:00"}, false, "time_not_allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT, err := jwt.Signed(sig).Claims(jwt.Claims{
+				Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				Audience: jwt.Audience{tt.route},
+			}).Claims(map[string]interface{}{"email": "user@example.com"}).CompactSerialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := pe.IsAuthorized(context.TODO(), &evaluator.Request{
+				Host: tt.route,
+				URL:  "https://" + tt.route + "/",
+				Time: tt.time,
+				User: rawJWT,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantAllow, got.GetAllow())
+			if tt.wantRuleID != "" {
+				assert.Contains(t, got.GetDenyRuleIds(), tt.wantRuleID)
+			}
+		})
+	}
+}
+
+func Test_EvalRequireRefreshToken(t *testing.T) {
+	t.Parallel()
+	policies := []config.Policy{
+		{From: "https://from.example", To: "https://to.example", AllowedDomains: []string{"example.com"}, RequireRefreshToken: true},
+		{From: "https://other.example", To: "https://to.example", AllowedDomains: []string{"example.com"}},
+	}
+	for i := range policies {
+		if err := (&policies[i]).Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pe, err := New(context.Background(), &Options{Data: map[string]interface{}{
+		"route_policies": policies,
+		"admins":         []string{},
+		"shared_key":     "secret",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		route           string
+		hasRefreshToken bool
+		wantAllow       bool
+	}{
+		{"with refresh token", "from.example", true, true},
+		{"without refresh token", "from.example", false, false},
+		{"not required", "other.example", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT, err := jwt.Signed(sig).Claims(jwt.Claims{
+				Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				Audience: jwt.Audience{tt.route},
+			}).Claims(map[string]interface{}{"email": "user@example.com"}).CompactSerialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := pe.IsAuthorized(context.TODO(), &evaluator.Request{
+				Host:    tt.route,
+				URL:     "https://" + tt.route + "/",
+				User:    rawJWT,
+				Session: evaluator.Session{HasRefreshToken: tt.hasRefreshToken},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantAllow, got.GetAllow())
+			if !tt.wantAllow {
+				assert.Contains(t, got.GetDenyRuleIds(), "refresh_token_required")
+				assert.Contains(t, got.GetDenyReasons(), "session has no refresh token")
+			}
+		})
+	}
+}
+
 func Test_anyToInt(t *testing.T) {
 	assert.Equal(t, 5, anyToInt("5"))
 	assert.Equal(t, 7, anyToInt(7))
@@ -121,3 +552,132 @@ func Test_anyToInt(t *testing.T) {
 	assert.Equal(t, 11, anyToInt(uint64(11)))
 	assert.Equal(t, 13, anyToInt(13.0))
 }
+
+func Test_EvalNoPolicyMatch(t *testing.T) {
+	t.Parallel()
+	policies := []config.Policy{
+		{From: "https://unconfigured.example", To: "https://to.example"},
+	}
+	for i := range policies {
+		if err := (&policies[i]).Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawJWT, err := jwt.Signed(sig).Claims(jwt.Claims{
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Audience: jwt.Audience{"unconfigured.example"},
+	}).Claims(map[string]interface{}{"email": "user@example.com"}).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		decision    string
+		wantAllow   bool
+		wantReasons []string
+	}{
+		{"default", "", false, []string{"NO_POLICY_MATCH"}},
+		{"deny", config.NoPolicyMatchDeny, false, []string{"NO_POLICY_MATCH"}},
+		{"allow", config.NoPolicyMatchAllow, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pe, err := New(context.Background(), &Options{Data: map[string]interface{}{
+				"route_policies":  policies,
+				"admins":          []string{},
+				"shared_key":      "secret",
+				"no_policy_match": tt.decision,
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := pe.IsAuthorized(context.TODO(), &evaluator.Request{
+				Host: "unconfigured.example",
+				URL:  "https://unconfigured.example",
+				User: rawJWT,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantAllow, got.GetAllow())
+			assert.Equal(t, tt.wantReasons, got.GetDenyReasons())
+		})
+	}
+}
+
+func Test_Explain(t *testing.T) {
+	t.Parallel()
+	policies := []config.Policy{
+		{From: "https://public.example", To: "https://to.example", AllowPublicUnauthenticatedAccess: true},
+		{From: "https://from.example", To: "https://to.example", AllowedUsers: []string{"user@example.com"}, AllowedGroups: []string{"admin"}},
+	}
+	for i := range policies {
+		if err := (&policies[i]).Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("secret")},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawJWT, err := jwt.Signed(sig).Claims(jwt.Claims{
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Audience: jwt.Audience{"from.example"},
+	}).Claims(map[string]interface{}{"email": "user@example.com", "groups": []string{"everyone"}}).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pe, err := New(context.Background(), &Options{Data: map[string]interface{}{
+		"route_policies": policies,
+		"admins":         []string{},
+		"shared_key":     "secret",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		host      string
+		url       string
+		wantRoute int
+		wantTrue  []string
+	}{
+		{"matched", "from.example", "https://from.example/", 1, []string{"route_matched", "token_valid", "allowed_user"}},
+		{"public", "public.example", "https://public.example/", 0, []string{"route_matched", "public", "denied"}},
+		{"unmatched", "other.example", "https://other.example/", -1, []string{"denied"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pe.Explain(context.TODO(), &evaluator.Request{
+				Method: "GET",
+				Host:   tt.host,
+				URL:    tt.url,
+				User:   rawJWT,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantRoute, got.Route)
+			for k, v := range got.Conditions {
+				assert.Equal(t, containsString(tt.wantTrue, k), v, k)
+			}
+		})
+	}
+}
+
+func containsString(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
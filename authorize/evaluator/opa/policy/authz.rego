@@ -13,6 +13,12 @@ http_status = [495, "invalid client certificate"]{
 allow {
 	route := first_allowed_route(input.url)
 	route_policies[route].AllowPublicUnauthenticatedAccess == true
+	not deny_rules["user_not_admin"]
+	not deny_rules["query_param_mismatch"]
+	not deny_rules["country_not_allowed"]
+	not deny_rules["server_name_not_allowed"]
+	not deny_rules["weekday_not_allowed"]
+	not deny_rules["time_not_allowed"]
 }
 
 # allow cors preflight
@@ -33,6 +39,13 @@ allow {
 	count(deny)==0
 }
 
+# allow trusted mesh identities
+allow {
+	route := first_allowed_route(input.url)
+	element_in_list(route_policies[route].allowed_mesh_identities, input.mesh_identity)
+	count(deny)==0
+}
+
 # allow group
 allow {
 	route := first_allowed_route(input.url)
@@ -76,6 +89,14 @@ allow {
 	token.valid
 	count(deny)==0
 }
+# allow routes without any policy rules, if configured
+allow {
+	no_policy_match_allow
+	route := first_allowed_route(input.url)
+	no_policy_rules(route_policies[route])
+	count(deny)==0
+}
+
 # allow pomerium urls
 allow {
 	contains(input.url, "/.pomerium/")
@@ -156,15 +177,124 @@ expired {
 	payload.exp < now_seconds
 }
 
-deny["token is expired (exp)"]{
+# deny rules are keyed by a rule id so that denials can be traced back to
+# the rule that caused them
+deny[reason] {
+	deny_rules[_] = reason
+}
+
+deny_rules["token_expired"] = "token is expired (exp)" {
 	expired
 }
 
-deny[sprintf("token has bad audience (aud): %s not in %+v",[input.host,payload.aud])]{
+deny_rules["token_bad_audience"] = sprintf("token has bad audience (aud): %s not in %+v",[input.host,payload.aud]) {
 	[header, payload, _] := io.jwt.decode(input.user)
 	not element_in_list(payload.aud,input.host)
 }
 
+# deny routes without any policy rules, unless configured otherwise
+deny_rules["no_policy_match"] = "NO_POLICY_MATCH" {
+	not no_policy_match_allow
+	route := first_allowed_route(input.url)
+	no_policy_rules(route_policies[route])
+}
+
+# deny requests without the query parameters required by the route
+deny_rules["query_param_mismatch"] = "query parameters do not match the route" {
+	route := first_allowed_route(input.url)
+	some name
+	values := route_policies[route].required_query_params[name]
+	not query_param_matches(object.get(object.get(input, "query", {}), name, []), values)
+}
+
+# a parameter matches if any of its values is required, or, if the route
+# requires no particular value, if it's present
+query_param_matches(request_values, values) {
+	request_values[_] == values[_]
+}
+query_param_matches(request_values, values) {
+	count(values) == 0
+	count(request_values) > 0
+}
+
+# deny requests from countries the route doesn't allow. Clients whose country
+# is unknown, e.g. without a GeoIP database, aren't denied.
+deny_rules["country_not_allowed"] = sprintf("client country is not allowed: %s", [country]) {
+	route := first_allowed_route(input.url)
+	count(object.get(route_policies[route], "allowed_countries", [])) > 0
+	country := object.get(input, "client_country", "")
+	country != ""
+	not element_in_list(route_policies[route].allowed_countries, country)
+}
+
+# deny requests whose TLS server name (SNI) the route doesn't allow, including
+# requests without one.
+deny_rules["server_name_not_allowed"] = sprintf("tls server name is not allowed: %s", [sni]) {
+	route := first_allowed_route(input.url)
+	count(object.get(route_policies[route], "allowed_server_names", [])) > 0
+	sni := lower(object.get(input, "sni", ""))
+	not element_in_list(route_policies[route].allowed_server_names, sni)
+}
+
+# deny requests on days of the week, in the policy timezone, the route doesn't
+# allow
+deny_rules["weekday_not_allowed"] = sprintf("request weekday is not allowed: %s", [weekday]) {
+	route := first_allowed_route(input.url)
+	count(object.get(route_policies[route], "allowed_weekdays", [])) > 0
+	weekday := object.get(object.get(input, "time", {}), "weekday", "")
+	not element_in_list(route_policies[route].allowed_weekdays, weekday)
+}
+
+# deny requests outside of the route's allowed hours, in the policy timezone
+deny_rules["time_not_allowed"] = sprintf("request time is not allowed: %s", [time_of_day]) {
+	route := first_allowed_route(input.url)
+	hours := object.get(route_policies[route], "allowed_hours", "")
+	hours != ""
+	time_of_day := object.get(object.get(input, "time", {}), "time_of_day", "")
+	not time_in_range(time_of_day, hours)
+}
+
+# deny sessions which can't be refreshed silently, for routes requiring it
+deny_rules["refresh_token_required"] = "session has no refresh token" {
+	route := first_allowed_route(input.url)
+	object.get(route_policies[route], "require_refresh_token", false) == true
+	not object.get(object.get(input, "session", {}), "has_refresh_token", false)
+}
+
+# times are zero padded, hh:mm, so they compare as strings
+time_in_range(time_of_day, hours) {
+	[start, end] := split(hours, "-")
+	start < end
+	time_of_day >= start
+	time_of_day < end
+}
+
+# ranges spanning midnight, e.g. 22:00-06:00
+time_in_range(time_of_day, hours) {
+	[start, end] := split(hours, "-")
+	start > end
+	time_of_day >= start
+}
+
+time_in_range(time_of_day, hours) {
+	[start, end] := split(hours, "-")
+	start > end
+	time_of_day != ""
+	time_of_day < end
+}
+
+no_policy_match_allow {
+	data.no_policy_match == "allow"
+}
+
+no_policy_rules(policy) {
+	count(object.get(policy, "allowed_users", [])) == 0
+	count(object.get(policy, "allowed_groups", [])) == 0
+	count(object.get(policy, "allowed_domains", [])) == 0
+	count(object.get(policy, "allowed_mesh_identities", [])) == 0
+	not policy.AllowPublicUnauthenticatedAccess
+}
+
 # allow user is admin
 allow {
 	element_in_list(data.admins, token.payload.email)
@@ -174,10 +304,86 @@ allow {
 }
 
 
-# deny non-admin users from accesing admin routes
-deny["user is not admin"]{
-	not element_in_list(data.admins, token.payload.email)
+# deny non-admin users from accesing admin routes, including those without
+# a session
+deny_rules["user_not_admin"] = "user is not admin" {
 	contains(input.url,".pomerium/admin")
+	not is_admin
+}
+
+is_admin {
+	element_in_list(data.admins, token.payload.email)
+	token.valid
+}
+
+# the ids of the rules, or route policy, responsible for a denial
+deny_rule_ids[id] {
+	deny_rules[id]
+}
+
+deny_rule_ids[id] {
+	not allow
+	count(deny_rules) == 0
+	route := first_allowed_route(input.url)
+	id := sprintf("route_policies[%v]", [route])
+}
+
+deny_rule_ids["no_matching_route"] {
+	not allow
+	count(deny_rules) == 0
+	not first_allowed_route(input.url)
+}
+
+# advisory warnings are returned alongside an allowed decision without
+# affecting it
+warnings[msg] {
+	allow
+	route := first_allowed_route(input.url)
+	msg := route_policies[route].deprecation_warning
+	msg != ""
+}
+
+# the high-level conditions an allowed request satisfied, e.g.
+# "group:engineering", which upstreams may use for finer grained decisions
+matched_conditions["public"] {
+	allow
+	route := first_allowed_route(input.url)
+	route_policies[route].AllowPublicUnauthenticatedAccess == true
+}
+
+matched_conditions[condition] {
+	allow
+	token.valid
+	route := first_allowed_route(input.url)
+	email := [token.payload.email, object.get(token.payload, "impersonate_email", "")][_]
+	email == route_policies[route].allowed_users[_]
+	condition := concat(":", ["user", email])
+}
+
+matched_conditions[condition] {
+	allow
+	token.valid
+	route := first_allowed_route(input.url)
+	group := array.concat(object.get(token.payload, "groups", []), object.get(token.payload, "impersonate_groups", []))[_]
+	group == route_policies[route].allowed_groups[_]
+	condition := concat(":", ["group", group])
+}
+
+matched_conditions[condition] {
+	allow
+	token.valid
+	route := first_allowed_route(input.url)
+	email := [token.payload.email, object.get(token.payload, "impersonate_email", "")][_]
+	domain := route_policies[route].allowed_domains[_]
+	email_in_domain(email, domain)
+	condition := concat(":", ["domain", domain])
+}
+
+matched_conditions["admin"] {
+	allow
+	token.valid
+	element_in_list(data.admins, token.payload.email)
+	contains(input.url, ".pomerium/admin")
 }
 
 token = {"payload": payload, "valid": valid} {
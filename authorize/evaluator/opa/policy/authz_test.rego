@@ -91,6 +91,56 @@ test_public_denied {
 	}
 }
 
+test_mesh_identity_allowed {
+	allow with data.route_policies as [{
+		"source": "example.com",
+		"allowed_mesh_identities": ["spiffe://cluster.local/ns/default/sa/billing"]
+	}] with input as {
+		"url": "http://example.com",
+		"host": "example.com",
+		"mesh_identity": "spiffe://cluster.local/ns/default/sa/billing"
+	}
+}
+test_mesh_identity_denied {
+	not allow with data.route_policies as [{
+		"source": "example.com",
+		"allowed_mesh_identities": ["spiffe://cluster.local/ns/default/sa/billing"],
+		"required_query_params": {"tenant": []}
+	}] with input as {
+		"url": "http://example.com",
+		"host": "example.com",
+		"mesh_identity": "spiffe://cluster.local/ns/default/sa/billing"
+	}
+}
+
+test_no_policy_match_allowed {
+	allow with data.route_policies as [{
+		"source": "example.com"
+	}] with data.no_policy_match as "allow" with input as {
+		"url": "http://example.com",
+		"host": "example.com"
+	}
+}
+test_no_policy_match_denied {
+	not allow with data.route_policies as [{
+		"source": "example.com",
+		"required_query_params": {"tenant": []}
+	}] with data.no_policy_match as "allow" with input as {
+		"url": "http://example.com",
+		"host": "example.com"
+	}
+}
+
+test_public_admin_denied {
+	not allow with data.route_policies as [{
+		"source": "example.com",
+		"AllowPublicUnauthenticatedAccess": true
+	}] with data.admins as ["admin@example.com"] with input as {
+		"url": "http://example.com/.pomerium/admin/debug/policy",
+		"host": "example.com"
+	}
+}
+
 test_pomerium_allowed {
 	allow with data.route_policies as [{
 		"source": "example.com",
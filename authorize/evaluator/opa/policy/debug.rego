@@ -0,0 +1,137 @@
+package pomerium.debug
+
+import data.pomerium.authz
+import data.route_policies
+
+# the outcomes of the conditions of the route policy matching a request, for
+# policy authors debugging their policies
+
+# the index of the first matching route, or -1 if none match
+default route = -1
+
+route = authz.first_allowed_route(input.url)
+
+policy := route_policies[route]
+
+default route_matched = false
+
+route_matched {
+	route >= 0
+}
+
+default token_valid = false
+
+token_valid {
+	authz.token.valid
+}
+
+default public = false
+
+public {
+	policy.AllowPublicUnauthenticatedAccess == true
+}
+
+default cors_preflight = false
+
+cors_preflight {
+	policy.CORSAllowPreflight == true
+	input.method == "OPTIONS"
+	count(object.get(input.headers, "Access-Control-Request-Method", [])) > 0
+	count(object.get(input.headers, "Origin", [])) > 0
+}
+
+default allowed_user = false
+
+allowed_user {
+	authz.token.payload.email == policy.allowed_users[_]
+}
+
+allowed_user {
+	authz.token.payload.impersonate_email == policy.allowed_users[_]
+}
+
+default allowed_group = false
+
+allowed_group {
+	authz.token.payload.groups[_] == policy.allowed_groups[_]
+}
+
+allowed_group {
+	authz.token.payload.impersonate_groups[_] == policy.allowed_groups[_]
+}
+
+default allowed_domain = false
+
+allowed_domain {
+	authz.email_in_domain(authz.token.payload.email, policy.allowed_domains[_])
+}
+
+allowed_domain {
+	authz.email_in_domain(authz.token.payload.impersonate_email, policy.allowed_domains[_])
+}
+
+default no_policy_rules = false
+
+no_policy_rules {
+	authz.no_policy_rules(policy)
+}
+
+default query_param_mismatch = false
+
+query_param_mismatch {
+	authz.deny_rules["query_param_mismatch"]
+}
+
+default country_not_allowed = false
+
+country_not_allowed {
+	authz.deny_rules["country_not_allowed"]
+}
+
+default server_name_not_allowed = false
+
+server_name_not_allowed {
+	authz.deny_rules["server_name_not_allowed"]
+}
+
+default weekday_not_allowed = false
+
+weekday_not_allowed {
+	authz.deny_rules["weekday_not_allowed"]
+}
+
+default time_not_allowed = false
+
+time_not_allowed {
+	authz.deny_rules["time_not_allowed"]
+}
+
+default refresh_token_required = false
+
+refresh_token_required {
+	authz.deny_rules["refresh_token_required"]
+}
+
+default denied = false
+
+denied {
+	count(authz.deny) > 0
+}
+
+conditions = {
+	"route_matched": route_matched,
+	"token_valid": token_valid,
+	"public": public,
+	"cors_preflight": cors_preflight,
+	"allowed_user": allowed_user,
+	"allowed_group": allowed_group,
+	"allowed_domain": allowed_domain,
+	"no_policy_rules": no_policy_rules,
+	"query_param_mismatch": query_param_mismatch,
+	"country_not_allowed": country_not_allowed,
+	"server_name_not_allowed": server_name_not_allowed,
+	"weekday_not_allowed": weekday_not_allowed,
+	"time_not_allowed": time_not_allowed,
+	"refresh_token_required": refresh_token_required,
+	"denied": denied,
+}
@@ -9,6 +9,6 @@ import (
 const Rego = "rego" // static asset namespace
 
 func init() {
-	data := "PK\x03\x04\x14\x00\x08\x00\x08\x00\xd1\x9a\xb5P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\n\x00	\x00authz.regoUT\x05\x00\x01\xfa\xd4\xc6^\xbcXQs\xdb\xb6\x0f\x7f\x16?\x05\xca^\xef\xa4\x7f\x15\xa5\xed\xfd\xbb\xbbz\xf3\xb2^o\x0f{\xd8\xd2k\xb7'\x9d\xaa\xd2\x12m1\x91H\x8d\xa4\x1a\xbb\x99\xbf\xfb\x0e\xa4d+\x8e\xed\xa6i\xd6\x97(\x02\x81\x1f\x7f\x00(\x80p\xcb\x8aK\xb6\xe0\xd0\xaa\x86k\xd15	\xebl\xf5\x99\x10\xd1\xb4J[(\x99e\x89V\x9d\xe5y\xabjQ\x08nn,\x99\x8ai^\xe6\x97|EH\xc9\xe7\xac\xab-\xb0\xbaVW0\x859\xab\x0d'\xa4\xb2\xb6\xcd\x8de\xb630\x85\xf4\xff\xaf^\xc6@\x85\xfc\xc4jQBQ\x0b.-\x14\\[1\x17\x05\xb3\x9cf\xd7$\x90\xca\x82\x90mg\x13ar\xa7\x99{\xcd|\xa4I\xd6\x84<\xeewk\xbbY-\n\xe2_\xaeI\xe0(\xc3d\ns\xa1\x8d\xcd\x9d\x9c\x97\xb9\x13\x87\x1e\xb9\xd3u\xd4+n|K\xddk\x96\xbcF\xfd\xb7\x0e\xf3/\x89\x11\xe1\xd2\xba=\xcb\xd7E\xc1\x8d\x81\xe9\x14\xac\xeenP(\x946\xd0j>\xaf\xc5\xa2\xb2\x0fF\xe5\xcd\xf9\xbb\xf7\x9e\xce\x00\xbd\xd9<\xf0\xd6\x0d\xb7\x95*QJ\xcf\xdf\xfe\xf9\xdb\xf9\x1f\xef)	\n\xd5I\x1b\xaa\xd9\x05/l\xb2\xe0\xb6\xdf\xa9\xe2\xac\xe4\xda\xc4@\xbd#'o\x94\xb4Z\xd5'\xef\xf8\xdf\x1d7\xf6\xe4w\x07FcH\xb3(\x82\x9f\xe1\xd9\x1d\xa0\xce\xb5X\x089\xb6Y\x93m\\f+\xe0\x0d\x13\xf5=\"b\xd5%\x97I\xcbV\xb5be\xe2P`\n\xfb\xe34\xa0t\x86k\x93\xe6\xd9`\xedN\xcf\xe0D\xc9\xe5*\x9aN\x9f\x8d\xf3\xb6\xd0\xaak\xefA\xce\xa8\x86\xf7\xc6;D\x9d\xd0\xa4\xee\x91a^\x8e3\xee\xd5\xbf\x82\xf2l\x05\xa2i\xb96J2\xcb\x1f(\xbc#\xc4\xfc?\n\xf5\x0e\xef\x87\x8f\xfc\xd8\x87\xef\x91\x85R5L\xc8\xfb\xba\xd0[\x07.\xda\xb9\x90\xb9\x17\x84{\x8e}\xfc\x05\xf6\xde\xd2\xa4\xfe\x99E_\xe3\xc48%\xdf\xc3\xa1[\x07\xed\xa1\x9d\xdb4\x85\xbe\xa7A\xa7k\xb3\xf5\xa9P\xd2\"\xde\x96\x7f\x0c\xf44\x19\xb4Oi\xe4\x1b\xd0\x1e\xbd\xb1\x1a+\x1b!i\xe4\x83\xa9\xb9\xed\xb44`+\xeeC\x05\x0d\xb3E%\xe4\xc2\xfbF\x0e\xc6/\xc7\xf8\x0d\x9f\xda\x8d\x90\xfb0\xc0?\xe0b\xeb_~\x84\x03)8\x10\xc3(K\x9feH\xf1\xc0\xce18\x83Ut\xddw\x13\x14\xe6jv\x81\x04Z\xa6\x0dG\xc1\x88)	n \xe5Fu\xba\x18\x01\xa2\xed\x06tW\x19\xbb\xa3X\xdeU\x99\xd9\xea\x8e\xaa\x9a/\xf8A\xd8]\xe7\x8fS\xc6\x0c\x8cZ\x9d\x97\xc6@\xbd\x11\x8d\x81\xd2\xc8\xb5ZJ\xd6\x0f\x8e\xfb\xc8\xe1\x06^\xb6?\x13\xde0\xf1*\xd1N\xd2\x92J\x19w=\xb8\x89\xe0\xc4\xb7\xe3p4\x1b\x87\xf8z\xa3\xa3q\xf8v\xdc!\x0e\x96ik\xae\xc4\xee9H\xf0h\x0c\x88\x89\xb7\xdc\x93\xe7#\x07\xe8 \x0bf\xab\xe3\xbe}\x13f\xef\xd7@\x9c\xd9\n\xb7\xb9\xed\xdbm_\x8e\x9d\xf0C\x1b;\x9b\xa3\xde|+j\xef\x8f\xe6\xb9\xabv\xc3\xe1t*\xf1\x1e\xbf\\\x92\xb6g\xd9X\x8d\x95\xef\x1a\xa8)*\xdep:\x01\xffO\x0c\x14\x8f,\x9d\x00>\x86\x18N\xc0El\x8d\xcc\xd2<\xde\xe8z\x1d\xcd\xaep9\xc3R\x8a\xfb's!K,\xb8\xb9\xb1Z\xc8En\xba\x99c\x99\xcb\x90\x04\xc1\xc7\xf0l\x12\xe2h\x92\x9a\xec,\x9a\x9c\x9eFga\xfa\xe14{\x1a\x85\xe9\x87\xb3\xc7\xd9\xff\xa2\x8f1	\x02cu\x0c\xcf#,\xa2\x81\xcf\x17H\xa5\x1bV\x8b\xcf\xfe\xf3r\x07\xa2\xdf\xdb\xb9\xb7g\xb9\xf7\x93\x9eR\xa4n\xac\xde\xa4\xe3\xb02j\xf5\xca\x8fze\xb2\xdbV\xfb\xe6\xe9\xdf\\\xc2\x96X,L[\x0b;,\xd2_\xb0\x9d\xf9\x16\xb9t\xe7\xe0\x05	\x96\xe9sw#\xea\xfb\xf5z;\xbb\xf1e+4/\xb7\xd3\xdb pC\xd9Unx\xa1di&S+\x1a\x9e\xa0D\x9a0:}\xce_\x91 \xf5\x13A\x0c}\xa3\x8f!\xcf\x90\x8fP\xc9\xc5\x95MJ^\xa8r\xd3\xb1\x0c\xd7\x11\x86\xb4\xbf\xe3,[\xf8	F\x1bxNr\x95R\xd7\xeaA\x98\x0d\xb5\x90/\xdb\xc8M\x89\xbdd\xa3kZ-\xa4\x9d\x87\xbdM\xc5\x0c\xccX	\xac+\x05\x97\x05\x87\x90ue4\x81'\x06\xfc|	O\x9e~\xa2q\xda\x8f3x\x8a\x06>\xac+\xb3\x08\xb7\xb8\x87O\x88\xcdk\xde\xe0\xb4*d^\x0bc\xc3\x11n\xbc\xdd.\x1a_\xc4\xd0\x1a\xddt\xd7\x8a\xedee\x17\xc9M\xddN\xc7\xc4\xb0\xe7\xa2\xf8\x85\xcb\xd1\xbe\xcb\x0f\xdd{\xa7!\x8f\x01\xad@*y\xe2\xc4\x8e\xa1\x81\xb9V\x0d0\x1c\x1e\xf1r\xe3W\\51}\xc2\x06G0\x0e\x1eo\x98\xe8\xef\xe1\xcb\x9d\xe9\xfa\x94O\xe1\x9a\xf6\x10\xae\\\xf49\xa3.\x18t\x02\xee\xe9\x0b\x88\xfb7\x86\x9d\xfc\xdeNn\xfe\x89k1_a\xcd\xd8\xa69F\x88 \xa0\x86\x17\x9ac\x9d\xda\xfe\x0e\x12\xbb\x05\xd6\xe1v\xa3\x93E\x82`M\x02G\x15\x01&\xd3\x9b\xfe\xa2\xcc\x7f\xdf\xbb+~\xb8\xf3\xd3\xc9\xee\x9a\x97\x12#\x16\x92\x97\xf9\xc5\x95\x9dL{\xee\\:\xee\xb8\x12^SV/\xe8\x04\xe8\xaf\xef_\xbc\xfc\x81\xaewb\x1d\xf7\xbf\xe4\x88\x85\xc42y\xc9W\x11!d7W\xf8'v\x19\xc4B\x03\x80\xefi\x8e\xd5\x16edM\xfe\x0d\x00\x00\xff\xffPK\x07\x08\x1f\xad<2-\x05\x00\x00I\x12\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00T\x99\xb5P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0f\x00	\x00authz_test.regoUT\x05\x00\x011\xd2\xc6^\xd4W[s\xda:\x17}\xb6\x7f\x85\xc7O\xcd7\xdc>\x87K\x9b\x99\xce)Mh\xca\xad\x10\x0c\x01\x9a\xc9x\x84\xad\xda\x02\xdb2\x92\x1c.\x1d\xfe\xfb\x19\xc9\\\x9c\xc4ILN\xe6\xe4\xf4\x89\xb1\xb5\xb5\xf7Z{\xad-\x8b\x00\x983`C%\xc0\x1e$(\xf4r d\xceZ\x96\xa7\x0bf8\x10X\x90(g\x9f\x95\xdf\xb2\xa4\xb2U\xa0\x9e)jc\xd8W3\xb2\xa4\x02\xd7\xe6\x8f\xdfu\xadTV\xe5\x8dL\x91\xed#\xdf6fp\xb5\xdb1c+\x1e\x82M&v\xcc\xf8Cg\xf6\xcd\x9b\xb7\x9b\x9f\x06\x05\xcb\xeb:\xed\xf3a\xe1z\xbc*_\x18\x044\x9a\x8bZ\x83\xb6\xad\xe5\xdc\xf2\xc3Y\xdfY\xcf\xf0\xe9\x851\"\x149\x8bq\xad\x10,\xc9@\x0f\xbcB\xa3O\x86\xdaUP_\x17I\xff\xffwV\xed\xee\xe7\xa2\\\x19v\x8bK2\x9f\xa2\xc5\xca\xaat\xed\xa0\xdb\xbf(-\xef\xae\xbe\xb6+\xfdz\x13\xe9\xc3\xc2H\xeb\x15\x82_s\xa3Sgt\xdd\xbd\xea\xb1I\xe5\x1a\x11\xa2O.\x1b\xa8\xf5C\xcf\xfeh\xb4\xdbd|\xdd\x1c\x0e\xd9`r\xad\xf7G\xb5i\xabrm~\x9b\xb7[\xa5.\xd2aet\xe1\xad\xce\x7fN\x03\xbb\x16\xfc\xaa\x95\xae>j\xeb:\x1c\xb55\xda\"\xeb\xf2\xf7\xa1V\xfdT_\\\xce*\xdeP/\x98\xa5J\xcf\xd0\x1a\x97\xabo\x1d\x8d\x9dW\x8b\xebZ}\xec\x0c\xefZ\xb5\xb2\xd6\xa1\x1a\xfbY\x1e\x13\xb2\xb0\xbe~\xf4OKS\xb7\x1b\xd8\x83Z9\xc0\xb5\xbb\xfa@+\xb8\xdd\x16\xc0&^\x8f\xc6\xedyu\x16f\x9b\x0d\xdf\xc5\x0d\xb7\xban\xda\xda\x08\x18\x05\xa4#\xdd\xd6\xab\xa1\xb7,\x16\xbf\x9e\xfa\x95\x8b\xab\xa9}:\xed:=[\x08\xe0\x00\x02\xad]\xff'\x80\xc2r1$n\xce\x82&\xb6\xe0\x87\x98>\xb9\xd9\x89,3H\x99\x01=\x80\\\x03\xb8.^@\x8bk\x16\xd2Hp\x84s\xd3\x05\xcbA\x9f\xef5\xf8\xde\x0f\x07Gdx\xa4\xa4\x82\xd0R\xcf\x94\x1b\x15.\x81\x17\xb80gbO\xbd\xcd\xf0\x15\x91\x96\xab=\xc5\xf0K|Y\x966\x19%\x86\xe4D\x96%Q]Y \xe6(\x16` Gp\xc8\xa0\x11`\x17\x99\x08R\x05P\xe5F\x94\xa38$&\xe4Y\xe3\x19E\xbd-\x01\x83\xa3\xa7\x02\xd3\xc3\xc2\xb7\xb2\xb4\xb9\x8d\x15\x89\xbb\x15\xd08\xa4x\xd0\xa1\xa3<\xe6\xf0$B\x90\x1f\x84\x8c/\x08t!\x11\x84\x1d\xc6\x82\xb3|\xfe\x11B\x07S\x96\x08\x9dCV\xcf\x14\xfe#K\x1by\xb3\x13&\x8a{\x17I$\x1f3%\x85*\xb2$\xf1\xa2qe\x9e\xa0/\xa9\x01`\x0e\x0f\xc8\x83\xed\x8b\x9dd\x16\xf6\x00\xf2\xe9c\xd4\xb2$m2\xaf*1yP\xe2\xe0\n\x1fc\x1f~\xd9\x1fu\xf1:\xefc\x8e-\xd6\xe3\xed!\xe6\xd6\x82>z\xb7\xb1Mi\x92\xe3Gw\x82'\x7f\xf4\xe8\x06\xe1\xc4Ef\xfcP}\x836Uy\x8a\xae\xc8<\xf0\xf9'\x1a\xfa\x0c\x99\x80A\xabj\x9a\x90\xf2\xce1\x12\xc2C\xab\xfe1\xc5\x88R\x9c\xd1\xc1n)\xb5O\x98\xde\x84\xb1%\xf0\x17ZF\x83\xbb\xca\x8a\x9e>5\xbe	\xceH>#\x1eWI\xdd?i\xb3?\x0bR\xb6\xf0\x1e\xecgZ\xb9\xed\xe5\xf6\xf0yc\x7f\xa4j\xd6\x91\xde\xc8\xe7v`\xf3/q\xbbO\xedh\xa3\xbc3;`y\xc8\x7f\x89b\xc4\xd1\xc4\x84\x1a\xdc\xb2.\xb2\x1d\xf6\xef\x8b(\xe2\xce;==2\xf4\x0e\xc8k\xc7\xff\x19a\xc5\x82\x07\x99\x83\xf9\xe7B\xedt\xfb\xf5\xce\x0f}\x1b/\xbe'\x1cb4y\x1d\x82l\xe4\x0b\xc4\x14{\x10G\x8f\xb7\xdb\xd1\x13\x03\x96=\xc7>#\xd8\xcd\xf6\xe0<\x84\x94e\xdb\xbb\xd47\xeae\xad\x1f\xcdr\xccL\x0f\x1a\xfdgX\xea\xbf\xd8\xcd\xedl\x02B\xa1\x11\x12W\xdc\x16\x88\xcb/\x0b\xfbw\x1f\x92\xb8\xf0\xd2y~\xa7\xfakN\xd5\x13\xb1)GM\x07zP\xf9\xfc9b\xafFo9_\xf1\xee\xfe\xd0\xf0%\xbe_,\x1d\xd2\xa9{L;	\"\xf5\"\xad\xf6\x93\xb4{\x9f\x84M\xcd(\xbf\x9f\xd0vsr\xfc\xfe\x84\x80\xd7\xa6\xa1\xaf\xc9\x93\x7f\xabD/\xe7\xc9\xa7K\x94\x02Q\x94i?\x8cOf\xc3\xc4~^\xaed7D\xb7\x82\xf4n\x88\xdd\"RR\x14\x87\xbe\xb0\xa5p\xe5\x83$\xd1'!\x1d\xc5\x04\x0c\xfb\xedO\xb0\xe3c\x91\x9e\xdb\xee\x8fMJf	\x9bR\x91Hl\xc9\xfe\x7f\xdbk\x1a\xf2hsr;\x08\xb4\xe1\x11Z\x8bp\x9e7\xf7\xbf\x94\x1dI \xb6O\xb2]\xdc\xe6JOn\x9f\xe0f\xb9Z\xdfF\xe4\xfe\x0e\x00\x00\xff\xffPK\x07\x08\x92\xac+\xdev\x04\x00\x00M\x13\x00\x00PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xd1\x9a\xb5P\x1f\xad<2-\x05\x00\x00I\x12\x00\x00\n\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\x00\x00\x00\x00authz.regoUT\x05\x00\x01\xfa\xd4\xc6^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00T\x99\xb5P\x92\xac+\xdev\x04\x00\x00M\x13\x00\x00\x0f\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81n\x05\x00\x00authz_test.regoUT\x05\x00\x011\xd2\xc6^PK\x05\x06\x00\x00\x00\x00\x02\x00\x02\x00\x87\x00\x00\x00*\n\x00\x00\x00\x00"
+	data := "PK\x03\x04\x14\x00\x08\x00\x08\x00\xd6SN]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\n\x00	\x00authz.regoUT\x05\x00\x01\xd5Y\xcfj\xbcZ\xe1s\xdb\xb6\x92\xff,\xfe\x15[f:\x11\xaf\x8c\xecf\xae\x9d\xa9\xaej\xae\x93\xb9\xb9\x97\x99\xb6\xc94}\x1f\xdehT\x16&W\"b\x12P\x01\xd0\xb6\xe2\xe7\xff\xfd\xcd.@\x89\x94)\xd9J\xe3~\xb2\x05,\x16\xbb\xbf],\x16\xbb\\\x8b\xfcR\xac\x10\xd6\xbaF#\x9bz\"\x1aW~\x8c\"Y\xaf\xb5qP\x08'&F7\x0e\xb3\xb5\xaed.\xd1\xf6\xa6l)\x0c\x16\xd9%n\xa2\xa8\xc0\xa5h*\x07\xa2\xaa\xf45\xcc`)*\x8bQT:\xb7\xce\xac\x13\xae\xb10\x83\xf9\x7f\x7f\xf7M\n\xb1TW\xa2\x92\x05\xe4\x95D\xe5 G\xe3\xe4R\xe6\xc2a\xbc\xb8\x8dFJ;\x90j\xdd\xb8\x89\xb4\x19Sf\x9e2\xebPFwQ\xf4,\xec\xb6n.*\x99G\xfe\xc7m4b\x91a:\x83\xa54\xd6e<\x8eE\xc6\xc3c\xcf\xb91U\x12\x8d\xfa\xba\xcd\x99`1\xf9\x91\xe8\xdf1\xcf\x7f*B\x04\x95\xe3=\x8b\x1f\xf3\x1c\xad\x85\xd9\x0c\x9ci\xd0KZ\xa0\xdad\xa6\xa9\xd0\xce\xe3\xc6\xa2\xc9\x94v\x99(j\xa9\xe2\xc5}\x8a?\x1b4\x9bl-\x8c\xa8\xb3Z\xdaZ\xb8\xbc\x1c\xa2\xcbu\xa3\x9c\xd9xf$\x0f\x16Cd\x16\xcd\x15m)j|\x88\xf4\x1a\xf1\xb2\x10\x0frt\xf2\x1e\xab\x0e\xd2\xb96\x16\xd6\x06\x97\x95\\\x95\xee\xb3!\xfe\xfa\xed\xaf\xef=\xea-\xeb\x1d\xc6~u\x8d\xae\xd4\x05\x8d\xc6o\xdf\xfd\xf6\xe6\xed/\xef\xe3h\xc4 \x8d\xf5\xc5\x07\xcc\xddd\x85.\xecT\xa2(\xd0\xd8\x14bo\xaf\x17\xaf\xb5rFW/~\xc5?\x1b\xb4\xee\xc5\xcf\xcc,Na\xbeH\x12\xf8\x01\xce\x1f\xc1\xea\xad\x91+\xa9\xbak\xee\xa2\x1d.\x17\x1b\xc0Z\xc8\xea\x13\x10q\xfa\x12\xd5d-6\x95\x16\xc5\x84\xb9\xc0\x0c\x86q\n\xe6\xcd\xc8\xcf\xec<[\xb4\xab\xf9\x90\xb4J\x905\x93\xd9\xec\xbck7g\x1a\xeb\xb0\x80\x1am	\xb2 \x87vt\x9aO\x17\x17+\xac\xe9(J\x95U\xd2\xba\xf1qAi\xbfl\xb7_\x1a\xceuwx\x93\x1c\x13|et\xb3\xfe\x041\xad\xae1,\xdeC\x98\x07\xed\x9c\xff,\xc8\xa1\x8ek\x10\xc8O\xc0\xfab\x03\xb2^\xa3\xb1Z	\x87\x9f\xc9/:\x1c\xb3'\xf2\x91=\xb9??\xf2]\x1d\xfe\x0e+\x14\xba\x16R}\xaa\na\xf5\x88\xd1&w\xf7\x03\xe3\xbeN<\x9b>\xe0C~\xa5\x9d\xfb\xbf\x8b\xe4\x14%:\xa0\xfd-\nu\x8d\xf4$\xca\xb5\x06b\xc8,\\KW\xea\xc6\x81P\x1b\xe03\xb8\x01\xbeLS\x90K\xc8\xb5Z\xcaUc\xb0\xd8YQi/\xca&\xe3\xcb\xd3\x9b\xf1\x04\xe3\xee\xd6\xf3>\xc3\x01\xechLj\xb3%hL\xd5	\xa1\xb9V\x8e\xcc\xbcs\xa5\x14\xe2\xb3IK}\x16'\xfe\xb6\x1d\xa0\xeb\x92\xf9\xd4!\xf1'\xd3\xa0k\x8c\xb2\xe0J\xf4^\x0b\xac\xb4T+\x8f_tP\xdb\x8c\\\xb9\x0d\x12\xbd\x03\xecU\x84\x7f\x03\xbb\xb9\xff\xf1?p\x00\xb0\x03\xd6O\x16\xf3sN\x0c\x06\x96\xd1\xcei\xb0er\x1b.p\x1a\xcc\xf4\xc5\x07\x12`-\x8cE\x1a\x18o\xa7\x92h\xd4\xe3\x94Y\xdd\x98\x1c\xc7\xbd\xb5[\xa6\xfb\xc4\x94\x90\xc8\x9b\xc7\x12\x0bW>\x92\xd4\xe0\n\x0f\xb2\xddW\xfe\xb8\xc8d\x81Nv\xe1\xd1I!\xf6\x8b\xe2\x14\xe28\xa1\xcb(\x8e\xa3\xbb\xcf\xce\xf7\x0b\xe6;\xf2\x8c\x86-\xe1\x05\x9ax\x92d\xcfh\x93R[\xce\xc8\xfa\x1cx\xf8>\x0eG\xadqH^\xbf\xe8(\x0e\x7f\x9do\x8b\x83\x13\xc6Y\n<}\xdbN\xc85Z\x8e\x13\xbf\xdd\x80\x9d\x8f8\xd0A)\x84+\x8f\xeb\xf6\x97x\x06\xbdZ\xc1\x85+i\x9b\xfb\xba\xdd\xd7\xe5\x98\x87\x1f\xda\x98\xd7\x1c\xd5\xe6\xafr\x0d\xfa\x18\xf4!>l=a\xb6)\xf4\xf8\xb2\xb6l\xa4]T\xb1\xceP\xe4\xbb\x85\xd8\xe6%\xd6\x18O\xc1\xff\x93BL.\x1bO\x81\xfe\xb4\x18N\x81\xfe\xc0\x1d\xe9;\xcf\xd2-\xad\xa71\xe2\x9a\xa6\x17\x14Ji\xff\xc9R\xaa\x82.\x9d\xcc:#\xd5*\xb3\xcd\x05K\x99\xa9q4\x1a\xfd1~5\x1d\xd3\xa3wn\x17\xaf\x92\xe9\xd9Y\xf2j<\xff\xfdl\xf1U2\x9e\xff\xfe\xea\xd9\xe2\xbf\x92?\xd2h4\xb2\xce\xa4\xf0uBAtD\xeca\x06J\x9bZT\xf2\xa3?\xa048\x0e{\xb3z\x03\xd3A\xcf\xf8,&\xd1\xad3\xdb\x00r\x98\x98\xa8\x02\xf1\x17\x818\xdaO\x08\xc2\xb5\xef\x7f\xb1\xc1n(l\xdbu%];\x19\xffo\xbc\xbd#o8r\xbd\x8cF7\xf3\xaf9\x97\x0by\xca\xdd\xae*\x807ki\xb0\xd8\xd5\x05\xda\x01~\xee_g\x16s\xad\n;\x9d9Y\xe3\x84F\x94\x1d'g_\xe3w\xd1h\xee\xdfs)\x84<2\x85lA\xf2H=\xf9p\xed&\x05\xe6\xba\x08\xe1qB\x0f\xa3$\x1am\xb3\xb3\x9b5|\x0f\x9d\x0dH\xa6g\xfcd\xf7Y\x06\x08\x83p\x89\x1b,(a\x14<\x08\xb2\x00\xab\xc1\x95\x82\x1f\xd0RT\x16r\xa1\xe0\x02\xc1\x19\x91\x13\xa9\xc8/\xc1\xe9\xe8\x19\xdf\xcb\xbc\x86\xa9s\xd1X,h\xb0\x8eh\x8f\xb9Aa\xb5Z\x90\x96\x9d\xa7x\xe6\x9d\x89\xa6H\x9e\xee#\x9ds\xb1,\x80\x13\x13\x9d\x1f\x02i\xb7\x18\x8e\xf1f\x9d\xb0\xc9\xc3\xc80\x93\x0bQd\xa2)$\xaa\x1c\x99\x93]\x1b\xa9\xdcr\x1c8\x96\xc2\xc2\x85(\xa0\xa5\x81\xb1h\x8ad\n_Z\xa0\xb2\x86T\xf0\xe5WWq:\x0foj:\x0cm\"/\x9ab\xc1~\xf1	\xa6!\xde\xfbo\xc8\x0e\xdft\xb7]H\x81\x08\x9e\x87\x93\xc5FUT\x99\xd9%\x8c\xa0]\x89\xe6ZZ\xec\x01\xbc\x97=20\xf1/o\xb3wo\x7fz\xf3\xfa_\xd9\xcf?\xfe\xf6\xfa\x1f\x8c-\xc9\xb9G\xfcT\xa9fGM_\xa0\xd8e\xc5\xe4_\\2\xa2\xa4I\xd4\xe8\xd0X\xa6\"\xbb\x93\xcb\x12\x01\x83\x13=\\e\"o\xba\xc7\xac\xd0ln&\xd9q\x8b\xe1\xf6\x04=9\x91\xa4\x1aT4\xba\x12U\x83\x96\x8e\xe7\xa0\xb2\x93V\xf6\xacS\x08\xb3sZ\x1bjQ\xbd\x02\x19	\x85\xb6[\xe1\xe9\xfc\xcb\x9e\x92\x06\x95\xe2\x14n\xef\x92\x94\xa5\xe0\x1aM\n^\x94\x00\xaf\xd8\xe9\x0c\x81-=0\xe8\xd5\xa1\x97 \x9d\x0d\xe4t\xd0Z\x19S\xd0\x86\x9f![X8#g\xf0\xe9\x90\x10K'\xf3\xa6\x12\xc6\xaffb\xe9\x9es\x89\xcc\xa2r\xd1\x906\xc1\xc8\x19/\xb1[9\x19\xf1\xde\x14\x07\x8aY\x98\xa7\n\xc4\xdd\xa9\xfc||n7\x98\xcdv\x95\xae\xfeN\xdb\x82\xd6\xbe\x1f.\x8d\xae\x81\xb9\x18\x89v\x07\x04\x14\x1a\xadz\x1e\xca\xbd\x13x\xcd\xf5Y\x0b\xd7\xa5\xb6\x18\x16l\xa2g\x84f\xa3.\x95\xbeV)\xe0d5\xd9:\xb6\x80\xffG\xfd\xe6\x1d\x97\x98/\x84\xc5\x94\"11,PI,&=w\x0e\xfc\xfae\xc9nDk\x0b\xc9\x9e\x8ev%W\n\xa4\x14\xd2\xa8n\x17f\x17\xc9I\xbe}\xaf08\xe8\xd7)\xc4a\xb7l\x8b\xd6\xfd\xfa\xa2\xd9\xd0\x8e\x03.\xdc\x96\xb7y)\xf9r\xbc\xbd]\xcd\xa6M\x87\x86\"\xe7\xf0!\xbb'J\xda\x9a$\x192\xb27\xdao?\xbd\x07_N\xe6C\x04\xe3\xf7\xbf\xbcI\x0eY\x9c\xb2\xb0\xbcj\n\xa9V\xe1LxN\xc1\xbaZ\xe1$zT\x9d\xbaw/U\xb6'\xc1\xb0\x19\xad\x92Om\xc2NU\xbdoE\xab$Lg@Tf(\x14Y%\xbd\xf1\x0e\\t\xc7\xcd\xd5\xdd6\x05\xab\xe4\xa0\xb5\xb4\x82Bl,\x85-2\x0e\x95\xf5\xc9\x1a\xfc#\xdc\x89N\xd6\xf8Q+L\xef\x9b\xaf-bD\x0f\xb6\x06\xba\x96	\xae\x02\xa1\x87p\xc02a\xf6\xa9\xad\x13\xb6\xe9[&\x0c\xee\x9d\xaf\x01\x13\x116\xede\xd1*\xde\x1e\xb8\xd3OX``\xd3\x16\x9aa\x935\xce\xca\x02[\x9314\xcfm\x1b\x9f\xa0\xd4\x8d\xb1\x87l\x18\x1do\xce\x0cY\x89\xa8\x0e\x98\x88\xa62\xbd\xccN7\x13\x0b\xb9\x07\xefC\xa1\x90\xd7\xb4\xe0\xf2\x8f6\x96u\xe48\xd1d\x9d\x95]\xb3\xf1\xb0T\x99\x11j\x85\xe3\x0eQ\xea\xe1\xed\xda\xc5\xa2\xb5R+\n|2/)\xb7\x7f\xee(\xbb7\xb84hK,\xc0\xca\n\x95\xab6),\xb5i\x93O\x82W\xd2\x93\x0f\xa4\xebY%\xac\xcb8\xaf\xce\xda\xec\x81o\xa88\xec\xc5\xd9\xb6\xd2\xed\x16\xc0\xa4\xa7%Y\x8f8!a\xeb,\xec\xe2\x05\x8aS\xdf\x8bMv-5\xf2\xf3\x0e\xbb\x01\xc8\x83\xd8[\xd4Ka\x0fp\xf5\xb8\x12\xe0\xfe1\xf5\x11\x0d%FEA\xf9SYN\xeb:\xf5\xaf)\xdc@\xae\xeb5=\xb8\x84\xa5w\xa8T+\x1b=h7\xc2h\xce\x15\x9b\x14P\x15\x8b\xddK4\x1c\x9b\xf8\x05\xdd\x95L\x01\xdf\x13I\xdf\xbb~\xa07\xaf0\xae?\xea	Yt\xf6\x18\x0bv-\x94\"\xe3\xd6\xb2P\xd4\xdc\x0c	\xcb\xcb\x97\xd3\xf3\xf3\x17\xe7\xdfN\xcf\xcf?\xb3\xb0?\x1c\x11\xf6.z\xfa\xcd\x06\x8e\xe2\x16\x96\xc1\x97\x0f\x99\x82\xf2\xb5\xc9\xde,9\x96\x8f\xceq\x7f-\x9f\x8fP\xbd\xe9\xe4\xa3C\xd5\xac\xd6\xef\xe9\x01\xbf\x8d\xec\xdd\x84\xf5\xd8\"\xdfL:uU\xe8\xca\x9c\xbal\xaf\xa5\xd9_N'+T\xab\x1e\xfa\x8a \xbcK\x88\nHk\x8a\xd8\\\xfb\xdfu\x14\xf6\xdf\xc9\x0c>\xd3\xd8\x14\x06\xfaP\x0f4\x96\x86:\x14\xf1`\xe3\xa1}\x07(\xad^\xf0~,ax\x0e\x08\x12\x9fN\x8a\x9f\xf1\xd1\xb1\x93\x10\x82\xe3G@\xc8\x05I\xc76\xe2F\xc7\xbe\x95\xa0\xd7i\x8b\x03\xc1\xe8\x87\xe1\xf6\xb1b\x8fh\x91\xb4\xfe\xd3\x0b\x02\xb7\xfd\xff3 \xc9\xa6\xa2\\J\x16\xdb\xc4+t\xa9\xda\xfb!\x98=\x05\x83v\xad\x95\x95\x17\x15\xf2\xed!B\x11i\xa7|&\x0b;\x97\xc5~YH\x16\x8b^%\xa7K\xb6}\xcet\x8d\xea\xd7\xb5\xae\xf7\xe8\x8bD\x160\xedf\x0d\xfdK\xfc\xcb\xab\x05\xb9t\xa76\xb1\xdd\x8a\x05\xa2\nJ\xdb\x83\xf2\xb7T\xfcX	I\x89\x07n9\x06Z\x14W\xd2j\xb3\x81ka($\xfb\x8b\xc5\xb7\xc2\xb0\x00Qi\xb5\xe2|J\xa8m\x0eU`.\xe9\xc6\xea\xba\xddr\x89\xb9\x0b\xf7u\xcbj^\xdb\x15\xcb{j-\xa7\xb6\xab\xc3U\x8d\x02\xd7\x06s\xe1\xa4VY\xd8\xc9\xaf\xd8\xd6X\xbd\xfb\x94rU\xbe\xa8\xf0\n+\xaaT\x15\x92\x16\xd8\xae\x1am\xa2m\x85\x93v)\xe9\x0e\xa5gs\xf4\x0cb\x0erST+\xa9\x10\xe9\xf2\x8c\xd3\x90\xc04k\xeb\x0c\x8a\xdaB-6tR\xd9\xef\x96R\xa1\x81\x95\x11Ru\xf0\xb1\x11\x1b\x0f\x8bl'\xc0<\xf6\x1fP\xc5\x9f\x04\xcc`2\xf2\xf8o\xa8\xee\xa2!\x89\xb6\xe8tE\xea5\xcd\x1f\xed\xee\\\xb0&\xd3\xcd\x07;\xf7\x9dP\xdf\x9b\xa7\xcf\xd4\xf6[\xe1>\xe1\\P)&\xb0}\xf0\xc3\x85\xddW\x18[\x95H\x96\\\xab\\\xb8q<\xa5\xb3\xc6a/N\xfd\x97\"\x8b\xe4\xe9!aW\"1\x841b3	\xc2\x1cA\xa2{\xc1>\x1a\xb2\xde\xad\xcc\x98\xf1\xc8I\x9f\xdc\x1c\x01\x8d\x99\xc5\xa9\xff\xd4\xe4\xef@\xed\x89\x1c\xc9\xa7!\x87c\xcb^\xba\xb2s\xbe\x83\x1d\x9b\xa3\xbe\xe6i\xe2\xb6\xbds\x10\xb88|\xc2x\x08\xb6O\xc8M\x06\xeeq\x18\xce?\x18@\x98\xc1m\x1cxp\x93\xae\x85\x93#@<\xa5B\xa7,|\xdb\x8e\xffMa\xaf\x1dq\xbfM\x94]\xa1\x91\xcb\x0du\xea\xc2\x81\xb0hRb1\x1a\xc5\x16s\x83\xd4\x1d\xdc}\xd7J\xbd\xbaQ,\x1a\xda\xae\xd3\x08\x89F\xa3\xbbh\xc4\xd0\x11\x83\xe9\xac\xaf0\x8d\xf9\xae\xda\xfe\x0c\x0fF\xec\xb2v\x7f\xce\x8fFV\xae\x14\x16\xd9\x87k7\x9d\x05\xd9QQ\x1f%\xa3\x99\xf1m,\xaaU<\x85\xf8\xff\xde\xbf\xfc\xe6\xdb\xf8n/\x11L\xb9\xa2:!RjN^\xe2&\x89\xa2h\xdfX\x94\x03\xa5\\\xea\xa0\xb4\x1c\x80~s\xb5\x19\xb0\xc2:\xba\x8b\xfe3\x00PK\x07\x08\x0fr\xcc\x89o\x0b\x00\x00\x19,\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\xafSN]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0f\x00	\x00authz_test.regoUT\x05\x00\x01\x8aY\xcfj\xd4X\xddr\xda\xbc\x16\xbd\xb6\x9f\xc2\xa3\xab\xe6\x9b\x04sH\x02-3\x99S\x9a\xd0\x14\x12\n\x89I \xcdd<\xc2Vl\x81m\x19I\x0e\x98\x8e\xdf\xfd\x8cd~\x0c!-ph\xfb\xf5\x8a\xd8\xd2^\xfbg\xad\xbd%'\x84\xd6\x00:H\x0b\x89\x8f(\x8e\xfc\x1c\x8c\xb8;Q\xd5\xfe\x88\x9b.\x826\xa2Z\xf9L\xfb\xae*\x80\xc7!(k\xa0\xdei\x83CU\x01\xd0s\xc4\xe3\x17\xa3pZ\x04j\xa22\xec\x048p\xcc\x01\x8ag\x16\x03\x1e\x8b-\xc4\xe2\xd2b \x1e\x9a\x83\xcf\xfe\xb0q\xf5\xe1.o\xfb-\xb7q\xde\xc9\xdf?\xc4\xc5\x0b\x93\xc2\xfa\xd5\xa8Zg\x0d{<\xb4\x83h\xd0v'\x03r|av)\xc3\xee\xe8\xa1\x9a\x0f\xc7\xf4\xce\x08\xfd|\xbdM;\x85\x9b\xb069\xa1\xed\xff\xbc\xd8\xd5\x97o\xa3b\xa9\xd3:\x19\xd3a\x1f\x8fb\xbb\xd4r\xc2V\xfb\xe2t\xfcr\xf3\xa9Qj\xd7\xae\xb0\xd1\xc9w\x0b\xb7\xf9\xf0yh6k\x9cMZ7\xb7\xbcW\xba\xc7\x94\x1a\xbd\xcb:\xbe\xfej\x1c}\xad7\x1a\xf4\xe1\xfe\xaa\xd3\xe1w\xbd{\xa3\xdd\xad\xf6\xafK\xf7\xd6\xe7a\xe3\xfa\xb4\x85\x0dT\xea^\xf8\xf1\xf9\xb7~\xe8T\xc3\xe7\xea\xe9\xcd\xfb\xc2\xa4\x86\xba\x8d\x02\xbb\xa6\x93\xe2\x97N\xa1\xf2\xa16\xba\x1c\x94\xfc\x8e\x91\xb7NK\xb7f\xa1~\x19\x7fn\x16\xf8y\xe5dR\xad=\xb8\x9d\x97\xebj\xb1\xd0d\x05\xfe\xad\xf8@\xe9\xc8\xfe\xf4>8>\xed{\xad\xd0\xb9\xab\x16CR}\xa9\xdd\x15\xf2^\xeb\x1a\x12\x8bL\xba\x0f\x8dae\x10\x1d]\xd5\x03\x8f\xd4\xbd\xca\xe4\xca)t\xa1\x99\xc7\x066\x1c\xa3\x12\xf9\xe3\x93\x93O\xc7A\xe9\xe2\xa6\xef\x1c\xf7[\xee\xad\x03\xd4De.\xa4\xc8\x9e\xd5\xbf\x07\x19*\x9eD\xd4\xcb\xd9\xc8\"6z\x97\xe1'78PU\x8e\x187\x91\x0f\xb1gB\xcf##d\x0b\xce\"\x96\x12\x8eI\xae?\xe29\x14\x08[S\xd8\xbe[(\xe2P\xecT\x00\x8clP\xd6\x1e\x01\x1aC?\xf4P\xce\">x:T\x15\x05HX\xc1v\x9f\xa0\x8f\xd9eUI\x0e\xb5L$\x07\xaa\xaaH\xef\xda\x08sW\xb3!\x879J\"\x8e\xcc\x90x\xd8\xc2\x88i\x90i\x8f\xd2\x1d#\x11\xb5\x90@\xcd\"J\x7f\xd3\x04L\x11=\x931\xad:~R\x95\xe4)\xe3$\x13\x83\xf0\x90}\xcclZTT\xecY<\xc9-8\x08#.\x16dt\x11\x95	\xbb\x9c\x87e]\x7f\x15\xa1K\x18_\x1b\xba\x08\x19\x945\xf1\xa3*\x89\x9a\xcc\x88I\x01\xfe\x08%J@\xb8\xb6\x01+\xaa\xa2\x88\xd4\xb3\xcc\xbc\x91\xbe\x02B\xc8]\x91\xbf\x0e\xa7/f\x94\xd9\xc4\x878`\xaf\x85\xa4*Jr\xb8\x93\x8b\xde\x8a\x8b\x85*\x02B\x02\xf4q>\xea\xb2~\xfe\x8c8\xf4\xde\x8e\xf2\x10l\x9a6\n\xf0\x1fk\xdb\x0dE\xb2}\xeb\xf6H\xef\xafn\xdd0\xeay\xd8\xca\x0e\xd5=L\xb8\x8a\x80hI\xe4\xbb@\x1c\xd1(\xe0\xd8\x82\x1c\xd9\x15\xcbBL\xf4\x0f\xa7\x11Z\x94\xea\xff\x9eNiJ\xd9\x8c\x16r\xdb}@\xac&\xa6\x80\x90\xa2g<\x16kz/>\x12B\x9e.\xbcn\xdf5\xcaX?#^{\xd9\xb8~J\xa2*\xdb\x95p)\xec\x1f\x942\xad\xa5\x8f\x98kb[\xd0\xc7\xe3=\x8bd\x8a\xb6\xe4\x03\xa3\xb4v,\xc4\xcf\xcf\xa8\xac\xeb\x96\x171\x8eh\xce#\x16\xf4\xf4\x80\xe96z\x86\x91\xc7u\x06\xf5\x1e\xf6<\x1c8\xe0i\x8f:\x92U\xc9F$\xef\x84[\x85\x93\x91\xe2\x12\xd0\xf6\x8a\xfc}\x15\x94p\x14\x0d#,\xe6\xd00B46CH\xa1/\xc0\xbe\x03\x8e\x02\x18\x88\x9e{|J\xf6\xd8\xb4{+v*\xd6\x80\xa4\xe5\x8bM\x1fr\xcb\xdd\x9f\\\x17)\xcb\xd9\xb1\xe2GX\xa7b\x06{\xbaje\x14\xb4\x9a\xd3\xde5\xb4-\xe9\xbf\xb3\x02K\xf3\x1c\xda>\x0e\xf6\x9f\xff\xd6G\x95,\x80\x0c&=6\x80\xfc{\xf9\x0e\xb0\xddD\xce\xcd\xaex\xba\x84\xd2m\xd4\x8b\x1c]\xb2\x18o6\xa6g\x00\xbfhB\xff\xf0L\xdb\xf2\x08\xcfd\xfb\xb3\xdc\x96S\xdb;\xf3\xbf8;\xc9\xe5f\xf4Y\x842S\xdc,<\xec\xb8\xfc\xf7\x93(\x83<o\xde\x1ai3\xcc\x02y%\xfdM)\xfe\xe9\xc4\xe7.\x11\x1f\xe3\xa0\xd9j\xd7\x9a_\x8d\xe9~\xf9i(\x98\x10\xf0\nhR\xec\xe0@\x1c:\x80\x11\x1f\x91\xf4Q\x06\xab\x80\xf4\x1eytN\x02N\x89wt\x8b\x86\x11b\xfc\xa81\x83~\x04\x97\xd5\xb6 PI2\xd3t\xa5\xd0\x7f\x87\xa4\xfe\x8d\xd5\x9c\xf6&\xa4\x0c\x99\x11\xf5\x84\x0f\xf1S>\xd3\xe6\xef\xde\xad\xcbE\xb8\xd6\xc5\xd7\xf5\x7f\x87\x0c\x1cH\xa3\x1c\xb3\\\xe4#\xed\xec,\x1d\x17 }+\xf2\x95\xef\xb2	\xa7K\xc2^.-\xe0\xc0\xbc\x97\xa6\xcdc\xa6\xec\xa5\\\xcd;i\xf6~]l\xe0P\xfb\xfe\x06\xb7\xc9\xc1\xf6\xf6k6\xec\n\xc3v\xc1\xd1\xf7\x05\xf4s\x1c}o\x11\xa5H\xf3\x93\xfd\xcd\xb0\x08uV\xc2\xca\x80\x08\x8c\xf5j\x10#\x16\x8f7WC\xe6co\xc3\x14\xe5\xd0\x97\xb2\x94\xaa\\\x01\x91\xab\x1b\xa6\xb8&\x86\xb9\xf9\x1b\xd9\x89\xb6\xd8<\xb7\xd9\xbf\xb8\xb6!o\xd9h\xa3$\xd6\x96d\x06\xb3SA^\x19\xaf/\x07E\x0e\xda\x82k\xb9]\xe0\xe6\xfe\xd9\x9d\xeb9\xc8t1\xf7\xcf\xe6\x85Z\x06x\x1c\xc7\x93'\x90\x1c\xa8\x89\xfa\xbf\x01\x00PK\x07\x08&\xd4\xdd\xa6>\x05\x00\x00\xf4\x18\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x009HN]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\n\x00	\x00debug.regoUT\x05\x00\x01\xfeD\xcfj\xa4VM\x8f\xdb6\x10=\x9b\xbf\x82P.\xbb\x80-$W\x01\x0e\x10\xe4\xd4C\xeb iO\xc6\x82\xe0\x8a#\x89]\x89T\xf8\x91\xd41\xfc\xdf\x0bR_\xa4L\xa1\x06z3g\xde\xbc7#\xcep\xdc\xd3\xf2\x8d\xd6\x80{\xd9\x81\xe2\xb6\xcb\x19\xbc\xda\x1a!\xde\xf5R\x19\xcc\xa8\xa1\xf9\xec\xa3\xd64\xbf\"\x97\x92\xd6\x00\xe9e\xcbK\x0e\x1a\xa1w\xd84\x80\xa55\xa5\xec@cY\xf9s)\x05\xe3\x86K1[|\x1c\xf6q\x17\xdcQS6\\\xd4\x98b\x05\xdf-h\xb3\xc7\x95T\xe8\xdd\x04p\xbaRi\xecs\xab\x1d\xd24\xc0\x15^\xebr\xc1\xe0\x9fI\xa2\xe2J\x9b\x85\xdb+\xee\xb1T\xf8\xf0\x01\xf3\n\x0b)`\xf0\"\x06\x15\xb5\xad\x19\x93:\xe2\xc3\x07\x84\xa6\xdfN\xfaW\xee\xb9\x08m[\xf9\x13\x18\xf1\xbe'.zkr\xab\xdag\x84\xc6<\x8b#\x8e?\xc8\xd9\x1f_P,A\xbc,0|\xc4\x15m5 \x14\x9b\xafh\xe7q\xf8\xe3\x11\xbfG\xb7%\xda\xc87\x10\xe4\x07my\x10\x1b\x1a\xafh7$\xec\x8d\xb97\x86\x04\xbd}my\xb9\xc4\x8e\xe7+\xda\xf9\x8c/\xf9'W\xe2\x17o\xfdK8*\x10\x86\x97\xd4\x00\xfbT\x96\xa05>\x1e\xb1Q\x16B\xd2R*Mz\x05U\xcb\xeb\xc6,\xe4+\xfb\"\xf2\xf9\xf4\xf5\xdb \xb4\x04\x8d\xb4\xbb\xe1\xabv`\x1a\xc9\x9cXv\xfa\xf2\xe7o\xa7?\xbeehWJ+\xcc\x93|\xfd\x1bJ\x93\xd7`\xc6\x1bh\x802Pz\x8f\xb3!\xc5\xc3g)\x8c\x92\xed\xe1\xeb\xd0K\x87\xdf=Y\xb6\xc7\xe7\x97\xe7g\xfc\x11\xbf\x7f\x80\xea\xa4x\xcdE\x18\x13\x14<\xf5\x81\xd5\xa0\x96r#\xeb\xea\"zzi%e9t\x94\xb7\xae\xac\xf1s\x871\xfaL^\x9c\xca#<\xbc\xebAi)\xa8\x01\xf2\x08\xe7\xd4@\x13w\xad\xa4\xed\xefS\x1f\xcc\x1b\xb9{\xa7K2\xa15\xfb\xd0\xedA\xb6\xb0\x82\x87\x99\xd7e0\xd9Q.\xee\xeb\x18\xeds!\xfe\x13\x11.F\xc7\xd3\xe6\xd5\xec\xd7\x1fq\x08p\xfa\xcf\xe8\xf6\xff\x04\xee\xee\xec\xbf\xc4\xa6j\x85\x1c\x1e\xd8\x0bQ\xb6\x05\xbd\x94\xbbv\xcc\xe9\xac\x1cO\xc3!\"\xfdnA]HO\x15\xedH\xc7\xb5\x7fz\x16\xe6\xa4w\xa6g F\xe6s\x96Bf\xd1e\xf9\xb1U\x17\"\xe4\xfc\x82.B)gR'\x01\x8ce4\xa8\x1f\xa0\x88\xa0\x1d\xa4\xa5\xb6\x00I\xb9\x0dp,\xf9\x13\xe0\x8d\xd1\x8d\xcaR\xce\xa4T\x02\x18\xcb\x18\xbeU\xd2\x9d')\xb0F\xc5\xec\n*\x05\xba!\xbeY\x89\xdb\xbf\\E\xab)\xedO*\xa5\xb1\xb1\x1e\x03\xc1C\xfe\xf1|\x9d\x1e\xe5\x85u~w\x83\xbf\x0fG\x07\xcc\xa2u\x99\x15\xf1V\xdd\xa3]\x16\xec\xc4\xac\x08\xd7\xa6s\x0eK/+\xc6m\xe8L\xf1\xaa\xca\x8a\xd5Ns\x90\xb1\x9f\xfc\xa3\x9f\x15\xd1\x0e\x08\xdd\xfe-\x0b\xfc\xfe\x1c\x02\x86\x17%@\x0c\x06\x07Y\x8dmV\xacG\xdf\x81\x92\x03W$\xe7\xd9\xc1SsS\xa4f\xd2\x81\xb7\xba\xbe\xd8\x9a.\x17\x94\xea\xdf\"5\x1b\x0e|\xd7\x8b\xc5]{;\xd8F#\x15\x1b\xdd\xeaB\x86>\xca\n\xcc@p`{tC\xff\x0e\x00PK\x07\x08\xa1\x1eV\x1a\x19\x03\x00\x00\xdc\n\x00\x00PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xd6SN]\x0fr\xcc\x89o\x0b\x00\x00\x19,\x00\x00\n\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xa4\x81\x00\x00\x00\x00authz.regoUT\x05\x00\x01\xd5Y\xcfjPK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xafSN]&\xd4\xdd\xa6>\x05\x00\x00\xf4\x18\x00\x00\x0f\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xa4\x81\xb0\x0b\x00\x00authz_test.regoUT\x05\x00\x01\x8aY\xcfjPK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x009HN]\xa1\x1eV\x1a\x19\x03\x00\x00\xdc\n\x00\x00\n\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xa4\x814\x11\x00\x00debug.regoUT\x05\x00\x01\xfeD\xcfjPK\x05\x06\x00\x00\x00\x00\x03\x00\x03\x00\xc8\x00\x00\x00\x8e\x14\x00\x00\x00\x00"
 	fs.RegisterWithNamespace("rego", data)
 }
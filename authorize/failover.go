@@ -0,0 +1,72 @@
+package authorize
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// authenticateUnhealthyCooldown is how long an authenticate service url is
+// skipped after it has been marked unhealthy.
+const authenticateUnhealthyCooldown = 30 * time.Second
+
+// authenticateHealth tracks the health of authenticate service urls so that
+// requests can fail over to the next url in order of preference.
+type authenticateHealth struct {
+	mu             sync.Mutex
+	unhealthyUntil map[string]time.Time
+}
+
+// Select returns the first url which has not recently been marked unhealthy.
+// If every url is unhealthy, the first url is returned.
+func (h *authenticateHealth) Select(urls []*url.URL) *url.URL {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for _, u := range urls {
+		if until, ok := h.unhealthyUntil[u.String()]; !ok || now.After(until) {
+			return u
+		}
+	}
+	return urls[0]
+}
+
+// AllUnhealthy returns true if every url has recently been marked unhealthy.
+func (h *authenticateHealth) AllUnhealthy(urls []*url.URL) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for _, u := range urls {
+		if until, ok := h.unhealthyUntil[u.String()]; !ok || now.After(until) {
+			return false
+		}
+	}
+	return len(urls) > 0
+}
+
+// MarkUnhealthy marks a url as unhealthy for the cooldown period.
+func (h *authenticateHealth) MarkUnhealthy(u *url.URL) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.unhealthyUntil == nil {
+		h.unhealthyUntil = make(map[string]time.Time)
+	}
+	h.unhealthyUntil[u.String()] = time.Now().Add(authenticateUnhealthyCooldown)
+}
+
+// MarkHealthy clears any unhealthy mark on a url.
+func (h *authenticateHealth) MarkHealthy(u *url.URL) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.unhealthyUntil, u.String())
+}
+
+// getAuthenticateURL returns the currently selected healthy authenticate url.
+func (a *Authorize) getAuthenticateURL() *url.URL {
+	opts := a.currentOptions.Load()
+	return a.authenticateHealth.Select(opts.GetAuthenticateURLs())
+}
@@ -0,0 +1,178 @@
+package authorize
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+)
+
+func TestAuthenticateHealth_Select(t *testing.T) {
+	primary := mustParseURL("https://authenticate-1.example.com")
+	secondary := mustParseURL("https://authenticate-2.example.com")
+	tertiary := mustParseURL("https://authenticate-3.example.com")
+	urls := []*url.URL{primary, secondary, tertiary}
+
+	var h authenticateHealth
+	assert.Equal(t, primary, h.Select(urls), "should select the primary by default")
+
+	h.MarkUnhealthy(primary)
+	assert.Equal(t, secondary, h.Select(urls), "should fail over to the next url")
+
+	h.MarkUnhealthy(secondary)
+	assert.Equal(t, tertiary, h.Select(urls), "should fail over in order")
+
+	h.MarkUnhealthy(tertiary)
+	assert.Equal(t, primary, h.Select(urls), "should fall back to the primary when nothing is healthy")
+
+	h.MarkHealthy(secondary)
+	assert.Equal(t, secondary, h.Select(urls), "should select the first healthy url")
+
+	h.unhealthyUntil[primary.String()] = time.Now().Add(-time.Second)
+	assert.Equal(t, primary, h.Select(urls), "should retry the primary after the cooldown")
+}
+
+func TestAuthenticateHealth_AllUnhealthy(t *testing.T) {
+	primary := mustParseURL("https://authenticate-1.example.com")
+	secondary := mustParseURL("https://authenticate-2.example.com")
+	urls := []*url.URL{primary, secondary}
+
+	var h authenticateHealth
+	assert.False(t, h.AllUnhealthy(urls))
+	h.MarkUnhealthy(primary)
+	assert.False(t, h.AllUnhealthy(urls), "should be false while any url is healthy")
+	h.MarkUnhealthy(secondary)
+	assert.True(t, h.AllUnhealthy(urls))
+	h.unhealthyUntil[secondary.String()] = time.Now().Add(-time.Second)
+	assert.False(t, h.AllUnhealthy(urls), "should be false after the cooldown")
+	assert.False(t, h.AllUnhealthy(nil))
+}
+
+func TestAuthorize_Check_authenticateOutage(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		status      int
+		gracePeriod time.Duration
+		expiry      time.Duration
+		wantAllowed bool
+	}{
+		{"down, within grace", http.StatusBadGateway, 10 * time.Minute, -time.Minute, true},
+		{"down, after grace", http.StatusBadGateway, 10 * time.Minute, -time.Hour, false},
+		{"down, disabled", http.StatusBadGateway, 0, -time.Minute, false},
+		{"up, refresh refused", http.StatusUnauthorized, 10 * time.Minute, -time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, http.StatusText(tt.status), tt.status)
+			}))
+			defer srv.Close()
+			a, err := New(config.Options{
+				Policies:                      policies,
+				CookieName:                    "_pomerium",
+				AuthenticateURL:               mustParseURL(srv.URL),
+				SharedKey:                     sharedKey,
+				AuthenticateOutageGracePeriod: tt.gracePeriod,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// POST, as it isn't one of the expired session grace methods
+			res, err := a.Check(context.TODO(), testCheckRequest(http.MethodPost, "https://app.example.com/", map[string]string{
+				"accept": "text/html",
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(tt.expiry)),
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			if !tt.wantAllowed {
+				assert.Nil(t, res.GetOkResponse())
+				assert.Equal(t, http.StatusFound, int(res.GetDeniedResponse().GetStatus().GetCode()))
+				return
+			}
+			var degraded string
+			for _, hvo := range res.GetOkResponse().GetHeaders() {
+				if hvo.GetHeader().GetKey() == "X-Pomerium-Degraded" {
+					degraded = hvo.GetHeader().GetValue()
+				}
+			}
+			assert.Equal(t, "authenticate-unavailable", degraded)
+		})
+	}
+}
+
+func TestAuthorize_Check_authenticateFailover(t *testing.T) {
+	a, err := New(config.Options{
+		CookieName:               "_pomerium",
+		AuthenticateURL:          mustParseURL("https://authenticate-1.example.com"),
+		AuthenticateFailoverURLs: []*url.URL{mustParseURL("https://authenticate-2.example.com")},
+		SharedKey:                cryptutil.NewBase64Key(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	redirectHost := func(t *testing.T) string {
+		t.Helper()
+		res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://test.example.com/", map[string]string{
+			"accept": "text/html",
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, hvo := range res.GetDeniedResponse().GetHeaders() {
+			if hvo.GetHeader().GetKey() == "Location" {
+				return mustParseURL(hvo.GetHeader().GetValue()).Host
+			}
+		}
+		t.Fatal("no redirect location")
+		return ""
+	}
+
+	assert.Equal(t, "authenticate-1.example.com", redirectHost(t))
+	a.authenticateHealth.MarkUnhealthy(a.currentOptions.Load().AuthenticateURL)
+	assert.Equal(t, "authenticate-2.example.com", redirectHost(t))
+}
+
+func TestAuthorize_refreshSession_marksUnhealthy(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("NEW SESSION"))
+	}))
+	defer healthy.Close()
+
+	a := new(Authorize)
+	a.currentOptions.Store(config.Options{
+		AuthenticateURL:          mustParseURL(unavailable.URL),
+		AuthenticateFailoverURLs: []*url.URL{mustParseURL(healthy.URL)},
+		SharedKey:                cryptutil.NewBase64Key(),
+	})
+
+	_, err := a.refreshSession(context.Background(), []byte("ABCD"))
+	assert.Error(t, err)
+	assert.Equal(t, healthy.URL, a.getAuthenticateURL().String())
+
+	newSession, err := a.refreshSession(context.Background(), []byte("ABCD"))
+	assert.NoError(t, err)
+	assert.Equal(t, "NEW SESSION", string(newSession))
+}
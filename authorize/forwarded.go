@@ -0,0 +1,171 @@
+package authorize
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+)
+
+// forwardedHop is a proxy hop from the Forwarded or X-Forwarded-* headers.
+type forwardedHop struct {
+	For   string
+	Proto string
+}
+
+// getClientAddr returns the IP and scheme of the original client. For
+// requests from a trusted proxy, they're taken from the Forwarded header if
+// set, otherwise from the X-Forwarded-For and X-Forwarded-Proto headers. The
+// two are never mixed. Hops are read from the right, skipping other trusted
+// proxies.
+func getClientAddr(in *envoy_service_auth_v2.CheckRequest, trustedProxies []*net.IPNet) (clientIP, scheme string) {
+	clientIP = in.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress()
+	scheme = in.GetAttributes().GetRequest().GetHttp().GetScheme()
+	if !isTrustedProxy(net.ParseIP(clientIP), trustedProxies) {
+		return clientIP, scheme
+	}
+
+	headers := in.GetAttributes().GetRequest().GetHttp().GetHeaders()
+	var hops []forwardedHop
+	if forwarded := headers["forwarded"]; forwarded != "" {
+		hops = parseForwarded(forwarded)
+	} else {
+		hops = parseXForwarded(headers["x-forwarded-for"], headers["x-forwarded-proto"])
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseForwardedNode(hops[i].For)
+		if ip == nil {
+			// obfuscated or unknown hops can't be trusted past
+			break
+		}
+		clientIP = ip.String()
+		if hops[i].Proto != "" {
+			scheme = strings.ToLower(hops[i].Proto)
+		}
+		if !isTrustedProxy(ip, trustedProxies) {
+			break
+		}
+	}
+	return clientIP, scheme
+}
+
+// isUnknownClientIP returns true if the IP of the original client can't be
+// determined, e.g. for requests over a pipe, so IP rules can't be applied.
+func (a *Authorize) isUnknownClientIP(in *envoy_service_auth_v2.CheckRequest) bool {
+	clientIP, _ := getClientAddr(in, a.currentOptions.LoadState().trustedProxies)
+	return net.ParseIP(clientIP) == nil
+}
+
+// getExternalURL returns the url of the request as the client made it, for
+// redirects back to it. Behind TLS-terminating load balancers the scheme and
+// port envoy sees may differ, so they're taken from the external scheme and
+// port options if set, otherwise, for requests from a trusted proxy, from the
+// X-Forwarded-Host and X-Forwarded-Port headers.
+func (a *Authorize) getExternalURL(in *envoy_service_auth_v2.CheckRequest) *url.URL {
+	u := getCheckRequestURL(in)
+	opts := a.currentOptions.Load()
+
+	peer := in.GetAttributes().GetSource().GetAddress().GetSocketAddress().GetAddress()
+	if isTrustedProxy(net.ParseIP(peer), a.currentOptions.LoadState().trustedProxies) {
+		headers := in.GetAttributes().GetRequest().GetHttp().GetHeaders()
+		// the first value was set by the proxy closest to the client
+		if host := strings.TrimSpace(strings.Split(headers["x-forwarded-host"], ",")[0]); host != "" {
+			u.Host = host
+		}
+		if port := strings.TrimSpace(strings.Split(headers["x-forwarded-port"], ",")[0]); port != "" {
+			u.Host = net.JoinHostPort(u.Hostname(), port)
+		}
+	}
+	if opts.ExternalScheme != "" {
+		u.Scheme = opts.ExternalScheme
+	}
+	if opts.ExternalPort != "" {
+		u.Host = net.JoinHostPort(u.Hostname(), opts.ExternalPort)
+	}
+
+	// the scheme's default port is implied
+	if port := u.Port(); (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+	return u
+}
+
+func isTrustedProxy(ip net.IP, trustedProxies []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseForwarded parses a Forwarded header value, as defined in rfc7239,
+// into its comma-separated hops.
+func parseForwarded(value string) []forwardedHop {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	var hops []forwardedHop
+	for _, element := range splitQuoted(value, ',') {
+		var hop forwardedHop
+		for _, pair := range splitQuoted(element, ';') {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch strings.ToLower(kv[0]) {
+			case "for":
+				hop.For = unquote(kv[1])
+			case "proto":
+				hop.Proto = unquote(kv[1])
+			}
+		}
+		hops = append(hops, hop)
+	}
+	return hops
+}
+
+// parseXForwarded parses X-Forwarded-For and X-Forwarded-Proto header values
+// into hops. A single proto applies to the nearest hop.
+func parseXForwarded(forwardedFor, forwardedProto string) []forwardedHop {
+	if forwardedFor == "" {
+		return nil
+	}
+	fors := strings.Split(forwardedFor, ",")
+	var protos []string
+	if forwardedProto != "" {
+		protos = strings.Split(forwardedProto, ",")
+	}
+	hops := make([]forwardedHop, len(fors))
+	for i := range fors {
+		hops[i].For = strings.TrimSpace(fors[i])
+		if j := i - (len(fors) - len(protos)); j >= 0 {
+			hops[i].Proto = strings.TrimSpace(protos[j])
+		}
+	}
+	return hops
+}
+
+// parseForwardedNode returns the IP of a forwarded node, which may be
+// bracketed and include a port (e.g. "[2001:db8::1]:4711"), or nil if the
+// node is obfuscated or unknown.
+func parseForwardedNode(node string) net.IP {
+	if strings.HasPrefix(node, "[") {
+		if end := strings.IndexByte(node, ']'); end > 0 {
+			return net.ParseIP(node[1:end])
+		}
+		return nil
+	}
+	if ip := net.ParseIP(node); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return net.ParseIP(host)
+	}
+	return nil
+}
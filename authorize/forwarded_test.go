@@ -0,0 +1,282 @@
+package authorize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/urlutil"
+)
+
+func Test_parseForwarded(t *testing.T) {
+	t.Parallel()
+	value := `for=192.0.2.43, For="[2001:db8:cafe::17]:4711";proto=https;by=203.0.113.43, for=unknown;host="example.com;x"`
+	assert.Equal(t, []forwardedHop{
+		{For: "192.0.2.43"},
+		{For: "[2001:db8:cafe::17]:4711", Proto: "https"},
+		{For: "unknown"},
+	}, parseForwarded(value))
+	assert.Nil(t, parseForwarded(""))
+}
+
+func Test_parseForwardedNode(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		node string
+		want net.IP
+	}{
+		{"192.0.2.43", net.ParseIP("192.0.2.43")},
+		{"192.0.2.43:8080", net.ParseIP("192.0.2.43")},
+		{"2001:db8:cafe::17", net.ParseIP("2001:db8:cafe::17")},
+		{"[2001:db8:cafe::17]", net.ParseIP("2001:db8:cafe::17")},
+		{"[2001:db8:cafe::17]:4711", net.ParseIP("2001:db8:cafe::17")},
+		{"[2001:db8:cafe::17", nil},
+		{"unknown", nil},
+		{"_hidden", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, parseForwardedNode(tt.node), tt.node)
+	}
+}
+
+func Test_getClientAddr(t *testing.T) {
+	t.Parallel()
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+	trusted := []*net.IPNet{proxies}
+
+	tests := []struct {
+		name       string
+		peer       string
+		headers    map[string]string
+		wantIP     string
+		wantScheme string
+	}{
+		{"untrusted peer", "192.0.2.1",
+			map[string]string{"forwarded": "for=198.51.100.7;proto=https", "x-forwarded-for": "198.51.100.8"},
+			"192.0.2.1", "http"},
+		{"no headers", "10.0.0.1", nil, "10.0.0.1", "http"},
+		{"forwarded", "10.0.0.1",
+			map[string]string{"forwarded": `for="[2001:db8:cafe::17]:4711";proto=HTTPS`},
+			"2001:db8:cafe::17", "https"},
+		{"forwarded preferred", "10.0.0.1",
+			map[string]string{"forwarded": "for=198.51.100.7", "x-forwarded-for": "198.51.100.8", "x-forwarded-proto": "https"},
+			"198.51.100.7", "http"},
+		{"forwarded skips trusted hops", "10.0.0.1",
+			map[string]string{"forwarded": "for=203.0.113.9, for=198.51.100.7;proto=https, for=10.1.1.1"},
+			"198.51.100.7", "https"},
+		{"forwarded obfuscated", "10.0.0.1",
+			map[string]string{"forwarded": "for=198.51.100.7, for=_hidden"},
+			"10.0.0.1", "http"},
+		{"x-forwarded-for", "10.0.0.1",
+			map[string]string{"x-forwarded-for": "203.0.113.9, 198.51.100.8, 10.2.2.2", "x-forwarded-proto": "https"},
+			"198.51.100.8", "https"},
+		{"x-forwarded-proto", "10.0.0.1",
+			map[string]string{"x-forwarded-for": "198.51.100.8", "x-forwarded-proto": "https"},
+			"198.51.100.8", "https"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			in := testCheckRequest("GET", "http://example.com/", tt.headers)
+			in.Attributes.Source = &envoy_service_auth_v2.AttributeContext_Peer{
+				Address: &envoy_api_v2_core.Address{
+					Address: &envoy_api_v2_core.Address_SocketAddress{
+						SocketAddress: &envoy_api_v2_core.SocketAddress{Address: tt.peer},
+					},
+				},
+			}
+			ip, scheme := getClientAddr(in, trusted)
+			assert.Equal(t, tt.wantIP, ip)
+			assert.Equal(t, tt.wantScheme, scheme)
+		})
+	}
+}
+
+func TestAuthorize_getExternalURL(t *testing.T) {
+	t.Parallel()
+	_, proxies, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name    string
+		peer    string
+		headers map[string]string
+		scheme  string
+		port    string
+		want    string
+	}{
+		{"default", "10.0.0.1", nil, "", "", "http://app.example.com/path?q=1"},
+		{"external scheme", "192.0.2.1", nil, "https", "", "https://app.example.com/path?q=1"},
+		{"external scheme and port", "192.0.2.1", nil, "https", "8443", "https://app.example.com:8443/path?q=1"},
+		{"external default port", "192.0.2.1", nil, "https", "443", "https://app.example.com/path?q=1"},
+		{"forwarded host and port", "10.0.0.1",
+			map[string]string{"x-forwarded-host": "www.example.com, lb.internal", "x-forwarded-port": "8080"},
+			"", "", "http://www.example.com:8080/path?q=1"},
+		{"forwarded headers from untrusted peer", "192.0.2.1",
+			map[string]string{"x-forwarded-host": "evil.example.com", "x-forwarded-port": "8080"},
+			"", "", "http://app.example.com/path?q=1"},
+		{"external options override forwarded port", "10.0.0.1",
+			map[string]string{"x-forwarded-port": "8080"},
+			"https", "443", "https://app.example.com/path?q=1"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a := new(Authorize)
+			a.currentOptions.StoreState(&optionsState{
+				options:        config.Options{ExternalScheme: tt.scheme, ExternalPort: tt.port},
+				trustedProxies: []*net.IPNet{proxies},
+			})
+			in := testCheckRequest("GET", "http://app.example.com/path?q=1", tt.headers)
+			in.Attributes.Source = &envoy_service_auth_v2.AttributeContext_Peer{
+				Address: &envoy_api_v2_core.Address{
+					Address: &envoy_api_v2_core.Address_SocketAddress{
+						SocketAddress: &envoy_api_v2_core.SocketAddress{Address: tt.peer},
+					},
+				},
+			}
+			assert.Equal(t, tt.want, a.getExternalURL(in).String())
+		})
+	}
+}
+
+func TestAuthorize_Check_externalRedirect(t *testing.T) {
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = cryptutil.NewBase64Key()
+	opts.ExternalScheme = "https"
+	opts.ExternalPort = "8443"
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the load balancer terminated tls, so envoy sees plain http on port 80
+	res, err := a.Check(context.TODO(), testCheckRequest("GET", "http://app.example.com/items", map[string]string{
+		"accept": "text/html",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !assert.Equal(t, http.StatusFound, int(res.GetDeniedResponse().GetStatus().GetCode())) {
+		return
+	}
+	var location string
+	for _, hdr := range res.GetDeniedResponse().GetHeaders() {
+		if hdr.GetHeader().GetKey() == "Location" {
+			location = hdr.GetHeader().GetValue()
+		}
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "https://app.example.com:8443/items", u.Query().Get(urlutil.QueryRedirectURI))
+}
+
+func TestAuthorize_Check_unknownClientIP(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&buf)
+
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour))
+	pipe := &envoy_api_v2_core.Address{
+		Address: &envoy_api_v2_core.Address_Pipe{Pipe: &envoy_api_v2_core.Pipe{Path: "/run/envoy.sock"}},
+	}
+	socket := &envoy_api_v2_core.Address{
+		Address: &envoy_api_v2_core.Address_SocketAddress{
+			SocketAddress: &envoy_api_v2_core.SocketAddress{Address: "192.0.2.1"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		handling   string
+		source     *envoy_api_v2_core.Address
+		path       string
+		session    bool
+		wantStatus int
+	}{
+		{"default", "", pipe, "/", true, http.StatusOK},
+		{"skip", config.UnknownClientIPSkip, pipe, "/", true, http.StatusOK},
+		{"skip, health probe", config.UnknownClientIPSkip, pipe, "/healthz", false, http.StatusFound},
+		{"deny", config.UnknownClientIPDeny, pipe, "/", true, http.StatusForbidden},
+		{"deny, known ip", config.UnknownClientIPDeny, socket, "/", true, http.StatusOK},
+		{"allow", config.UnknownClientIPAllow, pipe, "/", true, http.StatusOK},
+		{"allow, health probe", config.UnknownClientIPAllow, pipe, "/healthz", false, http.StatusOK},
+		{"allow, other path", config.UnknownClientIPAllow, pipe, "/admin", false, http.StatusFound},
+		{"allow, known ip", config.UnknownClientIPAllow, socket, "/healthz", false, http.StatusFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(config.Options{
+				Policies:        []config.Policy{policy},
+				CookieName:      "_pomerium",
+				AuthenticateURL: mustParseURL("https://authN.example.com"),
+				SharedKey:       sharedKey,
+				UnknownClientIP: tt.handling,
+				// allowed checks are logged too
+				AuthorizeLogSampleRate: 1,
+				HealthProbes:           []config.HealthProbe{{Sources: []string{"10.0.0.1"}, Paths: []string{"/healthz"}}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			headers := map[string]string{"accept": "text/html"}
+			if tt.session {
+				headers["cookie"] = "_pomerium=" + rawJWT
+			}
+			in := testCheckRequest("GET", "https://app.example.com"+tt.path, headers)
+			in.Attributes.Source = &envoy_service_auth_v2.AttributeContext_Peer{Address: tt.source}
+			buf.Reset()
+			res, err := a.Check(context.TODO(), in)
+			if !assert.NoError(t, err) {
+				return
+			}
+			if tt.wantStatus == http.StatusOK {
+				assert.NotNil(t, res.GetOkResponse())
+				if tt.session {
+					// the handling is logged with the check
+					var entry struct {
+						UnknownClientIP string `json:"unknown-client-ip"`
+					}
+					if assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry)) && tt.source == pipe {
+						assert.NotEmpty(t, entry.UnknownClientIP)
+					}
+				}
+				return
+			}
+			assert.Equal(t, tt.wantStatus, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
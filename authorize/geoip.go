@@ -0,0 +1,58 @@
+package authorize
+
+import (
+	"errors"
+	"net"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/geoip"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// newGeoIP opens the GeoIP database, or returns nil if there isn't one.
+func newGeoIP(opts *config.Options) (*geoip.DB, error) {
+	if opts.GeoIPDatabase == "" {
+		return nil, nil
+	}
+	return geoip.Open(opts.GeoIPDatabase)
+}
+
+// updateGeoIP replaces the GeoIP database if its file has changed. Lookups
+// use the new database before the old one is closed.
+func (a *Authorize) updateGeoIP(prev, opts *config.Options) error {
+	if (a.geoip.Load() != nil || opts.GeoIPDatabase == "") && prev.GeoIPDatabase == opts.GeoIPDatabase {
+		return nil
+	}
+	db, err := newGeoIP(opts)
+	if err != nil {
+		return err
+	}
+	prevDB := a.geoip.Load()
+	a.geoip.Store(db)
+	prevDB.Close()
+	return nil
+}
+
+// getClientLocation returns the country and region of the client IP, or empty
+// strings if they're unknown. Lookup errors are logged, rather than failing
+// the request.
+func (a *Authorize) getClientLocation(clientIP string) (country, region string) {
+	ip := net.ParseIP(clientIP)
+	db := a.geoip.Load()
+	if db == nil || ip == nil {
+		return "", ""
+	}
+	loc, err := db.Lookup(ip)
+	if errors.Is(err, geoip.ErrClosed) {
+		// the database was replaced during the lookup
+		if db = a.geoip.Load(); db == nil {
+			return "", ""
+		}
+		loc, err = db.Lookup(ip)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("ip", clientIP).Msg("authorize: error looking up client location")
+		return "", ""
+	}
+	return loc.Country, loc.Region
+}
@@ -0,0 +1,141 @@
+package authorize
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/geoip"
+	"github.com/pomerium/pomerium/internal/geoip/geoiptest"
+)
+
+func TestAuthorize_Check_allowedCountries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pomerium-geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbPath := filepath.Join(dir, "test.mmdb")
+	if err := geoiptest.WriteDB(dbPath, 24, map[string]geoip.Location{
+		"81.2.69.0/24":    {Country: "GB", Region: "ENG"},
+		"216.160.83.0/24": {Country: "US", Region: "WA"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, AllowedCountries: []string{"GB"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	opts.GeoIPDatabase = dbPath
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		ip          string
+		wantAllowed bool
+	}{
+		{"allowed", "81.2.69.142", true},
+		{"not allowed", "216.160.83.56", false},
+		{"unknown", "10.0.0.1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour)),
+			})
+			in.Attributes.Source = &envoy_service_auth_v2.AttributeContext_Peer{
+				Address: &envoy_api_v2_core.Address{
+					Address: &envoy_api_v2_core.Address_SocketAddress{
+						SocketAddress: &envoy_api_v2_core.SocketAddress{Address: tt.ip},
+					},
+				},
+			}
+			res, err := a.Check(context.TODO(), in)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+		})
+	}
+
+	// a missing database is a configuration error
+	opts.GeoIPDatabase = filepath.Join(dir, "missing.mmdb")
+	assert.Error(t, a.UpdateOptions(opts))
+}
+
+func TestAuthorize_updateGeoIP(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pomerium-geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	paths := []string{filepath.Join(dir, "a.mmdb"), filepath.Join(dir, "b.mmdb")}
+	for _, path := range paths {
+		if err := geoiptest.WriteDB(path, 24, map[string]geoip.Location{
+			"81.2.69.0/24": {Country: "GB", Region: "ENG"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := *config.NewDefaultOptions()
+	opts.GeoIPDatabase = paths[0]
+	a := new(Authorize)
+	if err := a.updateGeoIP(&config.Options{}, &opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// lookups keep finding the client while the database is replaced
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					country, region := a.getClientLocation("81.2.69.142")
+					assert.Equal(t, "GB", country)
+					assert.Equal(t, "ENG", region)
+				}
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		prev := opts
+		opts.GeoIPDatabase = paths[(i+1)%2]
+		if err := a.updateGeoIP(&prev, &opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+	a.geoip.Load().Close()
+}
@@ -6,81 +6,475 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/grpc/authorize"
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/internal/sessions/header"
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
 	"github.com/pomerium/pomerium/internal/telemetry/requestid"
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
 	"github.com/pomerium/pomerium/internal/urlutil"
 
 	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	octrace "go.opencensus.io/trace"
 )
 
 // Check implements the envoy auth server gRPC endpoint.
 func (a *Authorize) Check(ctx context.Context, in *envoy_service_auth_v2.CheckRequest) (*envoy_service_auth_v2.CheckResponse, error) {
 	ctx, span := trace.StartSpan(ctx, "authorize.grpc.Check")
 	defer span.End()
+	checkStart := time.Now()
+
+	// header bombs are denied before any of the headers are processed
+	if opts := a.currentOptions.Load(); isRequestHeadersTooLarge(in, opts.MaxRequestHeaders, opts.MaxRequestHeaderBytes) {
+		a.emitDenyEvent(in, "", http.StatusRequestHeaderFieldsTooLarge, "request headers too large")
+		return a.deniedResponse(in, http.StatusRequestHeaderFieldsTooLarge, http.StatusText(http.StatusRequestHeaderFieldsTooLarge), nil), nil
+	}
+
+	// routes are matched by the host envoy reports, so a conflicting Host
+	// header makes it ambiguous which route the request is for
+	if hostConflict := a.currentOptions.Load().HostConflict; hostConflict != config.HostConflictAllow {
+		if k, v, ok := getCheckRequestHostConflict(in, hostConflict == config.HostConflictStrict); ok {
+			log.Warn().Str("host", in.GetAttributes().GetRequest().GetHttp().GetHost()).Str(k, v).Msg("authorize: conflicting request host")
+			return a.invalidRequestResponse(in, "request host conflicts with its "+k+" header"), nil
+		}
+	}
+
+	// a server name which differs from the host may be an attempt to front
+	// a route with another's TLS connection
+	if a.currentOptions.Load().DenySNIHostMismatch {
+		if sni := getClientSNI(in); sni != "" && !strings.EqualFold(sni, urlutil.StripPort(in.GetAttributes().GetRequest().GetHttp().GetHost())) {
+			log.Warn().Str("host", in.GetAttributes().GetRequest().GetHttp().GetHost()).Str("sni", sni).Msg("authorize: tls server name doesn't match request host")
+			a.emitDenyEvent(in, "", http.StatusMisdirectedRequest, "tls server name doesn't match request host")
+			return a.deniedResponse(in, http.StatusMisdirectedRequest, http.StatusText(http.StatusMisdirectedRequest), nil), nil
+		}
+	}
 
 	// maybe rewrite http request for forward auth
 	isForwardAuth := a.handleForwardAuth(in)
+
+	// without a host the request can't match any route
+	if in.GetAttributes().GetRequest().GetHttp().GetHost() == "" {
+		return a.invalidRequestResponse(in, "request is missing a host header"), nil
+	}
+
+	// nor can requests without a path, or with the asterisk form of a
+	// server-wide OPTIONS request
+	switch p := in.GetAttributes().GetRequest().GetHttp().GetPath(); {
+	case p == "" || strings.HasPrefix(p, "?"):
+		return a.invalidRequestResponse(in, "request is missing a path"), nil
+	case p == "*":
+		if in.GetAttributes().GetRequest().GetHttp().GetMethod() != http.MethodOptions {
+			return a.invalidRequestResponse(in, "request path * is only valid for OPTIONS requests"), nil
+		}
+		if !a.currentOptions.Load().AllowOptionsAsterisk {
+			a.emitDenyEvent(in, "", http.StatusForbidden, "server-wide options request denied")
+			return a.deniedResponse(in, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil), nil
+		}
+		return a.serverOptionsResponse(), nil
+	}
+
+	// pomerium's own endpoints (e.g. sign in, the oauth2 callback and health
+	// checks) handle their own authentication, so they aren't subject to route
+	// policy. With forward auth they're served by the upstream, not pomerium.
+	if !isForwardAuth && isPomeriumRequest(in) {
+		return a.passthroughResponse(), nil
+	}
+
+	var sessionPreference string
+	policy := a.getMatchingPolicy(a.getPolicyRequestURL(in))
+	if policy != nil {
+		sessionPreference = policy.SessionPreference
+	}
+
+	// routes in maintenance are unavailable to everyone, unless
+	// administrators may bypass it, which is checked once they're known
+	if policy != nil && policy.Maintenance && !policy.MaintenanceAllowAdministrators {
+		return a.maintenanceResponse(in, policy), nil
+	}
+
+	// a heavy route's checks may be capped so that it can't starve the others
+	release, ok := a.currentOptions.LoadState().routeLimits.acquire(policy)
+	if !ok {
+		log.Warn().Str("route", policy.From).Msg("authorize: route concurrency limit reached")
+		return a.unavailableResponse(in, "route concurrency limit reached"), nil
+	}
+	defer release()
+
+	// known-bad user agents (e.g. scrapers), and optionally requests without
+	// one, are denied for every route
+	userAgent := in.GetAttributes().GetRequest().GetHttp().GetHeaders()["user-agent"]
+	if a.currentOptions.Load().RequireUserAgent && strings.TrimSpace(userAgent) == "" {
+		a.emitDenyEvent(in, "", http.StatusForbidden, "user agent required")
+		return a.deniedResponse(in, http.StatusForbidden, "A User-Agent header is required", nil), nil
+	}
+	if a.isDeniedUserAgent(userAgent) {
+		a.emitDenyEvent(in, "", http.StatusForbidden, "user agent denied")
+		return a.deniedResponse(in, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil), nil
+	}
+
+	// unusual methods (e.g. TRACE) are denied for every route
+	if method := in.GetAttributes().GetRequest().GetHttp().GetMethod(); containsString(a.currentOptions.Load().DeniedMethods, method) {
+		a.emitDenyEvent(in, "", http.StatusMethodNotAllowed, "method denied")
+		var hdrs http.Header
+		if policy != nil && len(policy.AllowedMethods) > 0 {
+			hdrs = http.Header{"Allow": {strings.Join(policy.AllowedMethods, ", ")}}
+		}
+		return a.deniedResponse(in, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed), hdrs), nil
+	}
+
+	// only the globally allowed methods are allowed for any route, and
+	// non-standard methods aren't implemented unless they're allowed
+	if opts := a.currentOptions.Load(); !isGloballyAllowedMethod(opts.GlobalAllowedMethods, in.GetAttributes().GetRequest().GetHttp().GetMethod()) {
+		if !config.IsStandardMethod(in.GetAttributes().GetRequest().GetHttp().GetMethod()) {
+			a.emitDenyEvent(in, "", http.StatusNotImplemented, "method not implemented")
+			return a.deniedResponse(in, http.StatusNotImplemented, http.StatusText(http.StatusNotImplemented), nil), nil
+		}
+		a.emitDenyEvent(in, "", http.StatusMethodNotAllowed, "method not globally allowed")
+		return a.deniedResponse(in, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed), http.Header{
+			"Allow": {strings.Join(getGloballyAllowedMethods(opts.GlobalAllowedMethods, opts.DeniedMethods), ", ")},
+		}), nil
+	}
+
+	// IP rules can't be applied to clients with an unknown IP, which are
+	// handled as configured instead, and logged with the check
+	var unknownClientIP string
+	if a.isUnknownClientIP(in) {
+		unknownClientIP = a.currentOptions.Load().UnknownClientIP
+		if unknownClientIP == "" {
+			unknownClientIP = config.UnknownClientIPSkip
+		}
+		if unknownClientIP == config.UnknownClientIPDeny {
+			log.Warn().
+				Str("host", in.GetAttributes().GetRequest().GetHttp().GetHost()).
+				Str("source", in.GetAttributes().GetSource().GetAddress().String()).
+				Msg("authorize: denied unknown client ip")
+			a.emitDenyEvent(in, "", http.StatusForbidden, "unknown client ip")
+			return a.deniedResponse(in, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil), nil
+		}
+	}
+
+	// cors preflight requests carry no credentials, so they're answered
+	// without loading a session
+	if isPreflightRequest(in) {
+		if policy != nil && policy.CORSAllowPreflight {
+			return a.passthroughResponse(), nil
+		}
+		if res := a.preflightResponse(in, policy); res != nil {
+			return res, nil
+		}
+	}
+
+	// routes may be restricted to a set of methods, regardless of policy
+	if policy != nil && !isAllowedMethod(policy, a.getPolicyRequestMethod(in)) {
+		a.emitDenyEvent(in, "", http.StatusMethodNotAllowed, "method not allowed")
+		return a.deniedResponse(in, http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed), http.Header{
+			"Allow": {strings.Join(policy.AllowedMethods, ", ")},
+		}), nil
+	}
+
+	// routes may also be restricted to a set of content types
+	if policy != nil && !isAllowedContentType(policy, getRequestContentType(in)) {
+		a.emitDenyEvent(in, "", http.StatusUnsupportedMediaType, "content type not allowed")
+		return a.deniedResponse(in, http.StatusUnsupportedMediaType, http.StatusText(http.StatusUnsupportedMediaType), nil), nil
+	}
+
+	// state-changing requests may be required to have a CSRF header
+	if policy != nil && isMissingCSRFHeader(policy, in, a.getPolicyRequestMethod(in)) {
+		a.emitDenyEvent(in, "", http.StatusForbidden, "missing csrf header")
+		return a.deniedResponse(in, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil), nil
+	}
+
+	// OPTIONS requests which aren't cors preflights may be passed upstream,
+	// or answered, without loading a session
+	if policy != nil && in.GetAttributes().GetRequest().GetHttp().GetMethod() == http.MethodOptions && !isPreflightRequest(in) {
+		switch policy.OptionsRequests {
+		case config.OptionsRequestsPassThrough:
+			return a.passthroughResponse(), nil
+		case config.OptionsRequestsRespond:
+			return a.optionsResponse(policy), nil
+		}
+	}
+
+	// load balancer and uptime checker probes of a route's health check
+	// paths have no session
+	if policy != nil && a.isHealthProbe(in) {
+		return a.passthroughResponse(), nil
+	}
+
+	// administrators may bypass policy with a single-use override token
+	if rawToken := in.GetAttributes().GetRequest().GetHttp().GetHeaders()[httputil.HeaderPomeriumOverrideToken]; rawToken != "" && policy != nil && a.currentOptions.LoadState().overrideVerifier != nil {
+		return a.overrideResponse(ctx, in, rawToken), nil
+	}
+
 	hreq := getHTTPRequestFromCheckRequest(in)
 
 	isNewSession := false
-	rawJWT, sessionErr := loadSession(hreq, a.currentOptions.Load(), a.currentEncoder.Load())
+	// reissueSession is set when the session cookie should be set again,
+	// without the session being new
+	reissueSession := false
+	var rawJWT []byte
+	var sessionErr error
+	var graceHeaders http.Header
+	var cookieMigrationHeaders http.Header
+	// degraded are the reasons, if any, that the request is checked in a
+	// degraded mode, failing open while a dependency is unavailable
+	var degraded []string
+	if apiKey := header.TokenFromHeader(hreq, "Authorization", httputil.AuthorizationTypePomeriumAPIKey); apiKey != "" {
+		// service accounts authenticate with an API key instead of a session
+		var err error
+		rawJWT, err = getAPIKeySession(a.currentOptions.Load(), a.currentEncoder.Load(), apiKey, hreq.Host)
+		if err != nil {
+			log.Warn().Err(err).Str("host", hreq.Host).Msg("authorize: denied service account api key")
+			a.emitDenyEvent(in, "", http.StatusUnauthorized, err.Error())
+			return a.unauthenticatedResponse(in), nil
+		}
+	} else if rawIdentity, verifier := in.GetAttributes().GetRequest().GetHttp().GetHeaders()[httputil.HeaderPomeriumInnerIdentity], a.currentOptions.LoadState().innerTrustVerifier; rawIdentity != "" && verifier != nil {
+		// users authenticated by an outer pomerium aren't authenticated again
+		var err error
+		rawJWT, err = getInnerTrustSession(verifier, a.currentEncoder.Load(), rawIdentity, hreq.Host)
+		if err != nil {
+			log.Warn().Err(err).Str("host", hreq.Host).Msg("authorize: denied inner identity")
+			a.emitDenyEvent(in, "", http.StatusUnauthorized, err.Error())
+			return a.unauthenticatedResponse(in), nil
+		}
+	} else {
+		// routes with their own shared key only accept sessions signed with it
+		routeEncoder := a.getRouteEncoder(policy)
+		rawJWT, sessionErr = loadSession(hreq, a.currentOptions.Load(), routeEncoder, sessionPreference)
+		if errors.Is(sessionErr, sessions.ErrNoSessionFound) && sessionPreference != config.SessionPreferenceHeaderOnly {
+			// sessions from a previous cookie name are re-issued under the
+			// current one
+			if legacyJWT, err := loadLegacyCookieSession(hreq, a.currentOptions.Load(), routeEncoder); err == nil {
+				rawJWT, sessionErr = legacyJWT, nil
+				reissueSession = true
+			} else if !errors.Is(err, sessions.ErrNoSessionFound) {
+				sessionErr = err
+			}
+		}
+		if len(rawJWT) > 0 && policy != nil && policy.SharedKeyRef != "" {
+			// once verified, the session is checked like any other
+			var err error
+			if rawJWT, err = resignSession(routeEncoder, a.currentEncoder.Load(), rawJWT); err != nil {
+				rawJWT, sessionErr = nil, err
+			}
+		}
+		if len(rawJWT) > 0 {
+			switch revoked, err := a.isRevoked(ctx, rawJWT); {
+			case err != nil:
+				// rather than deny every request while the store is unavailable
+				degraded = append(degraded, degradedRevocationStoreUnavailable)
+			case revoked:
+				rawJWT, sessionErr = nil, sessions.ErrRevoked
+			}
+		}
+		// sessions issued under the legacy cookie domain are re-issued under
+		// the current one
+		if sessionErr == nil && len(rawJWT) > 0 && needsCookieDomainMigration(a.currentOptions.Load(), hreq) {
+			if hdrs, err := getCookieDomainMigrationHeaders(a.currentOptions.Load(), routeEncoder, hreq); err != nil {
+				log.Warn().Err(err).Msg("authorize: error migrating session cookie domain")
+			} else {
+				cookieMigrationHeaders = hdrs
+				reissueSession = true
+			}
+		}
+		// users of a partner's SSO, federated with the route, have no
+		// session of their own. Pomerium endpoints, e.g. admin, are excluded.
+		if sessionErr != nil && !strings.HasPrefix(hreq.URL.Path, "/.pomerium/") {
+			partnerJWT, err := getPartnerSession(policy, a.currentEncoder.Load(), hreq, hreq.Host)
+			switch {
+			case err == nil:
+				rawJWT, sessionErr = partnerJWT, nil
+			case !errors.Is(err, sessions.ErrNoSessionFound):
+				log.Warn().Err(err).Str("host", hreq.Host).Msg("authorize: invalid partner session")
+			}
+		}
+	}
+	// refreshCache is whether a refresh of the session, if any, was cached
+	var refreshCache string
 	if a.isExpired(rawJWT) {
 		log.Info().Msg("refreshing session")
-		if newRawJWT, err := a.refreshSession(ctx, rawJWT); err == nil {
+		if newRawJWT, cached, err := a.refreshSessionCached(ctx, rawJWT); err == nil {
 			rawJWT = newRawJWT
 			sessionErr = nil
 			isNewSession = true
+			refreshCache = serverTimingCacheMiss
+			if cached {
+				refreshCache = serverTimingCacheHit
+			}
 		} else {
 			log.Warn().Err(err).Msg("authorize: error refreshing session")
 			// set the error to expired so that we can force a new login
 			sessionErr = sessions.ErrExpired
+			// unless the session is within its grace period
+			if graceJWT, remaining, ok := getGraceSession(a.currentOptions.Load(), a.currentEncoder.Load(), hreq.Method, rawJWT); ok {
+				rawJWT = graceJWT
+				sessionErr = nil
+				graceHeaders = http.Header{
+					http.CanonicalHeaderKey(httputil.HeaderPomeriumSessionGrace): {remaining.Round(time.Second).String()},
+				}
+			} else if outageJWT, remaining, ok := a.getOutageSession(rawJWT); ok {
+				// rather than loop on sign in while it's down
+				log.Warn().Dur("remaining", remaining).Msg("authorize: authenticate service is down, allowing expired session")
+				rawJWT = outageJWT
+				sessionErr = nil
+				degraded = append(degraded, degradedAuthenticateUnavailable)
+			}
+		}
+	}
+
+	// public routes evaluate requests without a session as the anonymous user
+	evalJWT, isAnonymous := rawJWT, false
+	if len(rawJWT) == 0 && policy != nil && policy.AllowPublicUnauthenticatedAccess && a.currentOptions.Load().AnonymousUser != "" {
+		if anonJWT, err := getAnonymousSession(a.currentOptions.Load(), a.currentEncoder.Load(), hreq.Host); err == nil {
+			evalJWT, isAnonymous = anonJWT, true
+		} else {
+			log.Warn().Err(err).Msg("authorize: error creating anonymous session")
 		}
 	}
 
-	req := getEvaluatorRequestFromCheckRequest(in, rawJWT)
+	req := a.getEvaluatorRequestFromCheckRequest(in, evalJWT)
+	// trusted service mesh traffic doesn't need a user session on the routes
+	// which allow its identity
+	req.MeshIdentity, _ = a.getTrustedMeshIdentity(in)
+	start := time.Now()
 	reply, err := a.pe.IsAuthorized(ctx, req)
 	if err != nil {
+		log.Error().Err(err).Str("request-id", requestid.FromContext(ctx)).Msg("authorize: error evaluating policy")
 		return nil, err
 	}
-	logAuthorizeCheck(ctx, in, reply, rawJWT)
+	// the anonymous user isn't an identity to assert to the upstream
+	if isAnonymous {
+		reply.SignedJwt = ""
+	}
+	elapsed := time.Since(start)
+	debugHeaders := a.getDebugHeaders(reply, elapsed)
+	a.applyGlobalAllowedGroups(reply, policy)
+	applyClientTLSRequirements(in, reply, policy)
+	// user identifiers are hashed in logs if enabled, and the session, which
+	// has them, isn't logged at all, nor are the headers it may be sent in
+	logReply, logJWT := a.getLogReply(reply), rawJWT
+	hashLogUsers := a.currentOptions.Load().HashLogUsers
+	if hashLogUsers {
+		logJWT = nil
+	}
+	if shouldLogAuthorizeCheck(ctx, in, reply, a.currentOptions.Load().AuthorizeLogSampleRate) {
+		logAuthorizeCheck(ctx, in, logReply, logJWT, isAnonymous, unknownClientIP, hashLogUsers, a.currentOptions.Load().ReservedHeaderPrefix)
+	}
+	if auditLog, decisionLog := a.auditLog.Load(), a.decisionLog.Load(); auditLog != nil || decisionLog != nil {
+		clientIP, _ := getClientAddr(in, a.currentOptions.LoadState().trustedProxies)
+		auditLog.Record(ctx, in, logReply, req, clientIP)
+		decisionLog.Record(ctx, in, logReply, clientIP, elapsed)
+	}
+	if a.currentOptions.Load().TracingProvider != "" {
+		annotateAuthorizeCheck(span, logReply)
+	}
+
+	// administrators may explain how policy is evaluated for a sample
+	// request, or replay a past one
+	if reply.Allow && a.currentOptions.Load().DebugPolicy && isPolicyDebugRequest(in) {
+		return a.policyDebugResponse(ctx, in, rawJWT), nil
+	}
+	if reply.Allow && a.currentOptions.Load().DebugPolicy && isPolicyReplayRequest(in) {
+		return a.policyReplayResponse(ctx, in, rawJWT), nil
+	}
+
+	// only administrators may use a route in maintenance which they bypass.
+	// Users without a session are left to sign in, so that they can, unless
+	// they'd be allowed without one.
+	if policy != nil && policy.Maintenance && (reply.GetEmail() != "" || reply.GetAllow()) &&
+		!containsString(a.currentOptions.Load().Administrators, reply.GetEmail()) {
+		return a.maintenanceResponse(in, policy), nil
+	}
+
+	if reply.GetAllow() && len(rawJWT) == 0 && req.MeshIdentity != "" {
+		return a.meshOKResponse(ctx, in, req.MeshIdentity), nil
+	}
 
 	switch {
 	case reply.GetHttpStatus().GetCode() > 0 && reply.GetHttpStatus().GetCode() != http.StatusOK:
 		// custom error from the IsAuthorized call
-		return a.deniedResponse(in,
+		hdrs := debugHeaders.Clone()
+		if hdrs == nil {
+			hdrs = http.Header{}
+		}
+		for k, v := range reply.GetHttpStatus().GetHeaders() {
+			hdrs.Set(k, v)
+		}
+		a.emitDenyEvent(in, reply.GetEmail(), reply.GetHttpStatus().GetCode(), reply.GetHttpStatus().GetMessage())
+		return withDenialDetails(a.deniedResponse(in,
 			reply.GetHttpStatus().GetCode(),
 			reply.GetHttpStatus().GetMessage(),
-			reply.GetHttpStatus().GetHeaders(),
-		), nil
+			hdrs,
+		), reply, policy, false), nil
 
 	case reply.Allow:
+		// tenants may only make their quota of requests in each period
+		if tenant := getTenant(a.currentOptions.Load().TenantClaim, a.currentEncoder.Load(), rawJWT); tenant != "" {
+			if reset, ok := a.tenantQuotas.use(tenant, timeNow()); !ok {
+				return a.tenantQuotaExhaustedResponse(in, reply, reset), nil
+			}
+		}
+
 		// ok!
-		return a.okResponse(reply, rawJWT, isNewSession), nil
+		metrics.RecordAuthorizeAllow(isNewSession)
+		for _, reason := range degraded {
+			metrics.RecordAuthorizeDegraded(reason)
+		}
+		serverTimingHeaders := a.getServerTimingHeaders(reply, time.Since(checkStart), elapsed, refreshCache)
+		return a.okResponse(reply, policy, rawJWT, isNewSession || reissueSession,
+			debugHeaders, serverTimingHeaders, graceHeaders, cookieMigrationHeaders, getDegradedHeaders(degraded), a.getSessionExpiresInHeaders(rawJWT), a.getProtocolHeaders(in)), nil
+
+	case errors.Is(sessionErr, sessions.ErrUnexpectedAlgorithm):
+		// a session with another alg header, e.g. "none", was forged or
+		// downgraded, so it's denied rather than signed in again. It's cleared,
+		// so that the user's next request signs in.
+		log.Warn().Err(sessionErr).Str("host", hreq.Host).Msg("authorize: denied session")
+		a.emitDenyEvent(in, "", http.StatusUnauthorized, sessionErr.Error())
+		var hdrs http.Header
+		if cookieStore, err := getCookieStore(a.currentOptions.Load(), a.currentEncoder.Load()); err == nil {
+			hdrs = getJWTClearCookieHeaders(cookieStore, hreq)
+		}
+		return withDenialDetails(a.deniedResponse(in, http.StatusUnauthorized, sessionErr.Error(), hdrs), reply, policy, false), nil
 
 	case reply.SessionExpired,
 		errors.Is(sessionErr, sessions.ErrExpired),
 		errors.Is(sessionErr, sessions.ErrIssuedInTheFuture),
 		errors.Is(sessionErr, sessions.ErrMalformed),
+		errors.Is(sessionErr, sessions.ErrMaxAgeExceeded),
 		errors.Is(sessionErr, sessions.ErrNoSessionFound),
+		errors.Is(sessionErr, sessions.ErrRevoked),
+		errors.Is(sessionErr, sessions.ErrInvalidIssuer),
 		errors.Is(sessionErr, sessions.ErrNotValidYet):
 		// redirect to login
 
-		// no redirect for forward auth, that's handled by a separate config setting
-		if isForwardAuth {
-			return a.deniedResponse(in, http.StatusUnauthorized, "Unauthenticated", nil), nil
+		// no redirect for forward auth, that's handled by a separate config
+		// setting, or for routes which only accept the authorization header
+		if isForwardAuth || sessionPreference == config.SessionPreferenceHeaderOnly {
+			return withDenialDetails(a.unauthenticatedResponse(in), reply, policy, true), nil
 		}
 
-		return a.redirectResponse(in), nil
+		// a malformed session (e.g. signed with a rotated key) would otherwise
+		// be re-sent by the browser and loop, so clear it
+		var hdrs http.Header
+		if errors.Is(sessionErr, sessions.ErrMalformed) {
+			if cookieStore, err := getCookieStore(a.currentOptions.Load(), a.currentEncoder.Load()); err == nil {
+				hdrs = getJWTClearCookieHeaders(cookieStore, hreq)
+			}
+		}
+		return withDenialDetails(a.redirectResponse(in, hdrs), reply, policy, true), nil
 
 	default:
 		// all other errors
@@ -88,20 +482,105 @@ func (a *Authorize) Check(ctx context.Context, in *envoy_service_auth_v2.CheckRe
 		if sessionErr != nil {
 			msg = sessionErr.Error()
 		}
-		return a.deniedResponse(in, http.StatusForbidden, msg, nil), nil
+		code := int32(http.StatusForbidden)
+		if policy != nil && policy.DenyStatusCode != 0 {
+			code = int32(policy.DenyStatusCode)
+		}
+		reason := msg
+		if len(reply.GetDenyReasons()) > 0 {
+			reason = strings.Join(reply.GetDenyReasons(), ", ")
+		} else if reason == "" {
+			reason = http.StatusText(int(code))
+		}
+		a.emitDenyEvent(in, reply.GetEmail(), code, reason)
+		return withDenialDetails(a.deniedResponse(in, code, msg, debugHeaders), reply, policy, false), nil
 	}
 }
 
-func (a *Authorize) getEnvoyRequestHeaders(rawJWT []byte, isNewSession bool) ([]*envoy_api_v2_core.HeaderValueOption, error) {
+// applyGlobalAllowedGroups denies an allowed request, other than to a public
+// route, if the user isn't a member of any of the global allowed groups.
+func (a *Authorize) applyGlobalAllowedGroups(reply *authorize.IsAuthorizedReply, policy *config.Policy) {
+	groups := a.currentOptions.Load().GlobalAllowedGroups
+	if len(groups) == 0 || !reply.GetAllow() || (policy != nil && policy.AllowPublicUnauthenticatedAccess) {
+		return
+	}
+	for _, group := range reply.GetGroups() {
+		for _, allowed := range groups {
+			if group == allowed {
+				return
+			}
+		}
+	}
+	reply.Allow = false
+	reply.DenyReasons = append(reply.DenyReasons, "NO_GLOBAL_GROUP")
+	reply.DenyRuleIds = append(reply.DenyRuleIds, "global_allowed_groups")
+}
+
+// getDebugHeaders returns the debugging headers for administrators, if
+// enabled.
+func (a *Authorize) getDebugHeaders(reply *authorize.IsAuthorizedReply, decisionTime time.Duration) http.Header {
+	opts := a.currentOptions.Load()
+	if !opts.DebugDecisionTime || reply.GetEmail() == "" {
+		return nil
+	}
+	for _, admin := range opts.Administrators {
+		if admin == reply.GetEmail() {
+			return http.Header{
+				http.CanonicalHeaderKey(httputil.HeaderPomeriumDecisionTime): {decisionTime.String()},
+			}
+		}
+	}
+	return nil
+}
+
+// getSessionExpiresInHeaders returns the header with the seconds until the
+// session expires, if enabled and the session expires.
+func (a *Authorize) getSessionExpiresInHeaders(rawSession []byte) http.Header {
+	if !a.currentOptions.Load().SessionExpiresInHeader || len(rawSession) == 0 {
+		return nil
+	}
+	state := sessions.State{}
+	if err := a.currentEncoder.Load().Unmarshal(rawSession, &state); err != nil || state.Expiry == nil {
+		return nil
+	}
+	expiresIn := time.Until(state.Expiry.Time())
+	if expiresIn < 0 {
+		expiresIn = 0
+	}
+	return http.Header{
+		http.CanonicalHeaderKey(httputil.HeaderPomeriumSessionExpiresIn): {strconv.Itoa(int(expiresIn / time.Second))},
+	}
+}
+
+// getProtocolHeaders returns the header with the HTTP protocol version of the
+// request, if enabled. It's always set, so that a client can't pass its own.
+func (a *Authorize) getProtocolHeaders(in *envoy_service_auth_v2.CheckRequest) http.Header {
+	if !a.currentOptions.Load().PassProtocolHeader {
+		return nil
+	}
+	return http.Header{
+		http.CanonicalHeaderKey(httputil.HeaderPomeriumProtocol): {in.GetAttributes().GetRequest().GetHttp().GetProtocol()},
+	}
+}
+
+func (a *Authorize) getEnvoyRequestHeaders(policy *config.Policy, rawJWT []byte, isNewSession bool) ([]*envoy_api_v2_core.HeaderValueOption, error) {
 	var hvos []*envoy_api_v2_core.HeaderValueOption
 
 	if isNewSession {
-		cookieStore, err := getCookieStore(a.currentOptions.Load(), a.currentEncoder.Load())
+		routeEncoder := a.getRouteEncoder(policy)
+		cookieStore, err := getCookieStore(a.currentOptions.Load(), routeEncoder)
 		if err != nil {
 			return nil, err
 		}
 
-		hdrs, err := getJWTSetCookieHeaders(cookieStore, rawJWT)
+		cookieJWT := rawJWT
+		if policy != nil && policy.SharedKeyRef != "" {
+			// the session is only accepted on the route signed with its key
+			if cookieJWT, err = resignSession(a.currentEncoder.Load(), routeEncoder, rawJWT); err != nil {
+				return nil, err
+			}
+		}
+		hdrs, err := getJWTSetCookieHeaders(cookieStore, cookieJWT)
 		if err != nil {
 			return nil, err
 		}
@@ -121,11 +600,99 @@ func (a *Authorize) getEnvoyRequestHeaders(rawJWT []byte, isNewSession bool) ([]
 	return hvos, nil
 }
 
+// refreshSessionOnce refreshes the session, coordinating concurrent requests
+// for the same session so that only a single refresh call is made to the
+// authenticate service. Callers waiting on another request's refresh share
+// its result.
+func (a *Authorize) refreshSessionOnce(ctx context.Context, rawJWT []byte) ([]byte, error) {
+	newJWT, _, err := a.refreshSessionCached(ctx, rawJWT)
+	return newJWT, err
+}
+
+// refreshSessionCached is refreshSessionOnce, which also returns whether the
+// session was refreshed recently enough for the refresh to be reused.
+func (a *Authorize) refreshSessionCached(ctx context.Context, rawJWT []byte) ([]byte, bool, error) {
+	key := string(rawJWT)
+	state := sessions.State{}
+	if err := a.currentEncoder.Load().Unmarshal(rawJWT, &state); err == nil && state.ID != "" {
+		key = state.ID
+	}
+
+	// requests which were sent before a recent refresh, and so still carry
+	// the old session, reuse the refreshed one
+	minInterval := a.currentOptions.Load().MinRefreshInterval
+	if v, ok := a.getRefreshedSession(key); ok && time.Since(v.refreshedAt) < minInterval {
+		return v.rawJWT, true, nil
+	}
+
+	ch := a.refreshGroup.DoChan(key, func() (interface{}, error) {
+		// the refresh is shared, so don't let a single caller's cancellation
+		// abort it for everybody else
+		newJWT, err := a.refreshSession(context.Background(), rawJWT)
+		if err == nil && minInterval > 0 && a.refreshedSessions != nil {
+			a.refreshedSessions.Add(key, refreshedSession{rawJWT: newJWT, refreshedAt: time.Now()})
+		}
+		return newJWT, err
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, false, res.Err
+		}
+		return res.Val.([]byte), false, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// The degraded header's values. degradedAuthenticateUnavailable is for
+// requests allowed with an expired session while the authenticate service is
+// down, and degradedRevocationStoreUnavailable for requests whose session
+// couldn't be checked for revocation.
+const (
+	degradedAuthenticateUnavailable    = "authenticate-unavailable"
+	degradedRevocationStoreUnavailable = "revocation-store-unavailable"
+)
+
+// getDegradedHeaders returns the degraded header for requests allowed in a
+// degraded mode, with a value for each reason, or nil if there are none.
+func getDegradedHeaders(reasons []string) http.Header {
+	if len(reasons) == 0 {
+		return nil
+	}
+	return http.Header{
+		http.CanonicalHeaderKey(httputil.HeaderPomeriumDegraded): reasons,
+	}
+}
+
+// refreshedSessionsSize is the number of recent refreshes which are kept for
+// the min refresh interval.
+const refreshedSessionsSize = 4096
+
+// A refreshedSession is a session's refresh, and when it was made.
+type refreshedSession struct {
+	rawJWT      []byte
+	refreshedAt time.Time
+}
+
+// getRefreshedSession returns the last refresh of the session, by its key.
+func (a *Authorize) getRefreshedSession(key string) (refreshedSession, bool) {
+	if a.refreshedSessions == nil {
+		return refreshedSession{}, false
+	}
+	v, ok := a.refreshedSessions.Get(key)
+	if !ok {
+		return refreshedSession{}, false
+	}
+	return v.(refreshedSession), true
+}
+
 func (a *Authorize) refreshSession(ctx context.Context, rawJWT []byte) (newSession []byte, err error) {
 	options := a.currentOptions.Load()
 
 	// 1 - build a signed url to call refresh on authenticate service
-	refreshURI := options.GetAuthenticateURL().ResolveReference(&url.URL{Path: "/.pomerium/refresh"})
+	authenticateURL := a.getAuthenticateURL()
+	refreshURI := authenticateURL.ResolveReference(&url.URL{Path: "/.pomerium/refresh"})
 	signedRefreshURL := urlutil.NewSignedURL(options.SharedKey, refreshURI).String()
 
 	// 2 - http call to authenticate service
@@ -139,9 +706,15 @@ func (a *Authorize) refreshSession(ctx context.Context, rawJWT []byte) (newSessi
 
 	res, err := httputil.DefaultClient.Do(req)
 	if err != nil {
+		a.authenticateHealth.MarkUnhealthy(authenticateURL)
 		return nil, fmt.Errorf("authorize: client err %s: %w", signedRefreshURL, err)
 	}
 	defer res.Body.Close()
+	if res.StatusCode >= http.StatusInternalServerError {
+		a.authenticateHealth.MarkUnhealthy(authenticateURL)
+	} else {
+		a.authenticateHealth.MarkHealthy(authenticateURL)
+	}
 	newJwt, err := ioutil.ReadAll(io.LimitReader(res.Body, 4<<10))
 	if err != nil {
 		return nil, err
@@ -153,12 +726,176 @@ func (a *Authorize) refreshSession(ctx context.Context, rawJWT []byte) (newSessi
 	return newJwt, nil
 }
 
+// getEvaluatorSession returns the policy evaluator's view of the session,
+// which is empty if the session can't be verified.
+func (a *Authorize) getEvaluatorSession(rawSession []byte) evaluator.Session {
+	state := sessions.State{}
+	if len(rawSession) == 0 || a.currentEncoder.Load().Unmarshal(rawSession, &state) != nil {
+		return evaluator.Session{}
+	}
+	return evaluator.Session{HasRefreshToken: state.HasRefreshToken}
+}
+
 func (a *Authorize) isExpired(rawSession []byte) bool {
 	state := sessions.State{}
 	err := a.currentEncoder.Load().Unmarshal(rawSession, &state)
 	return err == nil && state.IsExpired()
 }
 
+// isRevoked returns true if the session's id (jti) has been revoked. Sessions
+// without an id can't be revoked. An error is returned if the revocation
+// store is unavailable.
+func (a *Authorize) isRevoked(ctx context.Context, rawSession []byte) (bool, error) {
+	revocations := a.revocations.Load()
+	if revocations == nil {
+		return false, nil
+	}
+	state := sessions.State{}
+	if err := a.currentEncoder.Load().Unmarshal(rawSession, &state); err != nil || state.ID == "" {
+		return false, nil
+	}
+	revoked, err := revocations.IsRevoked(ctx, state.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("authorize: error checking session revocation")
+		return false, err
+	}
+	return revoked, nil
+}
+
+// getMatchingPolicy returns the first policy whose route matches the request
+// URL, or nil if none match.
+func (a *Authorize) getMatchingPolicy(requestURL *url.URL) *config.Policy {
+	options := a.currentOptions.Load()
+	for i := range options.Policies {
+		if options.Policies[i].Matches(requestURL) {
+			return &options.Policies[i]
+		}
+	}
+	return nil
+}
+
+// isAllowedMethod returns true if the route allows the method.
+func isAllowedMethod(policy *config.Policy, method string) bool {
+	if len(policy.AllowedMethods) == 0 {
+		return true
+	}
+	for _, allowed := range policy.AllowedMethods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// getRequestContentType returns the lowercase media type of the request's
+// Content-Type header, without parameters, or the header as is if it isn't a
+// valid media type.
+func getRequestContentType(in *envoy_service_auth_v2.CheckRequest) string {
+	contentType := strings.TrimSpace(in.GetAttributes().GetRequest().GetHttp().GetHeaders()["content-type"])
+	if contentType == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return mediaType
+}
+
+// isAllowedContentType returns true if the route allows the content type.
+// Requests without one, e.g. without a body, are always allowed.
+func isAllowedContentType(policy *config.Policy, contentType string) bool {
+	if len(policy.AllowedContentTypes) == 0 || contentType == "" {
+		return true
+	}
+	for _, allowed := range policy.AllowedContentTypes {
+		if allowed == contentType ||
+			(strings.HasSuffix(allowed, "/*") && strings.HasPrefix(contentType, strings.TrimSuffix(allowed, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMissingCSRFHeader returns true if the route requires a CSRF header for
+// the method, and the request doesn't have one.
+func isMissingCSRFHeader(policy *config.Policy, in *envoy_service_auth_v2.CheckRequest, method string) bool {
+	if policy.CSRFHeader == "" || !containsString(policy.GetCSRFMethods(), method) {
+		return false
+	}
+	return in.GetAttributes().GetRequest().GetHttp().GetHeaders()[strings.ToLower(policy.CSRFHeader)] == ""
+}
+
+// isGloballyAllowedMethod returns true if the method is globally allowed, or
+// if every method is.
+func isGloballyAllowedMethod(globalAllowedMethods []string, method string) bool {
+	return len(globalAllowedMethods) == 0 || containsString(globalAllowedMethods, method)
+}
+
+// getGloballyAllowedMethods returns the globally allowed methods which
+// aren't denied.
+func getGloballyAllowedMethods(globalAllowedMethods, deniedMethods []string) []string {
+	var methods []string
+	for _, method := range globalAllowedMethods {
+		if !containsString(deniedMethods, method) {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// isDeniedUserAgent returns true if the user agent matches any of the denied
+// user agents.
+func (a *Authorize) isDeniedUserAgent(userAgent string) bool {
+	for _, re := range a.currentOptions.LoadState().deniedUserAgents {
+		if re.MatchString(userAgent) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRequestHeadersTooLarge returns true if the request has more than
+// maxHeaders headers, or more than maxBytes bytes of header names and values.
+// A limit of 0 is no limit.
+func isRequestHeadersTooLarge(in *envoy_service_auth_v2.CheckRequest, maxHeaders, maxBytes int) bool {
+	hdrs := in.GetAttributes().GetRequest().GetHttp().GetHeaders()
+	if maxHeaders > 0 && len(hdrs) > maxHeaders {
+		return true
+	}
+	if maxBytes > 0 {
+		n := 0
+		for k, v := range hdrs {
+			if n += len(k) + len(v); n > maxBytes {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isPreflightRequest returns true if the check request is a CORS preflight
+// request.
+func isPreflightRequest(in *envoy_service_auth_v2.CheckRequest) bool {
+	hattrs := in.GetAttributes().GetRequest().GetHttp()
+	return hattrs.GetMethod() == http.MethodOptions &&
+		hattrs.GetHeaders()["access-control-request-method"] != "" &&
+		hattrs.GetHeaders()["origin"] != ""
+}
+
+// isPomeriumRequest returns true if the check request is for one of the
+// endpoints in pomerium's /.pomerium/ namespace, other than those, like the
+// policy debug endpoints, which administrators are authorized for by policy.
+func isPomeriumRequest(in *envoy_service_auth_v2.CheckRequest) bool {
+	p := getCheckRequestURL(in).Path
+	// administrators are authorized for the admin endpoints, e.g. policy
+	// debugging, by policy
+	if p == "/.pomerium/admin" || strings.HasPrefix(p, "/.pomerium/admin/") {
+		return false
+	}
+	return p == "/.pomerium" || strings.HasPrefix(p, "/.pomerium/")
+}
+
 func (a *Authorize) handleForwardAuth(req *envoy_service_auth_v2.CheckRequest) bool {
 	opts := a.currentOptions.Load()
 
@@ -177,6 +914,10 @@ func (a *Authorize) handleForwardAuth(req *envoy_service_auth_v2.CheckRequest) b
 			req.Attributes.Request.Http.Scheme = verifyURL.Scheme
 			req.Attributes.Request.Http.Host = verifyURL.Host
 			req.Attributes.Request.Http.Path = verifyURL.Path
+			if verifyURL.Path == "" {
+				// a uri without a path is for the root
+				req.Attributes.Request.Http.Path = "/"
+			}
 			// envoy sends the query string as part of the path
 			if verifyURL.RawQuery != "" {
 				req.Attributes.Request.Http.Path += "?" + verifyURL.RawQuery
@@ -188,16 +929,31 @@ func (a *Authorize) handleForwardAuth(req *envoy_service_auth_v2.CheckRequest) b
 	return false
 }
 
-func getEvaluatorRequestFromCheckRequest(in *envoy_service_auth_v2.CheckRequest, rawJWT []byte) *evaluator.Request {
-	requestURL := getCheckRequestURL(in)
+func (a *Authorize) getEvaluatorRequestFromCheckRequest(in *envoy_service_auth_v2.CheckRequest, rawJWT []byte) *evaluator.Request {
+	rawURL := getCheckRequestURL(in)
+	requestURL := a.getPolicyRequestURL(in)
+	clientIP, clientScheme := getClientAddr(in, a.currentOptions.LoadState().trustedProxies)
+	clientCountry, clientRegion := a.getClientLocation(clientIP)
 	req := &evaluator.Request{
-		User:              string(rawJWT),
-		Header:            getCheckRequestHeaders(in),
-		Host:              in.GetAttributes().GetRequest().GetHttp().GetHost(),
-		Method:            in.GetAttributes().GetRequest().GetHttp().GetMethod(),
-		RequestURI:        requestURL.String(),
-		URL:               requestURL.String(),
-		ClientCertificate: getPeerCertificate(in),
+		User:                   string(a.getPolicySessionJWT(rawJWT)),
+		Session:                a.getEvaluatorSession(rawJWT),
+		Header:                 splitHeaderValues(getCheckRequestHeaders(in), a.currentOptions.Load().AuthorizeSplitHeaders),
+		RawHeaders:             getCheckRequestRawHeaders(in, a.currentOptions.Load().AuthorizeRequestHeaders),
+		Host:                   in.GetAttributes().GetRequest().GetHttp().GetHost(),
+		Method:                 a.getPolicyRequestMethod(in),
+		RequestURI:             rawURL.String(),
+		Time:                   a.getRequestTime(),
+		URL:                    requestURL.String(),
+		Query:                  requestURL.Query(),
+		ContentType:            getRequestContentType(in),
+		ClientIP:               clientIP,
+		ClientScheme:           clientScheme,
+		ClientCountry:          clientCountry,
+		ClientRegion:           clientRegion,
+		SNI:                    getClientSNI(in),
+		Protocol:               in.GetAttributes().GetRequest().GetHttp().GetProtocol(),
+		ClientCertificate:      getPeerCertificate(in),
+		ClientCertificateChain: getForwardedClientCertificateChain(in),
 	}
 	return req
 }
@@ -223,6 +979,82 @@ func getCheckRequestHeaders(req *envoy_service_auth_v2.CheckRequest) map[string]
 	return h
 }
 
+// splitHeaderValues splits the comma-joined values of the named headers into
+// separate values, as if each had been sent as its own header.
+func splitHeaderValues(h map[string][]string, names []string) map[string][]string {
+	for _, name := range names {
+		k := http.CanonicalHeaderKey(name)
+		vs, ok := h[k]
+		if !ok {
+			continue
+		}
+		var split []string
+		for _, v := range vs {
+			for _, sv := range strings.Split(v, ",") {
+				if sv = strings.TrimSpace(sv); sv != "" {
+					split = append(split, sv)
+				}
+			}
+		}
+		h[k] = split
+	}
+	return h
+}
+
+// getCheckRequestRawHeaders returns the unmodified values of the named headers,
+// keyed by the name as given.
+func getCheckRequestRawHeaders(req *envoy_service_auth_v2.CheckRequest, names []string) map[string]string {
+	if len(names) == 0 {
+		return nil
+	}
+	h := make(map[string]string)
+	ch := req.GetAttributes().GetRequest().GetHttp().GetHeaders()
+	for _, name := range names {
+		for k, v := range ch {
+			if strings.EqualFold(k, name) {
+				h[name] = v
+				break
+			}
+		}
+	}
+	return h
+}
+
+// getPolicyRequestMethod returns the method the request is authorized as. If
+// configured, HEAD requests are authorized as GET requests. The upstream still
+// receives the request's own method.
+func (a *Authorize) getPolicyRequestMethod(req *envoy_service_auth_v2.CheckRequest) string {
+	method := req.GetAttributes().GetRequest().GetHttp().GetMethod()
+	if method == http.MethodHead && a.currentOptions.Load().HeadAsGet {
+		return http.MethodGet
+	}
+	return method
+}
+
+// getPolicyRequestURL returns the URL the request is matched to a route
+// policy with. Unless disabled, its path is normalized.
+func (a *Authorize) getPolicyRequestURL(req *envoy_service_auth_v2.CheckRequest) *url.URL {
+	u := getCheckRequestURL(req)
+	if a.currentOptions.Load().NormalizeRequestPath {
+		u.Path = normalizeRequestPath(u.Path)
+	}
+	return u
+}
+
+// normalizeRequestPath resolves the dot segments, including percent-encoded
+// dots, and repeated slashes in the path. A trailing slash is kept.
+func normalizeRequestPath(p string) string {
+	if p == "" {
+		return p
+	}
+	p = strings.NewReplacer("%2e", ".", "%2E", ".").Replace(p)
+	np := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && np != "/" {
+		np += "/"
+	}
+	return np
+}
+
 func getCheckRequestURL(req *envoy_service_auth_v2.CheckRequest) *url.URL {
 	h := req.GetAttributes().GetRequest().GetHttp()
 	u := &url.URL{
@@ -246,6 +1078,41 @@ func getCheckRequestURL(req *envoy_service_auth_v2.CheckRequest) *url.URL {
 	return u
 }
 
+// getCheckRequestHostConflict returns the name and value of a request header,
+// :authority or Host, which conflicts with the host envoy reports for the
+// request, if any. Unless strict, hosts which only differ by case, or by the
+// default port of the request's scheme, don't conflict.
+func getCheckRequestHostConflict(req *envoy_service_auth_v2.CheckRequest, strict bool) (string, string, bool) {
+	h := req.GetAttributes().GetRequest().GetHttp()
+	host := h.GetHost()
+	for _, k := range []string{":authority", "host"} {
+		v, ok := h.GetHeaders()[k]
+		if !ok || v == host {
+			continue
+		}
+		if strict {
+			return k, v, true
+		}
+		scheme := getCheckRequestURL(req).Scheme
+		if !strings.EqualFold(stripDefaultPort(scheme, v), stripDefaultPort(scheme, host)) {
+			return k, v, true
+		}
+	}
+	return "", "", false
+}
+
+// stripDefaultPort removes the port from the host if it's the scheme's
+// default port.
+func stripDefaultPort(scheme, host string) string {
+	switch {
+	case scheme == "http" && strings.HasSuffix(host, ":80"):
+		return strings.TrimSuffix(host, ":80")
+	case scheme == "https" && strings.HasSuffix(host, ":443"):
+		return strings.TrimSuffix(host, ":443")
+	}
+	return host
+}
+
 // getPeerCertificate gets the PEM-encoded peer certificate from the check request
 func getPeerCertificate(in *envoy_service_auth_v2.CheckRequest) string {
 	// ignore the error as we will just return the empty string in that case
@@ -258,6 +1125,10 @@ func logAuthorizeCheck(
 	in *envoy_service_auth_v2.CheckRequest,
 	reply *authorize.IsAuthorizedReply,
 	rawJWT []byte,
+	anonymous bool,
+	unknownClientIP string,
+	withoutCredentials bool,
+	reservedHeaderPrefix string,
 ) {
 	hdrs := getCheckRequestHeaders(in)
 	hattrs := in.GetAttributes().GetRequest().GetHttp()
@@ -266,7 +1137,11 @@ func logAuthorizeCheck(
 	evt = evt.Str("request-id", requestid.FromContext(ctx))
 	evt = evt.Strs("check-request-id", hdrs["X-Request-Id"])
 	evt = evt.Str("method", hattrs.GetMethod())
-	evt = evt.Interface("headers", hdrs)
+	if withoutCredentials {
+		evt = evt.Interface("headers", getLogHeaders(hdrs, reservedHeaderPrefix))
+	} else {
+		evt = evt.Interface("headers", hdrs)
+	}
 	evt = evt.Str("path", hattrs.GetPath())
 	evt = evt.Str("host", hattrs.GetHost())
 	evt = evt.Str("query", hattrs.GetQuery())
@@ -274,13 +1149,41 @@ func logAuthorizeCheck(
 	evt = evt.Bool("allow", reply.GetAllow())
 	evt = evt.Bool("session-expired", reply.GetSessionExpired())
 	evt = evt.Strs("deny-reasons", reply.GetDenyReasons())
+	evt = evt.Strs("deny-rule-ids", reply.GetDenyRuleIds())
+	evt = evt.Strs("warnings", reply.GetWarnings())
 	evt = evt.Str("email", reply.GetEmail())
 	evt = evt.Strs("groups", reply.GetGroups())
+	if anonymous {
+		evt = evt.Str("anonymous-user", reply.GetUser())
+	}
+	if unknownClientIP != "" {
+		evt = evt.Str("unknown-client-ip", unknownClientIP)
+	}
 	if rawJWT != nil {
 		evt = evt.Str("session", string(rawJWT))
 	}
 	if reply.GetHttpStatus() != nil {
 		evt = evt.Interface("http_status", reply.GetHttpStatus())
 	}
+	// correlate the log with the exported trace
+	if span := octrace.FromContext(ctx); span != nil && span.SpanContext().IsSampled() {
+		evt = evt.Str("trace-id", span.SpanContext().TraceID.String())
+		evt = evt.Str("span-id", span.SpanContext().SpanID.String())
+	}
 	evt.Msg("authorize check")
 }
+
+// annotateAuthorizeCheck records the decision as an annotation on the span,
+// so that it's exported along with the trace.
+func annotateAuthorizeCheck(span *octrace.Span, reply *authorize.IsAuthorizedReply) {
+	reason := "allowed"
+	if !reply.GetAllow() {
+		reason = strings.Join(reply.GetDenyReasons(), ", ")
+	}
+	span.Annotate([]octrace.Attribute{
+		octrace.BoolAttribute("allow", reply.GetAllow()),
+		octrace.StringAttribute("reason", reason),
+		octrace.StringAttribute("email", reply.GetEmail()),
+		octrace.StringAttribute("groups", strings.Join(reply.GetGroups(), ",")),
+	}, "authorize check")
+}
@@ -1,23 +1,46 @@
 package authorize
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
 	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/google/go-cmp/cmp"
 	"github.com/pomerium/pomerium/authorize/evaluator"
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/encoding"
 	"github.com/pomerium/pomerium/internal/encoding/jws"
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/internal/sessions/revocation"
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
+	"github.com/pomerium/pomerium/internal/urlutil"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"go.opencensus.io/stats/view"
+	octrace "go.opencensus.io/trace"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
 	"gopkg.in/square/go-jose.v2/jwt"
 )
 
@@ -46,7 +69,14 @@ yE+vPxsiUkvQHdO2fojCkY8jg70jxM+gu59tPDNbw3Uh/2Ij310FgTHsnGQMyA==
 -----END CERTIFICATE-----`
 
 func Test_getEvaluatorRequest(t *testing.T) {
-	actual := getEvaluatorRequestFromCheckRequest(&envoy_service_auth_v2.CheckRequest{
+	timeNow = func() time.Time { return time.Date(2020, 6, 3, 17, 30, 0, 0, time.UTC) }
+	defer func() { timeNow = time.Now }()
+
+	a := new(Authorize)
+	a.currentOptions.Store(config.Options{})
+	encoder, _ := jws.NewHS256Signer([]byte(cryptutil.NewBase64Key()), "authN.example.com")
+	a.currentEncoder.Store(encoder)
+	actual := a.getEvaluatorRequestFromCheckRequest(&envoy_service_auth_v2.CheckRequest{
 		Attributes: &envoy_service_auth_v2.AttributeContext{
 			Source: &envoy_service_auth_v2.AttributeContext_Peer{
 				Certificate: url.QueryEscape(certPEM),
@@ -59,10 +89,11 @@ func Test_getEvaluatorRequest(t *testing.T) {
 						"accept":            "text/html",
 						"x-forwarded-proto": "https",
 					},
-					Path:   "/some/path?qs=1",
-					Host:   "example.com",
-					Scheme: "http",
-					Body:   "BODY",
+					Path:     "/some/path?qs=1",
+					Host:     "example.com",
+					Scheme:   "http",
+					Body:     "BODY",
+					Protocol: "HTTP/2",
 				},
 			},
 		},
@@ -71,17 +102,103 @@ func Test_getEvaluatorRequest(t *testing.T) {
 		User:   "HELLO WORLD",
 		Method: "GET",
 		URL:    "https://example.com/some/path?qs=1",
+		Query:  map[string][]string{"qs": {"1"}},
 		Header: map[string][]string{
 			"Accept":            {"text/html"},
 			"X-Forwarded-Proto": {"https"},
 		},
 		Host:              "example.com",
 		RequestURI:        "https://example.com/some/path?qs=1",
+		Time:              evaluator.Time{Weekday: "wednesday", TimeOfDay: "17:30"},
+		ClientScheme:      "http",
 		ClientCertificate: certPEM,
+		Protocol:          "HTTP/2",
 	}
 	assert.Equal(t, expect, actual)
 }
 
+func Test_getEvaluatorRequestQuery(t *testing.T) {
+	a := new(Authorize)
+	a.currentOptions.Store(config.Options{})
+	actual := a.getEvaluatorRequestFromCheckRequest(&envoy_service_auth_v2.CheckRequest{
+		Attributes: &envoy_service_auth_v2.AttributeContext{
+			Request: &envoy_service_auth_v2.AttributeContext_Request{
+				Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+					Method: "GET",
+					Path:   "/?tenant=acme&tenant=globex&empty=&flag&name=a%20b",
+					Host:   "example.com",
+					Scheme: "https",
+				},
+			},
+		},
+	}, nil)
+	assert.Equal(t, map[string][]string{
+		"tenant": {"acme", "globex"},
+		"empty":  {""},
+		"flag":   {""},
+		"name":   {"a b"},
+	}, actual.Query)
+}
+
+func Test_getEvaluatorRequestRawHeaders(t *testing.T) {
+	a := new(Authorize)
+	a.currentOptions.Store(config.Options{
+		AuthorizeRequestHeaders: []string{"X-Tenant-ID", "X-Missing"},
+	})
+	actual := a.getEvaluatorRequestFromCheckRequest(&envoy_service_auth_v2.CheckRequest{
+		Attributes: &envoy_service_auth_v2.AttributeContext{
+			Request: &envoy_service_auth_v2.AttributeContext_Request{
+				Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+					Method: "GET",
+					Headers: map[string]string{
+						"x-tenant-id": "AcMe Corp",
+						"x-other":     "other",
+					},
+					Path:   "/",
+					Host:   "example.com",
+					Scheme: "https",
+				},
+			},
+		},
+	}, nil)
+	assert.Equal(t, map[string]string{"X-Tenant-ID": "AcMe Corp"}, actual.RawHeaders)
+}
+
+func Test_getEvaluatorRequestSplitHeaders(t *testing.T) {
+	a := new(Authorize)
+	a.currentOptions.Store(config.Options{
+		AuthorizeSplitHeaders: []string{"x-forwarded-for", "X-Missing"},
+	})
+	actual := a.getEvaluatorRequestFromCheckRequest(testCheckRequest("GET", "https://example.com/", map[string]string{
+		"x-forwarded-for": "10.0.0.1, 10.0.0.2,,10.0.0.3 ",
+		"accept":          "text/html, application/json",
+	}), nil)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, actual.Header["X-Forwarded-For"])
+	assert.Equal(t, []string{"text/html, application/json"}, actual.Header["Accept"], "unlisted headers should not be split")
+	_, ok := actual.Header["X-Missing"]
+	assert.False(t, ok)
+}
+
+func Test_getEvaluatorRequestContentType(t *testing.T) {
+	a := new(Authorize)
+	a.currentOptions.Store(config.Options{})
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"", ""},
+		{"application/json", "application/json"},
+		{"Text/HTML; charset=UTF-8", "text/html"},
+		{"not a media type;", "not a media type;"},
+	}
+	for _, tt := range tests {
+		actual := a.getEvaluatorRequestFromCheckRequest(testCheckRequest("POST", "https://example.com/", map[string]string{
+			"content-type": tt.contentType,
+		}), nil)
+		assert.Equal(t, tt.want, actual.ContentType, tt.contentType)
+	}
+}
+
 func Test_handleForwardAuth(t *testing.T) {
 	checkReq := &envoy_service_auth_v2.CheckRequest{
 		Attributes: &envoy_service_auth_v2.AttributeContext{
@@ -113,6 +230,15 @@ func Test_handleForwardAuth(t *testing.T) {
 			Scheme: "https",
 		}, checkReq.Attributes.Request.Http)
 	})
+	t.Run("uri without a path", func(t *testing.T) {
+		a := new(Authorize)
+		a.currentOptions.Store(config.Options{
+			ForwardAuthURL: mustParseURL("https://forward-auth.example.com"),
+		})
+		in := testCheckRequest("GET", "https://forward-auth.example.com/verify?uri="+url.QueryEscape("https://example.com"), nil)
+		assert.True(t, a.handleForwardAuth(in))
+		assert.Equal(t, "/", in.Attributes.Request.Http.Path)
+	})
 	t.Run("disabled", func(t *testing.T) {
 		a := new(Authorize)
 		a.currentOptions.Store(config.Options{
@@ -145,6 +271,222 @@ func Test_refreshSession(t *testing.T) {
 	assert.Equal(t, `{"Authorization":"Pomerium ABCD"}`, strings.TrimSpace(string(newSession)))
 }
 
+func Test_refreshSessionOnce(t *testing.T) {
+	var calls int32
+	var startOnce sync.Once
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		startOnce.Do(func() { close(started) })
+		<-release
+		_, _ = w.Write([]byte("NEW SESSION"))
+	}))
+	defer srv.Close()
+
+	sharedKey := cryptutil.NewBase64Key()
+	// callers which join after the refresh has finished reuse it, rather than
+	// refreshing again
+	a, err := New(config.Options{
+		CookieName:         "_pomerium",
+		AuthenticateURL:    mustParseURL(srv.URL),
+		SharedKey:          sharedKey,
+		MinRefreshInterval: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawJWT := []byte(testSessionJWT(t, sharedKey, "bob@example.com", "example.com", time.Now().Add(-time.Minute)))
+
+	const n = 10
+	var entered, wg sync.WaitGroup
+	entered.Add(n)
+	results := make(chan string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entered.Done()
+			newSession, err := a.refreshSessionOnce(context.Background(), rawJWT)
+			assert.NoError(t, err)
+			results <- string(newSession)
+		}()
+	}
+	// the refresh is held until every caller has entered
+	entered.Wait()
+	<-started
+	close(release)
+	wg.Wait()
+	close(results)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	for newSession := range results {
+		assert.Equal(t, "NEW SESSION", newSession)
+	}
+}
+
+func Test_refreshSessionOnce_minRefreshInterval(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		_, _ = fmt.Fprintf(w, "NEW SESSION %d", n)
+	}))
+	defer srv.Close()
+
+	sharedKey := cryptutil.NewBase64Key()
+	newAuthorize := func(minRefreshInterval time.Duration) *Authorize {
+		a, err := New(config.Options{
+			CookieName:         "_pomerium",
+			AuthenticateURL:    mustParseURL(srv.URL),
+			SharedKey:          sharedKey,
+			MinRefreshInterval: minRefreshInterval,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	rawJWT := []byte(testSessionJWT(t, sharedKey, "bob@example.com", "example.com", time.Now().Add(-time.Minute)))
+
+	t.Run("within interval", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		a := newAuthorize(time.Minute)
+		for i := 0; i < 2; i++ {
+			newSession, err := a.refreshSessionOnce(context.Background(), rawJWT)
+			assert.NoError(t, err)
+			assert.Equal(t, "NEW SESSION 1", string(newSession))
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+	t.Run("after interval", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		a := newAuthorize(time.Millisecond)
+		_, err := a.refreshSessionOnce(context.Background(), rawJWT)
+		assert.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+		newSession, err := a.refreshSessionOnce(context.Background(), rawJWT)
+		assert.NoError(t, err)
+		assert.Equal(t, "NEW SESSION 2", string(newSession))
+	})
+	t.Run("disabled", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		a := newAuthorize(0)
+		for i := 0; i < 2; i++ {
+			_, err := a.refreshSessionOnce(context.Background(), rawJWT)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestAuthorize_Check_refreshedAllowMetric(t *testing.T) {
+	view.Unregister(metrics.AuthorizeViews...)
+	if err := view.Register(metrics.AuthorizeViews...); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(metrics.AuthorizeViews...)
+
+	sharedKey := cryptutil.NewBase64Key()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour))))
+	}))
+	defer srv.Close()
+
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL(srv.URL)
+	opts.SharedKey = sharedKey
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expiry := range []time.Time{time.Now().Add(time.Hour), time.Now().Add(-time.Minute)} {
+		res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+			"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", expiry),
+		}))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NotNil(t, res.GetOkResponse())
+	}
+
+	rows, err := view.RetrieveData(metrics.AuthorizeAllowView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]int64{}
+	for _, row := range rows {
+		for _, tag := range row.Tags {
+			if tag.Key == metrics.TagKeyRefreshed {
+				got[tag.Value] = row.Data.(*view.CountData).Value
+			}
+		}
+	}
+	assert.Equal(t, map[string]int64{"false": 1, "true": 1}, got)
+}
+
+// testSessionJWT returns a session JWT for the given email and audience,
+// signed with the shared key.
+func testSessionJWT(t *testing.T, sharedKey, email, audience string, expiry time.Time) string {
+	t.Helper()
+	return testSignedSessionJWT(t, "HS256", sharedKey, email, audience, expiry)
+}
+
+// testSignedSessionJWT returns a session signed with the given algorithm.
+func testSignedSessionJWT(t *testing.T, alg, sharedKey, email, audience string, expiry time.Time) string {
+	t.Helper()
+	encoder, err := jws.NewHMACSigner(alg, []byte(sharedKey), "authN.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims jwt.Claims
+	claims.Expiry = jwt.NewNumericDate(expiry)
+	claims.IssuedAt = jwt.NewNumericDate(time.Now())
+	claims.NotBefore = jwt.NewNumericDate(time.Now())
+	claims.Subject = email
+	claims.Issuer = "authN.example.com"
+	claims.Audience = jwt.Audience{audience}
+	raw, err := encoder.Marshal(struct {
+		jwt.Claims
+		Email string `json:"email"`
+	}{claims, email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+// testCheckRequest returns a check request for the given method and url.
+func testCheckRequest(method, rawURL string, headers map[string]string) *envoy_service_auth_v2.CheckRequest {
+	u := mustParseURL(rawURL)
+	path := u.Path
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return &envoy_service_auth_v2.CheckRequest{
+		Attributes: &envoy_service_auth_v2.AttributeContext{
+			Request: &envoy_service_auth_v2.AttributeContext_Request{
+				Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+					Method:  method,
+					Headers: headers,
+					Path:    path,
+					Host:    u.Host,
+					Scheme:  u.Scheme,
+				},
+			},
+		},
+	}
+}
+
 func mustParseURL(str string) *url.URL {
 	u, err := url.Parse(str)
 	if err != nil {
@@ -281,6 +623,7 @@ func TestAuthorize_Check(t *testing.T) {
 								"cookie": "_pomerium=" + string(raw),
 							},
 							Host:   "test.example.com",
+							Path:   "/",
 							Scheme: "http",
 							Body:   "BODY",
 						},
@@ -298,3 +641,3260 @@ func TestAuthorize_Check(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthorize_Check_malformedSession(t *testing.T) {
+	opts := config.Options{
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       cryptutil.NewBase64Key(),
+	}
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sign the session with a different (e.g. rotated) key
+	encoder, err := jws.NewHS256Signer([]byte(cryptutil.NewBase64Key()), "authN.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := encoder.Marshal(map[string]interface{}{"email": "bob@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := a.Check(context.TODO(), &envoy_service_auth_v2.CheckRequest{
+		Attributes: &envoy_service_auth_v2.AttributeContext{
+			Request: &envoy_service_auth_v2.AttributeContext_Request{
+				Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+					Method: "GET",
+					Headers: map[string]string{
+						"accept": "text/html",
+						"cookie": "_pomerium=%" + string(raw) + "; _pomerium_1=garbage",
+					},
+					Path:   "/",
+					Host:   "test.example.com",
+					Scheme: "https",
+				},
+			},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	denied := res.GetDeniedResponse()
+	if !assert.NotNil(t, denied) {
+		return
+	}
+	assert.Equal(t, http.StatusFound, int(denied.GetStatus().GetCode()))
+
+	var cleared []string
+	for _, hvo := range denied.GetHeaders() {
+		if hvo.GetHeader().GetKey() != "Set-Cookie" {
+			continue
+		}
+		c := hvo.GetHeader().GetValue()
+		assert.Contains(t, c, "Max-Age=0")
+		cleared = append(cleared, c[:strings.Index(c, "=")])
+	}
+	assert.Equal(t, []string{"_pomerium", "_pomerium_1"}, cleared)
+}
+
+func TestAuthorize_Check_denyStatusCode(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://hidden.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, DenyStatusCode: http.StatusNotFound},
+		{From: "https://default.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:        policies,
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		host     string
+		wantCode int
+	}{
+		{"overridden", "hidden.example.com", http.StatusNotFound},
+		{"default", "default.example.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT := testSessionJWT(t, sharedKey, "alice@example.com", tt.host, time.Now().Add(time.Hour))
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://"+tt.host+"/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + rawJWT,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_corsDeny(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://api.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:           policies,
+		CookieName:         "_pomerium",
+		AuthenticateURL:    mustParseURL("https://authN.example.com"),
+		SharedKey:          sharedKey,
+		CORSAllowedOrigins: []string{"https://app.example.com/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		origin  string
+		wantHdr map[string]string
+	}{
+		{"allowed origin", "https://app.example.com", map[string]string{
+			"Access-Control-Allow-Origin":      "https://app.example.com",
+			"Access-Control-Allow-Credentials": "true",
+			"Vary":                             "Origin",
+		}},
+		{"disallowed origin", "https://evil.example.com", nil},
+		{"allowed origin prefix", "https://app.example.com.evil.com", nil},
+		{"no origin", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT := testSessionJWT(t, sharedKey, "alice@example.com", "api.example.com", time.Now().Add(time.Hour))
+			hdrs := map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + rawJWT,
+			}
+			if tt.origin != "" {
+				hdrs["origin"] = tt.origin
+			}
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://api.example.com/", hdrs))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, http.StatusForbidden, int(res.GetDeniedResponse().GetStatus().GetCode()))
+
+			got := map[string]string{}
+			for _, hvo := range res.GetDeniedResponse().GetHeaders() {
+				if strings.HasPrefix(hvo.GetHeader().GetKey(), "Access-Control-") || hvo.GetHeader().GetKey() == "Vary" {
+					got[hvo.GetHeader().GetKey()] = hvo.GetHeader().GetValue()
+				}
+			}
+			if tt.wantHdr == nil {
+				assert.Empty(t, got)
+			} else {
+				assert.Equal(t, tt.wantHdr, got)
+			}
+		})
+	}
+}
+
+// spanRecorder is a trace exporter which records exported spans.
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*octrace.SpanData
+}
+
+func (r *spanRecorder) ExportSpan(s *octrace.SpanData) {
+	r.mu.Lock()
+	r.spans = append(r.spans, s)
+	r.mu.Unlock()
+}
+
+func TestAuthorize_Check_traceDecision(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&buf)
+
+	recorder := &spanRecorder{}
+	octrace.RegisterExporter(recorder)
+	defer octrace.UnregisterExporter(recorder)
+
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:               policies,
+		CookieName:             "_pomerium",
+		AuthenticateURL:        mustParseURL("https://authN.example.com"),
+		SharedKey:              sharedKey,
+		TracingProvider:        config.JaegerTracingProviderName,
+		AuthorizeLogSampleRate: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, parent := octrace.StartSpan(context.Background(), "test", octrace.WithSampler(octrace.AlwaysSample()))
+	rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour))
+	res, err := a.Check(ctx, testCheckRequest("GET", "https://app.example.com/", map[string]string{
+		"cookie": "_pomerium=" + rawJWT,
+	}))
+	parent.End()
+	if !assert.NoError(t, err) || !assert.NotNil(t, res.GetOkResponse()) {
+		return
+	}
+	traceID := parent.SpanContext().TraceID.String()
+
+	// the decision is recorded on the check span
+	recorder.mu.Lock()
+	var annotation *octrace.Annotation
+	for _, s := range recorder.spans {
+		if s.Name != "authorize.grpc.Check" || s.TraceID.String() != traceID {
+			continue
+		}
+		for i := range s.Annotations {
+			if s.Annotations[i].Message == "authorize check" {
+				annotation = &s.Annotations[i]
+			}
+		}
+	}
+	recorder.mu.Unlock()
+	if assert.NotNil(t, annotation) {
+		assert.Equal(t, true, annotation.Attributes["allow"])
+		assert.Equal(t, "allowed", annotation.Attributes["reason"])
+		assert.Equal(t, "bob@example.com", annotation.Attributes["email"])
+	}
+
+	// and the log is correlated with the trace
+	var entry struct {
+		Message string `json:"message"`
+		TraceID string `json:"trace-id"`
+		Allow   bool   `json:"allow"`
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if err := json.Unmarshal([]byte(line), &entry); err == nil && entry.Message == "authorize check" {
+			break
+		}
+	}
+	assert.Equal(t, "authorize check", entry.Message)
+	assert.Equal(t, traceID, entry.TraceID)
+	assert.True(t, entry.Allow)
+}
+
+func Test_logAuthorizeCheck(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&buf)
+
+	logAuthorizeCheck(context.Background(), testCheckRequest("GET", "https://example.com/", nil), &authorize.IsAuthorizedReply{
+		DenyReasons: []string{"token is expired (exp)"},
+		DenyRuleIds: []string{"token_expired"},
+	}, nil, false, "", false, "")
+
+	var entry struct {
+		Allow       bool     `json:"allow"`
+		DenyReasons []string `json:"deny-reasons"`
+		DenyRuleIDs []string `json:"deny-rule-ids"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, entry.Allow)
+	assert.Equal(t, []string{"token is expired (exp)"}, entry.DenyReasons)
+	assert.Equal(t, []string{"token_expired"}, entry.DenyRuleIDs)
+}
+
+func TestAuthorize_Check_anonymousUser(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&buf)
+
+	policies := []config.Policy{
+		{From: "https://public.example.com", To: "http://localhost", AllowPublicUnauthenticatedAccess: true},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		anonymousUser string
+	}{
+		{"anonymous", "anonymous"},
+		{"disabled", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := *config.NewDefaultOptions()
+			opts.Policies = policies
+			opts.CookieName = "_pomerium"
+			opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+			opts.SharedKey = cryptutil.NewBase64Key()
+			opts.AnonymousUser = tt.anonymousUser
+			a, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf.Reset()
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://public.example.com/", nil))
+			if !assert.NoError(t, err) {
+				return
+			}
+			if !assert.NotNil(t, res.GetOkResponse()) {
+				return
+			}
+			// the anonymous user isn't asserted to the upstream
+			for _, h := range res.GetOkResponse().GetHeaders() {
+				if h.GetHeader().GetKey() == httputil.HeaderPomeriumJWTAssertion {
+					assert.Empty(t, h.GetHeader().GetValue())
+				}
+			}
+
+			var entry struct {
+				AnonymousUser string `json:"anonymous-user"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.anonymousUser, entry.AnonymousUser)
+		})
+	}
+}
+
+func TestAuthorize_Check_maxTokenAge(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	a, err := New(config.Options{
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+		MaxTokenAge:     time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder, err := jws.NewHS256Signer([]byte(sharedKey), "authN.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := encoder.Marshal(&sessions.State{
+		Email:    "bob@example.com",
+		Audience: jwt.Audience{"test.example.com"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		IssuedAt: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://test.example.com/", map[string]string{
+		"accept": "text/html",
+		"cookie": "_pomerium=" + string(raw),
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, http.StatusFound, int(res.GetDeniedResponse().GetStatus().GetCode()))
+}
+
+func TestAuthorize_Check_debugDecisionTime(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	newAuthorize := func(t *testing.T, enabled bool) *Authorize {
+		policies := []config.Policy{
+			{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"admin@example.com", "bob@example.com"}},
+			{From: "https://restricted.example.com", To: "http://localhost", AllowedUsers: []string{"nobody@example.com"}},
+		}
+		for i := range policies {
+			if err := policies[i].Validate(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		a, err := New(config.Options{
+			Policies:          policies,
+			Administrators:    []string{"admin@example.com"},
+			CookieName:        "_pomerium",
+			AuthenticateURL:   mustParseURL("https://authN.example.com"),
+			SharedKey:         sharedKey,
+			DebugDecisionTime: enabled,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		email   string
+		host    string
+		want    bool
+	}{
+		{"admin allowed", true, "admin@example.com", "app.example.com", true},
+		{"admin denied", true, "admin@example.com", "restricted.example.com", true},
+		{"non-admin", true, "bob@example.com", "app.example.com", false},
+		{"disabled", false, "admin@example.com", "app.example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthorize(t, tt.enabled)
+			rawJWT := testSessionJWT(t, sharedKey, tt.email, tt.host, time.Now().Add(time.Hour))
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://"+tt.host+"/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + rawJWT,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			hvos := res.GetOkResponse().GetHeaders()
+			if res.GetDeniedResponse() != nil {
+				hvos = res.GetDeniedResponse().GetHeaders()
+			}
+			var got string
+			for _, hvo := range hvos {
+				if strings.EqualFold(hvo.GetHeader().GetKey(), httputil.HeaderPomeriumDecisionTime) {
+					got = hvo.GetHeader().GetValue()
+				}
+			}
+			if !tt.want {
+				assert.Empty(t, got)
+				return
+			}
+			_, err = time.ParseDuration(got)
+			assert.NoError(t, err, "expected a decision time, got %q", got)
+		})
+	}
+}
+
+func TestAuthorize_Check_sessionExpiresInHeader(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		expiry  time.Duration
+		want    int
+	}{
+		{"hour", true, time.Hour, 3600},
+		{"minute", true, time.Minute, 60},
+		{"disabled", false, time.Hour, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(config.Options{
+				Policies:               []config.Policy{policy},
+				CookieName:             "_pomerium",
+				AuthenticateURL:        mustParseURL("https://authN.example.com"),
+				SharedKey:              sharedKey,
+				SessionExpiresInHeader: tt.enabled,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(tt.expiry))
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + rawJWT,
+			}))
+			if !assert.NoError(t, err) || !assert.Equal(t, int32(codes.OK), res.GetStatus().GetCode()) {
+				return
+			}
+			got := -1
+			for _, hvo := range res.GetOkResponse().GetHeaders() {
+				if strings.EqualFold(hvo.GetHeader().GetKey(), httputil.HeaderPomeriumSessionExpiresIn) {
+					got, err = strconv.Atoi(hvo.GetHeader().GetValue())
+					assert.NoError(t, err)
+				}
+			}
+			if tt.want < 0 {
+				assert.Equal(t, -1, got)
+				return
+			}
+			// the token's expiry has a resolution of a second
+			assert.InDelta(t, tt.want, got, 2)
+		})
+	}
+}
+
+func TestAuthorize_Check_protocolHeader(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		enabled  bool
+		protocol string
+		want     []string
+	}{
+		{"http/1.1", true, "HTTP/1.1", []string{"HTTP/1.1"}},
+		{"http/2", true, "HTTP/2", []string{"HTTP/2"}},
+		{"http/3", true, "HTTP/3", []string{"HTTP/3"}},
+		{"disabled", false, "HTTP/2", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(config.Options{
+				Policies:           []config.Policy{policy},
+				CookieName:         "_pomerium",
+				AuthenticateURL:    mustParseURL("https://authN.example.com"),
+				SharedKey:          sharedKey,
+				PassProtocolHeader: tt.enabled,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			in := testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour)),
+			})
+			in.Attributes.Request.Http.Protocol = tt.protocol
+			res, err := a.Check(context.TODO(), in)
+			if !assert.NoError(t, err) || !assert.Equal(t, int32(codes.OK), res.GetStatus().GetCode()) {
+				return
+			}
+			var got []string
+			for _, hvo := range res.GetOkResponse().GetHeaders() {
+				if strings.EqualFold(hvo.GetHeader().GetKey(), httputil.HeaderPomeriumProtocol) {
+					got = append(got, hvo.GetHeader().GetValue())
+				}
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAuthorize_Check_denialDetails(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(config.Options{
+		Policies:        []config.Policy{policy},
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantReason string
+		wantReauth string
+	}{
+		{"allowed", map[string]string{
+			"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour)),
+		}, "", ""},
+		{"denied", map[string]string{
+			"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "alice@example.com", "app.example.com", time.Now().Add(time.Hour)),
+		}, denialReasonAccessDenied, "false"},
+		{"expired", map[string]string{
+			"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(-time.Hour)),
+		}, denialReasonUnauthenticated, "true"},
+		{"no session", nil, denialReasonUnauthenticated, "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", tt.headers))
+			if !assert.NoError(t, err) {
+				return
+			}
+			details := res.GetStatus().GetDetails()
+			if tt.wantReason == "" {
+				assert.Empty(t, details)
+				return
+			}
+			if !assert.Len(t, details, 1) {
+				return
+			}
+			var info errdetails.ErrorInfo
+			if !assert.NoError(t, ptypes.UnmarshalAny(details[0], &info)) {
+				return
+			}
+			assert.Equal(t, tt.wantReason, info.GetReason())
+			assert.Equal(t, denialErrorDomain, info.GetDomain())
+			assert.Equal(t, tt.wantReauth, info.GetMetadata()["reauth"])
+			assert.Equal(t, fmt.Sprintf("%x", policy.Checksum()), info.GetMetadata()["policy_id"])
+		})
+	}
+}
+
+func TestAuthorize_Check_missingHost(t *testing.T) {
+	a, err := New(config.Options{
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       cryptutil.NewBase64Key(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, accept := range []string{"text/html", "application/json"} {
+		t.Run(accept, func(t *testing.T) {
+			in := testCheckRequest("GET", "http://example.com/", map[string]string{"accept": accept})
+			in.Attributes.Request.Http.Host = ""
+
+			res, err := a.Check(context.TODO(), in)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, int32(codes.InvalidArgument), res.GetStatus().GetCode())
+			assert.Equal(t, "request is missing a host header", res.GetStatus().GetMessage())
+			assert.Equal(t, http.StatusBadRequest, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_requestPath(t *testing.T) {
+	tests := []struct {
+		name                 string
+		method               string
+		path                 string
+		allowOptionsAsterisk bool
+		wantCode             int
+		wantMessage          string
+	}{
+		{"empty", "GET", "", false, http.StatusBadRequest, "request is missing a path"},
+		{"query only", "GET", "?a=b", false, http.StatusBadRequest, "request is missing a path"},
+		{"asterisk get", "GET", "*", true, http.StatusBadRequest, "request path * is only valid for OPTIONS requests"},
+		{"options asterisk denied", "OPTIONS", "*", false, http.StatusForbidden, "Access Denied"},
+		{"options asterisk allowed", "OPTIONS", "*", true, http.StatusOK, "Server Options"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(config.Options{
+				CookieName:           "_pomerium",
+				AuthenticateURL:      mustParseURL("https://authN.example.com"),
+				SharedKey:            cryptutil.NewBase64Key(),
+				AllowOptionsAsterisk: tt.allowOptionsAsterisk,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			in := testCheckRequest(tt.method, "http://example.com/", map[string]string{"accept": "application/json"})
+			in.Attributes.Request.Http.Path = tt.path
+
+			res, err := a.Check(context.TODO(), in)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantMessage, res.GetStatus().GetMessage())
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_pomeriumPath(t *testing.T) {
+	// a catch-all policy which would deny every request without a session
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	sharedKey := cryptutil.NewBase64Key()
+	a, err := New(config.Options{
+		Policies:        []config.Policy{policy},
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+		Administrators:  []string{"admin@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		wantCode codes.Code
+	}{
+		{"/.pomerium/sign_in", codes.OK},
+		{"/.pomerium/callback/?code=1", codes.OK},
+		{"/.pomerium", codes.OK},
+		{"/.pomerium/", codes.OK},
+		{policyDebugPath, codes.PermissionDenied},
+		{"/.pomerium/admin", codes.PermissionDenied},
+		{"/.pomerium/admin/sessions", codes.PermissionDenied},
+		{"/.pomeriumx", codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com"+tt.path, map[string]string{"accept": "application/json"}))
+			if assert.NoError(t, err) {
+				assert.Equal(t, int32(tt.wantCode), res.GetStatus().GetCode())
+			}
+		})
+	}
+
+	// admin endpoints are only allowed for administrators, even for users
+	// the route allows
+	for _, tt := range []struct {
+		email       string
+		wantAllowed bool
+	}{
+		{"bob@example.com", false},
+		{"admin@example.com", true},
+	} {
+		t.Run("admin/"+tt.email, func(t *testing.T) {
+			rawJWT := testSessionJWT(t, sharedKey, tt.email, "app.example.com", time.Now().Add(time.Hour))
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/.pomerium/admin/sessions", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + rawJWT,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			if !tt.wantAllowed {
+				assert.Equal(t, http.StatusForbidden, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			}
+		})
+	}
+}
+
+func Test_getCheckRequestHostConflict(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		headers map[string]string
+		strict  bool
+		want    string
+	}{
+		{"no headers", "https://app.example.com/", nil, false, ""},
+		{"matching authority", "https://app.example.com/", map[string]string{":authority": "app.example.com"}, false, ""},
+		{"matching host", "https://app.example.com/", map[string]string{"host": "app.example.com"}, true, ""},
+		{"conflicting authority", "https://app.example.com/", map[string]string{":authority": "admin.example.com"}, false, ":authority"},
+		{"conflicting host", "https://app.example.com/", map[string]string{":authority": "app.example.com", "host": "admin.example.com"}, false, "host"},
+		{"conflicting port", "https://app.example.com/", map[string]string{"host": "app.example.com:8443"}, false, "host"},
+		{"case", "https://app.example.com/", map[string]string{"host": "App.Example.com"}, false, ""},
+		{"default port", "https://app.example.com/", map[string]string{"host": "app.example.com:443"}, false, ""},
+		{"other scheme's default port", "https://app.example.com/", map[string]string{"host": "app.example.com:80"}, false, "host"},
+		{"strict case", "https://app.example.com/", map[string]string{"host": "App.Example.com"}, true, "host"},
+		{"strict default port", "https://app.example.com/", map[string]string{"host": "app.example.com:443"}, true, "host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, _, ok := getCheckRequestHostConflict(testCheckRequest("GET", tt.url, tt.headers), tt.strict)
+			assert.Equal(t, tt.want != "", ok)
+			assert.Equal(t, tt.want, k)
+		})
+	}
+}
+
+func TestAuthorize_Check_hostConflict(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		hostConflict string
+		host         string
+		wantAllowed  bool
+	}{
+		{"matching", "", "app.example.com", true},
+		{"conflicting", "", "admin.example.com", false},
+		{"conflicting allowed", config.HostConflictAllow, "admin.example.com", true},
+		{"default port", config.HostConflictDeny, "app.example.com:443", true},
+		{"strict default port", config.HostConflictStrict, "app.example.com:443", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := *config.NewDefaultOptions()
+			opts.Policies = policies
+			opts.CookieName = "_pomerium"
+			opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+			opts.SharedKey = sharedKey
+			opts.HostConflict = tt.hostConflict
+			a, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour)),
+				"host":   tt.host,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			if !tt.wantAllowed {
+				assert.Equal(t, http.StatusBadRequest, int(res.GetDeniedResponse().GetStatus().GetCode()))
+				assert.Equal(t, "request host conflicts with its host header", res.GetStatus().GetMessage())
+			}
+		})
+	}
+}
+
+func Test_normalizeRequestPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", ""},
+		{"/", "/"},
+		{"/admin", "/admin"},
+		{"/admin/", "/admin/"},
+		{"/public/../admin", "/admin"},
+		{"/public/./../admin/", "/admin/"},
+		{"/../../admin", "/admin"},
+		{"//admin//secret", "/admin/secret"},
+		{"/public/%2e%2e/admin", "/admin"},
+		{"/public/%2E%2E/admin", "/admin"},
+		{"admin", "/admin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, normalizeRequestPath(tt.path))
+		})
+	}
+}
+
+func TestAuthorize_Check_normalizeRequestPath(t *testing.T) {
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", Prefix: "/public/", AllowPublicUnauthenticatedAccess: true},
+		{From: "https://app.example.com", To: "http://localhost", Prefix: "/admin/", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		normalize   bool
+		path        string
+		wantAllowed bool
+	}{
+		{"public", true, "/public/", true},
+		{"traversal", true, "/public/../admin/", false},
+		{"encoded traversal", true, "/public/%2e%2e/admin/", false},
+		{"double slash", true, "/public/..//admin/", false},
+		{"not normalized", false, "/public/../admin/", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := *config.NewDefaultOptions()
+			opts.Policies = policies
+			opts.CookieName = "_pomerium"
+			opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+			opts.SharedKey = cryptutil.NewBase64Key()
+			opts.NormalizeRequestPath = tt.normalize
+			a, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com"+tt.path, nil))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+		})
+	}
+}
+
+func TestAuthorize_Check_serviceAccountAPIKey(t *testing.T) {
+	policies := []config.Policy{
+		{From: "https://api.example.com", To: "http://localhost", AllowedUsers: []string{"robot@example.com", "retired@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	apiKey := func(key, salt string) string {
+		return base64.StdEncoding.EncodeToString(cryptutil.GenerateHMAC([]byte(key), salt))
+	}
+	a, err := New(config.Options{
+		Policies:        policies,
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       cryptutil.NewBase64Key(),
+		ServiceAccountAPIKeys: []config.ServiceAccountAPIKey{
+			{Salt: "salt-1", Hash: apiKey("valid-key", "salt-1"), Email: "robot@example.com"},
+			{Salt: "salt-2", Hash: apiKey("revoked-key", "salt-2"), Email: "retired@example.com", Revoked: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		key         string
+		wantAllowed bool
+		wantCode    int
+	}{
+		{"valid", "valid-key", true, 0},
+		{"revoked", "revoked-key", false, http.StatusUnauthorized},
+		{"unknown", "unknown-key", false, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://api.example.com/", map[string]string{
+				"accept":        "application/json",
+				"authorization": "Pomerium-API-Key " + tt.key,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_warnings(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://old.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, DeprecationWarning: "policy deprecated"},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:        policies,
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		email        string
+		wantAllowed  bool
+		wantWarnings []string
+	}{
+		{"allowed", "bob@example.com", true, []string{"policy deprecated"}},
+		{"denied", "alice@example.com", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT := testSessionJWT(t, sharedKey, tt.email, "old.example.com", time.Now().Add(time.Hour))
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://old.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + rawJWT,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			var warnings []string
+			for _, h := range res.GetOkResponse().GetHeaders() {
+				if h.GetHeader().GetKey() == "X-Pomerium-Warning" {
+					warnings = append(warnings, h.GetHeader().GetValue())
+				}
+			}
+			assert.Equal(t, tt.wantWarnings, warnings)
+		})
+	}
+}
+
+func TestAuthorize_Check_allowedMethods(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://readonly.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, AllowedMethods: []string{"GET", "head"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:        policies,
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method      string
+		wantAllowed bool
+		wantCode    int
+		wantAllow   string
+	}{
+		{"GET", true, 0, ""},
+		{"HEAD", true, 0, ""},
+		{"POST", false, http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"DELETE", false, http.StatusMethodNotAllowed, "GET, HEAD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "readonly.example.com", time.Now().Add(time.Hour))
+			res, err := a.Check(context.TODO(), testCheckRequest(tt.method, "https://readonly.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + rawJWT,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			var allow string
+			for _, h := range res.GetDeniedResponse().GetHeaders() {
+				if h.GetHeader().GetKey() == "Allow" {
+					allow = h.GetHeader().GetValue()
+				}
+			}
+			assert.Equal(t, tt.wantAllow, allow)
+		})
+	}
+}
+
+func TestAuthorize_Check_allowedContentTypes(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://api.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, AllowedContentTypes: []string{"application/json", "Image/*"}},
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:        policies,
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		host        string
+		contentType string
+		wantAllowed bool
+	}{
+		{"allowed", "api.example.com", "application/json", true},
+		{"allowed with parameters", "api.example.com", "Application/JSON; charset=utf-8", true},
+		{"allowed subtype", "api.example.com", "image/png", true},
+		{"without content type", "api.example.com", "", true},
+		{"html", "api.example.com", "text/html", false},
+		{"json suffix", "api.example.com", "application/json-seq", false},
+		{"malformed", "api.example.com", "application/json;;", false},
+		{"unrestricted route", "app.example.com", "text/html", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", tt.host, time.Now().Add(time.Hour)),
+			}
+			if tt.contentType != "" {
+				headers["content-type"] = tt.contentType
+			}
+			res, err := a.Check(context.TODO(), testCheckRequest("POST", "https://"+tt.host+"/upload", headers))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			if !tt.wantAllowed {
+				assert.Equal(t, http.StatusUnsupportedMediaType, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			}
+		})
+	}
+}
+
+func TestAuthorize_Check_csrfHeader(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, CSRFHeader: "X-Requested-With"},
+		{From: "https://api.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, CSRFHeader: "X-CSRF-Token", CSRFMethods: []string{"delete"}},
+		{From: "https://other.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:        policies,
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		host        string
+		headers     map[string]string
+		wantAllowed bool
+	}{
+		{"post with header", "POST", "app.example.com", map[string]string{"x-requested-with": "XMLHttpRequest"}, true},
+		{"post without header", "POST", "app.example.com", nil, false},
+		{"post with empty header", "POST", "app.example.com", map[string]string{"x-requested-with": ""}, false},
+		{"put without header", "PUT", "app.example.com", nil, false},
+		{"delete without header", "DELETE", "app.example.com", nil, false},
+		{"get without header", "GET", "app.example.com", nil, true},
+		{"configured method with header", "DELETE", "api.example.com", map[string]string{"x-csrf-token": "abc"}, true},
+		{"configured method without header", "DELETE", "api.example.com", nil, false},
+		{"other method without header", "POST", "api.example.com", nil, true},
+		{"route without csrf header", "POST", "other.example.com", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", tt.host, time.Now().Add(time.Hour)),
+			}
+			for k, v := range tt.headers {
+				headers[k] = v
+			}
+			res, err := a.Check(context.TODO(), testCheckRequest(tt.method, "https://"+tt.host+"/items", headers))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			if !tt.wantAllowed {
+				assert.Equal(t, http.StatusForbidden, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			}
+		})
+	}
+}
+
+func TestAuthorize_Check_headAsGet(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://readonly.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, AllowedMethods: []string{"GET"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		headAsGet   bool
+		method      string
+		wantAllowed bool
+	}{
+		{"get", false, "GET", true},
+		{"head", false, "HEAD", false},
+		{"head as get", true, "HEAD", true},
+		{"post", true, "POST", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(config.Options{
+				Policies:        policies,
+				CookieName:      "_pomerium",
+				AuthenticateURL: mustParseURL("https://authN.example.com"),
+				SharedKey:       sharedKey,
+				HeadAsGet:       tt.headAsGet,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := a.Check(context.TODO(), testCheckRequest(tt.method, "https://readonly.example.com/", map[string]string{
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "readonly.example.com", time.Now().Add(time.Hour)),
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			if !tt.wantAllowed {
+				assert.Equal(t, http.StatusMethodNotAllowed, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			}
+		})
+	}
+}
+
+func TestAuthorize_Check_deniedMethods(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		deniedMethods []string
+		method        string
+		wantAllowed   bool
+		wantCode      int
+	}{
+		{"trace denied by default", nil, "TRACE", false, http.StatusMethodNotAllowed},
+		{"track denied by default", nil, "TRACK", false, http.StatusMethodNotAllowed},
+		{"get allowed by default", nil, "GET", true, 0},
+		{"trace allowed when removed", []string{"CONNECT"}, "TRACE", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := *config.NewDefaultOptions()
+			opts.Policies = policies
+			opts.CookieName = "_pomerium"
+			opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+			opts.SharedKey = sharedKey
+			if tt.deniedMethods != nil {
+				opts.DeniedMethods = tt.deniedMethods
+			}
+			a, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour))
+			res, err := a.Check(context.TODO(), testCheckRequest(tt.method, "https://app.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + rawJWT,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_globalAllowedMethods(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name                 string
+		globalAllowedMethods []string
+		method               string
+		wantAllowed          bool
+		wantCode             int
+		wantAllow            string
+	}{
+		{"get allowed by default", nil, "GET", true, 0, ""},
+		{"patch allowed by default", nil, "PATCH", true, 0, ""},
+		{"non-standard method not implemented by default", nil, "PROPFIND", false, http.StatusNotImplemented, ""},
+		{"denied method", nil, "TRACE", false, http.StatusMethodNotAllowed, ""},
+		{"standard method not allowed", []string{"GET", "HEAD"}, "POST", false, http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"standard method allowed", []string{"GET", "HEAD"}, "HEAD", true, 0, ""},
+		{"non-standard method allowed", []string{"GET", "PROPFIND"}, "PROPFIND", true, 0, ""},
+		{"non-standard method not allowed", []string{"GET", "PROPFIND"}, "MKCOL", false, http.StatusNotImplemented, ""},
+		{"every method allowed", []string{}, "PROPFIND", true, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := *config.NewDefaultOptions()
+			opts.Policies = policies
+			opts.CookieName = "_pomerium"
+			opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+			opts.SharedKey = sharedKey
+			if tt.globalAllowedMethods != nil {
+				opts.GlobalAllowedMethods = tt.globalAllowedMethods
+			}
+			a, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour))
+			res, err := a.Check(context.TODO(), testCheckRequest(tt.method, "https://app.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + rawJWT,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			if tt.wantAllow != "" {
+				var allow string
+				for _, hvo := range res.GetDeniedResponse().GetHeaders() {
+					if strings.EqualFold(hvo.GetHeader().GetKey(), "Allow") {
+						allow = hvo.GetHeader().GetValue()
+					}
+				}
+				assert.Equal(t, tt.wantAllow, allow)
+			}
+		})
+	}
+}
+
+func TestAuthorize_Check_deniedUserAgents(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		userAgent   string
+		wantAllowed bool
+		wantCode    int
+	}{
+		{"matching", "Mozilla/5.0 (compatible; BadBot/2.1)", false, http.StatusForbidden},
+		{"matching anchored", "python-requests/2.23.0", false, http.StatusForbidden},
+		{"not matching", "Mozilla/5.0 (X11; Linux x86_64) Firefox/76.0", true, 0},
+		{"pattern is case sensitive", "badbot/2.1", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := *config.NewDefaultOptions()
+			opts.Policies = policies
+			opts.CookieName = "_pomerium"
+			opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+			opts.SharedKey = sharedKey
+			opts.DeniedUserAgents = []string{"BadBot", "^python-requests/"}
+			a, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour))
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"accept":     "application/json",
+				"cookie":     "_pomerium=" + rawJWT,
+				"user-agent": tt.userAgent,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_requireUserAgent(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		require     bool
+		headers     map[string]string
+		wantAllowed bool
+	}{
+		{"present", true, map[string]string{"user-agent": "curl/7.68.0"}, true},
+		{"absent", true, nil, false},
+		{"empty", true, map[string]string{"user-agent": ""}, false},
+		{"blank", true, map[string]string{"user-agent": "  "}, false},
+		{"absent, not required", false, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(config.Options{
+				Policies:         []config.Policy{policy},
+				CookieName:       "_pomerium",
+				AuthenticateURL:  mustParseURL("https://authN.example.com"),
+				SharedKey:        sharedKey,
+				RequireUserAgent: tt.require,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			headers := map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour)),
+			}
+			for k, v := range tt.headers {
+				headers[k] = v
+			}
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", headers))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			if !tt.wantAllowed {
+				assert.Equal(t, http.StatusForbidden, int(res.GetDeniedResponse().GetStatus().GetCode()))
+				assert.Contains(t, res.GetDeniedResponse().GetBody(), "A User-Agent header is required")
+			}
+		})
+	}
+}
+
+func TestAuthorize_Check_maxRedirectURILength(t *testing.T) {
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	opts := *config.NewDefaultOptions()
+	opts.Policies = []config.Policy{policy}
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = cryptutil.NewBase64Key()
+	opts.MaxRedirectURILength = 64
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		url             string
+		wantRedirectURI string
+	}{
+		{"short", "https://app.example.com/items?page=2", "https://app.example.com/items?page=2"},
+		{"at the limit", "https://app.example.com/" + strings.Repeat("a", 40), "https://app.example.com/" + strings.Repeat("a", 40)},
+		{"over the limit", "https://app.example.com/" + strings.Repeat("a", 41), "https://app.example.com/"},
+		{"long query", "https://app.example.com/items?q=" + strings.Repeat("x", 4096), "https://app.example.com/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", tt.url, map[string]string{"accept": "text/html"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !assert.Equal(t, http.StatusFound, int(res.GetDeniedResponse().GetStatus().GetCode())) {
+				return
+			}
+			var location string
+			for _, hdr := range res.GetDeniedResponse().GetHeaders() {
+				if hdr.GetHeader().GetKey() == "Location" {
+					location = hdr.GetHeader().GetValue()
+				}
+			}
+			u, err := url.Parse(location)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantRedirectURI, u.Query().Get(urlutil.QueryRedirectURI))
+		})
+	}
+}
+
+func TestAuthorize_Check_requireRefreshToken(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, RequireRefreshToken: true}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(config.Options{
+		Policies:        []config.Policy{policy},
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder, err := jws.NewHS256Signer([]byte(sharedKey), "authN.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherEncoder, err := jws.NewHS256Signer([]byte(cryptutil.NewBase64Key()), "authN.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		encoder         encoding.Marshaler
+		hasRefreshToken bool
+		wantAllowed     bool
+	}{
+		{"with refresh token", encoder, true, true},
+		{"without refresh token", encoder, false, false},
+		{"unverified session", otherEncoder, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT, err := tt.encoder.Marshal(&sessions.State{
+				Issuer:          "authN.example.com",
+				Subject:         "bob@example.com",
+				Audience:        jwt.Audience{"app.example.com"},
+				Expiry:          jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				IssuedAt:        jwt.NewNumericDate(time.Now()),
+				Email:           "bob@example.com",
+				HasRefreshToken: tt.hasRefreshToken,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + string(rawJWT),
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+		})
+	}
+}
+
+func TestAuthorize_Check_globalAllowedGroups(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedDomains: []string{"example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// sessionJWT returns a session for bob, a member of groups
+	sessionJWT := func(t *testing.T, groups []string) string {
+		t.Helper()
+		encoder, err := jws.NewHS256Signer([]byte(sharedKey), "authN.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := encoder.Marshal(&sessions.State{
+			Subject:  "bob@example.com",
+			Issuer:   "authN.example.com",
+			Audience: jwt.Audience{"app.example.com"},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+			Email:    "bob@example.com",
+			Groups:   groups,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(raw)
+	}
+
+	tests := []struct {
+		name         string
+		globalGroups []string
+		groups       []string
+		wantAllowed  bool
+		wantCode     int
+	}{
+		{"disabled", nil, nil, true, 0},
+		{"in a global group", []string{"employees", "contractors"}, []string{"everyone", "contractors"}, true, 0},
+		{"not in a global group", []string{"employees", "contractors"}, []string{"everyone"}, false, http.StatusForbidden},
+		{"no groups", []string{"employees"}, nil, false, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := *config.NewDefaultOptions()
+			opts.Policies = policies
+			opts.CookieName = "_pomerium"
+			opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+			opts.SharedKey = sharedKey
+			opts.GlobalAllowedGroups = tt.globalGroups
+			opts.SecurityEvents = true
+			a, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+			log.Logger = zerolog.New(&buf)
+
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + sessionJWT(t, tt.groups),
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			if !tt.wantAllowed {
+				assert.Contains(t, buf.String(), "NO_GLOBAL_GROUP")
+			}
+		})
+	}
+}
+
+func TestAuthorize_Check_requestHeaderLimits(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// withHeaders returns the headers with n more headers of size bytes each
+	withHeaders := func(n, size int) map[string]string {
+		hdrs := map[string]string{
+			"accept": "application/json",
+			"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour)),
+		}
+		for i := 0; i < n; i++ {
+			hdrs[fmt.Sprintf("x-header-%d", i)] = strings.Repeat("x", size)
+		}
+		return hdrs
+	}
+
+	tests := []struct {
+		name        string
+		maxHeaders  int
+		maxBytes    int
+		headers     map[string]string
+		wantAllowed bool
+		wantCode    int
+	}{
+		{"under", 10, 4096, withHeaders(8, 16), true, 0},
+		{"too many headers", 10, 4096, withHeaders(9, 16), false, http.StatusRequestHeaderFieldsTooLarge},
+		{"too many bytes", 10, 4096, withHeaders(1, 4096), false, http.StatusRequestHeaderFieldsTooLarge},
+		{"no limits", 0, 0, withHeaders(1000, 1024), true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := *config.NewDefaultOptions()
+			opts.Policies = policies
+			opts.CookieName = "_pomerium"
+			opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+			opts.SharedKey = sharedKey
+			opts.MaxRequestHeaders = tt.maxHeaders
+			opts.MaxRequestHeaderBytes = tt.maxBytes
+			a, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", tt.headers))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_partnerSession(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	partnerKey, otherPartnerKey := cryptutil.NewKey(), cryptutil.NewKey()
+	policies := []config.Policy{
+		{From: "https://partner.example.com", To: "http://localhost", AllowedDomains: []string{"partner.example"},
+			PartnerCookieName: "_partner", PartnerCookieKey: base64.StdEncoding.EncodeToString(partnerKey)},
+		{From: "https://other.example.com", To: "http://localhost", AllowedDomains: []string{"partner.example"},
+			PartnerCookieName: "_partner", PartnerCookieKey: base64.StdEncoding.EncodeToString(otherPartnerKey)},
+		{From: "https://app.example.com", To: "http://localhost", AllowedDomains: []string{"partner.example"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// partnerCookie returns a partner session cookie for alice
+	partnerCookie := func(t *testing.T, key []byte, audience string, expiry time.Time) string {
+		t.Helper()
+		signer, err := jws.NewHS256Signer(key, "sso.partner.example")
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := signer.Marshal(partnerClaims{
+			Claims: jwt.Claims{
+				Issuer:   "sso.partner.example",
+				Subject:  "alice",
+				Audience: jwt.Audience{audience},
+				Expiry:   jwt.NewNumericDate(expiry),
+			},
+			Email: "alice@partner.example",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "_partner=" + string(raw)
+	}
+	inAnHour := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		host        string
+		cookie      string
+		wantAllowed bool
+		wantCode    int
+	}{
+		{"valid", "partner.example.com", partnerCookie(t, partnerKey, "partner.example.com", inAnHour), true, 0},
+		{"wrong key", "partner.example.com", partnerCookie(t, cryptutil.NewKey(), "partner.example.com", inAnHour), false, http.StatusFound},
+		{"another route's key", "other.example.com", partnerCookie(t, partnerKey, "other.example.com", inAnHour), false, http.StatusFound},
+		{"wrong audience", "partner.example.com", partnerCookie(t, partnerKey, "other.example.com", inAnHour), false, http.StatusFound},
+		{"expired", "partner.example.com", partnerCookie(t, partnerKey, "partner.example.com", time.Now().Add(-time.Hour)), false, http.StatusFound},
+		{"route without a partner", "app.example.com", partnerCookie(t, partnerKey, "app.example.com", inAnHour), false, http.StatusFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://"+tt.host+"/", map[string]string{
+				"cookie": tt.cookie,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_innerIdentity(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	innerTrustSecret := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	newAuthorize := func(t *testing.T, innerTrustSecret string) *Authorize {
+		t.Helper()
+		opts := *config.NewDefaultOptions()
+		opts.Policies = policies
+		opts.CookieName = "_pomerium"
+		opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+		opts.SharedKey = sharedKey
+		opts.InnerTrustSecret = innerTrustSecret
+		a, err := New(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	a := newAuthorize(t, innerTrustSecret)
+	// innerIdentity returns an inner identity header for the user, as an
+	// outer pomerium forwards it
+	innerIdentity := func(t *testing.T, secret, email, audience string, expiry time.Time) string {
+		t.Helper()
+		signer, err := jws.NewHS256Signer([]byte(secret), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := signer.Marshal(partnerClaims{
+			Claims: jwt.Claims{
+				Subject:  email,
+				Audience: jwt.Audience{audience},
+				Expiry:   jwt.NewNumericDate(expiry),
+			},
+			Email: email,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(raw)
+	}
+	inAnHour := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name        string
+		a           *Authorize
+		identity    string
+		wantAllowed bool
+		wantCode    int
+	}{
+		{"valid", a, innerIdentity(t, innerTrustSecret, "bob@example.com", "app.example.com", inAnHour), true, 0},
+		{"not allowed", a, innerIdentity(t, innerTrustSecret, "alice@example.com", "app.example.com", inAnHour), false, http.StatusForbidden},
+		{"forged", a, innerIdentity(t, cryptutil.NewBase64Key(), "bob@example.com", "app.example.com", inAnHour), false, http.StatusUnauthorized},
+		{"signed with the shared secret", a, innerIdentity(t, sharedKey, "bob@example.com", "app.example.com", inAnHour), false, http.StatusUnauthorized},
+		{"wrong audience", a, innerIdentity(t, innerTrustSecret, "bob@example.com", "other.example.com", inAnHour), false, http.StatusUnauthorized},
+		{"expired", a, innerIdentity(t, innerTrustSecret, "bob@example.com", "app.example.com", time.Now().Add(-time.Hour)), false, http.StatusUnauthorized},
+		{"no email", a, innerIdentity(t, innerTrustSecret, "", "app.example.com", inAnHour), false, http.StatusUnauthorized},
+		{"not trusted", newAuthorize(t, ""), innerIdentity(t, innerTrustSecret, "bob@example.com", "app.example.com", inAnHour), false, http.StatusFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"accept":                    "application/json",
+				"x-pomerium-inner-identity": tt.identity,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_matchedConditions(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", PassMatchedConditions: true,
+			AllowedUsers: []string{"bob@example.com"}, AllowedDomains: []string{"example.com"}},
+		{From: "https://public.example.com", To: "http://localhost", PassMatchedConditions: true, AllowPublicUnauthenticatedAccess: true},
+		{From: "https://other.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		host   string
+		email  string
+		want   string
+		wantOK bool
+	}{
+		{"user and domain", "app.example.com", "bob@example.com", "domain:example.com,user:bob@example.com", true},
+		{"domain", "app.example.com", "alice@example.com", "domain:example.com", true},
+		{"public", "public.example.com", "bob@example.com", "public", true},
+		{"not passed", "other.example.com", "bob@example.com", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://"+tt.host+"/", map[string]string{
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, tt.email, tt.host, time.Now().Add(time.Hour)),
+				// a client can't pass its own conditions upstream
+				"x-pomerium-matched-conditions": "admin",
+			}))
+			if !assert.NoError(t, err) || !assert.NotNil(t, res.GetOkResponse()) {
+				return
+			}
+			var got string
+			var ok bool
+			for _, hvo := range res.GetOkResponse().GetHeaders() {
+				if hvo.GetHeader().GetKey() == "X-Pomerium-Matched-Conditions" {
+					got, ok = hvo.GetHeader().GetValue(), true
+				}
+			}
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAuthorize_Check_expectContinue(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://upload.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		email       string
+		wantAllowed bool
+		wantCode    int
+	}{
+		{"allowed", "bob@example.com", true, 0},
+		{"denied", "alice@example.com", false, http.StatusForbidden},
+		{"unauthenticated", "", false, http.StatusFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{
+				"expect":         "100-continue",
+				"content-type":   "application/octet-stream",
+				"content-length": "1073741824",
+			}
+			if tt.email != "" {
+				headers["cookie"] = "_pomerium=" + testSessionJWT(t, sharedKey, tt.email, "upload.example.com", time.Now().Add(time.Hour))
+			}
+			// the check is made on the headers alone, so must be decided
+			// without waiting for a body that the client won't send until it
+			// has been allowed
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			res, err := a.Check(ctx, testCheckRequest("PUT", "https://upload.example.com/files/large.bin", headers))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.NoError(t, ctx.Err(), "expected the check to respond before the deadline")
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_corsPreflight(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, AllowedMethods: []string{"GET", "POST"}},
+		{From: "https://upstream.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, CORSAllowPreflight: true},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	preflight := map[string]string{
+		"origin":                         "https://ui.example.com",
+		"access-control-request-method":  "POST",
+		"access-control-request-headers": "content-type",
+	}
+	tests := []struct {
+		name             string
+		method           string
+		url              string
+		headers          map[string]string
+		withSession      bool
+		wantAllowed      bool
+		wantCode         int
+		wantAllowMethods string
+	}{
+		{"preflight answered", "OPTIONS", "https://app.example.com/api", preflight, false, false, http.StatusNoContent, "GET, POST"},
+		{"preflight from other origin", "OPTIONS", "https://app.example.com/api", map[string]string{
+			"origin":                        "https://evil.example.com",
+			"access-control-request-method": "POST",
+		}, false, false, http.StatusMethodNotAllowed, ""},
+		{"preflight passed upstream", "OPTIONS", "https://upstream.example.com/api", preflight, false, true, 0, ""},
+		{"options without request method", "OPTIONS", "https://upstream.example.com/api", map[string]string{
+			"origin": "https://ui.example.com",
+		}, false, false, http.StatusFound, ""},
+		{"actual request unauthenticated", "POST", "https://app.example.com/api", map[string]string{
+			"origin": "https://ui.example.com",
+		}, false, false, http.StatusFound, ""},
+		{"actual request authorized", "POST", "https://app.example.com/api", map[string]string{
+			"origin": "https://ui.example.com",
+		}, true, true, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := *config.NewDefaultOptions()
+			opts.Policies = policies
+			opts.CookieName = "_pomerium"
+			opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+			opts.SharedKey = sharedKey
+			opts.CORSAllowedOrigins = []string{"https://ui.example.com"}
+			a, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			headers := map[string]string{"accept": "text/html"}
+			for k, v := range tt.headers {
+				headers[k] = v
+			}
+			if tt.withSession {
+				headers["cookie"] = "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour))
+			}
+			res, err := a.Check(context.TODO(), testCheckRequest(tt.method, tt.url, headers))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			var allowMethods string
+			for _, h := range res.GetDeniedResponse().GetHeaders() {
+				if h.GetHeader().GetKey() == "Access-Control-Allow-Methods" {
+					allowMethods = h.GetHeader().GetValue()
+				}
+			}
+			assert.Equal(t, tt.wantAllowMethods, allowMethods)
+		})
+	}
+}
+
+func TestAuthorize_Check_optionsRequests(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://authorize.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+		{From: "https://upstream.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, OptionsRequests: config.OptionsRequestsPassThrough},
+		{From: "https://respond.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, OptionsRequests: config.OptionsRequestsRespond},
+		{From: "https://methods.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, OptionsRequests: config.OptionsRequestsRespond, AllowedMethods: []string{"GET", "OPTIONS"}},
+		{From: "https://get.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, OptionsRequests: config.OptionsRequestsRespond, AllowedMethods: []string{"GET"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	preflight := map[string]string{
+		"origin":                        "https://ui.example.com",
+		"access-control-request-method": "GET",
+	}
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		headers     map[string]string
+		withSession bool
+		wantAllowed bool
+		wantCode    int
+		wantAllow   string
+	}{
+		{"authorize without session", "OPTIONS", "https://authorize.example.com/api", nil, false, false, http.StatusFound, ""},
+		{"authorize with session", "OPTIONS", "https://authorize.example.com/api", nil, true, true, 0, ""},
+		{"pass through", "OPTIONS", "https://upstream.example.com/api", nil, false, true, 0, ""},
+		{"pass through other methods authorized", "GET", "https://upstream.example.com/api", nil, false, false, http.StatusFound, ""},
+		{"respond", "OPTIONS", "https://respond.example.com/api", nil, false, false, http.StatusOK, "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS"},
+		{"respond with allowed methods", "OPTIONS", "https://methods.example.com/api", nil, false, false, http.StatusOK, "GET, OPTIONS"},
+		{"respond not allowed", "OPTIONS", "https://get.example.com/api", nil, false, false, http.StatusMethodNotAllowed, "GET"},
+		{"preflight answered", "OPTIONS", "https://upstream.example.com/api", preflight, false, false, http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := *config.NewDefaultOptions()
+			opts.Policies = policies
+			opts.CookieName = "_pomerium"
+			opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+			opts.SharedKey = sharedKey
+			opts.CORSAllowedOrigins = []string{"https://ui.example.com"}
+			a, err := New(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			headers := map[string]string{"accept": "text/html"}
+			for k, v := range tt.headers {
+				headers[k] = v
+			}
+			if tt.withSession {
+				headers["cookie"] = "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", mustParseURL(tt.url).Host, time.Now().Add(time.Hour))
+			}
+			res, err := a.Check(context.TODO(), testCheckRequest(tt.method, tt.url, headers))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			var allow string
+			for _, h := range res.GetDeniedResponse().GetHeaders() {
+				if h.GetHeader().GetKey() == "Allow" {
+					allow = h.GetHeader().GetValue()
+				}
+			}
+			assert.Equal(t, tt.wantAllow, allow)
+		})
+	}
+}
+
+func TestAuthorize_Check_expiredSessionGracePeriod(t *testing.T) {
+	// the authenticate service is unable to refresh sessions
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "refresh token revoked", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://dashboard.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:                   policies,
+		CookieName:                 "_pomerium",
+		AuthenticateURL:            mustParseURL(srv.URL),
+		SharedKey:                  sharedKey,
+		ExpiredSessionGracePeriod:  5 * time.Minute,
+		ExpiredSessionGraceMethods: []string{http.MethodGet, http.MethodHead},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		expiry      time.Time
+		wantAllowed bool
+		wantCode    int
+	}{
+		{"get within grace", http.MethodGet, time.Now().Add(-time.Minute), true, 0},
+		{"head within grace", http.MethodHead, time.Now().Add(-time.Minute), true, 0},
+		{"post within grace", http.MethodPost, time.Now().Add(-time.Minute), false, http.StatusFound},
+		{"get after grace", http.MethodGet, time.Now().Add(-10 * time.Minute), false, http.StatusFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "dashboard.example.com", tt.expiry)
+			res, err := a.Check(context.TODO(), testCheckRequest(tt.method, "https://dashboard.example.com/", map[string]string{
+				"accept": "text/html",
+				"cookie": "_pomerium=" + rawJWT,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+
+			var grace string
+			for _, h := range res.GetOkResponse().GetHeaders() {
+				if h.GetHeader().GetKey() == "X-Pomerium-Session-Grace" {
+					grace = h.GetHeader().GetValue()
+				}
+			}
+			if tt.wantAllowed {
+				remaining, err := time.ParseDuration(grace)
+				assert.NoError(t, err)
+				assert.InDelta(t, 4*time.Minute, remaining, float64(5*time.Second))
+			} else {
+				assert.Empty(t, grace)
+			}
+		})
+	}
+}
+
+func TestAuthorize_Check_headerOnlySession(t *testing.T) {
+	policies := []config.Policy{
+		{From: "https://api.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, SessionPreference: config.SessionPreferenceHeaderOnly},
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:        policies,
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       cryptutil.NewBase64Key(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host     string
+		wantCode int
+	}{
+		{"api.example.com", http.StatusUnauthorized},
+		{"app.example.com", http.StatusFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://"+tt.host+"/", map[string]string{
+				"accept": "text/html",
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_sessionAuthorizationSchemes(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{From: "https://api.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, SessionPreference: config.SessionPreferenceHeaderOnly}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "api.example.com", time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name          string
+		schemes       []string
+		authorization string
+		wantAllowed   bool
+	}{
+		{"pomerium by default", nil, "Pomerium " + rawJWT, true},
+		{"upper case pomerium by default", nil, "POMERIUM " + rawJWT, true},
+		{"bearer not accepted by default", nil, "Bearer " + rawJWT, false},
+		{"bearer", []string{"Bearer"}, "Bearer " + rawJWT, true},
+		{"lower case bearer", []string{"Bearer"}, "bearer " + rawJWT, true},
+		{"upper case bearer", []string{"Bearer"}, "BEARER " + rawJWT, true},
+		{"extra whitespace", []string{"Bearer"}, " Bearer \t " + rawJWT + "  ", true},
+		{"pomerium not configured", []string{"Bearer"}, "Pomerium " + rawJWT, false},
+		{"basic", []string{"Bearer"}, "Basic dXNlcjpwYXNz", false},
+		{"malformed", []string{"Bearer"}, "Bearer " + rawJWT + " " + rawJWT, false},
+		{"missing token", []string{"Bearer"}, "Bearer", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(config.Options{
+				Policies:                    []config.Policy{policy},
+				CookieName:                  "_pomerium",
+				AuthenticateURL:             mustParseURL("https://authN.example.com"),
+				SharedKey:                   sharedKey,
+				SessionAuthorizationSchemes: tt.schemes,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://api.example.com/", map[string]string{
+				"accept":        "application/json",
+				"authorization": tt.authorization,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			if !tt.wantAllowed {
+				assert.Equal(t, http.StatusUnauthorized, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			}
+		})
+	}
+}
+
+func TestAuthorize_Check_sessionSigningAlgorithm(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+		{From: "https://api.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, SessionPreference: config.SessionPreferenceHeaderOnly},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sign := func(t *testing.T, alg, host string) string {
+		return testSignedSessionJWT(t, alg, sharedKey, "bob@example.com", host, time.Now().Add(time.Hour))
+	}
+	// none keeps the otherwise valid payload and signature of a session
+	none := func(t *testing.T, host string) string {
+		parts := strings.Split(sign(t, "HS256", host), ".")
+		return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + "." + parts[2]
+	}
+
+	tests := []struct {
+		name        string
+		alg         string
+		host        string
+		session     string
+		wantAllowed bool
+	}{
+		{"expected", "", "app.example.com", sign(t, "HS256", "app.example.com"), true},
+		{"configured", "HS512", "app.example.com", sign(t, "HS512", "app.example.com"), true},
+		{"none", "", "app.example.com", none(t, "app.example.com"), false},
+		{"unexpected", "", "app.example.com", sign(t, "HS512", "app.example.com"), false},
+		{"downgrade", "HS512", "app.example.com", sign(t, "HS256", "app.example.com"), false},
+		{"header expected", "", "api.example.com", sign(t, "HS256", "api.example.com"), true},
+		{"header none", "", "api.example.com", none(t, "api.example.com"), false},
+		{"header downgrade", "HS512", "api.example.com", sign(t, "HS384", "api.example.com"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(config.Options{
+				Policies:                policies,
+				CookieName:              "_pomerium",
+				AuthenticateURL:         mustParseURL("https://authN.example.com"),
+				SharedKey:               sharedKey,
+				SessionSigningAlgorithm: tt.alg,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			headers := map[string]string{"accept": "application/json", "cookie": "_pomerium=" + tt.session}
+			if tt.host == "api.example.com" {
+				headers = map[string]string{"accept": "application/json", "authorization": "Pomerium " + tt.session}
+			}
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://"+tt.host+"/", headers))
+			if !assert.NoError(t, err) {
+				return
+			}
+			if !assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil) || tt.wantAllowed {
+				return
+			}
+			// rather than redirected to sign in, the session is denied
+			assert.Equal(t, http.StatusUnauthorized, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			assert.Contains(t, res.GetDeniedResponse().GetBody(), sessions.ErrUnexpectedAlgorithm.Error())
+			var clearsCookie bool
+			for _, hdr := range res.GetDeniedResponse().GetHeaders() {
+				if hdr.GetHeader().GetKey() == "Set-Cookie" && strings.HasPrefix(hdr.GetHeader().GetValue(), "_pomerium=;") {
+					clearsCookie = true
+				}
+			}
+			assert.True(t, clearsCookie, "session cookie isn't cleared")
+		})
+	}
+}
+
+func TestAuthorize_Check_compressSessions(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	groups := make([]string, 500)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("group-%d@example.com", i)
+	}
+
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compress=%v", compress), func(t *testing.T) {
+			a, err := New(config.Options{
+				Policies:                []config.Policy{policy},
+				CookieName:              "_pomerium",
+				AuthenticateURL:         mustParseURL("https://authN.example.com"),
+				SharedKey:               sharedKey,
+				SessionSigningAlgorithm: "HS384",
+				CompressSessions:        compress,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the session of a user in many groups is saved in the session
+			// cookie, as authenticate does
+			cookieStore, err := getCookieStore(a.currentOptions.Load(), a.currentEncoder.Load())
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Now()
+			w := httptest.NewRecorder()
+			if err := cookieStore.SaveSession(w, nil, &sessions.State{
+				Subject:   "bob@example.com",
+				Issuer:    "authN.example.com",
+				Audience:  jwt.Audience{"app.example.com"},
+				Expiry:    jwt.NewNumericDate(now.Add(time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(now),
+				NotBefore: jwt.NewNumericDate(now),
+				Email:     "bob@example.com",
+				Groups:    groups,
+			}); err != nil {
+				t.Fatal(err)
+			}
+			var cookies []string
+			for _, c := range w.Result().Cookies() {
+				cookies = append(cookies, c.Name+"="+c.Value)
+			}
+			if compress {
+				assert.Len(t, cookies, 1, "expected the compressed session to fit in a single cookie")
+			} else {
+				assert.Greater(t, len(cookies), 1, "expected the session to be chunked")
+			}
+
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": strings.Join(cookies, "; "),
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.NotNil(t, res.GetOkResponse())
+		})
+	}
+}
+
+func TestAuthorize_Check_maintenance(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	allowedUsers := []string{"bob@example.com", "admin@example.com"}
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: allowedUsers, Maintenance: true, MaintenanceMessage: "Back at 10:00 UTC"},
+		{From: "https://bypass.example.com", To: "http://localhost", AllowedUsers: allowedUsers, Maintenance: true, MaintenanceAllowAdministrators: true},
+		{From: "https://public.example.com", To: "http://localhost", AllowPublicUnauthenticatedAccess: true, Maintenance: true, MaintenanceAllowAdministrators: true},
+		{From: "https://other.example.com", To: "http://localhost", AllowedUsers: allowedUsers},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:        policies,
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+		Administrators:  []string{"admin@example.com"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		host        string
+		email       string
+		wantAllowed bool
+		wantCode    int
+		wantBody    string
+	}{
+		{"user", "app.example.com", "bob@example.com", false, http.StatusServiceUnavailable, "Back at 10:00 UTC"},
+		{"administrator without bypass", "app.example.com", "admin@example.com", false, http.StatusServiceUnavailable, "Back at 10:00 UTC"},
+		{"without session", "app.example.com", "", false, http.StatusServiceUnavailable, "Back at 10:00 UTC"},
+		{"administrator bypass", "bypass.example.com", "admin@example.com", true, 0, ""},
+		{"user with bypass", "bypass.example.com", "bob@example.com", false, http.StatusServiceUnavailable, defaultMaintenanceMessage},
+		{"sign in with bypass", "bypass.example.com", "", false, http.StatusFound, ""},
+		{"public with bypass", "public.example.com", "", false, http.StatusServiceUnavailable, defaultMaintenanceMessage},
+		{"other route", "other.example.com", "bob@example.com", true, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{"accept": "text/plain"}
+			if tt.email != "" {
+				headers["cookie"] = "_pomerium=" + testSessionJWT(t, sharedKey, tt.email, tt.host, time.Now().Add(time.Hour))
+			}
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://"+tt.host+"/", headers))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			if tt.wantBody != "" {
+				assert.Contains(t, res.GetDeniedResponse().GetBody(), tt.wantBody)
+			}
+		})
+	}
+}
+
+// unavailableRevocationStore is a revocation store whose backend is down.
+type unavailableRevocationStore struct {
+	revocation.Store
+}
+
+func (unavailableRevocationStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestAuthorize_Check_degradedRevocationStore(t *testing.T) {
+	view.Unregister(metrics.AuthorizeDegradedView)
+	if err := view.Register(metrics.AuthorizeDegradedView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(metrics.AuthorizeDegradedView)
+
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://dashboard.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	encoder, err := jws.NewHS256Signer([]byte(sharedKey), "authN.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		unavailable  bool
+		email        string
+		wantAllowed  bool
+		wantDegraded string
+	}{
+		{"store available", false, "bob@example.com", true, ""},
+		{"store unavailable", true, "bob@example.com", true, "revocation-store-unavailable"},
+		{"store unavailable, denied", true, "alice@example.com", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(config.Options{
+				Policies:        policies,
+				CookieName:      "_pomerium",
+				AuthenticateURL: mustParseURL("https://authN.example.com"),
+				SharedKey:       sharedKey,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.unavailable {
+				a.revocations.Store(unavailableRevocationStore{a.revocations.Load()})
+			}
+			rawJWT, err := encoder.Marshal(&sessions.State{
+				Issuer:    "authN.example.com",
+				Audience:  jwt.Audience{"dashboard.example.com"},
+				Expiry:    jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				NotBefore: jwt.NewNumericDate(time.Now()),
+				ID:        cryptutil.NewRandomStringN(16),
+				Email:     tt.email,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			before := degradedCount(t)
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://dashboard.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + string(rawJWT),
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			if !assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil) {
+				return
+			}
+			var degraded string
+			for _, hvo := range res.GetOkResponse().GetHeaders() {
+				if hvo.GetHeader().GetKey() == "X-Pomerium-Degraded" {
+					degraded = hvo.GetHeader().GetValue()
+				}
+			}
+			assert.Equal(t, tt.wantDegraded, degraded)
+			want := before
+			if tt.wantDegraded != "" {
+				want++
+			}
+			assert.Equal(t, want, degradedCount(t))
+		})
+	}
+}
+
+// degradedCount returns the number of requests recorded as allowed in a
+// degraded mode.
+func degradedCount(t *testing.T) int64 {
+	t.Helper()
+	rows, err := view.RetrieveData(metrics.AuthorizeDegradedView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	for _, row := range rows {
+		n += row.Data.(*view.CountData).Value
+	}
+	return n
+}
+
+func TestAuthorize_Check_revokedSession(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://dashboard.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:        policies,
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder, err := jws.NewHS256Signer([]byte(sharedKey), "authN.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedID := cryptutil.NewRandomStringN(16)
+	if err := a.revocations.Load().Revoke(context.TODO(), revokedID, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		id          string
+		wantAllowed bool
+		wantCode    int
+	}{
+		{"revoked", revokedID, false, http.StatusFound},
+		{"not revoked", cryptutil.NewRandomStringN(16), true, 0},
+		{"no id", "", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT, err := encoder.Marshal(&sessions.State{
+				Issuer:    "authN.example.com",
+				Audience:  jwt.Audience{"dashboard.example.com"},
+				Expiry:    jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				NotBefore: jwt.NewNumericDate(time.Now()),
+				ID:        tt.id,
+				Email:     "bob@example.com",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://dashboard.example.com/", map[string]string{
+				"accept": "text/html",
+				"cookie": "_pomerium=" + string(rawJWT),
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+		})
+	}
+}
+
+func TestAuthorize_Check_jwtAssertionHeader(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://default.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+		{From: "https://bearer.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"},
+			JWTAssertionHeader: "Authorization", JWTAssertionFormat: config.JWTAssertionFormatBearer},
+		{From: "https://custom.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"},
+			JWTAssertionHeader: "X-Upstream-Jwt"},
+		{From: "https://none.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"},
+			JWTAssertionFormat: config.JWTAssertionFormatNone},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:        policies,
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host       string
+		wantHeader string
+		wantPrefix string
+	}{
+		{"default.example.com", "x-pomerium-jwt-assertion", ""},
+		{"bearer.example.com", "Authorization", "Bearer "},
+		{"custom.example.com", "X-Upstream-Jwt", ""},
+		{"none.example.com", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", tt.host, time.Now().Add(time.Hour))
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://"+tt.host+"/", map[string]string{
+				"cookie": "_pomerium=" + rawJWT,
+			}))
+			if !assert.NoError(t, err) || !assert.NotNil(t, res.GetOkResponse()) {
+				return
+			}
+
+			var got []string
+			for _, hvo := range res.GetOkResponse().GetHeaders() {
+				switch strings.ToLower(hvo.GetHeader().GetKey()) {
+				case "x-pomerium-jwt-assertion", "authorization", "x-upstream-jwt":
+					assert.Equal(t, tt.wantHeader, hvo.GetHeader().GetKey())
+					assert.True(t, strings.HasPrefix(hvo.GetHeader().GetValue(), tt.wantPrefix))
+					assert.Len(t, strings.Split(strings.TrimPrefix(hvo.GetHeader().GetValue(), tt.wantPrefix), "."), 3)
+					got = append(got, hvo.GetHeader().GetKey())
+				}
+			}
+			if tt.wantHeader == "" {
+				assert.Empty(t, got)
+			} else {
+				assert.Len(t, got, 1)
+			}
+		})
+	}
+}
+
+func TestAuthorize_Check_problemDetails(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://api.example.com", To: "http://localhost", AllowedUsers: []string{"alice@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+	}{
+		{"json", "application/json", "application/problem+json"},
+		{"problem json", "application/problem+json", "application/problem+json"},
+		{"plain text", "*/*", "text/plain"},
+		{"html", "text/html,application/json", "text/html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "api.example.com", time.Now().Add(time.Hour))
+			in := testCheckRequest("GET", "https://api.example.com/v1/items", map[string]string{
+				"accept": tt.accept,
+				"cookie": "_pomerium=" + rawJWT,
+			})
+			in.Attributes.Request.Http.Id = "request-1234"
+			res, err := a.Check(context.TODO(), in)
+			if !assert.NoError(t, err) {
+				return
+			}
+			if !assert.Equal(t, http.StatusForbidden, int(res.GetDeniedResponse().GetStatus().GetCode())) {
+				return
+			}
+			var contentType string
+			for _, h := range res.GetDeniedResponse().GetHeaders() {
+				if h.GetHeader().GetKey() == "Content-Type" {
+					contentType = h.GetHeader().GetValue()
+				}
+			}
+			assert.Equal(t, tt.wantContentType, contentType)
+			if tt.wantContentType != "application/problem+json" {
+				return
+			}
+			var problem map[string]interface{}
+			if err := json.Unmarshal([]byte(res.GetDeniedResponse().GetBody()), &problem); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, map[string]interface{}{
+				"type":     "about:blank",
+				"title":    "Forbidden",
+				"status":   float64(http.StatusForbidden),
+				"instance": "request-1234",
+			}, problem)
+		})
+	}
+
+	t.Run("detail", func(t *testing.T) {
+		in := testCheckRequest("GET", "https:///v1/items", map[string]string{
+			"accept":       "application/json",
+			"x-request-id": "request-5678",
+		})
+		res, err := a.Check(context.TODO(), in)
+		if !assert.NoError(t, err) {
+			return
+		}
+		var problem problemDetails
+		if err := json.Unmarshal([]byte(res.GetDeniedResponse().GetBody()), &problem); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, problemDetails{
+			Type:     "about:blank",
+			Title:    "Bad Request",
+			Status:   http.StatusBadRequest,
+			Detail:   "request is missing a host header",
+			Instance: "request-5678",
+		}, problem)
+	})
+}
+
+func TestAuthorize_Check_supportURL(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"alice@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	opts.SupportURL = mustParseURL("https://support.example.com/tickets/new")
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(t *testing.T, accept string) string {
+		t.Helper()
+		in := testCheckRequest("GET", "https://app.example.com/", map[string]string{
+			"accept": accept,
+			"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour)),
+		})
+		in.Attributes.Request.Http.Id = "request-1234"
+		res, err := a.Check(context.TODO(), in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Equal(t, http.StatusForbidden, int(res.GetDeniedResponse().GetStatus().GetCode())) {
+			t.FailNow()
+		}
+		return res.GetDeniedResponse().GetBody()
+	}
+
+	t.Run("html", func(t *testing.T) {
+		body := check(t, "text/html")
+		assert.Contains(t, body, `<a href="https://support.example.com/tickets/new">Contact support</a>`)
+		assert.Contains(t, body, `<span class="text-monospace">request-1234</span>`)
+	})
+	t.Run("json", func(t *testing.T) {
+		var problem problemDetails
+		if err := json.Unmarshal([]byte(check(t, "application/json")), &problem); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, "https://support.example.com/tickets/new", problem.SupportURL)
+		assert.Equal(t, "request-1234", problem.Instance)
+	})
+}
+
+func TestAuthorize_Check_wwwAuthenticate(t *testing.T) {
+	policies := []config.Policy{
+		{From: "https://api.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, SessionPreference: config.SessionPreferenceHeaderOnly},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		realm   string
+		schemes []string
+		want    []string
+	}{
+		{"default", "", nil, []string{`Bearer realm="pomerium", sign_in_url=`}},
+		{"custom", `corp "internal"`, []string{"Pomerium", "Bearer"}, []string{
+			`Pomerium realm="corp \"internal\""`,
+			`Bearer realm="corp \"internal\"", sign_in_url=`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(config.Options{
+				Policies:               policies,
+				CookieName:             "_pomerium",
+				AuthenticateURL:        mustParseURL("https://authN.example.com"),
+				SharedKey:              cryptutil.NewBase64Key(),
+				WWWAuthenticateRealm:   tt.realm,
+				WWWAuthenticateSchemes: tt.schemes,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://api.example.com/", nil))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, http.StatusUnauthorized, int(res.GetDeniedResponse().GetStatus().GetCode()))
+
+			var got []string
+			for _, hvo := range res.GetDeniedResponse().GetHeaders() {
+				if hvo.GetHeader().GetKey() == "Www-Authenticate" {
+					got = append(got, hvo.GetHeader().GetValue())
+				}
+			}
+			if !assert.Len(t, got, len(tt.want)) {
+				return
+			}
+			for i := range tt.want {
+				assert.True(t, strings.HasPrefix(got[i], tt.want[i]), "got %q, want prefix %q", got[i], tt.want[i])
+				if strings.HasPrefix(got[i], "Bearer") {
+					assert.Contains(t, got[i], `sign_in_url="https://authN.example.com/.pomerium/sign_in?`)
+				}
+			}
+		})
+	}
+}
+
+func Test_normalizeHeaders(t *testing.T) {
+	appended := func(k, v string) *envoy_api_v2_core.HeaderValueOption {
+		hvo := mkHeader(k, v)
+		hvo.Append = &wrappers.BoolValue{Value: true}
+		return hvo
+	}
+	type header struct {
+		Key    string
+		Value  string
+		Append bool
+	}
+	flatten := func(hvos []*envoy_api_v2_core.HeaderValueOption) []header {
+		var hdrs []header
+		for _, hvo := range hvos {
+			hdrs = append(hdrs, header{hvo.GetHeader().GetKey(), hvo.GetHeader().GetValue(), hvo.GetAppend().GetValue()})
+		}
+		return hdrs
+	}
+
+	got := normalizeHeaders([]*envoy_api_v2_core.HeaderValueOption{
+		mkHeader("X-Pomerium-Jwt-Assertion", "old"),
+		mkHeader("x-pomerium-claim-email", "bob@example.com"),
+		mkHeader("X-Pomerium-Warning", "policy deprecated"),
+		appended("X-Pomerium-Warning", "access will expire soon"),
+		appended("X-Pomerium-Warning", "policy deprecated"),
+		mkHeader("x-pomerium-jwt-assertion", "new"),
+		mkHeader("X-Pomerium-Decision-Time", "1ms"),
+	})
+	assert.Equal(t, []header{
+		{"x-pomerium-claim-email", "bob@example.com", false},
+		{"X-Pomerium-Decision-Time", "1ms", false},
+		{"x-pomerium-jwt-assertion", "new", false},
+		{"X-Pomerium-Warning", "policy deprecated", false},
+		{"X-Pomerium-Warning", "access will expire soon", true},
+	}, flatten(got))
+
+	// the output is stable regardless of the order of independent injectors
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, flatten(got), flatten(normalizeHeaders(got)))
+	}
+}
+
+func TestAuthorize_okResponse_headers(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	a, err := New(config.Options{
+		CookieName:       "_pomerium",
+		AuthenticateURL:  mustParseURL("https://authN.example.com"),
+		SharedKey:        sharedKey,
+		JWTClaimsHeaders: []string{"email", "sub", "aud", "iss"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawJWT := []byte(testSessionJWT(t, sharedKey, "bob@example.com", "example.com", time.Now().Add(time.Hour)))
+	reply := &authorize.IsAuthorizedReply{Allow: true, SignedJwt: "signed", Warnings: []string{"policy deprecated"}}
+
+	var want []string
+	for i := 0; i < 10; i++ {
+		res := a.okResponse(reply, nil, rawJWT, true,
+			http.Header{"X-Pomerium-Warning": {"policy deprecated"}},
+			http.Header{"X-Pomerium-Jwt-Assertion": {"signed"}},
+		)
+		var got []string
+		for _, hvo := range res.GetOkResponse().GetHeaders() {
+			got = append(got, hvo.GetHeader().GetKey()+": "+hvo.GetHeader().GetValue())
+		}
+		if want == nil {
+			want = got
+		}
+		assert.Equal(t, want, got)
+	}
+	assert.Contains(t, want, "X-Pomerium-Warning: policy deprecated")
+	assert.Contains(t, want, "X-Pomerium-Jwt-Assertion: signed")
+	assert.Len(t, want, 7)
+}
+
+func TestAuthorize_okResponse_headerTransforms(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	a, err := New(config.Options{
+		CookieName:       "_pomerium",
+		AuthenticateURL:  mustParseURL("https://authN.example.com"),
+		SharedKey:        sharedKey,
+		JWTClaimsHeaders: []string{"email"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawJWT := []byte(testSessionJWT(t, sharedKey, "bob@example.com", "example.com", time.Now().Add(time.Hour)))
+	reply := &authorize.IsAuthorizedReply{Allow: true, SignedJwt: "signed"}
+
+	tests := []struct {
+		name       string
+		transforms []config.HeaderTransform
+		want       map[string]string
+		wantAbsent []string
+	}{
+		{"rename",
+			[]config.HeaderTransform{{Rename: "X-Pomerium-Claim-Email", To: "X-User-Email"}},
+			map[string]string{"X-User-Email": "bob@example.com"},
+			[]string{"x-pomerium-claim-email"}},
+		{"rename missing header",
+			[]config.HeaderTransform{{Rename: "X-Pomerium-Claim-Name", To: "X-User-Name"}},
+			map[string]string{"X-User-Name": "", "x-pomerium-claim-email": "bob@example.com"},
+			nil},
+		{"add",
+			[]config.HeaderTransform{{Add: "X-Team", Value: "platform"}},
+			map[string]string{"X-Team": "platform", "x-pomerium-claim-email": "bob@example.com"},
+			nil},
+		{"add replaces",
+			[]config.HeaderTransform{{Add: "X-Pomerium-Jwt-Assertion", Value: "replaced"}},
+			map[string]string{"X-Pomerium-Jwt-Assertion": "replaced"},
+			[]string{"x-pomerium-jwt-assertion"}},
+		{"remove",
+			[]config.HeaderTransform{{Remove: "X-Pomerium-Jwt-Assertion"}},
+			map[string]string{"x-pomerium-claim-email": "bob@example.com"},
+			[]string{"x-pomerium-jwt-assertion", "X-Pomerium-Jwt-Assertion"}},
+		{"in order",
+			[]config.HeaderTransform{
+				{Rename: "X-Pomerium-Claim-Email", To: "X-User-Email"},
+				{Rename: "X-User-Email", To: "X-Email"},
+				{Add: "X-User-Email", Value: "other"},
+			},
+			map[string]string{"X-Email": "bob@example.com", "X-User-Email": "other"},
+			[]string{"x-pomerium-claim-email"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := &config.Policy{From: "https://example.com", To: "http://localhost", HeaderTransforms: tt.transforms}
+			res := a.okResponse(reply, policy, rawJWT, false)
+			got := make(map[string]string)
+			for _, hvo := range res.GetOkResponse().GetHeaders() {
+				got[hvo.GetHeader().GetKey()] = hvo.GetHeader().GetValue()
+			}
+			for k, v := range tt.want {
+				if assert.Contains(t, got, k) {
+					assert.Equal(t, v, got[k], k)
+				}
+			}
+			for _, k := range tt.wantAbsent {
+				assert.NotContains(t, got, k)
+			}
+		})
+	}
+}
+
+func Test_sanitizeHeaders(t *testing.T) {
+	got := sanitizeHeaders([]*envoy_api_v2_core.HeaderValueOption{
+		mkHeader("x-pomerium-claim-email", "bob@example.com"),
+		mkHeader("x-pomerium-claim-name", "Bob\r\nX-Injected: true"),
+		mkHeader("x-pomerium-claim-family_name", "Müller"),
+		mkHeader("x-pomerium-claim-bad name", "bob"),
+		mkHeader("x-pomerium-claim-tabbed", "a\tb"),
+	})
+	var hdrs []string
+	for _, hvo := range got {
+		hdrs = append(hdrs, hvo.GetHeader().GetKey()+": "+hvo.GetHeader().GetValue())
+	}
+	assert.Equal(t, []string{
+		"x-pomerium-claim-email: bob@example.com",
+		"x-pomerium-claim-name: =?utf-8?b?Qm9iDQpYLUluamVjdGVkOiB0cnVl?=",
+		"x-pomerium-claim-family_name: =?utf-8?b?TcO8bGxlcg==?=",
+		"x-pomerium-claim-tabbed: a\tb",
+	}, hdrs)
+}
+
+func TestAuthorize_okResponse_claimHeaderInjection(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	a, err := New(config.Options{
+		CookieName:       "_pomerium",
+		AuthenticateURL:  mustParseURL("https://authN.example.com"),
+		SharedKey:        sharedKey,
+		JWTClaimsHeaders: []string{"email", "name"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder, err := jws.NewHS256Signer([]byte(sharedKey), "authN.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawJWT, err := encoder.Marshal(map[string]interface{}{
+		"email": "bob@example.com",
+		"name":  "Bob\r\nX-Pomerium-Jwt-Assertion: forged",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := a.okResponse(&authorize.IsAuthorizedReply{Allow: true, SignedJwt: "signed"}, nil, rawJWT, false)
+	got := make(map[string][]string)
+	for _, hvo := range res.GetOkResponse().GetHeaders() {
+		assert.NotContains(t, hvo.GetHeader().GetValue(), "\r")
+		assert.NotContains(t, hvo.GetHeader().GetValue(), "\n")
+		got[hvo.GetHeader().GetKey()] = append(got[hvo.GetHeader().GetKey()], hvo.GetHeader().GetValue())
+	}
+	assert.Equal(t, []string{"signed"}, got["x-pomerium-jwt-assertion"])
+	if assert.Len(t, got["x-pomerium-claim-name"], 1) {
+		name, err := new(mime.WordDecoder).DecodeHeader(got["x-pomerium-claim-name"][0])
+		assert.NoError(t, err)
+		assert.Equal(t, "Bob\r\nX-Pomerium-Jwt-Assertion: forged", name)
+	}
+}
+
+func TestAuthorize_Check_cookieLegacyNames(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(config.Options{
+		Policies:          []config.Policy{policy},
+		CookieName:        "_pomerium",
+		CookieLegacyNames: []string{"_old", "_older"},
+		AuthenticateURL:   mustParseURL("https://authN.example.com"),
+		SharedKey:         sharedKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawJWT := testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour))
+	otherJWT := testSessionJWT(t, sharedKey, "alice@example.com", "app.example.com", time.Now().Add(time.Hour))
+
+	tests := []struct {
+		name         string
+		cookie       string
+		wantAllowed  bool
+		wantReissued bool
+	}{
+		{"current name", "_pomerium=" + rawJWT, true, false},
+		{"legacy name", "_old=" + rawJWT, true, true},
+		{"older legacy name", "_older=" + rawJWT, true, true},
+		{"legacy names in order", "_older=" + otherJWT + "; _old=" + rawJWT, true, true},
+		{"current name preferred", "_old=" + otherJWT + "; _pomerium=" + rawJWT, true, false},
+		{"unknown name", "_other=" + rawJWT, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": tt.cookie,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			if !tt.wantAllowed {
+				assert.Nil(t, res.GetOkResponse())
+				return
+			}
+			if !assert.Equal(t, int32(codes.OK), res.GetStatus().GetCode()) {
+				return
+			}
+			var setCookie string
+			for _, hvo := range res.GetOkResponse().GetHeaders() {
+				if strings.EqualFold(hvo.GetHeader().GetKey(), "x-pomerium-set-cookie") {
+					setCookie = hvo.GetHeader().GetValue()
+				}
+			}
+			if !tt.wantReissued {
+				assert.Empty(t, setCookie)
+				return
+			}
+			assert.True(t, strings.HasPrefix(setCookie, "_pomerium="+rawJWT+";"), "session isn't re-issued under the cookie name: %s", setCookie)
+		})
+	}
+}
+
+func TestAuthorize_Check_routeSharedKeys(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	tenantAKey := cryptutil.NewBase64Key()
+	tenantBKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://a.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, SharedKeyRef: "tenant-a"},
+		{From: "https://b.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, SharedKeyRef: "tenant-b"},
+		{From: "https://shared.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:          policies,
+		CookieName:        "_pomerium",
+		CookieLegacyNames: []string{"_old"},
+		AuthenticateURL:   mustParseURL("https://authN.example.com"),
+		SharedKey:         sharedKey,
+		SharedKeys:        map[string]string{"tenant-a": tenantAKey, "tenant-b": tenantBKey},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		host        string
+		key         string
+		wantAllowed bool
+	}{
+		{"tenant a's key on tenant a's route", "a.example.com", tenantAKey, true},
+		{"tenant a's key on tenant b's route", "b.example.com", tenantAKey, false},
+		{"shared key on tenant a's route", "a.example.com", sharedKey, false},
+		{"tenant a's key on a shared route", "shared.example.com", tenantAKey, false},
+		{"shared key on a shared route", "shared.example.com", sharedKey, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawJWT := testSessionJWT(t, tt.key, "bob@example.com", tt.host, time.Now().Add(time.Hour))
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://"+tt.host+"/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + rawJWT,
+			}))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+		})
+	}
+
+	t.Run("re-issued with the route's key", func(t *testing.T) {
+		rawJWT := testSessionJWT(t, tenantAKey, "bob@example.com", "a.example.com", time.Now().Add(time.Hour))
+		res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://a.example.com/", map[string]string{
+			"accept": "application/json",
+			"cookie": "_old=" + rawJWT,
+		}))
+		if !assert.NoError(t, err) || !assert.NotNil(t, res.GetOkResponse()) {
+			return
+		}
+		var setCookie string
+		for _, hvo := range res.GetOkResponse().GetHeaders() {
+			if strings.EqualFold(hvo.GetHeader().GetKey(), "x-pomerium-set-cookie") {
+				setCookie = hvo.GetHeader().GetValue()
+			}
+		}
+		cookieJWT := strings.TrimPrefix(strings.SplitN(setCookie, ";", 2)[0], "_pomerium=")
+		tenantEncoder, err := jws.NewHS256Signer([]byte(tenantAKey), "authN.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var state sessions.State
+		if assert.NoError(t, tenantEncoder.Unmarshal([]byte(cookieJWT), &state)) {
+			assert.Equal(t, "bob@example.com", state.Email)
+		}
+	})
+}
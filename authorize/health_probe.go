@@ -0,0 +1,64 @@
+package authorize
+
+import (
+	"net"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+
+	"github.com/pomerium/pomerium/config"
+)
+
+// healthProbe is a parsed config.HealthProbe.
+type healthProbe struct {
+	sources []*net.IPNet
+	paths   []string
+}
+
+func newHealthProbes(opts *config.Options) ([]healthProbe, error) {
+	var probes []healthProbe
+	for i := range opts.HealthProbes {
+		sources, err := opts.HealthProbes[i].GetSourceNetworks()
+		if err != nil {
+			return nil, err
+		}
+		probes = append(probes, healthProbe{sources: sources, paths: opts.HealthProbes[i].Paths})
+	}
+	return probes, nil
+}
+
+// isHealthProbe returns true if the request is from a health probe source,
+// for one of its paths. Paths must match exactly, and paths with dot segments
+// or repeated slashes never match, so that they can't be used to reach
+// another path of the route. Requests whose client IP is unknown only match
+// if they're allowed past IP rules, and then only probes with paths.
+func (a *Authorize) isHealthProbe(in *envoy_service_auth_v2.CheckRequest) bool {
+	state := a.currentOptions.LoadState()
+	if len(state.healthProbes) == 0 {
+		return false
+	}
+	clientIP, _ := getClientAddr(in, state.trustedProxies)
+	ip := net.ParseIP(clientIP)
+	unknownAllowed := ip == nil && state.options.UnknownClientIP == config.UnknownClientIPAllow
+	if ip == nil && !unknownAllowed {
+		return false
+	}
+	path := a.getPolicyRequestURL(in).Path
+	if normalizeRequestPath(path) != path {
+		return false
+	}
+	for _, probe := range state.healthProbes {
+		if unknownAllowed {
+			if len(probe.paths) > 0 && containsString(probe.paths, path) {
+				return true
+			}
+			continue
+		}
+		if !isTrustedProxy(ip, probe.sources) {
+			continue
+		}
+		if len(probe.paths) == 0 || containsString(probe.paths, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package authorize
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+)
+
+func TestAuthorize_Check_healthProbes(t *testing.T) {
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(config.Options{
+		Policies:        []config.Policy{policy},
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       cryptutil.NewBase64Key(),
+		TrustedProxies:  []string{"192.168.0.1"},
+		HealthProbes: []config.HealthProbe{
+			{Sources: []string{"10.0.0.0/24"}, Paths: []string{"/healthz", "/ready"}},
+			{Sources: []string{"2001:db8::1"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		ip          string
+		path        string
+		headers     map[string]string
+		wantAllowed bool
+	}{
+		{"exempt probe", "10.0.0.5", "/healthz", nil, true},
+		{"exempt probe, other health path", "10.0.0.5", "/ready", nil, true},
+		{"exempt probe with query", "10.0.0.5", "/healthz?full=1", nil, true},
+		{"non-exempt ip", "10.0.1.5", "/healthz", nil, false},
+		{"exempt probe, other path", "10.0.0.5", "/admin", nil, false},
+		{"exempt probe, path prefix", "10.0.0.5", "/healthz/admin", nil, false},
+		{"exempt probe, dot segments", "10.0.0.5", "/healthz/../admin", nil, false},
+		{"exempt probe, any path", "2001:db8::1", "/admin", nil, true},
+		{"exempt probe behind trusted proxy", "192.168.0.1", "/healthz", map[string]string{"x-forwarded-for": "10.0.0.5"}, true},
+		{"forwarded by untrusted proxy", "192.168.0.2", "/healthz", map[string]string{"x-forwarded-for": "10.0.0.5"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := testCheckRequest("GET", "https://app.example.com"+tt.path, tt.headers)
+			in.Attributes.Source = &envoy_service_auth_v2.AttributeContext_Peer{
+				Address: &envoy_api_v2_core.Address{
+					Address: &envoy_api_v2_core.Address_SocketAddress{
+						SocketAddress: &envoy_api_v2_core.SocketAddress{Address: tt.ip},
+					},
+				},
+			}
+			res, err := a.Check(context.TODO(), in)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.wantAllowed, res.GetOkResponse() != nil)
+			if !tt.wantAllowed {
+				assert.Equal(t, http.StatusFound, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package authorize
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+	"github.com/pomerium/pomerium/internal/telemetry/requestid"
+)
+
+// shouldLogAuthorizeCheck reports whether the authorize check is logged.
+// Denied requests are always logged, and allowed requests are sampled at the
+// rate.
+func shouldLogAuthorizeCheck(ctx context.Context, in *envoy_service_auth_v2.CheckRequest, reply *authorize.IsAuthorizedReply, rate float64) bool {
+	if !reply.GetAllow() {
+		return true
+	}
+	// envoy's request id is shared by the request's spans in every service
+	id := in.GetAttributes().GetRequest().GetHttp().GetId()
+	if id == "" {
+		id = requestid.FromContext(ctx)
+	}
+	return isSampled(id, rate)
+}
+
+// isSampled reports whether the request with the id is sampled at the rate.
+// The decision is deterministic for an id, so that the logs of a request are
+// sampled together.
+func isSampled(id string, rate float64) bool {
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	}
+	h := sha256.Sum256([]byte(id))
+	// the top 53 bits of the hash, as a fraction in [0, 1)
+	return float64(binary.BigEndian.Uint64(h[:8])>>11)/(1<<53) < rate
+}
@@ -0,0 +1,84 @@
+package authorize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+func Test_isSampled(t *testing.T) {
+	for _, rate := range []float64{0, 0.01, 0.1, 0.5, 1} {
+		const n = 20000
+		sampled := 0
+		for i := 0; i < n; i++ {
+			id := fmt.Sprintf("request-%d", i)
+			if isSampled(id, rate) {
+				sampled++
+			}
+			// related logs are sampled together
+			if isSampled(id, rate) != isSampled(id, rate) {
+				t.Fatalf("isSampled(%q, %v) isn't deterministic", id, rate)
+			}
+		}
+		if got := float64(sampled) / n; math.Abs(got-rate) > 0.01 {
+			t.Errorf("isSampled() sampled %v of requests, want %v", got, rate)
+		}
+	}
+}
+
+func TestAuthorize_Check_logSampling(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&buf)
+
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	opts.AuthorizeLogSampleRate = 0
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		email   string
+		wantLog bool
+	}{
+		{"bob@example.com", false},
+		{"alice@example.com", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email, func(t *testing.T) {
+			buf.Reset()
+			_, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, tt.email, "app.example.com", time.Now().Add(time.Hour)),
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.wantLog, strings.Contains(buf.String(), `"message":"authorize check"`))
+		})
+	}
+}
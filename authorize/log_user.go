@@ -0,0 +1,62 @@
+package authorize
+
+import (
+	"encoding/hex"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+)
+
+// logUserHashSalt salts the hashes of users logged without a configured salt,
+// so that they're consistent for the life of the process.
+var logUserHashSalt = cryptutil.NewBase64Key()
+
+// getLogUser returns a user identifier, e.g. an email, as it's logged: as is,
+// or if enabled a salted hash of it, which can still be correlated across
+// records without revealing the user.
+func (a *Authorize) getLogUser(user string) string {
+	opts := a.currentOptions.Load()
+	if !opts.HashLogUsers || user == "" {
+		return user
+	}
+	salt := opts.LogUserHashSalt
+	if salt == "" {
+		salt = logUserHashSalt
+	}
+	return hashLogUser(salt, user)
+}
+
+// hashLogUser returns the salted hash of a user identifier.
+func hashLogUser(salt, user string) string {
+	return hex.EncodeToString(cryptutil.GenerateHMAC([]byte(user), salt)[:16])
+}
+
+// getLogHeaders returns the request headers without those carrying
+// credentials, e.g. the session cookie, as they're left out of audit logs.
+func getLogHeaders(hdrs map[string][]string, reservedHeaderPrefix string) map[string][]string {
+	logHdrs := make(map[string][]string, len(hdrs))
+	for k, vs := range hdrs {
+		if !isDecisionLogSensitiveHeader(k, reservedHeaderPrefix) {
+			logHdrs[k] = vs
+		}
+	}
+	return logHdrs
+}
+
+// getLogReply returns the reply as it's logged, with its user identifiers
+// hashed if enabled.
+func (a *Authorize) getLogReply(reply *authorize.IsAuthorizedReply) *authorize.IsAuthorizedReply {
+	if !a.currentOptions.Load().HashLogUsers {
+		return reply
+	}
+	logReply, ok := proto.Clone(reply).(*authorize.IsAuthorizedReply)
+	if !ok {
+		return &authorize.IsAuthorizedReply{Allow: reply.GetAllow()}
+	}
+	logReply.Email = a.getLogUser(reply.GetEmail())
+	logReply.User = a.getLogUser(reply.GetUser())
+	logReply.SignedJwt = ""
+	return logReply
+}
@@ -0,0 +1,136 @@
+package authorize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+func TestHashLogUser(t *testing.T) {
+	assert.Equal(t, hashLogUser("salt", "bob@example.com"), hashLogUser("salt", "bob@example.com"))
+	assert.NotEqual(t, hashLogUser("salt", "bob@example.com"), hashLogUser("salt", "alice@example.com"))
+	assert.NotEqual(t, hashLogUser("salt", "bob@example.com"), hashLogUser("pepper", "bob@example.com"))
+	assert.Len(t, hashLogUser("salt", "bob@example.com"), 32)
+}
+
+func TestAuthorize_getLogUser(t *testing.T) {
+	a, err := New(config.Options{
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       cryptutil.NewBase64Key(),
+		HashLogUsers:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// without a salt, the same user has the same hash for the life of the
+	// process
+	assert.Equal(t, hashLogUser(logUserHashSalt, "bob@example.com"), a.getLogUser("bob@example.com"))
+	assert.Equal(t, a.getLogUser("bob@example.com"), a.getLogUser("bob@example.com"))
+	assert.Empty(t, a.getLogUser(""))
+
+	opts := a.currentOptions.Load()
+	opts.LogUserHashSalt = "salt"
+	a.currentOptions.Store(opts)
+	assert.Equal(t, hashLogUser("salt", "bob@example.com"), a.getLogUser("bob@example.com"))
+
+	opts.HashLogUsers = false
+	a.currentOptions.Store(opts)
+	assert.Equal(t, "bob@example.com", a.getLogUser("bob@example.com"))
+}
+
+func TestAuthorize_Check_hashLogUsers(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&buf)
+
+	dir, err := ioutil.TempDir("", "pomerium-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(config.Options{
+		Policies:               []config.Policy{policy},
+		CookieName:             "_pomerium",
+		AuthenticateURL:        mustParseURL("https://authN.example.com"),
+		SharedKey:              sharedKey,
+		AuthorizeLogSampleRate: 1,
+		AuditLogFile:           filepath.Join(dir, "audit.log"),
+		SecurityEvents:         true,
+		HashLogUsers:           true,
+		LogUserHashSalt:        "salt",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.auditLog.Load().Close()
+
+	var rawJWTs []string
+	for _, email := range []string{"bob@example.com", "bob@example.com", "alice@example.com"} {
+		rawJWT := testSessionJWT(t, sharedKey, email, "app.example.com", time.Now().Add(time.Hour))
+		rawJWTs = append(rawJWTs, rawJWT)
+		if _, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+			"accept":        "text/html",
+			"cookie":        "_pomerium=" + rawJWT,
+			"authorization": "Pomerium " + rawJWT,
+		})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.auditLog.Load().Close(); err != nil {
+		t.Fatal(err)
+	}
+	audit, err := ioutil.ReadFile(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, logs := range map[string]string{"authorize log": buf.String(), "audit log": string(audit)} {
+		assert.NotContains(t, logs, "bob@example.com", name)
+		assert.NotContains(t, logs, "alice@example.com", name)
+		assert.Equal(t, 2, strings.Count(logs, hashLogUser("salt", "bob@example.com")), name)
+		assert.Contains(t, logs, hashLogUser("salt", "alice@example.com"), name)
+		// the sessions, which have the users' emails, aren't logged in any
+		// of the headers they're sent in
+		for _, rawJWT := range rawJWTs {
+			assert.NotContains(t, logs, rawJWT, name)
+		}
+	}
+	checks := 0
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry struct {
+			Message string              `json:"message"`
+			Headers map[string][]string `json:"headers"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Message != "authorize check" {
+			continue
+		}
+		checks++
+		assert.Equal(t, map[string][]string{"Accept": {"text/html"}}, entry.Headers)
+	}
+	assert.Equal(t, 3, checks)
+	// alice's denial is a security event
+	assert.Contains(t, buf.String(), `"stream":"security"`)
+}
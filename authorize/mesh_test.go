@@ -0,0 +1,139 @@
+package authorize
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/httputil"
+)
+
+func TestAuthorize_Check_trustedMesh(t *testing.T) {
+	ca, caKey := testCertificateAuthority(t)
+	otherCA, otherCAKey := testCertificateAuthority(t)
+
+	billing, reports := "spiffe://cluster.local/ns/default/sa/billing", "spiffe://cluster.local/ns/default/sa/reports"
+	policies := []config.Policy{
+		{From: "https://billing.example.com", To: "http://localhost", AllowedMeshIdentities: []string{billing}, AllowedMethods: []string{"GET", "POST"}},
+		{From: "https://reports.example.com", To: "http://localhost", AllowedMeshIdentities: []string{reports}},
+		{From: "https://tenants.example.com", To: "http://localhost", AllowedMeshIdentities: []string{billing}, RequiredQueryParams: map[string][]string{"tenant": nil}},
+		{From: "https://maintenance.example.com", To: "http://localhost", AllowedMeshIdentities: []string{billing}, Maintenance: true, MaintenanceAllowAdministrators: true},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		CookieName:            "_pomerium",
+		AuthenticateURL:       mustParseURL("https://authN.example.com"),
+		SharedKey:             cryptutil.NewBase64Key(),
+		ClientCA:              base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})),
+		TrustedMeshIdentities: []string{billing, reports},
+		Policies:              policies,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		cert     string
+		wantCode int
+	}{
+		{"allowed svid", "GET", "https://billing.example.com/", testSVID(t, ca, caKey, billing), http.StatusOK},
+		{"not allowlisted svid", "GET", "https://billing.example.com/", testSVID(t, ca, caKey, "spiffe://cluster.local/ns/default/sa/other"), http.StatusFound},
+		{"svid allowed on another route", "GET", "https://billing.example.com/", testSVID(t, ca, caKey, reports), http.StatusFound},
+		{"route without mesh identities", "GET", "https://unconfigured.example.com/", testSVID(t, ca, caKey, billing), http.StatusFound},
+		{"method not allowed", "DELETE", "https://billing.example.com/", testSVID(t, ca, caKey, billing), http.StatusMethodNotAllowed},
+		{"deny rule", "GET", "https://tenants.example.com/", testSVID(t, ca, caKey, billing), http.StatusFound},
+		{"no deny rule", "GET", "https://tenants.example.com/?tenant=a", testSVID(t, ca, caKey, billing), http.StatusOK},
+		{"maintenance", "GET", "https://maintenance.example.com/", testSVID(t, ca, caKey, billing), http.StatusServiceUnavailable},
+		{"untrusted ca", "GET", "https://billing.example.com/", testSVID(t, otherCA, otherCAKey, billing), httputil.StatusInvalidClientCertificate},
+		{"no certificate", "GET", "https://billing.example.com/", "", httputil.StatusInvalidClientCertificate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := testCheckRequest(tt.method, tt.url, map[string]string{
+				"accept": "text/html",
+			})
+			in.Attributes.Source = &envoy_service_auth_v2.AttributeContext_Peer{
+				Certificate: url.QueryEscape(tt.cert),
+			}
+			res, err := a.Check(context.TODO(), in)
+			if !assert.NoError(t, err) {
+				return
+			}
+			if tt.wantCode == http.StatusOK {
+				assert.NotNil(t, res.GetOkResponse())
+			} else {
+				assert.Equal(t, tt.wantCode, int(res.GetDeniedResponse().GetStatus().GetCode()))
+			}
+		})
+	}
+}
+
+func testCertificateAuthority(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "mesh ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// testSVID returns a PEM encoded x509 SVID for the SPIFFE ID signed by the ca.
+func testSVID(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, spiffeID string) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		URIs:         []*url.URL{mustParseURL(spiffeID)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
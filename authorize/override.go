@@ -0,0 +1,87 @@
+package authorize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// maxOverrideTokenLifetime bounds how long an override token may be valid
+// for after it's issued.
+const maxOverrideTokenLifetime = 15 * time.Minute
+
+// overrideTokenIDPrefix namespaces the ids (jti) of used override tokens in the
+// revocation store.
+const overrideTokenIDPrefix = "override-token/"
+
+var (
+	errInvalidOverrideToken  = errors.New("authorize: invalid override token")
+	errOverrideTokenReplayed = errors.New("authorize: override token already used")
+)
+
+// overrideResponse grants access to the route, bypassing policy, if the
+// request carries a valid break-glass override token. Every use is logged.
+func (a *Authorize) overrideResponse(ctx context.Context, in *envoy_service_auth_v2.CheckRequest, rawToken string) *envoy_service_auth_v2.CheckResponse {
+	hattrs := in.GetAttributes().GetRequest().GetHttp()
+	claims, err := a.useOverrideToken(ctx, rawToken, hattrs.GetHost())
+	if err != nil {
+		log.Warn().Err(err).Str("host", hattrs.GetHost()).Msg("authorize: denied override token")
+		a.emitDenyEvent(in, "", http.StatusForbidden, err.Error())
+		return a.deniedResponse(in, http.StatusForbidden, http.StatusText(http.StatusForbidden), nil)
+	}
+
+	sourceIP, _ := getClientAddr(in, a.currentOptions.LoadState().trustedProxies)
+	log.Warn().
+		Str("service", "authorize").
+		Bool("break-glass", true).
+		Str("override-subject", claims.Subject).
+		Str("override-id", claims.ID).
+		Time("override-expiry", claims.Expiry.Time()).
+		Str("source-ip", sourceIP).
+		Str("method", hattrs.GetMethod()).
+		Str("host", hattrs.GetHost()).
+		Str("path", hattrs.GetPath()).
+		Msg("authorize: override token bypassed policy")
+	return a.passthroughResponse()
+}
+
+// useOverrideToken verifies an override token for the host and marks it used,
+// so that it can't be replayed. Tokens must be signed with the override token
+// secret, issued by an administrator, and short-lived.
+func (a *Authorize) useOverrideToken(ctx context.Context, rawToken, host string) (*jwt.Claims, error) {
+	verifier := a.currentOptions.LoadState().overrideVerifier
+	if verifier == nil {
+		return nil, fmt.Errorf("%w: override tokens are disabled", errInvalidOverrideToken)
+	}
+	var claims jwt.Claims
+	if err := verifier.Unmarshal([]byte(rawToken), &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidOverrideToken, err)
+	}
+	if claims.ID == "" || claims.IssuedAt == nil || claims.Expiry == nil {
+		return nil, fmt.Errorf("%w: jti, iat and exp are required", errInvalidOverrideToken)
+	}
+	if lifetime := claims.Expiry.Time().Sub(claims.IssuedAt.Time()); lifetime > maxOverrideTokenLifetime {
+		return nil, fmt.Errorf("%w: lifetime %s exceeds %s", errInvalidOverrideToken, lifetime, maxOverrideTokenLifetime)
+	}
+	if err := claims.ValidateWithLeeway(jwt.Expected{Audience: jwt.Audience{host}, Time: time.Now()}, 0); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidOverrideToken, err)
+	}
+	if !containsString(a.currentOptions.Load().Administrators, claims.Subject) {
+		return nil, fmt.Errorf("%w: %s is not an administrator", errInvalidOverrideToken, claims.Subject)
+	}
+
+	ok, err := a.revocations.Load().RevokeOnce(ctx, overrideTokenIDPrefix+claims.ID, time.Until(claims.Expiry.Time()))
+	if err != nil {
+		return nil, fmt.Errorf("authorize: error recording override token use: %w", err)
+	} else if !ok {
+		return nil, errOverrideTokenReplayed
+	}
+	return &claims, nil
+}
@@ -0,0 +1,133 @@
+package authorize
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/encoding/jws"
+)
+
+func testOverrideToken(t *testing.T, secret, subject, audience string, lifetime time.Duration) string {
+	t.Helper()
+	signer, err := jws.NewHS256Signer([]byte(secret), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	raw, err := signer.Marshal(jwt.Claims{
+		ID:       cryptutil.NewRandomStringN(16),
+		Subject:  subject,
+		Audience: jwt.Audience{audience},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(lifetime)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestAuthorize_Check_overrideToken(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	overrideSecret := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	opts.Administrators = []string{"admin@example.com"}
+	opts.OverrideTokenSecret = overrideSecret
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// check returns the status code of the denied response, or 0 if allowed
+	check := func(t *testing.T, token string) int {
+		t.Helper()
+		res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+			"accept":                    "application/json",
+			"x-pomerium-override-token": token,
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.GetOkResponse() != nil {
+			return 0
+		}
+		return int(res.GetDeniedResponse().GetStatus().GetCode())
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		token := testOverrideToken(t, overrideSecret, "admin@example.com", "app.example.com", 5*time.Minute)
+		assert.Equal(t, 0, check(t, token), "expected a valid override token to grant access")
+		assert.Equal(t, http.StatusForbidden, check(t, token), "expected a replayed override token to be rejected")
+	})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"not an administrator", testOverrideToken(t, overrideSecret, "bob@example.com", "app.example.com", 5*time.Minute)},
+		{"other route", testOverrideToken(t, overrideSecret, "admin@example.com", "other.example.com", 5*time.Minute)},
+		{"expired", testOverrideToken(t, overrideSecret, "admin@example.com", "app.example.com", -time.Minute)},
+		{"too long lived", testOverrideToken(t, overrideSecret, "admin@example.com", "app.example.com", time.Hour)},
+		{"signed with shared secret", testOverrideToken(t, sharedKey, "admin@example.com", "app.example.com", 5*time.Minute)},
+		{"malformed", "not-a-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, http.StatusForbidden, check(t, tt.token))
+		})
+	}
+}
+
+func TestAuthorize_Check_overrideTokenDisabled(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	overrideSecret := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	opts.Administrators = []string{"admin@example.com"}
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without an override token secret, the token is ignored and the user is
+	// asked to sign in
+	res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+		"accept":                    "application/json",
+		"x-pomerium-override-token": testOverrideToken(t, overrideSecret, "admin@example.com", "app.example.com", 5*time.Minute),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusFound, int(res.GetDeniedResponse().GetStatus().GetCode()))
+}
@@ -0,0 +1,21 @@
+package authorize
+
+import (
+	"time"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+)
+
+// timeNow is time.Now but pulled out as a variable for tests.
+var timeNow = time.Now
+
+// getRequestTime returns the current local time, in the policy timezone, for
+// routes which restrict the days and hours of access. The location's rules
+// account for daylight saving time.
+func (a *Authorize) getRequestTime() evaluator.Time {
+	loc := a.currentOptions.LoadState().policyLocation
+	if loc == nil {
+		loc = time.UTC
+	}
+	return evaluator.NewTime(timeNow().In(loc))
+}
@@ -0,0 +1,97 @@
+package authorize
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+)
+
+func TestAuthorize_getRequestTime(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		loc  *time.Location
+		now  time.Time
+		want evaluator.Time
+	}{
+		{"utc by default", nil, time.Date(2020, 6, 5, 23, 30, 0, 0, time.UTC), evaluator.Time{Weekday: "friday", TimeOfDay: "23:30"}},
+		{"other day", newYork, time.Date(2020, 6, 6, 1, 30, 0, 0, time.UTC), evaluator.Time{Weekday: "friday", TimeOfDay: "21:30"}},
+		{"before dst starts", newYork, time.Date(2020, 3, 8, 6, 59, 0, 0, time.UTC), evaluator.Time{Weekday: "sunday", TimeOfDay: "01:59"}},
+		{"after dst starts", newYork, time.Date(2020, 3, 8, 7, 0, 0, 0, time.UTC), evaluator.Time{Weekday: "sunday", TimeOfDay: "03:00"}},
+		{"before dst ends", newYork, time.Date(2020, 11, 1, 5, 59, 0, 0, time.UTC), evaluator.Time{Weekday: "sunday", TimeOfDay: "01:59"}},
+		{"after dst ends", newYork, time.Date(2020, 11, 1, 6, 0, 0, 0, time.UTC), evaluator.Time{Weekday: "sunday", TimeOfDay: "01:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeNow = func() time.Time { return tt.now }
+			defer func() { timeNow = time.Now }()
+
+			a := new(Authorize)
+			a.currentOptions.StoreState(&optionsState{policyLocation: tt.loc})
+			assert.Equal(t, tt.want, a.getRequestTime())
+		})
+	}
+}
+
+func TestAuthorize_Check_allowedHours(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{
+		From:            "https://app.example.com",
+		To:              "http://localhost",
+		AllowedUsers:    []string{"bob@example.com"},
+		AllowedWeekdays: []string{"monday", "tuesday", "wednesday", "thursday", "friday"},
+		AllowedHours:    "09:00-17:00",
+	}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(config.Options{
+		Policies:        []config.Policy{policy},
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+		PolicyTimezone:  "America/New_York",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		wantCode codes.Code
+	}{
+		// 2020-06-03 is a wednesday, and new york is four hours behind utc
+		{"inside", time.Date(2020, 6, 3, 15, 0, 0, 0, time.UTC), codes.OK},
+		{"start", time.Date(2020, 6, 3, 13, 0, 0, 0, time.UTC), codes.OK},
+		{"before", time.Date(2020, 6, 3, 12, 59, 0, 0, time.UTC), codes.PermissionDenied},
+		{"end", time.Date(2020, 6, 3, 21, 0, 0, 0, time.UTC), codes.PermissionDenied},
+		{"utc day, local night", time.Date(2020, 6, 4, 2, 0, 0, 0, time.UTC), codes.PermissionDenied},
+		{"weekend", time.Date(2020, 6, 6, 15, 0, 0, 0, time.UTC), codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeNow = func() time.Time { return tt.now }
+			defer func() { timeNow = time.Now }()
+
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", "app.example.com", time.Now().Add(time.Hour)),
+			}))
+			if assert.NoError(t, err) {
+				assert.Equal(t, int32(tt.wantCode), res.GetStatus().GetCode())
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package authorize
+
+import (
+	"github.com/pomerium/pomerium/config"
+)
+
+// routeLimits are the semaphores which cap the concurrent checks of the routes
+// with a max, by their policy in the current options.
+type routeLimits map[*config.Policy]chan struct{}
+
+func newRouteLimits(opts *config.Options) routeLimits {
+	limits := make(routeLimits)
+	for i := range opts.Policies {
+		if n := opts.Policies[i].MaxConcurrentChecks; n > 0 {
+			limits[&opts.Policies[i]] = make(chan struct{}, n)
+		}
+	}
+	return limits
+}
+
+// acquire reserves one of the route's concurrent checks, and returns the func
+// which releases it. It returns false if the route is at its limit. Routes
+// without a limit, or requests without a route, are never limited.
+func (l routeLimits) acquire(policy *config.Policy) (release func(), ok bool) {
+	sem, limited := l[policy]
+	if !limited {
+		return func() {}, true
+	}
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, true
+	default:
+		return nil, false
+	}
+}
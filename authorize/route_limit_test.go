@@ -0,0 +1,91 @@
+package authorize
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+)
+
+func TestAuthorize_Check_routeLimits(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://a.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, MaxConcurrentChecks: 2},
+		{From: "https://b.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}, MaxConcurrentChecks: 1},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := *config.NewDefaultOptions()
+	opts.Policies = policies
+	opts.CookieName = "_pomerium"
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = sharedKey
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(host string) (int32, int) {
+		res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://"+host+"/", map[string]string{
+			"accept": "application/json",
+			"cookie": "_pomerium=" + testSessionJWT(t, sharedKey, "bob@example.com", host, time.Now().Add(time.Hour)),
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.GetStatus().GetCode(), int(res.GetDeniedResponse().GetStatus().GetCode())
+	}
+
+	// fill route a's checks, as if they were in flight
+	policyA := a.getMatchingPolicy(mustParseURL("https://a.example.com/"))
+	var releases []func()
+	for i := 0; i < 2; i++ {
+		release, ok := a.currentOptions.LoadState().routeLimits.acquire(policyA)
+		if !assert.True(t, ok) {
+			return
+		}
+		releases = append(releases, release)
+	}
+
+	code, httpCode := check("a.example.com")
+	assert.Equal(t, int32(codes.ResourceExhausted), code)
+	assert.Equal(t, http.StatusServiceUnavailable, httpCode)
+
+	// route b is unaffected, and its check is released once it's done
+	for i := 0; i < 2; i++ {
+		code, _ = check("b.example.com")
+		assert.Equal(t, int32(codes.OK), code)
+	}
+
+	releases[0]()
+	code, _ = check("a.example.com")
+	assert.Equal(t, int32(codes.OK), code)
+}
+
+func Test_routeLimits_acquire(t *testing.T) {
+	opts := config.Options{Policies: []config.Policy{{MaxConcurrentChecks: 1}, {}}}
+	limits := newRouteLimits(&opts)
+
+	release, ok := limits.acquire(&opts.Policies[0])
+	assert.True(t, ok)
+	_, ok = limits.acquire(&opts.Policies[0])
+	assert.False(t, ok)
+	release()
+	_, ok = limits.acquire(&opts.Policies[0])
+	assert.True(t, ok)
+
+	for i := 0; i < 3; i++ {
+		_, ok = limits.acquire(&opts.Policies[1])
+		assert.True(t, ok, "routes without a limit aren't limited")
+		_, ok = limits.acquire(nil)
+		assert.True(t, ok, "requests without a route aren't limited")
+	}
+}
@@ -0,0 +1,103 @@
+package authorize
+
+import (
+	"sync"
+	"time"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// securityEventWindow is the rolling window over which denials are counted
+// for each source.
+const securityEventWindow = time.Minute
+
+// securityEvents emits an event for each denied request to the "security"
+// log stream, separate from the authorize check logs, so that they can be
+// shipped to a SIEM. Each event includes a rolling count of the recent
+// denials from the same source, which consumers can alert on.
+type securityEvents struct {
+	mu        sync.Mutex
+	denials   map[string][]time.Time
+	lastSweep time.Time
+}
+
+// securityEvent is a denied request.
+type securityEvent struct {
+	SourceIP string
+	Email    string
+	Route    string
+	Status   int32
+	Reason   string
+}
+
+// Emit logs the event, and returns the number of denials from its source
+// within the window, including this one.
+func (s *securityEvents) Emit(evt securityEvent) int {
+	count := s.record(evt.SourceIP, time.Now())
+	log.Warn().
+		Str("stream", "security").
+		Str("event", "authorize-deny").
+		Str("source-ip", evt.SourceIP).
+		Str("email", evt.Email).
+		Str("route", evt.Route).
+		Int32("status", evt.Status).
+		Str("reason", evt.Reason).
+		Int("source-deny-count", count).
+		Dur("source-deny-window", securityEventWindow).
+		Msg("authorize: denied request")
+	return count
+}
+
+// record adds a denial for the source at now, and returns the number of
+// denials from the source within the window.
+func (s *securityEvents) record(source string, now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.denials == nil {
+		s.denials = make(map[string][]time.Time)
+	}
+	cutoff := now.Add(-securityEventWindow)
+
+	// periodically forget sources without recent denials so that the map
+	// doesn't grow without bound
+	if now.Sub(s.lastSweep) > securityEventWindow {
+		for src, times := range s.denials {
+			if !times[len(times)-1].After(cutoff) {
+				delete(s.denials, src)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	times := s.denials[source]
+	i := 0
+	for i < len(times) && !times[i].After(cutoff) {
+		i++
+	}
+	times = append(times[i:], now)
+	s.denials[source] = times
+	return len(times)
+}
+
+// emitDenyEvent emits a security event for a denied check request, if
+// enabled.
+func (a *Authorize) emitDenyEvent(in *envoy_service_auth_v2.CheckRequest, email string, status int32, reason string) {
+	if !a.currentOptions.Load().SecurityEvents {
+		return
+	}
+	route := in.GetAttributes().GetRequest().GetHttp().GetHost()
+	if policy := a.getMatchingPolicy(a.getPolicyRequestURL(in)); policy != nil {
+		route = policy.From
+	}
+	sourceIP, _ := getClientAddr(in, a.currentOptions.LoadState().trustedProxies)
+	a.securityEvents.Emit(securityEvent{
+		SourceIP: sourceIP,
+		Email:    a.getLogUser(email),
+		Route:    route,
+		Status:   status,
+		Reason:   reason,
+	})
+}
@@ -0,0 +1,112 @@
+package authorize
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	envoy_api_v2_core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+func Test_securityEvents_record(t *testing.T) {
+	t.Parallel()
+	var s securityEvents
+	now := time.Now()
+	assert.Equal(t, 1, s.record("10.0.0.1", now))
+	assert.Equal(t, 2, s.record("10.0.0.1", now.Add(time.Second)))
+	assert.Equal(t, 1, s.record("10.0.0.2", now.Add(time.Second)))
+	// the first denial has left the window
+	assert.Equal(t, 2, s.record("10.0.0.1", now.Add(securityEventWindow+time.Millisecond)))
+	// sources without recent denials are forgotten
+	assert.Equal(t, 1, s.record("10.0.0.1", now.Add(3*securityEventWindow)))
+	assert.Len(t, s.denials, 1)
+}
+
+func TestAuthorize_Check_securityEvents(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(l zerolog.Logger) { log.Logger = l }(log.Logger)
+	log.Logger = zerolog.New(&buf)
+
+	sharedKey := cryptutil.NewBase64Key()
+	policies := []config.Policy{
+		{From: "https://private.example.com", To: "http://localhost", AllowedUsers: []string{"bob@example.com"}},
+	}
+	for i := range policies {
+		if err := policies[i].Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, err := New(config.Options{
+		Policies:        policies,
+		CookieName:      "_pomerium",
+		AuthenticateURL: mustParseURL("https://authN.example.com"),
+		SharedKey:       sharedKey,
+		SecurityEvents:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func(email string) {
+		rawJWT := testSessionJWT(t, sharedKey, email, "private.example.com", time.Now().Add(time.Hour))
+		in := testCheckRequest("GET", "https://private.example.com/", map[string]string{
+			"accept": "application/json",
+			"cookie": "_pomerium=" + rawJWT,
+		})
+		in.Attributes.Source = &envoy_service_auth_v2.AttributeContext_Peer{
+			Address: &envoy_api_v2_core.Address{Address: &envoy_api_v2_core.Address_SocketAddress{
+				SocketAddress: &envoy_api_v2_core.SocketAddress{Address: "192.0.2.10"},
+			}},
+		}
+		if _, err := a.Check(context.TODO(), in); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check("alice@example.com")
+	check("bob@example.com")
+	check("alice@example.com")
+
+	type event struct {
+		Stream    string `json:"stream"`
+		Event     string `json:"event"`
+		SourceIP  string `json:"source-ip"`
+		Email     string `json:"email"`
+		Route     string `json:"route"`
+		Status    int    `json:"status"`
+		Reason    string `json:"reason"`
+		DenyCount int    `json:"source-deny-count"`
+	}
+	var events []event
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var e event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Stream == "security" {
+			events = append(events, e)
+		}
+	}
+	if assert.Len(t, events, 2) {
+		for i, e := range events {
+			assert.Equal(t, "authorize-deny", e.Event)
+			assert.Equal(t, "192.0.2.10", e.SourceIP)
+			assert.Equal(t, "alice@example.com", e.Email)
+			assert.Equal(t, "https://private.example.com", e.Route)
+			assert.Equal(t, http.StatusForbidden, e.Status)
+			assert.Equal(t, "Forbidden", e.Reason)
+			assert.Equal(t, i+1, e.DenyCount)
+		}
+	}
+}
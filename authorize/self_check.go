@@ -0,0 +1,78 @@
+package authorize
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/encoding"
+	"github.com/pomerium/pomerium/internal/log"
+	"github.com/pomerium/pomerium/internal/sessions"
+)
+
+// authorizationServiceName is the name of the envoy authorization gRPC
+// service, as used by the gRPC health checking protocol.
+const authorizationServiceName = "envoy.service.auth.v2.Authorization"
+
+// errSignerRoundTrip is returned when a token signed by the signer differs
+// once verified.
+var errSignerRoundTrip = errors.New("authorize: signed token did not round trip")
+
+// RunSignerSelfCheck periodically signs and verifies a dummy token with the
+// current signer, until ctx is done. If the round trip fails, the authorize
+// service is reported as not serving, so that misconfigured keys are caught
+// before users are affected.
+func (a *Authorize) RunSignerSelfCheck(ctx context.Context, hs *health.Server, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		a.signerSelfCheck(hs)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Authorize) signerSelfCheck(hs *health.Server) {
+	status := healthpb.HealthCheckResponse_SERVING
+	err := a.sharedKeyErr.Load()
+	if err == nil {
+		err = checkSigner(a.currentEncoder.Load())
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("authorize: signer self-check failed")
+		status = healthpb.HealthCheckResponse_NOT_SERVING
+	}
+	hs.SetServingStatus("", status)
+	hs.SetServingStatus(authorizationServiceName, status)
+}
+
+// checkSigner signs a dummy session with the encoder and verifies it.
+func checkSigner(encoder encoding.MarshalUnmarshaler) error {
+	want := sessions.State{
+		Subject: "pomerium-self-check",
+		ID:      cryptutil.NewRandomStringN(16),
+		Expiry:  jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+	raw, err := encoder.Marshal(&want)
+	if err != nil {
+		return err
+	}
+	var got sessions.State
+	if err := encoder.Unmarshal(raw, &got); err != nil {
+		return err
+	}
+	if got.ID != want.ID {
+		return errSignerRoundTrip
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package authorize
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/encoding"
+	"github.com/pomerium/pomerium/internal/encoding/jws"
+	"github.com/pomerium/pomerium/internal/encoding/mock"
+)
+
+func TestAuthorize_RunSignerSelfCheck(t *testing.T) {
+	signer, err := jws.NewHS256Signer(cryptutil.NewKey(), "authN.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		encoder encoding.MarshalUnmarshaler
+		want    healthpb.HealthCheckResponse_ServingStatus
+	}{
+		{"good", signer, healthpb.HealthCheckResponse_SERVING},
+		{"broken signer", mock.Encoder{MarshalError: errors.New("bad key")}, healthpb.HealthCheckResponse_NOT_SERVING},
+		{"broken verifier", mockVerifier{signer, mock.Encoder{UnmarshalError: errors.New("bad signature")}}, healthpb.HealthCheckResponse_NOT_SERVING},
+		{"no round trip", mockVerifier{signer, mock.Encoder{}}, healthpb.HealthCheckResponse_NOT_SERVING},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Authorize{}
+			a.currentEncoder.Store(tt.encoder)
+
+			hs := health.NewServer()
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			a.RunSignerSelfCheck(ctx, hs, time.Minute)
+
+			for _, service := range []string{"", authorizationServiceName} {
+				res, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.Equal(t, tt.want, res.GetStatus(), "service %q", service)
+			}
+		})
+	}
+}
+
+// mockVerifier signs with one encoder and verifies with another.
+type mockVerifier struct {
+	encoding.Marshaler
+	encoding.Unmarshaler
+}
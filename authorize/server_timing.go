@@ -0,0 +1,66 @@
+package authorize
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+	"github.com/pomerium/pomerium/internal/httputil"
+)
+
+// The descriptions of the session refresh metric, for refreshes reused from
+// a recent refresh of the session and for those made to the authenticate
+// service.
+const (
+	serverTimingCacheHit  = "cache-hit"
+	serverTimingCacheMiss = "cache-miss"
+)
+
+// getServerTimingHeaders returns the Server-Timing header, if enabled and the
+// user is an administrator, as timing may leak information about policy to
+// other users. It has the time taken by the authorize check and its policy
+// evaluation, whether the session refresh, if any, was cached, and the user.
+func (a *Authorize) getServerTimingHeaders(reply *authorize.IsAuthorizedReply, checkTime, policyTime time.Duration, refreshCache string) http.Header {
+	opts := a.currentOptions.Load()
+	if !opts.DebugServerTiming || reply.GetEmail() == "" || !containsString(opts.Administrators, reply.GetEmail()) {
+		return nil
+	}
+	metrics := []string{
+		"pomerium-authorize;desc=" + quoteServerTimingDesc("allow") + ";dur=" + formatServerTimingDuration(checkTime),
+		"pomerium-policy;dur=" + formatServerTimingDuration(policyTime),
+	}
+	if refreshCache != "" {
+		metrics = append(metrics, "pomerium-session-refresh;desc="+quoteServerTimingDesc(refreshCache))
+	}
+	metrics = append(metrics, "pomerium-user;desc="+quoteServerTimingDesc(reply.GetEmail()))
+	return http.Header{
+		http.CanonicalHeaderKey(httputil.HeaderPomeriumServerTiming): {strings.Join(metrics, ", ")},
+	}
+}
+
+// formatServerTimingDuration formats a duration in milliseconds, the unit of
+// a Server-Timing metric's duration.
+func formatServerTimingDuration(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// quoteServerTimingDesc quotes a Server-Timing metric's description as an
+// HTTP quoted string. Control characters, which can't be quoted, are dropped.
+func quoteServerTimingDesc(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,123 @@
+package authorize
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+	"github.com/pomerium/pomerium/internal/httputil"
+)
+
+func TestAuthorize_Check_serverTiming(t *testing.T) {
+	sharedKey := cryptutil.NewBase64Key()
+	newAuthorize := func(t *testing.T, enabled bool) *Authorize {
+		policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedUsers: []string{"admin@example.com", "bob@example.com"}}
+		if err := policy.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		a, err := New(config.Options{
+			Policies:          []config.Policy{policy},
+			Administrators:    []string{"admin@example.com"},
+			CookieName:        "_pomerium",
+			AuthenticateURL:   mustParseURL("https://authN.example.com"),
+			SharedKey:         sharedKey,
+			DebugServerTiming: enabled,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+
+	tests := []struct {
+		name    string
+		enabled bool
+		email   string
+		want    bool
+	}{
+		{"admin", true, "admin@example.com", true},
+		{"non-admin", true, "bob@example.com", false},
+		{"disabled", false, "admin@example.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuthorize(t, tt.enabled)
+			rawJWT := testSessionJWT(t, sharedKey, tt.email, "app.example.com", time.Now().Add(time.Hour))
+			res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+				"accept": "application/json",
+				"cookie": "_pomerium=" + rawJWT,
+			}))
+			if !assert.NoError(t, err) || !assert.NotNil(t, res.GetOkResponse()) {
+				return
+			}
+			var got string
+			for _, hvo := range res.GetOkResponse().GetHeaders() {
+				if strings.EqualFold(hvo.GetHeader().GetKey(), httputil.HeaderPomeriumServerTiming) {
+					got = hvo.GetHeader().GetValue()
+				}
+			}
+			if !tt.want {
+				assert.Empty(t, got)
+				return
+			}
+			assert.Contains(t, got, `pomerium-authorize;desc="allow";dur=`)
+			assert.Contains(t, got, `pomerium-user;desc="admin@example.com"`)
+			assert.NotContains(t, got, "pomerium-session-refresh")
+			durs := regexp.MustCompile(`(pomerium-[a-z]+);[^,]*dur=([^,;]+)`).FindAllStringSubmatch(got, -1)
+			if !assert.Len(t, durs, 2, got) {
+				return
+			}
+			total, err := strconv.ParseFloat(durs[0][2], 64)
+			assert.NoError(t, err)
+			policy, err := strconv.ParseFloat(durs[1][2], 64)
+			assert.NoError(t, err)
+			assert.Equal(t, "pomerium-policy", durs[1][1])
+			assert.True(t, policy > 0, "expected a policy evaluation time, got %q", got)
+			assert.True(t, total >= policy, "expected the authorize time to include policy evaluation, got %q", got)
+		})
+	}
+}
+
+func TestAuthorize_getServerTimingHeaders(t *testing.T) {
+	a, err := New(config.Options{
+		Administrators:    []string{"admin@example.com", "a\"d\\min\n@example.com"},
+		CookieName:        "_pomerium",
+		AuthenticateURL:   mustParseURL("https://authN.example.com"),
+		SharedKey:         cryptutil.NewBase64Key(),
+		DebugServerTiming: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		email        string
+		refreshCache string
+		want         string
+	}{
+		{"no refresh", "admin@example.com", "",
+			`pomerium-authorize;desc="allow";dur=2.500, pomerium-policy;dur=1.250, pomerium-user;desc="admin@example.com"`},
+		{"cache hit", "admin@example.com", serverTimingCacheHit,
+			`pomerium-authorize;desc="allow";dur=2.500, pomerium-policy;dur=1.250, pomerium-session-refresh;desc="cache-hit", pomerium-user;desc="admin@example.com"`},
+		{"cache miss", "admin@example.com", serverTimingCacheMiss,
+			`pomerium-authorize;desc="allow";dur=2.500, pomerium-policy;dur=1.250, pomerium-session-refresh;desc="cache-miss", pomerium-user;desc="admin@example.com"`},
+		{"quoted user", "a\"d\\min\n@example.com", "",
+			`pomerium-authorize;desc="allow";dur=2.500, pomerium-policy;dur=1.250, pomerium-user;desc="a\"d\\min@example.com"`},
+		{"no user", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := a.getServerTimingHeaders(&authorize.IsAuthorizedReply{Allow: true, Email: tt.email}, 2500*time.Microsecond, 1250*time.Microsecond, tt.refreshCache)
+			assert.Equal(t, tt.want, h.Get(httputil.HeaderPomeriumServerTiming))
+		})
+	}
+}
@@ -1,40 +1,86 @@
 package authorize
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
 	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
 	"github.com/pomerium/pomerium/internal/encoding"
-	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/encoding/jws"
 	"github.com/pomerium/pomerium/internal/sessions"
 	"github.com/pomerium/pomerium/internal/sessions/cookie"
 	"github.com/pomerium/pomerium/internal/sessions/header"
 	"github.com/pomerium/pomerium/internal/sessions/queryparam"
 	"github.com/pomerium/pomerium/internal/urlutil"
+
+	"gopkg.in/square/go-jose.v2/jwt"
 )
 
-func loadSession(req *http.Request, options config.Options, encoder encoding.MarshalUnmarshaler) ([]byte, error) {
-	var loaders []sessions.SessionLoader
+// loadSession loads the session from the request, trying the session cookie
+// and authorization header in the order set by the route's session
+// preference.
+func loadSession(req *http.Request, options config.Options, encoder encoding.MarshalUnmarshaler, preference string) ([]byte, error) {
 	cookieStore, err := getCookieStore(options, encoder)
 	if err != nil {
 		return nil, err
 	}
-	loaders = append(loaders,
-		cookieStore,
-		header.NewStore(encoder, httputil.AuthorizationTypePomerium),
-		queryparam.NewStore(encoder, urlutil.QuerySession),
-	)
+	headerStore := header.NewStore(encoder, options.GetSessionAuthorizationSchemes()...)
+	queryStore := queryparam.NewStore(encoder, urlutil.QuerySession)
 
+	var loaders []sessions.SessionLoader
+	switch preference {
+	case config.SessionPreferenceHeaderFirst:
+		loaders = append(loaders, headerStore, cookieStore, queryStore)
+	case config.SessionPreferenceHeaderOnly:
+		loaders = append(loaders, headerStore)
+	case config.SessionPreferenceCookieOnly:
+		loaders = append(loaders, cookieStore)
+	default:
+		loaders = append(loaders, cookieStore, headerStore, queryStore)
+	}
+
+	return loadSessionFrom(req, options, encoder, loaders)
+}
+
+// loadLegacyCookieSession loads the session from the first of the legacy
+// session cookies the request has, in the order they're configured.
+func loadLegacyCookieSession(req *http.Request, options config.Options, encoder encoding.MarshalUnmarshaler) ([]byte, error) {
+	var loaders []sessions.SessionLoader
+	for _, name := range options.CookieLegacyNames {
+		legacyOptions := options
+		legacyOptions.CookieName = name
+		cookieStore, err := getCookieStore(legacyOptions, encoder)
+		if err != nil {
+			return nil, err
+		}
+		loaders = append(loaders, cookieStore)
+	}
+	return loadSessionFrom(req, options, encoder, loaders)
+}
+
+// loadSessionFrom loads the session from the first loader which finds one.
+func loadSessionFrom(req *http.Request, options config.Options, encoder encoding.MarshalUnmarshaler, loaders []sessions.SessionLoader) ([]byte, error) {
 	for _, loader := range loaders {
 		sess, err := loader.LoadSession(req)
 		if err != nil && !errors.Is(err, sessions.ErrNoSessionFound) {
 			return nil, err
 		} else if err == nil {
+			if err := checkSigningAlgorithm(encoder, sess); err != nil {
+				return nil, err
+			}
+			if err := checkMaxTokenAge(options.MaxTokenAge, encoder, sess); err != nil {
+				return nil, err
+			}
+			if err := checkIssuer(options.AllowedSessionIssuers, encoder, sess); err != nil {
+				return nil, err
+			}
 			return []byte(sess), nil
 		}
 	}
@@ -42,13 +88,258 @@ func loadSession(req *http.Request, options config.Options, encoder encoding.Mar
 	return nil, sessions.ErrNoSessionFound
 }
 
+// getRouteEncoder returns the encoder the route's sessions are signed and
+// verified with.
+func (a *Authorize) getRouteEncoder(policy *config.Policy) encoding.MarshalUnmarshaler {
+	if policy != nil && policy.SharedKeyRef != "" {
+		return a.currentOptions.LoadState().routeEncoders[policy.SharedKeyRef]
+	}
+	return a.currentEncoder.Load()
+}
+
+// resignSession verifies the session with one encoder, and returns it signed
+// with another.
+func resignSession(from, to encoding.MarshalUnmarshaler, rawJWT []byte) ([]byte, error) {
+	var state sessions.State
+	if err := from.Unmarshal(rawJWT, &state); err != nil {
+		return nil, sessions.ErrMalformed
+	}
+	return to.Marshal(&state)
+}
+
+// getPolicySessionJWT returns the session as it's evaluated by policy. OPA
+// can't verify compressed sessions, so they're re-signed uncompressed.
+func (a *Authorize) getPolicySessionJWT(rawJWT []byte) []byte {
+	if !jws.IsCompressed(rawJWT) {
+		return rawJWT
+	}
+	opts := a.currentOptions.Load()
+	encoder, err := jws.NewHMACSigner(opts.GetSessionSigningAlgorithm(), []byte(opts.SharedKey), getAuthenticateHost(opts))
+	if err != nil {
+		return rawJWT
+	}
+	policyJWT, err := resignSession(a.currentEncoder.Load(), encoder, rawJWT)
+	if err != nil {
+		return rawJWT
+	}
+	return policyJWT
+}
+
+// errInvalidAPIKey is returned for unknown or revoked API keys.
+var errInvalidAPIKey = errors.New("authorize: invalid api key")
+
+// getAPIKeySession returns a session for the service account mapped to the
+// API key, with the given audience.
+func getAPIKeySession(options config.Options, encoder encoding.MarshalUnmarshaler, apiKey, audience string) ([]byte, error) {
+	for _, k := range options.ServiceAccountAPIKeys {
+		hash, err := base64.StdEncoding.DecodeString(k.Hash)
+		if err != nil || !cryptutil.CheckHMAC([]byte(apiKey), hash, k.Salt) {
+			continue
+		}
+		if k.Revoked {
+			return nil, errInvalidAPIKey
+		}
+		now := time.Now()
+		return encoder.Marshal(&sessions.State{
+			Subject:      k.Email,
+			Audience:     jwt.Audience{audience},
+			Expiry:       jwt.NewNumericDate(now.Add(time.Minute)),
+			IssuedAt:     jwt.NewNumericDate(now),
+			NotBefore:    jwt.NewNumericDate(now),
+			Email:        k.Email,
+			Groups:       k.Groups,
+			Programmatic: true,
+		})
+	}
+	return nil, errInvalidAPIKey
+}
+
+// getAnonymousSession returns a session, with the given audience, for the
+// anonymous user of a public route. It has no email or groups, so it only
+// identifies the request to policy.
+func getAnonymousSession(options config.Options, encoder encoding.MarshalUnmarshaler, audience string) ([]byte, error) {
+	now := time.Now()
+	return encoder.Marshal(&sessions.State{
+		Subject:   options.AnonymousUser,
+		Audience:  jwt.Audience{audience},
+		Expiry:    jwt.NewNumericDate(now.Add(time.Minute)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		User:      options.AnonymousUser,
+	})
+}
+
+// partnerClaims are the claims of a partner's session cookie.
+type partnerClaims struct {
+	jwt.Claims
+	Email  string   `json:"email"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// getPartnerSession returns a session, with the given audience, for the user
+// of the route's partner session cookie, or sessions.ErrNoSessionFound if the
+// request has none. The cookie must be signed with the route's partner key,
+// unexpired, and issued for the route.
+func getPartnerSession(policy *config.Policy, encoder encoding.MarshalUnmarshaler, req *http.Request, audience string) ([]byte, error) {
+	if policy == nil || policy.PartnerCookieName == "" {
+		return nil, sessions.ErrNoSessionFound
+	}
+	c, err := req.Cookie(policy.PartnerCookieName)
+	if err != nil {
+		return nil, sessions.ErrNoSessionFound
+	}
+	key, err := policy.GetPartnerCookieKey()
+	if err != nil {
+		return nil, err
+	}
+	tok, err := jwt.ParseSigned(c.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", sessions.ErrMalformed, err)
+	}
+	var claims partnerClaims
+	if err := tok.Claims(key, &claims); err != nil {
+		return nil, fmt.Errorf("authorize: invalid partner session: %w", err)
+	}
+	if claims.Expiry == nil {
+		return nil, errors.New("authorize: partner session has no expiry")
+	}
+	if err := claims.Validate(jwt.Expected{Audience: jwt.Audience{policy.Source.Hostname()}, Time: time.Now()}); err != nil {
+		return nil, fmt.Errorf("authorize: invalid partner session: %w", err)
+	}
+	if claims.Email == "" {
+		return nil, errors.New("authorize: partner session has no email")
+	}
+
+	now := time.Now()
+	expiry := now.Add(time.Minute)
+	if claims.Expiry.Time().Before(expiry) {
+		expiry = claims.Expiry.Time()
+	}
+	return encoder.Marshal(&sessions.State{
+		Subject:   claims.Subject,
+		Audience:  jwt.Audience{audience},
+		Expiry:    jwt.NewNumericDate(expiry),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Email:     claims.Email,
+		Groups:    claims.Groups,
+	})
+}
+
+// errInvalidInnerIdentity is returned for inner identity headers which aren't
+// signed with the inner trust secret, or aren't valid for the request.
+var errInvalidInnerIdentity = errors.New("authorize: invalid inner identity")
+
+// getInnerTrustSession returns a session, with the given audience, for the
+// user of an inner identity header, forwarded by an outer pomerium which
+// authenticated them. It must be signed with the inner trust secret,
+// unexpired, and issued for the host.
+func getInnerTrustSession(verifier encoding.Unmarshaler, encoder encoding.MarshalUnmarshaler, rawIdentity, audience string) ([]byte, error) {
+	// the identity is in the same form as a partner's session
+	var claims partnerClaims
+	if err := verifier.Unmarshal([]byte(rawIdentity), &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidInnerIdentity, err)
+	}
+	if claims.Expiry == nil {
+		return nil, fmt.Errorf("%w: exp is required", errInvalidInnerIdentity)
+	}
+	if err := claims.Validate(jwt.Expected{Audience: jwt.Audience{audience}, Time: time.Now()}); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidInnerIdentity, err)
+	}
+	if claims.Email == "" {
+		return nil, fmt.Errorf("%w: email is required", errInvalidInnerIdentity)
+	}
+
+	now := time.Now()
+	expiry := now.Add(time.Minute)
+	if claims.Expiry.Time().Before(expiry) {
+		expiry = claims.Expiry.Time()
+	}
+	return encoder.Marshal(&sessions.State{
+		Subject:   claims.Subject,
+		Audience:  jwt.Audience{audience},
+		Expiry:    jwt.NewNumericDate(expiry),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Email:     claims.Email,
+		Groups:    claims.Groups,
+	})
+}
+
+// getGraceSession returns the expired session re-signed to expire at the end
+// of its grace period, and the time left, if the request method may use it.
+func getGraceSession(options config.Options, encoder encoding.MarshalUnmarshaler, method string, rawJWT []byte) ([]byte, time.Duration, bool) {
+	if options.ExpiredSessionGracePeriod <= 0 || !containsString(options.ExpiredSessionGraceMethods, method) {
+		return nil, 0, false
+	}
+	return extendSession(encoder, rawJWT, options.ExpiredSessionGracePeriod)
+}
+
+// getOutageSession returns the expired session re-signed to expire at the end
+// of the authenticate outage grace period, and the time left, if every
+// authenticate service url is unhealthy, so the session can't be refreshed.
+func (a *Authorize) getOutageSession(rawJWT []byte) ([]byte, time.Duration, bool) {
+	options := a.currentOptions.Load()
+	if options.AuthenticateOutageGracePeriod <= 0 || !a.authenticateHealth.AllUnhealthy(options.GetAuthenticateURLs()) {
+		return nil, 0, false
+	}
+	return extendSession(a.currentEncoder.Load(), rawJWT, options.AuthenticateOutageGracePeriod)
+}
+
+// extendSession returns the expired session re-signed to expire the period
+// after it did, and the time left, unless that's passed.
+func extendSession(encoder encoding.MarshalUnmarshaler, rawJWT []byte, period time.Duration) ([]byte, time.Duration, bool) {
+	var state sessions.State
+	if err := encoder.Unmarshal(rawJWT, &state); err != nil || state.Expiry == nil {
+		return nil, 0, false
+	}
+	extendedExpiry := state.Expiry.Time().Add(period)
+	remaining := time.Until(extendedExpiry)
+	if remaining <= 0 {
+		return nil, 0, false
+	}
+	state.Expiry = jwt.NewNumericDate(extendedExpiry)
+	extendedJWT, err := encoder.Marshal(&state)
+	if err != nil {
+		return nil, 0, false
+	}
+	return extendedJWT, remaining, true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkMaxTokenAge returns an error if the session was issued more than
+// maxAge ago. A zero maxAge disables the check.
+func checkMaxTokenAge(maxAge time.Duration, encoder encoding.MarshalUnmarshaler, rawJWT string) error {
+	if maxAge <= 0 {
+		return nil
+	}
+	var state sessions.State
+	if err := encoder.Unmarshal([]byte(rawJWT), &state); err != nil {
+		return sessions.ErrMalformed
+	}
+	if state.IssuedAt == nil || time.Since(state.IssuedAt.Time()) > maxAge {
+		return sessions.ErrMaxAgeExceeded
+	}
+	return nil
+}
+
 func getCookieStore(options config.Options, encoder encoding.MarshalUnmarshaler) (sessions.SessionStore, error) {
 	cookieOptions := &cookie.Options{
-		Name:     options.CookieName,
-		Domain:   options.CookieDomain,
-		Secure:   options.CookieSecure,
-		HTTPOnly: options.CookieHTTPOnly,
-		Expire:   options.CookieExpire,
+		Name:           options.CookieName,
+		Domain:         options.CookieDomain,
+		Secure:         options.CookieSecure,
+		HTTPOnly:       options.CookieHTTPOnly,
+		Expire:         options.CookieExpire,
+		MaxChunks:      options.CookieMaxChunks,
+		ChunkThreshold: options.CookieChunkThreshold,
 	}
 	cookieStore, err := cookie.NewStore(cookieOptions, encoder)
 	if err != nil {
@@ -76,6 +367,14 @@ func getJWTSetCookieHeaders(cookieStore sessions.SessionStore, rawjwt []byte) (m
 	return hdrs, nil
 }
 
+// getJWTClearCookieHeaders returns the Set-Cookie headers which expire the
+// session cookie, and any of its chunks, sent with the request.
+func getJWTClearCookieHeaders(cookieStore sessions.SessionStore, req *http.Request) http.Header {
+	recorder := httptest.NewRecorder()
+	cookieStore.ClearSession(recorder, req)
+	return recorder.Header()
+}
+
 func getJWTClaimHeaders(options config.Options, encoder encoding.MarshalUnmarshaler, rawjwt []byte) (map[string]string, error) {
 	if len(rawjwt) == 0 {
 		return make(map[string]string), nil
@@ -114,3 +413,29 @@ func (claim *jwtClaim) UnmarshalJSON(bs []byte) error {
 	}
 	return nil
 }
+
+// checkSigningAlgorithm returns an error if the session isn't signed with the
+// algorithm sessions are signed with, e.g. if its alg header is "none".
+func checkSigningAlgorithm(encoder encoding.MarshalUnmarshaler, rawJWT string) error {
+	var state sessions.State
+	if err := encoder.Unmarshal([]byte(rawJWT), &state); errors.Is(err, jws.ErrUnexpectedAlgorithm) {
+		return fmt.Errorf("%w: %v", sessions.ErrUnexpectedAlgorithm, err)
+	}
+	return nil
+}
+
+// checkIssuer returns an error if the session wasn't issued by one of the
+// allowed issuers. An empty list disables the check.
+func checkIssuer(allowed []string, encoder encoding.MarshalUnmarshaler, rawJWT string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	var state sessions.State
+	if err := encoder.Unmarshal([]byte(rawJWT), &state); err != nil {
+		return sessions.ErrMalformed
+	}
+	if state.Issuer == "" || !containsString(allowed, state.Issuer) {
+		return sessions.ErrInvalidIssuer
+	}
+	return nil
+}
@@ -1,12 +1,15 @@
 package authorize
 
 import (
+	"errors"
 	"net/url"
 	"regexp"
 	"testing"
+	"time"
 
 	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2/jwt"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/encoding/jws"
@@ -35,7 +38,7 @@ func TestLoadSession(t *testing.T) {
 				},
 			},
 		})
-		raw, err := loadSession(req, opts, encoder)
+		raw, err := loadSession(req, opts, encoder, "")
 		if err != nil {
 			return nil, err
 		}
@@ -109,6 +112,168 @@ func TestLoadSession(t *testing.T) {
 	})
 }
 
+func TestLoadSession_sessionPreference(t *testing.T) {
+	opts := *config.NewDefaultOptions()
+	encoder, err := jws.NewHS256Signer(nil, "example.com")
+	if !assert.NoError(t, err) {
+		return
+	}
+	cookieStore, err := getCookieStore(opts, encoder)
+	if !assert.NoError(t, err) {
+		return
+	}
+	cookieJWT, err := encoder.Marshal(&sessions.State{Email: "cookie@example.com"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	headerJWT, err := encoder.Marshal(&sessions.State{Email: "header@example.com"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	hdrs, err := getJWTSetCookieHeaders(cookieStore, cookieJWT)
+	if !assert.NoError(t, err) {
+		return
+	}
+	cookie := regexp.MustCompile(`^([^;]+)(;.*)?$`).ReplaceAllString(hdrs["Set-Cookie"], "$1")
+
+	both := map[string]string{"Cookie": cookie, "Authorization": "Pomerium " + string(headerJWT)}
+	cookieOnly := map[string]string{"Cookie": cookie}
+	headerOnly := map[string]string{"Authorization": "Pomerium " + string(headerJWT)}
+
+	tests := []struct {
+		name       string
+		preference string
+		headers    map[string]string
+		wantEmail  string
+		wantErr    error
+	}{
+		{"default both", "", both, "cookie@example.com", nil},
+		{"cookie-first both", config.SessionPreferenceCookieFirst, both, "cookie@example.com", nil},
+		{"cookie-first header", config.SessionPreferenceCookieFirst, headerOnly, "header@example.com", nil},
+		{"header-first both", config.SessionPreferenceHeaderFirst, both, "header@example.com", nil},
+		{"header-first cookie", config.SessionPreferenceHeaderFirst, cookieOnly, "cookie@example.com", nil},
+		{"header-only both", config.SessionPreferenceHeaderOnly, both, "header@example.com", nil},
+		{"header-only cookie", config.SessionPreferenceHeaderOnly, cookieOnly, "", sessions.ErrNoSessionFound},
+		{"cookie-only both", config.SessionPreferenceCookieOnly, both, "cookie@example.com", nil},
+		{"cookie-only header", config.SessionPreferenceCookieOnly, headerOnly, "", sessions.ErrNoSessionFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := getHTTPRequestFromCheckRequest(testCheckRequest("GET", "https://example.com/", tt.headers))
+			raw, err := loadSession(req, opts, encoder, tt.preference)
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "expected %v, got %v", tt.wantErr, err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			var state sessions.State
+			if !assert.NoError(t, encoder.Unmarshal(raw, &state)) {
+				return
+			}
+			assert.Equal(t, tt.wantEmail, state.Email)
+		})
+	}
+}
+
+func TestLoadSession_maxTokenAge(t *testing.T) {
+	opts := *config.NewDefaultOptions()
+	opts.MaxTokenAge = time.Hour
+	encoder, err := jws.NewHS256Signer(nil, "example.com")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name     string
+		issuedAt *jwt.NumericDate
+		wantErr  error
+	}{
+		{"within max age", jwt.NewNumericDate(time.Now().Add(-time.Minute)), nil},
+		{"beyond max age", jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)), sessions.ErrMaxAgeExceeded},
+		{"missing issued at", nil, sessions.ErrMaxAgeExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawjwt, err := encoder.Marshal(&sessions.State{
+				Email:    "bob@example.com",
+				Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+				IssuedAt: tt.issuedAt,
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			req := getHTTPRequestFromCheckRequest(&envoy_service_auth_v2.CheckRequest{
+				Attributes: &envoy_service_auth_v2.AttributeContext{
+					Request: &envoy_service_auth_v2.AttributeContext_Request{
+						Http: &envoy_service_auth_v2.AttributeContext_HttpRequest{
+							Method:  "GET",
+							Headers: map[string]string{"Authorization": "Pomerium " + string(rawjwt)},
+							Path:    "/",
+							Host:    "example.com",
+							Scheme:  "https",
+						},
+					},
+				},
+			})
+			raw, err := loadSession(req, opts, encoder, "")
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "expected %v, got %v", tt.wantErr, err)
+				assert.Nil(t, raw)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, rawjwt, raw)
+		})
+	}
+}
+
+func TestLoadSession_allowedIssuers(t *testing.T) {
+	encoder, err := jws.NewHS256Signer(nil, "example.com")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		name    string
+		allowed []string
+		issuer  string
+		wantErr error
+	}{
+		{"disabled", nil, "rogue.example.com", nil},
+		{"allowed", []string{"authenticate.example.com", "authenticate.corp.example.com"}, "authenticate.corp.example.com", nil},
+		{"allowed case insensitive", []string{"authenticate.example.com"}, "Authenticate.Example.com", nil},
+		{"disallowed", []string{"authenticate.example.com"}, "rogue.example.com", sessions.ErrInvalidIssuer},
+		{"missing issuer", []string{"authenticate.example.com"}, "", sessions.ErrInvalidIssuer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := *config.NewDefaultOptions()
+			opts.AllowedSessionIssuers = tt.allowed
+			rawjwt, err := encoder.Marshal(&sessions.State{
+				Issuer: tt.issuer,
+				Email:  "bob@example.com",
+				Expiry: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			})
+			if !assert.NoError(t, err) {
+				return
+			}
+			req := getHTTPRequestFromCheckRequest(testCheckRequest("GET", "https://example.com/", map[string]string{
+				"Authorization": "Pomerium " + string(rawjwt),
+			}))
+			raw, err := loadSession(req, opts, encoder, "")
+			if tt.wantErr != nil {
+				assert.True(t, errors.Is(err, tt.wantErr), "expected %v, got %v", tt.wantErr, err)
+				assert.Nil(t, raw)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, rawjwt, raw)
+		})
+	}
+}
+
 func TestGetJWTClaimHeaders(t *testing.T) {
 	options := config.NewDefaultOptions()
 	options.JWTClaimsHeaders = []string{"email", "groups", "user"}
@@ -0,0 +1,86 @@
+package authorize
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/encoding"
+)
+
+// tenantQuotas count the allowed requests of each tenant with a quota, in
+// fixed windows of its quota's period, so that exhausted tenants' requests
+// can be denied until the window resets.
+type tenantQuotas struct {
+	mu      sync.Mutex
+	quotas  map[string]config.TenantQuota
+	windows map[string]*tenantQuotaWindow
+}
+
+// tenantQuotaWindow is the count of a tenant's requests in the window
+// starting at start.
+type tenantQuotaWindow struct {
+	start time.Time
+	count int
+}
+
+// update replaces the quotas with the options'. The counts of tenants which
+// still have a quota are kept, so that updating the options doesn't reset
+// them.
+func (q *tenantQuotas) update(opts *config.Options) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.quotas = make(map[string]config.TenantQuota, len(opts.TenantQuotas))
+	for _, quota := range opts.TenantQuotas {
+		q.quotas[quota.Tenant] = quota
+	}
+	for tenant := range q.windows {
+		if _, ok := q.quotas[tenant]; !ok {
+			delete(q.windows, tenant)
+		}
+	}
+}
+
+// use counts a request from the tenant at now, and returns the time its quota
+// resets. It returns false if the tenant's quota was already exhausted.
+// Tenants without a quota are never limited.
+func (q *tenantQuotas) use(tenant string, now time.Time) (reset time.Time, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	quota, limited := q.quotas[tenant]
+	if !limited {
+		return time.Time{}, true
+	}
+	// windows are aligned to the period, so that instances agree on them
+	start := now.Truncate(quota.Period)
+	w := q.windows[tenant]
+	if w == nil || !w.start.Equal(start) {
+		if q.windows == nil {
+			q.windows = make(map[string]*tenantQuotaWindow)
+		}
+		w = &tenantQuotaWindow{start: start}
+		q.windows[tenant] = w
+	}
+	reset = start.Add(quota.Period)
+	if w.count >= quota.Requests {
+		return reset, false
+	}
+	w.count++
+	return reset, true
+}
+
+// getTenant returns the value of the tenant claim of the session, or "" if
+// there's no tenant claim or the session doesn't have it.
+func getTenant(claim string, encoder encoding.MarshalUnmarshaler, rawJWT []byte) string {
+	if claim == "" || len(rawJWT) == 0 {
+		return ""
+	}
+	var claims map[string]jwtClaim
+	if err := encoder.Unmarshal(rawJWT, &claims); err != nil {
+		return ""
+	}
+	if len(claims[claim]) == 0 {
+		return ""
+	}
+	return claims[claim][0]
+}
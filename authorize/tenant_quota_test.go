@@ -0,0 +1,145 @@
+package authorize
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/encoding/jws"
+)
+
+// testTenantSessionJWT returns a session for the user of the tenant.
+func testTenantSessionJWT(t *testing.T, sharedKey, email, tenant, audience string) string {
+	t.Helper()
+	encoder, err := jws.NewHS256Signer([]byte(sharedKey), "authN.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims jwt.Claims
+	claims.Expiry = jwt.NewNumericDate(time.Now().Add(time.Hour))
+	claims.IssuedAt = jwt.NewNumericDate(time.Now())
+	claims.NotBefore = jwt.NewNumericDate(time.Now())
+	claims.Subject = email
+	claims.Issuer = "authN.example.com"
+	claims.Audience = jwt.Audience{audience}
+	raw, err := encoder.Marshal(struct {
+		jwt.Claims
+		Email  string `json:"email"`
+		Tenant string `json:"tenant,omitempty"`
+	}{claims, email, tenant})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func TestAuthorize_Check_tenantQuotas(t *testing.T) {
+	now := time.Date(2020, 6, 3, 17, 30, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	sharedKey := cryptutil.NewBase64Key()
+	policy := config.Policy{From: "https://app.example.com", To: "http://localhost", AllowedDomains: []string{"example.com"}}
+	if err := policy.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	newAuthorize := func(t *testing.T, statusCode int) *Authorize {
+		t.Helper()
+		a, err := New(config.Options{
+			Policies:        []config.Policy{policy},
+			CookieName:      "_pomerium",
+			AuthenticateURL: mustParseURL("https://authN.example.com"),
+			SharedKey:       sharedKey,
+			TenantClaim:     "tenant",
+			TenantQuotas: []config.TenantQuota{
+				{Tenant: "acme", Requests: 2, Period: time.Hour},
+				{Tenant: "initech", Requests: 0, Period: time.Hour},
+			},
+			TenantQuotaStatusCode: statusCode,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	check := func(t *testing.T, a *Authorize, email, tenant string) (int, http.Header) {
+		t.Helper()
+		res, err := a.Check(context.TODO(), testCheckRequest("GET", "https://app.example.com/", map[string]string{
+			"accept": "application/json",
+			"cookie": "_pomerium=" + testTenantSessionJWT(t, sharedKey, email, tenant, "app.example.com"),
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.GetOkResponse() != nil {
+			return http.StatusOK, nil
+		}
+		hdrs := make(http.Header)
+		for _, hvo := range res.GetDeniedResponse().GetHeaders() {
+			hdrs.Add(hvo.GetHeader().GetKey(), hvo.GetHeader().GetValue())
+		}
+		return int(res.GetDeniedResponse().GetStatus().GetCode()), hdrs
+	}
+
+	t.Run("within quota", func(t *testing.T) {
+		a := newAuthorize(t, 0)
+		for i := 0; i < 2; i++ {
+			code, _ := check(t, a, "bob@example.com", "acme")
+			assert.Equal(t, http.StatusOK, code)
+		}
+	})
+	t.Run("exhausted quota", func(t *testing.T) {
+		a := newAuthorize(t, 0)
+		check(t, a, "bob@example.com", "acme")
+		check(t, a, "alice@example.com", "acme")
+		code, hdrs := check(t, a, "bob@example.com", "acme")
+		assert.Equal(t, http.StatusTooManyRequests, code)
+		assert.Equal(t, "1800", hdrs.Get("Retry-After"))
+
+		// other tenants, and users without a tenant, aren't limited
+		code, _ = check(t, a, "carol@example.com", "globex")
+		assert.Equal(t, http.StatusOK, code)
+		code, _ = check(t, a, "carol@example.com", "")
+		assert.Equal(t, http.StatusOK, code)
+
+		// the quota resets with the next period
+		now = now.Add(30 * time.Minute)
+		defer func() { now = now.Add(-30 * time.Minute) }()
+		code, _ = check(t, a, "bob@example.com", "acme")
+		assert.Equal(t, http.StatusOK, code)
+	})
+	t.Run("custom status", func(t *testing.T) {
+		a := newAuthorize(t, http.StatusPaymentRequired)
+		code, hdrs := check(t, a, "bob@example.com", "initech")
+		assert.Equal(t, http.StatusPaymentRequired, code)
+		assert.Equal(t, "1800", hdrs.Get("Retry-After"))
+	})
+	t.Run("denied requests don't count", func(t *testing.T) {
+		a := newAuthorize(t, 0)
+		for i := 0; i < 3; i++ {
+			code, _ := check(t, a, "mallory@evil.example", "acme")
+			assert.Equal(t, http.StatusForbidden, code)
+		}
+		code, _ := check(t, a, "bob@example.com", "acme")
+		assert.Equal(t, http.StatusOK, code)
+	})
+	t.Run("counts survive option updates", func(t *testing.T) {
+		a := newAuthorize(t, 0)
+		check(t, a, "bob@example.com", "acme")
+		check(t, a, "bob@example.com", "acme")
+		opts := a.currentOptions.Load()
+		opts.TenantQuotaStatusCode = http.StatusPaymentRequired
+		if err := a.UpdateOptions(opts); err != nil {
+			t.Fatal(err)
+		}
+		code, hdrs := check(t, a, "bob@example.com", "acme")
+		assert.Equal(t, http.StatusPaymentRequired, code)
+		assert.Equal(t, "1800", hdrs.Get("Retry-After"))
+	})
+}
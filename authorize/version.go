@@ -0,0 +1,49 @@
+package authorize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/version"
+)
+
+// VersionPath is the path of the authorize service's version endpoint.
+const VersionPath = "/.pomerium/version"
+
+// GetVersion returns the build version of the authorize service and the
+// checksum of its current configuration, so that operators can confirm which
+// version is serving during rolling upgrades. The configuration itself isn't
+// included, so no authentication is required.
+func (a *Authorize) GetVersion(ctx context.Context, _ *authorize.GetVersionRequest) (*authorize.GetVersionReply, error) {
+	return a.getVersion(), nil
+}
+
+func (a *Authorize) getVersion() *authorize.GetVersionReply {
+	opts := a.currentOptions.Load()
+	return &authorize.GetVersionReply{
+		Version:        version.FullVersion(),
+		GitCommit:      version.GitCommit,
+		ConfigChecksum: fmt.Sprintf("%x", opts.Checksum()),
+	}
+}
+
+// VersionHandler returns an http handler which responds with the same version
+// information as GetVersion, as JSON.
+func (a *Authorize) VersionHandler() http.Handler {
+	return httputil.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		jBytes, err := json.Marshal(a.getVersion())
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "%s", jBytes)
+		return nil
+	})
+}
@@ -0,0 +1,62 @@
+package authorize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/grpc/authorize"
+	"github.com/pomerium/pomerium/internal/version"
+)
+
+func TestAuthorize_GetVersion(t *testing.T) {
+	oldVersion, oldGitCommit := version.Version, version.GitCommit
+	version.Version, version.GitCommit = "v1.2.3", "abcdef0"
+	defer func() { version.Version, version.GitCommit = oldVersion, oldGitCommit }()
+
+	opts := *config.NewDefaultOptions()
+	opts.AuthenticateURL = mustParseURL("https://authN.example.com")
+	opts.SharedKey = cryptutil.NewBase64Key()
+	a, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &authorize.GetVersionReply{
+		Version:        "v1.2.3+abcdef0",
+		GitCommit:      "abcdef0",
+		ConfigChecksum: fmt.Sprintf("%x", opts.Checksum()),
+	}
+
+	t.Run("grpc", func(t *testing.T) {
+		got, err := a.GetVersion(context.TODO(), &authorize.GetVersionRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, want.Version, got.GetVersion())
+		assert.Equal(t, want.GitCommit, got.GetGitCommit())
+		assert.Equal(t, want.ConfigChecksum, got.GetConfigChecksum())
+	})
+	t.Run("http", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		a.VersionHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, VersionPath, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.NotContains(t, w.Body.String(), opts.SharedKey)
+		var got map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, map[string]string{
+			"version":         want.Version,
+			"git_commit":      want.GitCommit,
+			"config_checksum": want.ConfigChecksum,
+		}, got)
+	})
+}
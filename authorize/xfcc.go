@@ -0,0 +1,133 @@
+package authorize
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"net/url"
+	"strings"
+
+	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
+
+	"github.com/pomerium/pomerium/authorize/evaluator"
+	"github.com/pomerium/pomerium/internal/httputil"
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+// getForwardedClientCertificateChain returns the certificate chain, leaf
+// first, of the original client from the x-forwarded-client-cert header.
+func getForwardedClientCertificateChain(in *envoy_service_auth_v2.CheckRequest) []evaluator.Certificate {
+	hdrs := in.GetAttributes().GetRequest().GetHttp().GetHeaders()
+	elements := parseXFCC(hdrs[strings.ToLower(httputil.HeaderForwardedClientCert)])
+	if len(elements) == 0 {
+		return nil
+	}
+
+	// each proxy appends an element, so the first belongs to the original
+	// client. Chain includes the leaf certificate, Cert is only the leaf.
+	values := elements[0]["chain"]
+	if len(values) == 0 {
+		values = elements[0]["cert"]
+	}
+
+	var chain []evaluator.Certificate
+	for _, v := range values {
+		data, err := url.PathUnescape(v)
+		if err != nil {
+			log.Warn().Err(err).Msg("authorize: invalid x-forwarded-client-cert certificate encoding")
+			return nil
+		}
+		rest := []byte(data)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				log.Warn().Err(err).Msg("authorize: invalid x-forwarded-client-cert certificate")
+				return nil
+			}
+			chain = append(chain, newEvaluatorCertificate(cert))
+		}
+	}
+	return chain
+}
+
+func newEvaluatorCertificate(cert *x509.Certificate) evaluator.Certificate {
+	fingerprint := sha256.Sum256(cert.Raw)
+	c := evaluator.Certificate{
+		Subject:        cert.Subject.String(),
+		Issuer:         cert.Issuer.String(),
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		NotBefore:      cert.NotBefore.Unix(),
+		NotAfter:       cert.NotAfter.Unix(),
+		Fingerprint:    hex.EncodeToString(fingerprint[:]),
+	}
+	for _, u := range cert.URIs {
+		c.URIs = append(c.URIs, u.String())
+	}
+	return c
+}
+
+// parseXFCC parses an x-forwarded-client-cert header value into its
+// comma-separated elements. Each element maps its lower-cased keys (by, hash,
+// cert, chain, subject, uri, dns) to their values, as keys may be repeated.
+func parseXFCC(value string) []map[string][]string {
+	var elements []map[string][]string
+	for _, rawElement := range splitQuoted(value, ',') {
+		element := make(map[string][]string)
+		for _, pair := range splitQuoted(rawElement, ';') {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			k := strings.ToLower(strings.TrimSpace(kv[0]))
+			element[k] = append(element[k], unquote(strings.TrimSpace(kv[1])))
+		}
+		if len(element) > 0 {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+// splitQuoted splits s on sep, ignoring separators within double quotes.
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	var quoted, escaped bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\' && quoted:
+			escaped = true
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquote removes the surrounding double quotes, and escapes, of a value.
+func unquote(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
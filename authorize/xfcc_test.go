@@ -0,0 +1,75 @@
+package authorize
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseXFCC(t *testing.T) {
+	t.Parallel()
+	value := `By=spiffe://cluster.local/ns/default/sa/pomerium;Hash=abc;Subject="CN=client,O=\"Acme; Inc\"";URI=spiffe://cluster.local/ns/default/sa/client;DNS=a.example.com;DNS=b.example.com,By=spiffe://cluster.local/ns/default/sa/edge;Hash=def`
+	got := parseXFCC(value)
+	assert.Equal(t, []map[string][]string{
+		{
+			"by":      {"spiffe://cluster.local/ns/default/sa/pomerium"},
+			"hash":    {"abc"},
+			"subject": {`CN=client,O="Acme; Inc"`},
+			"uri":     {"spiffe://cluster.local/ns/default/sa/client"},
+			"dns":     {"a.example.com", "b.example.com"},
+		},
+		{
+			"by":   {"spiffe://cluster.local/ns/default/sa/edge"},
+			"hash": {"def"},
+		},
+	}, got)
+	assert.Nil(t, parseXFCC(""))
+}
+
+func Test_getForwardedClientCertificateChain(t *testing.T) {
+	t.Parallel()
+	ca, caKey := testCertificateAuthority(t)
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}))
+	leafPEM := testSVID(t, ca, caKey, "spiffe://cluster.local/ns/default/sa/client")
+	block, _ := pem.Decode([]byte(leafPEM))
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		xfcc         string
+		wantIssuers  []string
+		wantLeafURIs []string
+	}{
+		{"none", "", nil, nil},
+		{"cert", `Hash=abc;Cert="` + url.PathEscape(leafPEM) + `"`, []string{"CN=mesh ca"}, []string{"spiffe://cluster.local/ns/default/sa/client"}},
+		{"chain", `Hash=abc;Cert="` + url.PathEscape(leafPEM) + `";Chain="` + url.PathEscape(leafPEM+caPEM) + `"`, []string{"CN=mesh ca", "CN=mesh ca"}, []string{"spiffe://cluster.local/ns/default/sa/client"}},
+		{"first element", `Cert="` + url.PathEscape(leafPEM) + `",Cert="` + url.PathEscape(caPEM) + `"`, []string{"CN=mesh ca"}, []string{"spiffe://cluster.local/ns/default/sa/client"}},
+		{"bad cert", `Cert="` + url.PathEscape("-----BEGIN CERTIFICATE-----\nAAAA\n-----END CERTIFICATE-----\n") + `"`, nil, nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			in := testCheckRequest("GET", "https://example.com/", map[string]string{
+				"x-forwarded-client-cert": tt.xfcc,
+			})
+			chain := getForwardedClientCertificateChain(in)
+			var issuers []string
+			for _, c := range chain {
+				issuers = append(issuers, c.Issuer)
+			}
+			assert.Equal(t, tt.wantIssuers, issuers)
+			if len(chain) > 0 {
+				assert.Equal(t, tt.wantLeafURIs, chain[0].URIs)
+				assert.Equal(t, leaf.NotAfter.Unix(), chain[0].NotAfter)
+				assert.Len(t, chain[0].Fingerprint, 64)
+			}
+		})
+	}
+}
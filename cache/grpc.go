@@ -4,8 +4,10 @@ package cache
 
 import (
 	"context"
+	"errors"
 
 	"github.com/pomerium/pomerium/internal/grpc/cache"
+	"github.com/pomerium/pomerium/internal/kv"
 	"github.com/pomerium/pomerium/internal/telemetry/trace"
 
 	"google.golang.org/grpc/codes"
@@ -33,3 +35,17 @@ func (c *Cache) Set(ctx context.Context, in *cache.SetRequest) (*cache.SetReply,
 	}
 	return &cache.SetReply{}, nil
 }
+
+// Delete removes a key from the cache store. Stores which can't delete keys,
+// such as autocache, return an Unimplemented error.
+func (c *Cache) Delete(ctx context.Context, in *cache.DeleteRequest) (*cache.DeleteReply, error) {
+	ctx, span := trace.StartSpan(ctx, "cache.grpc.Delete")
+	defer span.End()
+	err := c.cache.Delete(ctx, in.GetKey())
+	if errors.Is(err, kv.ErrNotSupported) {
+		return nil, status.Errorf(codes.Unimplemented, "cache.grpc.Delete error: %v", err)
+	} else if err != nil {
+		return nil, status.Errorf(codes.Unknown, "cache.grpc.Delete error: %v", err)
+	}
+	return &cache.DeleteReply{}, nil
+}
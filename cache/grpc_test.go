@@ -105,3 +105,38 @@ func TestCache_Get_and_Set(t *testing.T) {
 		})
 	}
 }
+
+func TestCache_Delete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	c, err := New(config.Options{
+		CacheStorePath: dir + "/bolt.db", CacheStore: "bolt",
+		SharedKey: cryptutil.NewBase64Key(),
+		CacheURL:  &url.URL{Scheme: "http", Host: "example"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer c.Close()
+
+	ctx := context.TODO()
+	if _, err := c.Set(ctx, &cache.SetRequest{Key: "key", Value: []byte("hello")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Delete(ctx, &cache.DeleteRequest{Key: "key"}); err != nil {
+		t.Fatalf("Cache.Delete() error = %v", err)
+	}
+	got, err := c.Get(ctx, &cache.GetRequest{Key: "key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetExists() {
+		t.Error("Cache.Get() of a deleted key should miss")
+	}
+	// deleting a missing key isn't an error
+	if _, err := c.Delete(ctx, &cache.DeleteRequest{Key: "no-such-key"}); err != nil {
+		t.Errorf("Cache.Delete() error = %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// The broadest networks health probes may be sent from. Probes come from a
+// load balancer's or uptime checker's few addresses, so a broader network is
+// more likely a mistake which exempts clients too.
+const (
+	minHealthProbeIPv4Prefix = 24
+	minHealthProbeIPv6Prefix = 64
+)
+
+// HealthProbe exempts load balancer and uptime checker probes of routes'
+// health check paths from authentication.
+type HealthProbe struct {
+	// Sources are the IPs or CIDRs the probes are sent from.
+	Sources []string `mapstructure:"sources" yaml:"sources"`
+	// Paths are the exempt paths, e.g. /healthz. If empty, every path is
+	// exempt.
+	Paths []string `mapstructure:"paths" yaml:"paths,omitempty"`
+}
+
+// Validate checks the validity of a health probe.
+func (p *HealthProbe) Validate() error {
+	if len(p.Sources) == 0 {
+		return errors.New("config: health probe sources are required")
+	}
+	if _, err := p.GetSourceNetworks(); err != nil {
+		return err
+	}
+	for _, path := range p.Paths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("config: health probe path %s must start with /", path)
+		}
+	}
+	return nil
+}
+
+// GetSourceNetworks returns the parsed Sources. IPs are returned as single
+// address networks.
+func (p *HealthProbe) GetSourceNetworks() ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, source := range p.Sources {
+		network, err := parseIPNetwork(source)
+		if err != nil {
+			return nil, fmt.Errorf("config: bad health probe source %s", source)
+		}
+		ones, bits := network.Mask.Size()
+		if network.IP.IsUnspecified() ||
+			(bits == 8*net.IPv4len && ones < minHealthProbeIPv4Prefix) ||
+			(bits == 8*net.IPv6len && ones < minHealthProbeIPv6Prefix) {
+			return nil, fmt.Errorf("config: health probe source %s is too broad", source)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// parseIPNetwork parses an IP or a CIDR. An IP is returned as a single
+// address network.
+func parseIPNetwork(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, network, err := net.ParseCIDR(s)
+	return network, err
+}
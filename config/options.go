@@ -6,15 +6,22 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/encoding"
+	"github.com/pomerium/pomerium/internal/encoding/jws"
+	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/telemetry/metrics"
 	"github.com/pomerium/pomerium/internal/urlutil"
@@ -51,10 +58,20 @@ type Options struct {
 	// Possible options are "info","warn", and "error". Defaults to the value of `LogLevel`.
 	ProxyLogLevel string `mapstructure:"proxy_log_level" yaml:"proxy_log_level,omitempty"`
 
+	// AuthorizeLogSampleRate is the fraction, between 0 and 1, of allowed
+	// requests for which the authorize check is logged. Denied requests are
+	// always logged. Defaults to 1.
+	AuthorizeLogSampleRate float64 `mapstructure:"authorize_log_sample_rate" yaml:"authorize_log_sample_rate,omitempty"`
+
 	// SharedKey is the shared secret authorization key used to mutually authenticate
 	// requests between services.
 	SharedKey string `mapstructure:"shared_secret" yaml:"shared_secret,omitempty"`
 
+	// SharedKeys are named keys, by reference, which routes may sign their
+	// sessions with in place of SharedKey, so that a tenant's sessions can
+	// only be used on its own routes.
+	SharedKeys map[string]string `mapstructure:"shared_secrets" yaml:"shared_secrets,omitempty"`
+
 	// Services is a list enabled service mode. If none are selected, "all" is used.
 	// Available options are : "all", "authenticate", "proxy".
 	Services string `mapstructure:"services" yaml:"services,omitempty"`
@@ -104,6 +121,18 @@ type Options struct {
 	WriteTimeout time.Duration `mapstructure:"timeout_write" yaml:"timeout_write,omitempty"`
 	IdleTimeout  time.Duration `mapstructure:"timeout_idle" yaml:"timeout_idle,omitempty"`
 
+	// Proxy100Continue, if set, passes "Expect: 100-continue" requests on to
+	// the upstream, which sends the "100 Continue" once the request has been
+	// authorized. Otherwise envoy sends it as soon as the request is received,
+	// so that clients may start uploading a body that is then denied.
+	Proxy100Continue bool `mapstructure:"proxy_100_continue" yaml:"proxy_100_continue"`
+
+	// NormalizeRequestPath, if set, resolves dot segments and repeated
+	// slashes in the request path before it's matched to a route policy, so
+	// that e.g. "/public/../admin" matches the policy for "/admin". The raw
+	// path is still sent to the upstream.
+	NormalizeRequestPath bool `mapstructure:"normalize_request_path" yaml:"normalize_request_path"`
+
 	// Policies define per-route configuration and access control policies.
 	Policies   []Policy `yaml:"policy,omitempty"`
 	PolicyEnv  string   `yaml:",omitempty"`
@@ -114,6 +143,11 @@ type Options struct {
 	AuthenticateURLString string   `mapstructure:"authenticate_service_url" yaml:"authenticate_service_url,omitempty"`
 	AuthenticateURL       *url.URL `yaml:"-,omitempty"`
 
+	// AuthenticateFailoverURLs are additional authenticate service endpoints,
+	// in order of preference, used when the primary endpoint is unhealthy.
+	AuthenticateFailoverURLStrings []string   `mapstructure:"authenticate_service_failover_urls" yaml:"authenticate_service_failover_urls,omitempty"`
+	AuthenticateFailoverURLs       []*url.URL `yaml:"-,omitempty"`
+
 	// AuthenticateCallbackPath is the path to the HTTP endpoint that will
 	// receive the response from your identity provider. The value must exactly
 	// match one of the authorized redirect URIs for the OAuth 2.0 client.
@@ -128,6 +162,23 @@ type Options struct {
 	CookieSecure   bool          `mapstructure:"cookie_secure" yaml:"cookie_secure,omitempty"`
 	CookieHTTPOnly bool          `mapstructure:"cookie_http_only" yaml:"cookie_http_only,omitempty"`
 	CookieExpire   time.Duration `mapstructure:"cookie_expire" yaml:"cookie_expire,omitempty"`
+	// CookieMaxChunks limits the number of chunks, after the first, that a
+	// session cookie is read from. Defaults to 5.
+	CookieMaxChunks int `mapstructure:"cookie_max_chunks" yaml:"cookie_max_chunks,omitempty"`
+	// CookieChunkThreshold is the size, in bytes, above which a session
+	// cookie is split into chunks.
+	CookieChunkThreshold int `mapstructure:"cookie_chunk_threshold" yaml:"cookie_chunk_threshold,omitempty"`
+	// CookieLegacyNames are previous session cookie names, tried in order
+	// when there's no session cookie. Sessions loaded from them are re-issued
+	// under CookieName, so that the name can be changed without signing
+	// everyone out.
+	CookieLegacyNames []string `mapstructure:"cookie_legacy_names" yaml:"cookie_legacy_names,omitempty"`
+	// CookieMigrateDomain, if set, migrates session cookies issued under
+	// CookieLegacyDomain, host-only if empty, to CookieDomain: they're
+	// re-issued under CookieDomain and cleared under the legacy domain, so
+	// that the domain can be changed without signing everyone out.
+	CookieMigrateDomain bool   `mapstructure:"cookie_migrate_domain" yaml:"cookie_migrate_domain,omitempty"`
+	CookieLegacyDomain  string `mapstructure:"cookie_legacy_domain" yaml:"cookie_legacy_domain,omitempty"`
 
 	// Identity provider configuration variables as specified by RFC6749
 	// https://openid.net/specs/openid-connect-basic-1_0.html#RFC6749
@@ -142,6 +193,10 @@ type Options struct {
 	// (sudo) access including the ability to impersonate other users' access
 	Administrators []string `mapstructure:"administrators" yaml:"administrators,omitempty"`
 
+	// GlobalAllowedGroups, if set, are groups a user must be a member of at
+	// least one of to access any route, in addition to the route's policy.
+	GlobalAllowedGroups []string `mapstructure:"global_allowed_groups" yaml:"global_allowed_groups,omitempty"`
+
 	// AuthorizeURL is the routable destination of the authorize service's
 	// gRPC endpoint. NOTE: As many load balancers do not support
 	// externally routed gRPC so this may be an internal location.
@@ -164,6 +219,227 @@ type Options struct {
 	// List of JWT claims to insert as x-pomerium-claim-* headers on proxied requests
 	JWTClaimsHeaders []string `mapstructure:"jwt_claims_headers" yaml:"jwt_claims_headers,omitempty"`
 
+	// SessionExpiresInHeader, if set, adds a header with the seconds until
+	// the session expires to the responses of allowed requests, so that
+	// single-page apps can refresh it before then.
+	SessionExpiresInHeader bool `mapstructure:"session_expires_in_header" yaml:"session_expires_in_header,omitempty"`
+
+	// PassProtocolHeader, if set, passes the client's HTTP protocol version
+	// (e.g. HTTP/2) upstream in a header on allowed requests.
+	PassProtocolHeader bool `mapstructure:"pass_protocol_header" yaml:"pass_protocol_header,omitempty"`
+
+	// ReservedHeaderPrefix is the prefix of the request headers reserved for
+	// pomerium, e.g. x-pomerium-claim-email. Headers with the prefix are
+	// removed from requests before they're authorized, so that clients can't
+	// spoof them to the upstream.
+	ReservedHeaderPrefix string `mapstructure:"reserved_header_prefix" yaml:"reserved_header_prefix,omitempty"`
+
+	// AuthorizeRequestHeaders is a list of request headers whose raw values
+	// are made available to the policy evaluator as `raw_headers`.
+	AuthorizeRequestHeaders []string `mapstructure:"authorize_request_headers" yaml:"authorize_request_headers,omitempty"`
+
+	// AuthorizeSplitHeaders is a list of multi-value request headers (e.g.
+	// X-Forwarded-For) whose comma-joined values are split into separate
+	// values before being passed to the policy evaluator.
+	AuthorizeSplitHeaders []string `mapstructure:"authorize_split_headers" yaml:"authorize_split_headers,omitempty"`
+
+	// TrustedProxies is a list of IPs or CIDRs of proxies in front of
+	// pomerium. For requests from them, the client's IP and scheme are taken
+	// from the Forwarded, or X-Forwarded-For and X-Forwarded-Proto, headers.
+	TrustedProxies []string `mapstructure:"trusted_proxies" yaml:"trusted_proxies,omitempty"`
+
+	// UnknownClientIP is the handling of requests whose client IP can't be
+	// determined, e.g. over a pipe, which IP rules can't be applied to:
+	// UnknownClientIPSkip (the default), UnknownClientIPDeny or
+	// UnknownClientIPAllow.
+	UnknownClientIP string `mapstructure:"unknown_client_ip" yaml:"unknown_client_ip,omitempty"`
+
+	// GeoIPDatabase is the path of a MaxMind GeoIP2 or GeoLite2 country or
+	// city database, which the client's country and region are looked up in
+	// for the policy evaluator.
+	GeoIPDatabase string `mapstructure:"geoip_database" yaml:"geoip_database,omitempty"`
+
+	// PolicyTimezone is the IANA time zone, e.g. America/New_York, of the
+	// local time which routes' allowed weekdays and hours are evaluated in.
+	// It defaults to UTC.
+	PolicyTimezone string `mapstructure:"policy_timezone" yaml:"policy_timezone,omitempty"`
+
+	// ExternalScheme and ExternalPort, if set, replace the scheme and port of
+	// requests when constructing redirects back to them, such as after sign
+	// in, for deployments behind TLS-terminating load balancers.
+	ExternalScheme string `mapstructure:"external_scheme" yaml:"external_scheme,omitempty"`
+	ExternalPort   string `mapstructure:"external_port" yaml:"external_port,omitempty"`
+
+	// CORSAllowedOrigins is a list of origins (e.g. `https://app.example.com`)
+	// that are allowed to read denied responses to cross-origin requests.
+	CORSAllowedOrigins []string `mapstructure:"cors_allowed_origins" yaml:"cors_allowed_origins,omitempty"`
+
+	// WWWAuthenticateRealm and WWWAuthenticateSchemes are advertised in the
+	// WWW-Authenticate header of unauthenticated responses which aren't
+	// redirected to sign in. Default to "pomerium" and "Bearer".
+	WWWAuthenticateRealm   string   `mapstructure:"www_authenticate_realm" yaml:"www_authenticate_realm,omitempty"`
+	WWWAuthenticateSchemes []string `mapstructure:"www_authenticate_schemes" yaml:"www_authenticate_schemes,omitempty"`
+
+	// SessionAuthorizationSchemes are the schemes of Authorization headers
+	// sessions are loaded from, e.g. "Bearer", matched case-insensitively.
+	// Defaults to "Pomerium".
+	SessionAuthorizationSchemes []string `mapstructure:"session_authorization_schemes" yaml:"session_authorization_schemes,omitempty"`
+
+	// SessionSigningAlgorithm is the algorithm sessions are signed with, one
+	// of HS256 (the default), HS384 or HS512. Sessions whose alg header is
+	// any other algorithm, including "none", are rejected.
+	SessionSigningAlgorithm string `mapstructure:"session_signing_algorithm" yaml:"session_signing_algorithm,omitempty"`
+
+	// CompressSessions, if set, DEFLATE compresses the payloads of sessions
+	// when doing so makes them smaller, e.g. for users in many groups, so
+	// that they need fewer cookie chunks. Compressed sessions are accepted
+	// whether or not it's set.
+	CompressSessions bool `mapstructure:"compress_sessions" yaml:"compress_sessions,omitempty"`
+
+	// MaxTokenAge is the maximum time since a session token was issued (iat)
+	// after which it is rejected, regardless of its expiry. Disabled if zero.
+	MaxTokenAge time.Duration `mapstructure:"max_token_age" yaml:"max_token_age,omitempty"`
+
+	// AllowedSessionIssuers, if set, is the list of issuers (iss) which
+	// sessions must have been issued by. Typically the hostname of the
+	// authenticate service.
+	AllowedSessionIssuers []string `mapstructure:"allowed_session_issuers" yaml:"allowed_session_issuers,omitempty"`
+
+	// ExpiredSessionGracePeriod is how long after a session expires, and
+	// can't be refreshed, that it's still accepted for requests using one of
+	// the ExpiredSessionGraceMethods. Disabled if zero.
+	ExpiredSessionGracePeriod  time.Duration `mapstructure:"expired_session_grace_period" yaml:"expired_session_grace_period,omitempty"`
+	ExpiredSessionGraceMethods []string      `mapstructure:"expired_session_grace_methods" yaml:"expired_session_grace_methods,omitempty"`
+
+	// AuthenticateOutageGracePeriod is how long after a session expires that
+	// it's still accepted, for any request, while the authenticate service is
+	// down and the session can't be refreshed. Disabled if zero.
+	AuthenticateOutageGracePeriod time.Duration `mapstructure:"authenticate_outage_grace_period" yaml:"authenticate_outage_grace_period,omitempty"`
+
+	// MinRefreshInterval is how long a refreshed session is reused for
+	// requests which still carry the session it replaced, instead of
+	// refreshing it again. Disabled if zero.
+	MinRefreshInterval time.Duration `mapstructure:"min_refresh_interval" yaml:"min_refresh_interval,omitempty"`
+
+	// DeniedMethods is a list of HTTP methods which are denied for every
+	// route, before sessions are loaded or policy is evaluated. Defaults to
+	// TRACE, CONNECT and TRACK.
+	DeniedMethods []string `mapstructure:"denied_methods" yaml:"denied_methods,omitempty"`
+
+	// GlobalAllowedMethods is a list of HTTP methods which are allowed for
+	// every route, checked before sessions are loaded or policy is evaluated.
+	// Other standard methods are denied as not allowed, and non-standard
+	// methods as not implemented. Defaults to the standard methods. If empty,
+	// every method is allowed.
+	GlobalAllowedMethods []string `mapstructure:"global_allowed_methods" yaml:"global_allowed_methods,omitempty"`
+
+	// HeadAsGet, if set, authorizes HEAD requests as GET requests, so that
+	// routes and policies written for GET also apply to HEAD.
+	HeadAsGet bool `mapstructure:"head_as_get" yaml:"head_as_get,omitempty"`
+
+	// DeniedUserAgents is a list of regular expressions matched against the
+	// User-Agent header. Matching requests are denied for every route, before
+	// policy is evaluated.
+	DeniedUserAgents []string `mapstructure:"denied_user_agents" yaml:"denied_user_agents,omitempty"`
+
+	// RequireUserAgent, if set, denies requests without a User-Agent header,
+	// or with an empty one, for every route, before policy is evaluated.
+	RequireUserAgent bool `mapstructure:"require_user_agent" yaml:"require_user_agent,omitempty"`
+
+	// MaxRequestHeaders and MaxRequestHeaderBytes limit the number, and the
+	// total size of the names and values, of the headers of a request.
+	// Requests over either limit are denied with a 431 before they're
+	// processed further. If 0, there's no limit.
+	MaxRequestHeaders     int `mapstructure:"max_request_headers" yaml:"max_request_headers,omitempty"`
+	MaxRequestHeaderBytes int `mapstructure:"max_request_header_bytes" yaml:"max_request_header_bytes,omitempty"`
+
+	// MaxRedirectURILength limits the length of the url users are sent back
+	// to after signing in. Longer urls are replaced by the root of the route,
+	// so that a crafted url can't make the sign in url too large. If 0, there's
+	// no limit.
+	MaxRedirectURILength int `mapstructure:"max_redirect_uri_length" yaml:"max_redirect_uri_length,omitempty"`
+
+	// SupportURL, if set, is linked to from deny pages, along with the
+	// request's id as a reference code, so that denied users can contact
+	// support.
+	SupportURLString string   `mapstructure:"support_url" yaml:"support_url,omitempty"`
+	SupportURL       *url.URL `yaml:",omitempty"`
+
+	// SecurityEvents enables a security event, on a separate log stream, for
+	// each denied request.
+	SecurityEvents bool `mapstructure:"security_events" yaml:"security_events,omitempty"`
+
+	// AuditLogFile, if set, is the file to which an audit record of each
+	// authorization decision is written. The file is rotated once it reaches
+	// AuditLogMaxSize megabytes or AuditLogMaxAge, keeping AuditLogMaxBackups
+	// rotated files, and synced to disk every AuditLogFlushInterval.
+	AuditLogFile          string        `mapstructure:"audit_log_file" yaml:"audit_log_file,omitempty"`
+	AuditLogMaxSize       int           `mapstructure:"audit_log_max_size" yaml:"audit_log_max_size,omitempty"`
+	AuditLogMaxAge        time.Duration `mapstructure:"audit_log_max_age" yaml:"audit_log_max_age,omitempty"`
+	AuditLogMaxBackups    int           `mapstructure:"audit_log_max_backups" yaml:"audit_log_max_backups,omitempty"`
+	AuditLogFlushInterval time.Duration `mapstructure:"audit_log_flush_interval" yaml:"audit_log_flush_interval,omitempty"`
+
+	// AuditLogRequests, if set, adds the evaluated request, without
+	// credentials, to each audit record, so that it can be replayed against
+	// the current policy by administrators.
+	AuditLogRequests bool `mapstructure:"audit_log_requests" yaml:"audit_log_requests,omitempty"`
+
+	// HashLogUsers, if set, replaces user identifiers, the email and user id,
+	// with a hash of them salted with LogUserHashSalt in the authorize check
+	// log, audit log, decision log, security events and traces. The same user
+	// has the same hash as long as the salt doesn't change. Without a salt, a
+	// random salt is used for the life of the process.
+	HashLogUsers    bool   `mapstructure:"hash_log_users" yaml:"hash_log_users,omitempty"`
+	LogUserHashSalt string `mapstructure:"log_user_hash_salt" yaml:"log_user_hash_salt,omitempty"`
+
+	// DecisionLogURL, if set, is the endpoint to which each authorization
+	// decision is sent in OPA's decision log format, so that OPA's decision
+	// log tooling can be used. Decisions are sent in batches of up to
+	// DecisionLogBatchSize, at least every DecisionLogFlushInterval.
+	DecisionLogURLString     string        `mapstructure:"decision_log_url" yaml:"decision_log_url,omitempty"`
+	DecisionLogURL           *url.URL      `yaml:",omitempty"`
+	DecisionLogBatchSize     int           `mapstructure:"decision_log_batch_size" yaml:"decision_log_batch_size,omitempty"`
+	DecisionLogFlushInterval time.Duration `mapstructure:"decision_log_flush_interval" yaml:"decision_log_flush_interval,omitempty"`
+
+	// NoPolicyMatch is the decision for routes without any applicable policy
+	// rules: NoPolicyMatchDeny (the default) or NoPolicyMatchAllow.
+	NoPolicyMatch string `mapstructure:"no_policy_match" yaml:"no_policy_match,omitempty"`
+
+	// AnonymousUser, if set, is the subject of the identity that requests to
+	// public routes without a session are evaluated with, so that policy
+	// sees a consistent user for them.
+	AnonymousUser string `mapstructure:"anonymous_user" yaml:"anonymous_user,omitempty"`
+
+	// HostConflict is the handling of requests with a Host header which
+	// conflicts with the host envoy reports for them, e.g. an HTTP/2 request's
+	// :authority: HostConflictDeny (the default), HostConflictStrict or
+	// HostConflictAllow.
+	HostConflict string `mapstructure:"host_conflict" yaml:"host_conflict,omitempty"`
+
+	// AllowOptionsAsterisk, if set, answers server-wide "OPTIONS *" requests,
+	// which don't match any route, instead of denying them.
+	AllowOptionsAsterisk bool `mapstructure:"allow_options_asterisk" yaml:"allow_options_asterisk,omitempty"`
+
+	// DenySNIHostMismatch, if set, denies requests whose host differs from the
+	// server name (SNI) of their TLS connection, which may be domain fronting.
+	// Off by default, as browsers reuse connections for hosts which share a
+	// certificate.
+	DenySNIHostMismatch bool `mapstructure:"deny_sni_host_mismatch" yaml:"deny_sni_host_mismatch,omitempty"`
+
+	// DebugDecisionTime adds a header with the time taken to make the
+	// authorization decision to responses for administrators.
+	DebugDecisionTime bool `mapstructure:"debug_decision_time" yaml:"debug_decision_time,omitempty"`
+
+	// DebugServerTiming adds a Server-Timing header with the time taken by
+	// the authorize check, and the user it was made for, to responses for
+	// administrators.
+	DebugServerTiming bool `mapstructure:"debug_server_timing" yaml:"debug_server_timing,omitempty"`
+
+	// DebugPolicy enables endpoints for administrators which explain how
+	// policy is evaluated for a sample request, or for a past request
+	// captured in the audit log.
+	DebugPolicy bool `mapstructure:"debug_policy" yaml:"debug_policy,omitempty"`
+
 	// RefreshCooldown limits the rate a user can refresh her session
 	RefreshCooldown time.Duration `mapstructure:"refresh_cooldown" yaml:"refresh_cooldown,omitempty"`
 
@@ -202,6 +478,10 @@ type Options struct {
 	// If running in all-in-one mode, defaults to true.
 	GRPCInsecure bool `mapstructure:"grpc_insecure" yaml:"grpc_insecure,omitempty"`
 
+	// GRPCServerReflection registers the gRPC reflection service so that
+	// tools like grpcurl can introspect the gRPC services. Disabled by default.
+	GRPCServerReflection bool `mapstructure:"grpc_server_reflection" yaml:"grpc_server_reflection,omitempty"`
+
 	GRPCClientTimeout       time.Duration `mapstructure:"grpc_client_timeout" yaml:"grpc_client_timeout,omitempty"`
 	GRPCClientDNSRoundRobin bool          `mapstructure:"grpc_client_dns_roundrobin" yaml:"grpc_client_dns_roundrobin,omitempty"`
 
@@ -238,11 +518,69 @@ type Options struct {
 	// CacheStorePath is the path to use for a given cache store. e.g. /etc/bolt.db
 	CacheStorePath string `mapstructure:"cache_store_path" yaml:"cache_store_path,omitempty"`
 
+	// SessionRevocationStoreAddr is the host and port of a redis server used
+	// to share revoked sessions between instances. If unset, revoked sessions
+	// are kept in memory.
+	SessionRevocationStoreAddr string `mapstructure:"session_revocation_store_address" yaml:"session_revocation_store_address,omitempty"`
+	// SessionRevocationStorePassword is the password used to connect to the
+	// session revocation store.
+	SessionRevocationStorePassword string `mapstructure:"session_revocation_store_password" yaml:"session_revocation_store_password,omitempty"`
+	// SessionRevocationStoreRetryAttempts is the maximum number of attempts
+	// of each call to the session revocation store, including the first, to
+	// smooth over transient errors.
+	SessionRevocationStoreRetryAttempts int `mapstructure:"session_revocation_store_retry_attempts" yaml:"session_revocation_store_retry_attempts,omitempty"`
+	// SessionRevocationStoreRetryDelay is the delay before the first retry of
+	// a failed call to the session revocation store, doubled for each one
+	// after.
+	SessionRevocationStoreRetryDelay time.Duration `mapstructure:"session_revocation_store_retry_delay" yaml:"session_revocation_store_retry_delay,omitempty"`
+	// SessionRevocationStoreMaxConnections is the maximum number of
+	// concurrent connections to the session revocation store, so that a burst
+	// of requests can't overwhelm it. Unbounded beyond the redis client's
+	// default if zero.
+	SessionRevocationStoreMaxConnections int `mapstructure:"session_revocation_store_max_connections" yaml:"session_revocation_store_max_connections,omitempty"`
+	// SessionRevocationStorePoolTimeout is how long a call to the session
+	// revocation store waits for a connection, once the maximum are in use,
+	// before it fails like an unavailable store.
+	SessionRevocationStorePoolTimeout time.Duration `mapstructure:"session_revocation_store_pool_timeout" yaml:"session_revocation_store_pool_timeout,omitempty"`
+
 	// ClientCA is the base64-encoded certificate authority to validate client mTLS certificates against.
 	ClientCA string `mapstructure:"client_ca" yaml:"client_ca,omitempty"`
 	// ClientCAFile points to a file that contains the certificate authority to validate client mTLS certificates against.
 	ClientCAFile string `mapstructure:"client_ca_file" yaml:"client_ca_file,omitempty"`
 
+	// TrustedMeshIdentities is a list of SPIFFE IDs (e.g.
+	// `spiffe://cluster.local/ns/default/sa/billing`) which, when presented in
+	// a client certificate signed by the client certificate authority, are
+	// allowed without a user session on the routes which allow them.
+	TrustedMeshIdentities []string `mapstructure:"trusted_mesh_identities" yaml:"trusted_mesh_identities,omitempty"`
+
+	// ServiceAccountAPIKeys are the salted hashes of pre-shared API keys
+	// which authenticate requests as service accounts.
+	ServiceAccountAPIKeys []ServiceAccountAPIKey `mapstructure:"service_account_api_keys" yaml:"service_account_api_keys,omitempty"`
+
+	// HealthProbes are the sources of load balancer and uptime checker
+	// probes, and the health check paths they're allowed without a session.
+	HealthProbes []HealthProbe `mapstructure:"health_probes" yaml:"health_probes,omitempty"`
+
+	// TenantClaim is the session claim identifying the user's tenant, whose
+	// requests count towards its TenantQuotas.
+	TenantClaim string `mapstructure:"tenant_claim" yaml:"tenant_claim,omitempty"`
+	// TenantQuotas limit the requests allowed for each tenant in a period.
+	// Tenants without a quota are unlimited.
+	TenantQuotas []TenantQuota `mapstructure:"tenant_quotas" yaml:"tenant_quotas,omitempty"`
+	// TenantQuotaStatusCode is the status of the response to a request from
+	// a tenant which has exhausted its quota, 429 or 402. Defaults to 429.
+	TenantQuotaStatusCode int `mapstructure:"tenant_quota_status_code" yaml:"tenant_quota_status_code,omitempty"`
+
+	// OverrideTokenSecret is the secret used to verify administrators'
+	// break-glass override tokens. If empty, override tokens are not accepted.
+	OverrideTokenSecret string `mapstructure:"override_token_secret" yaml:"override_token_secret,omitempty"`
+
+	// InnerTrustSecret is the secret shared with an outer pomerium, used to
+	// verify the identities it forwards in the inner identity header. If
+	// empty, the inner identity header is ignored.
+	InnerTrustSecret string `mapstructure:"inner_trust_secret" yaml:"inner_trust_secret,omitempty"`
+
 	viper *viper.Viper
 }
 
@@ -252,10 +590,72 @@ type certificateFilePair struct {
 	KeyFile  string `mapstructure:"key" yaml:"key,omitempty"`
 }
 
+// Decisions for routes without any applicable policy rules.
+const (
+	// NoPolicyMatchDeny denies access to the route.
+	NoPolicyMatchDeny = "deny"
+	// NoPolicyMatchAllow treats the route as public.
+	NoPolicyMatchAllow = "allow"
+)
+
+// Handling of requests with conflicting Host headers.
+const (
+	// HostConflictDeny denies requests with hosts which differ, ignoring case
+	// and default ports.
+	HostConflictDeny = "deny"
+	// HostConflictStrict denies requests with hosts which differ at all.
+	HostConflictStrict = "strict"
+	// HostConflictAllow allows requests with conflicting hosts, which are
+	// matched to routes by the host envoy reports.
+	HostConflictAllow = "allow"
+)
+
+// Handling of requests whose client IP can't be determined.
+const (
+	// UnknownClientIPSkip skips IP rules for the request: allowed countries
+	// don't restrict it, and health probe exemptions don't apply to it.
+	UnknownClientIPSkip = "skip"
+	// UnknownClientIPDeny denies the request, for every route.
+	UnknownClientIPDeny = "deny"
+	// UnknownClientIPAllow treats the request as passing IP rules: allowed
+	// countries don't restrict it, and it's exempt from authentication on
+	// health probes' paths, regardless of their sources.
+	UnknownClientIPAllow = "allow"
+)
+
+// standardMethods are the HTTP methods defined by RFC 7231 and RFC 5789.
+var standardMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// IsStandardMethod returns true if the method is one of the HTTP methods
+// defined by RFC 7231 and RFC 5789.
+func IsStandardMethod(method string) bool {
+	for _, standard := range standardMethods {
+		if standard == method {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultReservedHeaderPrefix is the prefix of the request headers reserved
+// for pomerium.
+const DefaultReservedHeaderPrefix = "x-pomerium-"
+
 // DefaultOptions are the default configuration options for pomerium
 var defaultOptions = Options{
 	Debug:                  false,
 	LogLevel:               "debug",
+	AuthorizeLogSampleRate: 1,
 	Services:               "all",
 	CookieHTTPOnly:         true,
 	CookieSecure:           true,
@@ -271,6 +671,12 @@ var defaultOptions = Options{
 	ReadTimeout:                     30 * time.Second,
 	WriteTimeout:                    0, // support streaming by default
 	IdleTimeout:                     5 * time.Minute,
+	Proxy100Continue:                true,
+	ReservedHeaderPrefix:            DefaultReservedHeaderPrefix,
+	NormalizeRequestPath:            true,
+	AuditLogFlushInterval:           time.Second,
+	DecisionLogBatchSize:            100,
+	DecisionLogFlushInterval:        5 * time.Second,
 	RefreshCooldown:                 5 * time.Minute,
 	GRPCAddr:                        ":443",
 	GRPCClientTimeout:               10 * time.Second, // Try to withstand transient service failures for a single request
@@ -281,6 +687,12 @@ var defaultOptions = Options{
 	AuthenticateCallbackPath:        "/oauth2/callback",
 	AutoCertFolder:                  dataDir(),
 	TracingSampleRate:               0.0001,
+	ExpiredSessionGraceMethods:      []string{http.MethodGet, http.MethodHead},
+	DeniedMethods:                   []string{http.MethodTrace, http.MethodConnect, "TRACK"},
+	GlobalAllowedMethods:            standardMethods,
+	MaxRequestHeaders:               200,
+	MaxRequestHeaderBytes:           128 * 1024,
+	MaxRedirectURILength:            2048,
 }
 
 // NewDefaultOptions returns a copy the default options. It's the caller's
@@ -496,6 +908,15 @@ func (o *Options) Validate() error {
 		return errors.New("config: shared-key contains whitespace")
 	}
 
+	for ref, key := range o.SharedKeys {
+		if err := validateRouteSharedKey(key); err != nil {
+			return fmt.Errorf("config: bad shared key %q: %w", ref, err)
+		}
+		if key == o.SharedKey {
+			return fmt.Errorf("config: shared key %q cannot be the shared-key", ref)
+		}
+	}
+
 	if o.AuthenticateURLString != "" {
 		u, err := urlutil.ParseAndValidateURL(o.AuthenticateURLString)
 		if err != nil {
@@ -504,6 +925,18 @@ func (o *Options) Validate() error {
 		o.AuthenticateURL = u
 	}
 
+	if len(o.AuthenticateFailoverURLStrings) > 0 && o.AuthenticateURLString == "" {
+		return errors.New("config: authenticate failover urls require an authenticate-url")
+	}
+	o.AuthenticateFailoverURLs = nil
+	for _, rawURL := range o.AuthenticateFailoverURLStrings {
+		u, err := urlutil.ParseAndValidateURL(rawURL)
+		if err != nil {
+			return fmt.Errorf("config: bad authenticate failover url %s : %w", rawURL, err)
+		}
+		o.AuthenticateFailoverURLs = append(o.AuthenticateFailoverURLs, u)
+	}
+
 	if o.AuthorizeURLString != "" {
 		u, err := urlutil.ParseAndValidateURL(o.AuthorizeURLString)
 		if err != nil {
@@ -528,12 +961,225 @@ func (o *Options) Validate() error {
 		o.ForwardAuthURL = u
 	}
 
+	if o.SupportURLString != "" {
+		u, err := urlutil.ParseAndValidateURL(o.SupportURLString)
+		if err != nil {
+			return fmt.Errorf("config: bad support-url %s : %w", o.SupportURLString, err)
+		}
+		o.SupportURL = u
+	}
+
+	if o.DecisionLogURLString != "" {
+		u, err := urlutil.ParseAndValidateURL(o.DecisionLogURLString)
+		if err != nil {
+			return fmt.Errorf("config: bad decision log url %s : %w", o.DecisionLogURLString, err)
+		}
+		o.DecisionLogURL = u
+		if o.DecisionLogBatchSize < 1 {
+			return fmt.Errorf("config: decision log batch size must be positive: %d", o.DecisionLogBatchSize)
+		}
+		if o.DecisionLogFlushInterval <= 0 {
+			return fmt.Errorf("config: decision log flush interval must be positive: %s", o.DecisionLogFlushInterval)
+		}
+	}
+
+	switch o.NoPolicyMatch {
+	case "", NoPolicyMatchDeny, NoPolicyMatchAllow:
+	default:
+		return fmt.Errorf("config: unknown no policy match decision: %s", o.NoPolicyMatch)
+	}
+
+	switch o.HostConflict {
+	case "", HostConflictDeny, HostConflictStrict, HostConflictAllow:
+	default:
+		return fmt.Errorf("config: unknown host conflict handling: %s", o.HostConflict)
+	}
+
+	switch o.UnknownClientIP {
+	case "", UnknownClientIPSkip, UnknownClientIPDeny, UnknownClientIPAllow:
+	default:
+		return fmt.Errorf("config: bad unknown client ip handling: %s", o.UnknownClientIP)
+	}
+
+	// reserved headers are always removed, and envoy's header names are
+	// lower case
+	if o.ReservedHeaderPrefix == "" {
+		o.ReservedHeaderPrefix = DefaultReservedHeaderPrefix
+	}
+	o.ReservedHeaderPrefix = strings.ToLower(o.ReservedHeaderPrefix)
+
+	deniedMethods := make([]string, len(o.DeniedMethods))
+	for i, method := range o.DeniedMethods {
+		if method == "" {
+			return errors.New("config: denied methods cannot be empty")
+		}
+		deniedMethods[i] = strings.ToUpper(method)
+	}
+	o.DeniedMethods = deniedMethods
+
+	switch o.SessionSigningAlgorithm {
+	case "", "HS256", "HS384", "HS512":
+	default:
+		return fmt.Errorf("config: bad session signing algorithm: %s", o.SessionSigningAlgorithm)
+	}
+
+	for _, scheme := range o.SessionAuthorizationSchemes {
+		if scheme == "" || strings.ContainsAny(scheme, " \t") {
+			return fmt.Errorf("config: bad session authorization scheme: %q", scheme)
+		}
+	}
+
+	globalAllowedMethods := make([]string, len(o.GlobalAllowedMethods))
+	for i, method := range o.GlobalAllowedMethods {
+		if method == "" {
+			return errors.New("config: global allowed methods cannot be empty")
+		}
+		globalAllowedMethods[i] = strings.ToUpper(method)
+	}
+	o.GlobalAllowedMethods = globalAllowedMethods
+
+	if o.CookieMaxChunks < 0 {
+		return fmt.Errorf("config: cookie max chunks cannot be negative: %d", o.CookieMaxChunks)
+	}
+
+	if o.CookieChunkThreshold < 0 {
+		return fmt.Errorf("config: cookie chunk threshold cannot be negative: %d", o.CookieChunkThreshold)
+	}
+
+	if err := validateCookiePrefix(o.CookieName, o.CookieDomain, o.CookieSecure); err != nil {
+		return err
+	}
+	if o.CookieMigrateDomain && strings.TrimPrefix(o.CookieLegacyDomain, ".") == strings.TrimPrefix(o.CookieDomain, ".") {
+		return errors.New("config: cookie legacy domain must differ from the cookie domain")
+	}
+
+	for _, name := range o.CookieLegacyNames {
+		if name == "" {
+			return errors.New("config: cookie legacy names cannot be empty")
+		}
+		if name == o.CookieName {
+			return fmt.Errorf("config: cookie legacy name %q is the cookie name", name)
+		}
+	}
+	for _, p := range o.Policies {
+		// app cookies are set without a domain, on the root path
+		if err := validateCookiePrefix(p.AppCookieName, "", o.CookieSecure); err != nil {
+			return err
+		}
+	}
+
+	if o.ExpiredSessionGracePeriod < 0 {
+		return fmt.Errorf("config: expired session grace period cannot be negative: %s", o.ExpiredSessionGracePeriod)
+	}
+
+	if o.AuthenticateOutageGracePeriod < 0 {
+		return fmt.Errorf("config: authenticate outage grace period cannot be negative: %s", o.AuthenticateOutageGracePeriod)
+	}
+
+	if o.MinRefreshInterval < 0 {
+		return fmt.Errorf("config: min refresh interval cannot be negative: %s", o.MinRefreshInterval)
+	}
+
+	if o.MaxRequestHeaders < 0 {
+		return fmt.Errorf("config: max request headers cannot be negative: %d", o.MaxRequestHeaders)
+	}
+
+	if o.MaxRequestHeaderBytes < 0 {
+		return fmt.Errorf("config: max request header bytes cannot be negative: %d", o.MaxRequestHeaderBytes)
+	}
+
+	if o.MaxRedirectURILength < 0 {
+		return fmt.Errorf("config: max redirect uri length cannot be negative: %d", o.MaxRedirectURILength)
+	}
+
+	if o.MaxTokenAge < 0 {
+		return fmt.Errorf("config: max token age cannot be negative: %s", o.MaxTokenAge)
+	}
+
+	if o.SessionRevocationStoreRetryAttempts < 0 {
+		return fmt.Errorf("config: session revocation store retry attempts cannot be negative: %d", o.SessionRevocationStoreRetryAttempts)
+	}
+
+	if o.SessionRevocationStoreRetryDelay < 0 {
+		return fmt.Errorf("config: session revocation store retry delay cannot be negative: %s", o.SessionRevocationStoreRetryDelay)
+	}
+
+	if o.SessionRevocationStoreMaxConnections < 0 {
+		return fmt.Errorf("config: session revocation store max connections cannot be negative: %d", o.SessionRevocationStoreMaxConnections)
+	}
+
+	if o.SessionRevocationStorePoolTimeout < 0 {
+		return fmt.Errorf("config: session revocation store pool timeout cannot be negative: %s", o.SessionRevocationStorePoolTimeout)
+	}
+
+	if o.AuditLogMaxSize < 0 {
+		return fmt.Errorf("config: audit log max size cannot be negative: %d", o.AuditLogMaxSize)
+	}
+
+	if o.AuthorizeLogSampleRate < 0 || o.AuthorizeLogSampleRate > 1 {
+		return fmt.Errorf("config: authorize log sample rate must be between 0 and 1: %v", o.AuthorizeLogSampleRate)
+	}
+
+	if o.AuditLogMaxAge < 0 {
+		return fmt.Errorf("config: audit log max age cannot be negative: %s", o.AuditLogMaxAge)
+	}
+
+	if o.AuditLogMaxBackups < 0 {
+		return fmt.Errorf("config: audit log max backups cannot be negative: %d", o.AuditLogMaxBackups)
+	}
+
+	if o.AuditLogFlushInterval < 0 {
+		return fmt.Errorf("config: audit log flush interval cannot be negative: %s", o.AuditLogFlushInterval)
+	}
+
+	for _, origin := range o.CORSAllowedOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+			return fmt.Errorf("config: bad cors allowed origin %s", origin)
+		}
+	}
+
+	if _, err := o.GetTrustedProxies(); err != nil {
+		return err
+	}
+
+	switch o.ExternalScheme {
+	case "", "http", "https":
+	default:
+		return fmt.Errorf("config: external scheme must be http or https: %s", o.ExternalScheme)
+	}
+
+	if o.ExternalPort != "" {
+		if port, err := strconv.Atoi(o.ExternalPort); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("config: invalid external port: %s", o.ExternalPort)
+		}
+	}
+
+	if _, err := o.GetDeniedUserAgents(); err != nil {
+		return err
+	}
+
+	if _, err := o.GetPolicyLocation(); err != nil {
+		return err
+	}
+
+	for _, scheme := range o.WWWAuthenticateSchemes {
+		if scheme == "" || strings.ContainsAny(scheme, " \t,=\"") {
+			return fmt.Errorf("config: bad www-authenticate scheme %q", scheme)
+		}
+	}
+
 	if o.PolicyFile != "" {
 		return errors.New("config: policy file setting is deprecated")
 	}
 	if err := o.parsePolicy(); err != nil {
 		return fmt.Errorf("config: failed to parse policy: %w", err)
 	}
+	for i := range o.Policies {
+		if _, ok := o.GetSharedKey(&o.Policies[i]); !ok {
+			return fmt.Errorf("config: policy %s has unknown shared key %q", o.Policies[i].From, o.Policies[i].SharedKeyRef)
+		}
+	}
 
 	if err := o.parseHeaders(); err != nil {
 		return fmt.Errorf("config: failed to parse headers: %w", err)
@@ -579,6 +1225,75 @@ func (o *Options) Validate() error {
 		}
 	}
 
+	for i := range o.ServiceAccountAPIKeys {
+		if err := o.ServiceAccountAPIKeys[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	for i := range o.HealthProbes {
+		if err := o.HealthProbes[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	tenants := make(map[string]bool, len(o.TenantQuotas))
+	for i := range o.TenantQuotas {
+		if err := o.TenantQuotas[i].Validate(); err != nil {
+			return err
+		}
+		if tenants[o.TenantQuotas[i].Tenant] {
+			return fmt.Errorf("config: duplicate tenant quota for %s", o.TenantQuotas[i].Tenant)
+		}
+		tenants[o.TenantQuotas[i].Tenant] = true
+	}
+	if len(o.TenantQuotas) > 0 && o.TenantClaim == "" {
+		return errors.New("config: tenant quotas require a tenant claim")
+	}
+	switch o.TenantQuotaStatusCode {
+	case 0, http.StatusTooManyRequests, http.StatusPaymentRequired:
+	default:
+		return fmt.Errorf("config: tenant quota status code must be 429 or 402: %d", o.TenantQuotaStatusCode)
+	}
+
+	if o.OverrideTokenSecret != "" {
+		if _, err := cryptutil.NewAEADCipherFromBase64(o.OverrideTokenSecret); err != nil {
+			return fmt.Errorf("config: bad override token secret: %w", err)
+		}
+		if o.OverrideTokenSecret == o.SharedKey {
+			return errors.New("config: override token secret must differ from the shared secret")
+		}
+	}
+
+	if o.InnerTrustSecret != "" {
+		if _, err := cryptutil.NewAEADCipherFromBase64(o.InnerTrustSecret); err != nil {
+			return fmt.Errorf("config: bad inner trust secret: %w", err)
+		}
+		if o.InnerTrustSecret == o.SharedKey {
+			return errors.New("config: inner trust secret must differ from the shared secret")
+		}
+	}
+
+	if len(o.TrustedMeshIdentities) > 0 && o.ClientCA == "" && o.ClientCAFile == "" {
+		return errors.New("config: trusted mesh identities require a client ca")
+	}
+	for _, id := range o.TrustedMeshIdentities {
+		if u, err := url.Parse(id); err != nil || u.Scheme != "spiffe" || u.Host == "" {
+			return fmt.Errorf("config: bad trusted mesh identity %s", id)
+		}
+	}
+	trustedMeshIdentities := make(map[string]bool, len(o.TrustedMeshIdentities))
+	for _, id := range o.TrustedMeshIdentities {
+		trustedMeshIdentities[id] = true
+	}
+	for _, p := range o.Policies {
+		for _, id := range p.AllowedMeshIdentities {
+			if !trustedMeshIdentities[id] {
+				return fmt.Errorf("config: route %s allows untrusted mesh identity %s", p.From, id)
+			}
+		}
+	}
+
 	RedirectAndAutocertServer.update(o)
 
 	err = AutocertManager.update(o)
@@ -598,6 +1313,62 @@ func (o *Options) Validate() error {
 	return nil
 }
 
+// GetSharedKey returns the key the route's sessions are signed with, or false
+// if the route references an unknown key.
+func (o *Options) GetSharedKey(p *Policy) (string, bool) {
+	if p == nil || p.SharedKeyRef == "" {
+		return o.SharedKey, true
+	}
+	key, ok := o.SharedKeys[p.SharedKeyRef]
+	return key, ok
+}
+
+// validateRouteSharedKey checks that a route's shared key is a base64
+// encoded key of at least 32 bytes.
+func validateRouteSharedKey(key string) error {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return fmt.Errorf("not base64 encoded: %w", err)
+	}
+	if len(decoded) < 32 {
+		return fmt.Errorf("must be at least 32 bytes, got %d", len(decoded))
+	}
+	return nil
+}
+
+// Cookie name prefixes which browsers only accept cookies with additional
+// attributes for.
+// https://tools.ietf.org/html/draft-ietf-httpbis-rfc6265bis-05#section-4.1.3
+const (
+	// CookiePrefixSecure requires that the cookie is secure.
+	CookiePrefixSecure = "__Secure-"
+	// CookiePrefixHost requires that the cookie is secure, and set without a
+	// domain on the root path, so that it's only sent to the host which set
+	// it.
+	CookiePrefixHost = "__Host-"
+)
+
+// validateCookiePrefix checks that the attributes of the named cookie meet
+// the requirements of its prefix, if it has one. Pomerium's cookies are
+// always set on the root path. Browsers match prefixes case-insensitively.
+func validateCookiePrefix(name, domain string, secure bool) error {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasPrefix(lower, strings.ToLower(CookiePrefixHost)):
+		if !secure {
+			return fmt.Errorf("config: cookie %q must be secure for the %s prefix", name, CookiePrefixHost)
+		}
+		if domain != "" {
+			return fmt.Errorf("config: cookie %q cannot have a domain for the %s prefix: %s", name, CookiePrefixHost, domain)
+		}
+	case strings.HasPrefix(lower, strings.ToLower(CookiePrefixSecure)):
+		if !secure {
+			return fmt.Errorf("config: cookie %q must be secure for the %s prefix", name, CookiePrefixSecure)
+		}
+	}
+	return nil
+}
+
 func (o *Options) sourceHostnames() []string {
 	if len(o.Policies) == 0 {
 		return nil
@@ -620,6 +1391,43 @@ func (o *Options) sourceHostnames() []string {
 	return h
 }
 
+// GetReservedHeaderPrefixes returns the prefixes of the request headers
+// reserved for pomerium: the default prefix, which pomerium's own headers
+// keep, and the configured prefix, if it differs.
+func (o *Options) GetReservedHeaderPrefixes() []string {
+	prefixes := []string{DefaultReservedHeaderPrefix}
+	if prefix := strings.ToLower(o.ReservedHeaderPrefix); prefix != "" && prefix != DefaultReservedHeaderPrefix {
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes
+}
+
+// GetSessionAuthorizationSchemes returns the schemes of the Authorization
+// headers sessions are loaded from.
+func (o *Options) GetSessionAuthorizationSchemes() []string {
+	if len(o.SessionAuthorizationSchemes) == 0 {
+		return []string{httputil.AuthorizationTypePomerium}
+	}
+	return o.SessionAuthorizationSchemes
+}
+
+// GetSessionSigningAlgorithm returns the algorithm sessions are signed with.
+func (o *Options) GetSessionSigningAlgorithm() string {
+	if o.SessionSigningAlgorithm == "" {
+		return "HS256"
+	}
+	return o.SessionSigningAlgorithm
+}
+
+// GetSessionEncoder returns an encoder signing sessions with the key, using
+// the session signing algorithm, and compressing them if enabled.
+func (o *Options) GetSessionEncoder(key []byte, issuer string) (encoding.MarshalUnmarshaler, error) {
+	if o.CompressSessions {
+		return jws.NewCompressedHMACSigner(o.GetSessionSigningAlgorithm(), key, issuer)
+	}
+	return jws.NewHMACSigner(o.GetSessionSigningAlgorithm(), key, issuer)
+}
+
 // GetAuthenticateURL returns the AuthenticateURL in the options or localhost.
 func (o *Options) GetAuthenticateURL() *url.URL {
 	if o != nil && o.AuthenticateURL != nil {
@@ -629,6 +1437,16 @@ func (o *Options) GetAuthenticateURL() *url.URL {
 	return u
 }
 
+// GetAuthenticateURLs returns the AuthenticateURL followed by any failover
+// authenticate urls, in order of preference.
+func (o *Options) GetAuthenticateURLs() []*url.URL {
+	urls := []*url.URL{o.GetAuthenticateURL()}
+	if o != nil {
+		urls = append(urls, o.AuthenticateFailoverURLs...)
+	}
+	return urls
+}
+
 // GetAuthorizeURL returns the AuthorizeURL in the options or localhost:5443.
 func (o *Options) GetAuthorizeURL() *url.URL {
 	if o != nil && o.AuthorizeURL != nil {
@@ -656,6 +1474,43 @@ func (o *Options) GetForwardAuthURL() *url.URL {
 	return u
 }
 
+// GetTrustedProxies returns the parsed TrustedProxies. IPs are returned as
+// single address networks.
+func (o *Options) GetTrustedProxies() ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, proxy := range o.TrustedProxies {
+		network, err := parseIPNetwork(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("config: bad trusted proxy %s", proxy)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// GetDeniedUserAgents returns the compiled DeniedUserAgents.
+func (o *Options) GetDeniedUserAgents() ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, ua := range o.DeniedUserAgents {
+		re, err := regexp.Compile(ua)
+		if err != nil {
+			return nil, fmt.Errorf("config: bad denied user agent %s: %w", ua, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// GetPolicyLocation returns the location of the PolicyTimezone, or UTC if
+// it's unset.
+func (o *Options) GetPolicyLocation() (*time.Location, error) {
+	loc, err := time.LoadLocation(o.PolicyTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("config: bad policy timezone %s: %w", o.PolicyTimezone, err)
+	}
+	return loc, nil
+}
+
 // OptionsUpdater updates local state based on an Options struct
 type OptionsUpdater interface {
 	UpdateOptions(Options) error
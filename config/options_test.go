@@ -13,6 +13,8 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/spf13/viper"
+
+	"github.com/pomerium/pomerium/internal/cryptutil"
 )
 
 var cmpOptIgnoreUnexported = cmpopts.IgnoreUnexported(Options{})
@@ -39,6 +41,195 @@ func Test_Validate(t *testing.T) {
 
 	badPolicyFile := testOptions()
 	badPolicyFile.PolicyFile = "file"
+	failoverURLs := testOptions()
+	failoverURLs.AuthenticateURLString = "https://authenticate-1.example.com"
+	failoverURLs.AuthenticateFailoverURLStrings = []string{"https://authenticate-2.example.com"}
+	badFailoverURL := testOptions()
+	badFailoverURL.AuthenticateURLString = "https://authenticate-1.example.com"
+	badFailoverURL.AuthenticateFailoverURLStrings = []string{"authenticate-2"}
+	failoverWithoutPrimary := testOptions()
+	failoverWithoutPrimary.AuthenticateFailoverURLStrings = []string{"https://authenticate-2.example.com"}
+	meshWithoutCA := testOptions()
+	meshWithoutCA.TrustedMeshIdentities = []string{"spiffe://cluster.local/ns/default/sa/billing"}
+	badMeshIdentity := testOptions()
+	badMeshIdentity.ClientCA = base64.StdEncoding.EncodeToString([]byte("ca"))
+	badMeshIdentity.TrustedMeshIdentities = []string{"https://cluster.local/ns/default/sa/billing"}
+	routeMeshIdentity := testOptions()
+	routeMeshIdentity.ClientCA = base64.StdEncoding.EncodeToString([]byte("ca"))
+	routeMeshIdentity.TrustedMeshIdentities = []string{"spiffe://cluster.local/ns/default/sa/billing"}
+	routeMeshIdentity.Policies = []Policy{{From: "https://a.example.com", To: "http://localhost", AllowedMeshIdentities: []string{"spiffe://cluster.local/ns/default/sa/billing"}}}
+	untrustedRouteMeshIdentity := testOptions()
+	untrustedRouteMeshIdentity.ClientCA = base64.StdEncoding.EncodeToString([]byte("ca"))
+	untrustedRouteMeshIdentity.TrustedMeshIdentities = []string{"spiffe://cluster.local/ns/default/sa/billing"}
+	untrustedRouteMeshIdentity.Policies = []Policy{{From: "https://a.example.com", To: "http://localhost", AllowedMeshIdentities: []string{"spiffe://cluster.local/ns/default/sa/other"}}}
+	badMaxTokenAge := testOptions()
+	badMaxTokenAge.MaxTokenAge = -time.Hour
+	corsOrigins := testOptions()
+	corsOrigins.CORSAllowedOrigins = []string{"https://app.example.com", "http://localhost:8080/"}
+	badCORSOrigin := testOptions()
+	badCORSOrigin.CORSAllowedOrigins = []string{"app.example.com"}
+	badCORSOriginPath := testOptions()
+	badCORSOriginPath.CORSAllowedOrigins = []string{"https://app.example.com/path"}
+	badWWWAuthenticateScheme := testOptions()
+	badWWWAuthenticateScheme.WWWAuthenticateSchemes = []string{"Bearer realm"}
+	badTrustedProxy := testOptions()
+	badTrustedProxy.TrustedProxies = []string{"10.0.0.0/33"}
+	badOverrideTokenSecret := testOptions()
+	badOverrideTokenSecret.OverrideTokenSecret = "not base64"
+	sharedOverrideTokenSecret := testOptions()
+	sharedOverrideTokenSecret.SharedKey = cryptutil.NewBase64Key()
+	sharedOverrideTokenSecret.OverrideTokenSecret = sharedOverrideTokenSecret.SharedKey
+	goodOverrideTokenSecret := testOptions()
+	goodOverrideTokenSecret.OverrideTokenSecret = cryptutil.NewBase64Key()
+	badInnerTrustSecret := testOptions()
+	badInnerTrustSecret.InnerTrustSecret = "not base64"
+	sharedInnerTrustSecret := testOptions()
+	sharedInnerTrustSecret.SharedKey = cryptutil.NewBase64Key()
+	sharedInnerTrustSecret.InnerTrustSecret = sharedInnerTrustSecret.SharedKey
+	goodInnerTrustSecret := testOptions()
+	goodInnerTrustSecret.InnerTrustSecret = cryptutil.NewBase64Key()
+	badDeniedUserAgent := testOptions()
+	badDeniedUserAgent.DeniedUserAgents = []string{"curl/("}
+	badPolicyTimezone := testOptions()
+	badPolicyTimezone.PolicyTimezone = "America/Nowhere"
+	badDeniedMethods := testOptions()
+	badDeniedMethods.DeniedMethods = []string{""}
+	badGlobalAllowedMethods := testOptions()
+	badGlobalAllowedMethods.GlobalAllowedMethods = []string{"GET", ""}
+	externalOrigin := testOptions()
+	externalOrigin.ExternalScheme, externalOrigin.ExternalPort = "https", "8443"
+	badExternalScheme := testOptions()
+	badExternalScheme.ExternalScheme = "ftp"
+	badExternalPort := testOptions()
+	badExternalPort.ExternalPort = "65536"
+	badCookieMaxChunks := testOptions()
+	badCookieMaxChunks.CookieMaxChunks = -1
+	badCookieChunkThreshold := testOptions()
+	badCookieChunkThreshold.CookieChunkThreshold = -1
+	goodSharedKeys := testOptions()
+	goodSharedKeys.SharedKeys = map[string]string{"tenant": cryptutil.NewBase64Key()}
+	goodSharedKeys.Policies = []Policy{{From: "https://a.example.com", To: "http://localhost", SharedKeyRef: "tenant"}}
+	badSharedKey := testOptions()
+	badSharedKey.SharedKeys = map[string]string{"tenant": base64.StdEncoding.EncodeToString([]byte("short"))}
+	sameSharedKey := testOptions()
+	sameSharedKey.SharedKeys = map[string]string{"tenant": sameSharedKey.SharedKey}
+	unknownSharedKeyRef := testOptions()
+	unknownSharedKeyRef.Policies = []Policy{{From: "https://a.example.com", To: "http://localhost", SharedKeyRef: "tenant"}}
+	goodCookieLegacyNames := testOptions()
+	goodCookieLegacyNames.CookieLegacyNames = []string{"_old_pomerium"}
+	emptyCookieLegacyName := testOptions()
+	emptyCookieLegacyName.CookieLegacyNames = []string{""}
+	currentCookieLegacyName := testOptions()
+	currentCookieLegacyName.CookieLegacyNames = []string{currentCookieLegacyName.CookieName}
+	goodCookieMigrateDomain := testOptions()
+	goodCookieMigrateDomain.CookieMigrateDomain = true
+	goodCookieMigrateDomain.CookieDomain = ".example.com"
+	sameCookieLegacyDomain := testOptions()
+	sameCookieLegacyDomain.CookieMigrateDomain = true
+	sameCookieLegacyDomain.CookieDomain = ".example.com"
+	sameCookieLegacyDomain.CookieLegacyDomain = "example.com"
+	badExpiredSessionGracePeriod := testOptions()
+	badExpiredSessionGracePeriod.ExpiredSessionGracePeriod = -time.Minute
+	badAuthenticateOutageGracePeriod := testOptions()
+	badAuthenticateOutageGracePeriod.AuthenticateOutageGracePeriod = -time.Minute
+	badMinRefreshInterval := testOptions()
+	badMinRefreshInterval.MinRefreshInterval = -time.Minute
+	badRevocationStoreRetryAttempts := testOptions()
+	badRevocationStoreRetryAttempts.SessionRevocationStoreRetryAttempts = -1
+	badRevocationStoreRetryDelay := testOptions()
+	badRevocationStoreRetryDelay.SessionRevocationStoreRetryDelay = -time.Millisecond
+	goodTenantQuotas := testOptions()
+	goodTenantQuotas.TenantClaim = "tenant"
+	goodTenantQuotas.TenantQuotas = []TenantQuota{{Tenant: "acme", Requests: 100, Period: time.Hour}}
+	goodTenantQuotas.TenantQuotaStatusCode = 402
+	tenantQuotasWithoutClaim := testOptions()
+	tenantQuotasWithoutClaim.TenantQuotas = []TenantQuota{{Tenant: "acme", Requests: 100, Period: time.Hour}}
+	badTenantQuotaPeriod := testOptions()
+	badTenantQuotaPeriod.TenantClaim = "tenant"
+	badTenantQuotaPeriod.TenantQuotas = []TenantQuota{{Tenant: "acme", Requests: 100}}
+	duplicateTenantQuotas := testOptions()
+	duplicateTenantQuotas.TenantClaim = "tenant"
+	duplicateTenantQuotas.TenantQuotas = []TenantQuota{{Tenant: "acme", Requests: 1, Period: time.Hour}, {Tenant: "acme", Requests: 2, Period: time.Hour}}
+	badTenantQuotaStatusCode := testOptions()
+	badTenantQuotaStatusCode.TenantQuotaStatusCode = 503
+	goodSessionSigningAlgorithm := testOptions()
+	goodSessionSigningAlgorithm.SessionSigningAlgorithm = "HS512"
+	badSessionSigningAlgorithm := testOptions()
+	badSessionSigningAlgorithm.SessionSigningAlgorithm = "none"
+	goodSessionAuthorizationSchemes := testOptions()
+	goodSessionAuthorizationSchemes.SessionAuthorizationSchemes = []string{"Pomerium", "Bearer"}
+	badSessionAuthorizationSchemes := testOptions()
+	badSessionAuthorizationSchemes.SessionAuthorizationSchemes = []string{"Bearer token"}
+	badRevocationStoreMaxConnections := testOptions()
+	badRevocationStoreMaxConnections.SessionRevocationStoreMaxConnections = -1
+	badRevocationStorePoolTimeout := testOptions()
+	badRevocationStorePoolTimeout.SessionRevocationStorePoolTimeout = -time.Millisecond
+	badAuditLogMaxSize := testOptions()
+	badAuditLogMaxSize.AuditLogMaxSize = -1
+	badAuditLogFlushInterval := testOptions()
+	badAuditLogFlushInterval.AuditLogFlushInterval = -time.Second
+	badMaxRequestHeaders := testOptions()
+	badMaxRequestHeaders.MaxRequestHeaders = -1
+	badMaxRequestHeaderBytes := testOptions()
+	badMaxRequestHeaderBytes.MaxRequestHeaderBytes = -1
+	badMaxRedirectURILength := testOptions()
+	badMaxRedirectURILength.MaxRedirectURILength = -1
+	badAuthorizeLogSampleRate := testOptions()
+	badAuthorizeLogSampleRate.AuthorizeLogSampleRate = 1.5
+	hostCookie := testOptions()
+	hostCookie.CookieName = "__Host-pomerium"
+	hostCookieWithDomain := testOptions()
+	hostCookieWithDomain.CookieName = "__Host-pomerium"
+	hostCookieWithDomain.CookieDomain = "example.com"
+	insecureAppHostCookie := testOptions()
+	insecureAppHostCookie.CookieSecure = false
+	insecureAppHostCookie.Policies = []Policy{{From: "https://from.example", To: "https://to.example", AppCookieName: "__Host-app", AppCookieSecret: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}}
+	supportURL := testOptions()
+	supportURL.SupportURLString = "https://support.example.com/tickets/new"
+	badSupportURL := testOptions()
+	badSupportURL.SupportURLString = "support.example.com"
+	decisionLog := testOptions()
+	decisionLog.DecisionLogURLString = "https://logs.example.com/logs"
+	badDecisionLogURL := testOptions()
+	badDecisionLogURL.DecisionLogURLString = "logs.example.com"
+	badDecisionLogBatchSize := testOptions()
+	badDecisionLogBatchSize.DecisionLogURLString = "https://logs.example.com/logs"
+	badDecisionLogBatchSize.DecisionLogBatchSize = 0
+	badDecisionLogFlushInterval := testOptions()
+	badDecisionLogFlushInterval.DecisionLogURLString = "https://logs.example.com/logs"
+	badDecisionLogFlushInterval.DecisionLogFlushInterval = 0
+	noPolicyMatchAllow := testOptions()
+	noPolicyMatchAllow.NoPolicyMatch = NoPolicyMatchAllow
+	badNoPolicyMatch := testOptions()
+	badNoPolicyMatch.NoPolicyMatch = "maybe"
+	hostConflictStrict := testOptions()
+	hostConflictStrict.HostConflict = HostConflictStrict
+	badHostConflict := testOptions()
+	badHostConflict.HostConflict = "maybe"
+	unknownClientIPDeny := testOptions()
+	unknownClientIPDeny.UnknownClientIP = UnknownClientIPDeny
+	badUnknownClientIP := testOptions()
+	badUnknownClientIP.UnknownClientIP = "maybe"
+	apiKeys := testOptions()
+	apiKeys.ServiceAccountAPIKeys = []ServiceAccountAPIKey{{Salt: "salt", Hash: base64.StdEncoding.EncodeToString([]byte("hash")), Email: "robot@example.com"}}
+	badAPIKeyHash := testOptions()
+	badAPIKeyHash.ServiceAccountAPIKeys = []ServiceAccountAPIKey{{Salt: "salt", Hash: "not base64!", Email: "robot@example.com"}}
+	apiKeyWithoutEmail := testOptions()
+	apiKeyWithoutEmail.ServiceAccountAPIKeys = []ServiceAccountAPIKey{{Salt: "salt", Hash: base64.StdEncoding.EncodeToString([]byte("hash"))}}
+	healthProbes := testOptions()
+	healthProbes.HealthProbes = []HealthProbe{{Sources: []string{"10.0.0.1", "10.1.0.0/24", "2001:db8::/64"}, Paths: []string{"/healthz"}}}
+	healthProbeWithoutSources := testOptions()
+	healthProbeWithoutSources.HealthProbes = []HealthProbe{{Paths: []string{"/healthz"}}}
+	badHealthProbeSource := testOptions()
+	badHealthProbeSource.HealthProbes = []HealthProbe{{Sources: []string{"10.0.0.1/33"}}}
+	broadHealthProbeSource := testOptions()
+	broadHealthProbeSource.HealthProbes = []HealthProbe{{Sources: []string{"10.0.0.0/8"}}}
+	broadIPv6HealthProbeSource := testOptions()
+	broadIPv6HealthProbeSource.HealthProbes = []HealthProbe{{Sources: []string{"2001:db8::/32"}}}
+	unspecifiedHealthProbeSource := testOptions()
+	unspecifiedHealthProbeSource.HealthProbes = []HealthProbe{{Sources: []string{"0.0.0.0"}}}
+	badHealthProbePath := testOptions()
+	badHealthProbePath.HealthProbes = []HealthProbe{{Sources: []string{"10.0.0.1"}, Paths: []string{"healthz"}}}
 
 	tests := []struct {
 		name     string
@@ -50,6 +241,90 @@ func Test_Validate(t *testing.T) {
 		{"missing shared secret", badSecret, true},
 		{"missing shared secret but all service", badSecretAllServices, false},
 		{"policy file specified", badPolicyFile, true},
+		{"bad www-authenticate scheme", badWWWAuthenticateScheme, true},
+		{"bad trusted proxy", badTrustedProxy, true},
+		{"bad denied user agent", badDeniedUserAgent, true},
+		{"bad policy timezone", badPolicyTimezone, true},
+		{"bad override token secret", badOverrideTokenSecret, true},
+		{"override token secret is the shared secret", sharedOverrideTokenSecret, true},
+		{"good override token secret", goodOverrideTokenSecret, false},
+		{"bad inner trust secret", badInnerTrustSecret, true},
+		{"inner trust secret is the shared secret", sharedInnerTrustSecret, true},
+		{"good inner trust secret", goodInnerTrustSecret, false},
+		{"bad denied methods", badDeniedMethods, true},
+		{"bad global allowed methods", badGlobalAllowedMethods, true},
+		{"external scheme and port", externalOrigin, false},
+		{"bad external scheme", badExternalScheme, true},
+		{"bad external port", badExternalPort, true},
+		{"bad cookie max chunks", badCookieMaxChunks, true},
+		{"bad cookie chunk threshold", badCookieChunkThreshold, true},
+		{"good shared keys", goodSharedKeys, false},
+		{"bad shared key", badSharedKey, true},
+		{"shared key is the shared-key", sameSharedKey, true},
+		{"unknown shared key ref", unknownSharedKeyRef, true},
+		{"good cookie legacy names", goodCookieLegacyNames, false},
+		{"empty cookie legacy name", emptyCookieLegacyName, true},
+		{"cookie legacy name is the cookie name", currentCookieLegacyName, true},
+		{"good cookie migrate domain", goodCookieMigrateDomain, false},
+		{"cookie legacy domain is the cookie domain", sameCookieLegacyDomain, true},
+		{"bad expired session grace period", badExpiredSessionGracePeriod, true},
+		{"bad authenticate outage grace period", badAuthenticateOutageGracePeriod, true},
+		{"bad min refresh interval", badMinRefreshInterval, true},
+		{"no policy match allow", noPolicyMatchAllow, false},
+		{"bad no policy match", badNoPolicyMatch, true},
+		{"strict host conflict", hostConflictStrict, false},
+		{"bad host conflict", badHostConflict, true},
+		{"unknown client ip deny", unknownClientIPDeny, false},
+		{"bad unknown client ip", badUnknownClientIP, true},
+		{"service account api keys", apiKeys, false},
+		{"bad service account api key hash", badAPIKeyHash, true},
+		{"service account api key without email", apiKeyWithoutEmail, true},
+		{"health probes", healthProbes, false},
+		{"health probe without sources", healthProbeWithoutSources, true},
+		{"bad health probe source", badHealthProbeSource, true},
+		{"broad health probe source", broadHealthProbeSource, true},
+		{"broad ipv6 health probe source", broadIPv6HealthProbeSource, true},
+		{"unspecified health probe source", unspecifiedHealthProbeSource, true},
+		{"bad health probe path", badHealthProbePath, true},
+		{"authenticate failover urls", failoverURLs, false},
+		{"bad authenticate failover url", badFailoverURL, true},
+		{"authenticate failover urls without primary", failoverWithoutPrimary, true},
+		{"trusted mesh identities without client ca", meshWithoutCA, true},
+		{"bad trusted mesh identity", badMeshIdentity, true},
+		{"route allows trusted mesh identity", routeMeshIdentity, false},
+		{"route allows untrusted mesh identity", untrustedRouteMeshIdentity, true},
+		{"negative max token age", badMaxTokenAge, true},
+		{"negative session revocation store retry attempts", badRevocationStoreRetryAttempts, true},
+		{"negative session revocation store retry delay", badRevocationStoreRetryDelay, true},
+		{"negative session revocation store max connections", badRevocationStoreMaxConnections, true},
+		{"good session signing algorithm", goodSessionSigningAlgorithm, false},
+		{"bad session signing algorithm", badSessionSigningAlgorithm, true},
+		{"good session authorization schemes", goodSessionAuthorizationSchemes, false},
+		{"bad session authorization schemes", badSessionAuthorizationSchemes, true},
+		{"good tenant quotas", goodTenantQuotas, false},
+		{"tenant quotas without a tenant claim", tenantQuotasWithoutClaim, true},
+		{"bad tenant quota period", badTenantQuotaPeriod, true},
+		{"duplicate tenant quotas", duplicateTenantQuotas, true},
+		{"bad tenant quota status code", badTenantQuotaStatusCode, true},
+		{"negative session revocation store pool timeout", badRevocationStorePoolTimeout, true},
+		{"negative audit log max size", badAuditLogMaxSize, true},
+		{"negative audit log flush interval", badAuditLogFlushInterval, true},
+		{"authorize log sample rate over 1", badAuthorizeLogSampleRate, true},
+		{"negative max request headers", badMaxRequestHeaders, true},
+		{"negative max request header bytes", badMaxRequestHeaderBytes, true},
+		{"negative max redirect uri length", badMaxRedirectURILength, true},
+		{"cors allowed origins", corsOrigins, false},
+		{"cors allowed origin missing scheme", badCORSOrigin, true},
+		{"cors allowed origin with path", badCORSOriginPath, true},
+		{"support url", supportURL, false},
+		{"bad support url", badSupportURL, true},
+		{"decision log url", decisionLog, false},
+		{"bad decision log url", badDecisionLogURL, true},
+		{"bad decision log batch size", badDecisionLogBatchSize, true},
+		{"bad decision log flush interval", badDecisionLogFlushInterval, true},
+		{"host cookie prefix", hostCookie, false},
+		{"host cookie prefix with a domain", hostCookieWithDomain, true},
+		{"insecure app cookie with host prefix", insecureAppHostCookie, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -62,6 +337,34 @@ func Test_Validate(t *testing.T) {
 	}
 }
 
+func Test_validateCookiePrefix(t *testing.T) {
+	tests := []struct {
+		name    string
+		cookie  string
+		domain  string
+		secure  bool
+		wantErr bool
+	}{
+		{"no prefix", "_pomerium", "example.com", false, false},
+		{"host", "__Host-pomerium", "", true, false},
+		{"host insecure", "__Host-pomerium", "", false, true},
+		{"host with domain", "__Host-pomerium", "example.com", true, true},
+		{"host lowercase", "__host-pomerium", "example.com", true, true},
+		{"secure", "__Secure-pomerium", "example.com", true, false},
+		{"secure insecure", "__Secure-pomerium", "", false, true},
+		{"secure uppercase", "__SECURE-pomerium", "", false, true},
+		{"prefix without a dash", "__Hostpomerium", "example.com", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCookiePrefix(tt.cookie, tt.domain, tt.secure)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateCookiePrefix() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func Test_bindEnvs(t *testing.T) {
 	o := new(Options)
 	o.viper = viper.New()
@@ -206,7 +509,7 @@ func Test_Checksum(t *testing.T) {
 func TestOptionsFromViper(t *testing.T) {
 	t.Parallel()
 	opts := []cmp.Option{
-		cmpopts.IgnoreFields(Options{}, "CacheStore", "CookieSecret", "GRPCInsecure", "GRPCAddr", "CacheURLString", "CacheURL", "AuthorizeURL", "AuthorizeURLString", "DefaultUpstreamTimeout", "CookieExpire", "Services", "Addr", "RefreshCooldown", "LogLevel", "KeyFile", "CertFile", "SharedKey", "ReadTimeout", "IdleTimeout", "GRPCClientTimeout", "GRPCClientDNSRoundRobin", "TracingSampleRate"),
+		cmpopts.IgnoreFields(Options{}, "CacheStore", "CookieSecret", "GRPCInsecure", "GRPCAddr", "CacheURLString", "CacheURL", "AuthorizeURL", "AuthorizeURLString", "DefaultUpstreamTimeout", "CookieExpire", "Services", "Addr", "RefreshCooldown", "LogLevel", "KeyFile", "CertFile", "SharedKey", "ReadTimeout", "IdleTimeout", "GRPCClientTimeout", "GRPCClientDNSRoundRobin", "TracingSampleRate", "ExpiredSessionGraceMethods", "DeniedMethods", "GlobalAllowedMethods"),
 		cmpopts.IgnoreFields(Policy{}, "Source", "Destination"),
 		cmpOptIgnoreUnexported,
 	}
@@ -228,6 +531,16 @@ func TestOptionsFromViper(t *testing.T) {
 				GRPCServerMaxConnectionAge:      5 * time.Minute,
 				GRPCServerMaxConnectionAgeGrace: 5 * time.Minute,
 				AuthenticateCallbackPath:        "/oauth2/callback",
+				Proxy100Continue:                true,
+				ReservedHeaderPrefix:            "x-pomerium-",
+				NormalizeRequestPath:            true,
+				AuditLogFlushInterval:           time.Second,
+				DecisionLogBatchSize:            100,
+				DecisionLogFlushInterval:        5 * time.Second,
+				AuthorizeLogSampleRate:          1,
+				MaxRequestHeaders:               200,
+				MaxRequestHeaderBytes:           128 * 1024,
+				MaxRedirectURILength:            2048,
 				Headers: map[string]string{
 					"Strict-Transport-Security": "max-age=31536000; includeSubDomains; preload",
 					"X-Frame-Options":           "SAMEORIGIN",
@@ -245,6 +558,16 @@ func TestOptionsFromViper(t *testing.T) {
 				InsecureServer:                  true,
 				GRPCServerMaxConnectionAge:      5 * time.Minute,
 				GRPCServerMaxConnectionAgeGrace: 5 * time.Minute,
+				Proxy100Continue:                true,
+				ReservedHeaderPrefix:            "x-pomerium-",
+				NormalizeRequestPath:            true,
+				AuditLogFlushInterval:           time.Second,
+				DecisionLogBatchSize:            100,
+				DecisionLogFlushInterval:        5 * time.Second,
+				AuthorizeLogSampleRate:          1,
+				MaxRequestHeaders:               200,
+				MaxRequestHeaderBytes:           128 * 1024,
+				MaxRedirectURILength:            2048,
 				Headers:                         map[string]string{}},
 			false},
 		{"bad url", []byte(`{"policy":[{"from": "https://","to":"https://to.example"}]}`), nil, true},
@@ -4,18 +4,75 @@ import (
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime"
+	"net/http"
 	"net/url"
 	"os"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/cespare/xxhash/v2"
 	"github.com/mitchellh/hashstructure"
+	"golang.org/x/net/http/httpguts"
 
 	"github.com/pomerium/pomerium/internal/cryptutil"
 	"github.com/pomerium/pomerium/internal/urlutil"
 )
 
+// Session preferences set the order in which a route loads sessions.
+const (
+	// SessionPreferenceCookieFirst loads the session cookie, then the
+	// authorization header.
+	SessionPreferenceCookieFirst = "cookie-first"
+	// SessionPreferenceHeaderFirst loads the authorization header, then the
+	// session cookie.
+	SessionPreferenceHeaderFirst = "header-first"
+	// SessionPreferenceHeaderOnly only loads the authorization header. Users
+	// without a session are never redirected to sign in.
+	SessionPreferenceHeaderOnly = "header-only"
+	// SessionPreferenceCookieOnly only loads the session cookie.
+	SessionPreferenceCookieOnly = "cookie-only"
+)
+
+// Options request handling sets how a route handles OPTIONS requests which
+// aren't CORS preflights.
+const (
+	// OptionsRequestsAuthorize authorizes OPTIONS requests like any other
+	// request.
+	OptionsRequestsAuthorize = "authorize"
+	// OptionsRequestsPassThrough passes OPTIONS requests to the upstream
+	// without a session, for upstreams which implement OPTIONS themselves.
+	OptionsRequestsPassThrough = "pass-through"
+	// OptionsRequestsRespond answers OPTIONS requests with the route's allowed
+	// methods, without passing them to the upstream.
+	OptionsRequestsRespond = "respond"
+)
+
+// JWT assertion formats set how a route passes the signed JWT assertion
+// upstream.
+const (
+	// JWTAssertionFormatRaw passes the JWT as the header value.
+	JWTAssertionFormatRaw = "raw"
+	// JWTAssertionFormatBearer passes the JWT as a bearer token, e.g. for
+	// use with the Authorization header.
+	JWTAssertionFormatBearer = "bearer"
+	// JWTAssertionFormatNone doesn't pass the JWT upstream.
+	JWTAssertionFormatNone = "none"
+)
+
+// clientTLSVersions maps the versions ClientTLSMinVersion may be set to to
+// their tls package constants.
+var clientTLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
 // Policy contains route specific configuration and access settings.
 type Policy struct {
 	From string `mapstructure:"from" yaml:"from"`
@@ -24,6 +81,9 @@ type Policy struct {
 	AllowedUsers   []string `mapstructure:"allowed_users" yaml:"allowed_users,omitempty" json:"allowed_users,omitempty"`
 	AllowedGroups  []string `mapstructure:"allowed_groups" yaml:"allowed_groups,omitempty" json:"allowed_groups,omitempty"`
 	AllowedDomains []string `mapstructure:"allowed_domains" yaml:"allowed_domains,omitempty" json:"allowed_domains,omitempty"`
+	// AllowedMeshIdentities are the trusted mesh identities (SPIFFE IDs)
+	// allowed to use the route without a user session.
+	AllowedMeshIdentities []string `mapstructure:"allowed_mesh_identities" yaml:"allowed_mesh_identities,omitempty" json:"allowed_mesh_identities,omitempty"`
 
 	Source      *StringURL `yaml:",omitempty" json:"source,omitempty" hash:"ignore"`
 	Destination *url.URL   `yaml:",omitempty" json:"destination,omitempty" hash:"ignore"`
@@ -75,15 +135,173 @@ type Policy struct {
 	TLSClientKeyFile  string           `mapstructure:"tls_client_key_file" yaml:"tls_client_key_file,omitempty"`
 	ClientCertificate *tls.Certificate `yaml:",omitempty" hash:"ignore"`
 
+	// ClientTLSMinVersion, if set, is the minimum TLS version (1.0, 1.1, 1.2
+	// or 1.3) of the client's connection to pomerium, and ClientTLSCipherSuites
+	// the cipher suites it may use. Weaker connections are denied.
+	ClientTLSMinVersion   string   `mapstructure:"client_tls_min_version" yaml:"client_tls_min_version,omitempty"`
+	ClientTLSCipherSuites []string `mapstructure:"client_tls_cipher_suites" yaml:"client_tls_cipher_suites,omitempty"`
+
 	// SetRequestHeaders adds a collection of headers to the downstream request
 	// in the form of key value pairs. Note bene, this will overwrite the
 	// value of any existing value of a given header key.
 	SetRequestHeaders map[string]string `mapstructure:"set_request_headers" yaml:"set_request_headers,omitempty"`
 
+	// HeaderTransforms rename, add or remove the headers of allowed requests
+	// sent upstream, such as the JWT claim headers, in order.
+	HeaderTransforms []HeaderTransform `mapstructure:"header_transforms" yaml:"header_transforms,omitempty"`
+
 	// PreserveHostHeader disables host header rewriting.
 	//
 	// https://nginx.org/en/docs/http/ngx_http_proxy_module.html#proxy_set_header
 	PreserveHostHeader bool `mapstructure:"preserve_host_header" yaml:"preserve_host_header,omitempty"`
+
+	// DenyStatusCode overrides the HTTP status code returned when access to
+	// the route is denied. For example, 404 can be used to avoid revealing
+	// the existence of the route. Defaults to 403.
+	DenyStatusCode int `mapstructure:"deny_status_code" yaml:"deny_status_code,omitempty" json:"deny_status_code,omitempty"`
+
+	// AllowedMethods, if set, restricts the route to the given HTTP methods
+	// (e.g. GET, HEAD). Other methods are rejected before policy evaluation.
+	AllowedMethods []string `mapstructure:"allowed_methods" yaml:"allowed_methods,omitempty" json:"allowed_methods,omitempty"`
+
+	// AllowedContentTypes, if set, restricts the requests to the route which
+	// have a Content-Type to the given media types (e.g. application/json),
+	// or all subtypes of a type (e.g. image/*). Other requests are rejected
+	// with a 415 before policy evaluation.
+	AllowedContentTypes []string `mapstructure:"allowed_content_types" yaml:"allowed_content_types,omitempty" json:"allowed_content_types,omitempty"`
+
+	// CSRFHeader, if set, is a header (e.g. X-Requested-With) requests to the
+	// route with one of the CSRF methods must have, as a defense in depth
+	// against cross-site request forgery. Requests without it are rejected
+	// with a 403 before policy evaluation.
+	CSRFHeader string `mapstructure:"csrf_header" yaml:"csrf_header,omitempty" json:"csrf_header,omitempty"`
+	// CSRFMethods are the state-changing methods requiring the CSRF header.
+	// Defaults to POST, PUT, PATCH and DELETE.
+	CSRFMethods []string `mapstructure:"csrf_methods" yaml:"csrf_methods,omitempty" json:"csrf_methods,omitempty"`
+
+	// RequiredQueryParams are the query parameters, keyed by name, a request
+	// to the route must have one of the values of. A parameter without values
+	// must be present with any value.
+	RequiredQueryParams map[string][]string `mapstructure:"required_query_params" yaml:"required_query_params,omitempty" json:"required_query_params,omitempty"`
+
+	// AllowedCountries, if set, restricts the route to clients in the
+	// countries, by ISO 3166-1 alpha-2 code (e.g. US), per the GeoIP
+	// database. Clients whose country is unknown aren't restricted.
+	AllowedCountries []string `mapstructure:"allowed_countries" yaml:"allowed_countries,omitempty" json:"allowed_countries,omitempty"`
+
+	// AllowedServerNames, if set, restricts the route to clients whose TLS
+	// connection requested one of the server names (SNI). Clients which sent
+	// no server name, e.g. over plaintext, are denied.
+	AllowedServerNames []string `mapstructure:"allowed_server_names" yaml:"allowed_server_names,omitempty" json:"allowed_server_names,omitempty"`
+
+	// AllowedWeekdays, if set, restricts the route to the days of the week
+	// (e.g. monday), in the local time of the policy timezone.
+	AllowedWeekdays []string `mapstructure:"allowed_weekdays" yaml:"allowed_weekdays,omitempty" json:"allowed_weekdays,omitempty"`
+
+	// AllowedHours, if set, restricts the route to a range of hours of the
+	// day, in the local time of the policy timezone, such as 09:00-17:00. The
+	// end is exclusive, and a range may span midnight, e.g. 22:00-06:00.
+	AllowedHours string `mapstructure:"allowed_hours" yaml:"allowed_hours,omitempty" json:"allowed_hours,omitempty"`
+
+	// RequireRefreshToken, if set, restricts the route to users whose session
+	// has a refresh token, so that the session can be refreshed silently.
+	RequireRefreshToken bool `mapstructure:"require_refresh_token" yaml:"require_refresh_token,omitempty" json:"require_refresh_token,omitempty"`
+
+	// SessionPreference sets the order in which sessions are loaded from the
+	// session cookie and the authorization header for the route. Defaults to
+	// SessionPreferenceCookieFirst.
+	SessionPreference string `mapstructure:"session_preference" yaml:"session_preference,omitempty" json:"session_preference,omitempty"`
+
+	// OptionsRequests sets how OPTIONS requests to the route which aren't
+	// CORS preflights are handled. Defaults to OptionsRequestsAuthorize.
+	OptionsRequests string `mapstructure:"options_requests" yaml:"options_requests,omitempty" json:"options_requests,omitempty"`
+
+	// Maintenance puts the route into maintenance mode: requests are answered
+	// with a 503 and the MaintenanceMessage rather than passed upstream. With
+	// MaintenanceAllowAdministrators, administrators may still use the route.
+	Maintenance                    bool   `mapstructure:"maintenance" yaml:"maintenance,omitempty" json:"maintenance,omitempty"`
+	MaintenanceMessage             string `mapstructure:"maintenance_message" yaml:"maintenance_message,omitempty" json:"maintenance_message,omitempty"`
+	MaintenanceAllowAdministrators bool   `mapstructure:"maintenance_allow_administrators" yaml:"maintenance_allow_administrators,omitempty" json:"maintenance_allow_administrators,omitempty"`
+
+	// DeprecationWarning, if set, is returned to clients of the route in an
+	// X-Pomerium-Warning header. Access to the route is not affected.
+	DeprecationWarning string `mapstructure:"deprecation_warning" yaml:"deprecation_warning,omitempty" json:"deprecation_warning,omitempty"`
+
+	// JWTAssertionHeader and JWTAssertionFormat set the header, and its
+	// format, used to pass the signed JWT assertion upstream. Default to
+	// X-Pomerium-Jwt-Assertion and JWTAssertionFormatRaw.
+	JWTAssertionHeader string `mapstructure:"jwt_assertion_header" yaml:"jwt_assertion_header,omitempty" json:"jwt_assertion_header,omitempty"`
+	JWTAssertionFormat string `mapstructure:"jwt_assertion_format" yaml:"jwt_assertion_format,omitempty" json:"jwt_assertion_format,omitempty"`
+
+	// AppCookieName, if set, is the name of a short-lived cookie, signed with
+	// AppCookieSecret, set on allowed responses with the user's identity, for
+	// upstream apps which read their own cookie rather than headers.
+	AppCookieName   string `mapstructure:"app_cookie_name" yaml:"app_cookie_name,omitempty" json:"app_cookie_name,omitempty"`
+	AppCookieSecret string `mapstructure:"app_cookie_secret" yaml:"app_cookie_secret,omitempty" json:"-"`
+
+	// PartnerCookieName, if set, is the name of a session cookie issued by a
+	// partner's SSO, and signed (HS256) with PartnerCookieKey, which is
+	// accepted in place of a pomerium session for this route only.
+	PartnerCookieName string `mapstructure:"partner_cookie_name" yaml:"partner_cookie_name,omitempty" json:"partner_cookie_name,omitempty"`
+	PartnerCookieKey  string `mapstructure:"partner_cookie_key" yaml:"partner_cookie_key,omitempty" json:"-"`
+
+	// SharedKeyRef, if set, names the key of the options' SharedKeys that the
+	// route's sessions are signed and verified with, in place of the shared
+	// key. Sessions signed with any other key are rejected.
+	SharedKeyRef string `mapstructure:"shared_secret_ref" yaml:"shared_secret_ref,omitempty" json:"shared_secret_ref,omitempty"`
+
+	// PassMatchedConditions passes the policy conditions satisfied by an
+	// allowed request (e.g. group:engineering) upstream in the
+	// X-Pomerium-Matched-Conditions header.
+	PassMatchedConditions bool `mapstructure:"pass_matched_conditions" yaml:"pass_matched_conditions,omitempty" json:"pass_matched_conditions,omitempty"`
+
+	// MaxConcurrentChecks, if set, caps the number of the route's requests
+	// which are authorized at once, so that a heavy route can't starve the
+	// others. Requests over the cap are rejected.
+	MaxConcurrentChecks int `mapstructure:"max_concurrent_checks" yaml:"max_concurrent_checks,omitempty" json:"max_concurrent_checks,omitempty"`
+
+	// CompiledRegex is the compiled form of Regex.
+	CompiledRegex *regexp.Regexp `yaml:"-" json:"-" hash:"ignore"`
+}
+
+// A HeaderTransform renames, adds or removes a header of the requests sent
+// upstream. Exactly one of Rename, Add or Remove is set.
+type HeaderTransform struct {
+	// Rename renames one of the headers pomerium adds, such as a JWT claim
+	// header, to To. If there's no such header, To is set empty, so that the
+	// client can't set it.
+	Rename string `mapstructure:"rename" yaml:"rename,omitempty"`
+	To     string `mapstructure:"to" yaml:"to,omitempty"`
+	// Add sets the header to Value, replacing any existing value.
+	Add   string `mapstructure:"add" yaml:"add,omitempty"`
+	Value string `mapstructure:"value" yaml:"value,omitempty"`
+	// Remove removes the header, including any sent by the client.
+	Remove string `mapstructure:"remove" yaml:"remove,omitempty"`
+}
+
+// validate checks that the transform has a single action and valid header
+// names.
+func (t *HeaderTransform) validate() error {
+	var names []string
+	switch {
+	case t.Rename != "" && t.Add == "" && t.Remove == "":
+		if t.To == "" {
+			return fmt.Errorf("config: policy header transform rename of %s has no new name", t.Rename)
+		}
+		names = []string{t.Rename, t.To}
+	case t.Add != "" && t.Rename == "" && t.Remove == "":
+		names = []string{t.Add}
+	case t.Remove != "" && t.Rename == "" && t.Add == "":
+		names = []string{t.Remove}
+	default:
+		return errors.New("config: policy header transform must have exactly one of rename, add or remove")
+	}
+	for _, name := range names {
+		if !httpguts.ValidHeaderFieldName(name) {
+			return fmt.Errorf("config: policy header transform has a bad header name: %q", name)
+		}
+	}
+	return nil
 }
 
 // Validate checks the validity of a policy.
@@ -108,7 +326,7 @@ func (p *Policy) Validate() error {
 	}
 
 	// Only allow public access if no other whitelists are in place
-	if p.AllowPublicUnauthenticatedAccess && (p.AllowedDomains != nil || p.AllowedGroups != nil || p.AllowedUsers != nil) {
+	if p.AllowPublicUnauthenticatedAccess && (p.AllowedDomains != nil || p.AllowedGroups != nil || p.AllowedUsers != nil || p.AllowedMeshIdentities != nil) {
 		return fmt.Errorf("config: policy route marked as public but contains whitelists")
 	}
 
@@ -129,6 +347,141 @@ func (p *Policy) Validate() error {
 		}
 	}
 
+	if p.Regex != "" {
+		p.CompiledRegex, err = regexp.Compile(p.Regex)
+		if err != nil {
+			return fmt.Errorf("config: policy bad regex: %w", err)
+		}
+	}
+
+	if p.DenyStatusCode != 0 && (p.DenyStatusCode < 400 || p.DenyStatusCode > 499) {
+		return fmt.Errorf("config: policy deny status code must be a 4xx code, got %d", p.DenyStatusCode)
+	}
+
+	switch p.SessionPreference {
+	case "", SessionPreferenceCookieFirst, SessionPreferenceHeaderFirst, SessionPreferenceHeaderOnly, SessionPreferenceCookieOnly:
+	default:
+		return fmt.Errorf("config: policy unknown session preference: %s", p.SessionPreference)
+	}
+
+	switch p.OptionsRequests {
+	case "", OptionsRequestsAuthorize, OptionsRequestsPassThrough, OptionsRequestsRespond:
+	default:
+		return fmt.Errorf("config: policy unknown options requests handling: %s", p.OptionsRequests)
+	}
+
+	if strings.ContainsAny(p.JWTAssertionHeader, " \t:") {
+		return fmt.Errorf("config: policy bad jwt assertion header: %q", p.JWTAssertionHeader)
+	}
+
+	switch p.JWTAssertionFormat {
+	case "", JWTAssertionFormatRaw, JWTAssertionFormatBearer, JWTAssertionFormatNone:
+	default:
+		return fmt.Errorf("config: policy unknown jwt assertion format: %s", p.JWTAssertionFormat)
+	}
+
+	if _, ok := clientTLSVersions[p.ClientTLSMinVersion]; p.ClientTLSMinVersion != "" && !ok {
+		return fmt.Errorf("config: policy bad client tls min version: %q", p.ClientTLSMinVersion)
+	}
+	for _, suite := range p.ClientTLSCipherSuites {
+		if suite == "" {
+			return errors.New("config: policy client tls cipher suites cannot be empty")
+		}
+	}
+
+	if p.AppCookieName != "" {
+		if !httpguts.ValidHeaderFieldName(p.AppCookieName) {
+			return fmt.Errorf("config: policy bad app cookie name: %q", p.AppCookieName)
+		}
+		if _, err := p.GetAppCookieKey(); err != nil {
+			return err
+		}
+	}
+
+	for i := range p.HeaderTransforms {
+		if err := p.HeaderTransforms[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	if p.PartnerCookieName != "" {
+		if !httpguts.ValidHeaderFieldName(p.PartnerCookieName) {
+			return fmt.Errorf("config: policy bad partner cookie name: %q", p.PartnerCookieName)
+		}
+		if _, err := p.GetPartnerCookieKey(); err != nil {
+			return err
+		}
+	}
+
+	if p.MaxConcurrentChecks < 0 {
+		return fmt.Errorf("config: policy max concurrent checks cannot be negative: %d", p.MaxConcurrentChecks)
+	}
+
+	for i, method := range p.AllowedMethods {
+		if method == "" {
+			return fmt.Errorf("config: policy allowed methods cannot be empty")
+		}
+		p.AllowedMethods[i] = strings.ToUpper(method)
+	}
+
+	for i, contentType := range p.AllowedContentTypes {
+		mediaType, params, err := mime.ParseMediaType(contentType)
+		if err != nil || len(params) > 0 || !strings.Contains(mediaType, "/") || strings.HasPrefix(mediaType, "*/") {
+			return fmt.Errorf("config: policy bad allowed content type: %q", contentType)
+		}
+		p.AllowedContentTypes[i] = mediaType
+	}
+
+	if p.CSRFHeader != "" && !httpguts.ValidHeaderFieldName(p.CSRFHeader) {
+		return fmt.Errorf("config: policy bad csrf header: %q", p.CSRFHeader)
+	}
+	if len(p.CSRFMethods) > 0 && p.CSRFHeader == "" {
+		return errors.New("config: policy csrf methods require a csrf header")
+	}
+	for i, method := range p.CSRFMethods {
+		if method == "" {
+			return fmt.Errorf("config: policy csrf methods cannot be empty")
+		}
+		p.CSRFMethods[i] = strings.ToUpper(method)
+	}
+
+	for name, values := range p.RequiredQueryParams {
+		if name == "" {
+			return errors.New("config: policy required query params cannot be empty")
+		}
+		// a parameter without values is passed to the policy evaluator as an
+		// empty list, rather than null
+		if values == nil {
+			p.RequiredQueryParams[name] = []string{}
+		}
+	}
+
+	for i, country := range p.AllowedCountries {
+		if len(country) != 2 {
+			return fmt.Errorf("config: policy allowed country must be a two letter code: %q", country)
+		}
+		p.AllowedCountries[i] = strings.ToUpper(country)
+	}
+
+	for i, serverName := range p.AllowedServerNames {
+		p.AllowedServerNames[i] = strings.ToLower(serverName)
+	}
+
+	for i, weekday := range p.AllowedWeekdays {
+		if !isWeekday(weekday) {
+			return fmt.Errorf("config: policy allowed weekday must be the name of a day: %q", weekday)
+		}
+		p.AllowedWeekdays[i] = strings.ToLower(weekday)
+	}
+
+	if p.AllowedHours != "" {
+		hours, err := parseHourRange(p.AllowedHours)
+		if err != nil {
+			return fmt.Errorf("config: bad policy allowed hours %q: %w", p.AllowedHours, err)
+		}
+		p.AllowedHours = hours
+	}
+
 	if p.TLSCustomCA != "" {
 		_, err := base64.StdEncoding.DecodeString(p.TLSCustomCA)
 		if err != nil {
@@ -144,6 +497,129 @@ func (p *Policy) Validate() error {
 	return nil
 }
 
+// GetClientTLSMinVersion returns the tls package constant of the minimum TLS
+// version of client connections, or 0 if any version is allowed.
+func (p *Policy) GetClientTLSMinVersion() uint16 {
+	return clientTLSVersions[p.ClientTLSMinVersion]
+}
+
+// GetAppCookieKey returns the decoded key the app cookie is signed with.
+func (p *Policy) GetAppCookieKey() ([]byte, error) {
+	return decodeCookieKey("app cookie secret", p.AppCookieSecret)
+}
+
+// DefaultCSRFMethods are the methods requiring a route's CSRF header, if it
+// doesn't set its own.
+var DefaultCSRFMethods = []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// GetCSRFMethods returns the methods requiring the route's CSRF header.
+func (p *Policy) GetCSRFMethods() []string {
+	if len(p.CSRFMethods) == 0 {
+		return DefaultCSRFMethods
+	}
+	return p.CSRFMethods
+}
+
+// GetPartnerCookieKey returns the decoded key the partner cookie is signed
+// with.
+func (p *Policy) GetPartnerCookieKey() ([]byte, error) {
+	return decodeCookieKey("partner cookie key", p.PartnerCookieKey)
+}
+
+// decodeCookieKey decodes a base64 encoded cookie signing key, of at least 32
+// bytes.
+func decodeCookieKey(name, value string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("config: policy %s is not base64 encoded: %w", name, err)
+	}
+	if len(key) < 32 {
+		return nil, fmt.Errorf("config: policy %s must be at least 32 bytes, got %d", name, len(key))
+	}
+	return key, nil
+}
+
+// isWeekday returns true if s is the English name of a day of the week,
+// ignoring case.
+func isWeekday(s string) bool {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(s, d.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHourRange parses a range of hours of the day, such as 9:00-17:30, and
+// returns it with zero padded times, e.g. 09:00-17:30, so that the times can
+// be compared as strings.
+func parseHourRange(s string) (string, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return "", errors.New("must be a start and end time, e.g. 09:00-17:00")
+	}
+	var times [2]string
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return "", fmt.Errorf("bad time %q, must be hh:mm", part)
+		}
+		times[i] = t.Format("15:04")
+	}
+	if times[0] == times[1] {
+		return "", errors.New("start and end times must differ")
+	}
+	return times[0] + "-" + times[1], nil
+}
+
+// GetRemovedRequestHeaders returns the names of the headers the route's header
+// transforms remove, and don't then add back. They're removed from the
+// upstream request after it's authorized, so that they're also removed if sent
+// by the client.
+func (p *Policy) GetRemovedRequestHeaders() []string {
+	var names []string
+	removed := make(map[string]bool)
+	for _, t := range p.HeaderTransforms {
+		switch {
+		case t.Rename != "":
+			removed[http.CanonicalHeaderKey(t.To)] = false
+		case t.Add != "":
+			removed[http.CanonicalHeaderKey(t.Add)] = false
+		case t.Remove != "":
+			removed[http.CanonicalHeaderKey(t.Remove)] = true
+		}
+	}
+	for name, ok := range removed {
+		if ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Matches returns true if the policy route matches the given request URL.
+func (p *Policy) Matches(requestURL *url.URL) bool {
+	if p.Source != nil && p.Source.Host != requestURL.Host {
+		return false
+	}
+
+	path := requestURL.Path
+	if path == "" {
+		path = "/"
+	}
+	if p.Prefix != "" && !strings.HasPrefix(path, p.Prefix) {
+		return false
+	}
+	if p.Path != "" && p.Path != path {
+		return false
+	}
+	if p.CompiledRegex != nil && !p.CompiledRegex.MatchString(path) {
+		return false
+	}
+	return true
+}
+
 // Checksum returns the xxhash hash for the policy.
 func (p *Policy) Checksum() uint64 {
 	cs, _ := hashstructure.Hash(p, &hashstructure.HashOptions{
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"net/url"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -37,6 +38,51 @@ func Test_PolicyValidate(t *testing.T) {
 		{"bad certificate file", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", TLSClientCertFile: "testdata/example-cert-404.pem", TLSClientKeyFile: "testdata/example-key.pem"}, true},
 		{"bad key file", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", TLSClientCertFile: "testdata/example-cert.pem", TLSClientKeyFile: "testdata/example-key-404.pem"}, true},
 		{"good tls server name", Policy{From: "https://httpbin.corp.example", To: "https://internal-host-name", TLSServerName: "httpbin.corp.notatld"}, false},
+		{"good deny status code", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", DenyStatusCode: 404}, false},
+		{"bad deny status code", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", DenyStatusCode: 500}, true},
+		{"bad deny status code redirect", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", DenyStatusCode: 302}, true},
+		{"bad regex", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", Regex: "("}, true},
+		{"good allowed methods", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedMethods: []string{"GET", "head"}}, false},
+		{"good session preference", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", SessionPreference: SessionPreferenceHeaderOnly}, false},
+		{"bad session preference", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", SessionPreference: "header-last"}, true},
+		{"good allowed content types", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedContentTypes: []string{"application/json", "image/*"}}, false},
+		{"bad allowed content type", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedContentTypes: []string{"json"}}, true},
+		{"bad allowed content type parameters", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedContentTypes: []string{"text/html; charset=utf-8"}}, true},
+		{"good csrf header", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", CSRFHeader: "X-Requested-With", CSRFMethods: []string{"post"}}, false},
+		{"bad csrf header", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", CSRFHeader: "X Requested With"}, true},
+		{"csrf methods without a csrf header", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", CSRFMethods: []string{"POST"}}, true},
+		{"bad allowed content type wildcard", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedContentTypes: []string{"*/*"}}, true},
+		{"good options requests", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", OptionsRequests: OptionsRequestsRespond}, false},
+		{"bad options requests", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", OptionsRequests: "answer"}, true},
+		{"good jwt assertion header", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", JWTAssertionHeader: "Authorization", JWTAssertionFormat: JWTAssertionFormatBearer}, false},
+		{"bad jwt assertion header", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", JWTAssertionHeader: "X-Jwt: x"}, true},
+		{"bad jwt assertion format", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", JWTAssertionFormat: "basic"}, true},
+		{"good app cookie", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AppCookieName: "_app", AppCookieSecret: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}, false},
+		{"bad app cookie name", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AppCookieName: "_app; x=y", AppCookieSecret: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}, true},
+		{"missing app cookie secret", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AppCookieName: "_app"}, true},
+		{"short app cookie secret", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AppCookieName: "_app", AppCookieSecret: "MDEyMzQ1Njc4OWFiY2RlZg=="}, true},
+		{"good client tls requirements", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", ClientTLSMinVersion: "1.3", ClientTLSCipherSuites: []string{"TLS_AES_128_GCM_SHA256"}}, false},
+		{"bad client tls min version", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", ClientTLSMinVersion: "TLSv1.3"}, true},
+		{"empty client tls cipher suite", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", ClientTLSCipherSuites: []string{""}}, true},
+		{"good partner cookie", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", PartnerCookieName: "_partner", PartnerCookieKey: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="}, false},
+		{"missing partner cookie key", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", PartnerCookieName: "_partner"}, true},
+		{"empty allowed method", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedMethods: []string{""}}, true},
+		{"good required query params", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", RequiredQueryParams: map[string][]string{"tenant": {"acme"}, "debug": nil}}, false},
+		{"empty required query param", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", RequiredQueryParams: map[string][]string{"": {"acme"}}}, true},
+		{"good allowed countries", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedCountries: []string{"us", "GB"}}, false},
+		{"bad allowed country", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedCountries: []string{"USA"}}, true},
+		{"good allowed weekdays", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedWeekdays: []string{"Monday", "friday"}}, false},
+		{"bad allowed weekday", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedWeekdays: []string{"mon"}}, true},
+		{"good allowed hours", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedHours: "9:00-17:30"}, false},
+		{"overnight allowed hours", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedHours: "22:00-06:00"}, false},
+		{"bad allowed hours", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedHours: "9am-5pm"}, true},
+		{"empty allowed hours range", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", AllowedHours: "09:00-09:00"}, true},
+		{"good header transforms", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", HeaderTransforms: []HeaderTransform{{Rename: "X-Pomerium-Claim-Email", To: "X-User-Email"}, {Add: "X-Team", Value: "platform"}, {Remove: "X-Pomerium-Jwt-Assertion"}}}, false},
+		{"header transform without action", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", HeaderTransforms: []HeaderTransform{{Value: "platform"}}}, true},
+		{"header transform with two actions", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", HeaderTransforms: []HeaderTransform{{Add: "X-Team", Remove: "X-Other"}}}, true},
+		{"header transform rename without new name", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", HeaderTransforms: []HeaderTransform{{Rename: "X-Pomerium-Claim-Email"}}}, true},
+		{"header transform bad name", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", HeaderTransforms: []HeaderTransform{{Add: "X Team"}}}, true},
+		{"bad max concurrent checks", Policy{From: "https://httpbin.corp.example", To: "https://httpbin.corp.notatld", MaxConcurrentChecks: -1}, true},
 	}
 
 	for _, tt := range tests {
@@ -81,3 +127,74 @@ func TestPolicy_String(t *testing.T) {
 		})
 	}
 }
+
+func TestPolicy_Matches(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		policy Policy
+		url    string
+		want   bool
+	}{
+		{"host", Policy{From: "https://from.example", To: "https://to.example"}, "https://from.example/some/path", true},
+		{"other host", Policy{From: "https://from.example", To: "https://to.example"}, "https://other.example/", false},
+		{"prefix", Policy{From: "https://from.example", To: "https://to.example", Prefix: "/admin"}, "https://from.example/admin/users", true},
+		{"prefix mismatch", Policy{From: "https://from.example", To: "https://to.example", Prefix: "/admin"}, "https://from.example/users", false},
+		{"path", Policy{From: "https://from.example", To: "https://to.example", Path: "/"}, "https://from.example", true},
+		{"path mismatch", Policy{From: "https://from.example", To: "https://to.example", Path: "/exact"}, "https://from.example/exact/not", false},
+		{"regex", Policy{From: "https://from.example", To: "https://to.example", Regex: `^/\d+$`}, "https://from.example/123", true},
+		{"regex mismatch", Policy{From: "https://from.example", To: "https://to.example", Regex: `^/\d+$`}, "https://from.example/abc", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policy.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			u, err := url.Parse(tt.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.policy.Matches(u); got != tt.want {
+				t.Errorf("Policy.Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseHourRange(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"09:00-17:00", "09:00-17:00", false},
+		{"9:00-17:30", "09:00-17:30", false},
+		{" 22:00 - 6:00 ", "22:00-06:00", false},
+		{"09:00", "", true},
+		{"09:00-25:00", "", true},
+		{"12:00-12:00", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseHourRange(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseHourRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseHourRange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_GetRemovedRequestHeaders(t *testing.T) {
+	p := Policy{HeaderTransforms: []HeaderTransform{
+		{Remove: "x-forwarded-user"},
+		{Remove: "X-Team"},
+		{Rename: "X-Pomerium-Claim-Email", To: "X-User-Email"},
+		{Add: "x-team", Value: "platform"},
+	}}
+	if diff := cmp.Diff([]string{"X-Forwarded-User"}, p.GetRemovedRequestHeaders()); diff != "" {
+		t.Errorf("GetRemovedRequestHeaders() = %s", diff)
+	}
+}
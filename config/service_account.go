@@ -0,0 +1,38 @@
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ServiceAccountAPIKey maps a salted hash of a pre-shared API key to the
+// identity of a service account. The API key itself is never stored.
+type ServiceAccountAPIKey struct {
+	// Salt is the key used to hash the API key.
+	Salt string `mapstructure:"salt" yaml:"salt"`
+	// Hash is the base64 encoded HMAC-SHA-512/256 of the API key, keyed by
+	// the salt.
+	Hash string `mapstructure:"hash" yaml:"hash"`
+
+	// Email and Groups are the identity of the service account.
+	Email  string   `mapstructure:"email" yaml:"email"`
+	Groups []string `mapstructure:"groups" yaml:"groups,omitempty"`
+
+	// Revoked API keys are denied.
+	Revoked bool `mapstructure:"revoked" yaml:"revoked,omitempty"`
+}
+
+// Validate checks the validity of a service account API key.
+func (k *ServiceAccountAPIKey) Validate() error {
+	if k.Salt == "" {
+		return errors.New("config: service account api key salt is required")
+	}
+	if _, err := base64.StdEncoding.DecodeString(k.Hash); err != nil || k.Hash == "" {
+		return fmt.Errorf("config: service account api key hash must be base64 encoded")
+	}
+	if k.Email == "" {
+		return errors.New("config: service account email is required")
+	}
+	return nil
+}
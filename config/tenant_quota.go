@@ -0,0 +1,32 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TenantQuota limits the requests allowed for a tenant, identified by the
+// tenant claim of its users' sessions, in each period.
+type TenantQuota struct {
+	// Tenant is the value of the tenant claim.
+	Tenant string `mapstructure:"tenant" yaml:"tenant"`
+	// Requests is the number of allowed requests in each period.
+	Requests int `mapstructure:"requests" yaml:"requests"`
+	// Period is how often the quota resets.
+	Period time.Duration `mapstructure:"period" yaml:"period"`
+}
+
+// Validate checks the validity of a tenant quota.
+func (q *TenantQuota) Validate() error {
+	if q.Tenant == "" {
+		return errors.New("config: tenant quota tenant is required")
+	}
+	if q.Requests < 0 {
+		return fmt.Errorf("config: tenant quota requests cannot be negative: %d", q.Requests)
+	}
+	if q.Period <= 0 {
+		return fmt.Errorf("config: tenant quota period must be positive: %s", q.Period)
+	}
+	return nil
+}
@@ -409,3 +409,35 @@ func TestAttestationJWT(t *testing.T) {
 		"Expected JWT assertion")
 
 }
+
+func TestStripReservedHeaders(t *testing.T) {
+	ctx := mainCtx
+	ctx, clearTimeout := context.WithTimeout(ctx, time.Second*30)
+	defer clearTimeout()
+
+	client := testcluster.NewHTTPClient()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://httpdetails.localhost.pomerium.io/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Pomerium-Claim-Email", "spoofed@example.com")
+	req.Header.Set("X-Pomerium-Example", "spoofed")
+
+	res, err := client.Do(req)
+	if !assert.NoError(t, err, "unexpected http error") {
+		return
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		Headers map[string]string `json:"headers"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&result)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NotEqual(t, "spoofed@example.com", result.Headers["X-Pomerium-Claim-Email"], "Expected spoofed claim header to be stripped")
+	assert.NotContains(t, result.Headers, "X-Pomerium-Example", "Expected spoofed header to be stripped")
+}
@@ -10,9 +10,11 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	envoy_service_auth_v2 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v2"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/reflection"
 
 	"github.com/pomerium/pomerium/authenticate"
 	"github.com/pomerium/pomerium/authorize"
@@ -20,6 +22,7 @@ import (
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/controlplane"
 	"github.com/pomerium/pomerium/internal/envoy"
+	pbAuthorize "github.com/pomerium/pomerium/internal/grpc/authorize"
 	pbCache "github.com/pomerium/pomerium/internal/grpc/cache"
 	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/log"
@@ -30,6 +33,10 @@ import (
 	"github.com/pomerium/pomerium/proxy"
 )
 
+// signerSelfCheckInterval is how often the authorize service checks that its
+// signer can verify the tokens it signs.
+const signerSelfCheckInterval = time.Minute
+
 // Run runs the main pomerium application.
 func Run(ctx context.Context, configFile string) error {
 	opt, err := config.NewOptionsFromConfig(configFile)
@@ -74,7 +81,7 @@ func Run(ctx context.Context, configFile string) error {
 	if err := setupAuthenticate(opt, controlPlane); err != nil {
 		return err
 	}
-	if err := setupAuthorize(opt, controlPlane, &optionsUpdaters); err != nil {
+	if err := setupAuthorize(ctx, opt, controlPlane, &optionsUpdaters); err != nil {
 		return err
 	}
 	if err := setupCache(opt, controlPlane); err != nil {
@@ -83,6 +90,7 @@ func Run(ctx context.Context, configFile string) error {
 	if err := setupProxy(opt, controlPlane); err != nil {
 		return err
 	}
+	setupGRPCReflection(opt, controlPlane)
 
 	// start the config change listener
 	go config.WatchChanges(configFile, opt, optionsUpdaters)
@@ -130,7 +138,7 @@ func setupAuthenticate(opt *config.Options, controlPlane *controlplane.Server) e
 	return nil
 }
 
-func setupAuthorize(opt *config.Options, controlPlane *controlplane.Server, optionsUpdaters *[]config.OptionsUpdater) error {
+func setupAuthorize(ctx context.Context, opt *config.Options, controlPlane *controlplane.Server, optionsUpdaters *[]config.OptionsUpdater) error {
 	if !config.IsAuthorize(opt.Services) {
 		return nil
 	}
@@ -140,6 +148,8 @@ func setupAuthorize(opt *config.Options, controlPlane *controlplane.Server, opti
 		return fmt.Errorf("error creating authorize service: %w", err)
 	}
 	envoy_service_auth_v2.RegisterAuthorizationServer(controlPlane.GRPCServer, svc)
+	pbAuthorize.RegisterAuthorizerServer(controlPlane.GRPCServer, svc)
+	controlPlane.HTTPRouter.Path(authorize.VersionPath).Handler(svc.VersionHandler())
 
 	log.Info().Msg("enabled authorize service")
 
@@ -148,9 +158,18 @@ func setupAuthorize(opt *config.Options, controlPlane *controlplane.Server, opti
 	if err != nil {
 		return fmt.Errorf("error updating authorize options: %w", err)
 	}
+	go svc.RunSignerSelfCheck(ctx, controlPlane.HealthServer, signerSelfCheckInterval)
 	return nil
 }
 
+func setupGRPCReflection(opt *config.Options, controlPlane *controlplane.Server) {
+	if !opt.GRPCServerReflection {
+		return
+	}
+	reflection.Register(controlPlane.GRPCServer)
+	log.Warn().Msg("enabled gRPC server reflection")
+}
+
 func setupCache(opt *config.Options, controlPlane *controlplane.Server) error {
 	if !config.IsCache(opt.Services) {
 		return nil
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/controlplane"
 )
 
 func Test_setupTracing(t *testing.T) {
@@ -48,6 +49,33 @@ func Test_setupMetrics(t *testing.T) {
 	}
 }
 
+func Test_setupGRPCReflection(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  *config.Options
+		want bool
+	}{
+		{"disabled by default", &config.Options{}, false},
+		{"enabled", &config.Options{GRPCServerReflection: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controlPlane, err := controlplane.NewServer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer controlPlane.GRPCListener.Close()
+			defer controlPlane.HTTPListener.Close()
+
+			setupGRPCReflection(tt.opt, controlPlane)
+			_, got := controlPlane.GRPCServer.GetServiceInfo()["grpc.reflection.v1alpha.ServerReflection"]
+			if got != tt.want {
+				t.Errorf("reflection registered = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func waitSig(t *testing.T, c <-chan os.Signal, sig os.Signal) {
 	select {
 	case s := <-c:
@@ -1,4 +1,6 @@
 function remove_pomerium_cookie(cookie_name, cookie)
+    -- escape the name's punctuation, e.g. "-", which is magic in patterns
+    cookie_name = cookie_name:gsub("%p", "%%%0")
     -- lua doesn't support optional capture groups
     -- so we replace twice to handle pomerium=xyz at the end of the string
     cookie = cookie:gsub(cookie_name .. "=[^;]+; ", "")
@@ -23,12 +25,37 @@ function envoy_on_request(request_handle)
         end
     end
 
-    local remove_authorization = metadata:get("remove_pomerium_authorization")
-    if remove_authorization then
+    local remove_cookie_names = metadata:get("remove_pomerium_legacy_cookies")
+    if remove_cookie_names then
+        local cookie = headers:get("cookie")
+        if cookie ~= nil then
+            for _, name in ipairs(remove_cookie_names) do
+                cookie = remove_pomerium_cookie(name, cookie)
+            end
+            headers:replace("cookie", cookie)
+        end
+    end
+
+    -- sessions are loaded from the authorization header with any of the
+    -- session schemes, matched case-insensitively
+    local remove_authorization_schemes = metadata:get("remove_pomerium_authorization")
+    if remove_authorization_schemes then
         local authorization = headers:get("authorization")
-        local authorization_prefix = "Pomerium "
-        if has_prefix(authorization, authorization_prefix) then
-            headers:remove("authorization")
+        if authorization ~= nil then
+            authorization = authorization:gsub("^%s+", ""):lower()
+            for _, scheme in ipairs(remove_authorization_schemes) do
+                if has_prefix(authorization, scheme:lower() .. " ") or has_prefix(authorization, scheme:lower() .. "\t") then
+                    headers:remove("authorization")
+                    break
+                end
+            end
+        end
+    end
+
+    local reserved_header_exemptions = metadata:get("reserved_header_exemptions")
+    if reserved_header_exemptions then
+        for _, key in ipairs(reserved_header_exemptions) do
+            headers:remove(key)
         end
     end
 end
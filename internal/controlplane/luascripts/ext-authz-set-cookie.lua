@@ -6,6 +6,53 @@ function envoy_on_request(request_handle)
                          headers:get("x-pomerium-set-cookie"))
         headers:remove("x-pomerium-set-cookie")
     end
+    if headers:get("x-pomerium-decision-time") ~= nil then
+        dynamic_meta:set("envoy.filters.http.lua", "pomerium_decision_time",
+                         headers:get("x-pomerium-decision-time"))
+        headers:remove("x-pomerium-decision-time")
+    end
+    if headers:get("x-pomerium-server-timing") ~= nil then
+        dynamic_meta:set("envoy.filters.http.lua", "pomerium_server_timing",
+                         headers:get("x-pomerium-server-timing"))
+        headers:remove("x-pomerium-server-timing")
+    end
+    if headers:get("x-pomerium-session-grace") ~= nil then
+        dynamic_meta:set("envoy.filters.http.lua", "pomerium_session_grace",
+                         headers:get("x-pomerium-session-grace"))
+        headers:remove("x-pomerium-session-grace")
+    end
+    if headers:get("x-pomerium-session-expires-in") ~= nil then
+        dynamic_meta:set("envoy.filters.http.lua", "pomerium_session_expires_in",
+                         headers:get("x-pomerium-session-expires-in"))
+        headers:remove("x-pomerium-session-expires-in")
+    end
+    if headers:get("x-pomerium-app-cookie") ~= nil then
+        dynamic_meta:set("envoy.filters.http.lua", "pomerium_app_cookie",
+                         headers:get("x-pomerium-app-cookie"))
+        headers:remove("x-pomerium-app-cookie")
+    end
+    local warnings = {}
+    for key, value in pairs(headers) do
+        if key == "x-pomerium-warning" then
+            table.insert(warnings, value)
+        end
+    end
+    if #warnings > 0 then
+        dynamic_meta:set("envoy.filters.http.lua", "pomerium_warnings",
+                         table.concat(warnings, "\n"))
+        headers:remove("x-pomerium-warning")
+    end
+    local cookie_migration = {}
+    for key, value in pairs(headers) do
+        if key == "x-pomerium-cookie-migration" then
+            table.insert(cookie_migration, value)
+        end
+    end
+    if #cookie_migration > 0 then
+        dynamic_meta:set("envoy.filters.http.lua", "pomerium_cookie_migration",
+                         table.concat(cookie_migration, "\n"))
+        headers:remove("x-pomerium-cookie-migration")
+    end
 end
 
 function envoy_on_response(response_handle)
@@ -15,4 +62,29 @@ function envoy_on_response(response_handle)
     if tbl ~= nil and tbl["pomerium_set_cookie"] ~= nil then
         headers:add("set-cookie", tbl["pomerium_set_cookie"])
     end
+    if tbl ~= nil and tbl["pomerium_app_cookie"] ~= nil then
+        headers:add("set-cookie", tbl["pomerium_app_cookie"])
+    end
+    if tbl ~= nil and tbl["pomerium_cookie_migration"] ~= nil then
+        for cookie in string.gmatch(tbl["pomerium_cookie_migration"], "[^\n]+") do
+            headers:add("set-cookie", cookie)
+        end
+    end
+    if tbl ~= nil and tbl["pomerium_decision_time"] ~= nil then
+        headers:replace("x-pomerium-decision-time", tbl["pomerium_decision_time"])
+    end
+    if tbl ~= nil and tbl["pomerium_server_timing"] ~= nil then
+        headers:add("server-timing", tbl["pomerium_server_timing"])
+    end
+    if tbl ~= nil and tbl["pomerium_session_grace"] ~= nil then
+        headers:replace("x-pomerium-session-grace", tbl["pomerium_session_grace"])
+    end
+    if tbl ~= nil and tbl["pomerium_session_expires_in"] ~= nil then
+        headers:replace("x-pomerium-session-expires-in", tbl["pomerium_session_expires_in"])
+    end
+    if tbl ~= nil and tbl["pomerium_warnings"] ~= nil then
+        for warning in string.gmatch(tbl["pomerium_warnings"], "[^\n]+") do
+            headers:add("x-pomerium-warning", warning)
+        end
+    end
 end
@@ -0,0 +1,26 @@
+-- passes the properties of the downstream TLS connection to the ext_authz
+-- filter, as dynamic metadata, so that routes may require a minimum version
+-- or server name
+function envoy_on_request(request_handle)
+    local stream_info = request_handle:streamInfo()
+    local dynamic_meta = stream_info:dynamicMetadata()
+    -- only available in newer versions of envoy
+    if stream_info.requestedServerName ~= nil then
+        local sni = stream_info:requestedServerName()
+        if sni ~= nil and sni ~= "" then
+            dynamic_meta:set("pomerium.tls", "sni", sni)
+        end
+    end
+    if stream_info.downstreamSslConnection == nil then
+        return
+    end
+    local ssl = stream_info:downstreamSslConnection()
+    if ssl == nil then
+        return
+    end
+    dynamic_meta:set("pomerium.tls", "version", ssl:tlsVersion())
+    dynamic_meta:set("pomerium.tls", "cipher_suite", ssl:ciphersuiteString())
+end
+
+function envoy_on_response(response_handle)
+end
@@ -9,6 +9,6 @@ import (
 const Luascripts = "luascripts" // static asset namespace
 
 func init() {
-	data := "PK\x03\x04\x14\x00\x08\x00\x08\x00@\x89\xb3P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x12\x00	\x00clean-upstream.luaUT\x05\x00\x01\xe8\x12\xc4^\x94S\xc1n\x9c0\x10\xbd\xf3\x15O\xf4PV%\x91z\xdd\xc8\xff\xd0{\xd5\"\x17f\x17\xab`\xbb\xf68\x9b\xe4\xd0o\xaf\x00\xc3\xe2\x00\xaa\xe2\x03\x1e\xcb\xf3\xde<\xde\x8c/A\xd7\xac\x8c\x86\xa3\xde<SeMON\x85\xbe\xaa\x8d\xf9\xad\xa8\x98\xb6J\xcb\x9eJL\x87S\x06\x00\x0f\x0f\xe8\x82Dc\xc8\xeb\xcf\x0c\x1f\xac5\x8ea\xec\xc0&;\xd4\xd2rp\x84\xab3\xc1\xfa\x19\xe2\x0dn\x04G\xb6\x935\x81oj\xf8\x1a\xb4R7\x1da..^^\xdf \x19\xdc\x12H70\x971\xf4\xec\x94\xbe\x8eT\x93\x12\x88\x18\x9c\xaf>\xfcZk\xc5\xe3#r\xf1\xfd\xe7\xd3\x8f/O\xc8K\xe4\xf9\xe9\xa3\xb8\x15\xca\x11\x07\xa7#&#\xddd\xd9\xe2[+}e\x1d]\xd4K\xe1\xd9\x95\x98\xe2\x04\xe7\xd9\xe1\xaf\x80V\x1d\xa4n\x86\xe3y(\xfb\xb5\xc4\xa7\x98\x0d!\"\xf0\x1d;\xe9g\xf3Z\x19]9\xfa\x13\xc8s\x11\xf7jrl*\xd3\x99ZvhI6\xe4<\x04\xd2\x9cs\xbc(\xd6\xc9=\xb1l$\xcbm\xf6|S\x9c\xb2U~\x9c\x8e\xb5Sb!9_\x89\x8b|\x7f\x80\xa2\x83\xea\xb2G\xc1-\xe9\xf1\xfa^hiPT=q'\\\x91/fFc\x13\xaaai\xba-\\\x07\xb3\xbdU\x94\x8e\xf8\xbcf)ql\x179\xe5\xbd\xc8\x1d0\xf4o\xde\xb7\x06\xca\xc0\xadq\xeaM\x8e\xdd\xfd\x9f\x85I\xf6\xc6\xc9\x94k\xc7\xcb\xf7\xc5\x12K\xf7\xb8\x0f\xa0q\xbe!\x90\x7f\x8b\xd2\x90\xaf[\xb1z\x03	\xb0\xdc\xe59m\x9buwx\xf8\xb3cqks\x0f\x1f\x8a\xb7F\xfb\xa1\xbbS\xb0<\x95\x11\xf1/\x00\x00\xff\xffPK\x07\x08\xfb\x06j<\xa2\x01\x00\x00\xf0\x04\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00bz\xb3P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x18\x00	\x00ext-authz-set-cookie.luaUT\x05\x00\x01\xe8\xf8\xc3^\x8c\x91An\x830\x10E\xf7\x9cb\xc4\xcaHI\x0e\x80\x94\x03t\xd1\x13T\x955\xc5C\xb0j\x8fS{\x88\x9aM\xcf^AM\x04\x0dM\x19	\x01\xe2\xff?\xf8\xbf\xb6\xe7Fl` \xbe\x84\xab\x0e\xac#}\xf4\x94D\xe5\xbb\xee\x90\x8d\xa3\xaa\x00\x00p\xa1A\x07\x1d\xa1\xa1\x98\xe0\x08KM\x9d?\xa8\xb9\xd8\\\x19\xbdm\xb4'\xc1{G\x92H\xe8\x9f\xb8\x0d\xaa\xaa\xb3\xf4\x99\x04\x0d\n\xe6\x18\xdbN\x0b\xeb\x13\x89*?\xf7\xe7\xe0)\xda\xde\xef\x13\xc9\xbe	\xe1\xddRY\xc1\xd7\x11\xd8:\x90\x8ex\xf4\x0d3_^\xa7\xc1=\x1e\xf3\xd0Z'\x14\xd3\xa1\x139\x1f\\\x8f\xe5\x0e\xca)U'\x12\x9dSw\xb7\xa4\xbb\xd9\xf2OU\xf1[\x1d\xc9\x87\x0b\xfdi\x18\xf5\xc4\xa6\x18\xaeb\x8dM:\x07N\xa4\xa6\x87\x7f\xe8,D\xdb\xf0,-\x1b\xf8\xfc\xe4\xc8\x9b\x83\xe3\xb2\xef\xd3\x83\xbeoh\x07_&\x87l\x86\xd7\x97U\x12\xaf\xab|\xa7Z\xd1\x18U\xce\x8a\xdc=\x08Z\x96\xfc\x1d\x00\x00\xff\xffPK\x07\x08\x93\xe7\xad\x94\x07\x01\x00\x00\x00\x03\x00\x00PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00@\x89\xb3P\xfb\x06j<\xa2\x01\x00\x00\xf0\x04\x00\x00\x12\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\x00\x00\x00\x00clean-upstream.luaUT\x05\x00\x01\xe8\x12\xc4^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00bz\xb3P\x93\xe7\xad\x94\x07\x01\x00\x00\x00\x03\x00\x00\x18\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\xeb\x01\x00\x00ext-authz-set-cookie.luaUT\x05\x00\x01\xe8\xf8\xc3^PK\x05\x06\x00\x00\x00\x00\x02\x00\x02\x00\x98\x00\x00\x00A\x03\x00\x00\x00\x00"
+	data := "PK\x03\x04\x14\x00\x08\x00\x08\x00LTN]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x12\x00	\x00clean-upstream.luaUT\x05\x00\x01\xb0Z\xcfj\xb4UA\x8f\x9b<\x10\xbd\xe7W<\xf1)Z\xa2\x8fD\xed5+~I\xdbE^\x18\x82\x15\xb0\xa9\xc7$\x9b=\xf4\xb7W\x80!8\xc0\xae\xf6P\x1f\x12,\xcf\xbc\x19\xbfy3\xce\x1b\x95Z\xa9\x15\x0cU\xfaBI\xad+2\xb2\xa9\x92T\xeb\xb3\xa4\xb0\xffK\x94\xa8(B\xbf\xd9m\x00`\xbf\x07q*j\x82-\x08\xed\xf9\x13\xa3n\xd1\x1a\xd1\x02F\xa0\xc3\xe9\x80`\x1fD\xb8\x162- \x19\x958\xc9\x14R\xa1\x16\xd6\x92Q\xdcAMb \x9e\xee\x8e'n^\xc3`[\x07\x11\x82\xedv\xfb-\x18c\x97\x8d@\xa6\x89\xd5\x93\x057u\xad\x8d\x85\xae\xdb\xc0\xa2D*j\xdb\x18\xc2\xc9\xe8\xa6\xe6\xc1\x855\xae\x04Cu)R\x82\xbd\xca\xf6W\xa3\x10*+	\xc3\xc5\xe3\xb7\xdb;\x84\xednE*\x83\xce\xbbO\xb6F\xaa\xd3$\xdd1\xd3>\xc9I\xd68\x1c\x10\xc4?^\x9e\x7f\xfd\xff\x8c6\xf3`\xf7U\xbf\x89\x97!\xdb\x18\xe5bmHe\x9b\xcdX\xb3BpR\x1b\xca\xe5[\xc8\xd6D\xe8\xbf=?\xb6\x06\x7fb(YB\xa8\xac\xdd\x1e[N\xbfG\xf8\xcfY#\x8e\x9d\xe3\x03:\xa9\x8b\xbe%Z%\x86~7\xc46t\xffI\xcfX\x1f\xa6\xd4\xa9(Q\x90\xc8\xc80b\xf86Gw\x10N\x8d+\xb2\"\x13V\xcc\xad\x87\x93p\xb7\x99\xd8;eN\x99\x8aG\x90\xe3\x89l\x18,\x8b\xd7\xf1.\xf3%\x08[\x90\xea\x82\xdc\x03\x8d\x05rY\xf7\xd8\x1eV\xbbd>X:b=\xa8v)\xba:\x8bx\x08\xfd\xd8W\xf3\x8c\xfc\xf6\x1a\xd6\x90\x8a\x93\xed\x98Nt\x0frwh\xeb7\xfc\x7fH \x7f\xca`I'\x91\xde\x9c\x0f\x7f\xc4$\xffS*sm\x90D\xdd|i\xe7\x86\xac\x854\xbc\xc0\x1e\xef\x90i\xcf\xd3k\xb9\x95*\xac\xf3>P9\xac\xf5:<VmV\x84\xfd\x1eL\xccR+\x860\x84R\x8b\x8c2\xe4FW\xed}!\x1a[h#\xdf\xbb\xb1\xe9\x02\xe1*m\x01\xa1nn\xfe\x8c3\xac\x07\x02\xa7\x05U\xc4\x11*a\xd3\x822\xa4\x82i/\x15\x93bi\xe5\x85\xca\xdb\\\x00^\xa0\xc4A|\xaa\x04\xcfk&\x84e\xcc\x05Ex\x86\x8f\xc2X\x8a\xe1Z\xcd\xf7[\xeb8\xdf*\xf6\xf7\xee\x19y\xd9\xb2\x1b\xac\xc7R_\xc9\xb8\xa1\xf4\xa0\xb4\x9e\x96\xb9\xd6\x16\xc9[\x14\x9d\xcc\xa7\x83\xd9\xf3\x1b\xe0\x87\x04\xba\xa7\x02\xc1\x0e\xda|\xcd\xe7\xa7\x0dv>\xcb\xcbbmk\xb4N\xeft\xbd\x1a\x12\xe7\xd9\xc9c\x1bL\xf73\xa1\x0fbc2\x17\xca\x92>\x8b\x84\xde\xa8\xea\x1e\xe6%\xa9\xad\x99Nu\xb6\n\xe7\xdd\xdf\x95\xefL7\xafvk\xce\xb3\xca\xdd\x1b\xbc\xe3\xecL\xb7\xdd\xe2MW\xdfH\xae\xb5b\n\x87\x8f\xf1\x95\xdc\x90\xca6\x7f\x07\x00PK\x07\x08\xa5\x7fdU\xb6\x02\x00\x00g	\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00vNN]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x18\x00	\x00ext-authz-set-cookie.luaUT\x05\x00\x01\xb0P\xcfj\xb4VAo\xa3<\x10\xbd\xe7W\x8c\xf8.D\x1f\x89\xf6\x1c){\xdf\xc3\xfe\x82\xb6\x8b\\\x18\x88U\x18\xb3\xb6\x93m\xb5\xda\xfd\xed+\x83Im\x92\x82I(R\x15*f\x9e\xdf<{\xc6\xaf8R\xa6\xb9 @:\x89\xb7TP*\xf1\xe7\x11\x95\x8e\xedoz`\x94W\xb8^\x01\x00T\"c\x15\x1c\x90\xe5(\x15\xec\xc1\x8f\xd9\xd9\x0f\xb1\x1b\x9c\xbf\x11\xaby\x96\xd6\xa8\xd9e\x86\xd2\x12Y\xfd\x8d\n\x11\xafw6\xf4;j\x963\xcd,\x0c/\xfa\x05w%\xea8z\xdd4\xa2F\xc9\x8f\xf5F\xa1\xdedB\xbcp\x8c\xd6\xf0w\x0f\xc4+\xd0\x07\xa4vy\xf3\xb8\x8b\xef\x94\xc9n\xcb\xdc\x16\xbc\xd2(\xd5\xf6\xa0u\xb3\xad\x8e,J \xeaQS\x85:\xb5\xa8\xc9\x19\xe9\xe2	\xe1\xb4^\x0d\xa3%\xd6\xe2\x84\x1f&\xb4\xf1H\xf9T\xe19f\\qA\x1b\xcd\xebEk\xef\x81\xd3\x16\xf8\x86\xf2\x07\xcc\x82\x14\x18\xe4\x84\x8a\xa0P\x9eP\x1a	8\x95K\x8a\xd0\x01\xa7\x16\xf8\xa63\xe01\x0b<\x06^N\xb8\x08\xaa=\x08\xa5d\xd9\xc2]\xd0\x02\xa7\x1d\xf0M\"x\xcc\x02E\xf0r\xe6\x8a\x80\xaf\x0d\x97\xa86\x9c>C	\x8b\x9er\xbaG\x0e\x97\xe3,M\xdc\xc4PaX\xd3|\xc2\x80dMs\xc7\x80t9\x05	\xe0&x\x85w\x17\xcc/&\x89Si\xae\xa3\xdf\x7f\xda\xef\x85\x90\xf0\x82o	\x9cXuD\xe0\x04\x0d\xe3R\xc5\x96\xd1\x1arq^\x97\x17&\x14\xf6{p9Z\xcc\xc8\xbfM\xcc\xa3\xd9s\x85[N\n\xa5\x8e\xfb\xa5\xedJ\xef\xd5\xf4\x0c\xfb_^\xc0\x7f}0|\x85/\x0b\xdcR=\xdc\xd8Y\xec\xc8f\x822\xe6\x92\x8d\x1eC\xcf\x9e\xcd\x19tb\xa7{w\x02\xd2\x9a\x97\x92\xb5\x0ebA\xfd;\xec\xcd\x19{j#\x86\\\x826\xe4\xa2\x80e6f\x08\x1b\xbcA\xc3\xc49\x1bu!\xd8\xfb\x8e\x99\xbf\xd55\x9b\xa7\x1aA\n\xe3\xfee\xc2\xe8yAaN\xcfO	\xb0z\x1d\x8e~\xae`\xefO\xa6rd2\x9d]\xa2\xc9\xb3&\x90Qn\xfe}\xb8j\xea\x9e\xae^\x0d\xb6\xa2\x1d\xcb\xf38r\x8ce2\x02\xe4\xb7\xc5\x14\x05gl\xdeG\xc1\x05\x9aG\xe1\xe2l^'b\x1a\xb8\x0b5\xc3Si\xc9\xa9\xdc\x965\xd3\xd9!\x9e\x02L z\xf8\xf1HO\xffG\xde\x9c\x1d\x97\xb8{\x19\x9d\x9f\xa3u\xf5\x0e\xd2x\xb6)u%6\x15\xcb\xfc9\xe7;\xd0\xe1\x96\x0f\xd0\xe7I\xee\x9b\xc9qj\xf6\xec\xb9F0\x19\x87\x9b\xcb\xc5\xf5t\xf3e\xf2\xedY2\x8e~\x1b5\xebr\x8c\xc9\xba\x9d\x9fc\x95>\"\xe9\xae3\x8fi\x7f\x8f~\xc0\xcf\xf4\x8e\x0d\x99j\x9ew\xa4\xd0\xa6\xb9r5'\xfdj\xd7\xbb\x07)_\xfd\x1b\x00PK\x07\x08G\x04\xa5\xfc\xb0\x02\x00\x00\xe4\x0f\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\xabCN]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x11\x00	\x00ext-authz-tls.luaUT\x05\x00\x01b=\xcfj\x8c\x92\xb1\x8e\xdb0\x0c\x86w?\x05\xe1\xc9\x07$\xf7\x00\x012u*\xd0vI\xd1\xd5`m\xba! Q.I\xe7\x9a\x0e}\xf6B\xb6\xdc8A\x80^\x16\xc5\x94\xfe\x8f\xff/q\xbf\x87\x11\xcd\xc8\xc0\xcf\x04\xa3\xa6\x91\xd4\x99\x0c\xd20W\xfa\xf4&\xe6J\x18\xe1\xeb\xa7\x13tI\x84:\xe7$\xe0i>@\xbf\xbc\xc5\xc9\xcf\xbf\xab\xfd\x1e\x06\x0eN\xba\x034\xe8\xaf\x82\x91;\x88\xe4\xd8\xa3\xe3\x0e,\x0b\xd0A\xd3\xe4d\x10\xf1\nJ?'V\x02\x84\xc8\xc2q\x8ap!5N\x92aI\xc1H/\xa4 \x18\xa9\x1a&Y\x1a\x93\\\xd2\xb5M\xd2f5\x997em\xcf(}\xa0\x97\n\x00 \xa4\x0e\x03,\xce[\x96!\xc1\x11\xee\xcf\x1d\x96\xcd\x8f2\xa4f\xab)\xc6\xdbl\x1c\x8e[\xc4\xa1l}.\x91\x8a,[\x95p\x05\xbc \x07\xfc\x1e\x08X@\xe8\x8dtM3\xdf\xe6l{\xee\xc3\xc3\x96\xfaZlQ\x7f\x9a\xd3~\xc1H\xf0\xe7\x08\xc2!_\xb0\xcc\x92M$\xe1\x07WO\xf4\xc5\xd9\xdaLx\x05\xa2\xf4\xebg]\xdf\xe3\xf3o\x9b\xfd`\xe4M=\xa6H\xcaS|\xf5`\xf5\x0ej\x13\xaew\x19q\xeb@\xd2W\xdb\xf5!\xdem\x82N\x16>\xdc\xe6\xe7\xf8$\xa2\x92O*w\xb4\xf2\x90\x16\x1e\xdf\xe29\xb6$\xcf\x1e,\xbc\xb7\xc7\xffc\x97\x87\xcc\xd1-\x1c<\xd8\xb7\xa5\xd0\xbc\xbc\xbc\x93\xd0\xf1x&mmb\xa7\x82YJs\xe5\xe4\xca\xf2#\xd3\xf2m>\x9du\x1b\x93\x185\xeb\x9f\x7f\xd3N\xd2W\x7f\x07\x00PK\x07\x08O\xf5\x9f\x10\x86\x01\x00\x00\xc6\x03\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00LTN]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x1a\x00	\x00strip-reserved-headers.luaUT\x05\x00\x01\xb0Z\xcfjtT\xcb\x8e\x9b@\x10\xbc\xf3\x15\xa5\xcd!8\x02K\xb9Z\xf2_\xe4\x16Eh\x0c\xc522\xcc\x90\x9e\xc6\xb1w\xb5\xf9\xf6\x88\xc7\xb0f\xbd\x99\x0b\x0d\xddTUW7\xe49\x84\x9d\xbf0@\x1bB\xf8{`P44\x15%@\x18(\x17V\xa8\xbd\xa0\xf7\x1d\xc5\x0e]\x06\xee\x9f\xf7\xb8\xe6\xf1A^\xb6\xc6v\xf9\xb7,\xc9s\x9cX{\xe1\x06\xcd\x06\x98A\x1b/\xf6\x85U\x86\xe0\xa1\x8dQ\x94\xad\xa5\xd3\x80\xd2\xb8\xaf\x8a\xd0{_\x8f\xafu\xd0\xb1\x80#\xd8\xd0\x07\x15\x9an\x8f\x1f\x0d\xc1+\xbb~\x15\xb7\xc8\x18\x89\xfc\x85\"\xb6\"\xd4\x9f\xe92\x18!Z\xd6:\xc9^\xa0x\xd5bT\xf1\x82\xda\xb6J\xc9`\\\xb54_\xe1t\x1b\xebP\xb64.\x8f\xac\x08\xa5\xd8^\xf7I=\xb8R\xadwhL(zam\xafiP\xc90\xc7\xbb\x04\x00\x84:\x88CP\xc1\xdf#\x9cm'\x82\xa0r\x08\xc3)\xfd\x9e\xe1\xcbR\x8d\xe3qy1\xa1\xab\x92wt\xba\x8b\xbf\x15\xde\x15\x8bq\xe9r-\x1a\xe3\xaa\x963M\xebK\xd3F\x0fp\xc4\xb6\xe6\xb0$\xd2\xfb\xe2\x8ej*\xa3\xe6\xb1:f6\xe5\xb36\x8e\xe01\x7fx\xa6\xa6OA\xc5\xf6E\xdc\x89b\xa6Z\xfc`x\x9a1l}\x070\xfb\xa0\x0d\xdd\x94{\xb7i\xba\x1d\xbb\x7fW\xb9L\xf7\x88\xd7\xb7\xe9\xe98\xbb\"\xc3\x997X\x07\xdb\x1b+!\xdd\n\xfa(e\x86\xb0\xde\x85\xa7\x1d\xbc\xe0\xf5m\x87\xca\xaf\xd4s\xfa\xe7\x99\xb7_8Be\xe0*c\n\xf2|\xf5u\xde\xc9\x13\xd7\x05\xf9\xd3\xd8\x96\xb0J1j\xdd\xf3\xb4rcc\xdd]\x07Q\xce\xb6\x873o\x19\x8a\xb1\x87\xb9\x85\x85b\xa3\xcc\xd6p^7\x027\xa6\xdd\xf91\x9b{gIt{\x03\x18\x8f\xad\xefwv\x92\x12\xb7\xf0\x81 \x1e5\xa7\x96{\xeb\x02e\\\xc1\xf9\x0f0\x0db\x97|(\x9d\xceIh\xce\x0f\x998\xdc\xcf\xeec\x1c\xaf\x9f\x8e:2o\xfaZ\xcc;\xcccIWM#\xd2\x7f?\xa6\xd0{\x17\x98\xc6`\xfd\x9c\xe8\xaa\xe4\xdf\x00PK\x07\x08\xf4\xa3\xb4\xde\n\x02\x00\x00\x01\x05\x00\x00PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00LTN]\xa5\x7fdU\xb6\x02\x00\x00g	\x00\x00\x12\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\x00\x00\x00\x00clean-upstream.luaUT\x05\x00\x01\xb0Z\xcfjPK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00vNN]G\x04\xa5\xfc\xb0\x02\x00\x00\xe4\x0f\x00\x00\x18\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\xff\x02\x00\x00ext-authz-set-cookie.luaUT\x05\x00\x01\xb0P\xcfjPK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xabCN]O\xf5\x9f\x10\x86\x01\x00\x00\xc6\x03\x00\x00\x11\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xa4\x81\xfe\x05\x00\x00ext-authz-tls.luaUT\x05\x00\x01b=\xcfjPK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00LTN]\xf4\xa3\xb4\xde\n\x02\x00\x00\x01\x05\x00\x00\x1a\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xa4\x81\xcc\x07\x00\x00strip-reserved-headers.luaUT\x05\x00\x01\xb0Z\xcfjPK\x05\x06\x00\x00\x00\x00\x04\x00\x04\x001\x01\x00\x00'\n\x00\x00\x00\x00"
 	fs.RegisterWithNamespace("luascripts", data)
 }
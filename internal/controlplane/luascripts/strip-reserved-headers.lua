@@ -0,0 +1,39 @@
+-- removes the request headers reserved for pomerium, e.g. x-pomerium-claim-*,
+-- before the request is authorized, so that clients can't spoof them to the
+-- upstream. The exempt headers, e.g. the override token, are left for the
+-- ext_authz filter, and removed by the clean-upstream script.
+function has_prefix(str, prefix)
+    return str ~= nil and str:sub(1, #prefix) == prefix
+end
+
+function envoy_on_request(request_handle)
+    local headers = request_handle:headers()
+    local metadata = request_handle:metadata()
+    local prefixes = metadata:get("strip_reserved_header_prefixes")
+    if prefixes == nil then
+        return
+    end
+    local exempt = {}
+    for _, key in ipairs(metadata:get("reserved_header_exemptions") or {}) do
+        exempt[key] = true
+    end
+
+    -- headers can't be removed while iterating over them
+    local reserved = {}
+    for key, _ in pairs(headers) do
+        if not exempt[key] then
+            for _, prefix in ipairs(prefixes) do
+                if has_prefix(key, prefix) then
+                    table.insert(reserved, key)
+                    break
+                end
+            end
+        end
+    end
+    for _, key in ipairs(reserved) do
+        headers:remove(key)
+    end
+end
+
+function envoy_on_response(response_handle)
+end
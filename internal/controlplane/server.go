@@ -10,7 +10,8 @@ import (
 	"github.com/gorilla/mux"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/log"
@@ -39,6 +40,7 @@ func (avo *atomicVersionedOptions) Store(options versionedOptions) {
 type Server struct {
 	GRPCListener net.Listener
 	GRPCServer   *grpc.Server
+	HealthServer *health.Server
 	HTTPListener net.Listener
 	HTTPRouter   *mux.Router
 
@@ -65,9 +67,10 @@ func NewServer() (*Server, error) {
 		grpc.UnaryInterceptor(requestid.UnaryServerInterceptor()),
 		grpc.StreamInterceptor(requestid.StreamServerInterceptor()),
 	)
-	reflection.Register(srv.GRPCServer)
 	srv.registerXDSHandlers()
 	srv.registerAccessLogHandlers()
+	srv.HealthServer = health.NewServer()
+	healthpb.RegisterHealthServer(srv.GRPCServer, srv.HealthServer)
 
 	// setup HTTP
 	srv.HTTPListener, err = net.Listen("tcp4", "127.0.0.1:0")
@@ -172,6 +172,16 @@ func buildMainHTTPConnectionManagerFilter(options *config.Options, domains []str
 			},
 		},
 		IncludePeerCertificate: true,
+		// the downstream TLS properties, set by the ext-authz-tls lua script
+		MetadataContextNamespaces: []string{"pomerium.tls"},
+	})
+
+	stripReservedLua, _ := ptypes.MarshalAny(&envoy_extensions_filters_http_lua_v3.Lua{
+		InlineCode: luascripts.StripReserved,
+	})
+
+	extAuthzTLSLua, _ := ptypes.MarshalAny(&envoy_extensions_filters_http_lua_v3.Lua{
+		InlineCode: luascripts.ExtAuthzTLS,
 	})
 
 	extAuthzSetCookieLua, _ := ptypes.MarshalAny(&envoy_extensions_filters_http_lua_v3.Lua{
@@ -193,6 +203,18 @@ func buildMainHTTPConnectionManagerFilter(options *config.Options, domains []str
 			RouteConfig: buildRouteConfiguration("main", virtualHosts),
 		},
 		HttpFilters: []*envoy_http_connection_manager.HttpFilter{
+			{
+				Name: "envoy.filters.http.lua",
+				ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+					TypedConfig: stripReservedLua,
+				},
+			},
+			{
+				Name: "envoy.filters.http.lua",
+				ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
+					TypedConfig: extAuthzTLSLua,
+				},
+			},
 			{
 				Name: "envoy.filters.http.ext_authz",
 				ConfigType: &envoy_http_connection_manager.HttpFilter_TypedConfig{
@@ -221,6 +243,8 @@ func buildMainHTTPConnectionManagerFilter(options *config.Options, domains []str
 			MaxStreamDuration: maxStreamDuration,
 		},
 		RequestTimeout: ptypes.DurationProto(options.ReadTimeout),
+		// wait for the upstream, and so authorization, before continuing
+		Proxy_100Continue: options.Proxy100Continue,
 		Tracing: &envoy_http_connection_manager.HttpConnectionManager_Tracing{
 			RandomSampling: &envoy_type_v3.Percent{Value: options.TracingSampleRate * 100},
 		},
@@ -44,6 +44,20 @@ func Test_buildMainHTTPConnectionManagerFilter(t *testing.T) {
 				"idleTimeout": "300s"
 			},
 			"httpFilters": [
+				{
+					"name": "envoy.filters.http.lua",
+					"typedConfig": {
+						"@type": "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
+						"inlineCode": "-- removes the request headers reserved for pomerium, e.g. x-pomerium-claim-*,\n-- before the request is authorized, so that clients can't spoof them to the\n-- upstream. The exempt headers, e.g. the override token, are left for the\n-- ext_authz filter, and removed by the clean-upstream script.\nfunction has_prefix(str, prefix)\n    return str ~= nil and str:sub(1, #prefix) == prefix\nend\n\nfunction envoy_on_request(request_handle)\n    local headers = request_handle:headers()\n    local metadata = request_handle:metadata()\n    local prefixes = metadata:get(\"strip_reserved_header_prefixes\")\n    if prefixes == nil then\n        return\n    end\n    local exempt = {}\n    for _, key in ipairs(metadata:get(\"reserved_header_exemptions\") or {}) do\n        exempt[key] = true\n    end\n\n    -- headers can't be removed while iterating over them\n    local reserved = {}\n    for key, _ in pairs(headers) do\n        if not exempt[key] then\n            for _, prefix in ipairs(prefixes) do\n                if has_prefix(key, prefix) then\n                    table.insert(reserved, key)\n                    break\n                end\n            end\n        end\n    end\n    for _, key in ipairs(reserved) do\n        headers:remove(key)\n    end\nend\n\nfunction envoy_on_response(response_handle)\nend\n"
+					}
+				},
+				{
+					"name": "envoy.filters.http.lua",
+					"typedConfig": {
+						"@type": "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
+						"inlineCode": "-- passes the properties of the downstream TLS connection to the ext_authz\n-- filter, as dynamic metadata, so that routes may require a minimum version\n-- or server name\nfunction envoy_on_request(request_handle)\n    local stream_info = request_handle:streamInfo()\n    local dynamic_meta = stream_info:dynamicMetadata()\n    -- only available in newer versions of envoy\n    if stream_info.requestedServerName ~= nil then\n        local sni = stream_info:requestedServerName()\n        if sni ~= nil and sni ~= \"\" then\n            dynamic_meta:set(\"pomerium.tls\", \"sni\", sni)\n        end\n    end\n    if stream_info.downstreamSslConnection == nil then\n        return\n    end\n    local ssl = stream_info:downstreamSslConnection()\n    if ssl == nil then\n        return\n    end\n    dynamic_meta:set(\"pomerium.tls\", \"version\", ssl:tlsVersion())\n    dynamic_meta:set(\"pomerium.tls\", \"cipher_suite\", ssl:ciphersuiteString())\nend\n\nfunction envoy_on_response(response_handle)\nend\n"
+					}
+				},
 				{
 					"name": "envoy.filters.http.ext_authz",
 					"typedConfig": {
@@ -55,6 +69,7 @@ func Test_buildMainHTTPConnectionManagerFilter(t *testing.T) {
 							"timeout": "10s"
 						},
 						"includePeerCertificate": true,
+						"metadataContextNamespaces": ["pomerium.tls"],
 						"statusOnError": {
 							"code": "InternalServerError"
 						}
@@ -64,20 +79,21 @@ func Test_buildMainHTTPConnectionManagerFilter(t *testing.T) {
 					"name": "envoy.filters.http.lua",
 					"typedConfig": {
 						"@type": "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
-						"inlineCode": "function envoy_on_request(request_handle)\n    local headers = request_handle:headers()\n    local dynamic_meta = request_handle:streamInfo():dynamicMetadata()\n    if headers:get(\"x-pomerium-set-cookie\") ~= nil then\n        dynamic_meta:set(\"envoy.filters.http.lua\", \"pomerium_set_cookie\",\n                         headers:get(\"x-pomerium-set-cookie\"))\n        headers:remove(\"x-pomerium-set-cookie\")\n    end\nend\n\nfunction envoy_on_response(response_handle)\n    local headers = response_handle:headers()\n    local dynamic_meta = response_handle:streamInfo():dynamicMetadata()\n    local tbl = dynamic_meta:get(\"envoy.filters.http.lua\")\n    if tbl ~= nil and tbl[\"pomerium_set_cookie\"] ~= nil then\n        headers:add(\"set-cookie\", tbl[\"pomerium_set_cookie\"])\n    end\nend\n"
+						"inlineCode": "function envoy_on_request(request_handle)\n    local headers = request_handle:headers()\n    local dynamic_meta = request_handle:streamInfo():dynamicMetadata()\n    if headers:get(\"x-pomerium-set-cookie\") ~= nil then\n        dynamic_meta:set(\"envoy.filters.http.lua\", \"pomerium_set_cookie\",\n                         headers:get(\"x-pomerium-set-cookie\"))\n        headers:remove(\"x-pomerium-set-cookie\")\n    end\n    if headers:get(\"x-pomerium-decision-time\") ~= nil then\n        dynamic_meta:set(\"envoy.filters.http.lua\", \"pomerium_decision_time\",\n                         headers:get(\"x-pomerium-decision-time\"))\n        headers:remove(\"x-pomerium-decision-time\")\n    end\n    if headers:get(\"x-pomerium-server-timing\") ~= nil then\n        dynamic_meta:set(\"envoy.filters.http.lua\", \"pomerium_server_timing\",\n                         headers:get(\"x-pomerium-server-timing\"))\n        headers:remove(\"x-pomerium-server-timing\")\n    end\n    if headers:get(\"x-pomerium-session-grace\") ~= nil then\n        dynamic_meta:set(\"envoy.filters.http.lua\", \"pomerium_session_grace\",\n                         headers:get(\"x-pomerium-session-grace\"))\n        headers:remove(\"x-pomerium-session-grace\")\n    end\n    if headers:get(\"x-pomerium-session-expires-in\") ~= nil then\n        dynamic_meta:set(\"envoy.filters.http.lua\", \"pomerium_session_expires_in\",\n                         headers:get(\"x-pomerium-session-expires-in\"))\n        headers:remove(\"x-pomerium-session-expires-in\")\n    end\n    if headers:get(\"x-pomerium-app-cookie\") ~= nil then\n        dynamic_meta:set(\"envoy.filters.http.lua\", \"pomerium_app_cookie\",\n                         headers:get(\"x-pomerium-app-cookie\"))\n        headers:remove(\"x-pomerium-app-cookie\")\n    end\n    local warnings = {}\n    for key, value in pairs(headers) do\n        if key == \"x-pomerium-warning\" then\n            table.insert(warnings, value)\n        end\n    end\n    if #warnings > 0 then\n        dynamic_meta:set(\"envoy.filters.http.lua\", \"pomerium_warnings\",\n                         table.concat(warnings, \"\\n\"))\n        headers:remove(\"x-pomerium-warning\")\n    end\n    local cookie_migration = {}\n    for key, value in pairs(headers) do\n        if key == \"x-pomerium-cookie-migration\" then\n            table.insert(cookie_migration, value)\n        end\n    end\n    if #cookie_migration > 0 then\n        dynamic_meta:set(\"envoy.filters.http.lua\", \"pomerium_cookie_migration\",\n                         table.concat(cookie_migration, \"\\n\"))\n        headers:remove(\"x-pomerium-cookie-migration\")\n    end\nend\n\nfunction envoy_on_response(response_handle)\n    local headers = response_handle:headers()\n    local dynamic_meta = response_handle:streamInfo():dynamicMetadata()\n    local tbl = dynamic_meta:get(\"envoy.filters.http.lua\")\n    if tbl ~= nil and tbl[\"pomerium_set_cookie\"] ~= nil then\n        headers:add(\"set-cookie\", tbl[\"pomerium_set_cookie\"])\n    end\n    if tbl ~= nil and tbl[\"pomerium_app_cookie\"] ~= nil then\n        headers:add(\"set-cookie\", tbl[\"pomerium_app_cookie\"])\n    end\n    if tbl ~= nil and tbl[\"pomerium_cookie_migration\"] ~= nil then\n        for cookie in string.gmatch(tbl[\"pomerium_cookie_migration\"], \"[^\\n]+\") do\n            headers:add(\"set-cookie\", cookie)\n        end\n    end\n    if tbl ~= nil and tbl[\"pomerium_decision_time\"] ~= nil then\n        headers:replace(\"x-pomerium-decision-time\", tbl[\"pomerium_decision_time\"])\n    end\n    if tbl ~= nil and tbl[\"pomerium_server_timing\"] ~= nil then\n        headers:add(\"server-timing\", tbl[\"pomerium_server_timing\"])\n    end\n    if tbl ~= nil and tbl[\"pomerium_session_grace\"] ~= nil then\n        headers:replace(\"x-pomerium-session-grace\", tbl[\"pomerium_session_grace\"])\n    end\n    if tbl ~= nil and tbl[\"pomerium_session_expires_in\"] ~= nil then\n        headers:replace(\"x-pomerium-session-expires-in\", tbl[\"pomerium_session_expires_in\"])\n    end\n    if tbl ~= nil and tbl[\"pomerium_warnings\"] ~= nil then\n        for warning in string.gmatch(tbl[\"pomerium_warnings\"], \"[^\\n]+\") do\n            headers:add(\"x-pomerium-warning\", warning)\n        end\n    end\nend\n"
 					}
 				},
 				{
 					"name": "envoy.filters.http.lua",
 					"typedConfig": {
 						"@type": "type.googleapis.com/envoy.extensions.filters.http.lua.v3.Lua",
-						"inlineCode": "function remove_pomerium_cookie(cookie_name, cookie)\n    -- lua doesn't support optional capture groups\n    -- so we replace twice to handle pomerium=xyz at the end of the string\n    cookie = cookie:gsub(cookie_name .. \"=[^;]+; \", \"\")\n    cookie = cookie:gsub(cookie_name .. \"=[^;]+\", \"\")\n    return cookie\nend\n\nfunction has_prefix(str, prefix)\n    return str ~= nil and str:sub(1, #prefix) == prefix\nend\n\nfunction envoy_on_request(request_handle)\n    local headers = request_handle:headers()\n    local metadata = request_handle:metadata()\n\n    local remove_cookie_name = metadata:get(\"remove_pomerium_cookie\")\n    if remove_cookie_name then\n        local cookie = headers:get(\"cookie\")\n        if cookie ~= nil then\n            newcookie = remove_pomerium_cookie(remove_cookie_name, cookie)\n            headers:replace(\"cookie\", newcookie)\n        end\n    end\n\n    local remove_authorization = metadata:get(\"remove_pomerium_authorization\")\n    if remove_authorization then\n        local authorization = headers:get(\"authorization\")\n        local authorization_prefix = \"Pomerium \"\n        if has_prefix(authorization, authorization_prefix) then\n            headers:remove(\"authorization\")\n        end\n    end\nend\n\nfunction envoy_on_response(response_handle)\n\nend\n"
+						"inlineCode": "function remove_pomerium_cookie(cookie_name, cookie)\n    -- escape the name's punctuation, e.g. \"-\", which is magic in patterns\n    cookie_name = cookie_name:gsub(\"%p\", \"%%%0\")\n    -- lua doesn't support optional capture groups\n    -- so we replace twice to handle pomerium=xyz at the end of the string\n    cookie = cookie:gsub(cookie_name .. \"=[^;]+; \", \"\")\n    cookie = cookie:gsub(cookie_name .. \"=[^;]+\", \"\")\n    return cookie\nend\n\nfunction has_prefix(str, prefix)\n    return str ~= nil and str:sub(1, #prefix) == prefix\nend\n\nfunction envoy_on_request(request_handle)\n    local headers = request_handle:headers()\n    local metadata = request_handle:metadata()\n\n    local remove_cookie_name = metadata:get(\"remove_pomerium_cookie\")\n    if remove_cookie_name then\n        local cookie = headers:get(\"cookie\")\n        if cookie ~= nil then\n            newcookie = remove_pomerium_cookie(remove_cookie_name, cookie)\n            headers:replace(\"cookie\", newcookie)\n        end\n    end\n\n    local remove_cookie_names = metadata:get(\"remove_pomerium_legacy_cookies\")\n    if remove_cookie_names then\n        local cookie = headers:get(\"cookie\")\n        if cookie ~= nil then\n            for _, name in ipairs(remove_cookie_names) do\n                cookie = remove_pomerium_cookie(name, cookie)\n            end\n            headers:replace(\"cookie\", cookie)\n        end\n    end\n\n    -- sessions are loaded from the authorization header with any of the\n    -- session schemes, matched case-insensitively\n    local remove_authorization_schemes = metadata:get(\"remove_pomerium_authorization\")\n    if remove_authorization_schemes then\n        local authorization = headers:get(\"authorization\")\n        if authorization ~= nil then\n            authorization = authorization:gsub(\"^%s+\", \"\"):lower()\n            for _, scheme in ipairs(remove_authorization_schemes) do\n                if has_prefix(authorization, scheme:lower() .. \" \") or has_prefix(authorization, scheme:lower() .. \"\\t\") then\n                    headers:remove(\"authorization\")\n                    break\n                end\n            end\n        end\n    end\n\n    local reserved_header_exemptions = metadata:get(\"reserved_header_exemptions\")\n    if reserved_header_exemptions then\n        for _, key in ipairs(reserved_header_exemptions) do\n            headers:remove(key)\n        end\n    end\nend\n\nfunction envoy_on_response(response_handle)\n\nend\n"
 					}
 				},
 				{
 					"name": "envoy.filters.http.router"
 				}
 			],
+			"proxy100Continue": true,
 			"requestTimeout": "30s",
 			"routeConfig": {
 				"name": "main",
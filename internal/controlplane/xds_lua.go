@@ -14,7 +14,9 @@ import (
 
 var luascripts struct {
 	ExtAuthzSetCookie string
+	ExtAuthzTLS       string
 	CleanUpstream     string
+	StripReserved     string
 }
 
 func init() {
@@ -24,8 +26,10 @@ func init() {
 	}
 
 	fileToField := map[string]*string{
-		"/clean-upstream.lua":       &luascripts.CleanUpstream,
-		"/ext-authz-set-cookie.lua": &luascripts.ExtAuthzSetCookie,
+		"/clean-upstream.lua":         &luascripts.CleanUpstream,
+		"/ext-authz-set-cookie.lua":   &luascripts.ExtAuthzSetCookie,
+		"/ext-authz-tls.lua":          &luascripts.ExtAuthzTLS,
+		"/strip-reserved-headers.lua": &luascripts.StripReserved,
 	}
 
 	err = fs.Walk(hfs, "/", func(p string, fi os.FileInfo, err error) error {
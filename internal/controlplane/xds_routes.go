@@ -13,6 +13,7 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 
 	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
 )
 
 func buildGRPCRoutes() []*envoy_config_route_v3.Route {
@@ -43,10 +44,20 @@ func buildPomeriumHTTPRoutes(options *config.Options, domain string) []*envoy_co
 		buildControlPlanePathRoute("/ping"),
 		buildControlPlanePathRoute("/healthz"),
 		buildControlPlanePathRoute("/.pomerium"),
+	}
+	// the policy debug and replay endpoints are answered by authorize, so
+	// unlike the control plane's endpoints, their requests are checked
+	if options.DebugPolicy {
+		routes = append(routes,
+			buildControlPlaneAuthorizedPathRoute("/.pomerium/admin/debug/policy"),
+			buildControlPlaneAuthorizedPathRoute("/.pomerium/admin/debug/replay"),
+		)
+	}
+	routes = append(routes,
 		buildControlPlanePrefixRoute("/.pomerium/"),
 		buildControlPlanePathRoute("/.well-known/pomerium"),
 		buildControlPlanePrefixRoute("/.well-known/pomerium/"),
-	}
+	)
 	// if we're handling authentication, add the oauth2 callback url
 	if config.IsAuthenticate(options.Services) && domain == options.GetAuthenticateURL().Host {
 		routes = append(routes, buildControlPlanePathRoute(options.AuthenticateCallbackPath))
@@ -77,6 +88,24 @@ func buildControlPlanePathRoute(path string) *envoy_config_route_v3.Route {
 	}
 }
 
+// buildControlPlaneAuthorizedPathRoute returns a route for a path answered by
+// authorize, with ext_authz enabled, rather than by the control plane.
+func buildControlPlaneAuthorizedPathRoute(path string) *envoy_config_route_v3.Route {
+	return &envoy_config_route_v3.Route{
+		Name: "pomerium-authorized-path-" + path,
+		Match: &envoy_config_route_v3.RouteMatch{
+			PathSpecifier: &envoy_config_route_v3.RouteMatch_Path{Path: path},
+		},
+		Action: &envoy_config_route_v3.Route_Route{
+			Route: &envoy_config_route_v3.RouteAction{
+				ClusterSpecifier: &envoy_config_route_v3.RouteAction_Cluster{
+					Cluster: "pomerium-control-plane-http",
+				},
+			},
+		},
+	}
+}
+
 func buildControlPlanePrefixRoute(prefix string) *envoy_config_route_v3.Route {
 	return &envoy_config_route_v3.Route{
 		Name: "pomerium-prefix-" + prefix,
@@ -146,25 +175,54 @@ func buildPolicyRoutes(options *config.Options, domain string) []*envoy_config_r
 			}
 		}
 
+		luaMetadata := &structpb.Struct{
+			Fields: map[string]*structpb.Value{
+				"remove_pomerium_cookie": {
+					Kind: &structpb.Value_StringValue{
+						StringValue: options.CookieName,
+					},
+				},
+				"remove_pomerium_authorization": {
+					Kind: &structpb.Value_ListValue{
+						ListValue: getStringListValue(options.GetSessionAuthorizationSchemes()),
+					},
+				},
+				"strip_reserved_header_prefixes": {
+					Kind: &structpb.Value_ListValue{
+						ListValue: getStringListValue(options.GetReservedHeaderPrefixes()),
+					},
+				},
+				// these reserved headers are read by the ext_authz filter, so
+				// they're only removed once the request is authorized
+				"reserved_header_exemptions": {
+					Kind: &structpb.Value_ListValue{
+						ListValue: getStringListValue([]string{
+							httputil.HeaderPomeriumOverrideToken,
+							httputil.HeaderPomeriumInnerIdentity,
+						}),
+					},
+				},
+			},
+		}
+		legacyCookieNames := options.CookieLegacyNames
+		if options.CookieMigrateDomain {
+			// the cookie domain migration marker is pomerium's too
+			legacyCookieNames = append(legacyCookieNames[:len(legacyCookieNames):len(legacyCookieNames)], options.CookieName+"_domain")
+		}
+		if len(legacyCookieNames) > 0 {
+			// sessions are still accepted from the legacy cookies, so they're
+			// removed too
+			luaMetadata.Fields["remove_pomerium_legacy_cookies"] = &structpb.Value{
+				Kind: &structpb.Value_ListValue{ListValue: getStringListValue(legacyCookieNames)},
+			}
+		}
+
 		routes = append(routes, &envoy_config_route_v3.Route{
 			Name:  fmt.Sprintf("policy-%d", i),
 			Match: match,
 			Metadata: &envoy_config_core_v3.Metadata{
 				FilterMetadata: map[string]*structpb.Struct{
-					"envoy.filters.http.lua": {
-						Fields: map[string]*structpb.Value{
-							"remove_pomerium_cookie": {
-								Kind: &structpb.Value_StringValue{
-									StringValue: options.CookieName,
-								},
-							},
-							"remove_pomerium_authorization": {
-								Kind: &structpb.Value_BoolValue{
-									BoolValue: true,
-								},
-							},
-						},
-					},
+					"envoy.filters.http.lua": luaMetadata,
 				},
 			},
 			Action: &envoy_config_route_v3.Route_Route{
@@ -182,8 +240,21 @@ func buildPolicyRoutes(options *config.Options, domain string) []*envoy_config_r
 					Timeout: routeTimeout,
 				},
 			},
-			RequestHeadersToAdd: requestHeadersToAdd,
+			RequestHeadersToAdd:    requestHeadersToAdd,
+			RequestHeadersToRemove: policy.GetRemovedRequestHeaders(),
 		})
 	}
 	return routes
 }
+
+// getStringListValue returns the strings as a list value, e.g. for lua
+// metadata.
+func getStringListValue(values []string) *structpb.ListValue {
+	lv := &structpb.ListValue{}
+	for _, v := range values {
+		lv.Values = append(lv.Values, &structpb.Value{
+			Kind: &structpb.Value_StringValue{StringValue: v},
+		})
+	}
+	return lv
+}
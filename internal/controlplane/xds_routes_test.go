@@ -5,7 +5,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/pomerium/pomerium/config"
+	"github.com/pomerium/pomerium/internal/httputil"
 	"github.com/pomerium/pomerium/internal/testutil"
 )
 
@@ -152,6 +155,52 @@ func Test_buildPomeriumHTTPRoutes(t *testing.T) {
 	`, routes)
 }
 
+func Test_buildPomeriumHTTPRoutes_debugPolicy(t *testing.T) {
+	routes := buildPomeriumHTTPRoutes(&config.Options{
+		Services:    "proxy",
+		DebugPolicy: true,
+	}, "app.example.com")
+
+	var names []string
+	for _, route := range routes {
+		names = append(names, route.GetName())
+	}
+	// the debug endpoints are matched before the control plane's /.pomerium/
+	// prefix, and checked by authorize
+	assert.Equal(t, []string{
+		"pomerium-path-/ping",
+		"pomerium-path-/healthz",
+		"pomerium-path-/.pomerium",
+		"pomerium-authorized-path-/.pomerium/admin/debug/policy",
+		"pomerium-authorized-path-/.pomerium/admin/debug/replay",
+		"pomerium-prefix-/.pomerium/",
+		"pomerium-path-/.well-known/pomerium",
+		"pomerium-prefix-/.well-known/pomerium/",
+	}, names)
+	testutil.AssertProtoJSONEqual(t, `
+		{
+			"name": "pomerium-authorized-path-/.pomerium/admin/debug/policy",
+			"match": {
+				"path": "/.pomerium/admin/debug/policy"
+			},
+			"route": {
+				"cluster": "pomerium-control-plane-http"
+			}
+		}
+	`, routes[3])
+	testutil.AssertProtoJSONEqual(t, `
+		{
+			"name": "pomerium-authorized-path-/.pomerium/admin/debug/replay",
+			"match": {
+				"path": "/.pomerium/admin/debug/replay"
+			},
+			"route": {
+				"cluster": "pomerium-control-plane-http"
+			}
+		}
+	`, routes[4])
+}
+
 func Test_buildControlPlanePathRoute(t *testing.T) {
 	route := buildControlPlanePathRoute("/hello/world")
 	testutil.AssertProtoJSONEqual(t, `
@@ -198,6 +247,7 @@ func Test_buildPolicyRoutes(t *testing.T) {
 	routes := buildPolicyRoutes(&config.Options{
 		CookieName:             "pomerium",
 		DefaultUpstreamTimeout: time.Second * 3,
+		ReservedHeaderPrefix:   "x-pomerium-",
 		Policies: []config.Policy{
 			{
 				Source: &config.StringURL{URL: mustParseURL("https://ignore.example.com")},
@@ -233,14 +283,16 @@ func Test_buildPolicyRoutes(t *testing.T) {
 				"metadata": {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
-							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_authorization": ["Pomerium"],
+							"remove_pomerium_cookie": "pomerium",
+							"reserved_header_exemptions": ["x-pomerium-override-token", "x-pomerium-inner-identity"],
+							"strip_reserved_header_prefixes": ["x-pomerium-"]
 						}
 					}
 				},
 				"route": {
 					"autoHostRewrite": true,
-					"cluster": "policy-d00072a199d7b614",
+					"cluster": "policy-d9eb948bada4fcb",
 					"timeout": "3s",
 					"upgradeConfigs": [{
 						"enabled": false,
@@ -256,14 +308,16 @@ func Test_buildPolicyRoutes(t *testing.T) {
 				"metadata": {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
-							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_authorization": ["Pomerium"],
+							"remove_pomerium_cookie": "pomerium",
+							"reserved_header_exemptions": ["x-pomerium-override-token", "x-pomerium-inner-identity"],
+							"strip_reserved_header_prefixes": ["x-pomerium-"]
 						}
 					}
 				},
 				"route": {
 					"autoHostRewrite": false,
-					"cluster": "policy-907a31075a413547",
+					"cluster": "policy-4de4faee794ccc98",
 					"timeout": "0s",
 					"upgradeConfigs": [{
 						"enabled": true,
@@ -279,14 +333,16 @@ func Test_buildPolicyRoutes(t *testing.T) {
 				"metadata": {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
-							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_authorization": ["Pomerium"],
+							"remove_pomerium_cookie": "pomerium",
+							"reserved_header_exemptions": ["x-pomerium-override-token", "x-pomerium-inner-identity"],
+							"strip_reserved_header_prefixes": ["x-pomerium-"]
 						}
 					}
 				},
 				"route": {
 					"autoHostRewrite": true,
-					"cluster": "policy-f05528f790686bc3",
+					"cluster": "policy-2dcbe31eb365921c",
 					"timeout": "60s",
 					"upgradeConfigs": [{
 						"enabled": false,
@@ -312,14 +368,16 @@ func Test_buildPolicyRoutes(t *testing.T) {
 				"metadata": {
 					"filterMetadata": {
 						"envoy.filters.http.lua": {
-							"remove_pomerium_authorization": true,
-							"remove_pomerium_cookie": "pomerium"
+							"remove_pomerium_authorization": ["Pomerium"],
+							"remove_pomerium_cookie": "pomerium",
+							"reserved_header_exemptions": ["x-pomerium-override-token", "x-pomerium-inner-identity"],
+							"strip_reserved_header_prefixes": ["x-pomerium-"]
 						}
 					}
 				},
 				"route": {
 					"autoHostRewrite": true,
-					"cluster": "policy-e5d3a05ff1f97659",
+					"cluster": "policy-384d6bb6d2f48f86",
 					"timeout": "3s",
 					"upgradeConfigs": [{
 						"enabled": false,
@@ -331,6 +389,101 @@ func Test_buildPolicyRoutes(t *testing.T) {
 	`, routes)
 }
 
+func Test_buildPolicyRoutes_headerTransforms(t *testing.T) {
+	routes := buildPolicyRoutes(&config.Options{
+		Policies: []config.Policy{{
+			Source: &config.StringURL{URL: mustParseURL("https://example.com")},
+			HeaderTransforms: []config.HeaderTransform{
+				{Remove: "x-forwarded-user"},
+				{Remove: "Cookie"},
+				{Add: "cookie", Value: "a=b"},
+			},
+		}},
+	}, "example.com")
+	if assert.Len(t, routes, 1) {
+		assert.Equal(t, []string{"X-Forwarded-User"}, routes[0].GetRequestHeadersToRemove())
+	}
+}
+
+func Test_buildPolicyRoutes_cookieLegacyNames(t *testing.T) {
+	routes := buildPolicyRoutes(&config.Options{
+		CookieName:        "pomerium",
+		CookieLegacyNames: []string{"_old", "_older"},
+		Policies: []config.Policy{{
+			Source: &config.StringURL{URL: mustParseURL("https://example.com")},
+		}},
+	}, "example.com")
+	if assert.Len(t, routes, 1) {
+		fields := routes[0].GetMetadata().GetFilterMetadata()["envoy.filters.http.lua"].GetFields()
+		assert.Equal(t, "pomerium", fields["remove_pomerium_cookie"].GetStringValue())
+		var legacyCookies []string
+		for _, v := range fields["remove_pomerium_legacy_cookies"].GetListValue().GetValues() {
+			legacyCookies = append(legacyCookies, v.GetStringValue())
+		}
+		assert.Equal(t, []string{"_old", "_older"}, legacyCookies)
+	}
+}
+
+func Test_buildPolicyRoutes_cookieMigrateDomain(t *testing.T) {
+	routes := buildPolicyRoutes(&config.Options{
+		CookieName:          "pomerium",
+		CookieLegacyNames:   []string{"_old"},
+		CookieMigrateDomain: true,
+		Policies: []config.Policy{{
+			Source: &config.StringURL{URL: mustParseURL("https://example.com")},
+		}},
+	}, "example.com")
+	if assert.Len(t, routes, 1) {
+		fields := routes[0].GetMetadata().GetFilterMetadata()["envoy.filters.http.lua"].GetFields()
+		var legacyCookies []string
+		for _, v := range fields["remove_pomerium_legacy_cookies"].GetListValue().GetValues() {
+			legacyCookies = append(legacyCookies, v.GetStringValue())
+		}
+		assert.Equal(t, []string{"_old", "pomerium_domain"}, legacyCookies)
+	}
+}
+
+func Test_buildPolicyRoutes_sessionAuthorizationSchemes(t *testing.T) {
+	routes := buildPolicyRoutes(&config.Options{
+		CookieName:                  "pomerium",
+		SessionAuthorizationSchemes: []string{"Pomerium", "bearer"},
+		Policies: []config.Policy{{
+			Source: &config.StringURL{URL: mustParseURL("https://example.com")},
+		}},
+	}, "example.com")
+	if assert.Len(t, routes, 1) {
+		fields := routes[0].GetMetadata().GetFilterMetadata()["envoy.filters.http.lua"].GetFields()
+		var schemes []string
+		for _, v := range fields["remove_pomerium_authorization"].GetListValue().GetValues() {
+			schemes = append(schemes, v.GetStringValue())
+		}
+		assert.Equal(t, []string{"Pomerium", "bearer"}, schemes)
+	}
+}
+
+func Test_buildPolicyRoutes_reservedHeaderPrefix(t *testing.T) {
+	routes := buildPolicyRoutes(&config.Options{
+		CookieName:           "pomerium",
+		ReservedHeaderPrefix: "x-corp-",
+		Policies: []config.Policy{{
+			Source: &config.StringURL{URL: mustParseURL("https://example.com")},
+		}},
+	}, "example.com")
+	if assert.Len(t, routes, 1) {
+		fields := routes[0].GetMetadata().GetFilterMetadata()["envoy.filters.http.lua"].GetFields()
+		var prefixes, exemptions []string
+		for _, v := range fields["strip_reserved_header_prefixes"].GetListValue().GetValues() {
+			prefixes = append(prefixes, v.GetStringValue())
+		}
+		for _, v := range fields["reserved_header_exemptions"].GetListValue().GetValues() {
+			exemptions = append(exemptions, v.GetStringValue())
+		}
+		// pomerium's own headers keep the default prefix
+		assert.Equal(t, []string{"x-pomerium-", "x-corp-"}, prefixes)
+		assert.Equal(t, []string{httputil.HeaderPomeriumOverrideToken, httputil.HeaderPomeriumInnerIdentity}, exemptions)
+	}
+}
+
 func mustParseURL(str string) *url.URL {
 	u, err := url.Parse(str)
 	if err != nil {
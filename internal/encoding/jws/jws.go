@@ -3,42 +3,192 @@
 package jws
 
 import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
 	"github.com/pomerium/pomerium/internal/encoding"
 
 	jose "gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/jwt"
 )
 
+const (
+	// headerCompression is the protected header used to indicate that the
+	// payload has been compressed prior to signing.
+	headerCompression jose.HeaderKey = "zip"
+	// compressionDeflate is the value of the compression header for DEFLATE
+	// compressed payloads.
+	compressionDeflate = "DEF"
+	// maxDecompressedSize bounds the size of an inflated payload.
+	maxDecompressedSize = 1 << 20
+)
+
+// ErrUnexpectedAlgorithm is returned for tokens whose alg header isn't the
+// algorithm they're expected to be signed with, e.g. "none".
+var ErrUnexpectedAlgorithm = errors.New("jws: unexpected signature algorithm")
+
 // JSONWebSigner is the struct representing a signed JWT.
 // https://tools.ietf.org/html/rfc7519
 type JSONWebSigner struct {
 	Signer jose.Signer
 	Issuer string
 
-	key interface{}
+	// compressor, if set, is used to sign DEFLATE compressed payloads.
+	compressor jose.Signer
+	key        interface{}
+	// alg is the only algorithm tokens are accepted signed with.
+	alg jose.SignatureAlgorithm
 }
 
 // NewHS256Signer creates a SHA256 JWT signer from a 32 byte key.
 func NewHS256Signer(key []byte, issuer string) (encoding.MarshalUnmarshaler, error) {
-	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: key},
+	return NewHMACSigner(string(jose.HS256), key, issuer)
+}
+
+// NewHMACSigner creates a JWT signer from a key using one of the HMAC
+// algorithms, HS256, HS384 or HS512. Tokens signed with any other algorithm
+// are rejected.
+func NewHMACSigner(alg string, key []byte, issuer string) (encoding.MarshalUnmarshaler, error) {
+	switch jose.SignatureAlgorithm(alg) {
+	case jose.HS256, jose.HS384, jose.HS512:
+	default:
+		return nil, fmt.Errorf("jws: unsupported hmac algorithm: %q", alg)
+	}
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.SignatureAlgorithm(alg), Key: key},
 		(&jose.SignerOptions{}).WithType("JWT"))
 	if err != nil {
 		return nil, err
 	}
-	return &JSONWebSigner{Signer: sig, key: key, Issuer: issuer}, nil
+	return &JSONWebSigner{Signer: sig, key: key, Issuer: issuer, alg: jose.SignatureAlgorithm(alg)}, nil
+}
+
+// NewCompressedHS256Signer creates a SHA256 JWT signer from a 32 byte key
+// which DEFLATE compresses payloads when doing so reduces the size of the
+// resulting token.
+func NewCompressedHS256Signer(key []byte, issuer string) (encoding.MarshalUnmarshaler, error) {
+	return NewCompressedHMACSigner(string(jose.HS256), key, issuer)
+}
+
+// NewCompressedHMACSigner creates a JWT signer like NewHMACSigner which
+// DEFLATE compresses payloads when doing so reduces the size of the resulting
+// token.
+func NewCompressedHMACSigner(alg string, key []byte, issuer string) (encoding.MarshalUnmarshaler, error) {
+	m, err := NewHMACSigner(alg, key, issuer)
+	if err != nil {
+		return nil, err
+	}
+	signer := m.(*JSONWebSigner)
+	signer.compressor, err = jose.NewSigner(jose.SigningKey{Algorithm: signer.alg, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader(headerCompression, compressionDeflate))
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
 }
 
 // Marshal signs, and serializes a JWT.
 func (c *JSONWebSigner) Marshal(x interface{}) ([]byte, error) {
+	if c.compressor != nil {
+		return c.marshalCompressed(x)
+	}
 	s, err := jwt.Signed(c.Signer).Claims(x).CompactSerialize()
 	return []byte(s), err
 }
 
-// Unmarshal parses and validates a signed JWT.
+// marshalCompressed signs and serializes a JWT whose payload is DEFLATE
+// compressed. If compression does not reduce the size of the payload, the
+// token is signed uncompressed.
+func (c *JSONWebSigner) marshalCompressed(x interface{}) ([]byte, error) {
+	payload, err := json.Marshal(x)
+	if err != nil {
+		return nil, err
+	}
+	signer := c.Signer
+	if compressed, err := deflate(payload); err != nil {
+		return nil, err
+	} else if len(compressed) < len(payload) {
+		signer, payload = c.compressor, compressed
+	}
+	obj, err := signer.Sign(payload)
+	if err != nil {
+		return nil, err
+	}
+	s, err := obj.CompactSerialize()
+	return []byte(s), err
+}
+
+// Unmarshal parses and validates a signed JWT. Its alg header must be the
+// signer's algorithm exactly.
 func (c *JSONWebSigner) Unmarshal(value []byte, s interface{}) error {
+	obj, err := jose.ParseSigned(string(value))
+	if err != nil {
+		return err
+	}
+	for _, sig := range obj.Signatures {
+		if jose.SignatureAlgorithm(sig.Protected.Algorithm) != c.alg {
+			return fmt.Errorf("%w: %q", ErrUnexpectedAlgorithm, sig.Protected.Algorithm)
+		}
+	}
+	if len(obj.Signatures) == 1 && obj.Signatures[0].Protected.ExtraHeaders[headerCompression] == compressionDeflate {
+		payload, err := obj.Verify(c.key)
+		if err != nil {
+			return err
+		}
+		payload, err = inflate(payload)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(payload, s)
+	}
+
 	tok, err := jwt.ParseSigned(string(value))
 	if err != nil {
 		return err
 	}
 	return tok.Claims(c.key, s)
 }
+
+// IsCompressed reports whether the payload of a signed JWT is DEFLATE
+// compressed.
+func IsCompressed(value []byte) bool {
+	obj, err := jose.ParseSigned(string(value))
+	if err != nil {
+		return false
+	}
+	return len(obj.Signatures) == 1 && obj.Signatures[0].Protected.ExtraHeaders[headerCompression] == compressionDeflate
+}
+
+// deflate compresses a set of bytes
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, fmt.Errorf("jws: failed to create a flate writer: %w", err)
+	}
+	if _, err = writer.Write(data); err != nil {
+		return nil, fmt.Errorf("jws: failed to compress data: %w", err)
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// inflate decompresses a set of DEFLATE compressed bytes
+func inflate(data []byte) ([]byte, error) {
+	reader := flate.NewReader(bytes.NewReader(data))
+	defer reader.Close()
+	b, err := ioutil.ReadAll(io.LimitReader(reader, maxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("jws: failed to decompress data: %w", err)
+	}
+	if len(b) > maxDecompressedSize {
+		return nil, fmt.Errorf("jws: decompressed payload exceeds %d bytes", maxDecompressedSize)
+	}
+	return b, nil
+}
@@ -0,0 +1,210 @@
+package jws
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	jose "gopkg.in/square/go-jose.v2"
+
+	"github.com/pomerium/pomerium/internal/cryptutil"
+	"github.com/pomerium/pomerium/internal/encoding"
+	"github.com/pomerium/pomerium/internal/sessions"
+)
+
+func TestJSONWebSigner_Compression(t *testing.T) {
+	key := cryptutil.NewKey()
+	groups := make([]string, 200)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("engineering-group-%d@example.com", i)
+	}
+	tests := []struct {
+		name           string
+		state          sessions.State
+		wantCompressed bool
+	}{
+		{"group heavy", sessions.State{Email: "user@example.com", User: "user", Groups: groups}, true},
+		{"small", sessions.State{Email: "u@x.io"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed, err := NewCompressedHS256Signer(key, "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			plain, err := NewHS256Signer(key, "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := compressed.Marshal(&tt.state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			legacy, err := plain.Marshal(&tt.state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := isCompressed(t, raw); got != tt.wantCompressed {
+				t.Errorf("compressed = %v, want %v", got, tt.wantCompressed)
+			}
+			if tt.wantCompressed && len(raw) >= len(legacy) {
+				t.Errorf("compressed token (%d) should be smaller than legacy token (%d)", len(raw), len(legacy))
+			}
+
+			// both signers should be able to verify either token
+			for _, dec := range []encoding.Unmarshaler{compressed, plain} {
+				for _, tok := range [][]byte{raw, legacy} {
+					var got sessions.State
+					if err := dec.Unmarshal(tok, &got); err != nil {
+						t.Fatal(err)
+					}
+					if diff := cmp.Diff(tt.state, got); diff != "" {
+						t.Errorf("Unmarshal() = %s", diff)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNewCompressedHMACSigner(t *testing.T) {
+	key := cryptutil.NewKey()
+	groups := make([]string, 200)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("engineering-group-%d@example.com", i)
+	}
+	state := sessions.State{Email: "user@example.com", User: "user", Groups: groups}
+
+	signer, err := NewCompressedHMACSigner("HS512", key, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := signer.Marshal(&state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isCompressed(t, raw) || !IsCompressed(raw) {
+		t.Error("expected the token to be compressed")
+	}
+	if IsCompressed([]byte("not a token")) {
+		t.Error("expected an invalid token not to be compressed")
+	}
+	obj, err := jose.ParseSigned(string(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alg := obj.Signatures[0].Protected.Algorithm; alg != "HS512" {
+		t.Errorf("alg = %q, want HS512", alg)
+	}
+	var got sessions.State
+	if err := signer.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(state, got); diff != "" {
+		t.Errorf("Unmarshal() = %s", diff)
+	}
+
+	// compressed tokens are only accepted signed with the signer's algorithm
+	hs256, err := NewCompressedHS256Signer(key, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hs256.Unmarshal(raw, &got); !errors.Is(err, ErrUnexpectedAlgorithm) {
+		t.Errorf("Unmarshal() error = %v, want %v", err, ErrUnexpectedAlgorithm)
+	}
+
+	if _, err := NewCompressedHMACSigner("none", key, "example.com"); err == nil {
+		t.Error("expected an error for an unsupported algorithm")
+	}
+}
+
+func TestJSONWebSigner_CompressedBadKey(t *testing.T) {
+	groups := []string{strings.Repeat("admins,", 100)}
+	signer, err := NewCompressedHS256Signer(cryptutil.NewKey(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewHS256Signer(cryptutil.NewKey(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := signer.Marshal(&sessions.State{Email: "user@example.com", Groups: groups})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got sessions.State
+	if err := verifier.Unmarshal(raw, &got); err == nil {
+		t.Error("expected verification with the wrong key to fail")
+	}
+}
+
+func TestJSONWebSigner_UnexpectedAlgorithm(t *testing.T) {
+	key := cryptutil.NewKey()
+	state := &sessions.State{Email: "user@example.com"}
+	sign := func(t *testing.T, alg string) string {
+		t.Helper()
+		signer, err := NewHMACSigner(alg, key, "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := signer.Marshal(state)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(raw)
+	}
+	// none keeps the payload and signature of a valid token
+	parts := strings.Split(sign(t, "HS256"), ".")
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + "." + parts[2]
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + "."
+
+	tests := []struct {
+		name    string
+		alg     string
+		token   string
+		wantErr error
+	}{
+		{"expected", "HS256", sign(t, "HS256"), nil},
+		{"expected hs512", "HS512", sign(t, "HS512"), nil},
+		{"none", "HS256", none, ErrUnexpectedAlgorithm},
+		{"none unsigned", "HS256", unsigned, ErrUnexpectedAlgorithm},
+		{"stronger", "HS256", sign(t, "HS512"), ErrUnexpectedAlgorithm},
+		{"downgrade", "HS512", sign(t, "HS256"), ErrUnexpectedAlgorithm},
+		{"downgrade hs384", "HS512", sign(t, "HS384"), ErrUnexpectedAlgorithm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, err := NewHMACSigner(tt.alg, key, "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got sessions.State
+			err = verifier.Unmarshal([]byte(tt.token), &got)
+			if tt.wantErr == nil && err != nil {
+				t.Fatal(err)
+			} else if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Unmarshal() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewHMACSigner(t *testing.T) {
+	for _, alg := range []string{"none", "RS256", "hs256", ""} {
+		if _, err := NewHMACSigner(alg, cryptutil.NewKey(), "example.com"); err == nil {
+			t.Errorf("NewHMACSigner(%q) expected an error", alg)
+		}
+	}
+}
+
+func isCompressed(t *testing.T, raw []byte) bool {
+	t.Helper()
+	obj, err := jose.ParseSigned(string(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj.Signatures[0].Protected.ExtraHeaders[headerCompression] == compressionDeflate
+}
@@ -10,6 +10,7 @@ import (
 const Web = "web" // static asset namespace
 
 func init() {
-	data := "PK\x03\x04\x14\x00\x08\x00\x08\x00\x91\x11\xb9P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x16\x00	\x00html/dashboard.go.htmlUT\x05\x00\x01\x92)\xcb^\xecYKo\xe36\x10\xbe\xe7W\xb0D\x8e\xb5\x19\xa4=\x14\x0b\xd9h\xb0\x8fb\x81\xa2	\x9a\xe4\xb0\xa7\x80\x16\xc7\xf2\x14|\xa8$\xe580\xfc\xdf\x0b=\xec\xe8\xe5XV\xe4lP\xac/\x91\xc8\xf9\xc8\x99\xef#9\xcch\xbd\x160G\x0d\x84\n\xee\x163\xc3\xad\x18/\xbc\x92t\xb39\x0b~\xfat\xfd\xf1\xee\xdb\xcdg\x92\xb6L\xcf\x82\xf4\x0f\x91\\G\x13\n\x9a\x92p\xc1\xad\x03?\xa1\x89\x9f\x8f~\xa3\xd33B\x82\x05p\x91>\x10\x12x\xf4\x12\xa67F\x81\xc5D\x05,\x7f\xcf\xfa\xd6k\x0f*\x96\xdc\x03\xa1)\x02\xecnRB\x02\x96\x0f\x92>\xce\x8cx*\x86\x13\xb8$(&Tq\xd44o+\xb5\xa2\x9e\x9b\xd1\xcc\xacv=E_(\xb9s\x13\x1ar+J]\xcd\xceQ\xeeF\xc5&\x0d\xe7r\xfa1\xb1\x16\xb4'\x89\x03\x1b\xb0\xc5e\xd5b\xbd\xc69\x19\xdf\x82sh\xf4\xf8\x06C\x9fX Y\x1c\xa5aPE\xdb\xc904\x9a\x12g\xc3	]\xaf\xeb\xc0\xcd\x86\x12.SF\x1dX\x82\x8aG@	\xab\xcf\x08\xd2A\xcb\x0c\x95\x06R\x99\xaf\xd6U\xcc.\xb8\xe7\xf7\x7f\xffI(\x1b\xc7\x85J\x8c;\x07\xde1T\x11\xe3ah\x12\xed\x1fB\xb4\xa1\x84\xd1\xe5\xaf\xf1j\xec\x96\x11\xddl\xea\xe3\xad\x94\xd4nB\x17\xde\xc7\x1f\x18{||\x1c?\xfe266b\x97\x17\x17\x17,\xc5T\x00\xcd\x80\xb4\xa8\xc4\x130\x81\xcbl\x01\xecZ\xe6\xc6*\xa2\xc0/\x8c\x98\xd0\x9b\xeb\xdb;Jx\xe8\xd1\xe8I\xd9{\x87\x91~0\x89\xaf\xcb\xe8 \xb3\x9d\xd6\xfc\x0e\xe2-K\n\x9cK\xc9\x9e~3\x89%a!\xb9\xcb\xd5!\x02<G\xe9\xc6\x01\x8b\x1bC\xcc\x11\xa4p\xe0\xeb\x1d\xf5\xa5\xf1\x17Wu\xd12\xbc\xe43\x90Mp\xeat\xcc\xf54\x85\x05,{l\xb3A\x1d'\xbe\xa5\x83\x10\xff\x14\xc3\x84zX\xf9\xbaZ\xf9\xaf\x08<s\xbf\xddb\xc9e\x02\x95U\x9a\xc7\xd0n\x9d\xed\xee\xce\xd6\x02\x1d\x9fI\x10-\x9d\xac\x19h\xc0\xf6\xb0\xb4\xdd\x0b5\xae\xff\xc0%\xe8\x9e\x84gX\xf2\xaeh/\x85\xd3\x95\xfb\x83\x90\xe1\x04H\xf7n\x8d\xff/\\\xa1|\xea)@\x0e~_\n\x94\x03\xea*\xc1a\xcc\xe0\x1a\xb4iq\x9b\xcc\xfe\x81\xd0\xf7\x10\xe2\xde\x81\xfd\xfa\xe9\xddh\xb0\x0b\xa4\xab\x00\x07\x00\x83\xb3_f\xfd\xb3\xe2({p\x9e\xe1^A9\xa4\xf8\xe18/\xc2\xe8\xca\xf8\x8b\xe6'\xe5;]\xab=\xe8\xbe\xcf\xeet\xbd\xd9\x1ev\x81\xe71t\xe5\xfa%\xeb\xc1\xa9\xb6\\G@\xce\xf1\xe7\xf3\x87\x0f\x93R\x9e\xb5&\x89\xddQ\xbcg\xb2\xc1\xbf\xe4\x1c\xc9E\x0bp\x97\x85\xd3\x91\xf7+\xd3z\x07.\xe3_\x84\xd6n\x9b;\xe4\xdb\xc8}X\xe27\x93\xb5rb\xadb\xb4OGiY\x1cY\x19\xf0\xdd\xec\xa2\xdc\x9d\xf1\x1d\x1e\x93\xab;\x80NJ\xfeW\xe7\x12\x10W}\xb2t\x0e}7\xf4o#9R\x80N\xb0\xd3K\xd0'\x87\xe4\xc0\xef-@3\x90\x03\xd4w\x05\x0cN\xfa.\x97\x90j2\xb9J\x04\x82\x0e\x8f\xfb\x97\xa1c:\xd9\x8e\xfd\x7f\xca(%%\xc9>\xf1J\xcbc\xaf\xcd\xe0\x02Wv\x95\x8a\xc1:\xa3\xb9\x87\xbeW\xe2\xe7!PG\xe4\xb5\x17\xe4\xd70\xder\xda5\xc2{#\x8e\xf7\\\xc8J\xfe\x9c\xeenV\x15\xe4\xc7M\xad\xe57\xac\xdegu\xf3\xf6\x9ac\xc0Z\xcb\x9c\xe5J\xf7\\\xc2\x8a\xd6\xe7IO\x87\xd0\xd9\xf9\x97t\xd4z\xe9\x9a\x90`\x96xo\xf4v\x88\xe2m\x9eHI\x0b\xb6]2S\xe8\xe9\xf4\x16#M\xae\x13\x1f\xb0\xdc\xa8\xee^V\xd4-7\xcc\x8dU\xcf-\x15\x83j	\xf8\x0d\xca\xfc\xa9\xf3#\xd4#\xee\x9aE\xfeST\xd7]\x12\x83]\xa2\x03\xf1\x908\xb0'\xae\xb2\xf7\xae\xa8s\xa1P3|>W\xfa\x97\xd6\x1b\xcb\xfb*\x1d\x1b\x9d\xb7\xdc\x1b\xebH\xc85\xf1\xa0bc\xb9E\xf9DJ\x93\x12\xae\x8d_\x80\xcd>\xc2\x8c\x1b\xfb\xe1\x98\xaa\xfc\xa1t\xd3?\xc1h\xae\x8a\\[O\x0b{s\xefpE\x9b\xf6\xceX\xf2\x10\x16F\n\xb0\xf97\xa5\xdfa\xc5U,a\x1c\x1a\xd5\x069\xe6t:\xc4\xe4\x81\xccp,\x93yB;@\xe5\x00\x07\x7f\x07&AG\xa8\x01,\xea\xe8u$~\x9f\xa3\xbc\xe1]+\xdfW\x99\x03\xad|\x17L9h\xe1\xba%M4l*i\xa3\xd6\xdb\xe4\xae\xe4S\x83\xbe\xc1\x12M\xe51`\xf9w\xe7\x80\xe5\x1f\xbe\xb7y\xf8\xbf\x00\x00\x00\xff\xffPK\x07\x08\xaa\xa0\x16\xe1c\x04\x00\x00-\x1f\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\x9d\x11\xb9P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x12\x00	\x00html/error.go.htmlUT\x05\x00\x01\xab)\xcb^\x9cUKo\xdb<\x10\xbc\xe7W\xec\xc7s,\x06\xfe\x8a\xa2-d_\x92\x1c\x02\x04h\x90&\x05z\n\xd6\xe4J\" \x91.\xb9\xf2\x03\x82\xfe{A\xd9N\xf5p\x82\xb6'K\xfb\xe2\xccj\x86n\x1aM\x99\xb1\x04\x82\xbcw>)\xb8*E\xdb^\xa4\xff\xdd|\xbd~\xfa\xf1p\x0b1\xb2\xbcH\xe3\x0f\x94h\xf3\x85 +@\x15\xe8\x03\xf1B\xd4\x9c\xcd>\x89\xe5\x05@Z\x10\xea\xf8\x00\x90\xb2\xe1\x92\x96M\x93|c\xe4:\xb4-\xcc\xe0\xf5\xed\x89v\xdc\xb6\xa9<\x14u\x0dM\xc3T\xadKd\x02\x11\xc7\xd0o$\x00\xa9<MNWN\xef\x8fGh\xb3\x01\xa3\x17\xa2Bc\xc5!\xd6\x8b\x1a\x9b\xb9\xd9\xca\xed^3\xc7\x9c*1\x84\x85P\xe8u/5M\xce\x0e(\x065\x00\xa9\xa9\xf2A\x00N=F9+F\xa9\xe0\xd5B4\x8dF\xc6\xe7\xc7{\x102Y\xbb\x8a\xbc\xa9+\x89!\x10\x07i\xaa\\v{\x9f\xcd?\xacwI\xd8\xe4\xa2m\xc7cvUi\xc3B\x14\xcc\xeb/Rn\xb7\xdbd\xfb\x7f\xe2|.\xe7WWW2\xf6\x0c\x1a\xe4\x08r1_N6_\xcc\xdf)\x9a\x16\xa4R\x9b\xcd \x10H\xb1qv4\xa5\xb7\xc3\x8aB\xc0\x9cF\xfb\x1b\xd60\xedxV9\xeb\xc2\x1a\x15\x89\x88\xe06.#\x02\x18\x1dx\x06C\x14\x8d\xc9 \xb9F{C\xab:\xef\xa4r\xfe\xa0\xb7\xc0\xdce\xb0w5\x84\xc2\xd5\xa5\x86\x027\x04\xa8\x14\x85p	\xcaYF\xc51\xef\x01ue\xac	\xec\x91\x9d\x07\xb4\x1a\xd6\xdem\x8c\xa6\xd1<.\xa8\x82\xad\xe1\xa2k\x1b3G(<e\x8b\xbe\x0c\xc4\xd2\xd3\xcf\x9a\x02\x83&FS\x86T\xe229\xc7{D\x9c\xacn\xdbn\x01\xc9#\xb1\xdf??\xde\xff;\xff\x15\x95\x866\x14\xe1C\xa7F0\x01\xa2\x1f\x9dG\xbf\xbf\xecj\x14\xda\xb7\xf84M\x0fCd\xc4~\x1fyt\xf3\x8e\xfc\xcer\xea\x87\x8e\x94\x06\xa2;#\xb2\x89M3\xe7xj\xd3\x13\xb4\xe8\x99p4\xcdi\xe9\x89qSUN}\xfd\xa7\xf6=\xc5^\x94\xf1\xaa\xa4\x97\xcf\x1f\xdf\xb0\xf1_\x1b\x19\xde\xbf]\xc6>\x978R\xc9\xc4j\xde\xe4\x05\xc3\xc1u5\x93\x86PaYN\x96\xd1}\xcf\xee\xab\xdd\xdd\xb4-\xa4+?>\x0b\xe0\xe1\xc8:\x16\x7f'\x1f\x8c\xb3c\xfdM\xee\x8ca`\xf0\xda{y}L\xe5\xe1\xb2O\xe5\xe1\x1f\xe8\xa4\x90_\x01\x00\x00\xff\xffPK\x07\x08\xd6L6ob\x02\x00\x00\xb2\x06\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\"\x12\xb9P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x13\x00	\x00html/header.go.htmlUT\x05\x00\x01\xa1*\xcb^\x8c\xcd1k\xc30\x14\x04\xe0]\xbfBhN,Z:F\xfd\x05\x9d\n\x9d\xcb\xab|\xb1\x1f\x95\x9e\x8d\xf4\x9c8\x18\xfd\xf7\x12C\xc9\xd0\xa5\xe3\x1d\xdc}\xdb\xd6\xe3\xcc\x02\xebFP\x8f\xd2\x8d\x9a\x93k\xcd\x9c2\x94\x8c\xb5B\x19\xc1]\x18\xd7y*\xea\x8c\xb5q\x12\x85hpW\xeeu\x0c=.\x1cq\xdc\xc3\xc1\xb2\xb02\xa5c\x8d\x94\x10\x9e\x0e6\xd3\xcay\xc9\x8fb\xa9({\xa2\xaf\x84 \x933\xfe\xd5\x9c\x12\xcb\xb7\xb1\xb6 \x05W\xf5\x96PG`\xe7\xf46#8\xc5\xaa>\xd6zo\xc6\x82sp\xdb\xd6\x93\xd2\xc7\xfb\x9bu\xbe\x9b\xa7\x8c\xc2K\xf6T+\xb4\xfa\xfd\xc2gb\xe9\xee\xa3\xd6\xfe*\x1c'y\xfcs\xa6\x01~\x96\xe1\x9f\x00\xe7\xc1S\x8c\xd3\"\xfa\x19\xb9\xc4\x84\xe3\xf3\xcb\xbcv\xf52\xfcj\xdb\x06\xe9[3?\x01\x00\x00\xff\xffPK\x07\x08`}f\xf1\xde\x00\x00\x00c\x01\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00r\x9b\xb2P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x1b\x00	\x00img/account_circle-24px.svgUT\x05\x00\x01\xa9\xe1\xc2^<\x90Ao\x830\x0c\x85\xff\xcaSv\x8eq\x9c\x90\xc2Tz\xd8.\xbb\xec\xb4_0\xb5\x8c \xb5\xa5\x1a\x88T\xfc\xfa\xc9\x80&\x81\xfc\xf9\xe5=K\xf6q\x9c;<o\xd7\xfb\xd8\x984M\x8f\xd7\xa2\xc89S\xf64\xfcv\x850s1\xce\x9dA\xee/Sj\x8c\x04\x83\xd4\xf6]\x9a6\x9e\xfb6\xbf\x0d\xcf\xc60\x18\x12 \xc1\x9c\x8e\x8f\xef)\xe1\xa7\xbf^\x1b\xf3\x12\xdb\xe0\xdb\xca\xe0\xd2\x98O'\x90\xf7H\xa1\x82@\xb0\x83\x931(9\xfe\xff\xec.X\xc7_\xee@\xa5\xac6\xc8rc\xf8\xb3\xa3\x18\xc1\xf0p\xe4\x03<\xfch7\xb2^\xff\xb5\xb1\xdal\xa0\x9a\x06] 9[\xa1\x12:\xff\xe0\xac#\xa9l\xb4\x9eD\x885V\xd7\x08\xd6\x13W\x88[Y%FI\xf5\x01\x8e\xb8F\x84\xea\x9a\xac\xf5Q\xdd%t\x80\x8dkYL\xb1o\xaf\xeb28I\x98%|\xf0b\xf6{\xdc\x87{\xab&=\xea\xe9/\x00\x00\xff\xffPK\x07\x08\x83\xba\x83\xe4\xf5\x00\x00\x00|\x01\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00r\x9b\xb2P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x12\x00	\x00img/error-24px.svgUT\x05\x00\x01\xa9\xe1\xc2^<\x8f\xcbj\xc3@\x0cE\x7fE\xa8\xeb\xf1hn\x94\xa4\x94L\x16\xed\xa6\x9b\xae\n\xdd\x17\xe2Z\x06?B=\xcc\x04\x7f}\xf1\x83\n-\x0e\x97#\xc4\xbdL\xb9\xa1G\xdf\x0dSdK\xe9\xfe\xe2})\xa5*\x87j\xfcm<D\xc4O\xb9a*\xed-Yd(\x93\xd5mci\xe3\xdc\xd6\xe5u|D\x16\x12\x82\x12\x94\xaf\x97\xfbw2\xbaE\xfe\x10\x12\x83f\xe8\xbb\xccL?m\xd7E\x1e\xc6\xa1f\xbfK[\xf4tX\x87\xd7\x9b\x00\xc2\xdb\xa9\xd2g\x02\x81v\x08\x98t\xa1 \xff\xeb\xf6\xc0\x05\xf9\x0c\xe7\xea\x88U#\xcc}\xa0p4\x87\xec`\xc8\x98{qj\x0e_gC>\xcd\xcb\xeb\xa5\xd1\xf5/\x00\x00\xff\xffPK\x07\x08\xfc\xc6x\x8f\xb7\x00\x00\x00\xf9\x00\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00r\x9b\xb2P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x10\x00	\x00img/pomerium.svgUT\x05\x00\x01\xa9\xe1\xc2^\xc4UMo#7\x0c\xfd+\x84{i\x0fz\x16I}\x16\xeb\x1c\xfaO\x06\x89\xe3Y\xc0N\x16q:Y\xf8\xd7\x17\xa4\xc6N\x8a\xdd\xf4\xd4\xa2\x08\xc2\x91\x1f)\x0d\xf9\x9e\xc8\xf9r^\x0e\xb4|\xdd\xbf\xfd\xf1\xfc}\xb7\x89\x14\x89\xb5\x93\xc6\x0d}?\x1d\x9f\xce\xbb\xcd\xfc\xfa\xfa\xed\xf7\xed\xf6\xed\xed\x0do\x8a\xe7\x97\xc3Vb\x8c\xdb\xf3r\xd8\xdc}9\xd0\xeb\xcb\xf4t~|~9\xed6\xbe<N\xaf\xfb_C\xa6\x90\x91\x7f\xdb\xdc}\xf96\xbd\xce\xf4\xb0\xdb\x9c8\xa2PF\x9eY*\xda\xbd\"v\x8a\x94QH\xdc&h_\x84!r\x1fI\x90$\x082;\x1c\xae\xfe9\x8c\xcda\xec6<\x88[\xdf\x1d\xd6\xed\xc1\xb6\x93mw\x9c\xae\x01\x97\x0d=~=\x1ew\x9b_\xca>\xe9\xbe\x8d\x9f\xe1\xe5\xcf\xe3~\xb7\xd9/\xfb\xa7\xe7\x87\x87\xcd\xd6\xaaZ\xc3\x1e\x1f\x1f?\x96P3\x12IA\x9190R92X\x83\xa0\xf6 \x90l\x89\xf4\x99\x91\xd3\x91-\x01E+\xc4H\x1a\x14\xad\xba\xe3r\xb2 \xb6\x04\xd3\xb4f\x99\x84\x9cv\n\x8c\x1c\x90\xf3\x02\xad\xf6\x82\xccKhHi\xf6\xa5Z|#7#~\x0d\xbfgd\x83\x04\xc5^'m\xac\x8c\xa2s`\xc4f\xcb\x16\xae`\xbb\x9c\x02TBBk\x13\xa34r\x13\xfd/0\xb8!\xeb\"\xc8b\xdeLn\x86w8\xd3=Z\xf6_)\x05T\x1d\x0bF;\x07\xe4\x1e\xd8\xe8X\xa1\xda/\xa7\xd0\xd0L<\xcb_-7m\x1f\xea\xb5\xfc\xedY)z\x86\x81!u\xac\xbc\x00F\xecC\xed\x1bf<$rs\xe3\xc1X\x0bjD\xc9\xd2\x90\xb2\xd3'\x97S4\xea\xad\x0e\xcb\xb2\xe8\x87*k@\xd6\xfb`\x12EK7\xa3\xaa?\xc9\xd2>#wb\x187\x8e\xf8\x19v\xc4;\x13v\x80\xe9\xd9\x9d?\xf3\xfb\xaa\xdf\"|s'E\xce\xc3\\\x1d\xb5\x04\xe4\xb20\xa4L\xc9\x08t\xb3r\"\x84TH\xe9\x06\xd8\xeda%A\xab\xc3\x0c\\\xd0\x9a\xd1\xa1Cm7WO\xee\xa4\xd6j\xab\x19p$\xe4r91\xd4\xf8\xd54\xb1)\xc1W9\x9c\x15\xad\x06\x94Y\x90\xeb\xe4W\xc9\xcd;k\xa6\xac\x16\xbf\xc5\x9a\xd0uQt\xe7z	\x19}\\T\xe4>	\xc9M\x9c\x94\xc3\xca\xcd\x87\xcb\xc4\x84\xd4\x11\x8d\x05\xcd\x13\xa3\x99@\xed&\x10\xb2\x06D\xcb\xa6\xad\xe6\x96C2\x02/\xa7P`\x9e\xc4?a\xbe\x96\xff\x8d\xfa\xf2\x1f1/ze\xbe\xa0TJh\x06H\xb0.\x13J\xa6\x81\x1e}\x16\x0e\x15l4uJ\xe4\x9a\xd9\x04\xd1\xfa\x03\x94\xf9\x18l\xf6Z\x93v\x88\x82m&u\xeb\xad\x9e>r\x10\xfb\xca\n\x13S1]\"\xfd\x18h\xb8\xf1e]\x97\xd0\xc5\x9a\xab\x8d\xe6\xea\xa8\xd9\x9f.\xf2\x19\x85i\x95|\x85\xac\x875\xb8\xa2Y\xc7\x08A\xb1-^\xa6CMG\xf1\xe2\xf7\xc1\x0b.\x8b\xa0\xb3\xcf\xe2n\xa32\xd6\xd9d\x9a\xc4^\xe0f\xa4l\x9f\x19\xb1\xb1'\x12\x9c*N\x8b\xa0\xf1l\xab\xbf\xa9a\xb16\x88j\xbe|\xf6qx\xff\xa0\xa9\xa02qB\x9f\x14]\xc9\xcd\xaa\x98\xad\x837\x87&\xf0'\x01\x06\xc8\xc2\x05\x9cf;k		\x99\xa7l\xac\xb8\x19\xe93S\\\xcc3\xcb\xbf\x11\x81T/\xa7\xa0\x1d\x9a(\xe1\xe7\xa1\x97\x13\x0bz\xa6\xf8\x0f\xde\xf4\x99\xd7X\xda\x1e\xd6\xff\xf3r\xb8\xfb+\x00\x00\xff\xffPK\x07\x08K\xfe\x8b#M\x03\x00\x00d\x08\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00r\x9b\xb2P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x1a\x00	\x00img/pomerium_circle_96.svgUT\x05\x00\x01\xa9\xe1\xc2^l\x95\xc7\x0e\xe4HvE\xf7\xf3\x15\x85\xdaR\x9a\xa47\xad\xa9\x06\x82Lz\x9f\xf4\xb9\x19\xd0{\xef\xf9\xf5B\xb5\xd0\x82\x16\n \x16\x07\xe7\xe2\xdd\xb7{\xffZ\x8f\xf2\xc7\xd5w\xc3\xfa\xebg\xb5m\xd3\x1f\xaf\xd7y\x9e\xff<\xb1\x7f\x8eK\xf9Ba\x18~\xadG\xf9\xf3\x7f\"\x7f\\]=\xb4\xff_\x10a\x18\xe6\xf5\x97\xfd\xf9\xe3\xac\xb3\xad\xfa\xf5\x93!\xff	c?\x7fTy]V\xdb\xff\xe2Q\xe7';^\xbf~\xc2?\xe0\x1f\x0c\xfc\xd7\xff\xf9\xe7?~\xfc\xf8W\xdd\xc7e\xfe\xa3\xce~\xfd\xf4\xf3t\x1b\x97\x7f;}\xbcl\xff6\x93&O\xb7\x9f?\xb2x\x8b\xffs\x88\xfb\xfco\xff\xe3/\xff\xe3o\xffw+\x0c\xff\x9f\xce\xdf\xf0\xd7V\x7fTK^\xfc\xfa\xf9{\xc8\x1fu_\xbe\xa6\xa1\xfc\xaf$^s\x12\xff\x8f\xdag\xcd\xcf	\xabb9\x02\x00\x80\xe1x\x15\xef\x95\x00\x00\xf17\xb2%\x07\"\x00\xc0\xbb\xee\xa5\x14\xff\xedI\xbe\xe3m\xff\x83\x0f&\x9aq!\xeb\xf9\x9fX<0\x9bs8n\xb5GPQ\xb2\x8d\x8f\\S\x86#I5{\xa9\x93K\x95\xd8j\xdb\xe8\x8f/\xec\xd1\x93\x98\x1c\xd9!{]v\xf2x\xd6S\xa2\xee:?\xce$\x18\xe5\xc6\xe4\xd6\xb1/9 \n\xab\xde\xf6v+s\x9c-\xdeL\xe6N\xf9\xb3\x11d\xe8b\x01u\xb8N1\x1fd;\xf9\x13\x031\x04A=\xd4\x82\x0d/3\xa7!D\x13\xdfV\xe0\x8eb)\x0d@\x83\xd9dd\x8b\x08\xac2k\x8c\x00\xb5\xc1\x93\x84\xa3\xe0\x81+eK\x1c\x98\xa7\xb8\x95<U~0\x90\x1f\x08s\xd6\xfcV\xda\xb6S\xf98\x17\xaa\xc0\xb7`{A\xeb%\xfe\xbc\x86\xcc\x0b\x80\xb1\x02\"\xe2\x86\xd3$\xf4l\x08\x0c\xe1\x83=f\xc9? \xdd\x01\x8f\xa3\xb0\xa3\x84\xb3\xecA\xec\x1c\xbd\x87\x93\xa7\xe4\xce=0\xdf\x0e\xcf\x18\xe7\xb4R\xd8A7\xfa\x1f\x9a\x13\x05`>\xc0\xe9\xc1a5X\xd2\x1e\xcf\x81*p\x95\xbe_g\xb8;\xe0\xddq\xc4\xc8\x1a\xa7^\x00\x86\x06\x11\xa4}\xf3\xe4\xd4S\x16;\x87\x84\xd2\x9e\x91q\x07\x18?\xdfRi\xa0\xc0M\x85\x916#\x14\x05:\xce\x1f%;\x80\xd9\x95\xf6\x1b\xdeZ\x80Xp\xa3\x95xWa\xc2]\xbe\xa7S>\x80\xb3c\xbe!\xb8r#\xd4m\xf3\xe5\xbf\xf5#\xf1g\xc9\xbd\x10\xdf\xc2\x87\xaa\x86C\x1f\xc2\xdd\xe85}\xe7\x17\xc50#xN\xb1\x00\x1b\xcc$M\xfa\x81\xd98b\x97g\x9a\x9c+\x17c.\x0b\xf7\xb5\xa0\xd5\x9a\xf9\xe8\xc9v\ns\xd9]\xe9\x9d\xf7<\xa8\xfd\xe9\x11_U\x9c\\\xf9\xbb\xb4\x91\xbc1\xfa\x0d8\x94M\xed\xc1\x81\x81\xc5\x01\x8a\xb8\x7f_(\x92\xb4\x1f,\xc2\xc1\xb2\xd9\xef\xeb\xbdG\xce;\xb1\xd2\x0b\\f\x17\xe6DS\xdd\xae\xc7\x02,\x0b\x1d\xfam\x81$\xe2\x05\x87\x0e\xc3S\x188\x06\x8b\x8eU\xac\x81\x14\x98\x8a~\xd41K\x86\xf1$4\xab\x18\xab(\xc1pN\x8dc\x0ch\x902\x8a8\xc9\xd6\xe3\x8e\xf6^5\x9c\xa2\x1aq!\xd1\x19\xd6\x99\xde.@t\xbe\x10D\x11\x1e\xfb\xe5Q\xca\";\x1c\xa5\x9a\x0f7d\xed\xb7|+b\nm\xd2\xabM\xd8\x8c\nr4\xe3{v(?\x1e\xc2)9\xaf\xb7=B=d\xcb^\xa6\x97\xf6\x88C\xc7\xf8c\x9b\x05w\xd6b\x0c^\xb7\x0b\xa1\xec\xa8Om\xe4\x18\x8a\xcc.\xfdU\xaa\xe9\x87\xff\\E\xa9AB\xdb\x88\x0ep<$\xf0\x13\xe9\xbdK`\xab\x1e\x0bP\xb8\x94\xbfC\xc7\xae\xa3[O\xa5m\xdeuu\xdd\xdc\xa5\xc2A\x03\xcdk\xbc]\x9f\x0fFX+4\xaa\xc7U3\xef\x18\x16\x05\xb4\xfbB\xcex\xe09\x06\xf2\xae\xbaE\xa4\x0f\xd6\x8a\xbf2h\xe6jI\xd8\xbb3jR\xc4\x9dV,ds\xe6\\O\xad\xa8\x84\xb9\xd4\x8emS\xe2\x98\xad}\xa1\xd5\xe8\xb0\xeaN\xb9\xa4!\xe9\x11H\xbbk\xcb\xee\xb8\xc8z.\x17\x94\xc2\xb5\xf4\x9b\xb03Hl\x86\xcf&\xc4k\xe3\xab,v\x83m\xc0n\xc7\x08\\\x87\xb9\xda%_z\x0e\xc1(\xe1xVf\xa2u\xe2\xf5\x99cOs\xe5\x99\x1b\xa5s\x83[\xd9\xbb\xba	;\x08\x84e\x16z\xb2\xd2\xa8@\x19\xee\xcd\xcf=\xed\x1e\x19\xd6qx\xdaT\xc3I\xf5\x99\x93\x8c\xb1\x93|\xdbx0\xcb\x10\xd2x\xa1-y*\x8c\x96\xe0\xbe[$(\x0e4\x19\xcd\x85\x1a\x9d\xa7\xda\x8ey\x17\x84\n7\xba\x13\xc6\xad\x93\xc6\x92\x13u\x0d`xm\x18\xcajL\x92\x99\x16t5\xb1/r\xfa\xaeB\xe0\xc0{\x19 \xaf\x07\xbb\n\x084\xaf\xa3T\xd47\xac\x9d]f\x9ej`[i\x9f\xab\x83\xc4\xb8b\x88\xe1):8 90/$s\xfb\x08.k\xa8\xa60\x18\x8cq8{\x86%F,\xdb\xed\xb3y\xc3\xd2\\/\x15+\xed\x086\n\x9d\xaaUY\xd8\x0b>\x14\xa9u\xc1V\xf4\xb0\x0c7\x03\xa1@t^ C\x18\x90:\xa2\xd7\xc1\xc2\xac.\x9f\x91K\x10\xab\xdaE\xec\xa4\xda&\x01\x83\x81\xef\xfdI\x0bXt\x94\xa2\xd9\x91\xb3\xb1\xfa\xdd\xb39\xbc\xb9\x9f\xf7\xbe\xc3\x19\xa1\n\xdf\xcf\xce2\xfdQ\xd6\x8b5\x9a5@\xd8+\xd4\xb1\x93\xa83\xf0\x10V\xb9v\xc2\x99~>\xce\xee{\x89(\xc9\x1fM\x19\xc50?\xbf6\xb7\x88\xcai\x1b\xa9\xdcg*\x0e\xf9\xf6\x96Bp\x08=\x0coF9\x9bz\xd4\xa8\xef\xc77\xc0d\x8e\\B\x1a*158\xa1\xc6\xc4\xd3\xf2I\x8dL\xa4]fh\xaf\x8dVj\xf2\xcb\x91\xd8w\xc6	\xf3\xa5\x1f\xe6\xf2\x92\xb8\x95\x15\xbff\xc9y\x0f\xfe%C<=\x97\xc78\xe8\xa2l\xe9\xec,h\xba2Z\xa6\xfb\x88\x8c\xd8~\xe7{,)d\x8b\xd6\xb3\n\npZn$\xf4|@}x\xeaH\x98\xd1\xb5\xbb\xdc\xf2s\x1aL\xd9\x8c{rl\x11\xd0\xac\xd1\nI\x84\xf7\xde\xbeXC~:#m\x91WE\xc75\xb8sJ\xb9##j!3\x1d\xdb\x93bh\xab,\"SJ`1\xb53\xb3\xef\xa8a\x9a\x1cf\xf6\x82,\x98\xb5\xc0\xb6\xcah\xbcs\xf3\x8c	\xe7+\x9e\xaf\xb9\xa3\xad\xa2P\x1dz\xcf\xc9\xf2\xfe\xce\x13\xfb\xe9z\xd1<r\xde\x9a\x0b \\/ \xdfz\x9d?#k\xe6\xcf\x06\"\xf9\x81Al\xd7\xa1Ib\xf1\xbe\xc8\xa5]\xeeO\xf1^\xa9\xec=A\xc4\xbcDt\xbb	w\x81\x94\xd7]\xad*\x97\xa1\x12I\x13\x12\x95\x1f\xf0'xG\x16[\xde\xef\xcep\xe53z\xc1J\x81\xa2\xd4\xf94\x9fy\xedt\xf64\xa5\x88\x1d>\xad\xa4!\xfbb6\xb7\xd30\x9c.\xf2\xb6\xc1\xd0WOC3`\xc2o\x05\x90\x89]\x07\x9c\x80\x82g\x9a\xe5\xf2\xc9s\xde\xde\xf4DU|\xff+\xbeH\x0c\x87%m`\xf4\x99\x8ds\xd3\xa3=\xf5\xcamL\x1f\xca\x8aq\x91\xf8uA\xce\xc7\xe8\x84\xfeB	;-\x9b<\x7f\x16:a\x08F<4\xcc6\xd3\\8.\xec@\x94\x13\xa3\xf93y\x945\x10	\x9f'\xbeg_\xd5\xe5\x80\xe4[\xe2\xd7\x155\xb9;\x9aEo\x1e\x11\xe7Dtk7|\xe8|\xc3^\x0f\x86\x08\xa2\xd7\xd3\n\xf5\x12\x88\xb3\xef(\xf2Z\x837_\x14\xc7|1\xc7\x8b\xff\x16\xd7-\x0c\xee\x91\xdc\x06N\xcfk\xe7\x12\x89i\xc6Lt\xe8Q\x9c\x1f\x0dl3\\\x1eY\xe1\x10w\xdc\x8dm;\xfb(\xae\x19\x04Sf\x0dsK\xb4\xda\xe5\x0cJh\xab\xe9J\xe4\xc9\xb7\x0c\xcb\xeb\xb2\xb3*\xf7*1g\xba\x14\xc9q\x85,\x92\xb8E\xda\xf2fvk\xbe\\\x04\xaf$\x12\xa2\xeevh\xf2\xa3\xda\x92\xadK\xb46Y\xc2\xa7\x8d\x90\xb8\\\xf6-F\x8f\x18\xa5x\xf6]e\x80\xbe\x12\x14%\x0f\xaf8\xfa|I\xfc\x0eS\xd0\x01\x13\xc1\xa0\xaf\x8b\xa8\x99\x14\xcc\x1c\xc1\xef;v\xec*\xcf\x1cA\xa5\x80\xa97Br5\x96\xfd\x1e_\xf9\xd6\x95\xda\xaeu\xba,\x0e\xf1\x0e\xa1Tdn;\x11j\x13\xc1(\xa7\xaer\x9b\x97\xc5\xb0\x9b\x8bMe\xb3\xdc\x90H\xae=\xea\x95\xcfaCvB{h.%\xc3^\x8f\xcbl\xda\xb4\xdc\x06|e\xa3\xe2tC\x89m\xbb\x10B\xf4\x1b\xac\xe8\xb8[\x96\xc7\xe5\xe6\xd4\xdd\xd2\x1b\x7fI\xcfW\x0f\x9al\xeb\xe3G`\x9e[\x17.\x7f\xb4\"O!\xd8.\xc9\x01\x11\xf6\xe5Z\xca'\xbc\xd0\x93\xf9\xe5QChx\xb2,\x9a\xb2\x08.A5\xe3S\xc4\x06I4[h\xa5\xd5J\x11UUh\xa8w\xeb\xd0W\x15VR\xdb\xd0\xa3V\xc4\xbb\x80\xf4\xf7\xc4c\x16<\xb3\x10\xf2\xa5x^\x128J2B\x15*\xde\xe4\x18\x9cI\x828\xbd\xd5\xb8g\xad\x1bf\x99\xeeR\xdc\xd3\xd2\xb3\xdb\x86_\xcb\xda\xd9\xcb\x97\x9a\x87\x96\x97\xd2\x0bF\xbe\x85\x82~.]x\xa8`\x0dO&/l\x83\x88\xbb\\\xb7\x0dd\xe6\x0cUL\xaa\";\x10\xa6[\x96\xec\x03\xde\x02\x08j<\x11\x8a\xf0\x9a\x16U\xb6+n\x9b`D\"\x886\x9d;g\x1d_\xfaL#\xe8[7\xc5\x83\x17\xbf\xa3R\xe4\xab\x82j\x88\x90\xba\xf9}F\xf3}\xa9\xafb\xe7e!\xdd\xb3\x0c`\x99\xb1\xf6\xdfhL\xe4\xa1\x91\xbdN'\xec\xaa\x8aNmW+\xff^\x1a.\x0e1X\x1c/\xf2\xcd\x91\x04\x92\xf8\xea9\xf3\xb5\xfb\x852\x1cW\x94\x05_\xdf9\xc4\x1e>\xfd6\xdd\x8c\xa5ZAu\xd2o+\xafJ	\xa1\x1a\xedJ\x0b,JC\x99i\xd5\xfa=R\x98h!\xb6`\xee	R\x8b\x15\x9e\xd5{\x1e\x9d\x80\x87\xa1\xde\xe6\xf2\xb0\xabk\xd4\x87L\xe9\xeb\x12>'\xc3<w\x8c\xb9tR\xf3\x81\xf0\xd1\xa13\x96L\xda5\x81P^(\x98\xf7\xfb\x0b\x7f\xb93e\xce\xf1\x95\"x\x1f\xdc,V\x93\xf8\xdaeL\xee\x18dI\xcd\x051\xf7#o\x05\xb1\xc4k\xca\xe7\xadR@\xfc\x06\xee\x1bj\xf01nF\xb7\x93\xeen\x0e\xee\xd2\xc4\xc3\x96\x93\x04]\xd6\x1cI\xe5\x9f\xaf[k\xd6\xb6\x17o\xef\xc9\xc4\xa9\x1a\xc4\xc6W\x9c,\xc5\xa3Y\x9b\xab\xf7lv\xa5\xdbW\xc7mozQ\x82\xe0y\xb1\xe2\xe7\x8c=h9\xa1}?\x18:a+\xb1\xb0\xe3X'\x91\x1b\x80\xc7\x9c+\xbd\xdb\x9e\xec\xa2u>\xd5\xe4\xe9\x18\xee#\x98\x8c.p\xc5FQ?\xd6\x1e\x9b\xc2E\xd0\xd9\x98PG\xfb\x14\xd5\xcdt3\xf6p\xae\xfem\xa6vkO\xc8%\xfd\xaa\xd0\xed\xcb%\x98U\x81\x87V\x91\x0d\xd1\xbf-\x90\x7f\xf5Y\x12\xc1\xd8\xddJ^\xe5\x90\xf7*^4\xc3\xd8\x9b&\xaf\x96\x9f\x10\xb2\xd2]X\xb1\x80r\xc9\xe0s\xbb\xc1\xba['\xeel3\xd3\xf5\xc2\x03\x88*C\xbdo\x7f\xec\xf3tF\xa3\x00t\xfc\xad/\x8b\xdd\x9c\xca\x18\x0e.J,\xee\xd5\xc6\xf2Y\x7f\x9a\xad\xd1\xda;0\x95\xafRX\x1e\xecdC\"[A\xae\xb40Q\x18\xafy%\xb6\x82\x1e\xa8\x1c\x8b\xa7\xf7\x99\xcc\xd09\xe7\xfdW\xa1Z\xb6\xb5\xa4\x84?\xcb0X\x0d8\\\xf8\xd7\x05ZE\xba\xa1o\xd7Q\xad\xf7\x89*n\x9bIi\xb0\x1327S+\xeeb$\xab\xd4\x05\"\x02\xf7(\x86\x04\xed^/HF\x1bJ\xf0\x00\x19\xa0e\xa42\xf7\xecb\x9c2\xd1P\xc8\xe8\xc8\xc4\x13#u};\xc0\xe5v\xe3s\x01Gv:\xc7k\xa9[\x08\x81\xd7?\x0e\x8a\xd6\xcd\xb6\xde\xe0\xa5\xbc\x13\xe7\xb2\xc7\x98\x9a\xb3\xfd\xa1\xe54\xce\x84aC\x08d \x0e\xe0n\x0c\xd4\\\x06t\x0f\x1d\xaf8\x81\xad\xf4{>\x00~\xc8Q\xc7pG\x99\x8b\xa6\x96lv&\xc2\xbd\xb2\x93&\x7f\xbc\xd2\xbe\x0d\xeb\xb8f\xaa\x02\xad\xb4\x1e\xb9\xcd\x95?u/vL\xbc\x9c\xa0\xb8.5\x89\x13\x02X\xb1\x98l\x98\x97\xe9b\x86L*\xdb\\\xa8(-\x88\x03\xe2\xccc(\xbb\x91\xa8\xde\xb1\xfb\xd0\x9b-7\xaa\x9e4\xaf\xcb\x9cv:i\xcdbB\xb2\xac\x9bd\xfauKP\xcb\xea\xdf\xf9\x84O\xf7\x82\xc0\xe0\xb9\xb4\xaeo#\xb0\x84\xd5\xbf\x03_z2\x99\xd2\x02\xa15\x97q\xbb\x0e\xacgu\x15\xc93Y\x04\xd8Y\xfa\x9eR%\xa3\xb1\xd8\x9f\x1dE\x84\xad\x07\xe4r\xa9K\x92\x04:\xde\xc0~\x04\x9fc\xf8\xd5Z\xbe\xd25^\\\xb7V1\xc8\xd3\x99L\xbeK\xc45\x99\xcfFR|\xc3\x8cw\xebh\xec\xfe+\xdb\xfe\xeb\xc3\xe1\xe8~\xcdj\xec8\x9c\xe1Gi\xc8\xf2\x10s\x18\x03\xe7\xb6^\x1a\xbb\xa5Wv\xa1\xc6\xf6\x9f{.\x03\xca\x7fP\x85\xdc\xdf\x1f\x99\x8c C\x15<\xa7\x8a\xf32\xd0\xf6\x11|>\xa4\x82\x8cH\x83Q\x96\xd0M\xa7\xd3#ix\xe36\xa4\x84=dm0\xad\x8e\xcag\xbeK\x86c\xfb\x926i\x16\xda\xfa\n\xbb\x116\x94\x84\xcf\xdbY\xe7\x15\xbeFN\xd9\xad\x17\xe38s,s\xef\xbd\x08/.\xbd=\xd2\x9fO\xa8\xd3\xbbD\xd2v\x19n\x07\xe6\xc4\xe9\x85\x1f	\x12#\x81Q\x82\xb3A\xfb\xbe\xcf\xe2e.\x89W\xf76\x15\xc6\xdf\xca\xc6\x10\x04\xf8\x9a\x88\xa8\xe4\x00\xdd\xa6\x1d.z\x9e+\xb2\x8e\x81\n\x12\x01\xd5_\xd7\x8ea9N?\xc9\xa8\xcf\xc2\\\xa7\x92\x94\xb5db\x90\xef1\xf1\x92\xe6\xc2\xaf	2\xbeU\xd9\xe3\x92d\xbfQ9\xea\x0f\x85|\xab2\xb7\xd9\x11\x14\xccX\xecY&\x01[\xd9\xcb\xab\x0e\xd8*\x85Z\xe4\xe7\xbd\xedk{J\x81\xe4\xa6\xa6U\xa9\x0f\x9d\xe5\xfd2\xc3\x11\xaew6\x11X\xf7\xbb\xea'd\x7f\x15'\x88\xbf3W\xeb\xbeU\xdaM\xb6S\x93\xd7O\xaa\x16\xe32\xa2-\xb0}\x9f\xe6\xd0\x8d\xed\x9d\xdb+\x92\x0c\x1fq\x91\x1b\x03\x83<BmS\x1c[\xc6\xc6/\xbf#[\x17\xc5U\xf3+qn\xafh\x94>\x1b\xd0\xd9U\xf6\x1e\xce\xa9\xc0\xd0r\xa2\xe5*$\xb7)[%\xc6$=r\xc1:\"*3\x1ar\x90\xbd\xcd\\\x08k\xf7\xe8\xcfr\x7f\x9b\xc5S\xe1z\x06\xa0\xdb\xc8\x0e\xa2\xe3\xb7\x00?=\xd2\xc4\x02\xd2,\xc8\xd6\xdf\x97Q\xc1\xe9\x17\xb9Db\x80\xc7\xae\x1c\xcd\x94\x8b`\x1e\x9c6w\x8b\xc1\xc6\xc4\xc1\xe4\x12\x1a\xd4\x87b\x0c\xd5\xde\x85\xea\xf5\x91)\x14c\xdf\xf2\xc1$\x16sU\xe4\xf7\xa1?\xe5I\xac\"z\xc3\x90H,\x85\xe9X\x96\xda\xa5\xe3\xc7\xf6\x18\x01\xe5D\xe2\x1e\xb1\x93\xce\xcb<:D\x0c\xfe\x8a\\\xc3\xa20\"\x98\x8f\xcf\xde\xc4W\xeb\x1d\x99U\xe9YJ\xb2Q\x86mF\xa5\xa0\x0f\xceZ%\x82P\xbe\x17v\xc45#\xad\xe3\x15\xb3|\xf5\xcc|1\xa6\x04\xca)\xff\xd2P\x90\x91\x0f\x01Mu\x9a\xd6\xe7a\xb0k\xc9\x16\xae\x8e\xf3\xe5\x02\x95\x0c\x176lL\x08u\x96.O\xb1 l\x18#Ee\\\xf6\xea\xedak\x9fVW)d\xe3\x97\xc5\xfa \xe1q.]\xd2\xc1\x16\x86\xb0'/U\xfa\x81lPP_]\xb3\xa5\x04qY\x88\x1c\x86\x98\xf2\xaauT\x0d\\\xe2\xd6\x16\x0b\xd2\xaap-\x9e\xd1j\xef\xf0\x93\xbb#\xfe\xda\xdd\xd8\x0e\xcb\x81\xech\xea\xeb\xb4V\xcc#'t|`\x13\x9e\xc7\n\x97\xf9|\xa0D\xe7\x10\xd9e8\x06\x0bI/\xa9\xd6\xd4{\x19 )<NQ'\xd5\x14\xdb\x1f\x13\xd1\xfd;\xd7:XB1\xa7:\x83eC\xf2\x0f\x89ix\xa3\x18\xea\"\xac\xae\xa6\xab\xc9\xa9\x18\xf5\xa7\xb9\xceY\x15\xf7{\x10\xec\xed\xca7G\xe2(\xe4)d\xe5\xae\x19\x7f\xee4G\xbd\xe8I\xfc\xc6z\xc7U)\xd9=\x1d$|	hk\x90\x14\xe3r\xf0*_\x0f\xca*\x90\xa2*fw\x82\xdf\xcf\xf1|\xf3\xa3\x12\\$\xcb\xbf~\xbe\xfe\xfc\xc7\xbf^\xebQ\xfe\xf9\x8f\xff\x0e\x00\x00\xff\xffPK\x07\x08\xf9\xfe\x13#\x13\x0f\x00\x00\xe5\x13\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00r\x9b\xb2P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00#\x00	\x00img/supervised_user_circle-24px.svgUT\x05\x00\x01\xa9\xe1\xc2^D\x92\xdd\x8e\x9c0\x0c\x85_\xc5\xca\xbd\x0f\x8e\xf3G\xaae/z\xd5\x9b>D\xc5N\x87\x91\xe6gU\x10\xacx\xfa*	3+\xa1\xe4\xe3\x9c\xc4\x18\xdbo\xf3z\xa6\xaf\xdb\xf5>\x0ffZ\x96\xcf\x1f]\xb7m\x1b6\x87\xc7\xbfs\xa7\"\xd2\xcd\xeb\xd9\xd0v\xf9X\xa6\xc1\xa874\x9d.\xe7ii\xbc^N\xdb\xcf\xc7\xd7`\x84\x84\xd4\x93z\xf3\xfe\xf6\xf9g\x99\xe8c0\xbf\xadE\xce\xa4#\x07\x04%a+\xe4\xe1\xfb\xb2[\x99\x0bR\xc5\xf6\xf0!\xf0\x81\xdc\xd0\xca~s\x88\x96\"\x9c\x1f-$\x91\x90Ev\xe8c\xdd\xdbR4I\xfc\x14\xf9\xe5p\xbbR\x85b\xf3\xcbf\x88\xad\xeeS\xfd\xbe\xb9\xdf\xb8H\xa1\x1f-Jl\x85+q%6\xaaK\xf9b\x8d\xde\xde\xf9\xe5\xcc\x15\xab\xc3/g,\x198[\x82\x84\xef\xa3\x95\xf6\x9bP\x86u\xabC\n#+<#\x05\xf6p\xac\x88\x1c`={\xe4\xd8\xeeZX%\x87\x98\xd8\"f\xaan!\x84V\x04\x85\xf4\xe5G\xa0Zt\x8f\xbe\x9c\xcc\xa4\x10= \xf6\xfb\xb3722\xb4\x94\x07\xa1\xd5\x03)3\xc4\xaf\xec!\xa9&\xed\x95\x14\xd9\xb3\xc2\xba\xd2\xbf\x06G#\x14Y\xe129\xf4\x9e,l\xcd/\x159\x95\x08\x91\x02$s\x82\x0f\x95v\xd3\x1d\xf3\xf1\xf7r\xbd\x0e\xe6\xfe\xb8\x9fL\x9d\x15!\x99\xd4\xaf\xea\x7fI=T\xc6\xee\xfd\x7f\x00\x00\x00\xff\xffPK\x07\x08uq\x02\xd2d\x01\x00\x00\x9e\x02\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00r\x9b\xb2P\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0e\x00	\x00style/main.cssUT\x05\x00\x01\xa9\xe1\xc2^\xbcX[o\xdc\xbc\x11}\xf7\xaf`\xd7\xf8\x00;\x10\x15]V\xbb\x8e\xf2\xd6\x14A\x0b\xd4yH\xd0\x87>R\xd2h\xc5\x9a\"\x05\x92\xf2\xaeS\xe4\xbf\x17\xbc\xe8\xba\xbb\xb6\x9b\x87/\x88\x01\x8b\xd7\x993g\xce\x0c\xfd\x01\xfd\xf7\x06\xa1\x96\xc8\x03\xe59\x8a>\xdf \xd4\x91\xaa\xa2\xfc\xe0\xbf\xf0\x11\x8a'\xaaq-\xb8\xc6\xaa\x15B7v\x92pM	\xa3DAe\x97\xb5\xe2'\x16\xeat\xb6\xee \xc9\x8b*	\x83\xf9a\x1aN\x1a+\xfa\x130\xa9\xfe\xd3+\x9d#.\xb8]Q\x88\x93\x99\xb0[\x0b!+\x90\xb8\x10'3c\x0f\xaeIK\xd9K\x8e0\xe9:\x06X\xbd(\x0dm\x80\xfe\xca(\x7fz$\xe5\x0f\xfb\xfdUp\x1d\xa0\xcd\x0f8\x08@\xff\xfa\xc7&@\xdfE!\xb4\x08n\x10Bh\xf3w`\xcf\xa0iI\xd07\xe8a\x13 E\xb8\xc2\n$\xad\xc7{\x8cm9\x8a%\xb4f\x88Q\x0e\xb8\x01zht\x8e\xe2pkF\x7f\xdd\xdc\x84\x9d\xa4-\x91/\x16\xc2R0!st\xbb\x83m\n\x0ff>df\x83\x9d\xfc\xf8\x01\x91l\x1f\xd75\xfa\xf0qZ+\x0f\xc5]\xbc\xcb\x02\x14\xc7i\x80\x92,\xbb\xb7\xdb*\"\x9f\x86]\xb7\xdb$\xf9\xdbnw\xb6m\xb7\x0b\xd0\xd6\xec\x8c\x92{g\x8b\x85\xb4\x15\\\xa8\x8e\x94`\xf7\xcf<\x89\xc2\x87\xcc;\xb3\xc0\xf1\xc7\xd7G\xc1\x05\xfe\x0e\x87\x9e\x11\x19\xa0G\xe0L\x04\xe8QpR\x8a\x00}\x11\\	FT\x806\xff\xa4\x05H\xa2\xa9\xe0fVl<\x98_D/)H\xf4\x0d\x8e\x9b\x00M\xf7\xff\x85\xb6\x9d\x90\x9apm\xcd+D\xe5`\xaa\xa8\xea\x18y\xc9Q\xcd\xc0\x85\x95\xc1	WTBi\xce\xce\x91\x14G3L\x18=pL5\xb4*G%p\x0d\xd2\xd2\x83\x94O\x07)z^Y\x1c\xc8\x12\xbf\x00EadP\\`\x95\xa6\x01J\xf7\x01\xda\xc6v\xc6\xf0\x8d\xd6/\xb8\x14\\\x03\xd79\xb2\x06\xe3\x02\xf4\x11\x80[ko[B\xf9\xfb\xcc-\x05\xeb[~\xf1\xdc\xc9\xea#\xadt\x93\xa38\x8a\xfe0\x9f-\xe5\x13\x9b\xa2\xe8\xb9q\x97R^\x0bCv{\xf1+\xa7]\x81\xe6\xccT\x9f\xc7\xb8\x10Z\x8b6GI\x98H\xcf\\\xe5\xcc\xff\x7f]\xec\x84\xa2>J\xc0\x88\xa6\xcf6e-\xf3\xacQ9bP\xeb3\x17\x13\x7fk\x13\xafi\x99\x84\x0bV\x1e\xfd\x8em\x14\xad\x0f\x9e\xfcd\xa05Hl\xa2\xe6T*L\xbb\xd3\xb8\\K\xc2U-d\x9b\xa3\xbe\xeb@\x96D\xc1\x8a\x0fq\x1c\x05h\xb7\x0fP\x92\xfa\xe4i\xe2PS\xcd\\\xd6\\\xbeu\x12\xc5polFq\x98d\x03\x9cMr\xb6s\x00b\x90\x85\xd4\xfe{\xd3\xcc\xab\xce\xcdUi\xb8y\x85\xda.\x8b\x9c\x14\x94DV\xd6 /\xa0\x92T\xb4W\xe6\xb0a\xdfjb\xeb\xeep\xa39\x8a\xbb\x13R\x82\xd1\xca\xa5X\x14 \xff?\x8c\x13\x97]\x96\"\x07)\x8e9\x8a\xc7o\xd5H\xca\x9f\xfc\xc8XQ\x10N#w|KN\xd8g\xc2vJ\x84a\xe4\xc1\xaf\x1a\x81\xf6n\xce\xdd\xb5\xc5\xa1!\x95\xb97r\xe6\x98\x15\x91_\xb9\xb66\xda;E\xbd\xa9)\xb0J\x81\x9e\xd5\xba)+\xfc\xbdse\xb9\xad\xcb\xba\xac\xeb\xac\xfa\xedK\xdf\x82x(ws\x89^\x9a\xcaH\x01\xcc\x1a|9\xeb\xdeL[/\xa4C\x16n\x935\xbeQwr%\xfe\x8a\xa0\xbc\"A\xe7\xd9zfz\xce\x85\xbe\xcb\x19Q\x1a\x97\x0de\xd5\xfd\x9c\x91\x03\xf8\xaf0\xed\xfe\x12\x1c\xaa#N\xb4f\xcc\x89\x93l\xe5Y\x84\xe2l&	>#\xa5\xb1\xd6\x1eJ\xdbCHK/\x7f\xfe\x14\xd2k1\x87+\xdd\xcd\x036\x8613\xc459\xd6\x82R\xe4\xe0\x14c\xbc8	3\xcf\x8d\xfd\xa8\x0d\xa1u\xc1	\x1f\x83\x93O\x8f\x0b\xb6^R\xc0\xab\x15\xff\x15\xbe\x8a^\x9b~e\xe2X\xd9KeN1`\x98\xefcC5X\x89\xb1\x8b\x8e\x92tv\xdf3\xc8\x9a\x19\x967\xb4\xaa\x80\x8f\xf8M\x13\xc0\x18\xed\x14U\xcb\xd0\x84\n\x18\x94:\xcfI\xadA\xfaf\xc8Sf\xb3\xf9\xbc`0)\x94`\xbd\xb6vy\xe4?9\xf7\x07\xe0=\x1a\xd2}\x0d\xd9\xa9E\xe7\xc1\x1f\x13\xd8\x0e\xe1\x81\xd5\x82\x1anbx\x06\xae\xd5\xe0\xbb\x895\xefz=\xa7\x9e\xd2/l\x06\xce\n-[\x1b\x8d7\xeb\x80\xbdk\xf7\xd4\xe0\x92\xae\x03\"	/\xe7s\xa6E\xbe4qi\xec<\x8aC!\x89\xd3\"\xad\xe7q\xf5\xbe\xafIaKLG$\xf8\x16,4\xde\xbc\xaf\xe2{\xe9\xb0\xc3\x86\x1e9\x1aH\xf2\x8e\xe6),z\xad}r\x0dF;~\x9e\xd1v\xe8\x95\xd7\"\xbb\xedNh\xd7\x9d\x9c(dQ\x80\xcc\xcf\xa7\xd4\xeaB|\x1f\x98\xa4\xe9N(\x1dV\xcc\xd5\xf7\xe1\x1d\xe2\x1b\x9d\xa5\xdb\xde\xa5\xdb\xa8\x95\x9euWSi\x06\xf9BO\xc7\x9c\xa9\xa0\x14\xae[\x9e1\xcb#\x136\x84\xd5#\xbf|\x19\x8d>\xaf\xcb\xe84R\x10E\x8d2\x13V\xdee\xd1\x1f\x08\xdb\xbb\xee\x17g\xd6=c\xeb3\xe3\xf9\x8a\xbc1y\xec\xfa\x94\xa9\xf9\xb0\xbf2\xa2\xe1\xdfw8\xb6g\xaec\xb1\xefN(6\x01\x89.\x86\xe3\xde\xbd\x03\"\x1b\x8d\xdd\xb4l\x1d\x93_7\xa1\xa8kl	\xe1\xd2qN\x84l\x9ffE\xe6i*\x84\xc9\xe4Q\xa0G\xb6Rn\xdfa\x9a\x14\xee1\xb9\xd0\x818Y\xcaH\xe2c\xbe\x92\xf7g\x90\xe6\xe5\xc7\x86\xa2\xa0E7\x7f>\xf5\x1a\xaa\xe5k\xae\xdcg\xfbj\xf1\x9aY\xbd\xabfJo\xfa.\xec\xcc\xbf\x9cgoe\xcf\xaa\x14\x8f\xcd\xf4\xcc\xd1\xb1\x97\xb2C^%\xf1\xacGZ\xb57\x17\xa6\xcc\xb1\xcb\x89\xeb\xcd\xed<Nx\xaaF\xcb\xf8\xa6\xf3\x9c\xb3V\xbe\xddA.\x1a\x01\xe7\xc7\xf5>\xd5\xaf2\x86\x9f-\x1a\x81o\x80T\xd7\x80\x7fo\x87s\x16\x8f\xdf\x81\xde\x13\xf2O\xc6\xfd\x1d-\xd5\x12z-\xba\xb7p7K\xae\x82n3F\x8e\x7f\xe2\xb8\xd8j\xfd/\x00\x00\xff\xffPK\x07\x08L\xbb\xd3^\xd3\x05\x00\x00^\x12\x00\x00PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\x91\x11\xb9P\xaa\xa0\x16\xe1c\x04\x00\x00-\x1f\x00\x00\x16\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\x00\x00\x00\x00html/dashboard.go.htmlUT\x05\x00\x01\x92)\xcb^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\x9d\x11\xb9P\xd6L6ob\x02\x00\x00\xb2\x06\x00\x00\x12\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\xb0\x04\x00\x00html/error.go.htmlUT\x05\x00\x01\xab)\xcb^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\"\x12\xb9P`}f\xf1\xde\x00\x00\x00c\x01\x00\x00\x13\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81[\x07\x00\x00html/header.go.htmlUT\x05\x00\x01\xa1*\xcb^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00r\x9b\xb2P\x83\xba\x83\xe4\xf5\x00\x00\x00|\x01\x00\x00\x1b\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\x83\x08\x00\x00img/account_circle-24px.svgUT\x05\x00\x01\xa9\xe1\xc2^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00r\x9b\xb2P\xfc\xc6x\x8f\xb7\x00\x00\x00\xf9\x00\x00\x00\x12\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\xca	\x00\x00img/error-24px.svgUT\x05\x00\x01\xa9\xe1\xc2^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00r\x9b\xb2PK\xfe\x8b#M\x03\x00\x00d\x08\x00\x00\x10\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\xca\n\x00\x00img/pomerium.svgUT\x05\x00\x01\xa9\xe1\xc2^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00r\x9b\xb2P\xf9\xfe\x13#\x13\x0f\x00\x00\xe5\x13\x00\x00\x1a\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81^\x0e\x00\x00img/pomerium_circle_96.svgUT\x05\x00\x01\xa9\xe1\xc2^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00r\x9b\xb2Puq\x02\xd2d\x01\x00\x00\x9e\x02\x00\x00#\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\xc2\x1d\x00\x00img/supervised_user_circle-24px.svgUT\x05\x00\x01\xa9\xe1\xc2^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00r\x9b\xb2PL\xbb\xd3^\xd3\x05\x00\x00^\x12\x00\x00\x0e\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\x80\x1f\x00\x00style/main.cssUT\x05\x00\x01\xa9\xe1\xc2^PK\x05\x06\x00\x00\x00\x00	\x00	\x00\xb2\x02\x00\x00\x98%\x00\x00\x00\x00"
+	data := "PK\x03\x04\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x16\x00	\x00html/dashboard.go.htmlUT\x05\x00\x01\x0e!\xd0^\xecYMo\xe36\x10\xbd\xfbWL\x89=\xd6b\x90\xf6P,d\xa3\xc1nv\x11\xa0h\x82&{\xd8S@\x8bc\x9b\x05E\xaa$\xe580\xf4\xdf\x0bJ\xb2\xa3/\xc7\xb2c{\x83\xa2\xba\xc4\"9\xc3\x99\xf7H>f\xb4Zq\x9c\n\x85@8\xb3\xf3\x89f\x86\x07s\x17K\x92e\x83\xf0\xa7\xcf\xb7\x9f\x1e\xbe\xdf]\x83o\x19\x0fB\xff\x07$S\xb3\x11AE \x9a3c\xd1\x8dH\xea\xa6\xc3\xdf\xc8x\x00\x10\xce\x91q\xff\x03 t\xc2I\x1c\xdf\xe9\x18\x8dH\xe3\x90\x16\xefy\xdfj\xe50N$s\x08\xc4[\xa0\xd9L\n\x10R\xdf4\x1e\xf8\x9f\x13\xcd\x9fKw\\,@\xf0\x11\x89\x99P\xf9\\\xb5V\xa1\xa6z8\xd1\xcbMO\xd9\x17If\xed\x88D\xcc\xf0JW\xbbs\xe8\xe7DS\x1b\xe3\xd3\xb9\x1c\x7fJ\x8dA\xe5 \xb5hB:\xbf\xac\x8fX\xad\xc4\x14\x82{\xb4Vh\x15\xdc\x89\xc8\xa5\x06!\xcf\xe3\xe5	E<[G\"\"\xad\x08X\x13\x8d\xc8j\xd54\xcc2\x02LzD-\x1a\x101\x9b!\x01\xda\x9c\x11\xa5\xc5\x8e\x19j\x0dP\x9b\xaf\xd1U\xce\xce\x99c\xdf\xfe\xfa\x03\x08\x0d\x92\x92%\xca\xacEg\xa9\x88g\x94E\x91N\x95{\x8c\x84\x89$\x0e/\x7fM\x96\x81]\xccH\x965\xfd-c\xa9\xec\x88\xcc\x9dK>R\xfa\xf4\xf4\x14<\xfd\x12h3\xa3\x97\x17\x17\x17\xd4\xdb\xd4\x0c\xda	)^\xcb'\xa4\\,\xc6\x83j\xcbT\x9b\x18bts\xcdG\xe4\xee\xf6\xfe\x81\x00\x8b\x9c\xd0jT\x8d\xde\x8a\x99z\xd4\xa9k\xd2h1\x1f[o\x05\x08\x935J1Z\xeb\xc1\x1e\x7f\xd7\xa9\x81\xa8\xa4\xdc\x16\xb4\x02G\xc7\x84\xb4AH\x93\x96\x8b\xa9@\xc9-\xbafGsi\xfc\xc9\xe2&i\xfe	%\x9b\xa0l\x1b\x03\x846aj\xec\xcdB\x9a\xff\xec\x1a#T\x92\xba\x8e\x0e\x00\xf7\x9c\xe0\x888\\\xba&[\xc5S&\x9e\x87\xdf=b\xc1d\x8a\xb5U\xea\x83i\xb3_<\xf9\xee\xee=\x9a\x0b\xcb&\x12yGgcu\xf8'\xa4[PZ\xef\x85\x06\xd6_\xc5\x02\xd5\x81\x80\xe7\xb6\xf0\xae`\xaf\xa4\xd3\x17\xfb\x9d&\xc7#@\xf1\x16\xfe_X,\xe4\xf3\x81\x04\x14\xc6\xef\x8b\x81jB}\x97\xffn\x9b\xa3s\xd0\xc5\xc5}:\xf9\x1b#w\xc0\xd1\xf3\xcd\xa2\xb9\xf9\xfcn8\xd8$\xd2\x97\x80\x1d\x06'\xdd\x01\xd71\x13\xf2\x00\xccs\xbb7@\x8e\xde\xfex'O\x99F_\xc4_\x1d~R\xbc\xfdZ=p\x89\xbf\x9b\x05\xee\x83\xe9\x8f\xf5k\xa3\x8f\x0e\xb5aj\x86\xf0A\xfc\xfc\xe1\xf1\xe3\xe8\x05\xf5\xafF\xa7\x89\xdd\x0b\xf7\xfcp\xc2\x7f\xe0\x83\x80\x8b,\xdb*\x02\xb9\xe7\xed\xcct\xde\x81K\xd2r\xd1~\xd5\xb4q\xdb<3\xdd\xbb)>\x1b\xadU\x9d\xb8^&\xc2<\x1f\xa0\xd7\x85\xe1v\xc0\xcf\xbc\x8b\x8ap\x82\x07\xb1\x8fV\xf70:\xe9\xf1ucm\x8a\xfc\xea\x10\x95.L\xdf\x0d\xfc\xebL\xf6$\xa0\x97\xd9\xe9)8DC\n\xc3\x1fM@;\x91\x1d\xd0\xf758:\xe8\x1b-\x81\xba\x98\\\xa5\\\xa0\x8a\xf6\xfb'\xb9\xa7\x9c\xac}\xff\x97\x14e\xb3\x89 \x80,\xdb\xb9<\xe0l\x04WU\xe5&N\xd0X\xad\x98\xc3\xf2r\xb8\xb7\xbe\xbc\xb8\x10j\x06o\xbd \x1f\xf9\xb4k\xa5w&\x8c\xb7\\\xc8*\xf1\x9c\xeen\xf62\x89'\xe4\xff\x9b\xda\xc9oj\x8d0C\xda]s\x0cig\x99\xb3Z\xe9\x9eJ\xacV\xc8\xd7;\x17\x82\xc8\x9a\xe9\x17\xef\xb5Y\xba\xf65\xf8\xd49\xad\xd6u\x93\xf2m\x9aJI\xcaS\xcc\xa6\x93X82\xbe\x173\x05\xb7\xa9\x0bi1\xa8\x19^^\xd4}i\n\xa9/\xea\x8e\x07\x9d\x03\xca\x97\xf3\x95\xf9}\xf0C\xa1\x86\xcc\xb6\x8b\xfc\xa7\xa8\xae\xdb4A\xb3\x10\x16\xf9\xa3/\xf6\x9f\xb8\xca^\x87s\x8f\x8a:\xe3\xb1PTl\xb6<\x1e^Zo\xa4\x03p\xe5}\x0b\xeb\x0cs\xdaX\x88\x98\x02\xffYH\x1bf|\x05\xb02)0\xa5\xdd\x1cM\xfe\x11&h8\xda\xaf*\xbfKn\x0e\x17\x18\xc5\xe2Rk+\xc7p\xeen\xab\xf6\x1e\xafh\xd3m\x9eH\x16\xe1\\K\x8e\xa6\xf8\xa6\xf4;.Y\x9cH\x0c\"\x1d\x937\x16\xdfw!\xb9C\x19\xf6E2wgw@y\x84\x83\xbf\x07\x92\xa8fB!\x1a\xa1\x1a_\xb6\xf6\x05\xf1\xc7\x1c\xe5\x83^+\xf7*\xdf\xd7\x9dx\x97\x12i\xb1Cd;d\xa25\xa6&\x1b\x8d\xde\xf6R\xa9\xc4\xd4\xe8;\xa2\xd0T4\xc7\xb7\x15\xdf\x9dC\xea\xbfJ\x8f\x07\xab\x15*\x9ee\x83\x7f\x07\x00PK\x07\x08\xaa\xa0\x16\xe1t\x04\x00\x00-\x1f\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00C@N]\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x12\x00	\x00html/error.go.htmlUT\x05\x00\x01\xfe6\xcfj\x9cUMo\xdc6\x10\xbd\xef\xaf\x98\xf2\x9c\x15\x0d\xb7(\xda\x82\xab\x1e\xec\x1c\x02\x04m\xe0$\x05z\n\xb8\xe4H\" \x91\n9\xda\x0f\x08\xfc\xef\x05\xb5Z[\x1f\xb6\x9b\xe4$rf8\xef\xf1\x91|\xea{\x8d\x85\xb1\x08\x0c\xbdw>\xab\xa8\xa9Y\x8c\x1b\xf1\xd3\xfd\xdfw\x9f\xfe\xfd\xf0\x16R$\xdf\x88\xf4\x81Z\xdar\xc7\xd02P\x95\xf4\x01i\xc7:*\xb6\xbf\xb1|\x03 *\x94:\x0d\x00\x04\x19\xaa1\xef\xfb\xec#I\xeaB\x8c\xb0\x85\xc7\xd9'<Q\x8c\x82_\x8a\x86\x05}O\xd8\xb4\xb5$\x04\x96\xda\xe0\x13\x13\x00\xc1\xaf\x9d\xc5\xde\xe9\xf3\x08\xa1\xcd\x01\x8c\xde\xb1F\x1a;\xe0\xcf\xa2\xc6\x16n\xbbw\xa7\xc7\xcc\x98S\xb5\x0ca\xc7\x94\xf4z\x92Z'\xb7	\x12\xfd\xac\x06@\x98\xa6\x9c\x05\xe0\xda\xd0(g\xd9\"\x15\xbc\xda\xb1\xbe\xd7\x92\xe4\xe7\x87\xf7\xc0x\xd6\xba\x06\xbd\xe9\x1a.C@\n\xdc4%\x1ft\xdf\xde\xfe\xd2\x9e\xb2p(Y\x8c\xcb6\xa7\xa6\xb6a\xc7*\xa2\xf6\x0f\xce\x8f\xc7cv\xfc9s\xbe\xe4\xb7777<\xad\x99-\xe0\x0b\xca\xd5m\xbeR\xbe\xba}\xa5h] \xb86\x87\xe9\n\x11P\x91qv\xd1e\xa2a\x83!\xc8\x12\x17\xfa\xcdu&<\xd1\xb6q\xd6\x85V*d\x89\xe6\xdb$F\x8c+\xc0g8\xa4Kc\n\xc8\xee\xa4\xbd\xc7}W\xc6\xb8y	\xe8%2\xef\n8\xbb\x0eB\xe5\xbaZC%\x0f\x08R)\x0c\xe1\x0d(gI*Jy\x0fR7\xc6\x9a@^\x92\xf3 \xad\x86\xd6\xbb\x83\xd18C\x04\xa0\n\x1b8\x1a\xaa\x86e\x8b\xa4\x90Py,v\xd3k\xc0r\x8f_;\x0c\x04\x1aI\x9a:\x08.\xf3\xec\xb9}\xcfb}\x8fV\xc78\n\xf0\xb1k[\xe7\xe9\xf3\xc3\xfb\x1f\x90\xe0/D\x0d\x15\xd6\xed\x9fO\x04\xfb~\xd6\x93\xe5w\xa3\x18\xe1\x82\x94H^\xa0\x1f.\xec\xdf\xdd/\x90a\x10\xe9k\xe7\x08\x93(\xe0\xb1@\x8fV!(\xb7RM\x84V\xdaW\xee\xc4\x04D\xf0T\x9b\x8f\xfb\x7fV\xa8ih\xaaS\xf6\x80\xe4\xcf?&\xd2xO\xf6X\x1b<\\v4\xbcZ0\x01\x92o9/\xfd\xf9M:tP\xd2\xce\xda\xc3L\xd6'\x0e\xe9\xe4\xc9\x9f\x93\x94\xa3B\x83\x92\xdf\xba\xa5IL\xf0g\x1e\xe3\xf4\x99%\xaf\xdb\x16\xce\xd1\xda\xce\xae\xd4\x92\xb7\x84\xd1\\\xae\x1e\x95\x19\xb7~\xbdk\xff\xfbV\x9b\xbb\xc6\xbe(\xe3U\x8d_~\xff\xf5\x05\xbb\xfbn\xc3\xfb\x1f\x17^\xfa!\x97\x8b\xd7\xb4\xb2$o\xca\x8a`\x186\x1d\xa1\x86\xd0\xc8\xba^\x891\xbf\x9a \xf6~\x89\x05\xf0a\xdcu*\xfe\x07}0\xce\xc6\xf8\xfa\x19/\x0e}6\x9dL\x1e\x87\x82_~\x8a\x82\xa7_t\xbe\xe9{\xb4:\xc6\xcd\x7f\x03\x00PK\x07\x08Z\xe3\xce2\xb7\x02\x00\x00\xda\x07\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x13\x00	\x00html/header.go.htmlUT\x05\x00\x01\x0e!\xd0^\x8c\xcd1k\xc30\x14\x04\xe0]\xbfBhN,Z:F\xfd\x05\x9d\n\x9d\xcb\xab|\xb1\x1f\x95\x9e\x8d\xf4\x9c8\x18\xfd\xf7\x12C\xc9\xd0\xa5\xe3\x1d\xdc}\xdb\xd6\xe3\xcc\x02\xebFP\x8f\xd2\x8d\x9a\x93k\xcd\x9c2\x94\x8c\xb5B\x19\xc1]\x18\xd7y*\xea\x8c\xb5q\x12\x85hpW\xeeu\x0c=.\x1cq\xdc\xc3\xc1\xb2\xb02\xa5c\x8d\x94\x10\x9e\x0e6\xd3\xcay\xc9\x8fb\xa9({\xa2\xaf\x84 \x933\xfe\xd5\x9c\x12\xcb\xb7\xb1\xb6 \x05W\xf5\x96PG`\xe7\xf46#8\xc5\xaa>\xd6zo\xc6\x82sp\xdb\xd6\x93\xd2\xc7\xfb\x9bu\xbe\x9b\xa7\x8c\xc2K\xf6T+\xb4\xfa\xfd\xc2gb\xe9\xee\xa3\xd6\xfe*\x1c'y\xfcs\xa6\x01~\x96\xe1\x9f\x00\xe7\xc1S\x8c\xd3\"\xfa\x19\xb9\xc4\x84\xe3\xf3\xcb\xbcv\xf52\xfcj\xdb\x06\xe9[3?\x03\x00PK\x07\x08`}f\xf1\xdb\x00\x00\x00c\x01\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x1b\x00	\x00img/account_circle-24px.svgUT\x05\x00\x01\x0e!\xd0^<\x90\xcdn\x83@\x0c\x84_e\xb4=\xdb\xeb\xb5\x17\x02U\xc8\xa1\xbd\xf4\xd2S\x9f\xa0J( \xe5O\x05A\x94\xa7\xaf\x9c\xa0J{\xf8<\xe3\xb14\xbb\x1d\xe7\x0e\xb7\xd3\xf1<6\xa1\x9f\xa6\xebk\x8c\xcb\xb2\xf0b|\xf9\xed\xa2\x8aH\x1c\xe7.`\x19\x0eS\xdf\x04\xcd\x01};t\xfd\xf4\xe4yh\x97\xb7\xcb\xad	\x02\x81fh\x0e\xbb\xed\xf5{\xea\xf13\x1c\x8fMx)\xdblm\x15ph\xc2gR\xe8{\xc9\xb9\x82B\xb1B\xd21;%\xf9\x7f\xb4\n\x94\xe4+m\xb8\xf0m\xcf\xdeO\x02\xdb'.K\x08\x0c\x89-\xc3`#=\x89\x0cF\xf6\x18\xc8\x87'\xb8\xe6\xc1\x94Y\xf7\xa4\\\xc0\xefo\x12%\xd6\x8aJ2Ve\xf1X]#\x93\xb1TpU*<$A\xc1\xf5\x06\x89\xa5F	\xb7=Y\xbb\xe9\xdb\x05\xfc\x00\xb9\xa3z\x0fqm\xefu\x05\xd2k\x9e5\x7f\xc8=\xac\xffq\xbe\x9c\xdb\x10w\xdb8\xce\xdd\xeeo\x00PK\x07\x08\x83\xba\x83\xe4\xf6\x00\x00\x00|\x01\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x12\x00	\x00img/error-24px.svgUT\x05\x00\x01\x0e!\xd0^<\x8b\xcdj\xc30\x10\x06_e\xd9\x9ee\xad\xbe(I)\x96\x0f\xed\xa5\x97\x9e\n\xbd\x17\xe2j\x0d\xfe	\x95\x90\x82\x9f\xbe81]\xf60|\xcc\xb4\xa9D\xbaM\xe3\x9c\x02k\xce\xd7\x17kk\xadM=4\xcbo\xb4\x10\x11\x9bJd\xaa\xc3%k`x&\xed\x87\xa8\xf9\xc1e\xe8\xeb\xebr\x0b,$\x04O\xf0\xdc\xb5\xd7\xef\xact	\xfc!$\n_\xe0\xdfee\xfa\x19\xc61\xf0\xbc\xcc=\xdb]zLO\x87\xfb\xf1\xbdq \xbc\x9d\x1a\xffL \xd0\x0e\x0e\xc9o\xe4\xe4\xff\xcd>\x18'\x9f\xee\xdc\x1c7{k\xd7\xc9\x91;\xaaA1P\x14\xac\x93\x18\xaf\x06_gE9\xadl\xbb\xd6\xa6\x12\xbb\xbf\x01\x00PK\x07\x08\xfc\xc6x\x8f\xb5\x00\x00\x00\xf9\x00\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x10\x00	\x00img/pomerium.svgUT\x05\x00\x01\x0e!\xd0^\xc4U\xcd\x8e\xe3F\x0f|\x15\xc2\xdf\xe5\xcb\xa1\xcb\"\xd9\xbf\xc1x\x0ey\x13\xc1\xeb\xb1\x16\xb0g\x16cG\xb3\xf0\xd3\x07\xd5\x92gw\xb1;9%\xc8\x85j\x15\xd9-v\x15I=\\\xe6\xa3\xcc\x9f\x0fo\x7f\xbc|\xddm\x06\x19D\xbd\x89\x0f\x1b\xf9z>=_v\x9b\xe9z\xfd\xf2\xfbv\xfb\xf6\xf6\x867\xc7\xcb\xebqk\xc30l/\xf3q\xf3\xf8p\x94\xeb\xeb\xf8|yzy=\xef6\xd7\xd7\xf1\xf9r\x1a\xaf\x87\xff\x87$!!\xfd\xb6y|\xf82^'\xf9\xb4\xdb\x9cu@\x96\x844\xa9\x15\xd4\xbdch2HB\x16\x93\x84,\x11\xdefS\x98\xed\x071D\x0b\x86\xa4\x12\xe1-$d\x89\xf06\x05\xb5\x82\xba\x0f\xcbn\xe2\xc1\xba\xed\xbb\xc3\xba=\x18\xa2	\xb7w\\\xee\x01\xb7\x8d<}>\x9dv\x9b\xff\xe5C\xf4C]^\xc3\xeb\x9f\xa7\xc3ns\x98\x0f\xcf/\x9f>m\xb6\xbc\xd5\x1a\xf6\xf4\xf4\xf4\xfd\x15JB\x14\xcb\xc86\x05E\xcc'\x85z0\x94\x16\x0c\x96\x98H\x9b\x14)\x9e\x94	8j\x16E\xf4\xe0\xa8\xa5;ng\x06)\x13\x8c\xe3\x9ae4\xe9\xb4KP\xa4\x80\x94fx\xe1\x07\x92\xce\xa1\"F\xee\xd4\xd9\x19_\xc9L]\xe3\xd7\xf0\xbd\"\x112d~\xce\x18\x93\xb3\x90\xa2KP\x0c\x95\xcb\x1a\xee`\xbd\x9d\x03\xdcBD\xad\xa3\"W\xe9\x86G\x0eLA+\x92\xcf\x86d\xf4&z\xd3\xea]\x9cq\x8fJD\x11c@\xf1e\xa1\xa8\x97\x80\xd4\x82\x92\x8e\x15*\xedv\x0e\x15U\"\x9c\xf9;s\xf3{\xfe\xbc\"R\xda\xf3Y\x84\xaa\xe5\x1c\x14V\x96U\xbf\x80bh}%\xef\x18y\x88\xe4!\xae\xe7,\xc7\xcc\xc1I\x94\xcd\x151u\xfa\xecv\x1eH=\xef\xc1,\xb3\x7fw\xcb\x12\x90|\x1f(\xd1\xc0t\x13\x8a\xf7\xa7\xf0\x02\x17\xa4&\nr\xd3\x91~\x06\x8f\xf8\xc6\x04\x0f\xa0\x9e\x8c\xcb\xdd\xdfW\xed=\xa2on\xe2Hi1wG\xc9\x01)\xcf\n\xcbc$\x81\xdd\xac\x9c\x98 fqy\x07X=\xeab\xa8e1K\xa0\xa1V\x12CO\xce\x8b\xb9{R\x13\x97\x84\xb4\x9a\x05\x1e\x04)\xdf\xce\n'\xbf\x1eG\xa5\x12z\x97\xa3k\xef\x85@\x9e\x0c\xa9\x8c\xbd\x94\xba\xf9\xc6\x1a\xe5\xf5\xdc\xab\xd8#\x9a\xcf\x8e\xd6\xb9\x9eCB\xaf}\x9d\x91\xdahr/jR\x1aH\xcb\x8f\xc5\xa4\x82\xd80\x90\x05O\xa3\xa2R\xa0\xfa.\x10\x92\x07\x0c\xcc\xa6\xae\xe6=\x87H\x02o\xe7\x90AO\xd4_0_\xf2\x7fF}\xfe\x97\x987\xbf3\x9f\x91\x8bDT\x02\x16\xd8e&\x91\x1a\xf8\xa9\xcf\xc2E\x05\x8e\xa6&Q\xf8\x08\x9c ^~\x82\x92\x9e\x02g/\x9b\xb4\xc1\x1c\xca\x99\xd4\xd8[\xed\xde[\x9d\x83\xa1\xad\x05\xa9\xa2\x92\xa9\xcb ?\x07\x12'_\xec\xba\x88fl\xae\xba4WCI\x94\x8e\x1dR\xfd\x82\xcc\xba\xe8\x92\xaf\x10{\xd8CW4\xf1b\xa5\x05dn\xe9\xd7\xecP\xf5.z6n\xed\xf3\xd1\xf3lh\xcael\x1c\x95C\x99\xd8!\xa3\xf1\x03\xdd03\xda\x04\xe3\xd83\x0b\x9d*\x8d\xb3\xa1\xea\xc4\xd5\x0fj0\x96\x83\xa8\xa4\xdbG?\x87o?47\x14\x15\x8dh\xa3\xa3\xb9t\xb3\xd6)\xd74S\xf0\x08\xfd \x80\x80\xcd\x9a\xa1q\xe2Ys\x88H:&\xb2\xd2\xcd\x92\xbe\xaa\x0c3=\x93\xfd\x13\x11\x88\xe5v\x0e\xde\xe0Q\"~}\xd8\xed\xac\x86\x96d\xf8\x1bo\xfc\xc8\xcb_\xe8\xf6\xf8\xf8\xb0=>>l/\xf3\xf1\xf1\xaf\x01\x00PK\x07\x08K\xfe\x8b#h\x03\x00\x00d\x08\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x1a\x00	\x00img/pomerium_circle_96.svgUT\x05\x00\x01\x0e!\xd0^\x04\xc0E\xb2\xacj\x82\x00\xe0y\xad\xe2\xc4\x99\xd2}\x81\xc4_\xd7\xad\x88\x1f\x12\xf7\xc4sR\x81\xbb;\xab\xef\xef\xdf\xebQ\xfe\\}7\xac\x7f\x7f\xabm\x9b\xfe\x81\xe1\xf3<\xff\x9c\xd8\x9fq)\xe1\x17\x82 \xf0z\x94\xbf?W\xdf\x0d\xeb?WW\x0f\xed\xdf\xdfj\xdb\xa6\x7f`\xf8<\xcf?'\xf6g\\J\x18e\x18\x06\xbe\xbazh\x7f\x7f\xce:\xdb\xaa\xbf\xbf\x0c\xf9\x07\xc1~\x7f\xaa\xbc.\xab\xed\xef/C\xfeA\xb0\xdf\x9f\xa3\xceOv\xbc\xfe\xfe\"?\xc8\x0f\x83 ?\x0c\x82\xfc\xfe\xe7_??\xff\xae\xfb\xb8\xcc\x7f\xea\xec\xef\xaf\x9f\xa7\xdb\xb8\xfc\xd7\xe9\xe3e\xfb\xaf\x994y\xba\xfd\xfed\xf1\x16\xff\xef\x10\xf7\xf9\xdf_?O\xb7q\xf9q\xfax\xd9~\xcc\xa4\xc9\xd3\xed\xf7\xe7\xac\xb3\xad\xfa\xfb\xcb \xc8\xefO\x95\xd7e\xb5\xfd\xfde\x10\xe4\xf7\xe7\xea\xea\xa1\xfd\xa7Z\xf2\xe2\xefo\x16o\xf1?u_\xc2\xd3P\xfe_\x12\xaf9\x89\xffO\xed\xb3\xe6\xe7DT\xb1\x1c\x01\x00\xc0p\xbc\x8a\xf7J\x00\x80\x08\x00\x00l\xc9\x81\x08\x00\xf0\xae{)\xc5\x01\x00\x06\xc9w\xbc\xed\x7f\xf0\xc1|e\\\xc8z\xfe'\x16\x0f\xcc\xe6\x1c\x8e[\xed\x11T\x94l\xe3#\xd7\x94\xe1HR\xcd^\xea\xe4R%\xb6\xda6\xfa\xe3\x0b{\xf4$&Gv\xe8^\x97\x9d<\x9e\xf5\x94\xa8\xbb\xce\x8f3	F\xb91\xb9u\xecK\x0e\x88\xc2\xaa\xb7\xbd\xdd\xca\x1cg\x8b7\x93\xb9S\xfel\x04\x19\xbaX@\x1d\xaeS\xcc\x07\xd9N\xfe\xc4@\x0cAP\x0f\xb5`\x03l\xe64\x84j\xe2\xdb\n\xdcQ,\xa5\x01h\x08\x9b\x8cl\x11\x81Uf\x8d\x11\xbcl\xf0$\xe1(x\xe0J\xd9\x12\x07\xe6)n%O\x95\x1f\x0c\xe4\x07\xca\x9c5\xbf\x95\xb6\xedT>\xce\x85*\xf0-\xc4^^\xf5\x12\x7f\xe0!\xf3\x02`\xac\x80\x88\xb8\xe14	=\x1b\x02C\xf8`\x8fY\xf2\x0fHw\xc0\xe3/\xc4Q\xc2Y\xf6 v\x8e\xde\xc3\xc9Sr\xe7\x1e\x98o\x87g\x8csZ)\xec\xa0\x1b\xfd\x0f\xcd\x89\x020\x1f\xe0\xf4\xe0\xb0\x1a,i\x8f\xe7x)H\x95\xbe\xe13\xdc\x1d\xf0\xee8bd\x8dS/\x00C\x83\x08\xd2\xbeyr\xea)\x8b\x9dCBi\xcf\xc8\xb8\x03\x82\x9fo\xa94^\xc0M\x85\x916\xa3\xd7\x0b\xe88\x7f\x94\xec\x00fW\xdaodk\x01j!\x8dV\xe2]\x85	w\xf9\x9eN\xf9\x00\xce\x8e\xf9\x86\xe0\xca\x8dP\xb7\xcd\x97\xff\xd6\x8f\xc4\x9f%\x07\xa3\xbe\x85\x0fU\x8d\x84>\x84\xbb\x11<}g\x98b\x98\x11<\xa7X\x80\x0da\x92&\xfd l\x1c\xb1\xcb3M\xce\x95\x8b1\x97\x85\xfbZ\xd0j\xcd|\xf4d;\x85\xb9\xec\xae\xf4\xce{\x1e\xd4\xfe\xf4\x88p\x15'W\xfe.m4o\x8c~\x03\x0eeS{p``q\x80\"\xee_\xf8\x85&\xed\x07\x8bp\xb0l\xf6\xfbz\xef\x91\xf3N\xac\xf4\x02\x97\xd9\x859\xd1T\xb7\xeb\xb1\x00\xcbB\x87~[ \x89x\xc1\xa1\xc3\xf0\x14\x06\x8e\xc1\xa2c\x15k \x05\xa6\xa2\x1fu\xcc\x92a<	\xcd*\xc6\xea\x8b`8\xa7\xc61\x064h\x19E\x9cd\xebqG{p\x8d\xa4/\x8d\xb8\xd0\xe8\x0c\xebLo\x17 :_\x08\xa2\x08\x8f\xfd\xf2/\xca\";\xfcE5\x1fn\xc8\xdao\xf9V\xc4\x14\xda$\xb8M\xd8\x8c\n\xf2W\xc6\xf7\xecP~<\x94Sr^o{\x94z\xc8\x96\xbdL/\xedQ\x87\x8e\xf1\xc76\x0b\xee\xac\xc5\x18\xc0\xb7\x0b\xbd\xd8Q\x9f\xda\xc81\x14\x99]\xfa\xabT\xd3\x0f\xff\xb9\x8aR\x83\x84\xb6\x11\x1d\xe0xh\xe0'\xd2{\x97\xc0V=\x16\xa0p)\x7f\x87\x8e]G\xb7\x9eJ\xdb\xbc\xeb\xea\xba\xb9K\x85\x83\x06\x9a\xd7x\xbb>\x1f\x8c\xb0VhT\x8f\xabf\xde1\"\n\xaf\xee\x0b9\xe3\x81\xe7\x18\xc8\xbb\xea\x16\xd1>X+\xfe\xca\xa0\x99\xab%a\xef\xce\xa8IQwZ\xb1\x90\xcd\x99s=\xb5\xa2\x12\xe6R;\xb6M\x89c\xb6\xf6\x85V\xa3\xc3\xaa;\xe5\x92\x86\xa4G \xed\xae-\xbb\xe3\"\xeb\xb9\\^\x14\xae\xa5\xdf\x84\x9dAb3|6\xa1^\x1b_e\xb1\x1bl\x03v;F\x91:\xcc\xd5.\xf9\xd2s\x08F	\xc7\xb32\x13\xad\x13\xaf\xcf\x1c{\x9a+\xcf\xdc(\x9d\x1b\xdc\xca\xde\xd5M\xd8A ,\xb3\xd0\x93\x95F\x05\xcapo~\xeei\xf7\xc8\xb0\x8e\xc3\xd3\xa6\x1aN\xaa\xcf\x9cd\x8c\x9d\xe4\xdb\xc6\x83Y\x86\xd0\xc6\x0bm\xc9S\x91W	\xee\xbbE\x83\xe2x%\xa3\xb9P\xa3\xf3T\xdb1\xef\x82P\xe1Fw\"\xb8u\xd2Xr\xbe\\\x03\x18^\x1b\x86\xb2\x1a\x93d\xa6\x05]M\xec\x8b\x9c\xbe\xab\x108\xc8^\x06(\xfc`W\x01\x81\x06>JE}#\xda\xd9e\xe6\xa9\x06\xb6\x95\xf6\xb9:H\x8c+\x86\x18\x9e\xbe\x06\x07$\x07\xe6\x85dn\x1f\xc1e\x0d\xd5\x14\x06\x831\x0eg\xcf\xb0\xc4\x88e\xbb}6oD\x9a\xeb\xa5b\xa5\x1d\xc5F\xa1S\xb5*\x0b{\xc1\x87\"\xb5.\xd8\x8a\x1e\x96\xe1f\xa0\x17\x10\x1d\x18d(\x03RG\xf4:D\x98\xd5\xe53r	jU\xbb\x88\x9dT\xdb$`0\xf0\xbd?i\x01\x8b\x8eR4;r6V\xbf{6\x877\xf7\xf3\xdew$#T\xe1\xfb\xd9Y\xa6?\xcaz\xb1F\xb3\x06({\x85:v\x12u\x06\x1e\xc2*\xd7N8\xd3\xcf\xc7\xd9}/\x11%\xf9\xa3)\xa3\x18\xe6\xe7\xd7\xe6\x16Q9m#\x95\xfbL\xc5!\xdf\xdeR\x08	\xa1\x87\xe1\xcd(gS\x8f\x1a\xf5\xfd\xf8\x06\x98\xcc\x91KHC%\xa6\x06'\xd4\x98xZ>\xa9\x91\x89\xb4\xcb\x0c\xed\xb5\xd1JM~9\x12\xfb\xce8a\xc2\xfaa.\xb0\xc4\xad\xac\xf85K\xce{\xf0/\x19\xe2\xe9\xb9<\xc6A\x17eKggA\xd3\x95\xd12\xddGd\xc4\xf6;\xdfcI\xa1[\xb4\x9eUP\x80\xd3r#\xa1\xe7\x03\xea\xc3SG\xc2\x8c\xae\xdd\xe5\x96\x9f\xd3`\xcaf\xdc\x93c\x8b\x80f\x8dVH\"\xbc\xf7\x16f\x0d\xf9\xe9\x8c\xb4E\xe1\x8a\x8ekp\xe7\x94rGF\xd4Bf:\xb6'\xc5\xd0VYD\xa6\x94 bjgf\xdfQ\xc349\xcc\xec\x05Y0k\x81m\x95\xd1x\xe7\xe6\x19\x13\xceW<\xe1\xb9\xa3\xad\xa2P\x1dz\xcf\xc9\xf2\xfe\xce\x13\xfb\xe9z\xd1<r\xde\x9a\x0b \\0\x90o\xbd\xce\x9f\x915\xf3g\x03\x91\xfc  \xb6\xeb\xd0$\xb1x_\xe4\xd2.\xf7\xa7x\xafT\xf6\x9e b^\"\xba\xdd\x84\xbb@\xcb\xeb\xaeV\x95\xcb^\x12I\x13\x12\x95\x1f\xc8'xG\x16[\xde\xef\xcep\xe53\x82\x11\xa5x\xbd\xa8\xf3i>\xf3\xda\xe9\xeciJ\x11;|ZIC\xf7\xc5ln\xa7a8]\xe4m\x83\xa1\xaf\x9e\x86f\xc0\x84\xdf\n\xa0\x13\xbb\x0e8\x01\x05\xcf4\xcb\xe5\x93\xe7\xbc\xbd\xe9\x89\xaa\xf8\xfeW\x84I\x0cG$m`\xf4\x99\x8ds\xd3\xa3=\xf5\xcamL\x1f\xca\x8aq\xd1\x18\xbe \xe7ctB\x7f\xbd\x08;-\x9b<\x7f\x16:a\x08F<4\xcc6\xd3\\8.\xec@\x95\x13\xa3\xf93y\x945\x10	\x9f'\xbeg_\xd5\xe5\x80\xe6[\xe2\xd7\x155\xb9\xfb+\x8b\xde<*\xce\x89\xe8\xd6n\xf8\xd0\xf9\x86\xc1\x0f\x86\n\xa2\xd7\xd3\n\x05\x0b\xc4\xd9w\x14y\xad\xc1\x9b/\x8ac\xbe\x98\x03\xe6\xbf\xc5u\x0b\x83{$\xb7\x81\xd3\xf3\xda\xb9Db\x9a1\x13\x1dz\x14\xe7G\x83\xd8\x0c\x97GV8\xc4\x1dwc\xdb\xce>\x8ak\x06\xc1\x94Y\xc3\xdc\x12\xadv9\x83\x12\xdaj\xba\x12y\xf2-\xc3\xf2\xba\xec\xac\xca\xbdJ\xcc\x99.Es\\!\x8b$n\xd1\xb6\xbc\x99\xdd\x9a/\x17\xc5+\x89\x84\xa8\xbb\x1d\x9a\xfc\xa8\xb6d\xeb\x12\xadM\x96\xf0i#4.\x97}\x8b_G\xfc\xa2x\xf6]e\x80\xbe\x92\xd7\x8b<\xbc\xe2\xe8\xf3%\xf1;Ly\x0d\x98\x08\x06}]D\xcd\xa4\x10\xe6\x08r1\xe6\x8e]\xe5\x99#\xa8\x140\xf5FH\xae\xc6\xb2\xdf#\x9co]\xa9\xedZ\xa7\xcb\xe2\x10\xef\xd0\x8b\x8a\xccm'Bm\"\x18\xe5\xd4Un\xf3\xb2\x18qs\xb1\xa9l\x96\x1b\x12\xc9\xb5G\xbd\xf29l\xc8Nh\x0f\xcd\xa5d\xd8\xebq\x99M\x9b\x96\xdb@\xaelT\x9cn(\xb1m\x17B\x88~\x83\xf55\xee\x96\xe5q\xb99u\xb7\xf4\xc6a\xe9\xf9\xeaA\x93m}\xfc\x08\xccs\xeb\xc2\xe5\x8fV\xe4)\x04\xdb%9 \xc2\xbe\\K\xf9D\x16z2\xbf\xfc\xcb\x10\x1a\x9e,\x8b\xa6,\x82KP\xcd\xf8\x14\xb1A\x12\xcd\x16Zi\xb5RDU\x15\x1a\xea\xdd:\xf4U\x85\x95\xd46\xf4\xa8\x15\xf1.\xa0\xfd=\xf1\x98\x85\xcc,\x84~)\x9e\x97\x04\x8e\x92\x8cP\x85\x8a79\x06g\x92\xa0No5\xeeY\xeb\x86Y\xa6\xbb\x14\xf7\xb4\xf4\xec\xb6\xe1\xd7\xb2v\xf6\xf2\xa5\xe6\xa1\xe5\xa5\xf4\x82\x91o\xa1\xa0\x9fK\x17\x1e*X\xc3\x93\xc9\x0b\xdb \xe2.\xd7m\x03\x9d9C\x15\x93\xaa\xc8\x0e\x94\xe9\x96%\xfb\x80\xb7\x00\x82\x1aO\x84\"\xbc\xa6E\x95\xed\x8a\xdb&\x04\x95\x08\xa2M\xe7\xceYGX\x9fi\xf4\xf5\xd6M\xf1\xe0\xc5\xef\xa8\x14\xf9\xaa\xbc4TH\xdd\xfc>\xa3\xf9\xbeT\xb8\xd8yYH\xf7,\x03Xf\xac\xfd7\x1a\x13yhd\xaf\xd3	\xbb\xaa\xa2S\xdb\xd5\xca\xbf\x97\x86\x8bC\x0c\x11\xc7\x8b|s$\x81&\xbez\xce|\xed~\xa1\x0c\xc7\x15e\xc1\xd7w\x0e\xb1\x87O\xbfM7c\xa9VP\x9d\xf4\xdb\xca\xabRB/\x8dv\xa5\x05\x11\xa5\xa1\xcc\xb4j\xfd\x1e)B\xb4\x10[0\xf7\x04\xa9\xc5\x8a\xcc\xea=\x8fN\xc0#Posy\xd8\xd5\xf5\xcb\x87L\xe9\xeb\x12>'#<w\x8c\xb9tR\xf3\x81\xf2\xd1\xa13\x96L\xda5\x81R^(\x98\xf7\xfb\x8b|\xb93e\xce\x11NQ\xbc\x0fn\x16\xabI|\xed2&w\x0c\xb2\xa4\xe6\x82\x98\xfb\x91\xb7\x82X\xe25\xe5\xf3V) ~\x03\xf7\x0d5\xf8\x187\xa3\xdbIw7\x07wi\xe2a\xcbI\x82.k\x8e\xa4\xf2\xcf\xd7\xad5k\xdb\x8b\xb7\xf7d\xe2T\x0db\xe3+N\x96\xe2\xd1\xac\xcd\xd5{6\xbb\xd2\xed\xab\xe3\xb67\xbd(A\xf0\xc0\xac\xf89c\x0fZNh\xdf\x0f\x86N\xd8J,\xec8\xd6I\xf4\x06\xe01\xe7J\xef\xb6'\xbbh\x9dO5y:\x86\xfb\x08&\xa3\x0b\\\xb1Q\xd4\x8f\xb5\xc7\xa6p\x11t6&\xd4\xd1>Eu3\xdd\x8c=\x9c\xab\x7f\x9b\xa9\xdd\xda\x13rI\xbf*t\xfbr	fU\x90\xa1UdC\xf4o\x0b\xe4_}\x96D0v\xb7\x92W9\xe4\xc1\x05L3\x8c\xbdi\xf2j\xf9	!+\xdd\x85\x15\x0b(\x97\x0c9\xb7\x1b\xac\xbbu\xe2\xce63]/<\x80\xa8\xb2\x97\xf7\xed\x8f}\x9e\xceh\x14\x80\x8e\xbf\xf5e\xb1\x9bS\x19\xc3\xc1}\x11\x8b{\xb5\xb1|\xd6\x9ffk\xb4\xf6\x0eL\xe5\xab\x14\x96\x878\xd9\x90\xc8V\x90+-B\x14\x06<\xaf\xc4V\xd0\x03\x95c\xf1\xf4>\x93\x19:\xe7\xbc\xff*T\xcb\xb6\x96\x94\xf0g\x19\x06\xab\x81\x84\x0b\x0f_\xa0U\xa4\x1b\xfav\x1d\xd5z\x9f\xa8\xe2\xb6\x99\x94\x06;!s3\xb5\xe2.F\xb3J] \"p\x8fbH^\x1d\x0cC\xf2\xab\xa1\x04\x0f\x90\xc1\xab\x8cT\xe6\x9e]\x8cS&\x1a\n\x19\x1d\x9dxb\xa4\xaeo\x07\xb8\xdcn|.\xe0\xc8N\xe7x-u\x0b!\xf0\xfa\xc7y\xbd\xeaf[o\x00+\xef\xc4\xb9\xec1\xa6\xe6l\x7fh9\x8d3a\xd8P\x02\x1d\x88\x03\xb8\x1b\x035\x97\x01\xddC\xc7+N`+\xfd\x9e\x0f\x80\x1f\xf2\x97c\xb8\xa3\xccESK6;\x13\xe1^\xd9I\x93?^i\xdf\x86u\\3U\xf1\xaa\xb4\x1e\xbd\xcd\x95?u/vL\xbc\x9c\xa0\xb8.5\x89\x13\x02D\xb1\x98l\x98\x97\xe9b\x86L*\xdb\\\xa8(-\x88\x03\xe2\xccc(\xbb\xd1\xa8\xde\xb1\xfb\xd0\x9b-7\xaa\x9e4\xaf\xcb\x9cv:i\xcdbB\xb3\xac\x9bd\x1a\xbe%\xa8e\xf5\xef|\"\xa7{A`\xf0\\Z\xd7\xb7\x11X\xc2\xea\xdf\x81/=\x99Li\x81\xd0\x9a\xcb\xb8]\x07\xd6\xb3\xba\x8a\xe6\x99,\x02\xec,}O\xa9\x92\xd1X\xec\xcf\xfeB\x85\xad\x07\xe4r\xa9K\x92\x04:\xde ~\x84\x9cc\xf8\xd5Z\xbe\xd25^\\\xb7V1\xc8\xd3\x99L\xbeK\xc45\x99\xcfFR|\xc3\x8cw\xebh\xec\xfe+\xdb>\xfc\xe1\xf0\xd7~\xcdj\xec8\x9c\xe1Gi\xc8\xf2\x10s\x18\x03\xe7\xb6^\x1a\xbb\xa5Wv\xa1\xc6\xf6\x9f{.\x03\xca\x7f^\n\xb9\xbf?2\x19A\x86*xN\x15\xe7e\xa0\xed#\xf8|H\x05\x1d\xd1\x06\xa3,\xa1\x9bN\xa7G\xd3\xf0\xc6mH	{\xc8\xda\x10Z\x1d\x95\xcf|\x97\x0c\xc7\xf6%m\xd2,\xb4\xf5\x15v\xa3l(	\x9f\xb7\xb3\xce+r\x8d\x9c\xb2[0\xe38s,s\xef\xbd\x08/.\xbd=\xd2\x9fO\xa8\xd3\xbbD\xd2v\x19i\x07\xe6\xc4\xe9\x85\x1f	\x12#\x81Q\x82\xb3y\xf5}\x9f\xc5\xcb\\\x12p\xf76\x15\xc6\xdf\xca\xc6\x10\x04\xe4\x9a\x88\xa8\xe4\x00\xdd\xa6\x1d.z\x9e+\xb2\x8e\xf1\x12$\x02\xaa\xbf\xae\x1d#r\x9c~\x92Q\x9f\x85\xb9N%)k\xc9\xc4 \xdfc\xe2%\xcd\x85_\x13d|\xab\xb2\xc7%\xc9~\xbf\xe4\xa8?\x14\xf2\xad\xca\xdcfGP0c\xb1g\x99\x04be\xb0W\x1d\x88U\n\xb5\xc8\xcf{\xdb\xd7\xf6\x94\x02\xc9MM\xabR\x1f:\xcb\xfbeF\"\\\xefl\"\xb0\xeew\xd5O\xe8\x0e\x17'\x88\xbf3W\xeb\xbeU\xdaM\xb6S\x93\xd7O\xaa\x16\xe32\xaa-\x88}\x9f\xe6\xd0\x8d\xed\x9d\xdb+\x9a\x0c\x1fq\x91\x1b\x03\x83<BmS\x1c[\xc6\xc6/\xbf#[\x17\xc5U\xf3+qnp4J\x9f\x0d\xe8\xec*{\x0f\xe7T`h9\xd1r\x15\x92\xdb\x94\xad\x12c\x92\x1e\xb9`\x1dQ\x95\x19\x0d9\xc8\xdef.\x84\xb5{\xf4g\xb9\xbf\xcd\xe2\xa9p=\x03\xd0md\x07\xd1\xf1[\x80\x9f\x1eib\x01i\x16d\xeb\xef\xcb\xa8\xe04L.\x91\x18\xe0\xb1+G3\xe5\xa2\x98\x87\xa4\xcd\xddb\x881q\x08\xb9\x84\x06\xf5\xa1\x18C\xb5w\xa1\x82?2\xf5\xc2\xd8\xb7|0\x89\xc5\\\x15\xf9}\xe8Oy\x12\xab\xf8\xba\x11H$\x96\xc2t,K\xed\xd2\xf1c{\x8c\xf0\xe2D\xe2\x1e\xb1\x93\xce\xcb<:D\x0c\xf9\x8a\\\xc3\xbe\x10T0\x1f\x9f\xbd\x89\xaf\xd6;2\xab\xd2\xb3\x94d\xa3\x8c\xd8\x8cJA\x1f\x9c\xb5J\x14\xa5|/\xec\x88kF[\xc7+f\xf9\xea\x99\xf9bL	\x94S\xfe\xa5\xa1 #\x1f\x02\x9a\xea4\xad\xcf\xc3`\xd7\x92-\\\x1d\xe7\xcb\x05*\x19.l\xd8\x98\x10\xea,]\x9ebA\xd90F\x8b\xca\xb8\xec\xd5\xdb\xc3\xd6>\xad\xaeR\xc8\xc6/\x8b\xf5A\xc3\xe3\\\xba\xa4C,\x0ceO^\xaa\xf4\x03\xdd\xa0\xa0\xbe\xbafK	\xe2\xb2P9\x0c1\x05\xae\xf5\x97\x1a\xb8\xc4\xad-\x16\xa4U\xe1Z<\xa3\xd5\xde\xe1'wG\x1c\xde\xdd\xd8\x0e\xcb\x81\xech\xea\xeb\xb4V\xcc\xa3't|\x10\x13\x99\xc7\n\x97\xf9|\xa0D\xe7\x10\xd9e8\x06\x0bM/\xa9\xd6\xd4{\x19 )<NQ'\xd5\x14\xdb\x1f\x13\xd5\xfd;\xd7:DzaNu\x06\xcb\x86\xe6\x1f\x12\xd3\xf0F1\xd4EX]MW\x93S1\xeaOs\x9d\xb3*\xee\xf7 \xd8\xdb\x95o\x8e\xc4Q\xe8S\xc8\xca]3\xfe\xdci\x8ez\xd1\x93\xf8\x8d\xf5\x8e\xabR\xb2{:H\xf8\x12\xd0\xd6\xa0)\xc6\xe5\x00.\xe1\xe7\xc5*\x90\xa2*fw\x02\x00\x00p<\xdf\xfc\xa8\x04\x17\xc9\xf2\xdf_\xf8?\xff\xfa7\xbc\x1e\xe5\x7f\xfe\xf5\xff\x03\x00PK\x07\x08\xf9\xfe\x13#9\x0f\x00\x00\xe5\x13\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00#\x00	\x00img/supervised_user_circle-24px.svgUT\x05\x00\x01\x0e!\xd0^D\x92\xcd\xae\xe3 \x0c\x85_\xc5b\xef\x13c \x84Q\xdb\xc5\xacf3\x0f1\xca\xed4\x95\xfas5\x89\x92\xab<\xfd\xc8$\xed\x95\"\xfc\xe5\x1c\x03\x06s\x18\xe7\x0b}\xddo\x8f\xf1\xe8\x86i\xfa\xfc\xd14\xcb\xb2`	x\xfe\xbb4*\"\xcd8_\x1c-\xd7\x8fi8:\x8d\x8e\x86\xf3\xf52L\x1b\xcf\xd7\xf3\xf2\xf3\xf9utBB\x1aI\xa3;\x1d>\xffL\x03}\x1c\xddo\xefQ\ni\xcf	II\xd8\x0bE\xc4\xce\xa2\x97\xd1\x90*n#\xef\x02\xefh\xb1~\xeb=\xa0\xf5\xd4\"\xc4\xdeC2	y\x94\x80\xae\xadq\x1bL\x93\xcc/\x91\xdf\x0eoS\xaa`6\xef\xa2\xfd\x89\xaf\xeeK\xfd\x9e\xb9\xde\xd9\x12S\xd7{\x04;\x1a\x82m&\xedFu\xb0\x1d\x03\xbfE~;c\xc5\xea\xf0\xdb\xe9\xad\x82\xe0m\x91\xf4\x9dZi\xbd\x0b\x15\xf80\x07\xe4\xd4\xb3\"2r\xe2\x88\xc0\x8a\x96\x13|\xe4\x88R\x0bH\xec\xe1\x95\x02\xda\xcc\x1em\xa1\xea\x1a!Y\xa1\x1e\n\xe9\xec P5=\xa2\xb3\xccB\n\xd1\x1d\xdan}\xf5Fz\x86\xda\xf5 m\xf7\x81\\\x18\x12g\x8e\x90\\\x8b\x8eJ\x8a\x12Y\xe1\x83\xf5o\x83\xbd\x11\x8a\xa2\x08\x85\x02\xbaH\x1e\xbe\xd6\x97M\xce\xb6BK	R8#\xa6J\xabk\xf6\xf7\xf1\xf7z\xbb\x1d\xdd\xe3\xf98\xbb\xfaV\x84d\xd08k\xfc%\xabkN\x87f\x9c/\xa7\xff\x03\x00PK\x07\x08uq\x02\xd2f\x01\x00\x00\x9e\x02\x00\x00PK\x03\x04\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x0e\x00	\x00style/main.cssUT\x05\x00\x01\x0e!\xd0^\xbcX\xdf\x8f\xdb\xb8\x11~\xf7_1up@\x12\x88:I\xb6\xec\x9c\xf2\xd6+\x0e-\xd0\xdc\xc3\x05}\xe8#%\x8d,v)R \xa9\xb5\xf7\x16\xfb\xbf\x17\xa4\xa8\x1f\x96\xec\xddM\x1fz\xb8\x0019$g\xbe\xf9\xe6\x9bQ>\xc3\xf3\x06\xa0\xa1\xea\xc4D\x06\xd1\xd7\x0d@K\xcb\x92\x89\x93\xffE\xce\x98?0C*)\x0c\xd1\x8d\x94\xa6v\x9bT\x18F9\xa3\x1aKg\xd6\xc8?\x89\xd4\x97\x95\xddI\xd1']P\x8e\xf3\xcb\x0c^\x0c\xd1\xecO$\xb4\xfcO\xa7M\x06B\ng\x91\xcb\x8b\xddpGs\xa9JT$\x97\x17\xbb\xe3.\xaeh\xc3\xf8S\x06\x84\xb6-G\xa2\x9f\xb4\xc1&\x80\xbfr&\x1e\xbe\xd1\xe2\xbb\xfb\xfd\x9b\x14&\x80\xedw<I\x84\x7f\xfdc\x1b\xc0\x1f2\x97F\x06\x1b\x00\x80\xed\xdf\x91?\xa2a\x05\x85\xdf\xb1\xc3m\x00\x9a\nM4*V\x8d\xefX\xdf2\x88\x156v\x893\x81\xa4Fv\xaaM\x06q\xb8\xb7\xab/\x9bM\xd8*\xd6P\xf5\xe4 ,$\x97*\x83\x0f\x07\xdc\xef\xf0\xcb\xd7\xcd\xcb&\xe4\xf6\x80\xdb\xfc\xf93\xd0\xf4\x18W\x15|\xfey\xb2U\xa7\xfcc|H\x03\x88\xe3]\x00I\x9a~r\xc7J\xaa\x1e\x86S\x1f\xf6I\xf2\xb7\xc3au\xecp\x08`oOF\x89;\xb4	\x1d\xa4\x8d\x14R\xb7\xb4@w~\x16I\x14~I}0W8~\xff\xed\x9b\x14\x92\xfc\x81\xa7\x8eS\x15\xc07\x14\\\x06\xf0M\nZ\xc8\x00~\x95BKNu\x00\xdb\x7f\xb2\x1c\x155L\n\xbb+\xb7\x1e\xcc_e\xa7\x18*\xf8\x1d\xcf\xdb\x00\xa6\xf7\xff\xc2\x9aV*C\x85q\xee\xe5\xb2\xeca*\x99n9}\xca\xa0\xe2\xd8\xa7\x95\xe3\x85\x94Laa\xef\xce@\xc9\xb3]\xa6\x9c\x9d\x04a\x06\x1b\x9dA\x81\xc2\xa0\xb2\xcb9-\x1eNJv\xa2t8\xd0k\xfc\x02\x88\xc2\xc8\xa2x\x05\xf1n\x17\xc0\xee\x18\xc0>v;\x96o\xacz\"\x85\x14\x06\x85\xc9\xc0\x01Fr4gD\xe1\xbc\xfd\xd0P&\xde\xe7n!y\xd7\x88\x9b\xf7N^\x9fYi\xea\x0c\xe2(\xfa\xc9\xfel\x98\x98\xd8\x14E\x8fu\xff(\x13\x95\xb4d\x87\xe7[^N\xb7\xdd\x81f\xe5\xaa\xafc\x92Kcd\x93A\x12&\xca3W\xf7\xee\xffh\x88\xad\xd4\xccg	95\xec\xd1\x95\xacc\x9es*\x03\x8e\x95Y\x85\x98\xf8W\xebxI\xcb$\xbcb\xe5\xd9\x83\xb2\x8f\xa2\xe5\xc5S\xf8\x1c\x8dAEl\xd6\x9cJD\xe1\xae\xbd\x8c\xe6FQ\xa1+\xa9\x9a\x0c\xba\xb6EUP\x8d\x0b>\xc4q\x14\xc0\xe1\x18@\xb2\xf3\xc5S\xc7\xa1a\x86\xf7Us\xfb\xd5I\x14\xc3\xa3\xf5\x19\xe20I\x078\xeb\x04\x9e\xef\x001\xc8\xc2\xce\xfd\xf7\xa6\x9bw\x83\x9b\x816\xbe\xbc@\xed\x90F\xce\x9b\xb0\xa0\xaat\x0ey\x01U\xb4d\x9d\xb6H\x0dh/6\xf6=\x80\xfdj\x06q{\x01-9+\xfb\x12\x8b\x02\xf0\xff\x87q\xd2W\x97\xa5\x189)y\xce \x1e\x7f\xebZ1\xf1\xe0W\xc6\x8e\x02d\x17\xf5\xd77\xf4B|%\xec\xa7B\x18V\xbex\xab\x11h\x1f\xe6<\\\xd7\x1cjZ\xdaw\xa3\xde\x1d\x9b\x8a\xc8[.\xbd\x8d\x8e\xd6\xdb\x97\xcd\xa6b\xc8K\x8df\xd6\xeb\xa6\xaa\xf0\xef\xce\x95\xe5CUTEU\xa5\xe5\xff\xfc\xe8[\x10\x0f\xedn\x96\xd6\xe8\xdaUNs\xe4\xf0|\xb7\xea\xde,[/\xa4C\x15\xee\x93%\xbeQ{\x81\xe8\x15AY	\xe5\xb4\xb5\xae\xd6\x95\xeb\x99\x90\xe6c\xc6\xa96\xa4\xa8\x19/?\xcd\x199\x80\xff\n\xd3\x16\x99swZ\xa9\xeeEk\xc6\x9c8I\x17\x91E\x10\xfb\xa5y-+[%\xeeR\xd6\x9cBV\xc8\xfe&\xcf?\xda\x199\x87kwh/7*%\xb5\xc4\xb5\xed\xb6A\xad\xe9\xa9W\x8c\x91\xb2I\x98zB\x1eGm\x08]\x08\xce\xcef\xca\x97\xc7\x0d_\xd7\x98\xbe\xd2\xf1_\xe1\xab\xec\x8c\x9dW\xa6\x91\xaa\xe8\x94\xb6\x1ad\xc1\xb0\xbf\xcf53\xe8$\xc6\x19\x9d\x15m\xed\xb2|DUq\xcb\xf2\x9a\x95%\x8a\x11\xbfi\x039g\xadf\xfa:5\xa1F\x8e\x85\xc92Z\x19T~\x18\xf2=p\xbb\xbd\xee\x1b4\xd7\x92w\x06\xbfN\xc8\xff\xd2^\xe6<\xf5\x99s\xd9\x9a\xaa\xd3\xc8\xd6\x83?H\x0bqKd`\xb5d\xb6\x0f\x12|Da\xf4\x10\xfb\xcbf\xc3D\xdb\x999\xf5\xb4y\xe23p\x16h\xbdl6}4\xcb\x84\xbd\xeb\xf44-\xd3\xb6E\xaa\xa8(f\xc6nD\xbe\xb5qkm\x9d\xc5\xa1\x91\xc4\xbb|W\xcd\xf3\xeac_\x8eG\xae\xc5\xb4T\xa1\x1f\xc1BK\xbf\xf7u|/\x1d\xf6\x00\xb1\xf4\xc8` \xc9;\x86\xa70\xef\x8c\xf1\xc558\xdd\xf3s\xe9\xe18+/Ev\xdf^\xe0\xd0^zQH\xa3\x00\xec\x9f_vV\xd3\xe3\xf8S`\x0b\xbc\xbd\xc0n\xb0\x98K\xfe\x97w\x88o\xb4*\xb7c\x14]i\xa5\xef	\xeb$\xac!\x1f+9\xf6\x87l\x99\x91\x12\x0b\xd9O\xcbC\xfe'd\xc2\x9a\xf2\n\x9e\xaf\xdbh\xb4j\xa3\xd3JN5\xb3S0\xe5\xc5\xc74\xfa	\x88{\xcb\xcf\xfd=\xdaa\xd5q\xbe\xbc3\x9e[d\xb5\xadcg2\x9b\x91\xdc_95\xf8\xef\x8f$vw.sql/\x10\xdb\x84D7\xd3\xf1\xa9\xff\x0e\x88\\6\x0e\x93\xd92'/\x9bPV\x15q\x84\x80\xe7\xa5~\xa5\xc7]\x9a\xa7\x9e\xa6R\xdaJ\x1e\x05zd+\x136\x19\xc4\xd0\x9c\xe3J\x07b/\x04C\xbbK|\xce\x17\xf2\xfe\x88\xca~\xf9\xf1a\xac4\xb2\x9d\x7f>u\x06\xcb+\xe2\x1e\x8acz,\xaf\xbef\xae\x9b\xf6\\\xe9\xed\xdcEz\xf7o\xd7\xd9[\xd5\xb3h\xc5\xe30=\x0b\xd4\x93\xcc/y\x95$\xb3\x19\xc9\xef\x0c\x1d\xf6\xc6\x96\xbd\xf6\xfa\xcc\xd4\x8d\x96\xc3\xed<O\xc4\x83\xb2\xca\xefn^s\xce\xcb\xb7'Ho\xdd\xbb\xd9\xc7q\x7fN\xf5V\xd6\xf1\x95\xd1\x08|\x8d\xb4\xbc\x07\xfc\x9dO\xa6\xb7\xf3\xe1\xd1\xfc!\xe8=!\xff\xcf\xb8\xbfc\xa4J\xd2\xf9\x01#\xdb\xb7p\xb7&wAw\x15\xa3\xc6\x7f\xe2\xb89j\xfdw\x00PK\x07\x08L\xbb\xd3^\xeb\x05\x00\x00^\x12\x00\x00PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP\xaa\xa0\x16\xe1t\x04\x00\x00-\x1f\x00\x00\x16\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\x00\x00\x00\x00html/dashboard.go.htmlUT\x05\x00\x01\x0e!\xd0^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00C@N]Z\xe3\xce2\xb7\x02\x00\x00\xda\x07\x00\x00\x12\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\xc1\x04\x00\x00html/error.go.htmlUT\x05\x00\x01\xfe6\xcfjPK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP`}f\xf1\xdb\x00\x00\x00c\x01\x00\x00\x13\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\xc1\x07\x00\x00html/header.go.htmlUT\x05\x00\x01\x0e!\xd0^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP\x83\xba\x83\xe4\xf6\x00\x00\x00|\x01\x00\x00\x1b\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\xe6\x08\x00\x00img/account_circle-24px.svgUT\x05\x00\x01\x0e!\xd0^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP\xfc\xc6x\x8f\xb5\x00\x00\x00\xf9\x00\x00\x00\x12\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81.\n\x00\x00img/error-24px.svgUT\x05\x00\x01\x0e!\xd0^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcPK\xfe\x8b#h\x03\x00\x00d\x08\x00\x00\x10\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81,\x0b\x00\x00img/pomerium.svgUT\x05\x00\x01\x0e!\xd0^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcP\xf9\xfe\x13#9\x0f\x00\x00\xe5\x13\x00\x00\x1a\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81\xdb\x0e\x00\x00img/pomerium_circle_96.svgUT\x05\x00\x01\x0e!\xd0^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcPuq\x02\xd2f\x01\x00\x00\x9e\x02\x00\x00#\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81e\x1e\x00\x00img/supervised_user_circle-24px.svgUT\x05\x00\x01\x0e!\xd0^PK\x01\x02\x14\x03\x14\x00\x08\x00\x08\x00\xb1\xa4\xbcPL\xbb\xd3^\xeb\x05\x00\x00^\x12\x00\x00\x0e\x00	\x00\x00\x00\x00\x00\x00\x00\x00\x00\xb4\x81% \x00\x00style/main.cssUT\x05\x00\x01\x0e!\xd0^PK\x05\x06\x00\x00\x00\x00	\x00	\x00\xb2\x02\x00\x00U&\x00\x00\x00\x00"
 		fs.RegisterWithNamespace("web", data)
 	}
+	
\ No newline at end of file
@@ -0,0 +1,8 @@
+package geoip
+
+import "time"
+
+// SetReloadInterval sets how often the database file is checked for changes.
+func (db *DB) SetReloadInterval(d time.Duration) {
+	db.reloadInterval = d
+}
@@ -0,0 +1,151 @@
+// Package geoip looks up the geographic location of IP addresses in a MaxMind
+// GeoIP2 or GeoLite2 database.
+package geoip
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+const (
+	// cacheSize is the number of lookups that are cached.
+	cacheSize = 4096
+	// reloadInterval is how often the database file is checked for changes.
+	reloadInterval = time.Minute
+)
+
+// ErrClosed is returned for lookups in a closed database.
+var ErrClosed = errors.New("geoip: database is closed")
+
+// A Location is the geographic location of an IP address.
+type Location struct {
+	// Country is the ISO 3166-1 alpha-2 code of the country, e.g. "US".
+	Country string
+	// Region is the ISO 3166-2 code of the country's largest subdivision,
+	// without the country, e.g. "CA".
+	Region string
+}
+
+// A DB is a GeoIP database. The database is reloaded when its file changes,
+// e.g. when it's updated by geoipupdate.
+type DB struct {
+	path           string
+	reloadInterval time.Duration
+
+	mu      sync.Mutex
+	reader  *reader
+	modTime time.Time
+	checked time.Time
+	cache   *lru.Cache
+}
+
+// Open opens the GeoIP database file.
+func Open(path string) (*DB, error) {
+	db := &DB{path: path, reloadInterval: reloadInterval}
+	if err := db.load(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Path returns the path of the database file.
+func (db *DB) Path() string {
+	return db.path
+}
+
+func (db *DB) load() error {
+	fi, err := os.Stat(db.path)
+	if err != nil {
+		return err
+	}
+	buf, err := ioutil.ReadFile(db.path)
+	if err != nil {
+		return err
+	}
+	r, err := newReader(buf)
+	if err != nil {
+		return err
+	}
+	db.reader, db.modTime, db.checked = r, fi.ModTime(), time.Now()
+	db.cache = lru.New(cacheSize)
+	return nil
+}
+
+// reloadIfChanged reloads the database if its file has changed since it was
+// loaded. If the new file can't be loaded, the current database is kept.
+func (db *DB) reloadIfChanged() {
+	if time.Since(db.checked) < db.reloadInterval {
+		return
+	}
+	db.checked = time.Now()
+	fi, err := os.Stat(db.path)
+	if err != nil || fi.ModTime().Equal(db.modTime) {
+		return
+	}
+	if err := db.load(); err != nil {
+		log.Warn().Err(err).Str("path", db.path).Msg("geoip: error reloading database")
+		return
+	}
+	log.Info().Str("path", db.path).Msg("geoip: reloaded database")
+}
+
+// Lookup returns the location of the IP address. The location is empty if the
+// database doesn't have one for the address.
+func (db *DB) Lookup(ip net.IP) (Location, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.reader == nil {
+		return Location{}, ErrClosed
+	}
+	db.reloadIfChanged()
+	key := string(ip.To16())
+	if loc, ok := db.cache.Get(key); ok {
+		return loc.(Location), nil
+	}
+	v, err := db.reader.lookup(ip)
+	if err != nil {
+		return Location{}, err
+	}
+	loc := getLocation(v)
+	db.cache.Add(key, loc)
+	return loc, nil
+}
+
+// Close releases the database, once the lookup in progress, if any, is done.
+// Lookups after it's closed return ErrClosed.
+func (db *DB) Close() {
+	if db == nil {
+		return
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.reader, db.cache = nil, nil
+}
+
+// getLocation returns the location of a GeoIP2 country or city record.
+func getLocation(v interface{}) Location {
+	var loc Location
+	record, _ := v.(map[string]interface{})
+	for _, k := range []string{"country", "registered_country"} {
+		if country, ok := record[k].(map[string]interface{}); ok {
+			if loc.Country, _ = country["iso_code"].(string); loc.Country != "" {
+				break
+			}
+		}
+	}
+	if subdivisions, ok := record["subdivisions"].([]interface{}); ok && len(subdivisions) > 0 {
+		if subdivision, ok := subdivisions[0].(map[string]interface{}); ok {
+			loc.Region, _ = subdivision["iso_code"].(string)
+		}
+	}
+	return loc
+}
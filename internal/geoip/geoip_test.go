@@ -0,0 +1,155 @@
+package geoip_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pomerium/pomerium/internal/geoip"
+	"github.com/pomerium/pomerium/internal/geoip/geoiptest"
+)
+
+func TestDB_Lookup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pomerium-geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	networks := map[string]geoip.Location{
+		"81.2.69.0/24":    {Country: "GB", Region: "ENG"},
+		"216.160.83.0/24": {Country: "US", Region: "WA"},
+		"2001:480::/32":   {Country: "US", Region: "CA"},
+	}
+	tests := []struct {
+		ip   string
+		want geoip.Location
+	}{
+		{"81.2.69.142", geoip.Location{Country: "GB", Region: "ENG"}},
+		{"216.160.83.56", geoip.Location{Country: "US", Region: "WA"}},
+		{"2001:480::1", geoip.Location{Country: "US", Region: "CA"}},
+		{"::ffff:81.2.69.1", geoip.Location{Country: "GB", Region: "ENG"}},
+		{"10.0.0.1", geoip.Location{}},
+		{"2001:db8::1", geoip.Location{}},
+	}
+	for _, recordSize := range []int{24, 28, 32} {
+		t.Run(fmt.Sprint(recordSize), func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("test-%d.mmdb", recordSize))
+			if err := geoiptest.WriteDB(path, recordSize, networks); err != nil {
+				t.Fatal(err)
+			}
+			db, err := geoip.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tt := range tests {
+				// the second lookup is cached
+				for i := 0; i < 2; i++ {
+					got, err := db.Lookup(net.ParseIP(tt.ip))
+					if assert.NoError(t, err, tt.ip) {
+						assert.Equal(t, tt.want, got, tt.ip)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestOpen_invalid(t *testing.T) {
+	f, err := ioutil.TempFile("", "pomerium-geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, _ = f.WriteString("not a geoip database")
+	f.Close()
+
+	if _, err := geoip.Open(f.Name()); err == nil {
+		t.Error("Open() of an invalid database should fail")
+	}
+	if _, err := geoip.Open(filepath.Join(os.TempDir(), "does-not-exist.mmdb")); err == nil {
+		t.Error("Open() of a missing database should fail")
+	}
+}
+
+func TestDB_reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pomerium-geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.mmdb")
+
+	if err := geoiptest.WriteDB(path, 24, map[string]geoip.Location{"81.2.69.0/24": {Country: "GB"}}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := geoip.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetReloadInterval(0)
+	ip := net.ParseIP("81.2.69.142")
+	got, err := db.Lookup(ip)
+	assert.NoError(t, err)
+	assert.Equal(t, "GB", got.Country)
+
+	// an invalid update keeps the current database
+	if err := ioutil.WriteFile(path, []byte("not a geoip database"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, path, 1)
+	got, err = db.Lookup(ip)
+	assert.NoError(t, err)
+	assert.Equal(t, "GB", got.Country)
+
+	// a valid update replaces it, and clears the cache
+	if err := geoiptest.WriteDB(path, 24, map[string]geoip.Location{"81.2.69.0/24": {Country: "IE"}}); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, path, 2)
+	got, err = db.Lookup(ip)
+	assert.NoError(t, err)
+	assert.Equal(t, "IE", got.Country)
+}
+
+func TestDB_Close(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pomerium-geoip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.mmdb")
+
+	if err := geoiptest.WriteDB(path, 24, map[string]geoip.Location{"81.2.69.0/24": {Country: "GB"}}); err != nil {
+		t.Fatal(err)
+	}
+	db, err := geoip.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	_, err = db.Lookup(net.ParseIP("81.2.69.142"))
+	assert.True(t, errors.Is(err, geoip.ErrClosed), "Lookup() after Close() = %v, want ErrClosed", err)
+
+	// closing twice, or closing a nil database, is a no-op
+	db.Close()
+	var nilDB *geoip.DB
+	nilDB.Close()
+}
+
+// touch sets the modification time of the file to an hour ago plus the
+// seconds, so that consecutive writes have distinct times.
+func touch(t *testing.T, path string, seconds int) {
+	t.Helper()
+	mtime := time.Now().Add(-time.Hour).Add(time.Duration(seconds) * time.Second)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,190 @@
+// Package geoiptest writes GeoIP databases for testing.
+package geoiptest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/pomerium/pomerium/internal/geoip"
+)
+
+// WriteDB writes a GeoIP2 City database, with an IPv6 search tree of the
+// record size (24, 28 or 32 bits), which maps the networks, in CIDR notation,
+// to their locations. The networks must not overlap.
+func WriteDB(path string, recordSize int, networks map[string]geoip.Location) error {
+	type node struct {
+		children [2]*node
+		// id is the node's number in the search tree, and data the offset
+		// of a leaf's record in the data section
+		id, data int
+		leaf     bool
+	}
+
+	// the data section holds a record for each network
+	root := &node{}
+	var data []byte
+	cidrs := make([]string, 0, len(networks))
+	for cidr := range networks {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		ones, _ := ipnet.Mask.Size()
+		ip := ipnet.IP.To16()
+		if ip4 := ipnet.IP.To4(); ip4 != nil {
+			// IPv4 networks are stored with 96 leading zeros
+			ip, ones = append(make(net.IP, 12), ip4...), ones+96
+		}
+		n := root
+		for i := 0; i < ones; i++ {
+			bit := ip[i>>3] >> (7 - uint(i&7)) & 1
+			if n.children[bit] == nil {
+				n.children[bit] = &node{}
+			}
+			n = n.children[bit]
+			if n.leaf {
+				return fmt.Errorf("geoiptest: %s overlaps another network", cidr)
+			}
+		}
+		loc := networks[cidr]
+		n.leaf, n.data = true, len(data)
+		data = append(data, encode(map[string]interface{}{
+			"country":      map[string]interface{}{"iso_code": loc.Country},
+			"subdivisions": []interface{}{map[string]interface{}{"iso_code": loc.Region}},
+		})...)
+	}
+
+	// number the nodes of the search tree, breadth first
+	var nodes []*node
+	for queue := []*node{root}; len(queue) > 0; queue = queue[1:] {
+		n := queue[0]
+		n.id = len(nodes)
+		nodes = append(nodes, n)
+		for _, c := range n.children {
+			if c != nil && !c.leaf {
+				queue = append(queue, c)
+			}
+		}
+	}
+	nodeCount := len(nodes)
+	record := func(c *node) uint32 {
+		switch {
+		case c == nil:
+			return uint32(nodeCount)
+		case c.leaf:
+			return uint32(nodeCount + 16 + c.data)
+		default:
+			return uint32(c.id)
+		}
+	}
+
+	var buf []byte
+	for _, n := range nodes {
+		left, right := record(n.children[0]), record(n.children[1])
+		switch recordSize {
+		case 24:
+			buf = append(buf, byte(left>>16), byte(left>>8), byte(left),
+				byte(right>>16), byte(right>>8), byte(right))
+		case 28:
+			buf = append(buf, byte(left>>16), byte(left>>8), byte(left),
+				byte(left>>24&0x0f)<<4|byte(right>>24&0x0f),
+				byte(right>>16), byte(right>>8), byte(right))
+		case 32:
+			buf = append(buf, make([]byte, 8)...)
+			binary.BigEndian.PutUint32(buf[len(buf)-8:], left)
+			binary.BigEndian.PutUint32(buf[len(buf)-4:], right)
+		default:
+			return fmt.Errorf("geoiptest: unsupported record size %d", recordSize)
+		}
+	}
+	buf = append(buf, make([]byte, 16)...)
+	buf = append(buf, data...)
+	buf = append(buf, "\xab\xcd\xefMaxMind.com"...)
+	buf = append(buf, encode(map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Now().Unix()),
+		"database_type":               "GeoIP2-City",
+		"description":                 map[string]interface{}{"en": "pomerium test database"},
+		"ip_version":                  uint16(6),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+	})...)
+	return ioutil.WriteFile(path, buf, 0600)
+}
+
+// encode returns the data section encoding of the value.
+func encode(v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		return append(control(2, len(v)), v...)
+	case uint16:
+		return encodeUint(5, uint64(v))
+	case uint32:
+		return encodeUint(6, uint64(v))
+	case uint64:
+		return encodeUint(9, v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b := control(7, len(v))
+		for _, k := range keys {
+			b = append(b, encode(k)...)
+			b = append(b, encode(v[k])...)
+		}
+		return b
+	case []interface{}:
+		b := control(11, len(v))
+		for _, e := range v {
+			b = append(b, encode(e)...)
+		}
+		return b
+	}
+	panic(fmt.Sprintf("geoiptest: unsupported type %T", v))
+}
+
+// encodeUint returns the encoding of an unsigned integer, without leading
+// zeros.
+func encodeUint(typ int, v uint64) []byte {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	return append(control(typ, len(b)), b...)
+}
+
+// control returns the control bytes of a field of the type and size.
+func control(typ, size int) []byte {
+	var b []byte
+	if typ > 7 {
+		b = []byte{0, byte(typ - 7)}
+	} else {
+		b = []byte{byte(typ << 5)}
+	}
+	switch {
+	case size < 29:
+		b[0] |= byte(size)
+	case size < 285:
+		b[0] |= 29
+		b = append(b, byte(size-29))
+	case size < 65821:
+		b[0] |= 30
+		b = append(b, byte((size-285)>>8), byte(size-285))
+	default:
+		b[0] |= 31
+		b = append(b, byte((size-65821)>>16), byte((size-65821)>>8), byte(size-65821))
+	}
+	return b
+}
@@ -0,0 +1,300 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+)
+
+// errInvalidDB is returned for databases which can't be decoded.
+var errInvalidDB = errors.New("geoip: invalid database")
+
+// metadataMarker separates the data section from the metadata.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// The types of the fields of the data section.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maxDepth bounds the nesting of maps and arrays, so that a corrupt database
+// can't recurse without end.
+const maxDepth = 32
+
+// A reader looks up IP addresses in a MaxMind DB.
+//
+// https://maxmind.github.io/MaxMind-DB/
+type reader struct {
+	tree       []byte
+	data       decoder
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	// ipv4Start is the node IPv4 addresses start from in an IPv6 tree
+	ipv4Start uint
+}
+
+func newReader(buf []byte) (*reader, error) {
+	idx := bytes.LastIndex(buf, metadataMarker)
+	if idx == -1 {
+		return nil, fmt.Errorf("%w: no metadata", errInvalidDB)
+	}
+	v, _, err := decoder{buf: buf[idx+len(metadataMarker):]}.decode(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	md, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: bad metadata", errInvalidDB)
+	}
+	if v, _ := md["binary_format_major_version"].(uint64); v != 2 {
+		return nil, fmt.Errorf("%w: unsupported format version %d", errInvalidDB, v)
+	}
+	nodeCount, _ := md["node_count"].(uint64)
+	recordSize, _ := md["record_size"].(uint64)
+	ipVersion, _ := md["ip_version"].(uint64)
+	switch {
+	case recordSize != 24 && recordSize != 28 && recordSize != 32:
+		return nil, fmt.Errorf("%w: unsupported record size %d", errInvalidDB, recordSize)
+	case ipVersion != 4 && ipVersion != 6:
+		return nil, fmt.Errorf("%w: unsupported ip version %d", errInvalidDB, ipVersion)
+	}
+	// the search tree is followed by 16 bytes of zeros, then the data section
+	treeSize := nodeCount * recordSize / 4
+	if treeSize+16 > uint64(idx) {
+		return nil, fmt.Errorf("%w: truncated search tree", errInvalidDB)
+	}
+
+	r := &reader{
+		tree:       buf[:treeSize],
+		data:       decoder{buf: buf[treeSize+16 : idx]},
+		nodeCount:  uint(nodeCount),
+		recordSize: uint(recordSize),
+		ipVersion:  uint(ipVersion),
+	}
+	if r.ipVersion == 6 {
+		// IPv4 addresses are stored as IPv6 addresses with 96 leading zeros
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+// lookup returns the data for the IP address, or nil if there is none.
+func (r *reader) lookup(ip net.IP) (interface{}, error) {
+	node, bits := uint(0), 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if ip = ip.To16(); ip == nil || r.ipVersion == 4 {
+		return nil, nil
+	}
+
+	for i := 0; i < bits && node < r.nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i&7))) & 1
+		node = r.record(node, bit)
+	}
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node < r.nodeCount:
+		return nil, fmt.Errorf("%w: search tree is too deep", errInvalidDB)
+	}
+	v, _, err := r.data.decode(node-r.nodeCount-16, 0)
+	return v, err
+}
+
+// record returns the left (0) or right (1) record of the node.
+func (r *reader) record(node, bit uint) uint {
+	b := r.tree
+	switch r.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		return uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+	case 28:
+		// the middle byte holds the high nibbles of both records
+		off := node * 7
+		if bit == 0 {
+			return uint(b[off+3]&0xf0)<<20 | uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+		}
+		return uint(b[off+3]&0x0f)<<24 | uint(b[off+4])<<16 | uint(b[off+5])<<8 | uint(b[off+6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(b[off:]))
+	}
+}
+
+// A decoder decodes the fields of a data section. Offsets and pointers are
+// relative to the start of the section.
+type decoder struct {
+	buf []byte
+}
+
+// decode returns the field at the offset, and the offset of the next field.
+func (d decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, fmt.Errorf("%w: data is nested too deeply", errInvalidDB)
+	}
+	if offset >= uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("%w: offset %d is out of range", errInvalidDB, offset)
+	}
+	ctrl := d.buf[offset]
+	offset++
+	typ := uint(ctrl >> 5)
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, fmt.Errorf("%w: truncated field", errInvalidDB)
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	if typ == typePointer {
+		ptr, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		// pointers may not point to pointers
+		if ptr < uint(len(d.buf)) && d.buf[ptr]>>5 == typePointer {
+			return nil, 0, fmt.Errorf("%w: pointer to a pointer", errInvalidDB)
+		}
+		v, _, err := d.decode(ptr, depth+1)
+		return v, next, err
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return nil, 0, fmt.Errorf("%w: truncated field", errInvalidDB)
+		}
+		ext := uintFromBytes(d.buf[offset : offset+n])
+		switch size {
+		case 29:
+			size = 29 + ext
+		case 30:
+			size = 285 + ext
+		default:
+			size = 65821 + ext
+		}
+		offset += n
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{})
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key isn't a string", errInvalidDB)
+			}
+			if m[key], offset, err = d.decode(next, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case typeArray:
+		var a []interface{}
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a, offset = append(a, v), next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("%w: truncated field", errInvalidDB)
+	}
+	b, next := d.buf[offset:offset+size], offset+size
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: bad double size %d", errInvalidDB, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: bad float size %d", errInvalidDB, size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("%w: bad uint size %d", errInvalidDB, size)
+		}
+		return uint64(uintFromBytes(b)), next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%w: bad int32 size %d", errInvalidDB, size)
+		}
+		return int32(uint32(uintFromBytes(b))), next, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("%w: bad uint128 size %d", errInvalidDB, size)
+		}
+		return new(big.Int).SetBytes(b), next, nil
+	}
+	return nil, 0, fmt.Errorf("%w: unexpected type %d", errInvalidDB, typ)
+}
+
+// pointer returns the offset a pointer field points to, and the offset of the
+// next field.
+func (d decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	n := uint(ctrl>>3&0x3) + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, fmt.Errorf("%w: truncated pointer", errInvalidDB)
+	}
+	v, high := uintFromBytes(d.buf[offset:offset+n]), uint(ctrl&0x7)
+	switch n {
+	case 1:
+		v |= high << 8
+	case 2:
+		v = (v | high<<16) + 2048
+	case 3:
+		v = (v | high<<24) + 526336
+	}
+	return v, offset + n, nil
+}
+
+// uintFromBytes returns the big-endian unsigned integer of the bytes.
+func uintFromBytes(b []byte) uint {
+	var v uint
+	for _, c := range b {
+		v = v<<8 | uint(c)
+	}
+	return v
+}
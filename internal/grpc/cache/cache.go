@@ -10,5 +10,6 @@ import (
 type Cacher interface {
 	Get(ctx context.Context, key string) (value []byte, err error)
 	Set(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) error
 	Close() error
 }
@@ -51,6 +51,15 @@ func (a *Client) Set(ctx context.Context, key string, value []byte) error {
 	return nil
 }
 
+// Delete removes a key from the cache service.
+func (a *Client) Delete(ctx context.Context, key string) error {
+	ctx, span := trace.StartSpan(ctx, "grpc.cache.client.Delete")
+	defer span.End()
+
+	_, err := a.client.Delete(ctx, &cache.DeleteRequest{Key: key})
+	return err
+}
+
 // Close tears down the ClientConn and all underlying connections.
 func (a *Client) Close() error {
 	return a.conn.Close()
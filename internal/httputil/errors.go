@@ -54,6 +54,7 @@ type errResponse struct {
 	RequestID  string `json:",omitempty"`
 	CanDebug   bool   `json:"-"`
 	RetryURL   string `json:"-"`
+	SupportURL string `json:"-"`
 	Version    string `json:"-"`
 }
 
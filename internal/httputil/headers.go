@@ -3,6 +3,9 @@ package httputil
 // AuthorizationTypePomerium is for Authorization: Pomerium JWT... headers
 const AuthorizationTypePomerium = "Pomerium"
 
+// AuthorizationTypePomeriumAPIKey is for Authorization: Pomerium-API-Key KEY... headers
+const AuthorizationTypePomeriumAPIKey = "Pomerium-API-Key"
+
 // Pomerium headers contain information added to a request.
 const (
 	// HeaderPomeriumResponse is set when pomerium itself creates a response,
@@ -12,6 +15,46 @@ const (
 	HeaderPomeriumResponse = "x-pomerium-intercepted-response"
 	// HeaderPomeriumJWTAssertion is the header key containing JWT signed user details.
 	HeaderPomeriumJWTAssertion = "x-pomerium-jwt-assertion"
+	// HeaderPomeriumDecisionTime is the header key containing how long the
+	// authorization decision took. Only set for administrators when enabled.
+	HeaderPomeriumDecisionTime = "x-pomerium-decision-time"
+	// HeaderPomeriumServerTiming is the header key containing the
+	// Server-Timing metrics of the authorization decision, moved to the
+	// response by envoy. Only set for administrators when enabled.
+	HeaderPomeriumServerTiming = "x-pomerium-server-timing"
+	// HeaderPomeriumCookieMigration is the header key containing the
+	// Set-Cookie headers migrating the session cookie to the current cookie
+	// domain, moved to the response by envoy.
+	HeaderPomeriumCookieMigration = "x-pomerium-cookie-migration"
+	// HeaderPomeriumWarning is the header key containing advisory warnings
+	// returned by the policy evaluator for an allowed request.
+	HeaderPomeriumWarning = "x-pomerium-warning"
+	// HeaderPomeriumSessionGrace is the header key containing the time left
+	// before an expired session, accepted during its grace period, is
+	// rejected. Clients should refresh the session soon.
+	HeaderPomeriumSessionGrace = "x-pomerium-session-grace"
+	// HeaderPomeriumSessionExpiresIn is the header key containing the seconds
+	// until the session expires. Only set when enabled.
+	HeaderPomeriumSessionExpiresIn = "x-pomerium-session-expires-in"
+	// HeaderPomeriumDegraded is the header key passed upstream for requests
+	// which were allowed in a degraded mode, e.g. with an expired session
+	// while the authenticate service is down.
+	HeaderPomeriumDegraded = "x-pomerium-degraded"
+	// HeaderPomeriumOverrideToken is the header key containing an
+	// administrator's single-use, break-glass override token.
+	HeaderPomeriumOverrideToken = "x-pomerium-override-token"
+	// HeaderPomeriumInnerIdentity is the header key containing the signed
+	// identity of a user authenticated by an outer pomerium.
+	HeaderPomeriumInnerIdentity = "x-pomerium-inner-identity"
+	// HeaderPomeriumAppCookie is the header key containing the set-cookie
+	// value of a route's app cookie, moved to the response by envoy.
+	HeaderPomeriumAppCookie = "x-pomerium-app-cookie"
+	// HeaderPomeriumMatchedConditions is the header key containing the
+	// comma-separated policy conditions satisfied by an allowed request.
+	HeaderPomeriumMatchedConditions = "x-pomerium-matched-conditions"
+	// HeaderPomeriumProtocol is the header key containing the HTTP protocol
+	// version of the client's request, e.g. HTTP/2. Only set when enabled.
+	HeaderPomeriumProtocol = "x-pomerium-protocol"
 )
 
 // HeadersContentSecurityPolicy are the content security headers added to the service's handlers
@@ -42,6 +85,12 @@ const (
 	HeaderSentFrom        = "X-Sent-From"
 )
 
+// HeaderForwardedClientCert contains details of the client certificates used
+// by the downstream connections to each proxy in the path of the request.
+//
+// https://www.envoyproxy.io/docs/envoy/latest/configuration/http/http_conn_man/headers#x-forwarded-client-cert
+const HeaderForwardedClientCert = "X-Forwarded-Client-Cert"
+
 // HeadersXForwarded is the slice of the header keys used to contain information
 // from the client-facing side of proxy servers that is altered or lost when a
 // proxy is involved in the path of the request.
@@ -153,6 +153,12 @@ func (s *Store) Get(ctx context.Context, k string) (bool, []byte, error) {
 	return true, value, nil
 }
 
+// Delete isn't supported, since groupcache keys can't be removed, only
+// evicted once the cache is full.
+func (s Store) Delete(ctx context.Context, k string) error {
+	return fmt.Errorf("autocache: delete %s failed: %w", k, kv.ErrNotSupported)
+}
+
 // Close shuts down any HTTP server used for groupcache pool, and
 // also stop any background maintenance of memberlist.
 func (s Store) Close(ctx context.Context) error {
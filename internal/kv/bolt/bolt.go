@@ -105,6 +105,15 @@ func (s *Store) Get(ctx context.Context, k string) (bool, []byte, error) {
 	return true, value, nil
 }
 
+// Delete removes the key from the bucket. Deleting a key which doesn't exist
+// isn't an error.
+func (s Store) Delete(ctx context.Context, k string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.bucket))
+		return b.Delete([]byte(k))
+	})
+}
+
 // Close releases all database resources.
 // It will block waiting for any open transactions to finish
 // before closing the database and returning.
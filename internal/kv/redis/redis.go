@@ -85,6 +85,11 @@ func (s *Store) Get(ctx context.Context, k string) (bool, []byte, error) {
 	return true, []byte(v), nil
 }
 
+// Delete is equivalent to redis `DEL key` command.
+func (s Store) Delete(ctx context.Context, k string) error {
+	return s.db.Del(k).Err()
+}
+
 // Close closes the client, releasing any open resources.
 //
 // It is rare to Close a Client, as the Client is meant to be
@@ -2,11 +2,20 @@
 // datastores to provide key value storage capabilities.
 package kv
 
-import "context"
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotSupported is returned by stores which can't perform an operation,
+// such as deleting a key.
+var ErrNotSupported = errors.New("kv: operation not supported")
 
 // Store specifies a key value storage interface.
 type Store interface {
 	Set(ctx context.Context, key string, value []byte) error
 	Get(ctx context.Context, key string) (keyExists bool, value []byte, err error)
+	// Delete removes a key, if it exists.
+	Delete(ctx context.Context, key string) error
 	Close(ctx context.Context) error
 }
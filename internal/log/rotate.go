@@ -0,0 +1,198 @@
+package log
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat is the format of the time a file was rotated, appended to
+// the names of its backups. It sorts in time order.
+const backupTimeFormat = "20060102T150405.000000000"
+
+// RotatingFile is a file writer which rotates the file once it reaches a
+// maximum size or age, keeping a number of backups, and periodically syncs
+// it to disk so that records survive a crash.
+type RotatingFile struct {
+	// Filename is the file written to. Backups are kept alongside it.
+	Filename string
+	// MaxSize is the size, in bytes, at which the file is rotated. If 0, it
+	// isn't rotated by size.
+	MaxSize int64
+	// MaxAge is the age at which the file is rotated. If 0, it isn't rotated
+	// by age.
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept. If 0, all are kept.
+	MaxBackups int
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	done     chan struct{}
+	closed   sync.Once
+}
+
+// NewRotatingFile opens, or creates, the file for appending. If flushInterval
+// is greater than 0 the file is synced to disk at that interval until it's
+// closed.
+func NewRotatingFile(filename string, maxSize int64, maxAge time.Duration, maxBackups int, flushInterval time.Duration) (*RotatingFile, error) {
+	f := &RotatingFile{
+		Filename:   filename,
+		MaxSize:    maxSize,
+		MaxAge:     maxAge,
+		MaxBackups: maxBackups,
+		done:       make(chan struct{}),
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	if flushInterval > 0 {
+		go f.flush(flushInterval)
+	}
+	return f, nil
+}
+
+// Write writes p to the file, first rotating it if p would take it over its
+// maximum size, or it has reached its maximum age.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.shouldRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Sync commits the file's contents to disk.
+func (f *RotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return os.ErrClosed
+	}
+	return f.file.Sync()
+}
+
+// Close syncs and closes the file.
+func (f *RotatingFile) Close() error {
+	f.closed.Do(func() { close(f.done) })
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Sync()
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) flush(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := f.Sync(); err != nil && err != os.ErrClosed {
+				Error().Err(err).Str("file", f.Filename).Msg("log: error syncing file")
+			}
+		case <-f.done:
+			return
+		}
+	}
+}
+
+func (f *RotatingFile) shouldRotate(n int64) bool {
+	// a write larger than the maximum size is written to an empty file
+	// rather than rotating the file for every write
+	if f.MaxSize > 0 && f.size > 0 && f.size+n > f.MaxSize {
+		return true
+	}
+	return f.MaxAge > 0 && time.Since(f.openedAt) >= f.MaxAge
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("log: error opening file: %w", err)
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("log: error opening file: %w", err)
+	}
+	f.file, f.size, f.openedAt = file, fi.Size(), time.Now()
+	return nil
+}
+
+// rotate moves the file to a backup, opens a new file, and removes the
+// oldest backups over the maximum.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Sync(); err != nil {
+		return fmt.Errorf("log: error rotating file: %w", err)
+	}
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("log: error rotating file: %w", err)
+	}
+	f.file = nil
+	if err := os.Rename(f.Filename, f.Filename+"."+time.Now().UTC().Format(backupTimeFormat)); err != nil {
+		return fmt.Errorf("log: error rotating file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.removeOldBackups()
+}
+
+func (f *RotatingFile) removeOldBackups() error {
+	if f.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := f.Backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > f.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return fmt.Errorf("log: error removing old backup: %w", err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Backups returns the names of the file's backups, oldest first.
+func (f *RotatingFile) Backups() ([]string, error) {
+	dir, prefix := filepath.Dir(f.Filename), filepath.Base(f.Filename)+"."
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, fi := range entries {
+		if _, err := time.Parse(backupTimeFormat, strings.TrimPrefix(fi.Name(), prefix)); err == nil && strings.HasPrefix(fi.Name(), prefix) {
+			backups = append(backups, filepath.Join(dir, fi.Name()))
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
@@ -0,0 +1,128 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pomerium-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "audit.log")
+
+	f, err := NewRotatingFile(filename, 20, 0, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records := []string{"record-1\n", "record-2\n", "record-3\n", "record-4\n", "record-5\n", "record-6\n", "record-7\n"}
+	for _, r := range records {
+		if _, err := f.Write([]byte(r)); err != nil {
+			t.Fatal(err)
+		}
+		// backups are named by the time of rotation
+		time.Sleep(time.Millisecond)
+	}
+
+	backups, err := f.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, want 2: %v", len(backups), backups)
+	}
+	for i, want := range []string{"record-3\nrecord-4\n", "record-5\nrecord-6\n"} {
+		got, err := ioutil.ReadFile(backups[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("backup %d = %q, want %q", i, got, want)
+		}
+	}
+	got, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "record-7\n" {
+		t.Errorf("file = %q, want %q", got, "record-7\n")
+	}
+}
+
+func TestRotatingFile_maxAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pomerium-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "audit.log")
+
+	f, err := NewRotatingFile(filename, 0, 20*time.Millisecond, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("old\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, err := f.Write([]byte("new\n")); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := f.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("got %d backups, want 1", len(backups))
+	}
+	got, _ := ioutil.ReadFile(filename)
+	if string(got) != "new\n" {
+		t.Errorf("file = %q, want %q", got, "new\n")
+	}
+}
+
+func TestRotatingFile_flush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pomerium-rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "audit.log")
+
+	f, err := NewRotatingFile(filename, 0, 0, 0, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("record\n")); err != nil {
+		t.Fatal(err)
+	}
+	// records are readable before the file is closed, and the periodic sync
+	// doesn't interfere with writes
+	time.Sleep(30 * time.Millisecond)
+	got, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "record\n" {
+		t.Errorf("file = %q, want %q", got, "record\n")
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("late\n")); err == nil || !strings.Contains(err.Error(), "closed") {
+		t.Errorf("Write() after Close() error = %v, want closed", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Errorf("second Close() error = %v", err)
+	}
+}
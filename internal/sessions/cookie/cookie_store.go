@@ -9,7 +9,10 @@ import (
 	"time"
 
 	"github.com/pomerium/pomerium/internal/encoding"
+	"github.com/pomerium/pomerium/internal/encoding/jws"
+	"github.com/pomerium/pomerium/internal/log"
 	"github.com/pomerium/pomerium/internal/sessions"
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
 )
 
 var _ sessions.SessionStore = &Store{}
@@ -28,8 +31,8 @@ const (
 	// Note, this should be lower than the actual cookie's max size (4096 bytes)
 	// which includes metadata.
 	MaxChunkSize = 3800
-	// MaxNumChunks limits the number of chunks to iterate through. Conservatively
-	// set to prevent any abuse.
+	// MaxNumChunks is the default limit on the number of chunks, after the
+	// first, to iterate through. Conservatively set to prevent any abuse.
 	MaxNumChunks = 5
 )
 
@@ -40,6 +43,14 @@ type Store struct {
 	Expire   time.Duration
 	HTTPOnly bool
 	Secure   bool
+	// MaxChunks limits the number of chunks, after the first, that a session
+	// cookie is read from. Sessions with more are malformed. Defaults to
+	// MaxNumChunks.
+	MaxChunks int
+	// ChunkThreshold is the size, in bytes, above which a session cookie is
+	// split into chunks, rather than risk browsers rejecting it. Defaults to,
+	// and is capped at, MaxChunkSize.
+	ChunkThreshold int
 
 	encoder encoding.Marshaler
 	decoder encoding.Unmarshaler
@@ -47,11 +58,13 @@ type Store struct {
 
 // Options holds options for Store
 type Options struct {
-	Name     string
-	Domain   string
-	Expire   time.Duration
-	HTTPOnly bool
-	Secure   bool
+	Name           string
+	Domain         string
+	Expire         time.Duration
+	HTTPOnly       bool
+	Secure         bool
+	MaxChunks      int
+	ChunkThreshold int
 }
 
 // NewStore returns a new store that implements the SessionStore interface
@@ -84,12 +97,22 @@ func newStore(opts *Options) (*Store, error) {
 		return nil, fmt.Errorf("internal/sessions: cookie name cannot be empty")
 	}
 
+	if opts.MaxChunks < 0 {
+		return nil, fmt.Errorf("internal/sessions: cookie max chunks cannot be negative")
+	}
+
+	if opts.ChunkThreshold < 0 {
+		return nil, fmt.Errorf("internal/sessions: cookie chunk threshold cannot be negative")
+	}
+
 	return &Store{
-		Name:     opts.Name,
-		Secure:   opts.Secure,
-		HTTPOnly: opts.HTTPOnly,
-		Domain:   opts.Domain,
-		Expire:   opts.Expire,
+		Name:           opts.Name,
+		Secure:         opts.Secure,
+		HTTPOnly:       opts.HTTPOnly,
+		Domain:         opts.Domain,
+		Expire:         opts.Expire,
+		MaxChunks:      opts.MaxChunks,
+		ChunkThreshold: opts.ChunkThreshold,
 	}, nil
 }
 
@@ -105,12 +128,25 @@ func (cs *Store) makeCookie(value string) *http.Cookie {
 	}
 }
 
-// ClearSession clears the session cookie from a request
+// ClearSession clears the session cookie, and any chunks of it sent with the
+// request, from a request
 func (cs *Store) ClearSession(w http.ResponseWriter, r *http.Request) {
 	c := cs.makeCookie("")
 	c.MaxAge = -1
 	c.Expires = timeNow().Add(-time.Hour)
 	http.SetCookie(w, c)
+	if r == nil {
+		return
+	}
+	for i := 1; i <= cs.maxChunks(); i++ {
+		name := fmt.Sprintf("%s_%d", cs.Name, i)
+		if _, err := r.Cookie(name); err != nil {
+			break
+		}
+		nc := *c
+		nc.Name = name
+		http.SetCookie(w, &nc)
+	}
 }
 
 func getCookies(r *http.Request, name string) []*http.Cookie {
@@ -130,16 +166,22 @@ func (cs *Store) LoadSession(r *http.Request) (string, error) {
 	if len(cookies) == 0 {
 		return "", sessions.ErrNoSessionFound
 	}
+	loadErr := sessions.ErrMalformed
 	for _, cookie := range cookies {
-		jwt := loadChunkedCookie(r, cookie)
+		jwt, err := loadChunkedCookie(r, cookie, cs.maxChunks())
+		if err != nil {
+			return "", sessions.ErrMalformed
+		}
 
 		session := &sessions.State{}
-		err := cs.decoder.Unmarshal([]byte(jwt), session)
+		err = cs.decoder.Unmarshal([]byte(jwt), session)
 		if err == nil {
 			return jwt, nil
+		} else if errors.Is(err, jws.ErrUnexpectedAlgorithm) {
+			loadErr = sessions.ErrUnexpectedAlgorithm
 		}
 	}
-	return "", sessions.ErrMalformed
+	return "", loadErr
 }
 
 // SaveSession saves a session state to a request's cookie store.
@@ -170,11 +212,26 @@ func (cs *Store) setSessionCookie(w http.ResponseWriter, val string) {
 }
 
 func (cs *Store) setCookie(w http.ResponseWriter, cookie *http.Cookie) {
-	if len(cookie.String()) <= MaxChunkSize {
+	size := len(cookie.String())
+	if !cs.shouldChunk(size) {
 		http.SetCookie(w, cookie)
 		return
 	}
-	for i, c := range chunk(cookie.Value, MaxChunkSize) {
+	chunks := chunk(cookie.Value, cs.chunkThreshold())
+	metrics.RecordSessionCookieChunked()
+	// browsers silently drop oversized cookies, so the session would vanish
+	// and the user would be asked to sign in again, and again
+	evt := log.Warn()
+	if len(chunks)-1 > cs.maxChunks() {
+		evt = log.Error()
+	}
+	evt.Str("cookie", cookie.Name).
+		Int("size", size).
+		Int("threshold", cs.chunkThreshold()).
+		Int("chunks", len(chunks)).
+		Int("max-chunks", cs.maxChunks()+1).
+		Msg("internal/sessions: session cookie exceeds the chunk threshold, splitting it into chunks")
+	for i, c := range chunks {
 		// start with a copy of our original cookie
 		nc := *cookie
 		if i == 0 {
@@ -189,28 +246,66 @@ func (cs *Store) setCookie(w http.ResponseWriter, cookie *http.Cookie) {
 	}
 }
 
-func loadChunkedCookie(r *http.Request, c *http.Cookie) string {
+// maxChunks returns the limit on the number of chunks after the first.
+func (cs *Store) maxChunks() int {
+	if cs.MaxChunks > 0 {
+		return cs.MaxChunks
+	}
+	return MaxNumChunks
+}
+
+// chunkThreshold returns the size above which a cookie is split into chunks.
+func (cs *Store) chunkThreshold() int {
+	if cs.ChunkThreshold > 0 && cs.ChunkThreshold < MaxChunkSize {
+		return cs.ChunkThreshold
+	}
+	return MaxChunkSize
+}
+
+// shouldChunk reports whether a cookie of size bytes should be split into
+// chunks.
+func (cs *Store) shouldChunk(size int) bool {
+	return size > cs.chunkThreshold()
+}
+
+// errTooManyChunks is returned when a cookie has more than the maximum number
+// of chunks.
+var errTooManyChunks = errors.New("internal/sessions: too many cookie chunks")
+
+func loadChunkedCookie(r *http.Request, c *http.Cookie, maxChunks int) (string, error) {
 	if len(c.Value) == 0 {
-		return ""
+		return "", nil
 	}
 	// if the first byte is our canary byte, we need to handle the multipart bit
 	if []byte(c.Value)[0] != ChunkedCanaryByte {
-		return c.Value
+		return c.Value, nil
+	}
+
+	// index the request's cookies once, as a crafted request may contain a
+	// large number of them
+	values := make(map[string]string)
+	for _, rc := range r.Cookies() {
+		if _, ok := values[rc.Name]; !ok {
+			values[rc.Name] = rc.Value
+		}
 	}
 
 	data := c.Value
 	var b strings.Builder
 	fmt.Fprintf(&b, "%s", data[1:])
-	for i := 1; i <= MaxNumChunks; i++ {
-		next, err := r.Cookie(fmt.Sprintf("%s_%d", c.Name, i))
-		if err != nil {
+	for i := 1; ; i++ {
+		next, ok := values[fmt.Sprintf("%s_%d", c.Name, i)]
+		if !ok {
 			break // break if we can't find the next cookie
 		}
-		fmt.Fprintf(&b, "%s", next.Value)
+		if i > maxChunks {
+			return "", errTooManyChunks
+		}
+		fmt.Fprintf(&b, "%s", next)
 	}
 	data = b.String()
 
-	return data
+	return data, nil
 }
 
 func chunk(s string, size int) []string {
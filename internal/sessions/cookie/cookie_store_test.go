@@ -12,6 +12,7 @@ import (
 	"github.com/pomerium/pomerium/internal/cryptutil"
 	"github.com/pomerium/pomerium/internal/encoding"
 	"github.com/pomerium/pomerium/internal/encoding/ecjson"
+	"github.com/pomerium/pomerium/internal/encoding/jws"
 	"github.com/pomerium/pomerium/internal/encoding/mock"
 	"github.com/pomerium/pomerium/internal/sessions"
 
@@ -35,6 +36,10 @@ func TestNewStore(t *testing.T) {
 		{"good", &Options{Name: "_cookie", Secure: true, HTTPOnly: true, Domain: "pomerium.io", Expire: 10 * time.Second}, encoder, &Store{Name: "_cookie", Secure: true, HTTPOnly: true, Domain: "pomerium.io", Expire: 10 * time.Second}, false},
 		{"missing name", &Options{Name: "", Secure: true, HTTPOnly: true, Domain: "pomerium.io", Expire: 10 * time.Second}, encoder, nil, true},
 		{"missing encoder", &Options{Name: "_cookie", Secure: true, HTTPOnly: true, Domain: "pomerium.io", Expire: 10 * time.Second}, nil, nil, true},
+		{"max chunks", &Options{Name: "_cookie", MaxChunks: 2}, encoder, &Store{Name: "_cookie", MaxChunks: 2}, false},
+		{"negative max chunks", &Options{Name: "_cookie", MaxChunks: -1}, encoder, nil, true},
+		{"chunk threshold", &Options{Name: "_cookie", ChunkThreshold: 1000}, encoder, &Store{Name: "_cookie", ChunkThreshold: 1000}, false},
+		{"negative chunk threshold", &Options{Name: "_cookie", ChunkThreshold: -1}, encoder, nil, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -163,3 +168,208 @@ func TestStore_SaveSession(t *testing.T) {
 		})
 	}
 }
+
+func TestStore_CompressedSession(t *testing.T) {
+	key := cryptutil.NewKey()
+	groups := make([]string, 500)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("group-%d@pomerium.io", i)
+	}
+	state := &sessions.State{Email: "user@domain.com", User: "user", Groups: groups}
+
+	countCookies := func(t *testing.T, encoder encoding.MarshalUnmarshaler) int {
+		s, err := NewStore(&Options{Name: "_pomerium", Expire: 10 * time.Second}, encoder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		if err := s.SaveSession(w, nil, state); err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest("GET", "/", nil)
+		cookies := w.Result().Cookies()
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		raw, err := s.LoadSession(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got sessions.State
+		if err := encoder.Unmarshal([]byte(raw), &got); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(state, &got); diff != "" {
+			t.Errorf("Store.LoadSession() got = %s", diff)
+		}
+		return len(cookies)
+	}
+
+	plain, err := jws.NewHS256Signer(key, "pomerium.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := jws.NewCompressedHS256Signer(key, "pomerium.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countCookies(t, plain); n <= 1 {
+		t.Errorf("expected uncompressed session to be chunked, got %d cookies", n)
+	}
+	if n := countCookies(t, compressed); n != 1 {
+		t.Errorf("expected compressed session to fit in a single cookie, got %d cookies", n)
+	}
+	compressedHS512, err := jws.NewCompressedHMACSigner("HS512", key, "pomerium.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countCookies(t, compressedHS512); n != 1 {
+		t.Errorf("expected compressed HS512 session to fit in a single cookie, got %d cookies", n)
+	}
+}
+
+func TestStore_MaxChunks(t *testing.T) {
+	key := cryptutil.NewKey()
+	encoder, err := jws.NewHS256Signer(key, "pomerium.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := make([]string, 500)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("group-%d@pomerium.io", i)
+	}
+	state := &sessions.State{Email: "user@domain.com", User: "user", Groups: groups}
+
+	w := httptest.NewRecorder()
+	s, err := NewStore(&Options{Name: "_pomerium"}, encoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveSession(w, nil, state); err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) < 3 {
+		t.Fatalf("expected session to be split into at least 3 chunks, got %d", len(cookies))
+	}
+
+	tests := []struct {
+		name      string
+		maxChunks int
+		wantErr   error
+	}{
+		{"default", 0, nil},
+		{"within limit", len(cookies) - 1, nil},
+		{"too many chunks", len(cookies) - 2, sessions.ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewStore(&Options{Name: "_pomerium", MaxChunks: tt.maxChunks}, encoder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest("GET", "/", nil)
+			for _, cookie := range cookies {
+				r.AddCookie(cookie)
+			}
+			_, err = s.LoadSession(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Store.LoadSession() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStore_ChunkThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		size      int
+		want      bool
+	}{
+		{"default under", 0, MaxChunkSize, false},
+		{"default over", 0, MaxChunkSize + 1, true},
+		{"under", 1000, 1000, false},
+		{"over", 1000, 1001, true},
+		{"capped", MaxChunkSize * 2, MaxChunkSize + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Store{Name: "_pomerium", ChunkThreshold: tt.threshold}
+			if got := s.shouldChunk(tt.size); got != tt.want {
+				t.Errorf("Store.shouldChunk(%d) = %v, want %v", tt.size, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("save", func(t *testing.T) {
+		key := cryptutil.NewKey()
+		encoder, err := jws.NewHS256Signer(key, "pomerium.io")
+		if err != nil {
+			t.Fatal(err)
+		}
+		groups := make([]string, 50)
+		for i := range groups {
+			groups[i] = fmt.Sprintf("group-%d@pomerium.io", i)
+		}
+		state := &sessions.State{Email: "user@domain.com", User: "user", Groups: groups}
+
+		for _, threshold := range []int{0, 500} {
+			s, err := NewStore(&Options{Name: "_pomerium", ChunkThreshold: threshold}, encoder)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := httptest.NewRecorder()
+			if err := s.SaveSession(w, nil, state); err != nil {
+				t.Fatal(err)
+			}
+			cookies := w.Result().Cookies()
+			if threshold == 0 && len(cookies) != 1 {
+				t.Errorf("expected a single cookie without a threshold, got %d", len(cookies))
+			}
+			if threshold != 0 && len(cookies) < 2 {
+				t.Errorf("expected the session to be chunked at a %d byte threshold, got %d cookies", threshold, len(cookies))
+			}
+
+			// chunked or not, the session is read back
+			r := httptest.NewRequest("GET", "/", nil)
+			for _, cookie := range cookies {
+				r.AddCookie(cookie)
+			}
+			if _, err := s.LoadSession(r); err != nil {
+				t.Errorf("Store.LoadSession() error = %v", err)
+			}
+		}
+	})
+}
+
+func TestStore_HostPrefix(t *testing.T) {
+	encoder, err := jws.NewHS256Signer(cryptutil.NewKey(), "pomerium.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := make([]string, 50)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("group-%d@pomerium.io", i)
+	}
+	s, err := NewStore(&Options{Name: "__Host-pomerium", Secure: true, ChunkThreshold: 500}, encoder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	if err := s.SaveSession(w, nil, &sessions.State{Email: "user@domain.com", Groups: groups}); err != nil {
+		t.Fatal(err)
+	}
+	s.ClearSession(w, nil)
+
+	// browsers only accept __Host- cookies, including chunks, which are
+	// secure and set without a domain on the root path
+	for _, hdr := range w.Result().Header["Set-Cookie"] {
+		if !strings.HasPrefix(hdr, "__Host-pomerium") {
+			t.Errorf("cookie %q doesn't have the prefix", hdr)
+		}
+		if !strings.Contains(hdr, "; Path=/;") || !strings.Contains(hdr, "; Secure") || strings.Contains(hdr, "Domain=") {
+			t.Errorf("cookie %q doesn't meet the prefix requirements", hdr)
+		}
+	}
+}
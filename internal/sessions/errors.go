@@ -23,6 +23,21 @@ var (
 	// ErrIssuedInTheFuture indicates that the iat field is in the future.
 	ErrIssuedInTheFuture = errors.New("internal/sessions: validation field, token issued in the future (iat)")
 
+	// ErrMaxAgeExceeded indicates that the token was issued (iat) longer ago
+	// than the maximum allowed token age.
+	ErrMaxAgeExceeded = errors.New("internal/sessions: validation failed, token exceeds maximum age (iat)")
+
+	// ErrRevoked indicates that the session id (jti) has been revoked.
+	ErrRevoked = errors.New("internal/sessions: session has been revoked (jti)")
+
+	// ErrInvalidIssuer indicates that the token was issued (iss) by an
+	// issuer which is not allowed.
+	ErrInvalidIssuer = errors.New("internal/sessions: validation failed, issuer is not allowed (iss)")
+
+	// ErrUnexpectedAlgorithm indicates that the token's alg header isn't the
+	// algorithm sessions are signed with, e.g. "none".
+	ErrUnexpectedAlgorithm = errors.New("internal/sessions: validation failed, unexpected signature algorithm (alg)")
+
 	// ErrInvalidAudience indicated invalid aud claim.
 	ErrInvalidAudience = errors.New("internal/sessions: validation failed, invalid audience claim (aud)")
 )
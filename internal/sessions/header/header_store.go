@@ -21,42 +21,71 @@ const (
 // authorization headers.
 type Store struct {
 	authHeader string
-	authType   string
+	authTypes  []string
 	encoder    encoding.Unmarshaler
 }
 
 // NewStore returns a new header store for loading sessions from
-// authorization header as defined in as defined in rfc2617
+// authorization header as defined in as defined in rfc2617, with any of the
+// given authentication types (schemes).
 //
 // NOTA BENE: While most servers do not log Authorization headers by default,
 // you should ensure no other services are logging or leaking your auth headers.
-func NewStore(enc encoding.Unmarshaler, headerType string) *Store {
-	if headerType == "" {
-		headerType = defaultAuthType
+func NewStore(enc encoding.Unmarshaler, headerTypes ...string) *Store {
+	var authTypes []string
+	for _, headerType := range headerTypes {
+		if headerType != "" {
+			authTypes = append(authTypes, headerType)
+		}
+	}
+	if len(authTypes) == 0 {
+		authTypes = []string{defaultAuthType}
 	}
 	return &Store{
 		authHeader: defaultAuthHeader,
-		authType:   headerType,
+		authTypes:  authTypes,
 		encoder:    enc,
 	}
 }
 
 // LoadSession tries to retrieve the token string from the Authorization header.
+// Headers with another authentication type have no session, and those with
+// more than one token are malformed.
 func (as *Store) LoadSession(r *http.Request) (string, error) {
-	jwt := TokenFromHeader(r, as.authHeader, as.authType)
-	if jwt == "" {
-		return "", sessions.ErrNoSessionFound
+	authType, jwt := parseAuthorization(r.Header.Get(as.authHeader))
+	for _, t := range as.authTypes {
+		if !strings.EqualFold(authType, t) {
+			continue
+		}
+		switch {
+		case jwt == "":
+			return "", sessions.ErrNoSessionFound
+		case strings.IndexAny(jwt, " \t") >= 0:
+			return "", sessions.ErrMalformed
+		}
+		return jwt, nil
 	}
-	return jwt, nil
+	return "", sessions.ErrNoSessionFound
 }
 
 // TokenFromHeader retrieves the value of the authorization header from a given
-// request, header key, and authentication type.
+// request, header key, and authentication type. The authentication type is
+// matched case-insensitively, and whitespace around the token is ignored.
 func TokenFromHeader(r *http.Request, authHeader, authType string) string {
-	bearer := r.Header.Get(authHeader)
-	atSize := len(authType)
-	if len(bearer) > atSize && strings.EqualFold(bearer[0:atSize], authType) {
-		return bearer[atSize+1:]
+	t, token := parseAuthorization(r.Header.Get(authHeader))
+	if !strings.EqualFold(t, authType) || strings.IndexAny(token, " \t") >= 0 {
+		return ""
+	}
+	return token
+}
+
+// parseAuthorization splits an authorization header value into its
+// authentication type and the rest of the value, trimmed of whitespace.
+func parseAuthorization(value string) (authType, token string) {
+	value = strings.TrimSpace(value)
+	i := strings.IndexAny(value, " \t")
+	if i < 0 {
+		return value, ""
 	}
-	return ""
+	return value[:i], strings.TrimSpace(value[i:])
 }
@@ -0,0 +1,72 @@
+package header
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pomerium/pomerium/internal/sessions"
+)
+
+func TestStore_LoadSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		types   []string
+		value   string
+		want    string
+		wantErr error
+	}{
+		{"bearer", nil, "Bearer token", "token", nil},
+		{"lower case", nil, "bearer token", "token", nil},
+		{"upper case", nil, "BEARER token", "token", nil},
+		{"extra whitespace", nil, "  Bearer \t  token  ", "token", nil},
+		{"no header", nil, "", "", sessions.ErrNoSessionFound},
+		{"no token", nil, "Bearer   ", "", sessions.ErrNoSessionFound},
+		{"scheme prefix", nil, "Bearertoken", "", sessions.ErrNoSessionFound},
+		{"other scheme", nil, "Basic dXNlcjpwYXNz", "", sessions.ErrNoSessionFound},
+		{"several tokens", nil, "Bearer token other", "", sessions.ErrMalformed},
+		{"one of several types", []string{"Pomerium", "Bearer"}, "bearer token", "token", nil},
+		{"not one of several types", []string{"Pomerium", "Bearer"}, "Pomerium-API-Key key", "", sessions.ErrNoSessionFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewStore(nil, tt.types...)
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.value != "" {
+				r.Header.Set("Authorization", tt.value)
+			}
+			got, err := s.LoadSession(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LoadSession() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("LoadSession() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenFromHeader(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"api key", "Pomerium-API-Key key", "key"},
+		{"mixed case", "pomerium-api-key key", "key"},
+		{"extra whitespace", "Pomerium-API-Key   key ", "key"},
+		{"scheme prefix", "Pomerium key", ""},
+		{"several tokens", "Pomerium-API-Key key other", ""},
+		{"no token", "Pomerium-API-Key", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", tt.value)
+			if got := TokenFromHeader(r, "Authorization", "Pomerium-API-Key"); got != tt.want {
+				t.Errorf("TokenFromHeader() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+var _ Store = &LimitStore{}
+
+// DefaultPoolTimeout is how long a call waits for a connection to the store,
+// if unset.
+const DefaultPoolTimeout = 250 * time.Millisecond
+
+// ErrPoolTimeout is returned by a LimitStore call which couldn't get a
+// connection within the pool timeout.
+var ErrPoolTimeout = errors.New("revocation: timed out waiting for a store connection")
+
+// LimitStore caps the number of concurrent calls to a store, and so the
+// connections they use, so that a burst of requests can't overwhelm it. Calls
+// beyond the limit wait for one to finish, up to a timeout.
+type LimitStore struct {
+	Store
+	// Timeout is how long a call waits for a connection. Defaults to
+	// DefaultPoolTimeout.
+	Timeout time.Duration
+
+	sem      chan struct{}
+	timeouts int64
+}
+
+// NewLimitStore returns a store which makes at most max concurrent calls to s.
+func NewLimitStore(s Store, max int, timeout time.Duration) *LimitStore {
+	return &LimitStore{Store: s, Timeout: timeout, sem: make(chan struct{}, max)}
+}
+
+// Revoke revokes a session id, once a connection is available.
+func (s *LimitStore) Revoke(ctx context.Context, id string, ttl time.Duration) error {
+	if err := s.acquire(ctx); err != nil {
+		return err
+	}
+	defer s.release()
+	return s.Store.Revoke(ctx, id, ttl)
+}
+
+// IsRevoked returns true if the session id has been revoked, once a
+// connection is available.
+func (s *LimitStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	if err := s.acquire(ctx); err != nil {
+		return false, err
+	}
+	defer s.release()
+	return s.Store.IsRevoked(ctx, id)
+}
+
+// RevokeOnce revokes an id unless it's already revoked, once a connection is
+// available.
+func (s *LimitStore) RevokeOnce(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	if err := s.acquire(ctx); err != nil {
+		return false, err
+	}
+	defer s.release()
+	return s.Store.RevokeOnce(ctx, id, ttl)
+}
+
+// InUse returns the number of connections in use.
+func (s *LimitStore) InUse() int { return len(s.sem) }
+
+// Max returns the maximum number of connections.
+func (s *LimitStore) Max() int { return cap(s.sem) }
+
+// Timeouts returns the number of calls which timed out waiting for a
+// connection.
+func (s *LimitStore) Timeouts() int64 { return atomic.LoadInt64(&s.timeouts) }
+
+func (s *LimitStore) acquire(ctx context.Context) error {
+	select {
+	case s.sem <- struct{}{}:
+		return nil
+	default:
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultPoolTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case s.sem <- struct{}{}:
+		return nil
+	case <-timer.C:
+		atomic.AddInt64(&s.timeouts, 1)
+		return ErrPoolTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *LimitStore) release() { <-s.sem }
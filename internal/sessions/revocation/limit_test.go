@@ -0,0 +1,113 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowStore holds each call to IsRevoked until it's released, counting the
+// calls in progress.
+type slowStore struct {
+	Store
+	release chan struct{}
+	active  int64
+	peak    int64
+}
+
+func (s *slowStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	n := atomic.AddInt64(&s.active, 1)
+	defer atomic.AddInt64(&s.active, -1)
+	for {
+		peak := atomic.LoadInt64(&s.peak)
+		if n <= peak || atomic.CompareAndSwapInt64(&s.peak, peak, n) {
+			break
+		}
+	}
+	<-s.release
+	return false, nil
+}
+
+func TestLimitStore(t *testing.T) {
+	slow := &slowStore{Store: NewMemoryStore(), release: make(chan struct{})}
+	s := NewLimitStore(slow, 2, time.Second)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.IsRevoked(context.Background(), "id"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	// wait for the pool to fill up
+	for deadline := time.Now().Add(time.Second); s.InUse() < 2 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+	if got := s.InUse(); got != 2 {
+		t.Errorf("InUse() = %d, want 2", got)
+	}
+	close(slow.release)
+	wg.Wait()
+
+	if peak := atomic.LoadInt64(&slow.peak); peak != 2 {
+		t.Errorf("made %d concurrent calls, want 2", peak)
+	}
+	if got := s.InUse(); got != 0 {
+		t.Errorf("InUse() = %d, want 0", got)
+	}
+	if got := s.Timeouts(); got != 0 {
+		t.Errorf("Timeouts() = %d, want 0", got)
+	}
+}
+
+func TestLimitStore_timeout(t *testing.T) {
+	slow := &slowStore{Store: NewMemoryStore(), release: make(chan struct{})}
+	s := NewLimitStore(slow, 1, 20*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = s.IsRevoked(context.Background(), "id")
+	}()
+	for deadline := time.Now().Add(time.Second); s.InUse() < 1 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	_, err := s.IsRevoked(context.Background(), "id")
+	if !errors.Is(err, ErrPoolTimeout) {
+		t.Errorf("IsRevoked() error = %v, want %v", err, ErrPoolTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("IsRevoked() waited %s for a connection", elapsed)
+	}
+	if got := s.Timeouts(); got != 1 {
+		t.Errorf("Timeouts() = %d, want 1", got)
+	}
+
+	// a cancelled call gives up without counting as a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.IsRevoked(ctx, "id"); !errors.Is(err, context.Canceled) {
+		t.Errorf("IsRevoked() error = %v, want %v", err, context.Canceled)
+	}
+	if got := s.Timeouts(); got != 1 {
+		t.Errorf("Timeouts() = %d, want 1", got)
+	}
+
+	// and the slot is usable once the call holding it finishes
+	close(slow.release)
+	<-done
+	if _, err := s.IsRevoked(context.Background(), "id"); err != nil {
+		t.Errorf("IsRevoked() error = %v", err)
+	}
+	if got := s.InUse(); got != 0 {
+		t.Errorf("InUse() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,74 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Store = &MemoryStore{}
+
+// timeNow is time.Now but pulled out as a variable for tests.
+var timeNow = time.Now
+
+// MemoryStore is an in-memory revocation store.
+type MemoryStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryStore creates a new in-memory revocation store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{revoked: make(map[string]time.Time)}
+}
+
+// Revoke revokes a session id until ttl has passed.
+func (s *MemoryStore) Revoke(ctx context.Context, id string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	now := timeNow()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+	s.revoked[id] = now.Add(ttl)
+	return nil
+}
+
+// RevokeOnce revokes an id until ttl has passed, unless it's already revoked.
+func (s *MemoryStore) RevokeOnce(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, nil
+	}
+	now := timeNow()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+	if _, ok := s.revoked[id]; ok {
+		return false, nil
+	}
+	s.revoked[id] = now.Add(ttl)
+	return true, nil
+}
+
+// sweep drops revocations of sessions which have since expired.
+func (s *MemoryStore) sweep(now time.Time) {
+	for k, expiry := range s.revoked {
+		if !now.Before(expiry) {
+			delete(s.revoked, k)
+		}
+	}
+}
+
+// IsRevoked returns true if the session id has been revoked.
+func (s *MemoryStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.revoked[id]
+	return ok && timeNow().Before(expiry), nil
+}
@@ -0,0 +1,97 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	ctx := context.Background()
+	s := NewMemoryStore()
+	if err := s.Revoke(ctx, "revoked", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Revoke(ctx, "already-expired", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		id    string
+		after time.Duration
+		want  bool
+	}{
+		{"revoked", "revoked", 0, true},
+		{"not revoked", "other", 0, false},
+		{"already expired", "already-expired", 0, false},
+		{"revocation expired", "revoked", time.Minute, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeNow = func() time.Time { return now.Add(tt.after) }
+			got, err := s.IsRevoked(ctx, tt.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("MemoryStore.IsRevoked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_expiredRevocationsAreDropped(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	ctx := context.Background()
+	s := NewMemoryStore()
+	if err := s.Revoke(ctx, "a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	timeNow = func() time.Time { return now.Add(time.Hour) }
+	if err := s.Revoke(ctx, "b", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.revoked["a"]; ok {
+		t.Error("expected expired revocation to be dropped")
+	}
+}
+
+func TestMemoryStore_RevokeOnce(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	ctx := context.Background()
+	s := NewMemoryStore()
+	tests := []struct {
+		name  string
+		id    string
+		ttl   time.Duration
+		after time.Duration
+		want  bool
+	}{
+		{"first use", "a", time.Minute, 0, true},
+		{"replayed", "a", time.Minute, 30 * time.Second, false},
+		{"other id", "b", time.Minute, 30 * time.Second, true},
+		{"no ttl", "c", 0, 30 * time.Second, false},
+		{"after expiry", "a", time.Minute, time.Hour, true},
+	}
+	for _, tt := range tests {
+		timeNow = func() time.Time { return now.Add(tt.after) }
+		got, err := s.RevokeOnce(ctx, tt.id, tt.ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: MemoryStore.RevokeOnce() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package revocation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
+)
+
+var _ Store = &RedisStore{}
+
+// redisKeyPrefix namespaces the keys of revoked session ids.
+const redisKeyPrefix = "pomerium/revoked-session/"
+
+// RedisStore is a revocation store backed by redis, so that revocations are
+// shared between instances. Revoked ids expire along with their sessions.
+type RedisStore struct {
+	db *redis.Client
+}
+
+// NewRedisStore creates a new redis revocation store. If poolSize is set, at
+// most that many connections are opened, and commands wait up to poolTimeout
+// for one.
+func NewRedisStore(addr, password string, poolSize int, poolTimeout time.Duration) (*RedisStore, error) {
+	db := redis.NewClient(&redis.Options{
+		Addr:        addr,
+		Password:    password,
+		PoolSize:    poolSize,
+		PoolTimeout: poolTimeout,
+	})
+	if _, err := db.Ping().Result(); err != nil {
+		return nil, fmt.Errorf("revocation: error connecting to redis: %w", err)
+	}
+	metrics.AddRedisMetrics(db.PoolStats)
+	return &RedisStore{db: db}, nil
+}
+
+// Revoke is equivalent to redis `SET key 1 EX ttl`.
+func (s *RedisStore) Revoke(ctx context.Context, id string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return s.db.WithContext(ctx).Set(redisKeyPrefix+id, "1", ttl).Err()
+}
+
+// IsRevoked is equivalent to redis `EXISTS key`.
+func (s *RedisStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	n, err := s.db.WithContext(ctx).Exists(redisKeyPrefix + id).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// RevokeOnce is equivalent to redis `SET key 1 EX ttl NX`.
+func (s *RedisStore) RevokeOnce(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	if ttl <= 0 {
+		return false, nil
+	}
+	return s.db.WithContext(ctx).SetNX(redisKeyPrefix+id, "1", ttl).Result()
+}
@@ -0,0 +1,79 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var _ Store = &RetryStore{}
+
+// DefaultRetryDelay is the delay before the first retry of a failed store
+// call, if unset.
+const DefaultRetryDelay = 10 * time.Millisecond
+
+// RetryStore retries failed calls to a store, with exponential backoff, to
+// smooth over transient errors such as a brief loss of connection. Retries
+// stop early if the next one wouldn't finish before the context's deadline,
+// and calls which timed out waiting for a connection aren't retried, as that
+// would add to the load the pool limits.
+//
+// RevokeOnce isn't retried: a failed call may still have revoked the id, and a
+// retry would then wrongly report it as already revoked.
+type RetryStore struct {
+	Store
+	// Attempts is the maximum number of attempts of each call, including the
+	// first.
+	Attempts int
+	// Delay is the delay before the first retry, doubled for each one after.
+	// Defaults to DefaultRetryDelay.
+	Delay time.Duration
+}
+
+// NewRetryStore returns a store which retries failed calls to s, up to
+// attempts times in total.
+func NewRetryStore(s Store, attempts int, delay time.Duration) *RetryStore {
+	return &RetryStore{Store: s, Attempts: attempts, Delay: delay}
+}
+
+// Revoke revokes a session id, retrying on failure.
+func (s *RetryStore) Revoke(ctx context.Context, id string, ttl time.Duration) error {
+	return s.retry(ctx, func() error {
+		return s.Store.Revoke(ctx, id, ttl)
+	})
+}
+
+// IsRevoked returns true if the session id has been revoked, retrying on
+// failure.
+func (s *RetryStore) IsRevoked(ctx context.Context, id string) (revoked bool, err error) {
+	err = s.retry(ctx, func() error {
+		revoked, err = s.Store.IsRevoked(ctx, id)
+		return err
+	})
+	return revoked, err
+}
+
+func (s *RetryStore) retry(ctx context.Context, fn func() error) error {
+	delay := s.Delay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil ||
+			attempt >= s.Attempts ||
+			errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+			errors.Is(err, ErrPoolTimeout) {
+			return err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
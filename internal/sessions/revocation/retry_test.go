@@ -0,0 +1,82 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyStore fails the first failures calls to IsRevoked.
+type flakyStore struct {
+	Store
+	failures int
+	calls    int
+}
+
+func (s *flakyStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	s.calls++
+	if s.calls <= s.failures {
+		return false, errors.New("connection reset")
+	}
+	return true, nil
+}
+
+func TestRetryStore(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		timeout   time.Duration
+		wantErr   bool
+		wantCalls int
+	}{
+		{"no errors", 0, 0, false, 1},
+		{"transient error", 2, 0, false, 3},
+		{"persistent error", 10, 0, true, 3},
+		{"deadline", 10, 30 * time.Millisecond, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			flaky := &flakyStore{Store: NewMemoryStore(), failures: tt.failures}
+			s := NewRetryStore(flaky, 3, 20*time.Millisecond)
+			revoked, err := s.IsRevoked(ctx, "id")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("IsRevoked() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !revoked {
+				t.Error("IsRevoked() = false, want true")
+			}
+			if flaky.calls != tt.wantCalls {
+				t.Errorf("IsRevoked() made %d calls, want %d", flaky.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRetryStore_poolTimeout(t *testing.T) {
+	flaky := &flakyStore{Store: NewMemoryStore(), failures: 10}
+	s := NewRetryStore(&poolTimeoutStore{flaky}, 3, time.Millisecond)
+	if _, err := s.IsRevoked(context.Background(), "id"); !errors.Is(err, ErrPoolTimeout) {
+		t.Fatalf("IsRevoked() error = %v, want %v", err, ErrPoolTimeout)
+	}
+	if flaky.calls != 1 {
+		t.Errorf("IsRevoked() made %d calls, want 1", flaky.calls)
+	}
+}
+
+// poolTimeoutStore fails every call to IsRevoked, after making it, with
+// ErrPoolTimeout.
+type poolTimeoutStore struct {
+	*flakyStore
+}
+
+func (s *poolTimeoutStore) IsRevoked(ctx context.Context, id string) (bool, error) {
+	_, _ = s.flakyStore.IsRevoked(ctx, id)
+	return false, ErrPoolTimeout
+}
@@ -0,0 +1,80 @@
+// Package revocation provides stores for the ids (jti) of sessions which have
+// been revoked, such as by signing out, before they expire.
+package revocation
+
+import (
+	"context"
+	"time"
+
+	"github.com/pomerium/pomerium/internal/telemetry/metrics"
+)
+
+// Store tracks revoked session ids.
+type Store interface {
+	// Revoke revokes a session id for ttl, which should be the remaining
+	// lifetime of the session.
+	Revoke(ctx context.Context, id string, ttl time.Duration) error
+	// IsRevoked returns true if the session id has been revoked.
+	IsRevoked(ctx context.Context, id string) (bool, error)
+	// RevokeOnce revokes an id for ttl, unless it's already revoked, and
+	// returns whether it did so. It's used to enforce single-use tokens.
+	RevokeOnce(ctx context.Context, id string, ttl time.Duration) (bool, error)
+}
+
+// Options represents options for configuring a revocation store.
+type Options struct {
+	// RedisAddr is the host:port of a redis server used to share revocations
+	// between instances. If empty, revocations are kept in memory.
+	RedisAddr string
+	// RedisPassword is the optional password used to connect to redis.
+	RedisPassword string
+	// RetryAttempts is the maximum number of attempts of each call to redis,
+	// including the first. Failed calls aren't retried if unset.
+	RetryAttempts int
+	// RetryDelay is the delay before the first retry of a failed call to
+	// redis. Defaults to DefaultRetryDelay.
+	RetryDelay time.Duration
+	// MaxConns is the maximum number of concurrent connections to redis. The
+	// pool isn't bounded beyond redis's default if unset.
+	MaxConns int
+	// PoolTimeout is how long a call waits for a connection to redis, once
+	// MaxConns are in use, before it fails. Defaults to DefaultPoolTimeout.
+	PoolTimeout time.Duration
+}
+
+// sharedMemoryStore is shared by the services running in a single process,
+// so that a session revoked by authenticate is also rejected by authorize.
+var sharedMemoryStore = NewMemoryStore()
+
+// New returns a new revocation store.
+func New(o *Options) (Store, error) {
+	if o.RedisAddr == "" {
+		return sharedMemoryStore, nil
+	}
+	// redis's own pool timeout applies when the pool isn't bounded
+	var poolTimeout time.Duration
+	if o.MaxConns > 0 {
+		poolTimeout = o.PoolTimeout
+		if poolTimeout <= 0 {
+			poolTimeout = DefaultPoolTimeout
+		}
+	}
+	redisStore, err := NewRedisStore(o.RedisAddr, o.RedisPassword, o.MaxConns, poolTimeout)
+	if err != nil {
+		return nil, err
+	}
+	var s Store = redisStore
+	if o.MaxConns > 0 {
+		limited := NewLimitStore(s, o.MaxConns, poolTimeout)
+		metrics.AddRevocationStorePoolMetrics(
+			func() int64 { return int64(limited.InUse()) },
+			func() int64 { return int64(limited.Max()) },
+			limited.Timeouts,
+		)
+		s = limited
+	}
+	if o.RetryAttempts > 1 {
+		return NewRetryStore(s, o.RetryAttempts, o.RetryDelay), nil
+	}
+	return s, nil
+}
@@ -27,6 +27,9 @@ type State struct {
 	// At_hash is an OPTIONAL Access Token hash value
 	// https://ldapwiki.com/wiki/At_hash
 	AccessTokenHash string `json:"at_hash,omitempty"`
+	// HasRefreshToken is whether the access token has a refresh token, so
+	// that the session can be refreshed without the user signing in again.
+	HasRefreshToken bool `json:"has_refresh_token,omitempty"`
 
 	// core pomerium identity claims ; not standard to RFC 7519
 	Email  string   `json:"email"`
@@ -60,6 +63,7 @@ func NewSession(s *State, issuer string, audience []string, accessToken *oauth2.
 	newState.Audience = audience
 	newState.Issuer = issuer
 	newState.AccessTokenHash = fmt.Sprintf("%x", hashutil.Hash(accessToken))
+	newState.HasRefreshToken = accessToken.RefreshToken != ""
 	newState.Expiry = jwt.NewNumericDate(accessToken.Expiry)
 	return newState
 }
@@ -73,6 +73,26 @@ func TestState_IsExpired(t *testing.T) {
 	}
 }
 
+func TestNewSession_hasRefreshToken(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		accessToken *oauth2.Token
+		want        bool
+	}{
+		{"with refresh token", &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)}, true},
+		{"without refresh token", &oauth2.Token{AccessToken: "access", Expiry: time.Now().Add(time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSession(&State{Email: "user@example.com", HasRefreshToken: !tt.want}, "authenticate.example.com", []string{"example.com"}, tt.accessToken)
+			if s.HasRefreshToken != tt.want {
+				t.Errorf("NewSession().HasRefreshToken = %v, want %v", s.HasRefreshToken, tt.want)
+			}
+		})
+	}
+}
+
 func TestState_UnmarshalJSON(t *testing.T) {
 	fixedTime := time.Date(2009, 11, 17, 20, 34, 58, 651387237, time.UTC)
 	timeNow = func() time.Time {
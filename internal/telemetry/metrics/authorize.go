@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/pomerium/pomerium/internal/log"
+)
+
+var (
+	// AuthorizeViews contains opencensus views for the authorize service's
+	// decisions.
+	AuthorizeViews = []*view.View{AuthorizeAllowView, AuthorizeDegradedView}
+
+	authorizeAllow = stats.Int64(
+		"authorize_allow_total",
+		"Total requests allowed by the authorize service",
+		"1")
+
+	// AuthorizeAllowView contains the number of allowed requests, labeled by
+	// whether the session was refreshed to allow the request.
+	AuthorizeAllowView = &view.View{
+		Name:        authorizeAllow.Name(),
+		Description: authorizeAllow.Description(),
+		Measure:     authorizeAllow,
+		TagKeys:     []tag.Key{TagKeyRefreshed},
+		Aggregation: view.Count(),
+	}
+
+	authorizeDegraded = stats.Int64(
+		"authorize_degraded_total",
+		"Total requests allowed by the authorize service in a degraded mode",
+		"1")
+
+	// AuthorizeDegradedView contains the number of requests allowed in a
+	// degraded mode, labeled by the reason, e.g. an unavailable dependency.
+	AuthorizeDegradedView = &view.View{
+		Name:        authorizeDegraded.Name(),
+		Description: authorizeDegraded.Description(),
+		Measure:     authorizeDegraded,
+		TagKeys:     []tag.Key{TagKeyReason},
+		Aggregation: view.Count(),
+	}
+)
+
+// RecordAuthorizeAllow records an allowed request, and whether its session
+// was refreshed, and so re-issued, to allow it. You must register
+// AuthorizeViews or AuthorizeAllowView before calling.
+func RecordAuthorizeAllow(refreshed bool) {
+	if err := stats.RecordWithTags(
+		context.Background(),
+		[]tag.Mutator{tag.Insert(TagKeyRefreshed, strconv.FormatBool(refreshed))},
+		authorizeAllow.M(1),
+	); err != nil {
+		log.Error().Err(err).Msg("telemetry/metrics: failed to record authorize allow")
+	}
+}
+
+// RecordAuthorizeDegraded records a request allowed in a degraded mode, and
+// the reason. You must register AuthorizeViews or AuthorizeDegradedView before
+// calling.
+func RecordAuthorizeDegraded(reason string) {
+	if err := stats.RecordWithTags(
+		context.Background(),
+		[]tag.Mutator{tag.Insert(TagKeyReason, reason)},
+		authorizeDegraded.M(1),
+	); err != nil {
+		log.Error().Err(err).Msg("telemetry/metrics: failed to record authorize degraded")
+	}
+}
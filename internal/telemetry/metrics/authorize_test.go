@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"testing"
+
+	"go.opencensus.io/stats/view"
+)
+
+func Test_RecordAuthorizeAllow(t *testing.T) {
+	view.Unregister(AuthorizeViews...)
+	view.Register(AuthorizeViews...)
+	RecordAuthorizeAllow(true)
+
+	testDataRetrieval(AuthorizeAllowView, t, "{ { {refreshed true} }&{1} }")
+}
+
+func Test_RecordAuthorizeDegraded(t *testing.T) {
+	view.Unregister(AuthorizeViews...)
+	view.Register(AuthorizeViews...)
+	RecordAuthorizeDegraded("revocation-store-unavailable")
+
+	testDataRetrieval(AuthorizeDegradedView, t, "{ { {reason revocation-store-unavailable} }&{1} }")
+}
@@ -14,6 +14,8 @@ var (
 	TagKeyGRPCMethod  = tag.MustNewKey("grpc_method")
 	TagKeyHost        = tag.MustNewKey("host")
 	TagKeyDestination = tag.MustNewKey("destination")
+	TagKeyRefreshed   = tag.MustNewKey("refreshed")
+	TagKeyReason      = tag.MustNewKey("reason")
 )
 
 // Default distributions used by views in this package.
@@ -39,5 +41,6 @@ var (
 		HTTPClientViews,
 		HTTPServerViews,
 		InfoViews,
+		AuthorizeViews,
 	}
 )
@@ -15,7 +15,7 @@ import (
 var (
 	// InfoViews contains opencensus views for informational metrics about
 	// pomerium itself.
-	InfoViews = []*view.View{ConfigLastReloadView, ConfigLastReloadSuccessView}
+	InfoViews = []*view.View{ConfigLastReloadView, ConfigLastReloadSuccessView, SessionCookieChunkedView}
 
 	configLastReload = stats.Int64(
 		"config_last_reload_success_timestamp",
@@ -25,6 +25,10 @@ var (
 		"config_last_reload_success",
 		"Returns 1 if last reload was successful",
 		"1")
+	sessionCookieChunked = stats.Int64(
+		"session_cookie_chunked_total",
+		"Total session cookies split into chunks for exceeding the chunk threshold",
+		"1")
 
 	// ConfigLastReloadView contains the timestamp the configuration was last
 	// reloaded, labeled by service.
@@ -45,6 +49,15 @@ var (
 		TagKeys:     []tag.Key{TagKeyService},
 		Aggregation: view.LastValue(),
 	}
+
+	// SessionCookieChunkedView contains the number of session cookies split
+	// into chunks for exceeding the chunk threshold.
+	SessionCookieChunkedView = &view.View{
+		Name:        sessionCookieChunked.Name(),
+		Description: sessionCookieChunked.Description(),
+		Measure:     sessionCookieChunked,
+		Aggregation: view.Count(),
+	}
 )
 
 // SetConfigInfo records the status, checksum and timestamp of a configuration
@@ -73,6 +86,13 @@ func SetConfigInfo(service string, success bool) {
 	}
 }
 
+// RecordSessionCookieChunked records a session cookie being split into
+// chunks. You must register InfoViews or SessionCookieChunkedView before
+// calling.
+func RecordSessionCookieChunked() {
+	stats.Record(context.Background(), sessionCookieChunked.M(1))
+}
+
 // SetBuildInfo records the pomerium build info. You must call RegisterInfoMetrics to
 // have this exported
 func SetBuildInfo(service string) {
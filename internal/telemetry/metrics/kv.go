@@ -105,3 +105,24 @@ func AddRedisMetrics(stats func() *redis.PoolStats) {
 		registry.addInt64DerivedCumulativeMetric(m.name, m.desc, "redis", m.f)
 	}
 }
+
+// AddRevocationStorePoolMetrics registers metrics of the bounded pool of
+// connections to the session revocation store: the connections in use, the
+// maximum, and the calls which timed out waiting for one.
+func AddRevocationStorePoolMetrics(inUse, max, timeouts func() int64) {
+	gaugeMetrics := []struct {
+		name string
+		desc string
+		f    func() int64
+	}{
+		{"revocation_store_pool_conns_in_use", "Number of connections to the session revocation store in use", inUse},
+		{"revocation_store_pool_max_conns", "Maximum number of connections to the session revocation store", max},
+	}
+
+	for _, m := range gaugeMetrics {
+		registry.addInt64DerivedGaugeMetric(m.name, m.desc, "revocation", m.f)
+	}
+
+	registry.addInt64DerivedCumulativeMetric("revocation_store_pool_timeouts_total",
+		"Total number of times a wait for a session revocation store connection timed out", "revocation", timeouts)
+}
@@ -92,3 +92,32 @@ func Test_AddRedisMetrics(t *testing.T) {
 	}
 
 }
+
+func Test_AddRevocationStorePoolMetrics(t *testing.T) {
+	t.Parallel()
+
+	AddRevocationStorePoolMetrics(
+		func() int64 { return 3 },
+		func() int64 { return 8 },
+		func() int64 { return 5 },
+	)
+
+	tests := []struct {
+		name string
+		want int64
+	}{
+		{"revocation_store_pool_conns_in_use", 3},
+		{"revocation_store_pool_max_conns", 8},
+		{"revocation_store_pool_timeouts_total", 5},
+	}
+
+	labelValues := []metricdata.LabelValue{
+		metricdata.NewLabelValue("revocation"),
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testMetricRetrieval(registry.registry.Read(), t, labelValues, tt.want, tt.name)
+		})
+	}
+}
@@ -28,6 +28,7 @@ func (p *Proxy) registerDashboardHandlers(r *mux.Router) *mux.Router {
 	h.Use(csrf.Protect(
 		p.cookieSecret,
 		csrf.Secure(p.cookieOptions.Secure),
+		csrf.Path("/"),
 		csrf.CookieName(fmt.Sprintf("%s_csrf", p.cookieOptions.Name)),
 		csrf.ErrorHandler(httputil.HandlerFunc(httputil.CSRFFailureHandler)),
 	))
@@ -20,7 +20,6 @@ import (
 	"github.com/pomerium/pomerium/config"
 	"github.com/pomerium/pomerium/internal/cryptutil"
 	"github.com/pomerium/pomerium/internal/encoding"
-	"github.com/pomerium/pomerium/internal/encoding/jws"
 	"github.com/pomerium/pomerium/internal/frontend"
 	"github.com/pomerium/pomerium/internal/grpc"
 	"github.com/pomerium/pomerium/internal/httputil"
@@ -98,17 +97,19 @@ func New(opts config.Options) (*Proxy, error) {
 	decodedCookieSecret, _ := base64.StdEncoding.DecodeString(opts.CookieSecret)
 
 	// used to load and verify JWT tokens signed by the authenticate service
-	encoder, err := jws.NewHS256Signer([]byte(opts.SharedKey), opts.GetAuthenticateURL().Host)
+	encoder, err := opts.GetSessionEncoder([]byte(opts.SharedKey), opts.GetAuthenticateURL().Host)
 	if err != nil {
 		return nil, err
 	}
 
 	cookieOptions := &cookie.Options{
-		Name:     opts.CookieName,
-		Domain:   opts.CookieDomain,
-		Secure:   opts.CookieSecure,
-		HTTPOnly: opts.CookieHTTPOnly,
-		Expire:   opts.CookieExpire,
+		Name:           opts.CookieName,
+		Domain:         opts.CookieDomain,
+		Secure:         opts.CookieSecure,
+		HTTPOnly:       opts.CookieHTTPOnly,
+		Expire:         opts.CookieExpire,
+		MaxChunks:      opts.CookieMaxChunks,
+		ChunkThreshold: opts.CookieChunkThreshold,
 	}
 
 	cookieStore, err := cookie.NewStore(cookieOptions, encoder)